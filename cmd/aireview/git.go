@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/pkg/client"
+)
+
+// git runs a git command in the current directory and returns its output
+func git(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], message)
+	}
+	return stdout.String(), nil
+}
+
+// defaultBase returns the upstream of the current branch, or the remote's
+// default branch
+func defaultBase() (string, error) {
+	for _, ref := range []string{"@{upstream}", "origin/HEAD"} {
+		if _, err := git("rev-parse", "--verify", "--quiet", ref); err == nil {
+			return ref, nil
+		}
+	}
+	return "", fmt.Errorf("the current branch has no upstream and origin/HEAD is not set")
+}
+
+// gitDiff returns the changes head makes since it diverged from base, as a
+// pull request from head into base would show them
+func gitDiff(base, head string, pathspec []string) (string, error) {
+	args := []string{"diff", "--no-color", "--no-ext-diff", base + "..." + head}
+	if len(pathspec) > 0 {
+		args = append(append(args, "--"), pathspec...)
+	}
+	return git(args...)
+}
+
+// gitInfo describes head for the review history and notifications. Fields
+// git cannot provide are left empty.
+func gitInfo(head string) *client.GitInfo {
+	value := func(args ...string) string {
+		out, _ := git(args...)
+		return strings.TrimSpace(out)
+	}
+
+	info := &client.GitInfo{
+		CommitHash: value("rev-parse", head),
+		RepoURL:    value("remote", "get-url", "origin"),
+	}
+	if branch := value("rev-parse", "--abbrev-ref", head); branch != "HEAD" {
+		info.BranchName = branch
+	}
+	if author := strings.SplitN(value("log", "-1", "--format=%an%x00%ae", head), "\x00", 2); len(author) == 2 {
+		info.Author = &client.GitUser{Name: author[0], Email: author[1]}
+	}
+	return info
+}
@@ -0,0 +1,170 @@
+// Command aireview reviews local changes with an AI Gateway before a pull
+// request is opened. It diffs two revisions of the repository in the
+// current directory, sends the diff to the gateway and prints the findings.
+//
+//	export AI_GATEWAY_URL=https://ai-gateway.example.com AI_GATEWAY_KEY=...
+//	aireview -base origin/main
+//	aireview -format sarif > review.sarif
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/output"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/postprocess"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/pkg/client"
+)
+
+// Exit codes
+const (
+	exitOK       = 0
+	exitFindings = 1 // A finding reached -fail-on, or the verdict failed
+	exitError    = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("aireview", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	gateway := flags.String("gateway", os.Getenv("AI_GATEWAY_URL"), "Gateway URL (AI_GATEWAY_URL)")
+	key := flags.String("key", os.Getenv("AI_GATEWAY_KEY"), "Gateway API key (AI_GATEWAY_KEY)")
+	base := flags.String("base", "", "Revision to diff against; defaults to the upstream of the current branch, or origin/HEAD")
+	head := flags.String("head", "HEAD", "Revision to review")
+	provider := flags.String("provider", "", "AI provider; empty uses the gateway default")
+	model := flags.String("model", "", "Model; empty uses the gateway default")
+	language := flags.String("language", "", "Language hint, e.g. go")
+	mode := flags.String("mode", "", "Review mode, e.g. security")
+	minSeverity := flags.String("min-severity", "", "Drop findings below INFO, WARNING or ERROR")
+	format := flags.String("format", "text", "Output format: text, rdjsonl, sarif or json")
+	failOn := flags.String("fail-on", "", "Exit with status 1 when a finding is at least INFO, WARNING or ERROR, or with verdict, when the gateway's verdict is fail")
+	timeout := flags.Duration("timeout", 5*time.Minute, "Time limit for the review")
+	compress := flags.Bool("compress", true, "gzip the diff sent to the gateway")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: aireview [flags] [-- pathspec...]\n\nReviews the changes between -base and -head with an AI Gateway.\n\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitError
+	}
+
+	fail := func(format string, args ...interface{}) int {
+		fmt.Fprintf(stderr, "aireview: "+format+"\n", args...)
+		return exitError
+	}
+	if *gateway == "" {
+		return fail("set -gateway or AI_GATEWAY_URL")
+	}
+	render, ok := renderers[strings.ToLower(*format)]
+	if !ok {
+		return fail("unknown format %q; use text, rdjsonl, sarif or json", *format)
+	}
+	if *minSeverity != "" && postprocess.SeverityRank(*minSeverity) == 0 {
+		return fail("unknown severity %q; use INFO, WARNING or ERROR", *minSeverity)
+	}
+	if *failOn != "" && postprocess.SeverityRank(*failOn) == 0 && !strings.EqualFold(*failOn, "verdict") {
+		return fail("unknown -fail-on %q; use INFO, WARNING, ERROR or verdict", *failOn)
+	}
+
+	if *base == "" {
+		detected, err := defaultBase()
+		if err != nil {
+			return fail("%v; set -base", err)
+		}
+		*base = detected
+	}
+	diff, err := gitDiff(*base, *head, flags.Args())
+	if err != nil {
+		return fail("%v", err)
+	}
+	if strings.TrimSpace(diff) == "" {
+		fmt.Fprintf(stderr, "aireview: no changes between %s and %s\n", *base, *head)
+		return exitOK
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	var options []client.Option
+	if *compress {
+		options = append(options, client.WithCompression())
+	}
+	c := client.New(*gateway, *key, options...)
+	response, err := c.Review(ctx, &client.ReviewRequest{
+		AIProvider:  *provider,
+		AIModel:     *model,
+		Language:    *language,
+		ReviewMode:  *mode,
+		MinSeverity: strings.ToUpper(*minSeverity),
+		GitDiff:     diff,
+		GitInfo:     gitInfo(*head),
+	})
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	if err := render(stdout, response); err != nil {
+		return fail("failed to write results: %v", err)
+	}
+	if strings.EqualFold(*failOn, "verdict") {
+		if response.Verdict != nil && response.Verdict.Status == "fail" {
+			return exitFindings
+		}
+		return exitOK
+	}
+	if *failOn != "" {
+		threshold := postprocess.SeverityRank(*failOn)
+		for _, d := range response.Diagnostics {
+			if postprocess.SeverityRank(d.Severity) >= threshold {
+				return exitFindings
+			}
+		}
+	}
+	return exitOK
+}
+
+// renderers write a review in each output format
+var renderers = map[string]func(w io.Writer, response *client.ReviewResponse) error{
+	"text":    writeText,
+	"rdjsonl": writeRDJSONL,
+	"sarif": func(w io.Writer, response *client.ReviewResponse) error {
+		return writeJSON(w, output.ToSARIF(response.Source, response.Diagnostics))
+	},
+	"json": func(w io.Writer, response *client.ReviewResponse) error {
+		return writeJSON(w, response)
+	},
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeRDJSONL writes one reviewdog diagnostic per line, for
+// reviewdog -f=rdjsonl
+func writeRDJSONL(w io.Writer, response *client.ReviewResponse) error {
+	encoder := json.NewEncoder(w)
+	for _, d := range response.Diagnostics {
+		if err := encoder.Encode(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/pkg/client"
+)
+
+// ANSI colors for severities
+var severityColors = map[string]string{
+	"ERROR":   "\x1b[31m",
+	"WARNING": "\x1b[33m",
+	"INFO":    "\x1b[36m",
+}
+
+const (
+	bold  = "\x1b[1m"
+	dim   = "\x1b[2m"
+	reset = "\x1b[0m"
+)
+
+// writeText prints the findings grouped by file, colored when w is a
+// terminal and NO_COLOR is unset
+func writeText(w io.Writer, response *client.ReviewResponse) error {
+	color := useColor(w)
+	paint := func(code, text string) string {
+		if !color {
+			return text
+		}
+		return code + text + reset
+	}
+
+	var b strings.Builder
+	if response.Overview != "" {
+		fmt.Fprintf(&b, "%s\n\n", response.Overview)
+	}
+
+	diagnostics := append([]client.Diagnostic(nil), response.Diagnostics...)
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i].Location, diagnostics[j].Location
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Range.Start.Line < b.Range.Start.Line
+	})
+
+	counts := make(map[string]int)
+	path := ""
+	for _, d := range diagnostics {
+		counts[d.Severity]++
+		if d.Location.Path != path {
+			path = d.Location.Path
+			fmt.Fprintf(&b, "%s\n", paint(bold, path))
+		}
+		position := fmt.Sprintf("%d:%d", d.Location.Range.Start.Line, max(d.Location.Range.Start.Column, 1))
+		if end := d.Location.Range.End.Line; end > d.Location.Range.Start.Line {
+			position += fmt.Sprintf("-%d", end)
+		}
+		fmt.Fprintf(&b, "  %s %s %s", paint(dim, position),
+			paint(severityColors[d.Severity], fmt.Sprintf("%-7s", d.Severity)), d.Message)
+		if d.Code.Value != "" {
+			fmt.Fprintf(&b, " %s", paint(dim, "["+d.Code.Value+"]"))
+		}
+		b.WriteString("\n")
+		if d.Suggestion != "" {
+			for _, line := range strings.Split(strings.TrimRight(d.Suggestion, "\n"), "\n") {
+				fmt.Fprintf(&b, "      %s\n", paint(dim, line))
+			}
+		}
+	}
+	if len(diagnostics) > 0 {
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "%d errors, %d warnings, %d info", counts["ERROR"], counts["WARNING"], counts["INFO"])
+	if len(response.SkippedFiles) > 0 {
+		fmt.Fprintf(&b, "; skipped %s", strings.Join(response.SkippedFiles, ", "))
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// useColor reports whether w is a terminal that should get colors
+func useColor(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,206 @@
+// Command openapi-check reports where the component schemas of the
+// embedded OpenAPI document and the Go types they describe disagree:
+// properties the document lists that the type doesn't have, and JSON
+// fields of the type the document leaves out. It exits with status 1 when
+// they diverge, so the document can't drift from the models unnoticed.
+//
+//	make openapi-check
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/openapi"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/quota"
+)
+
+// described maps component schemas to the types they describe. Schemas of
+// responses built from maps or types outside these packages aren't
+// checked.
+var described = map[string]interface{}{
+	"GitUser":              models.GitUser{},
+	"GitInfo":              models.GitInfo{},
+	"RepoConfig":           models.RepoConfig{},
+	"VerdictPolicy":        models.VerdictPolicy{},
+	"Verdict":              models.Verdict{},
+	"LicenseHeaderConfig":  models.LicenseHeaderConfig{},
+	"FileContent":          models.FileContent{},
+	"ReviewRequest":        models.ReviewRequest{},
+	"CompareTarget":        models.CompareTarget{},
+	"Position":             models.Position{},
+	"Range":                models.Range{},
+	"Location":             models.Location{},
+	"Code":                 models.Code{},
+	"Diagnostic":           models.Diagnostic{},
+	"LinterReport":         models.LinterReport{},
+	"SuppressionSummary":   models.SuppressionSummary{},
+	"Redaction":            models.Redaction{},
+	"InjectionFinding":     models.InjectionFinding{},
+	"ContextSnippet":       models.ContextSnippet{},
+	"ReviewResponse":       models.ReviewResponse{},
+	"SchemaReport":         models.SchemaReport{},
+	"Patch":                models.Patch{},
+	"CommitMessageReview":  models.CommitMessageReview{},
+	"BatchItemResult":      models.BatchItemResult{},
+	"BatchResponse":        models.BatchResponse{},
+	"CompareResult":        models.CompareResult{},
+	"CompareResponse":      models.CompareResponse{},
+	"FollowupRequest":      models.FollowupRequest{},
+	"FollowupResponse":     models.FollowupResponse{},
+	"FeedbackRequest":      models.FeedbackRequest{},
+	"Feedback":             models.Feedback{},
+	"ReviewRecord":         models.ReviewRecord{},
+	"PromptVersionStats":   models.PromptVersionStats{},
+	"ReviewList":           models.ReviewList{},
+	"AskRequest":           models.AskRequest{},
+	"PRDescriptionRequest": models.PRDescriptionRequest{},
+	"DocsRequest":          models.DocsRequest{},
+	"DocsResponse":         models.DocsResponse{},
+	"DocComment":           models.DocComment{},
+	"PRDescription":        models.PRDescription{},
+	"AskResponse":          models.AskResponse{},
+	"IndexDocument":        models.IndexDocument{},
+	"IndexRequest":         models.IndexRequest{},
+	"IndexStats":           models.IndexStats{},
+	"UsageBucket":          models.UsageBucket{},
+	"TruncatedFile":        models.TruncatedFile{},
+	"FileStats":            models.FileStats{},
+	"PromptPreview":        models.PromptPreview{},
+	"ExpectedFinding":      models.ExpectedFinding{},
+	"EvalRequest":          models.EvalRequest{},
+	"EvalCaseResult":       models.EvalCaseResult{},
+	"EvalResponse":         models.EvalResponse{},
+	"DryRunResponse":       models.DryRunResponse{},
+	"EstimateResponse":     models.EstimateResponse{},
+	"StyleGuide":           models.StyleGuide{},
+	"KeyStats":             providers.KeyStats{},
+	"QuotaLimit":           quota.Limit{},
+	"QuotaStatus":          quota.Status{},
+}
+
+// schema is a JSON schema object from the document
+type schema = map[string]interface{}
+
+func main() {
+	var doc struct {
+		Components struct {
+			Schemas map[string]schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openapi.Spec(), &doc); err != nil {
+		fmt.Fprintf(os.Stderr, "openapi-check: failed to parse the OpenAPI document: %v\n", err)
+		os.Exit(2)
+	}
+
+	names := make([]string, 0, len(described))
+	for name := range described {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		s, ok := doc.Components.Schemas[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: no component schema", name))
+			continue
+		}
+		documented := properties(doc.Components.Schemas, s)
+		fields := jsonFields(reflect.TypeOf(described[name]))
+		for _, field := range sortedKeys(fields) {
+			if !documented[field] {
+				problems = append(problems, fmt.Sprintf("%s.%s: field of %s missing from the document", name, field, reflect.TypeOf(described[name])))
+			}
+		}
+		for _, property := range sortedKeys(documented) {
+			if !fields[property] {
+				problems = append(problems, fmt.Sprintf("%s.%s: documented but not a field of %s", name, property, reflect.TypeOf(described[name])))
+			}
+		}
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "openapi-check: %d differences between internal/openapi/openapi.json and the models\n", len(problems))
+		os.Exit(1)
+	}
+	fmt.Printf("✓ %d schemas match their models\n", len(names))
+}
+
+// properties returns the property names of a schema, including those of
+// the schemas it combines with allOf
+func properties(components map[string]schema, s schema) map[string]bool {
+	for i := 0; i < 10; i++ {
+		ref, ok := s["$ref"].(string)
+		if !ok {
+			break
+		}
+		s = components[strings.TrimPrefix(ref, "#/components/schemas/")]
+	}
+
+	names := make(map[string]bool)
+	if props, ok := s["properties"].(schema); ok {
+		for name := range props {
+			names[name] = true
+		}
+	}
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			if subSchema, ok := sub.(schema); ok {
+				for name := range properties(components, subSchema) {
+					names[name] = true
+				}
+			}
+		}
+	}
+	return names
+}
+
+// jsonFields returns the names a struct's fields are encoded under,
+// including those of embedded structs
+func jsonFields(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for n := range jsonFields(embedded) {
+					names[n] = true
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
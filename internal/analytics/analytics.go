@@ -0,0 +1,94 @@
+package analytics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// TenantStats holds aggregated review statistics for a single tenant
+type TenantStats struct {
+	Reviews     int
+	Diagnostics int
+	ByCategory  map[string]int
+	BySeverity  map[string]int
+	LastReview  time.Time
+}
+
+// Recorder collects per-tenant review statistics in memory
+type Recorder struct {
+	mu      sync.RWMutex
+	tenants map[string]*TenantStats
+}
+
+// NewRecorder creates a new analytics recorder
+func NewRecorder() *Recorder {
+	return &Recorder{
+		tenants: make(map[string]*TenantStats),
+	}
+}
+
+// Record adds the diagnostics of a completed review to the tenant's statistics
+func (r *Recorder) Record(tenant string, diagnostics []models.Diagnostic) {
+	if tenant == "" {
+		tenant = "anonymous"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats, ok := r.tenants[tenant]
+	if !ok {
+		stats = &TenantStats{
+			ByCategory: make(map[string]int),
+			BySeverity: make(map[string]int),
+		}
+		r.tenants[tenant] = stats
+	}
+
+	stats.Reviews++
+	stats.Diagnostics += len(diagnostics)
+	stats.LastReview = time.Now()
+	for _, d := range diagnostics {
+		stats.ByCategory[d.Code.Value]++
+		stats.BySeverity[d.Severity]++
+	}
+}
+
+// Snapshot returns a copy of the statistics of every tenant
+func (r *Recorder) Snapshot() map[string]TenantStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	snapshot := make(map[string]TenantStats, len(r.tenants))
+	for name, stats := range r.tenants {
+		copied := *stats
+		copied.ByCategory = copyCounts(stats.ByCategory)
+		copied.BySeverity = copyCounts(stats.BySeverity)
+		snapshot[name] = copied
+	}
+	return snapshot
+}
+
+// Tenants returns the names of all tenants with recorded reviews
+func (r *Recorder) Tenants() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.tenants))
+	for name := range r.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	copied := make(map[string]int, len(counts))
+	for k, v := range counts {
+		copied[k] = v
+	}
+	return copied
+}
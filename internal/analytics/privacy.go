@@ -0,0 +1,167 @@
+package analytics
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// PrivacyOptions controls how per-tenant statistics are protected when
+// exported as organisation-wide aggregates
+type PrivacyOptions struct {
+	// MinTenants is the minimum number of distinct tenants that must
+	// contribute to a bucket before it is released
+	MinTenants int
+	// MinReviews is the minimum number of reviews a tenant must have before
+	// its statistics are included in any aggregate
+	MinReviews int
+	// Epsilon is the differential privacy budget used for Laplace noise.
+	// Zero disables noise.
+	Epsilon float64
+	// MaxContribution caps what one tenant adds to any released count, so
+	// noise scaled to it hides any single tenant's share. Zero leaves
+	// contributions unclamped and noise scaled as if each added one.
+	MaxContribution int
+	// Seed derives the noise of each released count from the seed and the
+	// count's name, so repeated exports from one seed can't be averaged to
+	// remove it. Nil draws fresh noise every time.
+	Seed []byte
+}
+
+// Export represents an anonymised analytics export
+type Export struct {
+	Tenants     int            `json:"tenants"`
+	Reviews     int            `json:"reviews"`
+	Diagnostics int            `json:"diagnostics"`
+	ByCategory  map[string]int `json:"by_category"`
+	BySeverity  map[string]int `json:"by_severity"`
+	Suppressed  []string       `json:"suppressed_buckets,omitempty"`
+	Noise       bool           `json:"noise_applied"`
+	MinTenants  int            `json:"min_tenants"`
+	MaxCount    int            `json:"max_contribution,omitempty"` // Per-tenant cap applied to each count
+}
+
+// Aggregate builds an organisation-wide export from per-tenant statistics.
+// Tenants below the review threshold are excluded entirely, each tenant's
+// contribution to a count is clamped to MaxContribution, buckets with
+// fewer than MinTenants contributors are suppressed, and Laplace noise
+// scaled to the clamp is added to every released count, the number of
+// tenants included, when an epsilon is configured.
+func Aggregate(tenants map[string]TenantStats, opts PrivacyOptions) *Export {
+	export := &Export{
+		ByCategory: make(map[string]int),
+		BySeverity: make(map[string]int),
+		Noise:      opts.Epsilon > 0,
+		MinTenants: opts.MinTenants,
+		MaxCount:   opts.MaxContribution,
+	}
+	clamp := func(count int) int {
+		if opts.MaxContribution > 0 && count > opts.MaxContribution {
+			return opts.MaxContribution
+		}
+		return count
+	}
+	sensitivity := float64(max(opts.MaxContribution, 1))
+	noisy := func(name string, count int, sensitivity float64) int {
+		return addNoise(count, sensitivity, opts.Epsilon, opts.Seed, name)
+	}
+
+	categoryTenants := make(map[string]int)
+	severityTenants := make(map[string]int)
+	categoryCounts := make(map[string]int)
+	severityCounts := make(map[string]int)
+
+	for _, stats := range tenants {
+		if stats.Reviews < opts.MinReviews {
+			continue
+		}
+		export.Tenants++
+		export.Reviews += clamp(stats.Reviews)
+		export.Diagnostics += clamp(stats.Diagnostics)
+
+		for category, count := range stats.ByCategory {
+			categoryTenants[category]++
+			categoryCounts[category] += clamp(count)
+		}
+		for severity, count := range stats.BySeverity {
+			severityTenants[severity]++
+			severityCounts[severity] += clamp(count)
+		}
+	}
+
+	// Releasing totals for too few tenants would expose individual teams
+	if export.Tenants < opts.MinTenants {
+		return &Export{
+			ByCategory: map[string]int{},
+			BySeverity: map[string]int{},
+			Suppressed: []string{"all"},
+			Noise:      export.Noise,
+			MinTenants: opts.MinTenants,
+			MaxCount:   opts.MaxContribution,
+		}
+	}
+
+	for category, count := range categoryCounts {
+		if categoryTenants[category] < opts.MinTenants {
+			export.Suppressed = append(export.Suppressed, "category:"+category)
+			continue
+		}
+		export.ByCategory[category] = noisy("category:"+category, count, sensitivity)
+	}
+	for severity, count := range severityCounts {
+		if severityTenants[severity] < opts.MinTenants {
+			export.Suppressed = append(export.Suppressed, "severity:"+severity)
+			continue
+		}
+		export.BySeverity[severity] = noisy("severity:"+severity, count, sensitivity)
+	}
+	sort.Strings(export.Suppressed)
+
+	export.Reviews = noisy("reviews", export.Reviews, sensitivity)
+	export.Diagnostics = noisy("diagnostics", export.Diagnostics, sensitivity)
+	// A tenant joining or leaving changes the count by one
+	export.Tenants = noisy("tenants", export.Tenants, 1)
+
+	return export
+}
+
+// addNoise perturbs a count with Laplace noise of scale
+// sensitivity/epsilon, drawn from the seed and the count's name when a
+// seed is set
+func addNoise(count int, sensitivity, epsilon float64, seed []byte, name string) int {
+	if epsilon <= 0 {
+		return count
+	}
+
+	u := uniform(seed, name) - 0.5
+	noise := -(sensitivity / epsilon) * sign(u) * math.Log(1-2*math.Abs(u))
+
+	noisy := int(math.Round(float64(count) + noise))
+	if noisy < 0 {
+		return 0
+	}
+	return noisy
+}
+
+// uniform returns a number in (0, 1): derived from the seed and name when
+// a seed is set, random otherwise
+func uniform(seed []byte, name string) float64 {
+	var bits uint64
+	if seed == nil {
+		bits = rand.Uint64()
+	} else {
+		sum := sha256.Sum256(append(append(append([]byte{}, seed...), 0), name...))
+		bits = binary.BigEndian.Uint64(sum[:8])
+	}
+	// 53 random bits, offset by half a step so neither end is reached
+	return (float64(bits>>11) + 0.5) / (1 << 53)
+}
+
+func sign(x float64) float64 {
+	if x < 0 {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,251 @@
+package anonymize
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// Mapping records the substitutions made while anonymizing a diff so they
+// can be reversed on the diagnostics returned by the provider
+type Mapping struct {
+	paths       map[string]string // original -> anonymized
+	identifiers map[string]string // original -> anonymized
+	reverse     map[string]string // anonymized -> original
+}
+
+var (
+	anonymizedTokenRegex = regexp.MustCompile(`\b(?:ident\d+|file\d+(?:\.[A-Za-z0-9]+)?)\b`)
+	gitHeaderRegex       = regexp.MustCompile(`^diff --git a/(.+) b/(.+)$`)
+)
+
+func newMapping() *Mapping {
+	return &Mapping{
+		paths:       make(map[string]string),
+		identifiers: make(map[string]string),
+		reverse:     make(map[string]string),
+	}
+}
+
+// Diff anonymizes a unified diff: file paths are replaced with opaque names
+// (keeping the extension), comments and string literals are stripped, and
+// every non-keyword identifier is renamed. Line structure is preserved so
+// that diagnostic line numbers remain valid.
+func Diff(text string) (string, *Mapping) {
+	m := newMapping()
+	lines := strings.Split(text, "\n")
+	inBlockComment := false
+	oldRemaining, newRemaining := 0, 0
+
+	for i, line := range lines {
+		inHunk := oldRemaining > 0 || newRemaining > 0
+
+		switch {
+		case inHunk && line != "" && (line[0] == '+' || line[0] == '-' || line[0] == ' '):
+			switch line[0] {
+			case '+':
+				newRemaining--
+			case '-':
+				oldRemaining--
+			default:
+				oldRemaining--
+				newRemaining--
+			}
+			var content string
+			content, inBlockComment = m.code(line[1:], inBlockComment)
+			lines[i] = line[:1] + content
+		case strings.HasPrefix(line, "diff --git "):
+			inBlockComment = false
+			if match := gitHeaderRegex.FindStringSubmatch(line); match != nil {
+				lines[i] = fmt.Sprintf("diff --git a/%s b/%s", m.path(match[1]), m.path(match[2]))
+			}
+		case strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ "):
+			prefix, value := line[:4], line[4:]
+			if value == "/dev/null" {
+				continue
+			}
+			if strings.HasPrefix(value, "a/") || strings.HasPrefix(value, "b/") {
+				lines[i] = prefix + value[:2] + m.path(value[2:])
+			} else {
+				lines[i] = prefix + m.path(value)
+			}
+		case strings.HasPrefix(line, "@@ "):
+			if hunk, ok := diff.ParseHunkHeader(line); ok {
+				oldRemaining, newRemaining = hunk.OldLines, hunk.NewLines
+				// Hunk headers may carry a function name; drop it
+				lines[i] = fmt.Sprintf("@@ -%d,%d +%d,%d @@", hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines)
+			}
+		case line != "" && line[0] == '+' && !strings.HasPrefix(line, "+++"):
+			// Added lines in diffs without hunk headers
+			var content string
+			content, inBlockComment = m.code(line[1:], inBlockComment)
+			lines[i] = line[:1] + content
+		}
+	}
+
+	return strings.Join(lines, "\n"), m
+}
+
+// Restore maps anonymized paths and identifiers in diagnostics back to
+// their original values
+func (m *Mapping) Restore(diagnostics []models.Diagnostic) []models.Diagnostic {
+	restored := make([]models.Diagnostic, len(diagnostics))
+	for i, d := range diagnostics {
+		if original, ok := m.reverse[strings.TrimPrefix(strings.TrimPrefix(d.Location.Path, "b/"), "a/")]; ok {
+			d.Location.Path = original
+		}
+		d.Message = m.RestoreText(d.Message)
+		d.Suggestion = m.RestoreText(d.Suggestion)
+		d.Original = m.RestoreText(d.Original)
+		if d.Fix != nil {
+			fix := *d.Fix
+			fix.Replacement = m.RestoreText(fix.Replacement)
+			d.Fix = &fix
+		}
+		restored[i] = d
+	}
+	return restored
+}
+
+// RestoreText replaces anonymized tokens in free text with their originals
+func (m *Mapping) RestoreText(text string) string {
+	if text == "" {
+		return text
+	}
+	return anonymizedTokenRegex.ReplaceAllStringFunc(text, func(token string) string {
+		if original, ok := m.reverse[token]; ok {
+			return original
+		}
+		return token
+	})
+}
+
+// path returns the anonymized name for a file path
+func (m *Mapping) path(original string) string {
+	if anon, ok := m.paths[original]; ok {
+		return anon
+	}
+	anon := fmt.Sprintf("file%d%s", len(m.paths)+1, path.Ext(original))
+	m.paths[original] = anon
+	m.reverse[anon] = original
+	return anon
+}
+
+// identifier returns the anonymized name for an identifier
+func (m *Mapping) identifier(original string) string {
+	if keywords[original] {
+		return original
+	}
+	if anon, ok := m.identifiers[original]; ok {
+		return anon
+	}
+	anon := fmt.Sprintf("ident%d", len(m.identifiers)+1)
+	m.identifiers[original] = anon
+	m.reverse[anon] = original
+	return anon
+}
+
+// code anonymizes a single line of source code. It returns the rewritten
+// line and whether a block comment is still open at the end of the line.
+func (m *Mapping) code(line string, inBlockComment bool) (string, bool) {
+	var out strings.Builder
+	i := 0
+
+	for i < len(line) {
+		if inBlockComment {
+			end := strings.Index(line[i:], "*/")
+			if end < 0 {
+				return strings.TrimRight(out.String(), " \t"), true
+			}
+			i += end + 2
+			inBlockComment = false
+			continue
+		}
+
+		c := line[i]
+		switch {
+		case strings.HasPrefix(line[i:], "/*"):
+			inBlockComment = true
+			i += 2
+		case strings.HasPrefix(line[i:], "//") || c == '#':
+			return strings.TrimRight(out.String(), " \t"), false
+		case c == '"' || c == '\'' || c == '`':
+			end := closingQuote(line, i)
+			out.WriteByte(c)
+			out.WriteString("str")
+			out.WriteByte(c)
+			i = end
+		case isIdentStart(c):
+			start := i
+			for i < len(line) && isIdentPart(line[i]) {
+				i++
+			}
+			out.WriteString(m.identifier(line[start:i]))
+		case c >= '0' && c <= '9':
+			// Keep numeric literals (including suffixes like 10ms) intact
+			for i < len(line) && isIdentPart(line[i]) {
+				out.WriteByte(line[i])
+				i++
+			}
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+
+	return out.String(), inBlockComment
+}
+
+// closingQuote returns the index just after the string literal starting at i
+func closingQuote(line string, i int) int {
+	quote := line[i]
+	for j := i + 1; j < len(line); j++ {
+		if line[j] == '\\' && quote != '`' {
+			j++
+			continue
+		}
+		if line[j] == quote {
+			return j + 1
+		}
+	}
+	return len(line)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// keywords are language keywords and builtins common across the languages
+// we review. They are kept so the anonymized code remains reviewable.
+var keywords = toSet(`
+break case chan const continue default defer else fallthrough for func go goto if
+import interface map package range return select struct switch type var
+bool byte complex64 complex128 error float32 float64 int int8 int16 int32 int64
+rune string uint uint8 uint16 uint32 uint64 uintptr any
+append cap close copy delete len make new panic print println recover nil true false iota
+abstract async await boolean catch class debugger do enum export extends final finally
+function implements in instanceof let null of private protected public readonly static
+super this throw throws try typeof undefined void while with yield keyof as from get set
+number object symbol bigint never unknown
+and assert def del elif except global is lambda nonlocal not or pass raise None True False self cls
+char double float long short signed unsigned sizeof volatile extern register union auto
+synchronized transient native strictfp
+select insert update where join left right inner outer on group by order having limit
+fn impl mut pub use mod crate trait match loop move ref where
+`)
+
+func toSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
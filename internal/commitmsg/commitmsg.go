@@ -0,0 +1,77 @@
+// Package commitmsg checks commit messages against the Conventional Commits
+// specification (https://www.conventionalcommits.org)
+package commitmsg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxHeaderLength is the longest header accepted; longer ones are cut off
+// by git log --oneline and most code hosts
+const MaxHeaderLength = 72
+
+// Types are the commit types accepted, from the Angular convention most
+// Conventional Commits tooling follows
+var Types = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+
+// header matches type(scope)!: subject
+var header = regexp.MustCompile(`^([A-Za-z]+)(\(([^()\s][^()]*)\))?(!)?: (.*)$`)
+
+// Check returns the ways message breaks the specification; none means it
+// conforms. Lines starting with # are git comments and are ignored.
+func Check(message string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, strings.TrimRight(line, " \t"))
+		}
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 || lines[0] == "" {
+		return []string{"the message is empty, or starts with a blank line"}
+	}
+
+	var problems []string
+	first := lines[0]
+	m := header.FindStringSubmatch(first)
+	if m == nil {
+		problems = append(problems, `the header must have the form "type(scope): subject", e.g. "fix(auth): reject expired tokens"`)
+	} else {
+		kind, subject := m[1], m[5]
+		if !contains(Types, kind) {
+			if contains(Types, strings.ToLower(kind)) {
+				problems = append(problems, fmt.Sprintf("the type %q must be lower-case", kind))
+			} else {
+				problems = append(problems, fmt.Sprintf("the type %q is not one of %s", kind, strings.Join(Types, ", ")))
+			}
+		}
+		switch {
+		case strings.TrimSpace(subject) == "":
+			problems = append(problems, "the subject is empty")
+		case subject != strings.TrimLeft(subject, " "):
+			problems = append(problems, "the subject must follow \": \" with a single space")
+		case strings.HasSuffix(subject, "."):
+			problems = append(problems, "the subject must not end with a period")
+		}
+	}
+	if n := len([]rune(first)); n > MaxHeaderLength {
+		problems = append(problems, fmt.Sprintf("the header is %d characters; keep it within %d", n, MaxHeaderLength))
+	}
+	if len(lines) > 1 && lines[1] != "" {
+		problems = append(problems, "a blank line must separate the header from the body")
+	}
+	return problems
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -2,51 +2,661 @@ package config
 
 import (
 	"fmt"
-	"os"
+	"net/mail"
+	"net/url"
+	"strconv"
 	"strings"
 )
 
 // Config holds all configuration for the AI Gateway
 type Config struct {
-	Port            string
-	APIKeys         []string
-	GoogleAPIKey    string
-	OpenAIAPIKey    string
-	AnthropicAPIKey string
-	MaxDiffSize     int64 // Maximum diff size in bytes
-	DefaultProvider string
-	DefaultModel    string
+	Port                 string
+	APIKeys              []string
+	APIKeyStore          string   // JSON file of hashed client keys with metadata
+	TenantsFile          string   // YAML or JSON tenants with their own provider credentials
+	PolicyFile           string   // YAML or JSON rules restricting which providers requests may use
+	AuthMode             string   // api_key, jwt or both
+	JWTIssuer            string   // OIDC issuer bearer tokens must come from
+	JWTJWKSURL           string   // Empty discovers the key set from JWT_ISSUER
+	JWTAudience          string   // Required aud claim
+	JWTRequiredScopes    []string // Scopes every bearer token must carry
+	JWTClientClaim       string   // Claim identifying the caller
+	GoogleAPIKey         string
+	OpenAIAPIKey         string
+	AnthropicAPIKey      string
+	MaxDiffSize          int64 // Maximum diff size in bytes
+	MaxRequestSize       int64 // Maximum request body size in bytes; zero allows twice MaxDiffSize
+	DefaultProvider      string
+	ProvidersFile        string // YAML or JSON provider manifest; replaces the *_API_KEY providers
+	RoutingFile          string // YAML rules picking the provider and model of requests that name neither
+	KeySelection         string // round-robin or least-rate-limited, for comma-separated *_API_KEY values
+	HealthCheckInterval  int    // Seconds between provider health checks, starting at startup; zero disables
+	ConfigWatchInterval  int    // Seconds between checks of configuration files for changes; zero disables
+	ReviewTimeout        int    // Seconds a review may take when the request sets no timeout_seconds
+	MaxReviewTimeout     int    // Upper bound for a request's timeout_seconds
+	QuestionTimeout      int    // Seconds /ask and follow-up questions may take
+	ProviderTimeouts     string // name=seconds,... per-provider call timeouts
+	HTTPTimeout          int    // Seconds one provider HTTP exchange may take, streamed body included; zero means none
+	HTTPDialTimeout      int    // Seconds to open a connection to a provider
+	HTTPTLSTimeout       int    // Seconds for a provider TLS handshake
+	HTTPIdleTimeout      int    // Seconds an unused provider connection is kept open
+	HTTPIdleConns        int    // Unused connections kept open per provider host
+	HTTP2                bool   // Use HTTP/2 with providers that offer it
+	ProviderWarmup       bool   // Connect to every provider before serving
+	ShutdownTimeout      int    // Seconds to wait for in-flight requests on SIGTERM
+	ShutdownDelay        int    // Seconds /readyz reports draining before the listener closes
+	ReadHeaderTimeout    int    // Seconds a client may take to send request headers
+	ReadTimeout          int    // Seconds a client may take to send a whole request; zero derives it from the body limit
+	IdleTimeout          int    // Seconds an idle keep-alive connection is kept open
+	TLSCertFile          string
+	TLSKeyFile           string
+	TLSAutocertDomains   []string // Hosts to obtain ACME certificates for, instead of TLS_CERT_FILE
+	TLSAutocertCacheDir  string
+	TLSAutocertEmail     string
+	TLSAutocertHTTPAddr  string // Listener for ACME HTTP-01 challenges; empty uses TLS-ALPN-01 only
+	TLSClientCAFile      string // CA bundle client certificates are verified against
+	TLSClientAuth        string // require or optional, when TLS_CLIENT_CA_FILE is set
+	GeminiTransport      string // sdk, rest or auto
+	GeminiEndpoint       string
+	GeminiAPIVersion     string
+	GeminiCacheTTL       int      // Seconds shared review context stays in Gemini's cache; zero disables caching
+	OpenAIBatch          bool     // Send queued batch-class reviews through the OpenAI Batch API
+	EnsembleProviders    []string // provider or provider:model entries for ai_provider=ensemble
+	MockProvider         bool     // Register the mock provider, which answers without calling a model
+	MockResponseFile     string   // JSON review the mock returns; empty uses its built-in rules
+	MockLatency          int      // Milliseconds the mock waits before answering
+	VCRMode              string   // off, record or replay provider HTTP traffic
+	VCRDir               string   // Directory of recorded provider exchanges
+	ModelPricing         string   // model=input:output USD per million tokens overrides
+	DefaultModel         string   // Empty uses the default provider's own default
+	ModelAliases         string   // name=provider:model,...
+	AllowUnlistedModels  bool     // Accept models a provider doesn't list
+	LineValidation       string   // off, clamp or filter
+	OutputFormat         string   // Response format when a request names none
+	DefaultMinSeverity   string   // min_severity when neither the request nor .aireview.yml sets one
+	DefaultMaxIssues     int      // max_issues when neither the request nor .aireview.yml sets one
+	MaxLanguageGroups    int      // Provider calls a mixed-language review is split into; 1 disables splitting
+	VerdictPolicy        string   // verdict:SEVERITY>N rules when neither the request nor .aireview.yml sets any
+	AdminAPIKey          string
+	ReadOnly             bool
+	CompressResponses    bool   // gzip responses for clients that accept it
+	GuidelinesDir        string // Directory named team guidelines are stored in
+	PromptTemplateDir    string
+	PromptVersionsDir    string      // Subdirectories of prompt templates, one per version
+	PromptExperiment     string      // version=weight pairs splitting reviews between prompt versions
+	CategoriesFile       string      // YAML review category taxonomy replacing the built-in six
+	AnonymizeProviders   []string    // Providers that only ever receive anonymized diffs
+	RepoConfigFetch      bool        // Fetch .aireview.yml from GitHub when not sent inline
+	GitHubToken          *Credential // Reread on SIGHUP
+	GitHubTokenRepos     []string    // Repository globs whose base_sha..head_sha diffs may be fetched with GitHubToken
+	IgnorePaths          []string    // Glob patterns of files never sent to providers
+	SkipGenerated        bool        // Skip vendored, lockfile and generated files
+	MaxFileDiffSize      int         // Largest diff of a single file sent in full; zero disables the limit
+	LargeFileStrategy    string      // skip, head-tail or sample, for files over MaxFileDiffSize
+	MaxContinuations     int         // Follow-up requests made to finish a response cut off by the token limit
+	JSONRepairRetry      bool        // Ask the model to fix a response that isn't valid JSON
+	RedactSecrets        bool        // Replace credentials in diffs before they reach a provider
+	FetchFileContext     bool        // Fetch full changed files from GitHub for prompt context
+	FileContextMaxSize   int         // Byte budget for full file contents in a prompt
+	RemoteDiffHosts      []string    // Hosts the gateway may fetch base_sha..head_sha diffs from; empty disables
+	RAGEmbeddingProvider string      // Provider used for repository index embeddings
+	RAGEmbeddingModel    string
+	RAGStorePath         string // File the repository index is persisted to
+	RAGTopK              int    // Snippets retrieved per review
+	RAGMaxSize           int    // Byte budget for retrieved snippets in a prompt
+	FollowupTTLHours     int    // How long reviews accept follow-up questions; zero disables them
+	FollowupMaxReviews   int    // Reviews kept in memory for follow-up questions
+	HistoryDatabase      string // SQLite file or postgres:// URL completed reviews are stored in
+	HistoryMaxReviews    int    // Reviews kept in memory without HistoryDatabase; zero disables the history
+	HistoryPrompts       bool   // Keep each review's prompts in the history for the dashboard
+	DashboardURL         string // Public address of /ui/ that diagnostics link back to
+	FeedbackPath         string // JSON-lines file diagnostic feedback is persisted to
+	FeedbackExamples     int    // Rejected findings shown to the model per review
+
+	// Rate limiting and scheduling
+	RateLimitTiers       string // name=rpm:burst:priority,...
+	APIKeyTiers          string // key=tier,...
+	DefaultRateLimitTier string
+	MaxConcurrentReviews int // Zero means unlimited
+
+	// Monthly quotas
+	QuotaStorePath      string  // JSON file usage is persisted to; empty keeps it in memory
+	DefaultTokenQuota   int64   // Monthly tokens for callers without a key or tenant quota; zero is unlimited
+	DefaultCostQuotaUSD float64 // Monthly estimated cost for the same callers; zero is unlimited
+
+	// Review callbacks
+	WebhookSecret       string   // Signs callback payloads; empty disables callback_url
+	WebhookMaxAttempts  int      // Delivery attempts per callback
+	WebhookAllowedHosts []string // Hosts callbacks may be sent to, at any address; empty allows any public host
+	WebhookAllowHTTP    bool     // Accept plain http callback URLs
+
+	// Asynchronous review workers, per priority class
+	JobInteractiveWorkers int    // Workers reviewing interactive requests
+	JobBatchWorkers       int    // Workers reviewing batch requests
+	JobQueueSize          int    // Requests each class queues while its workers are busy
+	JobRedisURL           string // Keeps queued requests in Redis, shared by every replica; empty keeps them in memory
+	JobRedisPrefix        string // Prefix of the Redis keys
+
+	// One review per pull request head and caller
+	DedupReviews  bool   // Share the review of the same changes instead of reviewing them again
+	DedupRedisURL string // Coordinates replicas; empty uses JobRedisURL, and without either only this replica's reviews are shared
+	DedupTTL      int    // Seconds a finished review is shared
+
+	// POST /review/batch
+	BatchMaxItems    int // Reviews one batch may hold
+	BatchConcurrency int // Reviews of one batch run at once
+
+	// Scheduled reviews of branches
+	ScheduledReviewsFile string // YAML schedules reviewing branch pairs or recent commits
+
+	// Review requests read from a message queue
+	IngestBroker       string // nats or kafka; empty disables the consumer
+	IngestURL          string // NATS server, or the Kafka REST Proxy
+	IngestUsername     string
+	IngestPassword     string // With no username, sent to NATS as a token
+	IngestTopic        string // Subject or topic review requests are read from
+	IngestResultsTopic string // Subject or topic results are published to
+	IngestGroup        string // Queue group or consumer group shared by every gateway
+	IngestConcurrency  int    // Queued requests reviewed at once
+
+	// Review notifications
+	NotificationsFile string // YAML or JSON routes sending review summaries per tenant or repository
+	SlackWebhookURL   string // Incoming webhook notified of every review
+	SlackBotToken     string // Bot token posting every review to SlackChannel
+	SlackChannel      string
+	TeamsWebhookURL   string   // Teams incoming webhook or Workflows URL notified of every review
+	NotifyWebhookURL  string   // Generic JSON webhook notified of every review
+	NotifyWebhookKey  string   // Signs NotifyWebhookURL deliveries; optional
+	EmailReportTo     []string // Recipients of every review's report
+	SMTPHost          string
+	SMTPPort          int
+	SMTPUsername      string
+	SMTPPassword      string
+	SMTPFrom          string
+	SMTPTLS           string // starttls, tls or none
+
+	// Shadow traffic settings
+	ShadowProvider      string
+	ShadowModel         string
+	ShadowReviewMode    string
+	ShadowPercent       float64
+	ShadowOutputPath    string
+	ShadowPromptVersion string
+	ShadowMaxConcurrent int
+
+	// Evaluation against a golden set of labeled diffs
+	EvalDir           string
+	EvalLineTolerance int
+
+	// Analytics export privacy settings
+	AnalyticsMinTenants   int
+	AnalyticsMinReviews   int
+	AnalyticsNoiseEpsilon float64
+	AnalyticsMaxContrib   int // Cap on one tenant's share of each count; 0 leaves shares unclamped
+	AnalyticsNoiseWindow  int // Minutes an export, and its noise, is reused
 }
 
 // Load reads configuration from environment variables
 func Load() *Config {
 	return &Config{
-		Port:            getEnv("PORT", "8080"),
-		APIKeys:         parseAPIKeys(getEnv("API_KEYS", "")),
-		GoogleAPIKey:    getEnv("GOOGLE_API_KEY", ""),
-		OpenAIAPIKey:    getEnv("OPENAI_API_KEY", ""),
-		AnthropicAPIKey: getEnv("ANTHROPIC_API_KEY", ""),
-		MaxDiffSize:     10 * 1024 * 1024, // 10MB default
-		DefaultProvider: getEnv("DEFAULT_AI_PROVIDER", "google"),
-		DefaultModel:    getEnv("DEFAULT_AI_MODEL", "gemini-2.0-flash"),
+		Port:                 getEnv("PORT", "8080"),
+		APIKeys:              parseList(Secret("API_KEYS")),
+		APIKeyStore:          getEnv("API_KEY_STORE", ""),
+		TenantsFile:          getEnv("TENANTS_FILE", ""),
+		PolicyFile:           getEnv("POLICY_FILE", ""),
+		AuthMode:             strings.ToLower(getEnv("AUTH_MODE", "api_key")),
+		JWTIssuer:            getEnv("JWT_ISSUER", ""),
+		JWTJWKSURL:           getEnv("JWT_JWKS_URL", ""),
+		JWTAudience:          getEnv("JWT_AUDIENCE", ""),
+		JWTRequiredScopes:    strings.Fields(strings.ReplaceAll(getEnv("JWT_REQUIRED_SCOPES", ""), ",", " ")),
+		JWTClientClaim:       getEnv("JWT_CLIENT_CLAIM", "sub"),
+		GoogleAPIKey:         Secret("GOOGLE_API_KEY"),
+		OpenAIAPIKey:         Secret("OPENAI_API_KEY"),
+		AnthropicAPIKey:      Secret("ANTHROPIC_API_KEY"),
+		MaxDiffSize:          int64(getEnvInt("MAX_DIFF_SIZE", 10*1024*1024)), // 10MB default
+		MaxRequestSize:       int64(getEnvInt("MAX_REQUEST_SIZE", 0)),
+		DefaultProvider:      getEnv("DEFAULT_AI_PROVIDER", "google"),
+		RoutingFile:          getEnv("ROUTING_FILE", ""),
+		ProvidersFile:        getEnv("PROVIDERS_FILE", ""),
+		KeySelection:         strings.ToLower(getEnv("KEY_SELECTION", "round-robin")),
+		HealthCheckInterval:  getEnvInt("PROVIDER_CHECK_INTERVAL", 300),
+		ConfigWatchInterval:  getEnvInt("CONFIG_WATCH_INTERVAL", 30),
+		ReviewTimeout:        getEnvInt("REVIEW_TIMEOUT", 120),
+		MaxReviewTimeout:     getEnvInt("MAX_REVIEW_TIMEOUT", 600),
+		QuestionTimeout:      getEnvInt("QUESTION_TIMEOUT", 60),
+		ProviderTimeouts:     getEnv("PROVIDER_TIMEOUTS", ""),
+		HTTPTimeout:          getEnvInt("PROVIDER_HTTP_TIMEOUT", 900),
+		HTTPDialTimeout:      getEnvInt("PROVIDER_DIAL_TIMEOUT", 10),
+		HTTPTLSTimeout:       getEnvInt("PROVIDER_TLS_TIMEOUT", 10),
+		HTTPIdleTimeout:      getEnvInt("PROVIDER_IDLE_TIMEOUT", 90),
+		HTTPIdleConns:        getEnvInt("PROVIDER_IDLE_CONNS", 16),
+		HTTP2:                getEnvBool("PROVIDER_HTTP2", true),
+		ProviderWarmup:       getEnvBool("PROVIDER_WARMUP", true),
+		ShutdownTimeout:      getEnvInt("SHUTDOWN_TIMEOUT", 30),
+		ShutdownDelay:        getEnvInt("SHUTDOWN_DELAY", 0),
+		ReadHeaderTimeout:    getEnvInt("READ_HEADER_TIMEOUT", 10),
+		ReadTimeout:          getEnvInt("READ_TIMEOUT", 0),
+		IdleTimeout:          getEnvInt("IDLE_TIMEOUT", 120),
+		TLSCertFile:          getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:           getEnv("TLS_KEY_FILE", ""),
+		TLSAutocertDomains:   parseList(getEnv("TLS_AUTOCERT_DOMAINS", "")),
+		TLSAutocertCacheDir:  getEnv("TLS_AUTOCERT_CACHE_DIR", "./data/autocert"),
+		TLSAutocertEmail:     getEnv("TLS_AUTOCERT_EMAIL", ""),
+		TLSAutocertHTTPAddr:  getEnv("TLS_AUTOCERT_HTTP_ADDR", ""),
+		TLSClientCAFile:      getEnv("TLS_CLIENT_CA_FILE", ""),
+		TLSClientAuth:        strings.ToLower(getEnv("TLS_CLIENT_AUTH", "require")),
+		GeminiTransport:      strings.ToLower(getEnv("GEMINI_TRANSPORT", "auto")),
+		GeminiEndpoint:       getEnv("GEMINI_API_ENDPOINT", "https://generativelanguage.googleapis.com"),
+		GeminiAPIVersion:     getEnv("GEMINI_API_VERSION", "v1beta"),
+		GeminiCacheTTL:       getEnvInt("GEMINI_CONTEXT_CACHE_TTL", 0),
+		OpenAIBatch:          getEnvBool("OPENAI_BATCH", false),
+		EnsembleProviders:    parseList(getEnv("ENSEMBLE_PROVIDERS", "")),
+		MockProvider:         getEnvBool("MOCK_PROVIDER", false),
+		MockResponseFile:     getEnv("MOCK_RESPONSE_FILE", ""),
+		MockLatency:          getEnvInt("MOCK_LATENCY_MS", 0),
+		VCRMode:              strings.ToLower(getEnv("VCR_MODE", "off")),
+		VCRDir:               getEnv("VCR_DIR", "./testdata/vcr"),
+		ModelPricing:         getEnv("MODEL_PRICING", ""),
+		DefaultModel:         getEnv("DEFAULT_AI_MODEL", ""),
+		ModelAliases:         getEnv("MODEL_ALIASES", ""),
+		AllowUnlistedModels:  getEnvBool("ALLOW_UNLISTED_MODELS", false),
+		LineValidation:       strings.ToLower(getEnv("LINE_VALIDATION", "clamp")),
+		OutputFormat:         strings.ToLower(getEnv("OUTPUT_FORMAT", "diagnostic")),
+		DefaultMinSeverity:   strings.ToUpper(getEnv("DEFAULT_MIN_SEVERITY", "")),
+		DefaultMaxIssues:     getEnvInt("DEFAULT_MAX_ISSUES", 0),
+		MaxLanguageGroups:    getEnvInt("MAX_LANGUAGE_GROUPS", 4),
+		VerdictPolicy:        getEnv("VERDICT_POLICY", "fail:ERROR>0,warn:WARNING>0"),
+		AdminAPIKey:          Secret("ADMIN_API_KEY"),
+		ReadOnly:             getEnvBool("READ_ONLY_MODE", false),
+		CompressResponses:    getEnvBool("RESPONSE_COMPRESSION", true),
+		PromptTemplateDir:    getEnv("PROMPT_TEMPLATE_DIR", ""),
+		PromptVersionsDir:    getEnv("PROMPT_VERSIONS_DIR", ""),
+		PromptExperiment:     getEnv("PROMPT_EXPERIMENT", ""),
+		CategoriesFile:       getEnv("CATEGORIES_FILE", ""),
+		GuidelinesDir:        getEnv("GUIDELINES_DIR", ""),
+		AnonymizeProviders:   parseList(getEnv("ANONYMIZE_PROVIDERS", "")),
+		RepoConfigFetch:      getEnvBool("REPO_CONFIG_FETCH", false),
+		GitHubToken:          NewCredential(Secret("GITHUB_TOKEN")),
+		GitHubTokenRepos:     parseList(getEnv("GITHUB_TOKEN_REPOS", "")),
+		IgnorePaths:          parseList(getEnv("IGNORE_PATHS", "")),
+		SkipGenerated:        getEnvBool("SKIP_GENERATED_FILES", true),
+		MaxFileDiffSize:      getEnvInt("MAX_FILE_DIFF_SIZE", 100*1024),
+		LargeFileStrategy:    strings.ToLower(getEnv("LARGE_FILE_STRATEGY", "head-tail")),
+		MaxContinuations:     getEnvInt("MAX_CONTINUATIONS", 2),
+		JSONRepairRetry:      getEnvBool("JSON_REPAIR_RETRY", false),
+		RedactSecrets:        getEnvBool("REDACT_SECRETS", true),
+		FetchFileContext:     getEnvBool("FETCH_FILE_CONTEXT", false),
+		FileContextMaxSize:   getEnvInt("FILE_CONTEXT_MAX_SIZE", 100*1024),
+		RemoteDiffHosts:      parseList(getEnv("REMOTE_DIFF_HOSTS", "github.com,gitlab.com,bitbucket.org")),
+		RAGEmbeddingProvider: getEnv("RAG_EMBEDDING_PROVIDER", ""),
+		RAGEmbeddingModel:    getEnv("RAG_EMBEDDING_MODEL", ""),
+		RAGStorePath:         getEnv("RAG_STORE_PATH", ""),
+		RAGTopK:              getEnvInt("RAG_TOP_K", 5),
+		RAGMaxSize:           getEnvInt("RAG_MAX_SIZE", 20*1024),
+		FollowupTTLHours:     getEnvInt("FOLLOWUP_TTL_HOURS", 24),
+		FollowupMaxReviews:   getEnvInt("FOLLOWUP_MAX_REVIEWS", 1000),
+		HistoryDatabase:      Secret("HISTORY_DATABASE"),
+		HistoryMaxReviews:    getEnvInt("HISTORY_MAX_REVIEWS", 10000),
+		HistoryPrompts:       getEnvBool("HISTORY_PROMPTS", false),
+		DashboardURL:         getEnv("DASHBOARD_URL", ""),
+		FeedbackPath:         getEnv("FEEDBACK_PATH", ""),
+		FeedbackExamples:     getEnvInt("FEEDBACK_EXAMPLES", 5),
+
+		RateLimitTiers:       getEnv("RATE_LIMIT_TIERS", ""),
+		APIKeyTiers:          getEnv("API_KEY_TIERS", ""),
+		DefaultRateLimitTier: getEnv("DEFAULT_RATE_LIMIT_TIER", ""),
+		MaxConcurrentReviews: getEnvInt("MAX_CONCURRENT_REVIEWS", 0),
+
+		QuotaStorePath:      getEnv("QUOTA_STORE_PATH", ""),
+		DefaultTokenQuota:   int64(getEnvInt("DEFAULT_MONTHLY_TOKEN_QUOTA", 0)),
+		DefaultCostQuotaUSD: getEnvFloat("DEFAULT_MONTHLY_COST_QUOTA", 0),
+
+		WebhookSecret:       Secret("WEBHOOK_SECRET"),
+		WebhookMaxAttempts:  getEnvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+		WebhookAllowedHosts: parseList(getEnv("WEBHOOK_ALLOWED_HOSTS", "")),
+		WebhookAllowHTTP:    getEnvBool("WEBHOOK_ALLOW_HTTP", false),
+
+		JobInteractiveWorkers: getEnvInt("JOB_INTERACTIVE_WORKERS", 8),
+		JobBatchWorkers:       getEnvInt("JOB_BATCH_WORKERS", 2),
+		JobQueueSize:          getEnvInt("JOB_QUEUE_SIZE", 100),
+		JobRedisURL:           Secret("JOB_REDIS_URL"),
+		JobRedisPrefix:        getEnv("JOB_REDIS_PREFIX", "ai-review:jobs"),
+
+		DedupReviews:  getEnvBool("DEDUP_REVIEWS", false),
+		DedupRedisURL: Secret("DEDUP_REDIS_URL"),
+		DedupTTL:      getEnvInt("DEDUP_TTL", 600),
+
+		BatchMaxItems:    getEnvInt("BATCH_MAX_ITEMS", 100),
+		BatchConcurrency: getEnvInt("BATCH_CONCURRENCY", 4),
+
+		ScheduledReviewsFile: getEnv("SCHEDULED_REVIEWS_FILE", ""),
+
+		IngestBroker:       getEnv("INGEST_BROKER", ""),
+		IngestURL:          getEnv("INGEST_URL", ""),
+		IngestUsername:     getEnv("INGEST_USERNAME", ""),
+		IngestPassword:     Secret("INGEST_PASSWORD"),
+		IngestTopic:        getEnv("INGEST_TOPIC", "review-requests"),
+		IngestResultsTopic: getEnv("INGEST_RESULTS_TOPIC", "review-results"),
+		IngestGroup:        getEnv("INGEST_GROUP", "ai-review-gateway"),
+		IngestConcurrency:  getEnvInt("INGEST_CONCURRENCY", 4),
+
+		NotificationsFile: getEnv("NOTIFICATIONS_FILE", ""),
+		SlackWebhookURL:   Secret("SLACK_WEBHOOK_URL"),
+		SlackBotToken:     Secret("SLACK_BOT_TOKEN"),
+		SlackChannel:      getEnv("SLACK_CHANNEL", ""),
+		TeamsWebhookURL:   Secret("TEAMS_WEBHOOK_URL"),
+		NotifyWebhookURL:  Secret("NOTIFY_WEBHOOK_URL"),
+		NotifyWebhookKey:  Secret("NOTIFY_WEBHOOK_SECRET"),
+		EmailReportTo:     parseList(getEnv("EMAIL_REPORT_TO", "")),
+		SMTPHost:          getEnv("SMTP_HOST", ""),
+		SMTPPort:          getEnvInt("SMTP_PORT", 587),
+		SMTPUsername:      getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:      Secret("SMTP_PASSWORD"),
+		SMTPFrom:          getEnv("SMTP_FROM", ""),
+		SMTPTLS:           strings.ToLower(getEnv("SMTP_TLS", "starttls")),
+
+		ShadowProvider:      getEnv("SHADOW_PROVIDER", ""),
+		ShadowModel:         getEnv("SHADOW_MODEL", ""),
+		ShadowReviewMode:    getEnv("SHADOW_REVIEW_MODE", ""),
+		ShadowPercent:       getEnvFloat("SHADOW_PERCENT", 0),
+		ShadowOutputPath:    getEnv("SHADOW_OUTPUT_PATH", ""),
+		ShadowPromptVersion: getEnv("SHADOW_PROMPT_VERSION", ""),
+		ShadowMaxConcurrent: getEnvInt("SHADOW_MAX_CONCURRENT", 4),
+
+		EvalDir:           getEnv("EVAL_DIR", ""),
+		EvalLineTolerance: getEnvInt("EVAL_LINE_TOLERANCE", 3),
+
+		AnalyticsMinTenants:   getEnvInt("ANALYTICS_MIN_TENANTS", 3),
+		AnalyticsMinReviews:   getEnvInt("ANALYTICS_MIN_REVIEWS", 5),
+		AnalyticsNoiseEpsilon: getEnvFloat("ANALYTICS_NOISE_EPSILON", 0),
+		AnalyticsMaxContrib:   getEnvInt("ANALYTICS_MAX_CONTRIBUTION", 100),
+		AnalyticsNoiseWindow:  getEnvInt("ANALYTICS_NOISE_WINDOW", 60),
 	}
 }
 
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
-	if len(c.APIKeys) == 0 {
-		return fmt.Errorf("at least one API key must be configured via API_KEYS environment variable")
+	switch c.AuthMode {
+	case "api_key", "jwt", "both":
+	default:
+		return fmt.Errorf("AUTH_MODE must be one of api_key, jwt or both")
+	}
+	if c.AuthMode != "jwt" && len(c.APIKeys) == 0 && c.APIKeyStore == "" {
+		return fmt.Errorf("at least one API key must be configured via API_KEYS or API_KEY_STORE")
+	}
+	if c.AuthMode != "api_key" && c.JWTIssuer == "" {
+		return fmt.Errorf("JWT_ISSUER is required when AUTH_MODE is %s", c.AuthMode)
+	}
+	if c.AuthMode != "api_key" && c.JWTAudience == "" {
+		return fmt.Errorf("JWT_AUDIENCE is required when AUTH_MODE is %s", c.AuthMode)
 	}
 
-	if c.GoogleAPIKey == "" && c.OpenAIAPIKey == "" && c.AnthropicAPIKey == "" {
+	if c.ProvidersFile == "" && !c.MockProvider && c.GoogleAPIKey == "" && c.OpenAIAPIKey == "" && c.AnthropicAPIKey == "" {
 		return fmt.Errorf("at least one AI provider API key must be configured")
 	}
 
+	if c.PromptExperiment != "" && c.PromptVersionsDir == "" {
+		return fmt.Errorf("PROMPT_EXPERIMENT requires PROMPT_VERSIONS_DIR")
+	}
+
+	switch c.LineValidation {
+	case "off", "clamp", "filter":
+	default:
+		return fmt.Errorf("LINE_VALIDATION must be one of off, clamp or filter")
+	}
+
+	switch c.KeySelection {
+	case "round-robin", "least-rate-limited":
+	default:
+		return fmt.Errorf("KEY_SELECTION must be round-robin or least-rate-limited")
+	}
+
+	switch c.OutputFormat {
+	case "diagnostic", "codequality", "sarif":
+	default:
+		return fmt.Errorf("OUTPUT_FORMAT must be diagnostic, codequality or sarif")
+	}
+
+	switch c.DefaultMinSeverity {
+	case "", "INFO", "WARNING", "ERROR":
+	default:
+		return fmt.Errorf("DEFAULT_MIN_SEVERITY must be one of INFO, WARNING or ERROR")
+	}
+
+	if c.DefaultMaxIssues < 0 {
+		return fmt.Errorf("DEFAULT_MAX_ISSUES must not be negative")
+	}
+
+	if c.MaxFileDiffSize < 0 {
+		return fmt.Errorf("MAX_FILE_DIFF_SIZE must not be negative")
+	}
+	switch c.LargeFileStrategy {
+	case "skip", "head-tail", "sample":
+	default:
+		return fmt.Errorf("LARGE_FILE_STRATEGY must be one of skip, head-tail or sample")
+	}
+	if c.MaxContinuations < 0 {
+		return fmt.Errorf("MAX_CONTINUATIONS must not be negative")
+	}
+	if c.MockLatency < 0 {
+		return fmt.Errorf("MOCK_LATENCY_MS must not be negative")
+	}
+	switch c.VCRMode {
+	case "off", "record", "replay":
+	default:
+		return fmt.Errorf("VCR_MODE must be one of off, record or replay")
+	}
+
+	if c.DashboardURL != "" {
+		if u, err := url.Parse(c.DashboardURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("DASHBOARD_URL must be an http or https URL")
+		}
+	}
+
+	if c.MaxLanguageGroups < 1 {
+		return fmt.Errorf("MAX_LANGUAGE_GROUPS must be at least 1")
+	}
+
+	if c.ReviewTimeout <= 0 || c.QuestionTimeout <= 0 {
+		return fmt.Errorf("REVIEW_TIMEOUT and QUESTION_TIMEOUT must be positive")
+	}
+	if c.MaxReviewTimeout < c.ReviewTimeout {
+		return fmt.Errorf("MAX_REVIEW_TIMEOUT must be at least REVIEW_TIMEOUT")
+	}
+	if c.HTTPTimeout < 0 || c.HTTPIdleTimeout < 0 || c.HTTPIdleConns < 0 {
+		return fmt.Errorf("PROVIDER_HTTP_TIMEOUT, PROVIDER_IDLE_TIMEOUT and PROVIDER_IDLE_CONNS must not be negative")
+	}
+	if c.HTTPTimeout != 0 && c.HTTPTimeout < c.MaxReviewTimeout {
+		// A streamed review would be cut off before its own timeout
+		return fmt.Errorf("PROVIDER_HTTP_TIMEOUT must be 0 or at least MAX_REVIEW_TIMEOUT")
+	}
+	if c.HTTPDialTimeout <= 0 || c.HTTPTLSTimeout <= 0 {
+		return fmt.Errorf("PROVIDER_DIAL_TIMEOUT and PROVIDER_TLS_TIMEOUT must be positive")
+	}
+
+	if c.MaxDiffSize <= 0 || c.MaxRequestSize < 0 {
+		return fmt.Errorf("MAX_DIFF_SIZE must be positive and MAX_REQUEST_SIZE must not be negative")
+	}
+
+	if c.ReadHeaderTimeout <= 0 || c.IdleTimeout <= 0 {
+		return fmt.Errorf("READ_HEADER_TIMEOUT and IDLE_TIMEOUT must be positive")
+	}
+	if c.ReadTimeout < 0 || (c.ReadTimeout > 0 && c.ReadTimeout < c.ReadHeaderTimeout) {
+		return fmt.Errorf("READ_TIMEOUT must be 0 or at least READ_HEADER_TIMEOUT")
+	}
+
+	if c.ShutdownTimeout < 0 || c.ShutdownDelay < 0 {
+		return fmt.Errorf("SHUTDOWN_TIMEOUT and SHUTDOWN_DELAY must not be negative")
+	}
+	if c.HealthCheckInterval < 0 {
+		return fmt.Errorf("PROVIDER_CHECK_INTERVAL must not be negative")
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.TLSCertFile != "" && len(c.TLSAutocertDomains) > 0 {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_AUTOCERT_DOMAINS are mutually exclusive")
+	}
+	if c.TLSClientCAFile != "" && !c.TLSEnabled() {
+		return fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE or TLS_AUTOCERT_DOMAINS")
+	}
+	switch c.TLSClientAuth {
+	case "require", "optional":
+	default:
+		return fmt.Errorf("TLS_CLIENT_AUTH must be require or optional")
+	}
+
+	switch c.GeminiTransport {
+	case "sdk", "rest", "auto":
+	default:
+		return fmt.Errorf("GEMINI_TRANSPORT must be one of sdk, rest or auto")
+	}
+	if c.GeminiCacheTTL < 0 {
+		return fmt.Errorf("GEMINI_CONTEXT_CACHE_TTL must not be negative")
+	}
+
+	if c.DefaultTokenQuota < 0 || c.DefaultCostQuotaUSD < 0 {
+		return fmt.Errorf("DEFAULT_MONTHLY_TOKEN_QUOTA and DEFAULT_MONTHLY_COST_QUOTA must not be negative")
+	}
+
+	if c.WebhookMaxAttempts < 1 {
+		return fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be at least 1")
+	}
+	if c.JobInteractiveWorkers < 1 || c.JobBatchWorkers < 1 {
+		return fmt.Errorf("JOB_INTERACTIVE_WORKERS and JOB_BATCH_WORKERS must be at least 1")
+	}
+	if c.JobQueueSize < 0 {
+		return fmt.Errorf("JOB_QUEUE_SIZE must not be negative")
+	}
+	if c.JobRedisURL != "" && c.JobRedisPrefix == "" {
+		return fmt.Errorf("JOB_REDIS_PREFIX must not be empty")
+	}
+	if c.DedupReviews && c.DedupTTL < 1 {
+		return fmt.Errorf("DEDUP_TTL must be at least 1")
+	}
+	if c.BatchMaxItems < 1 || c.BatchConcurrency < 1 {
+		return fmt.Errorf("BATCH_MAX_ITEMS and BATCH_CONCURRENCY must be at least 1")
+	}
+	if c.ScheduledReviewsFile != "" && len(c.RemoteDiffHosts) == 0 {
+		return fmt.Errorf("SCHEDULED_REVIEWS_FILE requires REMOTE_DIFF_HOSTS")
+	}
+	switch c.IngestBroker {
+	case "":
+	case "nats", "kafka":
+		if c.IngestURL == "" {
+			return fmt.Errorf("INGEST_URL is required with INGEST_BROKER")
+		}
+		if c.IngestTopic == "" || c.IngestResultsTopic == "" || c.IngestGroup == "" {
+			return fmt.Errorf("INGEST_TOPIC, INGEST_RESULTS_TOPIC and INGEST_GROUP must not be empty")
+		}
+		if c.IngestConcurrency < 1 {
+			return fmt.Errorf("INGEST_CONCURRENCY must be at least 1")
+		}
+	default:
+		return fmt.Errorf("invalid INGEST_BROKER %q: must be nats or kafka", c.IngestBroker)
+	}
+
+	if c.SlackBotToken != "" && c.SlackChannel == "" {
+		return fmt.Errorf("SLACK_CHANNEL is required with SLACK_BOT_TOKEN")
+	}
+
+	switch c.SMTPTLS {
+	case "starttls", "tls", "none":
+	default:
+		return fmt.Errorf("SMTP_TLS must be one of starttls, tls or none")
+	}
+	if c.SMTPHost != "" && c.SMTPFrom == "" {
+		return fmt.Errorf("SMTP_FROM is required with SMTP_HOST")
+	}
+	if c.SMTPFrom != "" {
+		if _, err := mail.ParseAddress(c.SMTPFrom); err != nil {
+			return fmt.Errorf("SMTP_FROM is not a valid address: %w", err)
+		}
+	}
+	if len(c.EmailReportTo) > 0 && c.SMTPHost == "" {
+		return fmt.Errorf("SMTP_HOST is required with EMAIL_REPORT_TO")
+	}
+
+	if c.ShadowPercent < 0 || c.ShadowPercent > 100 {
+		return fmt.Errorf("SHADOW_PERCENT must be between 0 and 100")
+	}
+	if c.ShadowMaxConcurrent < 1 {
+		return fmt.Errorf("SHADOW_MAX_CONCURRENT must be at least 1")
+	}
+	if c.ShadowPromptVersion != "" && c.PromptVersionsDir == "" {
+		return fmt.Errorf("SHADOW_PROMPT_VERSION requires PROMPT_VERSIONS_DIR")
+	}
+
+	if c.EvalLineTolerance < 0 {
+		return fmt.Errorf("EVAL_LINE_TOLERANCE must not be negative")
+	}
+
+	if c.AnalyticsNoiseEpsilon < 0 {
+		return fmt.Errorf("ANALYTICS_NOISE_EPSILON must not be negative")
+	}
+	if c.AnalyticsMaxContrib < 0 {
+		return fmt.Errorf("ANALYTICS_MAX_CONTRIBUTION must not be negative")
+	}
+	if c.AnalyticsNoiseWindow < 1 {
+		return fmt.Errorf("ANALYTICS_NOISE_WINDOW must be at least 1")
+	}
+
 	return nil
 }
 
-// parseAPIKeys splits comma-separated API keys
-func parseAPIKeys(keys string) []string {
+// RequestSizeLimit returns the maximum request body size. By default it
+// leaves room for JSON escaping of a MaxDiffSize diff and the fields sent
+// alongside it.
+func (c *Config) RequestSizeLimit() int64 {
+	if c.MaxRequestSize > 0 {
+		return c.MaxRequestSize
+	}
+	return 2 * c.MaxDiffSize
+}
+
+// minUploadRate is the slowest upload, in bytes per second, the default
+// read timeout lets a client send a full-size request at
+const minUploadRate = 256 * 1024
+
+// RequestReadTimeout returns the seconds a client may take to send a whole
+// request. By default it is long enough to upload RequestSizeLimit bytes
+// at minUploadRate after the headers, and at least 30 seconds.
+func (c *Config) RequestReadTimeout() int {
+	if c.ReadTimeout > 0 {
+		return c.ReadTimeout
+	}
+	return max(30, c.ReadHeaderTimeout+int(c.RequestSizeLimit()/minUploadRate))
+}
+
+// AcceptsAPIKeys reports whether callers may authenticate with X-API-Key
+func (c *Config) AcceptsAPIKeys() bool {
+	return c.AuthMode != "jwt"
+}
+
+// AcceptsTokens reports whether callers may authenticate with OIDC bearer
+// tokens
+func (c *Config) AcceptsTokens() bool {
+	return c.AuthMode != "api_key"
+}
+
+// TLSEnabled reports whether the gateway serves HTTPS itself
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" || len(c.TLSAutocertDomains) > 0
+}
+
+// ShouldAnonymize reports whether diffs sent to the provider must be anonymized
+func (c *Config) ShouldAnonymize(provider string) bool {
+	for _, p := range c.AnonymizeProviders {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// parseList splits a comma-separated list, dropping empty entries
+func parseList(keys string) []string {
 	if keys == "" {
 		return []string{}
 	}
@@ -64,10 +674,41 @@ func parseAPIKeys(keys string) []string {
 	return result
 }
 
-// getEnv gets an environment variable or returns a default value
+// getEnv gets an environment variable, or its configuration file setting,
+// or returns a default value
 func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+	if value := Lookup(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+// getEnvInt gets an integer environment variable or returns a default value
+func getEnvInt(key string, defaultValue int) int {
+	if value := Lookup(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvFloat gets a float environment variable or returns a default value
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := Lookup(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvBool gets a boolean environment variable or returns a default value
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := Lookup(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,307 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileKeys maps the dotted paths of the configuration file to the
+// environment variables they set. Each key also accepts a "_file" variant
+// setting the matching *_FILE variable.
+var fileKeys = map[string]string{
+	"server.port":                        "PORT",
+	"server.read_only":                   "READ_ONLY_MODE",
+	"server.config_watch_interval":       "CONFIG_WATCH_INTERVAL",
+	"server.shutdown_timeout":            "SHUTDOWN_TIMEOUT",
+	"server.shutdown_delay":              "SHUTDOWN_DELAY",
+	"server.read_header_timeout":         "READ_HEADER_TIMEOUT",
+	"server.read_timeout":                "READ_TIMEOUT",
+	"server.idle_timeout":                "IDLE_TIMEOUT",
+	"server.max_request_size":            "MAX_REQUEST_SIZE",
+	"server.compression":                 "RESPONSE_COMPRESSION",
+	"timeouts.review":                    "REVIEW_TIMEOUT",
+	"timeouts.max_review":                "MAX_REVIEW_TIMEOUT",
+	"timeouts.question":                  "QUESTION_TIMEOUT",
+	"timeouts.providers":                 "PROVIDER_TIMEOUTS",
+	"timeouts.provider_http":             "PROVIDER_HTTP_TIMEOUT",
+	"timeouts.provider_dial":             "PROVIDER_DIAL_TIMEOUT",
+	"timeouts.provider_tls":              "PROVIDER_TLS_TIMEOUT",
+	"providers.http.idle_timeout":        "PROVIDER_IDLE_TIMEOUT",
+	"providers.http.idle_conns":          "PROVIDER_IDLE_CONNS",
+	"providers.http.http2":               "PROVIDER_HTTP2",
+	"providers.warmup":                   "PROVIDER_WARMUP",
+	"server.tls.cert_file":               "TLS_CERT_FILE",
+	"server.tls.key_file":                "TLS_KEY_FILE",
+	"server.tls.autocert.domains":        "TLS_AUTOCERT_DOMAINS",
+	"server.tls.autocert.cache_dir":      "TLS_AUTOCERT_CACHE_DIR",
+	"server.tls.autocert.email":          "TLS_AUTOCERT_EMAIL",
+	"server.tls.autocert.http_addr":      "TLS_AUTOCERT_HTTP_ADDR",
+	"server.tls.client_ca_file":          "TLS_CLIENT_CA_FILE",
+	"server.tls.client_auth":             "TLS_CLIENT_AUTH",
+	"auth.mode":                          "AUTH_MODE",
+	"auth.api_keys":                      "API_KEYS",
+	"auth.key_store":                     "API_KEY_STORE",
+	"auth.tenants_file":                  "TENANTS_FILE",
+	"auth.jwt.issuer":                    "JWT_ISSUER",
+	"auth.jwt.jwks_url":                  "JWT_JWKS_URL",
+	"auth.jwt.audience":                  "JWT_AUDIENCE",
+	"auth.jwt.required_scopes":           "JWT_REQUIRED_SCOPES",
+	"auth.jwt.client_claim":              "JWT_CLIENT_CLAIM",
+	"auth.api_key_tiers":                 "API_KEY_TIERS",
+	"auth.admin_api_key":                 "ADMIN_API_KEY",
+	"providers.default":                  "DEFAULT_AI_PROVIDER",
+	"providers.default_model":            "DEFAULT_AI_MODEL",
+	"providers.routing_file":             "ROUTING_FILE",
+	"providers.manifest":                 "PROVIDERS_FILE",
+	"providers.policy_file":              "POLICY_FILE",
+	"providers.key_selection":            "KEY_SELECTION",
+	"providers.check_interval":           "PROVIDER_CHECK_INTERVAL",
+	"providers.proxy":                    "PROVIDER_PROXY",
+	"providers.aliases":                  "MODEL_ALIASES",
+	"providers.allow_unlisted_models":    "ALLOW_UNLISTED_MODELS",
+	"providers.ensemble":                 "ENSEMBLE_PROVIDERS",
+	"providers.anonymize":                "ANONYMIZE_PROVIDERS",
+	"providers.pricing":                  "MODEL_PRICING",
+	"providers.google.api_key":           "GOOGLE_API_KEY",
+	"providers.google.transport":         "GEMINI_TRANSPORT",
+	"providers.google.endpoint":          "GEMINI_API_ENDPOINT",
+	"providers.google.api_version":       "GEMINI_API_VERSION",
+	"providers.google.context_cache_ttl": "GEMINI_CONTEXT_CACHE_TTL",
+	"providers.openai.api_key":           "OPENAI_API_KEY",
+	"providers.openai.batch":             "OPENAI_BATCH",
+	"providers.anthropic.api_key":        "ANTHROPIC_API_KEY",
+	"providers.mock.enabled":             "MOCK_PROVIDER",
+	"providers.mock.response_file":       "MOCK_RESPONSE_FILE",
+	"providers.mock.latency_ms":          "MOCK_LATENCY_MS",
+	"providers.vcr.mode":                 "VCR_MODE",
+	"providers.vcr.dir":                  "VCR_DIR",
+	"rate_limits.tiers":                  "RATE_LIMIT_TIERS",
+	"rate_limits.default_tier":           "DEFAULT_RATE_LIMIT_TIER",
+	"rate_limits.max_concurrent_reviews": "MAX_CONCURRENT_REVIEWS",
+	"quotas.store_path":                  "QUOTA_STORE_PATH",
+	"quotas.monthly_tokens":              "DEFAULT_MONTHLY_TOKEN_QUOTA",
+	"quotas.monthly_cost_usd":            "DEFAULT_MONTHLY_COST_QUOTA",
+	"review.max_diff_size":               "MAX_DIFF_SIZE",
+	"review.ignore_paths":                "IGNORE_PATHS",
+	"review.skip_generated_files":        "SKIP_GENERATED_FILES",
+	"review.max_file_diff_size":          "MAX_FILE_DIFF_SIZE",
+	"review.large_file_strategy":         "LARGE_FILE_STRATEGY",
+	"review.max_continuations":           "MAX_CONTINUATIONS",
+	"review.json_repair_retry":           "JSON_REPAIR_RETRY",
+	"review.redact_secrets":              "REDACT_SECRETS",
+	"review.prompt_template_dir":         "PROMPT_TEMPLATE_DIR",
+	"review.prompt_versions_dir":         "PROMPT_VERSIONS_DIR",
+	"review.prompt_experiment":           "PROMPT_EXPERIMENT",
+	"review.categories_file":             "CATEGORIES_FILE",
+	"review.guidelines_dir":              "GUIDELINES_DIR",
+	"review.feedback_examples":           "FEEDBACK_EXAMPLES",
+	"review.max_language_groups":         "MAX_LANGUAGE_GROUPS",
+	"output.format":                      "OUTPUT_FORMAT",
+	"output.min_severity":                "DEFAULT_MIN_SEVERITY",
+	"output.max_issues":                  "DEFAULT_MAX_ISSUES",
+	"output.line_validation":             "LINE_VALIDATION",
+	"output.verdict_policy":              "VERDICT_POLICY",
+	"output.dashboard_url":               "DASHBOARD_URL",
+	"github.token":                       "GITHUB_TOKEN",
+	"github.token_repos":                 "GITHUB_TOKEN_REPOS",
+	"github.fetch_repo_config":           "REPO_CONFIG_FETCH",
+	"github.fetch_file_context":          "FETCH_FILE_CONTEXT",
+	"github.file_context_max_size":       "FILE_CONTEXT_MAX_SIZE",
+	"review.remote_diff_hosts":           "REMOTE_DIFF_HOSTS",
+	"knowledge.embedding_provider":       "RAG_EMBEDDING_PROVIDER",
+	"knowledge.embedding_model":          "RAG_EMBEDDING_MODEL",
+	"knowledge.store_path":               "RAG_STORE_PATH",
+	"knowledge.top_k":                    "RAG_TOP_K",
+	"knowledge.max_size":                 "RAG_MAX_SIZE",
+	"storage.history_database":           "HISTORY_DATABASE",
+	"storage.history_max_reviews":        "HISTORY_MAX_REVIEWS",
+	"storage.history_prompts":            "HISTORY_PROMPTS",
+	"storage.feedback_path":              "FEEDBACK_PATH",
+	"storage.followup_ttl_hours":         "FOLLOWUP_TTL_HOURS",
+	"storage.followup_max_reviews":       "FOLLOWUP_MAX_REVIEWS",
+	"webhooks.secret":                    "WEBHOOK_SECRET",
+	"webhooks.max_attempts":              "WEBHOOK_MAX_ATTEMPTS",
+	"webhooks.allowed_hosts":             "WEBHOOK_ALLOWED_HOSTS",
+	"webhooks.allow_http":                "WEBHOOK_ALLOW_HTTP",
+	"jobs.interactive_workers":           "JOB_INTERACTIVE_WORKERS",
+	"jobs.batch_workers":                 "JOB_BATCH_WORKERS",
+	"jobs.queue_size":                    "JOB_QUEUE_SIZE",
+	"jobs.redis_url":                     "JOB_REDIS_URL",
+	"jobs.redis_prefix":                  "JOB_REDIS_PREFIX",
+	"dedup.enabled":                      "DEDUP_REVIEWS",
+	"dedup.redis_url":                    "DEDUP_REDIS_URL",
+	"dedup.ttl":                          "DEDUP_TTL",
+	"batch.max_items":                    "BATCH_MAX_ITEMS",
+	"batch.concurrency":                  "BATCH_CONCURRENCY",
+	"jobs.schedules_file":                "SCHEDULED_REVIEWS_FILE",
+	"ingest.broker":                      "INGEST_BROKER",
+	"ingest.url":                         "INGEST_URL",
+	"ingest.username":                    "INGEST_USERNAME",
+	"ingest.password":                    "INGEST_PASSWORD",
+	"ingest.topic":                       "INGEST_TOPIC",
+	"ingest.results_topic":               "INGEST_RESULTS_TOPIC",
+	"ingest.group":                       "INGEST_GROUP",
+	"ingest.concurrency":                 "INGEST_CONCURRENCY",
+	"notifications.file":                 "NOTIFICATIONS_FILE",
+	"notifications.slack.webhook_url":    "SLACK_WEBHOOK_URL",
+	"notifications.slack.bot_token":      "SLACK_BOT_TOKEN",
+	"notifications.slack.channel":        "SLACK_CHANNEL",
+	"notifications.teams.webhook_url":    "TEAMS_WEBHOOK_URL",
+	"notifications.webhook.url":          "NOTIFY_WEBHOOK_URL",
+	"notifications.webhook.secret":       "NOTIFY_WEBHOOK_SECRET",
+	"notifications.email.to":             "EMAIL_REPORT_TO",
+	"notifications.email.smtp.host":      "SMTP_HOST",
+	"notifications.email.smtp.port":      "SMTP_PORT",
+	"notifications.email.smtp.username":  "SMTP_USERNAME",
+	"notifications.email.smtp.password":  "SMTP_PASSWORD",
+	"notifications.email.smtp.from":      "SMTP_FROM",
+	"notifications.email.smtp.tls":       "SMTP_TLS",
+	"shadow.provider":                    "SHADOW_PROVIDER",
+	"shadow.model":                       "SHADOW_MODEL",
+	"shadow.review_mode":                 "SHADOW_REVIEW_MODE",
+	"shadow.percent":                     "SHADOW_PERCENT",
+	"shadow.output_path":                 "SHADOW_OUTPUT_PATH",
+	"shadow.prompt_version":              "SHADOW_PROMPT_VERSION",
+	"shadow.max_concurrent":              "SHADOW_MAX_CONCURRENT",
+	"eval.dir":                           "EVAL_DIR",
+	"eval.line_tolerance":                "EVAL_LINE_TOLERANCE",
+	"analytics.min_tenants":              "ANALYTICS_MIN_TENANTS",
+	"analytics.min_reviews":              "ANALYTICS_MIN_REVIEWS",
+	"analytics.noise_epsilon":            "ANALYTICS_NOISE_EPSILON",
+	"analytics.max_contribution":         "ANALYTICS_MAX_CONTRIBUTION",
+	"analytics.noise_window":             "ANALYTICS_NOISE_WINDOW",
+}
+
+// file holds the settings read from the configuration file
+var file struct {
+	mu     sync.RWMutex
+	path   string
+	values map[string]string // Environment variable name to value
+}
+
+// LoadFile reads a YAML configuration file. Its settings apply wherever
+// the matching environment variable is unset, so the environment
+// overrides the file.
+func LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc map[string]interface{}
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten("", doc, values); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	file.mu.Lock()
+	defer file.mu.Unlock()
+	file.path = path
+	file.values = values
+	return nil
+}
+
+// FilePath returns the path of the loaded configuration file, if any
+func FilePath() string {
+	file.mu.RLock()
+	defer file.mu.RUnlock()
+	return file.path
+}
+
+// Lookup returns the environment variable name, falling back to the
+// configuration file
+func Lookup(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	file.mu.RLock()
+	defer file.mu.RUnlock()
+	return file.values[name]
+}
+
+// flatten converts the nested document into environment variable values
+func flatten(prefix string, node map[string]interface{}, values map[string]string) error {
+	for key, value := range node {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		name, known := fileKeys[path]
+		if !known {
+			if base, ok := strings.CutSuffix(path, "_file"); ok {
+				if name, known = fileKeys[base]; known {
+					name += "_FILE"
+				}
+			}
+		}
+
+		if section, ok := value.(map[string]interface{}); ok && !known {
+			if err := flatten(path, section, values); err != nil {
+				return err
+			}
+			continue
+		}
+		if !known {
+			return fmt.Errorf("unknown setting %s", path)
+		}
+		formatted, err := formatValue(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		values[name] = formatted
+	}
+	return nil
+}
+
+// formatValue renders a setting the way its environment variable is
+// written: lists are comma-separated and maps become key=value pairs
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			formatted, err := formatValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, formatted)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		items := make([]string, 0, len(v))
+		for _, key := range keys {
+			formatted, err := formatValue(v[key])
+			if err != nil {
+				return "", err
+			}
+			items = append(items, key+"="+formatted)
+		}
+		return strings.Join(items, ","), nil
+	case string:
+		return v, nil
+	case int, int64, float64, bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
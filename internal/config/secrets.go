@@ -0,0 +1,47 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// Secret returns the environment variable name or, when name_FILE is set,
+// the trimmed contents of that file, e.g. a Docker or Kubernetes secret
+// mounted at /run/secrets/openai. Both may come from the configuration file.
+func Secret(name string) string {
+	path := Lookup(name + "_FILE")
+	if path == "" {
+		return Lookup(name)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Warning: failed to read %s_FILE: %v", name, err)
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Credential is a secret that can be replaced while the gateway runs
+type Credential struct {
+	value atomic.Pointer[string]
+}
+
+// NewCredential creates a credential holding value
+func NewCredential(value string) *Credential {
+	c := &Credential{}
+	c.Set(value)
+	return c
+}
+
+// Get returns the current value
+func (c *Credential) Get() string {
+	return *c.value.Load()
+}
+
+// Set replaces the value
+func (c *Credential) Set(value string) {
+	c.value.Store(&value)
+}
@@ -0,0 +1,170 @@
+// Package dedup makes sure a review requested several times at once, e.g.
+// by the same pull request webhook delivered to several replicas, runs once
+// and its result is shared
+package dedup
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/redis"
+)
+
+// pollInterval is the wait between checks for a result another replica is
+// producing
+const pollInterval = 500 * time.Millisecond
+
+// Coordinator runs one review per key
+type Coordinator interface {
+	// Do runs review unless the same key is being reviewed or was reviewed
+	// within the result TTL, in which case it returns that result and true.
+	// A failed review isn't shared: whoever waited on it runs its own.
+	// lockTTL bounds how long a review may hold the key, should its
+	// replica die mid-review.
+	Do(ctx context.Context, key string, lockTTL time.Duration, review func() ([]byte, error)) ([]byte, bool, error)
+}
+
+// local coordinates the reviews of one replica
+type local struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// entry is a review in progress or done
+type entry struct {
+	done    chan struct{}
+	result  []byte
+	failed  bool
+	expires time.Time
+}
+
+// NewLocal creates a coordinator sharing results within this replica for
+// ttl
+func NewLocal(ttl time.Duration) Coordinator {
+	return &local{ttl: ttl, entries: make(map[string]*entry)}
+}
+
+func (l *local) Do(ctx context.Context, key string, lockTTL time.Duration, review func() ([]byte, error)) ([]byte, bool, error) {
+	for {
+		l.mu.Lock()
+		l.evictExpired()
+		e, ok := l.entries[key]
+		if !ok {
+			e = &entry{done: make(chan struct{})}
+			l.entries[key] = e
+			l.mu.Unlock()
+
+			result, err := review()
+			l.mu.Lock()
+			if err != nil {
+				e.failed = true
+				delete(l.entries, key)
+			} else {
+				e.result, e.expires = result, time.Now().Add(l.ttl)
+			}
+			l.mu.Unlock()
+			close(e.done)
+			return result, false, err
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-e.done:
+			if !e.failed {
+				return e.result, true, nil
+			}
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+}
+
+// evictExpired drops finished reviews older than the TTL; the caller holds
+// the lock
+func (l *local) evictExpired() {
+	now := time.Now()
+	for key, e := range l.entries {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			delete(l.entries, key)
+		}
+	}
+}
+
+// releaseScript deletes a lock only if this replica still holds it
+const releaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+
+// shared coordinates the reviews of every replica through Redis: a review
+// holds a lock key while it runs and stores its result under a result key
+type shared struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedis creates a coordinator sharing results across replicas for ttl
+func NewRedis(client *redis.Client, prefix string, ttl time.Duration) Coordinator {
+	return &shared{client: client, prefix: prefix, ttl: ttl}
+}
+
+func (s *shared) Do(ctx context.Context, key string, lockTTL time.Duration, review func() ([]byte, error)) ([]byte, bool, error) {
+	lockKey, resultKey := s.prefix+":lock:"+key, s.prefix+":result:"+key
+	token := make([]byte, 8)
+	rand.Read(token)
+	owner := hex.EncodeToString(token)
+
+	for {
+		result, err := redis.String(s.client.Do(ctx, "GET", resultKey))
+		if err == nil {
+			return []byte(result), true, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			// Without Redis, reviewing twice beats not reviewing
+			log.Printf("Warning: failed to check for a duplicate review, reviewing anyway: %v", err)
+			result, err := review()
+			return result, false, err
+		}
+
+		_, err = s.client.Do(ctx, "SET", lockKey, owner, "NX", "PX", fmt.Sprint(lockTTL.Milliseconds()))
+		if err == nil {
+			return s.run(lockKey, resultKey, owner, review)
+		}
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Warning: failed to lock a review, reviewing anyway: %v", err)
+			result, err := review()
+			return result, false, err
+		}
+
+		// Another replica is reviewing the same changes
+		select {
+		case <-time.After(pollInterval):
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+}
+
+// run reviews while holding the lock, then stores the result and releases
+// the lock
+func (s *shared) run(lockKey, resultKey, owner string, review func() ([]byte, error)) ([]byte, bool, error) {
+	result, err := review()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err == nil {
+		if _, err := s.client.Do(ctx, "SET", resultKey, string(result), "PX", fmt.Sprint(s.ttl.Milliseconds())); err != nil {
+			log.Printf("Warning: failed to share a review result: %v", err)
+		}
+	}
+	if _, err := s.client.Do(ctx, "EVAL", releaseScript, "1", lockKey, owner); err != nil {
+		log.Printf("Warning: failed to release a review lock; it expires on its own: %v", err)
+	}
+	return result, false, err
+}
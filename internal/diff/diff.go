@@ -0,0 +1,356 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Line kinds within a hunk
+const (
+	LineContext = ' '
+	LineAdded   = '+'
+	LineRemoved = '-'
+)
+
+// File represents the changes to a single file in a unified diff
+type File struct {
+	OldPath  string
+	NewPath  string
+	IsNew    bool
+	IsDelete bool
+	IsBinary bool
+	Hunks    []*Hunk
+	Raw      string // Raw diff text for this file, including headers
+}
+
+// Hunk represents a single @@ section of a file diff
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Header   string
+	Lines    []Line
+}
+
+// Line represents a single line within a hunk
+type Line struct {
+	Kind    byte
+	Content string
+	OldLine int // Zero for added lines
+	NewLine int // Zero for removed lines
+}
+
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@(.*)$`)
+
+// Path returns the path of the file after the change, falling back to the
+// old path for deleted files
+func (f *File) Path() string {
+	if f.IsDelete || f.NewPath == "" {
+		return f.OldPath
+	}
+	return f.NewPath
+}
+
+// AddedLines returns the set of new-file line numbers added by the diff
+func (f *File) AddedLines() map[int]bool {
+	lines := make(map[int]bool)
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			if l.Kind == LineAdded {
+				lines[l.NewLine] = true
+			}
+		}
+	}
+	return lines
+}
+
+// Stats returns the number of lines the diff adds to and removes from the
+// file
+func (f *File) Stats() (added, removed int) {
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			switch l.Kind {
+			case LineAdded:
+				added++
+			case LineRemoved:
+				removed++
+			}
+		}
+	}
+	return added, removed
+}
+
+// WithHunks returns a copy of the file with its hunks replaced and its raw
+// text rebuilt from its headers and the new hunks
+func (f *File) WithHunks(hunks []*Hunk) *File {
+	var builder strings.Builder
+	header := f.Raw
+	if strings.HasPrefix(header, "@@ ") {
+		header = ""
+	} else if idx := strings.Index(header, "\n@@ "); idx >= 0 {
+		header = header[:idx+1]
+	}
+	builder.WriteString(header)
+	for _, h := range hunks {
+		builder.WriteString(h.String())
+	}
+
+	copied := *f
+	copied.Hunks = hunks
+	copied.Raw = builder.String()
+	return &copied
+}
+
+// String renders the hunk as unified diff text
+func (h *Hunk) String() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines))
+	if h.Header != "" {
+		builder.WriteString(" " + h.Header)
+	}
+	builder.WriteString("\n")
+	for _, l := range h.Lines {
+		builder.WriteByte(l.Kind)
+		builder.WriteString(l.Content)
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// Slice returns a hunk holding lines [from, to) of this one, with its
+// header ranges recomputed
+func (h *Hunk) Slice(from, to int) *Hunk {
+	sliced := &Hunk{OldStart: h.OldStart, NewStart: h.NewStart, Header: h.Header}
+	for i, l := range h.Lines[:to] {
+		if i < from {
+			if l.Kind != LineAdded {
+				sliced.OldStart++
+			}
+			if l.Kind != LineRemoved {
+				sliced.NewStart++
+			}
+			continue
+		}
+		if l.Kind != LineAdded {
+			sliced.OldLines++
+		}
+		if l.Kind != LineRemoved {
+			sliced.NewLines++
+		}
+		sliced.Lines = append(sliced.Lines, l)
+	}
+	return sliced
+}
+
+// Contains reports whether the new-file line falls within one of the hunks
+func (h *Hunk) Contains(line int) bool {
+	return line >= h.NewStart && line < h.NewStart+h.NewLines
+}
+
+// Parse parses a unified diff (as produced by git diff) into files.
+// Parsing is lenient: unrecognised lines are preserved in File.Raw but
+// otherwise ignored.
+func Parse(text string) []*File {
+	var files []*File
+	var current *File
+	var hunk *Hunk
+	var raw strings.Builder
+	oldRemaining, newRemaining := 0, 0
+	oldLine, newLine := 0, 0
+
+	flush := func() {
+		if current != nil {
+			current.Raw = raw.String()
+			files = append(files, current)
+		}
+		raw.Reset()
+		current = nil
+		hunk = nil
+	}
+
+	lines := strings.Split(text, "\n")
+	// A trailing newline produces an empty final element that isn't a line
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	for _, line := range lines {
+		inHunk := hunk != nil && (oldRemaining > 0 || newRemaining > 0)
+
+		switch {
+		case inHunk && (strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") ||
+			strings.HasPrefix(line, " ") || line == ""):
+			kind := byte(LineContext)
+			content := line
+			if line != "" {
+				kind = line[0]
+				content = line[1:]
+			}
+			l := Line{Kind: kind, Content: content}
+			if strings.ContainsRune(content, 0) {
+				// Text diffs of binary files, e.g. with --text
+				current.IsBinary = true
+			}
+			switch kind {
+			case LineAdded:
+				l.NewLine = newLine
+				newLine++
+				newRemaining--
+			case LineRemoved:
+				l.OldLine = oldLine
+				oldLine++
+				oldRemaining--
+			default:
+				l.OldLine = oldLine
+				l.NewLine = newLine
+				oldLine++
+				newLine++
+				oldRemaining--
+				newRemaining--
+			}
+			hunk.Lines = append(hunk.Lines, l)
+
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file"
+
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			current = &File{}
+			if a, b, ok := parseGitHeader(line); ok {
+				current.OldPath = a
+				current.NewPath = b
+			}
+
+		case strings.HasPrefix(line, "--- "):
+			if current == nil || len(current.Hunks) > 0 {
+				flush()
+				current = &File{}
+			}
+			path := parsePath(line[4:])
+			if path == "" {
+				current.IsNew = true
+			} else {
+				current.OldPath = path
+			}
+
+		case strings.HasPrefix(line, "+++ "):
+			if current == nil {
+				current = &File{}
+			}
+			path := parsePath(line[4:])
+			if path == "" {
+				current.IsDelete = true
+			} else {
+				current.NewPath = path
+			}
+
+		case strings.HasPrefix(line, "new file mode"):
+			if current != nil {
+				current.IsNew = true
+			}
+
+		case strings.HasPrefix(line, "deleted file mode"):
+			if current != nil {
+				current.IsDelete = true
+			}
+
+		case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch":
+			if current != nil {
+				current.IsBinary = true
+			}
+
+		case strings.HasPrefix(line, "@@ "):
+			h, ok := ParseHunkHeader(line)
+			if !ok {
+				break
+			}
+			if current == nil {
+				current = &File{}
+			}
+			hunk = h
+			current.Hunks = append(current.Hunks, hunk)
+			oldRemaining, newRemaining = hunk.OldLines, hunk.NewLines
+			oldLine, newLine = hunk.OldStart, hunk.NewStart
+		}
+
+		if current != nil {
+			raw.WriteString(line)
+			raw.WriteString("\n")
+		}
+	}
+	flush()
+
+	return files
+}
+
+// ParseHunkHeader parses an "@@ -a,b +c,d @@ header" line into an empty hunk
+func ParseHunkHeader(line string) (*Hunk, bool) {
+	m := hunkHeaderRegex.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+	return &Hunk{
+		OldStart: atoi(m[1], 0),
+		OldLines: atoi(m[2], 1),
+		NewStart: atoi(m[3], 0),
+		NewLines: atoi(m[4], 1),
+		Header:   strings.TrimSpace(m[5]),
+	}, true
+}
+
+// Join reassembles the raw text of the given files into a single diff
+func Join(files []*File) string {
+	var builder strings.Builder
+	for _, f := range files {
+		builder.WriteString(f.Raw)
+	}
+	return builder.String()
+}
+
+// NormalizePath strips git prefixes so paths from diffs and model output
+// can be compared
+func NormalizePath(path string) string {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "./")
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		path = path[2:]
+	}
+	return path
+}
+
+// parseGitHeader extracts the paths from a "diff --git a/x b/y" line
+func parseGitHeader(line string) (string, string, bool) {
+	rest := strings.TrimPrefix(line, "diff --git ")
+	idx := strings.Index(rest, " b/")
+	if !strings.HasPrefix(rest, "a/") || idx < 0 {
+		return "", "", false
+	}
+	return rest[2:idx], rest[idx+3:], true
+}
+
+// parsePath extracts a path from a ---/+++ header, returning "" for /dev/null
+func parsePath(value string) string {
+	// Strip trailing timestamps emitted by some diff tools
+	if idx := strings.Index(value, "\t"); idx >= 0 {
+		value = value[:idx]
+	}
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	if value == "/dev/null" {
+		return ""
+	}
+	return NormalizePath(value)
+}
+
+func atoi(value string, defaultValue int) int {
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return n
+}
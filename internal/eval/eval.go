@@ -0,0 +1,162 @@
+// Package eval scores reviews against a golden set of labeled diffs, so a
+// provider, model or prompt version can be measured before it becomes the
+// default
+package eval
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// DefaultLineTolerance is how many lines a finding may be off from the
+// expected one and still match
+const DefaultLineTolerance = 3
+
+// Case is one labeled diff of the golden set, read from <dir>/<name>.yaml
+type Case struct {
+	Name       string                   `yaml:"-"`
+	Language   string                   `yaml:"language"`
+	ReviewMode string                   `yaml:"review_mode"`
+	Diff       string                   `yaml:"diff"`
+	DiffFile   string                   `yaml:"diff_file"` // Read instead of Diff, relative to the case file
+	Expected   []models.ExpectedFinding `yaml:"expected"`  // Findings a good review reports; empty for a clean diff
+}
+
+// LoadCases reads the cases in dir, sorted by name. When names are given,
+// only those are loaded and each must exist.
+func LoadCases(dir string, names []string) ([]Case, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list eval cases: %w", err)
+	}
+	byName := make(map[string]string, len(paths))
+	for _, path := range paths {
+		byName[strings.TrimSuffix(filepath.Base(path), ".yaml")] = path
+	}
+
+	if len(names) == 0 {
+		for name := range byName {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no eval cases found in %s", dir)
+	}
+
+	cases := make([]Case, 0, len(names))
+	for _, name := range names {
+		path, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown eval case %q", name)
+		}
+		c, err := loadCase(path)
+		if err != nil {
+			return nil, fmt.Errorf("eval case %s: %w", name, err)
+		}
+		c.Name = name
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+// loadCase reads and checks one case file
+func loadCase(path string) (Case, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Case{}, fmt.Errorf("failed to read: %w", err)
+	}
+	var c Case
+	if err := yaml.Unmarshal(data, &c); err != nil {
+		return Case{}, fmt.Errorf("failed to parse: %w", err)
+	}
+	if c.DiffFile != "" {
+		diff, err := os.ReadFile(filepath.Join(filepath.Dir(path), c.DiffFile))
+		if err != nil {
+			return Case{}, fmt.Errorf("failed to read diff: %w", err)
+		}
+		c.Diff = string(diff)
+	}
+	if strings.TrimSpace(c.Diff) == "" {
+		return Case{}, fmt.Errorf("diff is empty")
+	}
+	for i, expected := range c.Expected {
+		if expected.Path == "" || expected.Line <= 0 {
+			return Case{}, fmt.Errorf("expected finding %d needs a path and a line", i)
+		}
+	}
+	return c, nil
+}
+
+// Score matches the diagnostics of a review to the expected findings. Each
+// expected finding is matched by at most one diagnostic in the same file
+// within tolerance lines of it, and in its category when it names one.
+// Diagnostics matching nothing are false positives; expected findings no
+// diagnostic matched are false negatives and returned as missed.
+func Score(expected []models.ExpectedFinding, diagnostics []models.Diagnostic, tolerance int) (result models.EvalCaseResult) {
+	result.Expected = len(expected)
+	result.Reported = len(diagnostics)
+
+	used := make([]bool, len(diagnostics))
+	for _, e := range expected {
+		match := -1
+		best := tolerance + 1
+		for i, d := range diagnostics {
+			if used[i] || d.Location.Path != e.Path {
+				continue
+			}
+			if e.Category != "" && !strings.EqualFold(d.Code.Value, e.Category) {
+				continue
+			}
+			if distance := lineDistance(e.Line, d.Location.Range); distance < best {
+				match, best = i, distance
+			}
+		}
+		if match < 0 {
+			result.Missed = append(result.Missed, e)
+			continue
+		}
+		used[match] = true
+		result.TruePositives++
+	}
+	result.FalsePositives = result.Reported - result.TruePositives
+	result.FalseNegatives = len(result.Missed)
+	result.Precision, result.Recall, result.F1 = Rates(result.TruePositives, result.FalsePositives, result.FalseNegatives)
+	return result
+}
+
+// Rates returns precision, recall and F1 for the counts. Precision is 1
+// when nothing was reported and recall is 1 when nothing was expected, so
+// a clean diff reviewed as clean scores perfectly.
+func Rates(truePositives, falsePositives, falseNegatives int) (precision, recall, f1 float64) {
+	precision, recall = 1, 1
+	if reported := truePositives + falsePositives; reported > 0 {
+		precision = float64(truePositives) / float64(reported)
+	}
+	if expected := truePositives + falseNegatives; expected > 0 {
+		recall = float64(truePositives) / float64(expected)
+	}
+	if precision+recall > 0 {
+		f1 = 2 * precision * recall / (precision + recall)
+	}
+	return precision, recall, f1
+}
+
+// lineDistance is how many lines line is outside a range
+func lineDistance(line int, r models.Range) int {
+	end := max(r.End.Line, r.Start.Line)
+	switch {
+	case line < r.Start.Line:
+		return r.Start.Line - line
+	case line > end:
+		return line - end
+	}
+	return 0
+}
@@ -0,0 +1,296 @@
+package feedback
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scm"
+	"golang.org/x/text/cases"
+)
+
+// Verdicts
+const (
+	VerdictHelpful       = "helpful"
+	VerdictFalsePositive = "false-positive"
+)
+
+// maxEntries bounds the feedback kept in memory; older entries stop
+// counting towards examples
+const maxEntries = 50000
+
+// entry is one stored verdict with the client it belongs to
+type entry struct {
+	Tenant   string          `json:"tenant"`
+	Feedback models.Feedback `json:"feedback"`
+	seq      uint64          // Position in the order verdicts were stored
+}
+
+// example aggregates the verdicts on one finding of a repository
+type example struct {
+	models.FeedbackExample
+	entries    int    // Verdicts counted
+	latest     uint64 // Last false-positive verdict
+	commentSeq uint64 // Verdict Comment came from
+}
+
+// Store keeps diagnostic feedback in memory, optionally appending it to a
+// JSON-lines file so it survives restarts. Verdicts are also aggregated
+// per tenant, repository and finding as they arrive, so examples for a
+// review don't require scanning every verdict.
+type Store struct {
+	mu       sync.RWMutex
+	path     string
+	entries  []entry
+	seq      uint64
+	examples map[string]map[string]*example // By tenant and repository, then category and message
+}
+
+// NewStore creates a feedback store, loading path if it exists
+func NewStore(path string) (*Store, error) {
+	s := &Store{path: path, examples: make(map[string]map[string]*example)}
+	if path == "" {
+		return s, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open feedback store: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			log.Printf("Warning: skipping unreadable feedback line %d: %v", line, err)
+			continue
+		}
+		s.entries = append(s.entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load feedback store: %w", err)
+	}
+	if len(s.entries) > maxEntries {
+		s.entries = s.entries[len(s.entries)-maxEntries:]
+	}
+	for i := range s.entries {
+		s.seq++
+		s.entries[i].seq = s.seq
+		s.index(s.entries[i])
+	}
+	return s, nil
+}
+
+// ValidVerdict reports whether verdict is a supported verdict
+func ValidVerdict(verdict string) bool {
+	return verdict == VerdictHelpful || verdict == VerdictFalsePositive
+}
+
+// Add records a client's verdict on a diagnostic
+func (s *Store) Add(tenant string, feedback models.Feedback) error {
+	if s == nil {
+		return nil
+	}
+	feedback.Repository = scm.NormalizeRepository(feedback.Repository)
+	e := entry{Tenant: tenant, Feedback: feedback}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	e.seq = s.seq
+	s.entries = append(s.entries, e)
+	s.index(e)
+	if len(s.entries) > maxEntries {
+		for _, evicted := range s.entries[:len(s.entries)-maxEntries] {
+			s.unindex(evicted)
+		}
+		s.entries = s.entries[len(s.entries)-maxEntries:]
+	}
+	if s.path == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode feedback: %w", err)
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open feedback store: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write feedback: %w", err)
+	}
+	return nil
+}
+
+// Examples returns up to n findings a client's developers have rejected
+// for a repository, most rejected first. Verdicts on the same category
+// and message are aggregated, and helpful votes cancel out false-positive
+// votes.
+func (s *Store) Examples(tenant, repo string, n int) []models.FeedbackExample {
+	if s == nil || n <= 0 {
+		return nil
+	}
+	repo = scm.NormalizeRepository(repo)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var rejected []*example
+	for _, example := range s.examples[repoKey(tenant, repo)] {
+		if example.Votes > 0 {
+			rejected = append(rejected, example)
+		}
+	}
+	sort.Slice(rejected, func(i, j int) bool {
+		a, b := rejected[i], rejected[j]
+		if a.Votes != b.Votes {
+			return a.Votes > b.Votes
+		}
+		return a.latest > b.latest
+	})
+
+	result := make([]models.FeedbackExample, 0, min(n, len(rejected)))
+	for _, example := range rejected[:min(n, len(rejected))] {
+		result = append(result, example.FeedbackExample)
+	}
+	return result
+}
+
+// index counts a verdict towards its finding's example; callers hold mu
+func (s *Store) index(e entry) {
+	f := e.Feedback
+	rk := repoKey(e.Tenant, f.Repository)
+	findings := s.examples[rk]
+	if findings == nil {
+		findings = make(map[string]*example)
+		s.examples[rk] = findings
+	}
+	k := f.Category + "|" + normalizeMessage(f.Message)
+	ex := findings[k]
+	if ex == nil {
+		ex = &example{}
+		findings[k] = ex
+	}
+	ex.entries++
+	switch f.Verdict {
+	case VerdictFalsePositive:
+		ex.Votes++
+		ex.Path = f.Path
+		ex.Category = f.Category
+		ex.Message = f.Message
+		if f.Comment != "" {
+			ex.Comment = f.Comment
+			ex.commentSeq = e.seq
+		}
+		ex.latest = e.seq
+	case VerdictHelpful:
+		ex.Votes--
+	}
+}
+
+// unindex removes an evicted verdict from its finding's example; callers
+// hold mu. Verdicts are evicted oldest first, so the path and message of
+// a later false positive stay current.
+func (s *Store) unindex(e entry) {
+	f := e.Feedback
+	rk := repoKey(e.Tenant, f.Repository)
+	k := f.Category + "|" + normalizeMessage(f.Message)
+	ex := s.examples[rk][k]
+	if ex == nil {
+		return
+	}
+	if ex.entries--; ex.entries == 0 {
+		delete(s.examples[rk], k)
+		if len(s.examples[rk]) == 0 {
+			delete(s.examples, rk)
+		}
+		return
+	}
+	switch f.Verdict {
+	case VerdictFalsePositive:
+		ex.Votes--
+	case VerdictHelpful:
+		ex.Votes++
+	}
+	if ex.commentSeq == e.seq {
+		ex.Comment = ""
+	}
+}
+
+// repoKey identifies a tenant's repository in the examples index
+func repoKey(tenant, repo string) string {
+	return tenant + "|" + repo
+}
+
+// ForReview returns the feedback a client gave on one review
+func (s *Store) ForReview(tenant, reviewID string) []models.Feedback {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []models.Feedback
+	for _, e := range s.entries {
+		if e.Tenant == tenant && e.Feedback.ReviewID == reviewID {
+			result = append(result, e.Feedback)
+		}
+	}
+	return result
+}
+
+// ByPromptVersion counts a client's verdicts per prompt version, for
+// comparing the versions of a prompt experiment. Feedback on reviews made
+// before prompt versions were registered is left out.
+func (s *Store) ByPromptVersion(tenant string) map[string]models.PromptVersionStats {
+	result := make(map[string]models.PromptVersionStats)
+	if s == nil {
+		return result
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, e := range s.entries {
+		f := e.Feedback
+		if e.Tenant != tenant || f.PromptVersion == "" {
+			continue
+		}
+		stats := result[f.PromptVersion]
+		switch f.Verdict {
+		case VerdictHelpful:
+			stats.Helpful++
+		case VerdictFalsePositive:
+			stats.FalsePositives++
+		}
+		result[f.PromptVersion] = stats
+	}
+	return result
+}
+
+// normalizeMessage reduces a message to case-folded words, in any script,
+// so that case and punctuation differences still match
+func normalizeMessage(message string) string {
+	return strings.Join(strings.FieldsFunc(cases.Fold().String(message), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.Is(unicode.Mn, r)
+	}), " ")
+}
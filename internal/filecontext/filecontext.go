@@ -0,0 +1,99 @@
+package filecontext
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scm"
+)
+
+// maxConcurrentFetches bounds parallel requests to the SCM API
+const maxConcurrentFetches = 4
+
+// Options controls how full file contents are gathered
+type Options struct {
+	Fetch    bool   // Fetch contents missing from the request from the repository
+	Token    string // SCM API token
+	MaxBytes int    // Total budget for file contents
+}
+
+// Collect returns the full contents of the files changed by a diff.
+// Contents supplied by the caller are used first; with Fetch enabled the
+// rest are downloaded at the reviewed commit. Files are included in diff
+// order until the byte budget is spent, and deleted or binary files are
+// skipped.
+func Collect(ctx context.Context, files []*diff.File, provided []models.FileContent, gitInfo *models.GitInfo, opts Options) []models.FileContent {
+	if opts.MaxBytes <= 0 {
+		return nil
+	}
+
+	byPath := make(map[string]string, len(provided))
+	for _, f := range provided {
+		byPath[diff.NormalizePath(f.Path)] = f.Content
+	}
+
+	var paths []string
+	for _, f := range files {
+		if f.IsDelete || f.IsBinary {
+			continue
+		}
+		paths = append(paths, f.Path())
+	}
+
+	canFetch := opts.Fetch && gitInfo != nil && gitInfo.RepoURL != ""
+	if canFetch {
+		fetchMissing(ctx, paths, byPath, gitInfo, opts)
+	}
+
+	var contents []models.FileContent
+	remaining := opts.MaxBytes
+	for _, path := range paths {
+		content, ok := byPath[path]
+		if !ok {
+			continue
+		}
+		if len(content) > remaining {
+			log.Printf("File context budget exhausted; skipping %s (%d bytes)", path, len(content))
+			continue
+		}
+		remaining -= len(content)
+		contents = append(contents, models.FileContent{Path: path, Content: content})
+	}
+	return contents
+}
+
+// fetchMissing downloads files not supplied by the caller into byPath
+func fetchMissing(ctx context.Context, paths []string, byPath map[string]string, gitInfo *models.GitInfo, opts Options) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxConcurrentFetches)
+
+	for _, path := range paths {
+		if _, ok := byPath[path]; ok {
+			continue
+		}
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			data, err := scm.FetchFile(ctx, gitInfo.RepoURL, gitInfo.CommitHash, path, opts.Token, int64(opts.MaxBytes))
+			if err != nil {
+				if !errors.Is(err, scm.ErrNotFound) && !errors.Is(err, scm.ErrTooLarge) {
+					log.Printf("Warning: failed to fetch context for %s: %v", path, err)
+				}
+				return
+			}
+
+			mu.Lock()
+			byPath[path] = string(data)
+			mu.Unlock()
+		}(path)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,66 @@
+package glob
+
+import (
+	"path"
+	"strings"
+)
+
+// Match reports whether a slash-separated file path matches a glob pattern.
+// In addition to path.Match syntax it supports:
+//   - "**" matching any number of directories ("vendor/**", "**/*.pb.go")
+//   - patterns without a slash matching the base name anywhere ("*.min.js")
+//   - patterns ending in "/" matching everything below a directory ("dist/")
+func Match(pattern, filePath string) bool {
+	pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "./")
+	filePath = strings.TrimPrefix(filePath, "./")
+	if pattern == "" {
+		return false
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(filePath))
+		return ok
+	}
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(filePath, "/"))
+}
+
+// MatchAny reports whether the path matches any of the patterns
+func MatchAny(patterns []string, filePath string) bool {
+	for _, p := range patterns {
+		if Match(p, filePath) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(rest, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
@@ -0,0 +1,146 @@
+package guidelines
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// MaxSize bounds the size of a single guideline document
+const MaxSize = 64 * 1024
+
+// fileExt is the extension of guideline documents on disk
+const fileExt = ".md"
+
+var nameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// Library holds named team guideline documents such as style guides and
+// API conventions. When a directory is set, documents are loaded from and
+// saved to <dir>/<name>.md.
+type Library struct {
+	mu   sync.RWMutex
+	dir  string
+	docs map[string]models.StyleGuide
+}
+
+// NewLibrary creates a library, loading any documents found in dir
+func NewLibrary(dir string) (*Library, error) {
+	l := &Library{
+		dir:  dir,
+		docs: make(map[string]models.StyleGuide),
+	}
+	if dir == "" {
+		return l, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create guidelines directory: %w", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+fileExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list guidelines: %w", err)
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), fileExt)
+		if !nameRegex.MatchString(name) {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read guideline %s: %w", name, err)
+		}
+		info, _ := os.Stat(path)
+		guide := models.StyleGuide{Name: name, Content: string(data)}
+		if info != nil {
+			guide.UpdatedAt = info.ModTime()
+		}
+		l.docs[name] = guide
+	}
+	return l, nil
+}
+
+// ValidName reports whether name can be used for a guideline
+func ValidName(name string) bool {
+	return nameRegex.MatchString(name)
+}
+
+// Put creates or replaces a guideline document
+func (l *Library) Put(name, content string) (models.StyleGuide, error) {
+	if !ValidName(name) {
+		return models.StyleGuide{}, fmt.Errorf("invalid guideline name %q: use lower-case letters, digits, '.', '_' and '-'", name)
+	}
+	if len(content) > MaxSize {
+		return models.StyleGuide{}, fmt.Errorf("guideline %s exceeds %d bytes", name, MaxSize)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.dir != "" {
+		if err := os.WriteFile(filepath.Join(l.dir, name+fileExt), []byte(content), 0o644); err != nil {
+			return models.StyleGuide{}, fmt.Errorf("failed to save guideline %s: %w", name, err)
+		}
+	}
+	guide := models.StyleGuide{Name: name, Content: content, UpdatedAt: time.Now()}
+	l.docs[name] = guide
+	return guide, nil
+}
+
+// Get returns a guideline document by name
+func (l *Library) Get(name string) (models.StyleGuide, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	guide, ok := l.docs[name]
+	return guide, ok
+}
+
+// Resolve returns the documents for the given names, failing on the first
+// unknown name
+func (l *Library) Resolve(names []string) ([]models.StyleGuide, error) {
+	guides := make([]models.StyleGuide, 0, len(names))
+	for _, name := range names {
+		guide, ok := l.Get(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown guideline %q", name)
+		}
+		guides = append(guides, guide)
+	}
+	return guides, nil
+}
+
+// Delete removes a guideline document. It reports whether it existed.
+func (l *Library) Delete(name string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.docs[name]; !ok {
+		return false, nil
+	}
+	if l.dir != "" {
+		if err := os.Remove(filepath.Join(l.dir, name+fileExt)); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("failed to delete guideline %s: %w", name, err)
+		}
+	}
+	delete(l.docs, name)
+	return true, nil
+}
+
+// List returns all guideline documents sorted by name
+func (l *Library) List() []models.StyleGuide {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	guides := make([]models.StyleGuide, 0, len(l.docs))
+	for _, g := range l.docs {
+		guides = append(guides, g)
+	}
+	sort.Slice(guides, func(i, j int) bool { return guides[i].Name < guides[j].Name })
+	return guides
+}
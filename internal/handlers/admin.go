@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
+)
+
+// AdminHandler handles operator endpoints
+type AdminHandler struct {
+	maintenance *middleware.MaintenanceMode
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(maintenance *middleware.MaintenanceMode) *AdminHandler {
+	return &AdminHandler{
+		maintenance: maintenance,
+	}
+}
+
+// maintenanceStatus is the body of the /admin/maintenance endpoint
+type maintenanceStatus struct {
+	Enabled bool `json:"enabled"`
+}
+
+// HandleMaintenance handles the /admin/maintenance endpoint.
+// GET returns the current state, POST/PUT sets it.
+func (h *AdminHandler) HandleMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		var status maintenanceStatus
+		if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"Invalid JSON: %v"}`, err), http.StatusBadRequest)
+			return
+		}
+		h.maintenance.Set(status.Enabled)
+		log.Printf("Maintenance mode set to %v", status.Enabled)
+	default:
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(maintenanceStatus{Enabled: h.maintenance.Enabled()}); err != nil {
+		log.Printf("Error encoding maintenance status: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
+)
+
+// aliasInfo describes a model alias
+type aliasInfo struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// listAliases returns the registry's model aliases sorted by name
+func listAliases(registry *providers.Registry) []aliasInfo {
+	aliases := registry.Aliases()
+	result := make([]aliasInfo, 0, len(aliases))
+	for name, alias := range aliases {
+		result = append(result, aliasInfo{Name: name, Provider: alias.Provider, Model: alias.Model})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// HandleAliases handles /admin/aliases and /admin/aliases/{name}. GET on
+// the collection lists aliases; PUT and DELETE on a name set and remove
+// one. Changes last until restart; MODEL_ALIASES sets the initial aliases.
+func (h *ProvidersHandler) HandleAliases(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/aliases"), "/")
+
+	if name == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"aliases": listAliases(h.registry)})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		alias, ok := h.registry.Aliases()[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Alias not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, aliasInfo{Name: name, Provider: alias.Provider, Model: alias.Model})
+
+	case http.MethodPut, http.MethodPost:
+		var alias providers.Alias
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&alias); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+			return
+		}
+		if err := h.registry.SetAlias(name, alias); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		alias = h.registry.Aliases()[name]
+		log.Printf("Model alias %s set to %s:%s", name, alias.Provider, alias.Model)
+		writeJSON(w, http.StatusOK, aliasInfo{Name: name, Provider: alias.Provider, Model: alias.Model})
+
+	case http.MethodDelete:
+		if !h.registry.DeleteAlias(name) {
+			writeError(w, http.StatusNotFound, "Alias not found")
+			return
+		}
+		log.Printf("Model alias %s deleted", name)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
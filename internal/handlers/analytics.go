@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/analytics"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/config"
+)
+
+// AnalyticsHandler serves aggregated review analytics
+type AnalyticsHandler struct {
+	recorder *analytics.Recorder
+	config   *config.Config
+	noiseKey []byte // Secret the noise of each window is derived from
+
+	mu      sync.Mutex
+	cached  *analytics.Export
+	cacheID analyticsWindow
+}
+
+// analyticsWindow identifies the export served for a time window under
+// one set of privacy settings
+type analyticsWindow struct {
+	start time.Time
+	opts  [4]float64
+}
+
+// NewAnalyticsHandler creates a new analytics handler
+func NewAnalyticsHandler(recorder *analytics.Recorder, cfg *config.Config) *AnalyticsHandler {
+	noiseKey := make([]byte, 32)
+	rand.Read(noiseKey)
+	return &AnalyticsHandler{
+		recorder: recorder,
+		config:   cfg,
+		noiseKey: noiseKey,
+	}
+}
+
+// export returns the export of the current window, computing it on the
+// window's first request. Within a window every caller gets the same
+// numbers, and the noise is derived from the window, so asking again
+// can't average it away.
+func (h *AnalyticsHandler) export() *analytics.Export {
+	window := time.Duration(h.config.AnalyticsNoiseWindow) * time.Minute
+	opts := analytics.PrivacyOptions{
+		MinTenants:      h.config.AnalyticsMinTenants,
+		MinReviews:      h.config.AnalyticsMinReviews,
+		Epsilon:         h.config.AnalyticsNoiseEpsilon,
+		MaxContribution: h.config.AnalyticsMaxContrib,
+	}
+	id := analyticsWindow{
+		start: time.Now().Truncate(window),
+		opts:  [4]float64{float64(opts.MinTenants), float64(opts.MinReviews), opts.Epsilon, float64(opts.MaxContribution)},
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cached != nil && h.cacheID == id {
+		return h.cached
+	}
+	seed := sha256.New()
+	seed.Write(h.noiseKey)
+	binary.Write(seed, binary.BigEndian, id.start.Unix())
+	opts.Seed = seed.Sum(nil)
+
+	h.cached = analytics.Aggregate(h.recorder.Snapshot(), opts)
+	h.cacheID = id
+	return h.cached
+}
+
+// HandleAnalytics handles the /analytics endpoint.
+// Only privacy-protected organisation-wide aggregates are exported; per-tenant
+// statistics never leave the gateway.
+func (h *AnalyticsHandler) HandleAnalytics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	export := h.export()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(export); err != nil {
+		log.Printf("Error encoding analytics: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/config"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/language"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/policy"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/preprocess"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/redact"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scheduler"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/telemetry"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/tenants"
+)
+
+// maxQuestionLength bounds the size of a question
+const maxQuestionLength = 2000
+
+// AskHandler answers targeted questions about a single hunk
+type AskHandler struct {
+	registry  *providers.Registry
+	config    *config.Config
+	scheduler *scheduler.Scheduler
+	policy    *policy.Policy
+}
+
+// NewAskHandler creates a new ask handler
+func NewAskHandler(registry *providers.Registry, cfg *config.Config, sched *scheduler.Scheduler, rules *policy.Policy) *AskHandler {
+	return &AskHandler{
+		registry:  registry,
+		config:    cfg,
+		scheduler: sched,
+		policy:    rules,
+	}
+}
+
+// HandleAsk handles the /ask endpoint
+func (h *AskHandler) HandleAsk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request models.AskRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.config.MaxDiffSize)).Decode(&request); err != nil {
+		bodyError(err, fmt.Sprintf("Invalid JSON: %v", err)).write(w)
+		return
+	}
+
+	// Validate request
+	if strings.TrimSpace(request.Hunk) == "" {
+		writeError(w, http.StatusBadRequest, "Empty hunk")
+		return
+	}
+	if strings.TrimSpace(request.Question) == "" {
+		writeError(w, http.StatusBadRequest, "Empty question")
+		return
+	}
+	if len(request.Question) > maxQuestionLength {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Question exceeds %d characters", maxQuestionLength))
+		return
+	}
+
+	// Apply defaults and aliases, and reject models the provider doesn't offer
+	registry := tenants.RegistryFor(r.Context(), h.registry)
+	var err error
+	request.AIProvider, request.AIModel, err = registry.Resolve(request.AIProvider, request.AIModel)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := middleware.AuthorizeModel(r.Context(), request.AIProvider, request.AIModel); err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err := h.policy.Check(policySubject(r.Context(), nil, request.Language), request.AIProvider, request.AIModel); err != nil {
+		writePolicyViolation(w, err)
+		return
+	}
+	if language.IsUnknown(request.Language) {
+		request.Language = language.Of(request.File, "")
+	}
+	if request.Language == "" {
+		request.Language = language.Unknown
+	}
+
+	// Never send credentials to the provider
+	var redactions []models.Redaction
+	if h.config.RedactSecrets {
+		var hunkRedactions, contextRedactions []models.Redaction
+		request.Hunk, hunkRedactions = redact.Diff(request.Hunk)
+		request.Context, contextRedactions = redact.Diff(request.Context)
+		redactions = append(hunkRedactions, contextRedactions...)
+		if len(redactions) > 0 {
+			log.Printf("Redacted %d secrets from ask request", len(redactions))
+		}
+	}
+
+	// Defang and flag text aimed at the model rather than at reviewers
+	var hunkFindings, contextFindings []models.InjectionFinding
+	request.Hunk, hunkFindings = preprocess.NeutralizeInjection(request.Hunk)
+	request.Context, contextFindings = preprocess.NeutralizeInjection(request.Context)
+	request.InjectionFindings = append(hunkFindings, contextFindings...)
+	if len(request.InjectionFindings) > 0 {
+		log.Printf("Warning: %d suspected prompt-injection attempts in ask request", len(request.InjectionFindings))
+	}
+
+	provider, err := registry.Get(request.AIProvider)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Provider not available: %v", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.config.QuestionTimeout)*time.Second)
+	defer cancel()
+
+	release, err := h.scheduler.Acquire(ctx, middleware.Priority(r.Context()))
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, "Timed out waiting for a free review slot")
+		return
+	}
+	defer release()
+
+	completionRequest := &providers.CompletionRequest{
+		Model:        request.AIModel,
+		SystemPrompt: prompt.GenerateAskSystemPrompt(request.Language),
+		UserPrompt:   prompt.GenerateAskUserPrompt(&request),
+		MaxTokens:    2048,
+		Temperature:  0.2,
+	}
+	start := time.Now()
+	completion, err := provider.Complete(ctx, completionRequest)
+	registry.ReportResult(request.AIProvider, err)
+	if err != nil {
+		log.Printf("AI ask error: %v", err)
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("AI request failed: %v", err))
+		return
+	}
+	chargeUsage(r.Context(), request.AIModel, completion.PromptTokens, completion.CompletionTokens)
+
+	response, err := prompt.ParseAskResponse(completion.Text, request.File)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to parse answer: %v", err))
+		return
+	}
+
+	telemetry.Emit(telemetry.Event{
+		Kind:      "ask",
+		ClientID:  middleware.ClientID(r.Context()),
+		Provider:  request.AIProvider,
+		Model:     request.AIModel,
+		DiffBytes: len(request.Hunk) + len(request.Context),
+		Usage: models.Usage{
+			PromptBytes:      len(completionRequest.SystemPrompt) + len(completionRequest.UserPrompt),
+			ResponseBytes:    len(completion.Text),
+			PromptTokens:     completion.PromptTokens,
+			CompletionTokens: completion.CompletionTokens,
+			Truncated:        completion.Truncated,
+		},
+		Diagnostics: len(response.Diagnostics),
+		Latency:     time.Since(start),
+	})
+
+	response.Redactions = redactions
+	response.SuspiciousContent = len(request.InjectionFindings) > 0
+	response.InjectionFindings = request.InjectionFindings
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/session"
+)
+
+// Batch item statuses
+const (
+	batchCompleted = "completed"
+	batchPending   = "pending"
+	batchFailed    = "failed"
+)
+
+// HandleBatch handles POST /review/batch. The body is a JSON array of
+// review requests, or NDJSON with one request per line. Reviews run
+// BATCH_CONCURRENCY at a time and each succeeds or fails on its own; those
+// with a callback_url are queued like asynchronous reviews. A JSON batch
+// is answered with every result in order once all are done; an NDJSON one
+// with a line per result as each finishes.
+func (h *ReviewHandler) HandleBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	ndjson := isNDJSON(r.Header.Get("Content-Type"))
+	requests, reqErr := h.parseBatch(r.Body, ndjson)
+	if reqErr != nil {
+		reqErr.write(w)
+		return
+	}
+	log.Printf("Batch of %d reviews received", len(requests))
+
+	var (
+		mu      sync.Mutex
+		results = make([]models.BatchItemResult, len(requests))
+		slots   = make(chan struct{}, h.config.BatchConcurrency)
+		wg      sync.WaitGroup
+	)
+	if ndjson {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+	}
+	for i, request := range requests {
+		wg.Add(1)
+		go func(i int, request models.ReviewRequest) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			result := h.batchItem(r, request)
+			result.Index = i
+			mu.Lock()
+			defer mu.Unlock()
+			results[i] = result
+			if ndjson {
+				if err := json.NewEncoder(w).Encode(result); err != nil {
+					log.Printf("Error encoding batch result: %v", err)
+				}
+				http.NewResponseController(w).Flush()
+			}
+		}(i, request)
+	}
+	wg.Wait()
+
+	response := models.BatchResponse{Items: results}
+	for _, result := range results {
+		switch result.Status {
+		case batchCompleted:
+			response.Completed++
+		case batchPending:
+			response.Pending++
+		default:
+			response.Failed++
+		}
+	}
+	log.Printf("Batch done: %d completed, %d pending, %d failed", response.Completed, response.Pending, response.Failed)
+	if !ndjson {
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+// parseBatch decodes the review requests of a batch body
+func (h *ReviewHandler) parseBatch(body io.Reader, ndjson bool) ([]models.ReviewRequest, *requestError) {
+	decoder := json.NewDecoder(body)
+	if !ndjson {
+		if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+			if err != nil {
+				return nil, bodyError(err, fmt.Sprintf("Invalid JSON: %v", err))
+			}
+			return nil, &requestError{http.StatusBadRequest, "Expected a JSON array of review requests, or NDJSON with Content-Type application/x-ndjson"}
+		}
+	}
+
+	var requests []models.ReviewRequest
+	for decoder.More() {
+		if len(requests) == h.config.BatchMaxItems {
+			return nil, &requestError{http.StatusBadRequest, fmt.Sprintf("At most %d reviews can be sent in a batch", h.config.BatchMaxItems)}
+		}
+		var request models.ReviewRequest
+		if err := decoder.Decode(&request); err != nil {
+			return nil, bodyError(err, fmt.Sprintf("Invalid JSON in review %d: %v", len(requests), err))
+		}
+		requests = append(requests, request)
+	}
+	if len(requests) == 0 {
+		return nil, &requestError{http.StatusBadRequest, "The batch has no reviews"}
+	}
+	return requests, nil
+}
+
+// batchItem runs one review of a batch as /review would
+func (h *ReviewHandler) batchItem(r *http.Request, request models.ReviewRequest) models.BatchItemResult {
+	failed := func(reqErr *requestError) models.BatchItemResult {
+		return models.BatchItemResult{Status: batchFailed, Error: &models.BatchError{Status: reqErr.status, Message: reqErr.message}}
+	}
+
+	if reqErr := h.checkReviewRequest(r.Context(), &request); reqErr != nil {
+		return failed(reqErr)
+	}
+	admitted, reqErr := h.admitReview(r, request)
+	if reqErr != nil {
+		return failed(reqErr)
+	}
+
+	if admitted.prepared.request.CallbackURL != "" {
+		id, reqErr := h.enqueue(admitted)
+		if reqErr != nil {
+			return failed(reqErr)
+		}
+		return models.BatchItemResult{Status: batchPending, ID: id}
+	}
+
+	response, reqErr := h.complete(admitted.r, admitted.registry, admitted.subject, admitted.prepared, session.NewID())
+	if reqErr != nil {
+		return failed(reqErr)
+	}
+	return models.BatchItemResult{Status: batchCompleted, ID: response.ID, Review: &response}
+}
+
+// isNDJSON reports whether a content type is newline-delimited JSON
+func isNDJSON(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	switch strings.TrimSpace(strings.ToLower(mediaType)) {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/pricing"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/tenants"
+)
+
+// maxCompareTargets bounds the fan-out of a single comparison
+const maxCompareTargets = 5
+
+// HandleCompare handles the /review/compare endpoint. The same diff is
+// reviewed by every target concurrently and the results are returned side
+// by side with latency and cost.
+func (h *ReviewHandler) HandleCompare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var compare struct {
+		Targets []models.CompareTarget `json:"targets"`
+	}
+	parsed, reqErr := h.parseReviewRequest(r, &compare)
+	if reqErr != nil {
+		reqErr.write(w)
+		return
+	}
+
+	if len(compare.Targets) < 2 {
+		writeError(w, http.StatusBadRequest, "At least two targets are required")
+		return
+	}
+	if len(compare.Targets) > maxCompareTargets {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("At most %d targets can be compared", maxCompareTargets))
+		return
+	}
+	registry := tenants.RegistryFor(r.Context(), h.registry)
+	for i, target := range compare.Targets {
+		provider, model, err := registry.Resolve(target.AIProvider, target.AIModel)
+		if err == nil {
+			err = registry.CheckParams(provider, model, parsed.ModelParams)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := middleware.AuthorizeModel(r.Context(), provider, model); err != nil {
+			writeError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if err := h.policy.Check(policySubject(r.Context(), parsed.GitInfo, parsed.Language), provider, model); err != nil {
+			writePolicyViolation(w, err)
+			return
+		}
+		compare.Targets[i].AIProvider, compare.Targets[i].AIModel = provider, model
+	}
+
+	prepared, reqErr := h.prepareReview(r, *parsed, true)
+	if reqErr != nil {
+		reqErr.write(w)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.reviewTimeout(prepared.request))
+	defer cancel()
+
+	results := make([]models.CompareResult, len(compare.Targets))
+	var wg sync.WaitGroup
+	for i, target := range compare.Targets {
+		wg.Add(1)
+		go func(i int, target models.CompareTarget) {
+			defer wg.Done()
+			results[i] = h.compareTarget(ctx, r, prepared, target)
+		}(i, target)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(models.CompareResponse{Results: results}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+
+	log.Printf("Comparison completed: %d targets", len(results))
+}
+
+// compareTarget runs a prepared review against a single target
+func (h *ReviewHandler) compareTarget(ctx context.Context, r *http.Request, prepared *preparedReview, target models.CompareTarget) models.CompareResult {
+	result := models.CompareResult{
+		AIProvider: target.AIProvider,
+		AIModel:    target.AIModel,
+	}
+
+	provider, err := tenants.RegistryFor(r.Context(), h.registry).Get(target.AIProvider)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	targetPrepared := *prepared
+	targetPrepared.request.AIProvider = target.AIProvider
+	targetPrepared.request.AIModel = target.AIModel
+
+	aiResponse, latency, reqErr := h.review(ctx, r, provider, &targetPrepared, targetPrepared.request)
+	if reqErr != nil {
+		result.Error = reqErr.message
+		return result
+	}
+
+	review := h.buildResponse(&targetPrepared, aiResponse)
+	result.Review = &review
+	result.LatencyMs = latency.Milliseconds()
+	result.PromptTokens = aiResponse.Usage.PromptTokens
+	result.CompletionTokens = aiResponse.Usage.CompletionTokens
+	if cost, ok := pricing.Cost(target.AIModel, result.PromptTokens, result.CompletionTokens); ok {
+		result.CostUSD = &cost
+	}
+	return result
+}
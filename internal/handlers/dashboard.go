@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/history"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/jobs"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
+)
+
+// Usage windows of the dashboard, in hours
+const (
+	defaultUsageHours = 24
+	maxUsageHours     = 24 * 14
+)
+
+// dashboardSummary is what the operator dashboard shows at a glance
+type dashboardSummary struct {
+	Providers []providerInfo             `json:"providers"`
+	Jobs      map[string]jobs.ClassStats `json:"jobs"`
+	Usage     []models.UsageBucket       `json:"usage"`   // Hourly, oldest first
+	History   bool                       `json:"history"` // Whether reviews are kept, and usage can be shown
+}
+
+// DashboardHandler serves the data of the operator dashboard
+type DashboardHandler struct {
+	registry *providers.Registry
+	history  *history.Store
+	queue    *jobs.Queue
+}
+
+// NewDashboardHandler creates a new dashboard handler
+func NewDashboardHandler(registry *providers.Registry, store *history.Store, queue *jobs.Queue) *DashboardHandler {
+	return &DashboardHandler{registry: registry, history: store, queue: queue}
+}
+
+// HandleSummary handles GET /admin/dashboard, reporting provider health,
+// job queue load and hourly usage over the last ?hours (24 by default)
+func (h *DashboardHandler) HandleSummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	hours, err := queryInt(r.URL.Query().Get("hours"), defaultUsageHours)
+	if err != nil || hours < 1 || hours > maxUsageHours {
+		writeError(w, http.StatusBadRequest, "hours must be between 1 and 336")
+		return
+	}
+
+	since := time.Now().Add(-time.Duration(hours-1) * time.Hour)
+	usage, err := h.history.Usage(since, time.Hour)
+	if err != nil {
+		historyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dashboardSummary{
+		Providers: describeProviders(h.registry),
+		Jobs:      h.queue.Stats(),
+		Usage:     usage,
+		History:   h.history != nil,
+	})
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/pricing"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
+)
+
+// HandleDryRun handles the /review/dry-run endpoint. It prepares a review
+// request as a review would and returns the exact system and user prompts
+// of each provider call with their token estimates, without calling a
+// provider, for debugging prompt templates and repository settings.
+func (h *ReviewHandler) HandleDryRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	plan, reqErr := h.planReview(r)
+	if reqErr != nil {
+		reqErr.write(w)
+		return
+	}
+	request := plan.prepared.request
+
+	response := models.DryRunResponse{
+		AIProvider:     request.AIProvider,
+		AIModel:        request.AIModel,
+		ReviewMode:     request.ReviewMode,
+		Calls:          make([]models.PromptPreview, 0, len(plan.calls)),
+		CritiquePass:   request.ReviewMode == prompt.ModeRefined,
+		SkippedFiles:   plan.prepared.skippedFiles,
+		TruncatedFiles: request.TruncatedFiles,
+		Redactions:     plan.prepared.redactions,
+	}
+	anonymized := request.Anonymize || h.config.ShouldAnonymize(request.AIProvider)
+	for _, call := range plan.calls {
+		preview := promptPreview(call, anonymized)
+		response.PromptTokens += preview.PromptTokens
+		response.Calls = append(response.Calls, preview)
+	}
+
+	log.Printf("Review dry run: %d provider calls, ~%d prompt tokens", len(response.Calls), response.PromptTokens)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// promptPreview builds the prompts a provider call sends, with their
+// token estimate
+func promptPreview(call models.ReviewRequest, anonymized bool) models.PromptPreview {
+	files := diff.Parse(call.GitDiff)
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.Path())
+	}
+	preview := models.PromptPreview{
+		Language:     call.Language,
+		Files:        paths,
+		Anonymized:   anonymized,
+		SystemPrompt: prompt.GenerateSystemPrompt(call.Language, call.ReviewMode, call.Categories, call.PromptVersion),
+		UserPrompt:   prompt.GenerateUserPrompt(&call),
+	}
+	preview.PromptTokens = pricing.EstimateTokens(preview.SystemPrompt) + pricing.EstimateTokens(preview.UserPrompt)
+	return preview
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/language"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/pricing"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/tenants"
+)
+
+// estimateOutputTokens is the output budget assumed when the request sets
+// none, the default of most providers
+const estimateOutputTokens = 4096
+
+// reviewPlan is a review prepared as HandleReview would, split into the
+// provider calls it would make
+type reviewPlan struct {
+	files    []*diff.File // Every file of the change, including those the review skips
+	prepared *preparedReview
+	calls    []models.ReviewRequest // Each call's request as the provider sees it
+}
+
+// planReview parses, resolves and prepares a review request without
+// calling a provider or fetching from the repository; supplied file
+// contents still count
+func (h *ReviewHandler) planReview(r *http.Request) (*reviewPlan, *requestError) {
+	parsed, reqErr := h.parseReviewRequest(r, nil)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	request := *parsed
+
+	registry := tenants.RegistryFor(r.Context(), h.registry)
+	h.routeRequest(r, registry, &request)
+	var err error
+	request.AIProvider, request.AIModel, err = registry.Resolve(request.AIProvider, request.AIModel)
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, err.Error()}
+	}
+
+	plan := &reviewPlan{files: diff.Parse(request.GitDiff)}
+	plan.prepared, reqErr = h.prepareReview(r, request, false)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	plan.calls = h.providerCalls(plan.prepared)
+	return plan, nil
+}
+
+// providerCalls splits a prepared review into the requests of the provider
+// calls it makes, as each provider sees them
+func (h *ReviewHandler) providerCalls(prepared *preparedReview) []models.ReviewRequest {
+	request := prepared.request
+	calls := []models.ReviewRequest{request}
+	if len(prepared.groups) > 0 {
+		preparedFiles := diff.Parse(request.GitDiff)
+		calls = calls[:0]
+		for i, group := range prepared.groups {
+			calls = append(calls, languageGroupRequest(request, preparedFiles, group, i == 0))
+		}
+	}
+	for i, call := range calls {
+		calls[i], _ = h.providerRequest(call)
+	}
+	return calls
+}
+
+// HandleEstimate handles the /review/estimate endpoint. It takes a review
+// request, prepares it as a review would without calling a provider, and
+// returns the diff's statistics with the expected token usage and cost, so
+// CI can decide whether a review is worth running.
+func (h *ReviewHandler) HandleEstimate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	plan, reqErr := h.planReview(r)
+	if reqErr != nil {
+		reqErr.write(w)
+		return
+	}
+	prepared := plan.prepared
+	request := prepared.request
+
+	// Count every file of the change, including those the review skips
+	estimate := models.EstimateResponse{
+		AIProvider:        request.AIProvider,
+		AIModel:           request.AIModel,
+		FilesChanged:      len(plan.files),
+		Files:             make([]models.FileStats, 0, len(plan.files)),
+		DetectedLanguages: prepared.languages,
+	}
+	skipped := make(map[string]bool, len(prepared.skippedFiles))
+	for _, path := range prepared.skippedFiles {
+		skipped[path] = true
+	}
+	truncated := make(map[string]bool, len(request.TruncatedFiles))
+	for _, t := range request.TruncatedFiles {
+		truncated[t.Path] = true
+	}
+	for _, f := range plan.files {
+		added, removed := f.Stats()
+		estimate.LinesAdded += added
+		estimate.LinesRemoved += removed
+		estimate.Files = append(estimate.Files, models.FileStats{
+			Path:      f.Path(),
+			Language:  language.OfFile(f),
+			Added:     added,
+			Removed:   removed,
+			Binary:    f.IsBinary,
+			Skipped:   skipped[f.Path()],
+			Truncated: truncated[f.Path()],
+		})
+	}
+
+	// Estimate the prompts each provider call would send
+	outputTokens := prompt.MaxOutputTokens(request.ReviewMode, estimateOutputTokens)
+	if request.ModelParams.MaxTokens > 0 {
+		outputTokens = request.ModelParams.MaxTokens
+	}
+	window, knownWindow := pricing.ContextWindow(request.AIModel)
+	for _, call := range plan.calls {
+		tokens := pricing.EstimateTokens(prompt.GenerateSystemPrompt(call.Language, call.ReviewMode, call.Categories, call.PromptVersion)) +
+			pricing.EstimateTokens(prompt.GenerateUserPrompt(&call))
+		estimate.ProviderCalls++
+		estimate.PromptTokens += tokens
+		estimate.CompletionTokens += outputTokens
+		if knownWindow && tokens+outputTokens > window {
+			estimate.ChunkingRequired = true
+		}
+
+		// A refined review sends the diff again with the first-pass findings
+		if request.ReviewMode == prompt.ModeRefined {
+			critique := pricing.EstimateTokens(prompt.GenerateCritiqueSystemPrompt(call.Language)) +
+				pricing.EstimateTokens(prompt.GenerateCritiqueUserPrompt(&call, &models.AIProviderResponse{})) + outputTokens
+			estimate.ProviderCalls++
+			estimate.PromptTokens += critique
+			estimate.CompletionTokens += outputTokens
+		}
+	}
+	if knownWindow {
+		estimate.ContextWindow = window
+	}
+	if cost, ok := pricing.Cost(request.AIModel, estimate.PromptTokens, estimate.CompletionTokens); ok {
+		estimate.CostUSD = &cost
+	}
+
+	log.Printf("Review estimate: %d files, ~%d prompt tokens, %d provider calls", estimate.FilesChanged, estimate.PromptTokens, estimate.ProviderCalls)
+	writeJSON(w, http.StatusOK, estimate)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/eval"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/pricing"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/tenants"
+)
+
+// evalConcurrency bounds the cases of one eval run reviewed at once
+const evalConcurrency = 4
+
+// HandleEval handles POST /eval/run. Every case of the golden set in
+// EVAL_DIR, or the named ones, is reviewed by the requested provider, model
+// and prompt version through the regular review pipeline, and the findings
+// are scored against the labeled ones.
+func (h *ReviewHandler) HandleEval(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if h.config.EvalDir == "" {
+		writeError(w, http.StatusServiceUnavailable, "Evaluation is disabled")
+		return
+	}
+
+	var request models.EvalRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return
+	}
+
+	registry := tenants.RegistryFor(r.Context(), h.registry)
+	var err error
+	request.AIProvider, request.AIModel, err = registry.Resolve(request.AIProvider, request.AIModel)
+	if err == nil {
+		err = registry.CheckParams(request.AIProvider, request.AIModel, request.ModelParams)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := middleware.AuthorizeModel(r.Context(), request.AIProvider, request.AIModel); err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err := h.policy.Check(policySubject(r.Context(), nil, ""), request.AIProvider, request.AIModel); err != nil {
+		writePolicyViolation(w, err)
+		return
+	}
+	provider, err := registry.Get(request.AIProvider)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Provider not available: %v", err))
+		return
+	}
+
+	// Cases use the version asked for, never the experiment's pick
+	if request.PromptVersion == "" && prompt.HasVersion(prompt.DefaultVersion) {
+		request.PromptVersion = prompt.DefaultVersion
+	}
+	if request.PromptVersion != "" && !prompt.HasVersion(request.PromptVersion) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Unknown prompt version %q", request.PromptVersion))
+		return
+	}
+
+	cases, err := eval.LoadCases(h.config.EvalDir, request.Cases)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := models.EvalResponse{
+		AIProvider:    request.AIProvider,
+		AIModel:       request.AIModel,
+		PromptVersion: request.PromptVersion,
+		Cases:         make([]models.EvalCaseResult, len(cases)),
+	}
+	usages := make([]models.Usage, len(cases))
+	slots := make(chan struct{}, evalConcurrency)
+	var wg sync.WaitGroup
+	for i, c := range cases {
+		wg.Add(1)
+		go func(i int, c eval.Case) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			response.Cases[i], usages[i] = h.evalCase(r, provider, request, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for i, result := range response.Cases {
+		if result.Error != "" {
+			response.Failed++
+			continue
+		}
+		response.TruePositives += result.TruePositives
+		response.FalsePositives += result.FalsePositives
+		response.FalseNegatives += result.FalseNegatives
+		response.PromptTokens += usages[i].PromptTokens
+		response.CompletionTokens += usages[i].CompletionTokens
+	}
+	response.Precision, response.Recall, response.F1 = eval.Rates(response.TruePositives, response.FalsePositives, response.FalseNegatives)
+	if cost, ok := pricing.Cost(request.AIModel, response.PromptTokens, response.CompletionTokens); ok {
+		response.CostUSD = &cost
+	}
+
+	log.Printf("Eval run completed: %s/%s, %d cases (%d failed), precision %.2f, recall %.2f",
+		request.AIProvider, request.AIModel, len(cases), response.Failed, response.Precision, response.Recall)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// evalCase reviews one case and scores the result
+func (h *ReviewHandler) evalCase(r *http.Request, provider providers.AIProvider, request models.EvalRequest, c eval.Case) (models.EvalCaseResult, models.Usage) {
+	reviewRequest := models.ReviewRequest{
+		AIProvider:    request.AIProvider,
+		AIModel:       request.AIModel,
+		Language:      c.Language,
+		ReviewMode:    c.ReviewMode,
+		GitDiff:       c.Diff,
+		PromptVersion: request.PromptVersion,
+		ModelParams:   request.ModelParams,
+	}
+	if request.ReviewMode != "" {
+		reviewRequest.ReviewMode = request.ReviewMode
+	}
+
+	prepared, reqErr := h.prepareReview(r, reviewRequest, false)
+	if reqErr != nil {
+		return models.EvalCaseResult{Name: c.Name, Error: reqErr.message}, models.Usage{}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.reviewTimeout(prepared.request))
+	defer cancel()
+
+	aiResponse, latency, reqErr := h.review(ctx, r, provider, prepared, prepared.request)
+	if reqErr != nil {
+		return models.EvalCaseResult{Name: c.Name, Error: reqErr.message}, models.Usage{}
+	}
+
+	review := h.buildResponse(prepared, aiResponse)
+	result := eval.Score(c.Expected, review.Diagnostics, h.config.EvalLineTolerance)
+	result.Name = c.Name
+	result.Diagnostics = review.Diagnostics
+	result.LatencyMs = latency.Milliseconds()
+	return result, aiResponse.Usage
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/redact"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/telemetry"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/tenants"
+)
+
+// HandleFollowup handles POST /review/{id}/followup, answering a question
+// about a completed review with its diff and findings as context
+func (h *ReviewHandler) HandleFollowup(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/review/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "followup" {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	id := parts[0]
+
+	var request models.FollowupRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return
+	}
+	if strings.TrimSpace(request.Question) == "" {
+		writeError(w, http.StatusBadRequest, "Empty question")
+		return
+	}
+	if len(request.Question) > maxQuestionLength {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Question exceeds %d characters", maxQuestionLength))
+		return
+	}
+
+	review, ok := h.sessions.Get(middleware.ClientID(r.Context()), id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Review not found or expired")
+		return
+	}
+	// The provider only ever saw an anonymized diff; don't send it the original
+	if review.Anonymized {
+		writeError(w, http.StatusBadRequest, "Follow-up questions are not available for anonymized reviews")
+		return
+	}
+
+	followup := &prompt.FollowupContext{
+		Diff:        review.Diff,
+		Files:       review.Files,
+		Overview:    review.Overview,
+		Diagnostics: review.Diagnostics,
+		History:     review.History,
+	}
+	if request.Diagnostic != nil {
+		index := *request.Diagnostic
+		if index < 0 || index >= len(review.Diagnostics) {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("diagnostic must be between 0 and %d", len(review.Diagnostics)-1))
+			return
+		}
+		followup.Focus = &review.Diagnostics[index]
+	}
+
+	// Developers sometimes paste credentials into questions
+	question := request.Question
+	if h.config.RedactSecrets {
+		question, _ = redact.Diff(question)
+	}
+
+	registry := tenants.RegistryFor(r.Context(), h.registry)
+	provider, err := registry.Get(review.Provider)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Provider not available: %v", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.config.QuestionTimeout)*time.Second)
+	defer cancel()
+
+	release, err := h.scheduler.Acquire(ctx, middleware.Priority(r.Context()))
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, "Timed out waiting for a free review slot")
+		return
+	}
+	defer release()
+
+	completionRequest := &providers.CompletionRequest{
+		Model:        review.Model,
+		SystemPrompt: prompt.GenerateFollowupSystemPrompt(review.Language),
+		UserPrompt:   prompt.GenerateFollowupUserPrompt(followup, question),
+		MaxTokens:    2048,
+		Temperature:  0.3,
+	}
+	start := time.Now()
+	completion, err := provider.Complete(ctx, completionRequest)
+	registry.ReportResult(review.Provider, err)
+	if err != nil {
+		log.Printf("AI follow-up error: %v", err)
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("AI request failed: %v", err))
+		return
+	}
+	chargeUsage(r.Context(), review.Model, completion.PromptTokens, completion.CompletionTokens)
+
+	telemetry.Emit(telemetry.Event{
+		Kind:      "followup",
+		ClientID:  middleware.ClientID(r.Context()),
+		Provider:  review.Provider,
+		Model:     review.Model,
+		DiffBytes: len(review.Diff),
+		Usage: models.Usage{
+			PromptBytes:      len(completionRequest.SystemPrompt) + len(completionRequest.UserPrompt),
+			ResponseBytes:    len(completion.Text),
+			PromptTokens:     completion.PromptTokens,
+			CompletionTokens: completion.CompletionTokens,
+			Truncated:        completion.Truncated,
+		},
+		Latency: time.Since(start),
+	})
+
+	answer := strings.TrimSpace(completion.Text)
+	turns := h.sessions.Append(id,
+		models.ConversationTurn{Role: models.RoleUser, Content: question},
+		models.ConversationTurn{Role: models.RoleAssistant, Content: answer},
+	)
+
+	writeJSON(w, http.StatusOK, models.FollowupResponse{
+		ReviewID: id,
+		Answer:   answer,
+		Turns:    turns,
+	})
+}
@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/config"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/language"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/policy"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/postprocess"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/preprocess"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/redact"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scheduler"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/telemetry"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/tenants"
+)
+
+// maxNotesLength bounds the author's notes sent with a generation request
+const maxNotesLength = 4000
+
+// GenerateHandler writes text about a change, such as pull request
+// descriptions, rather than reviewing it
+type GenerateHandler struct {
+	registry  *providers.Registry
+	config    *config.Config
+	scheduler *scheduler.Scheduler
+	policy    *policy.Policy
+}
+
+// NewGenerateHandler creates a new generate handler
+func NewGenerateHandler(registry *providers.Registry, cfg *config.Config, sched *scheduler.Scheduler, rules *policy.Policy) *GenerateHandler {
+	return &GenerateHandler{
+		registry:  registry,
+		config:    cfg,
+		scheduler: sched,
+		policy:    rules,
+	}
+}
+
+// HandlePRDescription handles the /generate/pr-description endpoint
+func (h *GenerateHandler) HandlePRDescription(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request models.PRDescriptionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.config.RequestSizeLimit())).Decode(&request); err != nil {
+		bodyError(err, fmt.Sprintf("Invalid JSON: %v", err)).write(w)
+		return
+	}
+	if strings.TrimSpace(request.GitDiff) == "" {
+		writeError(w, http.StatusBadRequest, "Empty git diff")
+		return
+	}
+	if size := int64(len(request.GitDiff)); size > h.config.MaxDiffSize {
+		diffTooLarge(size, h.config.MaxDiffSize).write(w)
+		return
+	}
+	if len(request.Notes) > maxNotesLength {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Notes exceed %d characters", maxNotesLength))
+		return
+	}
+
+	registry, provider, reqErr := h.resolve(r.Context(), &request.AIProvider, &request.AIModel, request.GitInfo, request.Language)
+	if reqErr != nil {
+		reqErr.write(w)
+		return
+	}
+
+	// Leave out vendored, generated and ignored files, which would only
+	// crowd the description
+	files, skipped := preprocess.FileFilter{
+		IgnorePatterns: h.config.IgnorePaths,
+		SkipGenerated:  h.config.SkipGenerated,
+	}.Apply(diff.Parse(request.GitDiff))
+	if len(files) == 0 {
+		writeError(w, http.StatusBadRequest, "Every file in the diff is ignored, generated or binary")
+		return
+	}
+	request.GitDiff = diff.Join(files)
+	if language.IsUnknown(request.Language) {
+		request.Language = language.Describe(language.Detect(files))
+	}
+
+	// Never send credentials to the provider
+	var redactions []models.Redaction
+	if h.config.RedactSecrets {
+		var diffRedactions, notesRedactions []models.Redaction
+		request.GitDiff, diffRedactions = redact.Diff(request.GitDiff)
+		request.Notes, notesRedactions = redact.Diff(request.Notes)
+		redactions = append(diffRedactions, notesRedactions...)
+		if len(redactions) > 0 {
+			log.Printf("Redacted %d secrets from pr-description request", len(redactions))
+		}
+	}
+
+	var diffFindings, notesFindings []models.InjectionFinding
+	request.GitDiff, diffFindings = preprocess.NeutralizeInjection(request.GitDiff)
+	request.Notes, notesFindings = preprocess.NeutralizeInjection(request.Notes)
+	request.InjectionFindings = append(diffFindings, notesFindings...)
+	if len(request.InjectionFindings) > 0 {
+		log.Printf("Warning: %d suspected prompt-injection attempts in pr-description request", len(request.InjectionFindings))
+	}
+
+	completion, reqErr := h.complete(r, registry, provider, "pr-description", request.AIProvider, &providers.CompletionRequest{
+		Model:        request.AIModel,
+		SystemPrompt: prompt.GeneratePRDescriptionSystemPrompt(request.Language),
+		UserPrompt:   prompt.GeneratePRDescriptionUserPrompt(&request),
+		MaxTokens:    2048,
+		Temperature:  0.3,
+	}, len(request.GitDiff))
+	if reqErr != nil {
+		reqErr.write(w)
+		return
+	}
+
+	response, err := prompt.ParsePRDescription(completion.Text)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("Failed to parse description: %v", err))
+		return
+	}
+	response.SkippedFiles = skipped
+	response.Redactions = redactions
+	response.SuspiciousContent = len(request.InjectionFindings) > 0
+	response.InjectionFindings = request.InjectionFindings
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// HandleDocs handles the /generate/docs endpoint
+func (h *GenerateHandler) HandleDocs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request models.DocsRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.config.RequestSizeLimit())).Decode(&request); err != nil {
+		bodyError(err, fmt.Sprintf("Invalid JSON: %v", err)).write(w)
+		return
+	}
+	hasDiff := strings.TrimSpace(request.GitDiff) != ""
+	if hasDiff == (request.File != nil) {
+		writeError(w, http.StatusBadRequest, "Send either git_diff or file")
+		return
+	}
+	if request.File != nil {
+		if request.File.Path == "" || strings.TrimSpace(request.File.Content) == "" {
+			writeError(w, http.StatusBadRequest, "file needs a path and content")
+			return
+		}
+		if size := int64(len(request.File.Content)); size > h.config.MaxDiffSize {
+			diffTooLarge(size, h.config.MaxDiffSize).write(w)
+			return
+		}
+	} else if size := int64(len(request.GitDiff)); size > h.config.MaxDiffSize {
+		diffTooLarge(size, h.config.MaxDiffSize).write(w)
+		return
+	}
+
+	registry, provider, reqErr := h.resolve(r.Context(), &request.AIProvider, &request.AIModel, request.GitInfo, request.Language)
+	if reqErr != nil {
+		reqErr.write(w)
+		return
+	}
+
+	var skipped []string
+	var languages []string
+	if hasDiff {
+		var files []*diff.File
+		files, skipped = preprocess.FileFilter{
+			IgnorePatterns: h.config.IgnorePaths,
+			SkipGenerated:  h.config.SkipGenerated,
+		}.Apply(diff.Parse(request.GitDiff))
+		if len(files) == 0 {
+			writeError(w, http.StatusBadRequest, "Every file in the diff is ignored, generated or binary")
+			return
+		}
+		request.GitDiff = diff.Join(files)
+		languages = language.Detect(files)
+	} else if name := language.Of(request.File.Path, strings.SplitN(request.File.Content, "\n", 2)[0]); name != "" {
+		languages = []string{name}
+	}
+	if language.IsUnknown(request.Language) {
+		request.Language = language.Describe(languages)
+	}
+
+	// Never send credentials to the provider
+	text := &request.GitDiff
+	if request.File != nil {
+		text = &request.File.Content
+	}
+	var redactions []models.Redaction
+	if h.config.RedactSecrets {
+		*text, redactions = redact.Diff(*text)
+		if len(redactions) > 0 {
+			log.Printf("Redacted %d secrets from docs request", len(redactions))
+		}
+	}
+	*text, request.InjectionFindings = preprocess.NeutralizeInjection(*text)
+	if len(request.InjectionFindings) > 0 {
+		log.Printf("Warning: %d suspected prompt-injection attempts in docs request", len(request.InjectionFindings))
+	}
+
+	completion, reqErr := h.complete(r, registry, provider, "docs", request.AIProvider, &providers.CompletionRequest{
+		Model:        request.AIModel,
+		SystemPrompt: prompt.GenerateDocsSystemPrompt(request.Language),
+		UserPrompt:   prompt.GenerateDocsUserPrompt(&request),
+		MaxTokens:    4096,
+		Temperature:  0.2,
+	}, len(*text))
+	if reqErr != nil {
+		reqErr.write(w)
+		return
+	}
+
+	proposals, err := prompt.ParseDocs(completion.Text)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("Failed to parse documentation: %v", err))
+		return
+	}
+
+	response := models.DocsResponse{
+		Docs:              docComments(proposals, &request),
+		SkippedFiles:      skipped,
+		Redactions:        redactions,
+		SuspiciousContent: len(request.InjectionFindings) > 0,
+		InjectionFindings: request.InjectionFindings,
+	}
+	if dropped := len(proposals) - len(response.Docs); dropped > 0 {
+		log.Printf("Docs: dropped %d comments for lines outside the change", dropped)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// docComments turns the model's proposals into patches that insert each
+// comment. For a diff, only declarations on added lines are documented.
+func docComments(proposals []prompt.DocProposal, request *models.DocsRequest) []models.DocComment {
+	var files []*diff.File
+	var contents []models.FileContent
+	if request.File != nil {
+		contents = []models.FileContent{*request.File}
+	} else {
+		files = diff.Parse(request.GitDiff)
+	}
+	known := postprocess.KnownLines(files, contents)
+	added := make(map[string]map[int]bool, len(files))
+	for _, f := range files {
+		added[f.Path()] = f.AddedLines()
+	}
+
+	docs := []models.DocComment{}
+	seen := make(map[string]bool)
+	for _, p := range proposals {
+		path := diff.NormalizePath(p.Path)
+		key := fmt.Sprintf("%s:%d", path, p.Line)
+		if seen[key] || (request.File == nil && !added[path][p.Line]) {
+			continue
+		}
+		declaration, ok := known.Line(path, p.Line)
+		if !ok {
+			continue
+		}
+
+		// Indent comments above nested declarations like the declaration
+		comment := p.Comment
+		indent := declaration[:len(declaration)-len(strings.TrimLeft(declaration, " \t"))]
+		if p.Placement == prompt.PlacementAbove && indent != "" && comment == strings.TrimLeft(comment, " \t") {
+			comment = indent + strings.ReplaceAll(comment, "\n", "\n"+indent)
+		}
+		replacement := comment + "\n" + declaration
+		if p.Placement == prompt.PlacementBelow {
+			replacement = declaration + "\n" + comment
+		}
+
+		patch, ok := known.Patch(path, models.Fix{StartLine: p.Line, EndLine: p.Line, Replacement: replacement}, request.SuggestionBlocks)
+		if !ok {
+			continue
+		}
+		seen[key] = true
+		docs = append(docs, models.DocComment{
+			Path:       path,
+			Line:       p.Line,
+			Symbol:     p.Symbol,
+			Comment:    comment,
+			Diff:       patch.Diff,
+			Suggestion: patch.Suggestion,
+		})
+	}
+	return docs
+}
+
+// resolve applies defaults and aliases to the requested provider and model,
+// and checks them against the caller's key and the provider policy
+func (h *GenerateHandler) resolve(ctx context.Context, providerName, model *string, gitInfo *models.GitInfo, language string) (*providers.Registry, providers.AIProvider, *requestError) {
+	registry := tenants.RegistryFor(ctx, h.registry)
+	var err error
+	*providerName, *model, err = registry.Resolve(*providerName, *model)
+	if err != nil {
+		return nil, nil, &requestError{http.StatusBadRequest, err.Error()}
+	}
+	if err := middleware.AuthorizeModel(ctx, *providerName, *model); err != nil {
+		return nil, nil, &requestError{http.StatusForbidden, err.Error()}
+	}
+	if err := h.policy.Check(policySubject(ctx, gitInfo, language), *providerName, *model); err != nil {
+		return nil, nil, &requestError{http.StatusForbidden, "Policy violation: " + err.Error()}
+	}
+	provider, err := registry.Get(*providerName)
+	if err != nil {
+		return nil, nil, &requestError{http.StatusBadRequest, fmt.Sprintf("Provider not available: %v", err)}
+	}
+	return registry, provider, nil
+}
+
+// complete runs a completion within REVIEW_TIMEOUT and a scheduler slot,
+// charging its usage to the caller's quotas
+func (h *GenerateHandler) complete(r *http.Request, registry *providers.Registry, provider providers.AIProvider, kind, providerName string, request *providers.CompletionRequest, inputBytes int) (*providers.CompletionResponse, *requestError) {
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(h.config.ReviewTimeout)*time.Second)
+	defer cancel()
+
+	release, err := h.scheduler.Acquire(ctx, middleware.Priority(r.Context()))
+	if err != nil {
+		return nil, &requestError{http.StatusServiceUnavailable, "Timed out waiting for a free review slot"}
+	}
+	defer release()
+
+	start := time.Now()
+	completion, err := provider.Complete(ctx, request)
+	registry.ReportResult(providerName, err)
+	if err != nil {
+		log.Printf("AI %s error: %v", kind, err)
+		return nil, &requestError{http.StatusInternalServerError, fmt.Sprintf("AI request failed: %v", err)}
+	}
+	chargeUsage(r.Context(), request.Model, completion.PromptTokens, completion.CompletionTokens)
+
+	telemetry.Emit(telemetry.Event{
+		Kind:      kind,
+		ClientID:  middleware.ClientID(r.Context()),
+		Provider:  providerName,
+		Model:     request.Model,
+		DiffBytes: inputBytes,
+		Usage: models.Usage{
+			PromptBytes:      len(request.SystemPrompt) + len(request.UserPrompt),
+			ResponseBytes:    len(completion.Text),
+			PromptTokens:     completion.PromptTokens,
+			CompletionTokens: completion.CompletionTokens,
+			Truncated:        completion.Truncated,
+		},
+		Latency: time.Since(start),
+	})
+	return completion, nil
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/guidelines"
+)
+
+// GuidelinesHandler manages named team guideline documents
+type GuidelinesHandler struct {
+	library *guidelines.Library
+}
+
+// NewGuidelinesHandler creates a new guidelines handler
+func NewGuidelinesHandler(library *guidelines.Library) *GuidelinesHandler {
+	return &GuidelinesHandler{
+		library: library,
+	}
+}
+
+// HandleGuidelines handles /admin/guidelines and /admin/guidelines/{name}.
+// GET on the collection lists documents; GET, PUT and DELETE on a name
+// read, replace and remove a document. PUT accepts the raw document as
+// the body, or JSON of the form {"content": "..."}.
+func (h *GuidelinesHandler) HandleGuidelines(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/guidelines"), "/")
+
+	if name == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		guides := h.library.List()
+		for i := range guides {
+			guides[i].Content = ""
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"guidelines": guides})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		guide, ok := h.library.Get(name)
+		if !ok {
+			writeError(w, http.StatusNotFound, "Guideline not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, guide)
+
+	case http.MethodPut, http.MethodPost:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, guidelines.MaxSize+1024))
+		if err != nil {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Guideline exceeds %d bytes", guidelines.MaxSize))
+			return
+		}
+		content := string(body)
+		if strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+			var doc struct {
+				Content string `json:"content"`
+			}
+			if err := json.Unmarshal(body, &doc); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+				return
+			}
+			content = doc.Content
+		}
+		if strings.TrimSpace(content) == "" {
+			writeError(w, http.StatusBadRequest, "Empty guideline")
+			return
+		}
+
+		guide, err := h.library.Put(name, content)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("Guideline %s updated (%d bytes)", name, len(content))
+		guide.Content = ""
+		writeJSON(w, http.StatusOK, guide)
+
+	case http.MethodDelete:
+		deleted, err := h.library.Delete(name)
+		if err != nil {
+			log.Printf("Guideline error: %v", err)
+			writeError(w, http.StatusInternalServerError, "Failed to delete guideline")
+			return
+		}
+		if !deleted {
+			writeError(w, http.StatusNotFound, "Guideline not found")
+			return
+		}
+		log.Printf("Guideline %s deleted", name)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
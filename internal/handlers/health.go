@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ReadinessCheck reports whether one dependency of the gateway is usable
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// checkResult is the outcome of one readiness check
+type checkResult struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// HealthHandler serves the liveness and readiness probes
+type HealthHandler struct {
+	checks   []ReadinessCheck
+	draining atomic.Bool
+}
+
+// NewHealthHandler creates a new health handler running checks on each
+// readiness probe
+func NewHealthHandler(checks ...ReadinessCheck) *HealthHandler {
+	return &HealthHandler{
+		checks: checks,
+	}
+}
+
+// SetDraining marks the gateway as shutting down, failing readiness so
+// load balancers stop sending it new requests
+func (h *HealthHandler) SetDraining() {
+	h.draining.Store(true)
+}
+
+// HandleLiveness handles GET /healthz. It succeeds while the process can
+// serve requests at all.
+func (h *HealthHandler) HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+}
+
+// HandleReadiness handles GET /readyz. It fails with 503 while draining or
+// when any check fails.
+func (h *HealthHandler) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "draining", "checks": []checkResult{}})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	status, code := "ready", http.StatusOK
+	results := make([]checkResult, 0, len(h.checks))
+	for _, check := range h.checks {
+		result := checkResult{Name: check.Name, OK: true}
+		if err := check.Check(ctx); err != nil {
+			result.OK = false
+			result.Error = err.Error()
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+		results = append(results, result)
+	}
+	writeJSON(w, code, map[string]interface{}{"status": status, "checks": results})
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/feedback"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/history"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
+)
+
+// Review history page sizes
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// maxFeedbackComment bounds the reason given with a verdict
+const maxFeedbackComment = 500
+
+// HistoryHandler serves the review history and collects feedback on it
+type HistoryHandler struct {
+	store    *history.Store
+	feedback *feedback.Store
+}
+
+// NewHistoryHandler creates a new history handler
+func NewHistoryHandler(store *history.Store, verdicts *feedback.Store) *HistoryHandler {
+	return &HistoryHandler{store: store, feedback: verdicts}
+}
+
+// HandleReviews handles GET /reviews, GET /reviews/{id} and
+// POST /reviews/{id}/feedback
+func (h *HistoryHandler) HandleReviews(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		writeError(w, http.StatusServiceUnavailable, "Review history is disabled")
+		return
+	}
+	tenant := middleware.ClientID(r.Context())
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/reviews"), "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[1] == "feedback":
+		h.handleFeedback(w, r, tenant, parts[0])
+		return
+	case len(parts) > 1:
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	if id := parts[0]; id != "" {
+		review, ok, err := h.store.Get(tenant, id)
+		if err != nil {
+			historyError(w, err)
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusNotFound, "Review not found")
+			return
+		}
+		review.Feedback = h.feedback.ForReview(tenant, id)
+		writeJSON(w, http.StatusOK, review)
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := queryInt(query.Get("limit"), defaultHistoryLimit)
+	if err != nil || limit < 1 || limit > maxHistoryLimit {
+		writeError(w, http.StatusBadRequest, "limit must be between 1 and 200")
+		return
+	}
+	offset, err := queryInt(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "offset must not be negative")
+		return
+	}
+
+	list, err := h.store.List(tenant, history.Query{
+		Repository:    query.Get("repo"),
+		PRNumber:      query.Get("pr"),
+		PromptVersion: query.Get("prompt_version"),
+		Limit:         limit,
+		Offset:        offset,
+	})
+	if err != nil {
+		historyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// HandleAdminReviews handles GET /admin/reviews and GET
+// /admin/reviews/{id}: the review history of every client, for operators
+func (h *HistoryHandler) HandleAdminReviews(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if h.store == nil {
+		writeError(w, http.StatusServiceUnavailable, "Review history is disabled")
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/reviews"), "/")
+	if strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if id != "" {
+		review, ok, err := h.store.Find(id)
+		if err != nil {
+			historyError(w, err)
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusNotFound, "Review not found")
+			return
+		}
+		review.Feedback = h.feedback.ForReview(review.Tenant, id)
+		writeJSON(w, http.StatusOK, review)
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := queryInt(query.Get("limit"), defaultHistoryLimit)
+	if err != nil || limit < 1 || limit > maxHistoryLimit {
+		writeError(w, http.StatusBadRequest, "limit must be between 1 and 200")
+		return
+	}
+	offset, err := queryInt(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "offset must not be negative")
+		return
+	}
+
+	list, err := h.store.ListAll(query.Get("tenant"), history.Query{
+		Repository:    query.Get("repo"),
+		PRNumber:      query.Get("pr"),
+		PromptVersion: query.Get("prompt_version"),
+		Limit:         limit,
+		Offset:        offset,
+	})
+	if err != nil {
+		historyError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleFeedback records a verdict on one diagnostic of a review
+func (h *HistoryHandler) handleFeedback(w http.ResponseWriter, r *http.Request, tenant, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request models.FeedbackRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16*1024)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return
+	}
+	request.Verdict = strings.ToLower(strings.TrimSpace(request.Verdict))
+	if !feedback.ValidVerdict(request.Verdict) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid verdict %q: must be helpful or false-positive", request.Verdict))
+		return
+	}
+	if len(request.Comment) > maxFeedbackComment {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Comment exceeds %d characters", maxFeedbackComment))
+		return
+	}
+
+	review, ok, err := h.store.Get(tenant, id)
+	if err != nil {
+		historyError(w, err)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "Review not found")
+		return
+	}
+	if request.Diagnostic == nil || *request.Diagnostic < 0 || *request.Diagnostic >= len(review.Diagnostics) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("diagnostic must be between 0 and %d", len(review.Diagnostics)-1))
+		return
+	}
+
+	diagnostic := review.Diagnostics[*request.Diagnostic]
+	verdict := models.Feedback{
+		ReviewID:   id,
+		Diagnostic: *request.Diagnostic,
+		Verdict:    request.Verdict,
+		Comment:    strings.TrimSpace(request.Comment),
+		Repository: review.Repository,
+		Path:       diagnostic.Location.Path,
+		Category:   diagnostic.Code.Value,
+		Message:    diagnostic.Message,
+		CreatedAt:  time.Now().UTC(),
+
+		PromptVersion: review.PromptVersion,
+	}
+	if err := h.feedback.Add(tenant, verdict); err != nil {
+		log.Printf("Error saving feedback: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to save feedback")
+		return
+	}
+
+	log.Printf("Feedback on review %s diagnostic %d: %s", id, verdict.Diagnostic, verdict.Verdict)
+	writeJSON(w, http.StatusCreated, verdict)
+}
+
+// HandlePrompts handles GET /prompts, listing the registered prompt
+// versions with the client's reviews and feedback on each, so the versions
+// of an experiment can be compared
+func (h *HistoryHandler) HandlePrompts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	tenant := middleware.ClientID(r.Context())
+
+	weights := prompt.ExperimentWeights()
+	scores := h.feedback.ByPromptVersion(tenant)
+	list := models.PromptVersionList{
+		Experiment: weights != nil,
+		Versions:   []models.PromptVersionStats{},
+	}
+	for _, version := range prompt.Versions() {
+		stats := scores[version]
+		stats.Version = version
+		stats.Weight = weights[version]
+		reviews, err := h.store.List(tenant, history.Query{PromptVersion: version})
+		if err != nil {
+			historyError(w, err)
+			return
+		}
+		stats.Reviews = reviews.Total
+		if rated := stats.Helpful + stats.FalsePositives; rated > 0 {
+			rate := float64(stats.Helpful) / float64(rated)
+			stats.HelpfulRate = &rate
+		}
+		list.Versions = append(list.Versions, stats)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// historyError reports a failure to read the review history
+func historyError(w http.ResponseWriter, err error) {
+	log.Printf("Error reading review history: %v", err)
+	writeError(w, http.StatusInternalServerError, "Failed to read review history")
+}
+
+// queryInt parses an optional integer query parameter
+func queryInt(value string, defaultValue int) (int, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/config"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/knowledge"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/preprocess"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/redact"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scm"
+)
+
+// maxIndexDocuments bounds the number of documents in one index request
+const maxIndexDocuments = 1000
+
+// IndexHandler manages the repository knowledge base
+type IndexHandler struct {
+	store  *knowledge.Store
+	config *config.Config
+}
+
+// NewIndexHandler creates a new index handler
+func NewIndexHandler(store *knowledge.Store, cfg *config.Config) *IndexHandler {
+	return &IndexHandler{
+		store:  store,
+		config: cfg,
+	}
+}
+
+// HandleIndex handles the /index endpoint. POST adds documents to a
+// repository's index, GET lists indexed repositories and DELETE removes
+// a repository's index.
+func (h *IndexHandler) HandleIndex(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		writeError(w, http.StatusServiceUnavailable, "Indexing is not available: no embedding provider configured")
+		return
+	}
+
+	tenant := middleware.ClientID(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{"repositories": h.store.Stats(tenant)})
+
+	case http.MethodDelete:
+		repo := r.URL.Query().Get("repository")
+		if repo == "" {
+			writeError(w, http.StatusBadRequest, "Missing repository parameter")
+			return
+		}
+		deleted, err := h.store.Delete(tenant, repo)
+		if err != nil {
+			log.Printf("Index error: %v", err)
+			writeError(w, http.StatusInternalServerError, "Failed to delete index")
+			return
+		}
+		if !deleted {
+			writeError(w, http.StatusNotFound, "Repository is not indexed")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodPost:
+		h.index(w, r, tenant)
+
+	default:
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// index embeds the documents of a POST /index request
+func (h *IndexHandler) index(w http.ResponseWriter, r *http.Request, tenant string) {
+	var request models.IndexRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.config.MaxDiffSize)).Decode(&request); err != nil {
+		bodyError(err, fmt.Sprintf("Invalid JSON: %v", err)).write(w)
+		return
+	}
+
+	if strings.TrimSpace(request.Repository) == "" {
+		writeError(w, http.StatusBadRequest, "Missing repository")
+		return
+	}
+	if len(request.Documents) == 0 {
+		writeError(w, http.StatusBadRequest, "No documents to index")
+		return
+	}
+	if len(request.Documents) > maxIndexDocuments {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("At most %d documents can be indexed per request", maxIndexDocuments))
+		return
+	}
+	for i, doc := range request.Documents {
+		if doc.Path == "" {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Document %d has no path", i))
+			return
+		}
+		switch doc.Kind {
+		case "", knowledge.KindCode, knowledge.KindGuideline:
+		default:
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid kind %q: must be code or guideline", doc.Kind))
+			return
+		}
+
+		// Indexed content is sent to the embedding provider and later to
+		// review providers, so it gets the same treatment as diffs
+		if h.config.RedactSecrets {
+			request.Documents[i].Content, _ = redact.Diff(doc.Content)
+		}
+		request.Documents[i].Content, _ = preprocess.NeutralizeInjection(request.Documents[i].Content)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+	defer cancel()
+
+	chunks, err := h.store.Index(ctx, tenant, request.Repository, request.Documents, request.Replace)
+	if err != nil {
+		log.Printf("Index error: %v", err)
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("Indexing failed: %v", err))
+		return
+	}
+
+	log.Printf("Indexed %d documents for %s (%d chunks total)", len(request.Documents), scm.NormalizeRepository(request.Repository), chunks)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"repository": scm.NormalizeRepository(request.Repository),
+		"documents":  len(request.Documents),
+		"chunks":     chunks,
+	})
+}
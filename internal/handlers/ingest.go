@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/session"
+)
+
+// ReviewIngested reviews a request read from the message queue as /review
+// would, with the gateway's own providers and no API key restrictions
+func (h *ReviewHandler) ReviewIngested(ctx context.Context, request models.ReviewRequest) (models.ReviewResponse, *models.BatchError) {
+	failed := func(reqErr *requestError) (models.ReviewResponse, *models.BatchError) {
+		return models.ReviewResponse{}, &models.BatchError{Status: reqErr.status, Message: reqErr.message}
+	}
+
+	if request.CallbackURL != "" {
+		return failed(&requestError{http.StatusBadRequest, "callback_url is not supported for queued reviews; results are published to INGEST_RESULTS_TOPIC"})
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/review", nil)
+	if err != nil {
+		return failed(&requestError{http.StatusInternalServerError, err.Error()})
+	}
+	if reqErr := h.checkReviewRequest(ctx, &request); reqErr != nil {
+		return failed(reqErr)
+	}
+	admitted, reqErr := h.admitReview(r, request)
+	if reqErr != nil {
+		return failed(reqErr)
+	}
+	response, reqErr := h.complete(admitted.r, admitted.registry, admitted.subject, admitted.prepared, session.NewID())
+	if reqErr != nil {
+		return failed(reqErr)
+	}
+	return response, nil
+}
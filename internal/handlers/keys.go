@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/quota"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/ratelimit"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/tenants"
+)
+
+// KeysHandler manages client API keys
+type KeysHandler struct {
+	store   *middleware.KeyStore
+	tenants *tenants.Directory
+	limiter *ratelimit.Limiter
+}
+
+// NewKeysHandler creates a new keys handler; a nil store disables it.
+// Tiers of new keys are checked against limiter.
+func NewKeysHandler(store *middleware.KeyStore, directory *tenants.Directory, limiter *ratelimit.Limiter) *KeysHandler {
+	return &KeysHandler{
+		store:   store,
+		tenants: directory,
+		limiter: limiter,
+	}
+}
+
+// keyView is a client key as shown by the admin API, without its hash
+type keyView struct {
+	ID       string       `json:"id"`
+	Name     string       `json:"name"`
+	Owner    string       `json:"owner,omitempty"`
+	Created  *time.Time   `json:"created,omitempty"`
+	Scopes   []string     `json:"scopes,omitempty"`
+	Models   []string     `json:"models,omitempty"`
+	Tenant   string       `json:"tenant,omitempty"`
+	Quota    *quota.Limit `json:"quota,omitempty"`
+	Tier     string       `json:"tier,omitempty"`
+	LastUsed *time.Time   `json:"last_used,omitempty"`
+	Source   string       `json:"source"` // store or env
+}
+
+// HandleKeys handles /admin/keys and /admin/keys/{id}. GET on the
+// collection lists keys with their last use, POST creates a key and returns
+// its secret once; GET and DELETE on an ID read and revoke a key.
+func (h *KeysHandler) HandleKeys(w http.ResponseWriter, r *http.Request) {
+	if h.store == nil {
+		writeError(w, http.StatusNotFound, "API keys are disabled (AUTH_MODE=jwt)")
+		return
+	}
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/keys"), "/")
+
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			keys := h.store.List()
+			views := make([]keyView, 0, len(keys))
+			for _, key := range keys {
+				views = append(views, h.view(key))
+			}
+			writeJSON(w, http.StatusOK, map[string]interface{}{"keys": views})
+
+		case http.MethodPost:
+			h.create(w, r)
+
+		default:
+			http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		for _, key := range h.store.List() {
+			if key.ID == id {
+				writeJSON(w, http.StatusOK, h.view(key))
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "Key not found")
+
+	case http.MethodDelete:
+		revoked, err := h.store.Revoke(id)
+		if errors.Is(err, middleware.ErrStaticKey) {
+			writeError(w, http.StatusConflict, "Key is configured in API_KEYS; remove it there")
+			return
+		}
+		if err != nil {
+			log.Printf("Key store error: %v", err)
+			writeError(w, http.StatusInternalServerError, "Failed to revoke key")
+			return
+		}
+		if !revoked {
+			writeError(w, http.StatusNotFound, "Key not found")
+			return
+		}
+		log.Printf("Client key %s revoked", id)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+// create handles POST /admin/keys
+func (h *KeysHandler) create(w http.ResponseWriter, r *http.Request) {
+	if !h.store.Writable() {
+		writeError(w, http.StatusConflict, "API_KEY_STORE must be set to create keys")
+		return
+	}
+
+	var request struct {
+		Name   string       `json:"name"`
+		Owner  string       `json:"owner"`
+		Scopes []string     `json:"scopes"`
+		Models []string     `json:"models"`
+		Tenant string       `json:"tenant"`
+		Tier   string       `json:"tier"`
+		Quota  *quota.Limit `json:"quota"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		bodyError(err, "Invalid JSON: "+err.Error()).write(w)
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	if err := middleware.CheckModelPatterns(request.Models); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, ok := h.tenants.Get(request.Tenant); request.Tenant != "" && !ok {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Unknown tenant %s", request.Tenant))
+		return
+	}
+
+	if _, ok := h.limiter.Tier(request.Tier); request.Tier != "" && !ok {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Unknown rate-limit tier %s", request.Tier))
+		return
+	}
+
+	if request.Quota != nil && (request.Quota.Tokens < 0 || request.Quota.CostUSD < 0) {
+		writeError(w, http.StatusBadRequest, "quota must not be negative")
+		return
+	}
+
+	secret, key, err := h.store.Create(request.Name, request.Owner, request.Scopes, request.Models, request.Tenant, request.Tier, request.Quota)
+	if err != nil {
+		log.Printf("Key store error: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create key")
+		return
+	}
+	log.Printf("Client key %s (%s) created", key.ID, key.Name)
+	writeJSON(w, http.StatusCreated, struct {
+		keyView
+		Secret string `json:"secret"`
+	}{h.view(key), secret})
+}
+
+// view converts a key for display
+func (h *KeysHandler) view(key *middleware.Key) keyView {
+	view := keyView{
+		ID:     key.ID,
+		Name:   key.Name,
+		Owner:  key.Owner,
+		Scopes: key.Scopes,
+		Models: key.Models,
+		Tenant: key.Tenant,
+		Quota:  key.Quota,
+		Tier:   key.Tier,
+		Source: "store",
+	}
+	if h.store.IsStatic(key) {
+		view.Source = "env"
+	} else {
+		created := key.Created
+		view.Created = &created
+	}
+	if used, ok := key.LastUsedAt(); ok {
+		view.LastUsed = &used
+	}
+	return view
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/policy"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/tenants"
+)
+
+// policySubject describes a request to the provider policy
+func policySubject(ctx context.Context, gitInfo *models.GitInfo, language string) policy.Subject {
+	subject := policy.Subject{Language: language}
+	if gitInfo != nil {
+		subject.Repo = gitInfo.RepoURL
+	}
+	if t := tenants.FromContext(ctx); t != nil {
+		subject.Tenant = t.Name
+	}
+	return subject
+}
+
+// writePolicyViolation rejects a request the provider policy forbids
+func writePolicyViolation(w http.ResponseWriter, err error) {
+	policyViolation(err).write(w)
+}
+
+// policyViolation is the error of a request the provider policy forbids
+func policyViolation(err error) *requestError {
+	return &requestError{http.StatusForbidden, "Policy violation: " + err.Error()}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/config"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/tenants"
+)
+
+// providerInfo describes a registered provider
+type providerInfo struct {
+	Name         string           `json:"name"`
+	Default      bool             `json:"default,omitempty"` // Used when a request doesn't name a provider
+	DefaultModel string           `json:"default_model,omitempty"`
+	Models       []string         `json:"models"`
+	Embeddings   bool             `json:"embeddings,omitempty"` // Can embed the repository knowledge base
+	Health       providers.Health `json:"health"`
+}
+
+// modelInfo describes one model offered by a provider
+type modelInfo struct {
+	ID           string `json:"id"`
+	Provider     string `json:"provider"`
+	Default      bool   `json:"default,omitempty"`        // The provider's default model
+	P95LatencyMs int64  `json:"p95_latency_ms,omitempty"` // Of recent successful reviews
+}
+
+// ProvidersHandler serves provider and model discovery
+type ProvidersHandler struct {
+	registry *providers.Registry
+	config   *config.Config
+}
+
+// NewProvidersHandler creates a new providers handler
+func NewProvidersHandler(registry *providers.Registry, cfg *config.Config) *ProvidersHandler {
+	return &ProvidersHandler{
+		registry: registry,
+		config:   cfg,
+	}
+}
+
+// HandleProviders handles GET /providers
+func (h *ProvidersHandler) HandleProviders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	registry := tenants.RegistryFor(r.Context(), h.registry)
+	defaultProvider, _ := registry.Defaults()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"default_provider": defaultProvider,
+		"providers":        describeProviders(registry),
+	})
+}
+
+// HandleModels handles GET /models, optionally filtered by ?provider=
+func (h *ProvidersHandler) HandleModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	registry := tenants.RegistryFor(r.Context(), h.registry)
+	filter := r.URL.Query().Get("provider")
+	if filter != "" {
+		if _, err := registry.Get(filter); err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+	}
+
+	models := []modelInfo{}
+	for _, p := range describeProviders(registry) {
+		if filter != "" && p.Name != filter {
+			continue
+		}
+		for _, model := range p.Models {
+			info := modelInfo{ID: model, Provider: p.Name, Default: model == p.DefaultModel}
+			if latency, samples := registry.LatencyP95(p.Name, model); samples > 0 {
+				info.P95LatencyMs = latency.Milliseconds()
+			}
+			models = append(models, info)
+		}
+	}
+	aliases := []aliasInfo{}
+	for _, alias := range listAliases(registry) {
+		if filter == "" || alias.Provider == filter {
+			aliases = append(aliases, alias)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"models": models, "aliases": aliases})
+}
+
+// providerKeys is the per-key usage of one provider
+type providerKeys struct {
+	Name string               `json:"name"`
+	Keys []providers.KeyStats `json:"keys"`
+}
+
+// HandleProviderKeys handles GET /admin/providers, reporting the usage of
+// each provider's upstream API keys
+func (h *ProvidersHandler) HandleProviderKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	names := h.registry.List()
+	sort.Strings(names)
+	result := []providerKeys{}
+	for _, name := range names {
+		if keys := h.registry.KeyStats(name); keys != nil {
+			result = append(result, providerKeys{Name: name, Keys: keys})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"providers": result})
+}
+
+// describeProviders describes every provider in a registry, sorted by name
+func describeProviders(registry *providers.Registry) []providerInfo {
+	names := registry.List()
+	sort.Strings(names)
+	defaultProvider, defaultModel := registry.Defaults()
+
+	result := make([]providerInfo, 0, len(names))
+	for _, name := range names {
+		provider, err := registry.Get(name)
+		if err != nil {
+			continue
+		}
+		info := providerInfo{
+			Name:         name,
+			Default:      name == defaultProvider,
+			DefaultModel: provider.DefaultModel(),
+			Models:       provider.SupportedModels(),
+			Health:       registry.Health(name),
+		}
+		if info.Default && defaultModel != "" {
+			info.DefaultModel = defaultModel
+		}
+		_, info.Embeddings = provider.(providers.Embedder)
+		result = append(result, info)
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/jobs"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/quota"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/ratelimit"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/tenants"
+)
+
+// queuedReview is an asynchronous review as kept in Redis: the checked
+// request, who sent it and the quotas it is charged to, enough for any
+// replica to review it. Fields of the request that aren't encoded and
+// aren't derived again by prepareReview are kept alongside it.
+type queuedReview struct {
+	ID             string                    `json:"id"`
+	ClientID       string                    `json:"client_id,omitempty"`
+	Tenant         string                    `json:"tenant,omitempty"`
+	Quotas         []middleware.QuotaAccount `json:"quotas,omitempty"`
+	Request        models.ReviewRequest      `json:"request"`
+	LinterFindings []models.Diagnostic       `json:"linter_findings,omitempty"` // Parsed from the request's linter_reports
+	Route          string                    `json:"route,omitempty"`           // Routing rule that picked the provider
+}
+
+// enqueueDurable queues an admitted review in Redis
+func (h *ReviewHandler) enqueueDurable(admitted *admittedReview, id string) error {
+	job := queuedReview{
+		ID:             id,
+		ClientID:       middleware.ClientID(admitted.r.Context()),
+		Quotas:         middleware.QuotaAccounts(admitted.r.Context()),
+		Request:        admitted.request,
+		LinterFindings: admitted.request.LinterFindings,
+		Route:          admitted.request.Route,
+	}
+	if t := tenants.FromContext(admitted.r.Context()); t != nil {
+		job.Tenant = t.Name
+	}
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return h.jobs.SubmitPayload(admitted.request.Priority, payload)
+}
+
+// RunQueued returns the handler reviewing jobs taken from Redis. Each is
+// prepared again for its caller and tenant, found in directory, and its
+// result sent to its callback URL. The caller's key and quotas were
+// checked when the review was queued; its usage is charged in quotas to
+// the accounts recorded then.
+func (h *ReviewHandler) RunQueued(directory *tenants.Directory, quotas *quota.Tracker) jobs.Handler {
+	return func(jobCtx context.Context, payload []byte) {
+		var job queuedReview
+		if err := json.Unmarshal(payload, &job); err != nil {
+			log.Printf("Error decoding queued review: %v", err)
+			return
+		}
+		request := job.Request
+		request.LinterFindings, request.Route = job.LinterFindings, job.Route
+
+		ctx := middleware.WithQuotas(middleware.WithClientID(jobCtx, job.ClientID), quotas, job.Quotas)
+		if job.Tenant != "" {
+			t, ok := directory.Get(job.Tenant)
+			if !ok {
+				log.Printf("Queued review %s belongs to unknown tenant %s", job.ID, job.Tenant)
+				event, body := reviewEvent(job.ID, models.ReviewResponse{}, &requestError{http.StatusForbidden, "Unknown tenant"})
+				h.webhooks.Send(request.CallbackURL, event, body)
+				return
+			}
+			ctx = tenants.NewContext(ctx, t)
+		}
+		if request.Priority == jobs.ClassBatch {
+			ctx = middleware.WithPriority(ctx, ratelimit.PriorityLow)
+		}
+		r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/review", nil)
+		if err != nil {
+			log.Printf("Error running queued review %s: %v", job.ID, err)
+			return
+		}
+
+		registry := tenants.RegistryFor(ctx, h.registry)
+		subject := policySubject(ctx, request.GitInfo, request.Language)
+		var response models.ReviewResponse
+		prepared, reqErr := h.prepareReview(r, request, true)
+		if reqErr == nil {
+			response, reqErr = h.complete(r, registry, subject, prepared, job.ID)
+		}
+		event, body := reviewEvent(job.ID, response, reqErr)
+		h.webhooks.Send(request.CallbackURL, event, body)
+	}
+}
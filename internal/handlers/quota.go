@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/pricing"
+)
+
+// HandleQuota handles the /quota endpoint, reporting the caller's usage
+// and what remains of its monthly quotas
+func HandleQuota(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"quotas": middleware.QuotaStatus(r.Context()),
+	})
+}
+
+// chargeUsage charges a provider call to the caller's quotas. Models
+// without a price only count towards token quotas.
+func chargeUsage(ctx context.Context, model string, promptTokens, completionTokens int) {
+	cost, _ := pricing.Cost(model, promptTokens, completionTokens)
+	middleware.RecordUsage(ctx, promptTokens+completionTokens, cost)
+}
+
+// reviewCost returns the cost in USD of a review's usage, at the batch
+// price when it went through a batch API and with cached prompt tokens at
+// their discount, and false if the model has no known price
+func reviewCost(model string, usage models.Usage) (float64, bool) {
+	cost, ok := pricing.Cost(model, usage.PromptTokens-usage.CachedTokens, usage.CompletionTokens)
+	if usage.CachedTokens > 0 {
+		cached, _ := pricing.Cost(model, usage.CachedTokens, 0)
+		cost += cached * pricing.CachedInputDiscount
+	}
+	if usage.Batched {
+		cost *= pricing.BatchDiscount
+	}
+	return cost, ok
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/jobs"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/linter"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/repoconfig"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scm"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/verdict"
+)
+
+// maxGuidelines bounds the named guidelines referenced by one request
+const maxGuidelines = 5
+
+// maxBaseline bounds the acknowledged findings sent with one request
+const maxBaseline = 10000
+
+// maxCommitMessageLength bounds the commit message sent with a review
+const maxCommitMessageLength = 10000
+
+// maxLinterReports bounds the linter reports sent with one request
+const maxLinterReports = 10
+
+// requestError is an error that maps to an HTTP status code
+type requestError struct {
+	status  int
+	message string
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// write sends the error as a JSON error body
+func (e *requestError) write(w http.ResponseWriter) {
+	writeError(w, e.status, e.message)
+}
+
+// writeError sends a JSON error body with the given status
+func writeError(w http.ResponseWriter, status int, message string) {
+	body, _ := json.Marshal(map[string]string{"error": message})
+	http.Error(w, string(body), status)
+}
+
+// bodyError maps a failure to read or decode a request body to a
+// requestError: 413 past the body size limit, otherwise 400 with message
+func bodyError(err error, message string) *requestError {
+	if limit, tooLarge := middleware.IsBodyTooLarge(err); tooLarge {
+		return &requestError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", limit)}
+	}
+	return &requestError{http.StatusBadRequest, message}
+}
+
+// writeJSON sends body as JSON with the given status
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// parseReviewRequest reads a review request from a JSON or
+// multipart/form-data body. If extra is non-nil the JSON body (or metadata
+// field) is also decoded into it, for endpoints that accept more fields.
+func (h *ReviewHandler) parseReviewRequest(r *http.Request, extra interface{}) (*models.ReviewRequest, *requestError) {
+	contentType := r.Header.Get("Content-Type")
+	log.Printf("Received review request - Content-Type: %s, Content-Length: %d", contentType, r.ContentLength)
+
+	var request models.ReviewRequest
+
+	// Handle both JSON and multipart/form-data
+	if strings.Contains(contentType, "application/json") {
+		// Handle JSON request (from GitHub Actions)
+		log.Printf("Processing as JSON request")
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Printf("Error reading request body: %v", err)
+			return nil, bodyError(err, "Failed to read request body")
+		}
+		defer r.Body.Close()
+
+		if err := json.Unmarshal(body, &request); err != nil {
+			log.Printf("Error parsing JSON: %v", err)
+			return nil, &requestError{http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err)}
+		}
+		if extra != nil {
+			if err := json.Unmarshal(body, extra); err != nil {
+				return nil, &requestError{http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err)}
+			}
+		}
+	} else {
+		// Handle multipart/form-data request (from local/curl)
+		log.Printf("Processing as multipart/form-data request")
+		// Parts beyond MultipartMemory, i.e. large diffs, are spooled to
+		// temporary files rather than held in memory
+		if err := r.ParseMultipartForm(middleware.MultipartMemory); err != nil {
+			log.Printf("Error parsing multipart form: %v", err)
+			return nil, bodyError(err, fmt.Sprintf("Failed to parse form: %v", err))
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		// Get metadata
+		metadataStr := r.FormValue("metadata")
+		if metadataStr == "" {
+			return nil, &requestError{http.StatusBadRequest, "Missing metadata field"}
+		}
+
+		// Parse metadata JSON
+		if err := json.Unmarshal([]byte(metadataStr), &request); err != nil {
+			log.Printf("Error parsing metadata JSON: %v", err)
+			return nil, &requestError{http.StatusBadRequest, fmt.Sprintf("Invalid metadata JSON: %v", err)}
+		}
+		if extra != nil {
+			if err := json.Unmarshal([]byte(metadataStr), extra); err != nil {
+				return nil, &requestError{http.StatusBadRequest, fmt.Sprintf("Invalid metadata JSON: %v", err)}
+			}
+		}
+
+		// Get git_diff file
+		file, header, err := r.FormFile("git_diff")
+		if err != nil {
+			log.Printf("Error reading git_diff file: %v", err)
+			return nil, &requestError{http.StatusBadRequest, fmt.Sprintf("Missing or invalid git_diff file: %v", err)}
+		}
+		defer file.Close()
+		if header.Size > h.config.MaxDiffSize {
+			return nil, diffTooLarge(header.Size, h.config.MaxDiffSize)
+		}
+
+		// Read diff content
+		diffBytes, err := io.ReadAll(file)
+		if err != nil {
+			log.Printf("Error reading diff content: %v", err)
+			return nil, &requestError{http.StatusInternalServerError, "Failed to read diff content"}
+		}
+		request.GitDiff = string(diffBytes)
+
+		// Optional linter reports, in any supported format
+		for _, header := range r.MultipartForm.File["linter_report"] {
+			report, err := readFormFile(header)
+			if err != nil {
+				return nil, &requestError{http.StatusBadRequest, fmt.Sprintf("Invalid linter_report file: %v", err)}
+			}
+			request.LinterReports = append(request.LinterReports, models.LinterReport{Content: report})
+		}
+
+		// Optional .aireview.yml content
+		if repoConfig := r.FormValue("repo_config"); repoConfig != "" {
+			parsed, err := repoconfig.Parse([]byte(repoConfig))
+			if err != nil {
+				return nil, &requestError{http.StatusBadRequest, err.Error()}
+			}
+			request.RepoConfig = parsed
+		}
+	}
+
+	if reqErr := h.checkReviewRequest(r.Context(), &request); reqErr != nil {
+		return nil, reqErr
+	}
+	return &request, nil
+}
+
+// checkReviewRequest fetches the diff of a decoded review request when it
+// names commits instead, and validates its fields
+func (h *ReviewHandler) checkReviewRequest(ctx context.Context, request *models.ReviewRequest) *requestError {
+	if request.GitDiff == "" && (request.BaseSHA != "" || request.HeadSHA != "") {
+		if reqErr := h.fetchDiff(ctx, request); reqErr != nil {
+			return reqErr
+		}
+	}
+	request.SCMToken = ""
+
+	// Validate request
+	if request.GitDiff == "" {
+		return &requestError{http.StatusBadRequest, "Empty git diff"}
+	}
+	if size := int64(len(request.GitDiff)); size > h.config.MaxDiffSize {
+		return diffTooLarge(size, h.config.MaxDiffSize)
+	}
+	switch strings.ToUpper(request.MinSeverity) {
+	case "", "INFO", "WARNING", "ERROR":
+	default:
+		return &requestError{http.StatusBadRequest, fmt.Sprintf("Invalid min_severity %q: must be INFO, WARNING or ERROR", request.MinSeverity)}
+	}
+	if request.MaxIssues < 0 {
+		return &requestError{http.StatusBadRequest, "max_issues must not be negative"}
+	}
+	if request.VerdictPolicy != nil {
+		if err := verdict.Validate(request.VerdictPolicy); err != nil {
+			return &requestError{http.StatusBadRequest, fmt.Sprintf("Invalid verdict_policy: %v", err)}
+		}
+	}
+	if reqErr := resolveCategories(request.Categories); reqErr != nil {
+		return reqErr
+	}
+	if request.ResponseLanguage != "" {
+		name, ok := prompt.ResponseLanguage(request.ResponseLanguage)
+		if !ok {
+			return &requestError{http.StatusBadRequest, fmt.Sprintf("Unsupported response_language %q: use one of %s", request.ResponseLanguage, strings.Join(prompt.ResponseLanguageTags(), ", "))}
+		}
+		request.ResponseLanguage = name
+	}
+	priority, err := jobs.ParseClass(request.Priority)
+	if err != nil {
+		return &requestError{http.StatusBadRequest, err.Error()}
+	}
+	request.Priority = priority
+	if request.TimeoutSeconds < 0 || request.TimeoutSeconds > h.config.MaxReviewTimeout {
+		return &requestError{http.StatusBadRequest, fmt.Sprintf("timeout_seconds must be between 1 and %d", h.config.MaxReviewTimeout)}
+	}
+	if len(request.Guidelines) > maxGuidelines {
+		return &requestError{http.StatusBadRequest, fmt.Sprintf("At most %d guidelines can be referenced", maxGuidelines)}
+	}
+	if len(request.Baseline) > maxBaseline {
+		return &requestError{http.StatusBadRequest, fmt.Sprintf("At most %d baseline fingerprints can be sent", maxBaseline)}
+	}
+	if len(request.CommitMessage) > maxCommitMessageLength {
+		return &requestError{http.StatusBadRequest, fmt.Sprintf("commit_message exceeds %d characters", maxCommitMessageLength)}
+	}
+	if request.RepoConfig != nil {
+		if err := repoconfig.Validate(request.RepoConfig); err != nil {
+			return &requestError{http.StatusBadRequest, err.Error()}
+		}
+	}
+	if len(request.LinterReports) > maxLinterReports {
+		return &requestError{http.StatusBadRequest, fmt.Sprintf("At most %d linter reports can be sent", maxLinterReports)}
+	}
+	for i, report := range request.LinterReports {
+		findings, err := linter.Parse(report)
+		if err != nil {
+			return &requestError{http.StatusBadRequest, fmt.Sprintf("linter_reports[%d]: %v", i, err)}
+		}
+		request.LinterFindings = append(request.LinterFindings, findings...)
+	}
+	// Reports can be large and are of no use once parsed
+	request.LinterReports = nil
+
+	return nil
+}
+
+// readFormFile reads an uploaded file of a multipart form
+func readFormFile(header *multipart.FileHeader) (string, error) {
+	file, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// fetchDiff fills in the diff between the request's base_sha and head_sha,
+// fetched from git_info.repo_url with scm_token. Without one, GITHUB_TOKEN
+// is only used for the repositories in GITHUB_TOKEN_REPOS, so callers can't
+// read every private repository the gateway's token reaches.
+func (h *ReviewHandler) fetchDiff(ctx context.Context, request *models.ReviewRequest) *requestError {
+	if len(h.config.RemoteDiffHosts) == 0 {
+		return &requestError{http.StatusBadRequest, "Fetching diffs from repositories is disabled; send git_diff"}
+	}
+	if request.BaseSHA == "" || request.HeadSHA == "" || request.GitInfo == nil || request.GitInfo.RepoURL == "" {
+		return &requestError{http.StatusBadRequest, "base_sha, head_sha and git_info.repo_url are all required to fetch the diff"}
+	}
+
+	token := request.SCMToken
+	if token == "" && strings.HasPrefix(scm.NormalizeRepository(request.GitInfo.RepoURL), "github.com/") &&
+		scm.MatchRepository(h.config.GitHubTokenRepos, request.GitInfo.RepoURL) {
+		token = h.config.GitHubToken.Get()
+	}
+	diff, err := scm.FetchDiff(ctx, scm.DiffRequest{
+		RepoURL:      request.GitInfo.RepoURL,
+		Base:         request.BaseSHA,
+		Head:         request.HeadSHA,
+		Token:        token,
+		AllowedHosts: h.config.RemoteDiffHosts,
+		MaxSize:      h.config.MaxDiffSize,
+	})
+	if errors.Is(err, scm.ErrDiffTooLarge) {
+		return &requestError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Git diff is over the limit of %d bytes", h.config.MaxDiffSize)}
+	}
+	if err != nil {
+		log.Printf("Failed to fetch diff of %s: %v", request.GitInfo.RepoURL, err)
+		return &requestError{http.StatusBadGateway, fmt.Sprintf("Failed to fetch the diff: %v", err)}
+	}
+
+	request.GitDiff = diff
+	if request.GitInfo.CommitHash == "" {
+		request.GitInfo.CommitHash = request.HeadSHA
+	}
+	log.Printf("Fetched diff of %s %s...%s (%d bytes)", request.GitInfo.RepoURL, request.BaseSHA, request.HeadSHA, len(diff))
+	return nil
+}
+
+// diffTooLarge reports a diff over MAX_DIFF_SIZE
+func diffTooLarge(size, limit int64) *requestError {
+	return &requestError{http.StatusRequestEntityTooLarge, fmt.Sprintf("Git diff is %d bytes; the limit is %d", size, limit)}
+}
+
+// reviewTimeout returns the time limit of a review: the request's
+// timeout_seconds, or REVIEW_TIMEOUT
+func (h *ReviewHandler) reviewTimeout(request models.ReviewRequest) time.Duration {
+	if request.TimeoutSeconds > 0 {
+		return time.Duration(request.TimeoutSeconds) * time.Second
+	}
+	return time.Duration(h.config.ReviewTimeout) * time.Second
+}
+
+// batchTimeout bounds a review sent through its provider's batch API,
+// which has a day to answer
+const batchTimeout = 25 * time.Hour
+
+// batched reports whether a review goes through its provider's batch API:
+// a queued review of the batch class, on a provider with one enabled
+func batched(ctx context.Context, provider providers.AIProvider, request models.ReviewRequest) bool {
+	return request.Priority == jobs.ClassBatch && jobs.Queued(ctx) && providers.SupportsBatch(provider)
+}
+
+// resolveCategories rewrites requested categories to taxonomy slugs, and
+// rejects unknown ones
+func resolveCategories(categories []string) *requestError {
+	taxonomy := prompt.CurrentTaxonomy()
+	for i, c := range categories {
+		slug, ok := taxonomy.Resolve(c)
+		if !ok {
+			return &requestError{http.StatusBadRequest, fmt.Sprintf("Unknown category %q: use one of %s", c, strings.Join(taxonomy.Slugs(), ", "))}
+		}
+		categories[i] = slug
+	}
+	return nil
+}
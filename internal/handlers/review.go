@@ -2,30 +2,95 @@ package handlers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/analytics"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/anonymize"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/commitmsg"
 	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/config"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/dedup"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/feedback"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/filecontext"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/guidelines"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/history"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/jobs"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/knowledge"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/language"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/license"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/linter"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
 	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/notify"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/output"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/policy"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/postprocess"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/preprocess"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/pricing"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
 	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/ratelimit"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/redact"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/repoconfig"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/routing"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scheduler"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scm"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/session"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/shadow"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/telemetry"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/tenants"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/verdict"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/webhook"
 )
 
 // ReviewHandler handles code review requests
 type ReviewHandler struct {
-	registry *providers.Registry
-	config   *config.Config
+	registry  *providers.Registry
+	config    *config.Config
+	analytics *analytics.Recorder
+	shadow    *shadow.Shadower
+	scheduler *scheduler.Scheduler
+	knowledge *knowledge.Store
+	library   *guidelines.Library
+	sessions  *session.Store
+	history   *history.Store
+	feedback  *feedback.Store
+	policy    *policy.Policy
+	webhooks  *webhook.Dispatcher
+	jobs      *jobs.Queue
+	dedup     dedup.Coordinator // nil reviews duplicates again
+	notifier  *notify.Dispatcher
 }
 
 // NewReviewHandler creates a new review handler
-func NewReviewHandler(registry *providers.Registry, cfg *config.Config) *ReviewHandler {
+func NewReviewHandler(registry *providers.Registry, cfg *config.Config, recorder *analytics.Recorder, shadower *shadow.Shadower, sched *scheduler.Scheduler, store *knowledge.Store, library *guidelines.Library, sessions *session.Store, reviews *history.Store, verdicts *feedback.Store, rules *policy.Policy, webhooks *webhook.Dispatcher, queue *jobs.Queue, coordinator dedup.Coordinator, notifier *notify.Dispatcher) *ReviewHandler {
 	return &ReviewHandler{
-		registry: registry,
-		config:   cfg,
+		registry:  registry,
+		config:    cfg,
+		analytics: recorder,
+		shadow:    shadower,
+		scheduler: sched,
+		knowledge: store,
+		library:   library,
+		sessions:  sessions,
+		history:   reviews,
+		feedback:  verdicts,
+		policy:    rules,
+		webhooks:  webhooks,
+		jobs:      queue,
+		dedup:     coordinator,
+		notifier:  notifier,
 	}
 }
 
@@ -36,108 +101,763 @@ func (h *ReviewHandler) HandleReview(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Log request details for debugging
-	contentType := r.Header.Get("Content-Type")
-	log.Printf("Received review request - Content-Type: %s, Content-Length: %d", contentType, r.ContentLength)
-
-	var request models.ReviewRequest
+	parsed, reqErr := h.parseReviewRequest(r, nil)
+	if reqErr != nil {
+		reqErr.write(w)
+		return
+	}
+	admitted, reqErr := h.admitReview(r, *parsed)
+	if reqErr != nil {
+		reqErr.write(w)
+		return
+	}
+	r, request := admitted.r, admitted.prepared.request
 
-	// Handle both JSON and multipart/form-data
-	if strings.Contains(contentType, "application/json") {
-		// Handle JSON request (from GitHub Actions)
-		log.Printf("Processing as JSON request")
-		body, err := io.ReadAll(r.Body)
-		if err != nil {
-			log.Printf("Error reading request body: %v", err)
-			http.Error(w, `{"error":"Failed to read request body"}`, http.StatusBadRequest)
+	if request.CallbackURL != "" {
+		id, reqErr := h.enqueue(admitted)
+		if reqErr != nil {
+			w.Header().Set("Retry-After", "30")
+			reqErr.write(w)
 			return
 		}
-		defer r.Body.Close()
+		writeJSON(w, http.StatusAccepted, map[string]string{"id": id, "status": "pending", "priority": request.Priority})
+		return
+	}
 
-		if err := json.Unmarshal(body, &request); err != nil {
-			log.Printf("Error parsing JSON: %v", err)
-			http.Error(w, fmt.Sprintf(`{"error":"Invalid JSON: %v"}`, err), http.StatusBadRequest)
-			return
+	response, reqErr := h.complete(r, admitted.registry, admitted.subject, admitted.prepared, session.NewID())
+	if reqErr != nil {
+		reqErr.write(w)
+		return
+	}
+
+	// Query parameter takes precedence over the metadata field, which
+	// takes precedence over OUTPUT_FORMAT
+	format := h.config.OutputFormat
+	if request.OutputFormat != "" {
+		format = request.OutputFormat
+	}
+	if f := r.URL.Query().Get("format"); f != "" {
+		format = f
+	}
+
+	// Send response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	var body interface{} = response
+	switch output.NormalizeFormat(format) {
+	case output.FormatCodeQuality:
+		// GitLab reads the report as-is
+		middleware.RawResponse(r.Context())
+		body = output.ToCodeQuality(response.Diagnostics)
+	case output.FormatSARIF:
+		// Code scanning uploads read the report as-is
+		middleware.RawResponse(r.Context())
+		body = output.ToSARIF(response.Source, response.Diagnostics)
+	}
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// admittedReview is a review request that passed every check, ready to be
+// sent to its provider
+type admittedReview struct {
+	r        *http.Request // Carries the request's scheduling priority
+	registry *providers.Registry
+	subject  policy.Subject
+	request  models.ReviewRequest // Before preparation, as queued in Redis
+	prepared *preparedReview
+}
+
+// admitReview applies defaults, aliases and routing to a parsed review
+// request, checks it against the caller's key, the provider policy and the
+// callback rules, and prepares it
+func (h *ReviewHandler) admitReview(r *http.Request, request models.ReviewRequest) (*admittedReview, *requestError) {
+	if request.Priority == jobs.ClassBatch {
+		// Bulk reviews wait for provider slots behind every interactive one
+		r = r.WithContext(middleware.WithPriority(r.Context(), ratelimit.PriorityLow))
+	}
+
+	// Apply defaults and aliases, and reject models the provider doesn't
+	// offer or parameters it doesn't accept
+	registry := tenants.RegistryFor(r.Context(), h.registry)
+	h.routeRequest(r, registry, &request)
+	var err error
+	request.AIProvider, request.AIModel, err = registry.Resolve(request.AIProvider, request.AIModel)
+	if err == nil {
+		err = registry.CheckParams(request.AIProvider, request.AIModel, request.ModelParams)
+	}
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, err.Error()}
+	}
+	if err := middleware.AuthorizeModel(r.Context(), request.AIProvider, request.AIModel); err != nil {
+		return nil, &requestError{http.StatusForbidden, err.Error()}
+	}
+	subject := policySubject(r.Context(), request.GitInfo, request.Language)
+	if err := h.policy.Check(subject, request.AIProvider, request.AIModel); err != nil {
+		return nil, policyViolation(err)
+	}
+	if request.CallbackURL != "" {
+		if err := h.webhooks.CheckURL(request.CallbackURL); err != nil {
+			return nil, &requestError{http.StatusBadRequest, err.Error()}
 		}
+	}
+
+	prepared, reqErr := h.prepareReview(r, request, true)
+	if reqErr != nil {
+		return nil, reqErr
+	}
+	return &admittedReview{r: r, registry: registry, subject: subject, request: request, prepared: prepared}, nil
+}
+
+// enqueue finishes an admitted review with a callback_url on the workers of
+// its priority class, which push the result to the caller. The review
+// keeps the request's values but not its cancellation.
+func (h *ReviewHandler) enqueue(admitted *admittedReview) (string, *requestError) {
+	request := admitted.prepared.request
+	id := session.NewID()
+	var err error
+	if h.jobs.Durable() {
+		err = h.enqueueDurable(admitted, id)
 	} else {
-		// Handle multipart/form-data request (from local/curl)
-		log.Printf("Processing as multipart/form-data request")
-		if err := r.ParseMultipartForm(h.config.MaxDiffSize); err != nil {
-			log.Printf("Error parsing multipart form: %v", err)
-			http.Error(w, fmt.Sprintf(`{"error":"Failed to parse form: %v"}`, err), http.StatusBadRequest)
-			return
+		err = h.jobs.Submit(request.Priority, func(ctx context.Context) {
+			background := admitted.r.WithContext(jobs.WithJob(context.WithoutCancel(admitted.r.Context()), ctx))
+			response, reqErr := h.complete(background, admitted.registry, admitted.subject, admitted.prepared, id)
+			event, payload := reviewEvent(id, response, reqErr)
+			h.webhooks.Send(request.CallbackURL, event, payload)
+		})
+	}
+	if errors.Is(err, jobs.ErrQueueFull) {
+		return "", &requestError{http.StatusServiceUnavailable, fmt.Sprintf("Too many %s reviews queued; retry later", request.Priority)}
+	}
+	if err != nil {
+		log.Printf("Error queueing review %s: %v", id, err)
+		return "", &requestError{http.StatusServiceUnavailable, "Failed to queue the review; retry later"}
+	}
+	log.Printf("Review %s accepted (%s); the result will be sent to its callback URL", id, request.Priority)
+	return id, nil
+}
+
+// complete sends a prepared review to its provider and records the result
+// under id for follow-up questions, history and analytics. When the caller
+// asked for the same review of the same pull request head moments ago, on
+// this replica or another, it returns that review instead.
+func (h *ReviewHandler) complete(r *http.Request, registry *providers.Registry, subject policy.Subject, prepared *preparedReview, id string) (models.ReviewResponse, *requestError) {
+	key := dedupKey(r, prepared.request)
+	if h.dedup == nil || key == "" {
+		return h.run(r, registry, subject, prepared, id)
+	}
+
+	var response models.ReviewResponse
+	var reqErr *requestError
+	lockTTL := h.reviewTimeout(prepared.request) + time.Minute
+	if provider, err := registry.Get(prepared.request.AIProvider); err == nil && batched(r.Context(), provider, prepared.request) {
+		lockTTL = batchTimeout + time.Minute
+	}
+	result, shared, err := h.dedup.Do(r.Context(), key, lockTTL, func() ([]byte, error) {
+		response, reqErr = h.run(r, registry, subject, prepared, id)
+		if reqErr != nil {
+			return nil, reqErr
+		}
+		return json.Marshal(response)
+	})
+	if reqErr != nil {
+		return models.ReviewResponse{}, reqErr
+	}
+	if err != nil {
+		return models.ReviewResponse{}, &requestError{http.StatusGatewayTimeout, fmt.Sprintf("Gave up waiting for a review of the same changes: %v", err)}
+	}
+	if shared {
+		response = models.ReviewResponse{}
+		if err := json.Unmarshal(result, &response); err != nil {
+			return models.ReviewResponse{}, &requestError{http.StatusInternalServerError, fmt.Sprintf("Failed to read a shared review: %v", err)}
 		}
+		response.Deduplicated = true
+		log.Printf("Review %s shares review %s of the same changes", id, response.ID)
+	}
+	return response, nil
+}
 
-		// Get metadata
-		metadataStr := r.FormValue("metadata")
-		if metadataStr == "" {
-			http.Error(w, `{"error":"Missing metadata field"}`, http.StatusBadRequest)
-			return
+// dedupKey identifies a review of a pull request head, or of a commit, for
+// one caller and set of options. It is empty for reviews of a bare diff.
+func dedupKey(r *http.Request, request models.ReviewRequest) string {
+	gitInfo := request.GitInfo
+	if gitInfo == nil || gitInfo.RepoURL == "" {
+		return ""
+	}
+	head := request.HeadSHA
+	if head == "" {
+		head = gitInfo.CommitHash
+	}
+	if head == "" {
+		return ""
+	}
+
+	// Options that don't change the review itself
+	request.CallbackURL, request.TimeoutSeconds, request.Priority = "", 0, ""
+	options, err := json.Marshal(request)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(options)
+	return fmt.Sprintf("%s|%s#%s@%s|%s", middleware.ClientID(r.Context()), scm.NormalizeRepository(gitInfo.RepoURL), gitInfo.PRNumber, head, hex.EncodeToString(sum[:8]))
+}
+
+// run sends a prepared review to its provider and records the result
+func (h *ReviewHandler) run(r *http.Request, registry *providers.Registry, subject policy.Subject, prepared *preparedReview, id string) (models.ReviewResponse, *requestError) {
+	request := prepared.request
+
+	// Get provider
+	provider, err := registry.Get(request.AIProvider)
+	if err != nil {
+		log.Printf("Provider error: %v", err)
+		return models.ReviewResponse{}, &requestError{http.StatusBadRequest, fmt.Sprintf("Provider not available: %v", err)}
+	}
+
+	// Call AI provider with timeout. Queued batch reviews may wait for the
+	// provider's batch API instead, for up to a day.
+	ctx, timeout := r.Context(), h.reviewTimeout(request)
+	if batched(ctx, provider, request) {
+		ctx, timeout = providers.WithBatch(ctx), batchTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	aiResponse, latency, reqErr := h.review(ctx, r, provider, prepared, request)
+	if reqErr != nil {
+		return models.ReviewResponse{}, reqErr
+	}
+	if registry == h.registry && !h.policy.Applies(subject) {
+		// Tenants with their own providers and requests under a provider
+		// policy aren't mirrored to the gateway's
+		h.shadow.Mirror(request, aiResponse, latency)
+	}
+
+	response := h.buildResponse(prepared, aiResponse)
+
+	response.ID = id
+	h.linkDiagnostics(response.Diagnostics, id)
+	tenant := middleware.ClientID(r.Context())
+
+	// Keep the review so developers can ask follow-up questions about it
+	h.sessions.Save(&session.Session{
+		ID:          response.ID,
+		Tenant:      tenant,
+		Provider:    request.AIProvider,
+		Model:       request.AIModel,
+		Language:    request.Language,
+		Anonymized:  request.Anonymize || h.config.ShouldAnonymize(request.AIProvider),
+		Diff:        request.GitDiff,
+		Files:       request.FileContents,
+		Overview:    response.Overview,
+		Diagnostics: response.Diagnostics,
+	})
+
+	record := newReviewRecord(request, response, aiResponse.Usage, latency)
+	if h.config.HistoryPrompts {
+		anonymized := request.Anonymize || h.config.ShouldAnonymize(request.AIProvider)
+		for _, call := range h.providerCalls(prepared) {
+			record.Prompts = append(record.Prompts, promptPreview(call, anonymized))
 		}
+	}
+	if err := h.history.Add(tenant, record); err != nil {
+		log.Printf("Warning: failed to record review history: %v", err)
+	}
 
-		// Parse metadata JSON
-		if err := json.Unmarshal([]byte(metadataStr), &request); err != nil {
-			log.Printf("Error parsing metadata JSON: %v", err)
-			http.Error(w, fmt.Sprintf(`{"error":"Invalid metadata JSON: %v"}`, err), http.StatusBadRequest)
-			return
+	h.analytics.Record(tenant, response.Diagnostics)
+	h.notifier.Notify(notify.NewSummary(id, subject.Tenant, request, response))
+
+	log.Printf("Review completed: %d diagnostics found", len(response.Diagnostics))
+	return response, nil
+}
+
+// linkDiagnostics points diagnostics without a documentation link at their
+// review on the dashboard, when it is configured and keeps reviews
+func (h *ReviewHandler) linkDiagnostics(diagnostics []models.Diagnostic, id string) {
+	if h.config.DashboardURL == "" || h.history == nil {
+		return
+	}
+	base := strings.TrimRight(h.config.DashboardURL, "/") + "/#/reviews/" + url.PathEscape(id)
+	for i := range diagnostics {
+		if diagnostics[i].Code.URL == "" {
+			diagnostics[i].Code.URL = fmt.Sprintf("%s/diagnostics/%d", base, i)
 		}
+	}
+}
 
-		// Get git_diff file
-		file, _, err := r.FormFile("git_diff")
+// preparedReview is a review request after pre-processing, ready to be
+// sent to one or more providers
+type preparedReview struct {
+	request        models.ReviewRequest
+	skippedFiles   []string
+	redactions     []models.Redaction
+	commitProblems []string            // Conventional Commits rules the commit message breaks
+	licenseIssues  []models.Diagnostic // New files missing the repository's license header
+	languages      []string            // Languages detected in the diff, when the request named none
+	groups         []language.Group    // Language groups reviewed separately; empty for a single call
+}
+
+// prepareReview applies repository configuration and strips ignored files,
+// secrets and prompt-injection attempts from the diff. Without retrieve,
+// file contents and related code are not fetched from the repository.
+func (h *ReviewHandler) prepareReview(r *http.Request, request models.ReviewRequest, retrieve bool) (*preparedReview, *requestError) {
+	if request.Language == "" {
+		request.Language = "unknown"
+	}
+	request.ReviewMode = prompt.NormalizeMode(request.ReviewMode)
+	if request.PromptVersion != "" && !prompt.HasVersion(request.PromptVersion) {
+		return nil, &requestError{http.StatusBadRequest, fmt.Sprintf("Unknown prompt version %q", request.PromptVersion)}
+	}
+	if request.PromptVersion == "" {
+		request.PromptVersion = prompt.AssignVersion(experimentKey(r, request.GitInfo))
+	}
+
+	log.Printf("Review request: provider=%s, model=%s, language=%s, mode=%s, diff_size=%d bytes",
+		request.AIProvider, request.AIModel, request.Language, request.ReviewMode, len(request.GitDiff))
+
+	// Apply per-repository configuration
+	if request.RepoConfig == nil && h.config.RepoConfigFetch && request.GitInfo != nil && request.GitInfo.RepoURL != "" {
+		repoConfig, err := repoconfig.Fetch(r.Context(), request.GitInfo.RepoURL, request.GitInfo.CommitHash, h.config.GitHubToken.Get())
 		if err != nil {
-			log.Printf("Error reading git_diff file: %v", err)
-			http.Error(w, fmt.Sprintf(`{"error":"Missing or invalid git_diff file: %v"}`, err), http.StatusBadRequest)
-			return
+			log.Printf("Warning: failed to fetch %s: %v", repoconfig.FileName, err)
+		}
+		request.RepoConfig = repoConfig
+	}
+
+	// Gateway output defaults apply when neither the request nor the
+	// repository sets a threshold
+	if request.MinSeverity == "" && (request.RepoConfig == nil || request.RepoConfig.MinSeverity == "") {
+		request.MinSeverity = h.config.DefaultMinSeverity
+	}
+	if request.MaxIssues == 0 && (request.RepoConfig == nil || request.RepoConfig.MaxIssues == 0) {
+		request.MaxIssues = h.config.DefaultMaxIssues
+	}
+
+	// Resolve named team guidelines; unknown names in a fetched repository
+	// config shouldn't break reviews, unknown names in the request should
+	styleGuides, err := h.library.Resolve(request.Guidelines)
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, err.Error()}
+	}
+	if request.RepoConfig != nil {
+		for _, name := range request.RepoConfig.StyleGuides {
+			if slices.Contains(request.Guidelines, name) {
+				continue
+			}
+			guide, ok := h.library.Get(name)
+			if !ok {
+				log.Printf("Warning: %s references unknown guideline %q", repoconfig.FileName, name)
+				continue
+			}
+			styleGuides = append(styleGuides, guide)
 		}
-		defer file.Close()
+	}
+	request.StyleGuides = styleGuides
 
-		// Read diff content
-		diffBytes, err := io.ReadAll(file)
+	// Strip ignored, vendored, generated and binary files before they reach
+	// the model
+	fileFilter := preprocess.FileFilter{
+		IgnorePatterns: h.config.IgnorePaths,
+		SkipGenerated:  h.config.SkipGenerated,
+	}
+	if request.RepoConfig != nil {
+		fileFilter.IgnorePatterns = append(append([]string{}, fileFilter.IgnorePatterns...), request.RepoConfig.IgnorePaths...)
+	}
+	files, skippedFiles := fileFilter.Apply(diff.Parse(request.GitDiff))
+	if len(skippedFiles) > 0 {
+		log.Printf("Skipped %d ignored, generated or binary files", len(skippedFiles))
+	}
+
+	// Cut down single files too large to review in full
+	sizeLimit := preprocess.SizeLimit{MaxBytes: h.config.MaxFileDiffSize, Strategy: h.config.LargeFileStrategy}
+	files, oversized, truncated := sizeLimit.Apply(files)
+	if len(oversized) > 0 || len(truncated) > 0 {
+		log.Printf("Large files: %d skipped, %d truncated (%s)", len(oversized), len(truncated), sizeLimit.Strategy)
+	}
+	skippedFiles = append(skippedFiles, oversized...)
+	request.TruncatedFiles = truncated
+
+	if len(skippedFiles) > 0 || len(truncated) > 0 {
+		request.GitDiff = diff.Join(files)
+		if strings.TrimSpace(request.GitDiff) == "" {
+			return nil, &requestError{http.StatusBadRequest, "All changed files are ignored, generated, binary or too large"}
+		}
+	}
+
+	// Name the languages of the change rather than prompting for "unknown"
+	// code when the client named none
+	var detectedLanguages []string
+	if language.IsUnknown(request.Language) {
+		detectedLanguages = language.Detect(files)
+		request.Language = language.Describe(detectedLanguages)
+		if len(detectedLanguages) > 0 {
+			log.Printf("Detected languages: %s", request.Language)
+		}
+	}
+
+	// Give the model the full changed files, not just the hunks
+	request.FileContents = filecontext.Collect(r.Context(), files, request.FileContents, request.GitInfo, filecontext.Options{
+		Fetch:    h.config.FetchFileContext && retrieve,
+		Token:    h.config.GitHubToken.Get(),
+		MaxBytes: h.config.FileContextMaxSize,
+	})
+
+	// Retrieve related code and guidelines from the repository index
+	if retrieve && request.GitInfo != nil && request.GitInfo.RepoURL != "" {
+		exclude := make(map[string]bool, len(files))
+		for _, f := range files {
+			exclude[f.Path()] = true
+		}
+		related, err := h.knowledge.Search(r.Context(), middleware.ClientID(r.Context()), request.GitInfo.RepoURL,
+			retrievalQuery(files), h.config.RAGTopK, h.config.RAGMaxSize, exclude)
 		if err != nil {
-			log.Printf("Error reading diff content: %v", err)
-			http.Error(w, `{"error":"Failed to read diff content"}`, http.StatusInternalServerError)
-			return
+			log.Printf("Warning: knowledge retrieval failed: %v", err)
 		}
-		request.GitDiff = string(diffBytes)
+		request.RelatedContext = related
 	}
 
-	// Validate request
-	if request.GitDiff == "" {
-		http.Error(w, `{"error":"Empty git diff"}`, http.StatusBadRequest)
-		return
+	// Steer the model away from findings developers keep rejecting
+	repoURL := ""
+	if request.GitInfo != nil {
+		repoURL = request.GitInfo.RepoURL
 	}
+	request.RejectedFindings = h.feedback.Examples(middleware.ClientID(r.Context()), repoURL, h.config.FeedbackExamples)
 
-	// Set defaults
-	if request.AIProvider == "" {
-		request.AIProvider = h.config.DefaultProvider
+	// Check the commit message and license headers before they are
+	// redacted or defanged
+	var commitProblems []string
+	if request.CommitMessage != "" {
+		commitProblems = commitmsg.Check(request.CommitMessage)
 	}
-	if request.AIModel == "" {
-		request.AIModel = h.config.DefaultModel
+	var licenseIssues []models.Diagnostic
+	if request.RepoConfig != nil {
+		licenseIssues = license.Check(files, request.RepoConfig.LicenseHeader)
 	}
-	if request.Language == "" {
-		request.Language = "unknown"
+
+	// Linters report on the whole tree; keep their findings on changed lines
+	if len(request.LinterFindings) > 0 {
+		linter.ResolvePaths(request.LinterFindings, files)
+		var lineStats postprocess.LineStats
+		request.LinterFindings, lineStats = postprocess.ValidateLines(request.LinterFindings, files, postprocess.LinePolicyFilter)
+		log.Printf("Linter reports: %d findings on changed lines, %d elsewhere", len(request.LinterFindings), lineStats.Dropped)
 	}
 
-	log.Printf("Review request: provider=%s, model=%s, language=%s, diff_size=%d bytes",
-		request.AIProvider, request.AIModel, request.Language, len(request.GitDiff))
+	// Never send credentials to the provider
+	var redactions []models.Redaction
+	if h.config.RedactSecrets {
+		request.GitDiff, redactions = redact.Diff(request.GitDiff)
+		var messageRedactions []models.Redaction
+		request.CommitMessage, messageRedactions = redact.Diff(request.CommitMessage)
+		redactions = append(redactions, messageRedactions...)
+		for i, f := range request.FileContents {
+			var fileRedactions []models.Redaction
+			request.FileContents[i].Content, fileRedactions = redact.Diff(f.Content)
+			for _, rd := range fileRedactions {
+				rd.Path = f.Path
+				redactions = append(redactions, rd)
+			}
+		}
+		if len(redactions) > 0 {
+			log.Printf("Redacted %d secrets from diff", len(redactions))
+		}
+	}
 
-	// Get provider
-	provider, err := h.registry.Get(request.AIProvider)
-	if err != nil {
-		log.Printf("Provider error: %v", err)
-		http.Error(w, fmt.Sprintf(`{"error":"Provider not available: %v"}`, err), http.StatusBadRequest)
-		return
+	// Defang and flag text aimed at the model rather than at reviewers
+	request.GitDiff, request.InjectionFindings = preprocess.NeutralizeInjection(request.GitDiff)
+	var messageFindings []models.InjectionFinding
+	request.CommitMessage, messageFindings = preprocess.NeutralizeInjection(request.CommitMessage)
+	request.InjectionFindings = append(request.InjectionFindings, messageFindings...)
+	for i, f := range request.FileContents {
+		var fileFindings []models.InjectionFinding
+		request.FileContents[i].Content, fileFindings = preprocess.NeutralizeInjection(f.Content)
+		for _, finding := range fileFindings {
+			finding.Path = f.Path
+			request.InjectionFindings = append(request.InjectionFindings, finding)
+		}
+	}
+	if len(request.InjectionFindings) > 0 {
+		log.Printf("Warning: %d suspected prompt-injection attempts in diff", len(request.InjectionFindings))
 	}
 
-	// Call AI provider with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), 120*time.Second)
-	defer cancel()
+	// Review each language of a mixed change with its own prompt rather than
+	// one claiming expertise in all of them
+	groups := language.Groups(diff.Parse(request.GitDiff), h.config.MaxLanguageGroups)
+	if len(groups) > 0 {
+		log.Printf("Splitting review into %d language groups", len(groups))
+	}
+
+	return &preparedReview{
+		request:        request,
+		skippedFiles:   skippedFiles,
+		redactions:     redactions,
+		commitProblems: commitProblems,
+		licenseIssues:  licenseIssues,
+		languages:      detectedLanguages,
+		groups:         groups,
+	}, nil
+}
 
-	aiResponse, err := provider.Review(ctx, &request)
+// review sends a prepared request to a provider, one call per language
+// group when the change mixes languages. The groups are reviewed
+// concurrently and their results merged; the review fails when any group
+// does. request may differ from prepared.request in provider and model.
+func (h *ReviewHandler) review(ctx context.Context, r *http.Request, provider providers.AIProvider, prepared *preparedReview, request models.ReviewRequest) (*models.AIProviderResponse, time.Duration, *requestError) {
+	if len(prepared.groups) == 0 {
+		return h.callProvider(ctx, r, provider, request)
+	}
+
+	type result struct {
+		response *models.AIProviderResponse
+		latency  time.Duration
+		err      *requestError
+	}
+	files := diff.Parse(request.GitDiff)
+	results := make([]result, len(prepared.groups))
+	var wg sync.WaitGroup
+	for i, group := range prepared.groups {
+		wg.Add(1)
+		go func(i int, groupRequest models.ReviewRequest) {
+			defer wg.Done()
+			results[i].response, results[i].latency, results[i].err = h.callProvider(ctx, r, provider, groupRequest)
+		}(i, languageGroupRequest(request, files, group, i == 0))
+	}
+	wg.Wait()
+
+	merged := &models.AIProviderResponse{ParserPath: prompt.ParserStructured}
+	var overviews []string
+	var latency time.Duration
+	for i, res := range results {
+		if res.err != nil {
+			return nil, 0, res.err
+		}
+		group := prepared.groups[i]
+		if overview := strings.TrimSpace(res.response.Overview); overview != "" {
+			overviews = append(overviews, fmt.Sprintf("**%s:** %s", language.Describe(group.Languages), overview))
+		}
+		merged.Diagnostics = append(merged.Diagnostics, res.response.Diagnostics...)
+		if merged.CommitMessage == "" {
+			merged.CommitMessage = res.response.CommitMessage
+		}
+		switch res.response.ParserPath {
+		case prompt.ParserPartial:
+			merged.ParserPath = prompt.ParserPartial
+		case prompt.ParserUnstructured:
+			if merged.ParserPath != prompt.ParserPartial {
+				merged.ParserPath = prompt.ParserUnstructured
+			}
+		}
+		merged.Usage.PromptBytes += res.response.Usage.PromptBytes
+		merged.Usage.ResponseBytes += res.response.Usage.ResponseBytes
+		merged.Usage.PromptTokens += res.response.Usage.PromptTokens
+		merged.Usage.CompletionTokens += res.response.Usage.CompletionTokens
+		merged.Usage.Truncated = merged.Usage.Truncated || res.response.Usage.Truncated
+		merged.Usage.Batched = merged.Usage.Batched || res.response.Usage.Batched
+		merged.Usage.CachedTokens += res.response.Usage.CachedTokens
+		merged.Schema = merged.Schema.Add(res.response.Schema)
+		latency = max(latency, res.latency)
+	}
+	merged.Overview = strings.Join(overviews, "\n\n")
+	return merged, latency, nil
+}
+
+// languageGroupRequest narrows a request to the files of one language
+// group. The commit message is reviewed with the first group only.
+func languageGroupRequest(request models.ReviewRequest, files []*diff.File, group language.Group, first bool) models.ReviewRequest {
+	paths := make(map[string]bool, len(group.Paths))
+	for _, p := range group.Paths {
+		paths[p] = true
+	}
+
+	var groupFiles []*diff.File
+	for _, f := range files {
+		if paths[f.Path()] {
+			groupFiles = append(groupFiles, f)
+		}
+	}
+	request.GitDiff = diff.Join(groupFiles)
+	request.Language = language.Describe(group.Languages)
+
+	var contents []models.FileContent
+	for _, c := range request.FileContents {
+		if paths[diff.NormalizePath(c.Path)] {
+			contents = append(contents, c)
+		}
+	}
+	request.FileContents = contents
+
+	var findings []models.InjectionFinding
+	for _, f := range request.InjectionFindings {
+		if paths[diff.NormalizePath(f.Path)] || (f.Path == "" && first) {
+			findings = append(findings, f)
+		}
+	}
+	request.InjectionFindings = findings
+
+	var truncated []models.TruncatedFile
+	for _, t := range request.TruncatedFiles {
+		if paths[t.Path] {
+			truncated = append(truncated, t)
+		}
+	}
+	request.TruncatedFiles = truncated
+
+	if !first {
+		request.CommitMessage = ""
+	}
+	return request
+}
+
+// providerRequest returns a request as its provider sees it: anonymized for
+// untrusted or evaluation providers, with the mapping that restores names
+func (h *ReviewHandler) providerRequest(request models.ReviewRequest) (models.ReviewRequest, *anonymize.Mapping) {
+	if !request.Anonymize && !h.config.ShouldAnonymize(request.AIProvider) {
+		return request, nil
+	}
+
+	// Untrusted or evaluation providers only see an anonymized diff
+	var mapping *anonymize.Mapping
+	request.GitDiff, mapping = anonymize.Diff(request.GitDiff)
+	request.GitInfo = nil
+	request.FileContents = nil
+	request.TruncatedFiles = nil
+	request.RelatedContext = nil
+	request.RejectedFindings = nil
+	request.CommitMessage = ""
+	// Re-scan so warning excerpts don't leak original names
+	_, request.InjectionFindings = preprocess.NeutralizeInjection(request.GitDiff)
+	return request, mapping
+}
+
+// callProvider sends a prepared request to a provider, anonymizing it when
+// required, and returns the response with names restored
+func (h *ReviewHandler) callProvider(ctx context.Context, r *http.Request, provider providers.AIProvider, request models.ReviewRequest) (*models.AIProviderResponse, time.Duration, *requestError) {
+	providerRequest, mapping := h.providerRequest(request)
+	if mapping != nil {
+		log.Printf("Diff anonymized for provider %s", request.AIProvider)
+	}
+
+	// Wait for a provider slot per concurrent call, so an ensemble holds
+	// one for each member; interactive tiers are admitted first. Batched
+	// calls wait on the provider's batch API without one.
+	if !providers.BatchRequested(ctx) {
+		release, err := h.scheduler.AcquireN(ctx, middleware.Priority(r.Context()), providers.ReviewCalls(provider))
+		if err != nil {
+			return nil, 0, &requestError{http.StatusServiceUnavailable, "Timed out waiting for a free review slot"}
+		}
+		defer release()
+	}
+
+	start := time.Now()
+	aiResponse, err := provider.Review(ctx, &providerRequest)
+	tenants.RegistryFor(r.Context(), h.registry).ReportResult(request.AIProvider, err)
 	if err != nil {
 		log.Printf("AI review error: %v", err)
-		http.Error(w, fmt.Sprintf(`{"error":"AI review failed: %v"}`, err), http.StatusInternalServerError)
-		return
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, 0, &requestError{http.StatusGatewayTimeout, fmt.Sprintf("AI review timed out: %v", err)}
+		}
+		return nil, 0, &requestError{http.StatusInternalServerError, fmt.Sprintf("AI review failed: %v", err)}
+	}
+	latency := time.Since(start)
+	if !aiResponse.Usage.Batched {
+		tenants.RegistryFor(r.Context(), h.registry).ReportLatency(request.AIProvider, request.AIModel, latency)
+	}
+	cost, _ := reviewCost(request.AIModel, aiResponse.Usage)
+	middleware.RecordUsage(r.Context(), aiResponse.Usage.PromptTokens+aiResponse.Usage.CompletionTokens, cost)
+
+	telemetry.Emit(telemetry.Event{
+		Kind:        "review",
+		ClientID:    middleware.ClientID(r.Context()),
+		Provider:    request.AIProvider,
+		Model:       request.AIModel,
+		Mode:        request.ReviewMode,
+		DiffBytes:   len(providerRequest.GitDiff),
+		Usage:       aiResponse.Usage,
+		ParserPath:  aiResponse.ParserPath,
+		Diagnostics: len(aiResponse.Diagnostics),
+		Latency:     latency,
+
+		PromptVersion: request.PromptVersion,
+	})
+	switch aiResponse.ParserPath {
+	case prompt.ParserUnstructured:
+		log.Printf("Warning: %s returned unstructured output; used fallback parser", request.AIProvider)
+	case prompt.ParserPartial:
+		log.Printf("Warning: %s response was cut off; returning the %d issues it finished", request.AIProvider, len(aiResponse.Diagnostics))
+	}
+	if aiResponse.Usage.Truncated && aiResponse.ParserPath != prompt.ParserPartial {
+		log.Printf("Warning: %s response truncated at the max token limit", request.AIProvider)
+	}
+	if s := aiResponse.Schema; s.Rejected > 0 || s.Repaired > 0 {
+		log.Printf("Warning: %s output broke the response schema: %d issues rejected, %d repaired", request.AIProvider, s.Rejected, s.Repaired)
+	}
+
+	if mapping != nil {
+		aiResponse.Diagnostics = mapping.Restore(aiResponse.Diagnostics)
+		aiResponse.Overview = mapping.RestoreText(aiResponse.Overview)
+	}
+	return aiResponse, latency, nil
+}
+
+// buildResponse validates and filters the provider's diagnostics and
+// assembles the review response
+func (h *ReviewHandler) buildResponse(prepared *preparedReview, aiResponse *models.AIProviderResponse) models.ReviewResponse {
+	request := prepared.request
+
+	// Drop or fix diagnostics that don't point at changed lines
+	files := diff.Parse(request.GitDiff)
+	diagnostics := aiResponse.Diagnostics
+	if len(request.LinterFindings) > 0 {
+		diagnostics = postprocess.MergeLinters(diagnostics, request.LinterFindings)
+	}
+	diagnostics = append(append([]models.Diagnostic{}, prepared.licenseIssues...), diagnostics...)
+	diagnostics, lineStats := postprocess.ValidateLines(diagnostics, files, h.config.LineValidation)
+	if lineStats.Adjusted > 0 || lineStats.Dropped > 0 {
+		log.Printf("Line validation: %d diagnostics adjusted, %d dropped", lineStats.Adjusted, lineStats.Dropped)
+	}
+
+	suppressed := models.SuppressionSummary{OutsideDiff: lineStats.Dropped}
+
+	// Merge findings reported more than once, e.g. by overlapping calls
+	diagnostics, suppressed.Duplicates = postprocess.Deduplicate(diagnostics)
+
+	// Don't re-report findings the team has already acknowledged
+	diagnostics, suppressed.Baseline = postprocess.FilterBaseline(diagnostics, request.Baseline)
+
+	// Apply repository filters (ignored paths, categories, severity, limit)
+	if request.RepoConfig != nil {
+		diagnostics, suppressed.RepoConfig = postprocess.ApplyRepoConfig(diagnostics, request.RepoConfig)
+	}
+
+	// Apply caller filters
+	diagnostics, suppressed.Categories = postprocess.FilterRequestedCategories(diagnostics, request.Categories, prompt.CurrentTaxonomy().Slugs())
+	if request.MinSeverity != "" {
+		diagnostics, suppressed.Severity = postprocess.FilterSeverity(diagnostics, request.MinSeverity)
+	}
+	diagnostics, suppressed.MaxIssues = postprocess.Limit(diagnostics, request.MaxIssues)
+
+	// Enforce mode limits in case the model ignored its instructions
+	var modeSuppressed int
+	switch request.ReviewMode {
+	case prompt.ModeSummary:
+		modeSuppressed = len(diagnostics)
+		diagnostics = []models.Diagnostic{}
+	case prompt.ModeQuick:
+		diagnostics, modeSuppressed = postprocess.Limit(diagnostics, prompt.QuickModeMaxIssues)
+	}
+	suppressed.MaxIssues += modeSuppressed
+
+	suppressed.Total = suppressed.OutsideDiff + suppressed.Duplicates + suppressed.Baseline + suppressed.RepoConfig + suppressed.Categories + suppressed.Severity + suppressed.MaxIssues
+	if suppressed.Total > 0 {
+		log.Printf("Suppressed %d diagnostics (outside diff %d, duplicates %d, baseline %d, repo config %d, categories %d, severity %d, max issues %d)",
+			suppressed.Total, suppressed.OutsideDiff, suppressed.Duplicates, suppressed.Baseline, suppressed.RepoConfig, suppressed.Categories, suppressed.Severity, suppressed.MaxIssues)
+	}
+	postprocess.AddFingerprints(diagnostics)
+
+	var patches []models.Patch
+	if request.GeneratePatches || request.SuggestionBlocks {
+		patches = postprocess.BuildPatches(diagnostics, files, request.FileContents, request.SuggestionBlocks)
 	}
 
 	// Build response in reviewdog diagnostic format
@@ -146,18 +866,179 @@ func (h *ReviewHandler) HandleReview(w http.ResponseWriter, r *http.Request) {
 			Name: "ai-review",
 			URL:  "",
 		},
-		Diagnostics: aiResponse.Diagnostics,
-		Overview:    aiResponse.Overview,
+		Diagnostics:    diagnostics,
+		Overview:       aiResponse.Overview,
+		SkippedFiles:   prepared.skippedFiles,
+		TruncatedFiles: request.TruncatedFiles,
+		Suppressed:     &suppressed,
+		Redactions:     prepared.redactions,
+
+		SuspiciousContent: len(request.InjectionFindings) > 0,
+		InjectionFindings: request.InjectionFindings,
+		RelatedContext:    request.RelatedContext,
+		CommitMessage:     commitMessageReview(prepared, aiResponse.CommitMessage),
+		Patches:           patches,
+		DetectedLanguages: prepared.languages,
+		Partial:           aiResponse.ParserPath == prompt.ParserPartial,
+		Verdict:           verdict.Evaluate(diagnostics, h.verdictPolicy(request)),
+		SchemaValidation:  schemaValidation(aiResponse.Schema),
+		PromptVersion:     request.PromptVersion,
+	}
+	if request.Route != "" {
+		response.AIProvider, response.AIModel, response.Route = request.AIProvider, request.AIModel, request.Route
 	}
+	return response
+}
 
-	// Send response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+// schemaValidation reports the issues of the model's output that broke the
+// response schema, or nil when all matched
+func schemaValidation(report models.SchemaReport) *models.SchemaReport {
+	if report == (models.SchemaReport{}) {
+		return nil
+	}
+	return &report
+}
 
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding response: %v", err)
+// verdictPolicy returns the thresholds of a review's verdict: the request's,
+// the repository's or the gateway's
+func (h *ReviewHandler) verdictPolicy(request models.ReviewRequest) *models.VerdictPolicy {
+	if request.VerdictPolicy != nil {
+		return request.VerdictPolicy
+	}
+	if request.RepoConfig != nil && request.RepoConfig.Verdict != nil {
+		return request.RepoConfig.Verdict
+	}
+	policy, err := verdict.Parse(h.config.VerdictPolicy)
+	if err != nil {
+		log.Printf("Warning: invalid VERDICT_POLICY: %v", err)
 	}
+	return policy
+}
 
-	log.Printf("Review completed: %d diagnostics found", len(response.Diagnostics))
+// commitMessageReview combines the rule check of the request's commit
+// message with the model's improved message
+func commitMessageReview(prepared *preparedReview, suggested string) *models.CommitMessageReview {
+	if prepared.request.CommitMessage == "" {
+		return nil
+	}
+	review := &models.CommitMessageReview{
+		Conventional: len(prepared.commitProblems) == 0,
+		Problems:     prepared.commitProblems,
+	}
+	if suggested != "" && suggested != strings.TrimSpace(prepared.request.CommitMessage) {
+		review.Suggested = suggested
+	}
+	return review
+}
+
+// newReviewRecord summarizes a completed review for the review history
+func newReviewRecord(request models.ReviewRequest, response models.ReviewResponse, usage models.Usage, latency time.Duration) models.ReviewRecord {
+	record := models.ReviewRecord{
+		ID:               response.ID,
+		CreatedAt:        time.Now().UTC(),
+		AIProvider:       request.AIProvider,
+		AIModel:          request.AIModel,
+		ReviewMode:       request.ReviewMode,
+		Language:         request.Language,
+		DiffBytes:        len(request.GitDiff),
+		LatencyMs:        latency.Milliseconds(),
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Overview:         response.Overview,
+		DiagnosticCount:  len(response.Diagnostics),
+		SeverityCounts:   make(map[string]int),
+		Diagnostics:      response.Diagnostics,
+		Suppressed:       response.Suppressed,
+		PromptVersion:    request.PromptVersion,
+		Batched:          usage.Batched,
+		CachedTokens:     usage.CachedTokens,
+	}
+	if request.GitInfo != nil {
+		record.Repository = request.GitInfo.RepoURL
+		record.PRNumber = request.GitInfo.PRNumber
+		record.CommitHash = request.GitInfo.CommitHash
+		record.BranchName = request.GitInfo.BranchName
+	}
+	if cost, ok := reviewCost(request.AIModel, usage); ok {
+		record.CostUSD = &cost
+	}
+	for _, d := range response.Diagnostics {
+		record.SeverityCounts[d.Severity]++
+	}
+	return record
 }
 
+// routeRequest picks the provider and model of a request that names
+// neither by the routing rules, among those the caller may use. Tenants with
+// their own providers keep their defaults.
+func (h *ReviewHandler) routeRequest(r *http.Request, registry *providers.Registry, request *models.ReviewRequest) {
+	router := routing.Current()
+	if router == nil || registry != h.registry || request.AIProvider != "" || request.AIModel != "" {
+		return
+	}
+
+	subject := policySubject(r.Context(), request.GitInfo, request.Language)
+	choice, ok := router.Pick(registry, routing.Request{
+		PromptTokens: pricing.EstimateTokens(request.GitDiff),
+		Mode:         prompt.NormalizeMode(request.ReviewMode),
+		Language:     request.Language,
+		Time:         time.Now(),
+	}, func(provider, model string) bool {
+		return middleware.AuthorizeModel(r.Context(), provider, model) == nil && h.policy.Check(subject, provider, model) == nil
+	})
+	if !ok {
+		return
+	}
+	request.AIProvider, request.AIModel, request.Route = choice.Provider, choice.Model, choice.Route
+	log.Printf("Route %s picked %s/%s", choice.Route, choice.Provider, choice.Model)
+}
+
+// experimentKey identifies the unit a prompt experiment assigns versions
+// to: the client's pull request or commit, so re-reviews keep their
+// version, or otherwise the single request
+func experimentKey(r *http.Request, gitInfo *models.GitInfo) string {
+	if gitInfo != nil && gitInfo.RepoURL != "" {
+		if gitInfo.PRNumber != "" {
+			return middleware.ClientID(r.Context()) + "|" + scm.NormalizeRepository(gitInfo.RepoURL) + "#" + gitInfo.PRNumber
+		}
+		if gitInfo.CommitHash != "" {
+			return middleware.ClientID(r.Context()) + "|" + scm.NormalizeRepository(gitInfo.RepoURL) + "@" + gitInfo.CommitHash
+		}
+	}
+	return session.NewID()
+}
+
+// retrievalQuery builds the text used to search the repository index: the
+// paths and added lines of the change
+func retrievalQuery(files []*diff.File) string {
+	var builder strings.Builder
+	for _, f := range files {
+		builder.WriteString(f.Path())
+		builder.WriteString("\n")
+		for _, h := range f.Hunks {
+			for _, l := range h.Lines {
+				if l.Kind == diff.LineAdded {
+					builder.WriteString(l.Content)
+					builder.WriteString("\n")
+				}
+			}
+		}
+	}
+	return builder.String()
+}
+
+// reviewEvent builds the callback event for an asynchronous review
+func reviewEvent(id string, response models.ReviewResponse, reqErr *requestError) (string, interface{}) {
+	if reqErr != nil {
+		return "review.failed", map[string]interface{}{
+			"id":     id,
+			"status": "failed",
+			"error":  map[string]interface{}{"status": reqErr.status, "message": reqErr.message},
+		}
+	}
+	return "review.completed", map[string]interface{}{
+		"id":     id,
+		"status": "completed",
+		"review": response,
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/config"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/jobs"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/periodic"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/ratelimit"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scm"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/session"
+)
+
+// ResolveScheduled returns the commits a scheduled review covers now: the
+// tips of its branch pair, or the last commits of its branch
+func (h *ReviewHandler) ResolveScheduled(ctx context.Context, s periodic.Schedule) (string, string, error) {
+	resolve := func(branch string, back int) (string, error) {
+		return scm.ResolveBranch(ctx, scm.BranchRequest{
+			RepoURL:      s.Repo,
+			Branch:       branch,
+			Back:         back,
+			Token:        h.scheduleToken(s),
+			AllowedHosts: h.config.RemoteDiffHosts,
+		})
+	}
+
+	if s.Branch != "" {
+		head, err := resolve(s.Branch, 0)
+		if err != nil {
+			return "", "", err
+		}
+		base, err := resolve(s.Branch, s.LastCommits)
+		return base, head, err
+	}
+	base, err := resolve(s.Base, 0)
+	if err != nil {
+		return "", "", err
+	}
+	head, err := resolve(s.Head, 0)
+	return base, head, err
+}
+
+// SubmitScheduled queues a scheduled review on the batch workers
+func (h *ReviewHandler) SubmitScheduled(s periodic.Schedule, request models.ReviewRequest, done func(models.ReviewResponse, error)) error {
+	request.SCMToken = h.scheduleToken(s)
+	request.Priority = jobs.ClassBatch
+	return h.jobs.Submit(jobs.ClassBatch, func(ctx context.Context) {
+		response, reqErr := h.runScheduled(ctx, request)
+		if reqErr != nil {
+			done(response, reqErr)
+			return
+		}
+		done(response, nil)
+	})
+}
+
+// runScheduled reviews a scheduled request as /review would, fetching its
+// diff, recording it in the history and sending its notifications
+func (h *ReviewHandler) runScheduled(ctx context.Context, request models.ReviewRequest) (models.ReviewResponse, *requestError) {
+	r, err := http.NewRequestWithContext(middleware.WithPriority(ctx, ratelimit.PriorityLow), http.MethodPost, "/review", nil)
+	if err != nil {
+		return models.ReviewResponse{}, &requestError{http.StatusInternalServerError, err.Error()}
+	}
+
+	registry := h.registry
+	h.routeRequest(r, registry, &request)
+	request.AIProvider, request.AIModel, err = registry.Resolve(request.AIProvider, request.AIModel)
+	if err == nil {
+		err = registry.CheckParams(request.AIProvider, request.AIModel, request.ModelParams)
+	}
+	if err != nil {
+		return models.ReviewResponse{}, &requestError{http.StatusBadRequest, err.Error()}
+	}
+	subject := policySubject(ctx, request.GitInfo, request.Language)
+	if err := h.policy.Check(subject, request.AIProvider, request.AIModel); err != nil {
+		return models.ReviewResponse{}, policyViolation(err)
+	}
+	if reqErr := h.fetchDiff(ctx, &request); reqErr != nil {
+		return models.ReviewResponse{}, reqErr
+	}
+
+	prepared, reqErr := h.prepareReview(r, request, true)
+	if reqErr != nil {
+		return models.ReviewResponse{}, reqErr
+	}
+	return h.complete(r, registry, subject, prepared, session.NewID())
+}
+
+// scheduleToken returns the token a schedule reads its repository with:
+// its token_env variable, or GITHUB_TOKEN for GitHub repositories
+func (h *ReviewHandler) scheduleToken(s periodic.Schedule) string {
+	if s.TokenEnv != "" {
+		return config.Secret(s.TokenEnv)
+	}
+	if strings.HasPrefix(scm.NormalizeRepository(s.Repo), "github.com/") {
+		return h.config.GitHubToken.Get()
+	}
+	return ""
+}
+
+// ScheduledHandler handles the admin endpoints of scheduled reviews
+type ScheduledHandler struct {
+	runner *periodic.Runner
+}
+
+// NewScheduledHandler creates a handler for runner, which is nil when no
+// reviews are scheduled
+func NewScheduledHandler(runner *periodic.Runner) *ScheduledHandler {
+	return &ScheduledHandler{runner: runner}
+}
+
+// HandleScheduled handles /admin/scheduled-reviews. GET lists the schedules
+// with their next and latest runs; POST /admin/scheduled-reviews/{name}/run
+// runs one now.
+func (h *ScheduledHandler) HandleScheduled(w http.ResponseWriter, r *http.Request) {
+	if h.runner == nil {
+		writeError(w, http.StatusServiceUnavailable, "No reviews are scheduled")
+		return
+	}
+
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/scheduled-reviews"), "/")
+	if rest == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"schedules": h.runner.Statuses()})
+		return
+	}
+
+	name, action, _ := strings.Cut(rest, "/")
+	if action != "run" {
+		writeError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.runner.Trigger(name) {
+		writeError(w, http.StatusNotFound, "Unknown scheduled review "+name)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"name": name, "status": "triggered"})
+}
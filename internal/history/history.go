@@ -0,0 +1,359 @@
+// Package history keeps completed reviews in a SQL database so they can be
+// audited and queried by repository, pull request and prompt version
+package history
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // Registers the pgx driver
+	_ "modernc.org/sqlite"             // Registers the sqlite driver
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scm"
+)
+
+// queryTimeout bounds one statement, so a stalled database can't hold up
+// the review that is being recorded
+const queryTimeout = 5 * time.Second
+
+// Query selects reviews from the history
+type Query struct {
+	Repository    string // Any URL form; matched after normalization
+	PRNumber      string
+	PromptVersion string
+	Limit         int
+	Offset        int
+}
+
+// Store keeps completed reviews in SQLite or PostgreSQL. Each review is a
+// row holding the full record as JSON, with the columns it is queried and
+// summed by alongside and indexed. Without a database, reviews are kept
+// in an in-memory SQLite database bounded to the latest maxReviews.
+type Store struct {
+	db         *sql.DB
+	postgres   bool
+	maxReviews int // Bound of an in-memory database; zero keeps every review
+}
+
+// NewStore opens the review history in database: a postgres:// URL, or the
+// path of a SQLite file, which is created if missing. An empty database
+// keeps the latest maxReviews in memory. It returns nil if maxReviews is
+// not positive; a nil store keeps nothing.
+func NewStore(database string, maxReviews int) (*Store, error) {
+	if maxReviews <= 0 {
+		return nil, nil
+	}
+
+	s := &Store{}
+	var err error
+	switch {
+	case strings.HasPrefix(database, "postgres://") || strings.HasPrefix(database, "postgresql://"):
+		s.postgres = true
+		s.db, err = sql.Open("pgx", database)
+	case database == "":
+		s.maxReviews = maxReviews
+		s.db, err = sql.Open("sqlite", ":memory:")
+		if err == nil {
+			// Every connection to :memory: opens a database of its own
+			s.db.SetMaxOpenConns(1)
+		}
+	default:
+		if err := os.MkdirAll(filepath.Dir(database), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to open review history: %w", err)
+		}
+		s.db, err = sql.Open("sqlite", "file:"+database+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open review history: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.migrate(ctx); err != nil {
+		s.db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// migrate creates the reviews table and its indexes if they are missing
+func (s *Store) migrate(ctx context.Context) error {
+	seq, cost := "INTEGER PRIMARY KEY AUTOINCREMENT", "REAL"
+	if s.postgres {
+		seq, cost = "BIGSERIAL PRIMARY KEY", "DOUBLE PRECISION"
+	}
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS reviews (
+			seq ` + seq + `,
+			id TEXT NOT NULL,
+			tenant TEXT NOT NULL,
+			created_at BIGINT NOT NULL,
+			repository TEXT NOT NULL,
+			pr_number TEXT NOT NULL,
+			prompt_version TEXT NOT NULL,
+			diagnostic_count INTEGER NOT NULL,
+			prompt_tokens INTEGER NOT NULL,
+			completion_tokens INTEGER NOT NULL,
+			cost_usd ` + cost + `,
+			latency_ms BIGINT NOT NULL,
+			record TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS reviews_id ON reviews (id)`,
+		`CREATE INDEX IF NOT EXISTS reviews_tenant_created ON reviews (tenant, created_at)`,
+		`CREATE INDEX IF NOT EXISTS reviews_repository_pr ON reviews (repository, pr_number, created_at)`,
+		`CREATE INDEX IF NOT EXISTS reviews_prompt_version ON reviews (prompt_version, tenant)`,
+		`CREATE INDEX IF NOT EXISTS reviews_created ON reviews (created_at)`,
+	}
+	for _, statement := range statements {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to create review history tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// Add records a completed review for a client
+func (s *Store) Add(tenant string, review models.ReviewRecord) error {
+	if s == nil {
+		return nil
+	}
+	review.Repository = scm.NormalizeRepository(review.Repository)
+	record, err := json.Marshal(review)
+	if err != nil {
+		return fmt.Errorf("failed to encode review: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	_, err = s.db.ExecContext(ctx, s.rebind(`INSERT INTO reviews
+		(id, tenant, created_at, repository, pr_number, prompt_version, diagnostic_count,
+		 prompt_tokens, completion_tokens, cost_usd, latency_ms, record)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		review.ID, tenant, review.CreatedAt.UnixMilli(), review.Repository, review.PRNumber, review.PromptVersion,
+		review.DiagnosticCount, review.PromptTokens, review.CompletionTokens, review.CostUSD, review.LatencyMs, string(record))
+	if err != nil {
+		return fmt.Errorf("failed to save review %s: %w", review.ID, err)
+	}
+
+	if s.maxReviews > 0 {
+		_, err = s.db.ExecContext(ctx, s.rebind(`DELETE FROM reviews WHERE seq <= (SELECT MAX(seq) FROM reviews) - ?`), s.maxReviews)
+		if err != nil {
+			return fmt.Errorf("failed to prune review history: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Ping reports whether the database can be reached
+func (s *Store) Ping(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	return s.db.PingContext(ctx)
+}
+
+// Get returns one of a client's reviews
+func (s *Store) Get(tenant, id string) (models.ReviewRecord, bool, error) {
+	if s == nil {
+		return models.ReviewRecord{}, false, nil
+	}
+	review, _, ok, err := s.find(`tenant = ? AND id = ?`, tenant, id)
+	return review, ok, err
+}
+
+// Find returns a review of any client, with the client it belongs to
+func (s *Store) Find(id string) (models.ReviewRecord, bool, error) {
+	if s == nil {
+		return models.ReviewRecord{}, false, nil
+	}
+	review, tenant, ok, err := s.find(`id = ?`, id)
+	review.Tenant = tenant
+	return review, ok, err
+}
+
+// find returns the latest review matching a condition
+func (s *Store) find(condition string, args ...interface{}) (models.ReviewRecord, string, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	var tenant, record string
+	err := s.db.QueryRowContext(ctx, s.rebind(`SELECT tenant, record FROM reviews WHERE `+condition+` ORDER BY seq DESC LIMIT 1`), args...).
+		Scan(&tenant, &record)
+	if err == sql.ErrNoRows {
+		return models.ReviewRecord{}, "", false, nil
+	}
+	if err != nil {
+		return models.ReviewRecord{}, "", false, fmt.Errorf("failed to read review history: %w", err)
+	}
+	var review models.ReviewRecord
+	if err := json.Unmarshal([]byte(record), &review); err != nil {
+		return models.ReviewRecord{}, "", false, fmt.Errorf("failed to decode review: %w", err)
+	}
+	return review, tenant, true, nil
+}
+
+// List returns a client's reviews matching the query, newest first and
+// without diagnostics
+func (s *Store) List(tenant string, query Query) (models.ReviewList, error) {
+	return s.list(query, false, tenant)
+}
+
+// ListAll returns the reviews of every client matching the query, newest
+// first, without diagnostics and with the client each belongs to. An empty
+// tenant matches every client.
+func (s *Store) ListAll(tenant string, query Query) (models.ReviewList, error) {
+	return s.list(query, true, tenant)
+}
+
+// list returns the reviews of tenant that match the query, newest first
+// and without diagnostics or prompts. With withTenant set, each carries
+// its client, and an empty tenant matches every client.
+func (s *Store) list(query Query, withTenant bool, tenant string) (models.ReviewList, error) {
+	result := models.ReviewList{Reviews: []models.ReviewRecord{}}
+	if s == nil {
+		return result, nil
+	}
+
+	var conditions []string
+	var args []interface{}
+	filter := func(column, value string) {
+		if value != "" {
+			conditions = append(conditions, column+" = ?")
+			args = append(args, value)
+		}
+	}
+	if tenant != "" || !withTenant {
+		conditions = append(conditions, "tenant = ?")
+		args = append(args, tenant)
+	}
+	filter("repository", scm.NormalizeRepository(query.Repository))
+	filter("pr_number", query.PRNumber)
+	filter("prompt_version", query.PromptVersion)
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	if err := s.db.QueryRowContext(ctx, s.rebind(`SELECT COUNT(*) FROM reviews`+where), args...).Scan(&result.Total); err != nil {
+		return result, fmt.Errorf("failed to read review history: %w", err)
+	}
+	if query.Limit <= 0 || query.Offset >= result.Total {
+		return result, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT tenant, record FROM reviews`+where+
+		` ORDER BY created_at DESC, seq DESC LIMIT ? OFFSET ?`), append(args, query.Limit, query.Offset)...)
+	if err != nil {
+		return result, fmt.Errorf("failed to read review history: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var owner, record string
+		if err := rows.Scan(&owner, &record); err != nil {
+			return result, fmt.Errorf("failed to read review history: %w", err)
+		}
+		var review models.ReviewRecord
+		if err := json.Unmarshal([]byte(record), &review); err != nil {
+			return result, fmt.Errorf("failed to decode review: %w", err)
+		}
+		review.Diagnostics = nil
+		review.Prompts = nil
+		if withTenant {
+			review.Tenant = owner
+		}
+		result.Reviews = append(result.Reviews, review)
+	}
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("failed to read review history: %w", err)
+	}
+	return result, nil
+}
+
+// Usage sums the reviews of every client completed since a time into
+// buckets of a fixed length, oldest first. Buckets without reviews are
+// included so the series can be drawn as is.
+func (s *Store) Usage(since time.Time, bucket time.Duration) ([]models.UsageBucket, error) {
+	since = since.Truncate(bucket)
+	count := int(time.Since(since)/bucket) + 1
+	buckets := make([]models.UsageBucket, count)
+	latency := make([]int64, count)
+	for i := range buckets {
+		buckets[i].Start = since.Add(time.Duration(i) * bucket).UTC()
+	}
+	if s == nil {
+		return buckets, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	width := bucket.Milliseconds()
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT (created_at - ?) / ?, COUNT(*),
+		CAST(SUM(diagnostic_count) AS BIGINT), CAST(SUM(prompt_tokens) AS BIGINT), CAST(SUM(completion_tokens) AS BIGINT),
+		COALESCE(SUM(cost_usd), 0), CAST(SUM(latency_ms) AS BIGINT)
+		FROM reviews WHERE created_at >= ? GROUP BY 1`),
+		since.UnixMilli(), width, since.UnixMilli())
+	if err != nil {
+		return buckets, fmt.Errorf("failed to read review history: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var index int
+		var b models.UsageBucket
+		var latencyMs int64
+		if err := rows.Scan(&index, &b.Reviews, &b.Diagnostics, &b.PromptTokens, &b.CompletionTokens, &b.CostUSD, &latencyMs); err != nil {
+			return buckets, fmt.Errorf("failed to read review history: %w", err)
+		}
+		if index < 0 || index >= count {
+			continue
+		}
+		b.Start = buckets[index].Start
+		buckets[index] = b
+		latency[index] = latencyMs
+	}
+	if err := rows.Err(); err != nil {
+		return buckets, fmt.Errorf("failed to read review history: %w", err)
+	}
+	for i := range buckets {
+		if buckets[i].Reviews > 0 {
+			buckets[i].AvgLatencyMs = latency[i] / int64(buckets[i].Reviews)
+		}
+	}
+	return buckets, nil
+}
+
+// rebind rewrites the ? placeholders of a query as $1, $2, ... for
+// PostgreSQL
+func (s *Store) rebind(query string) string {
+	if !s.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
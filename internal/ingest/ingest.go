@@ -0,0 +1,191 @@
+// Package ingest reviews requests pulled from a message queue, NATS or
+// Kafka, and publishes their results to another topic, so CI systems can
+// hand reviews over without depending on the gateway being reachable
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// Brokers
+const (
+	BrokerNATS  = "nats"
+	BrokerKafka = "kafka" // Through the Confluent REST Proxy
+)
+
+// maxBackoff caps the wait between attempts to reach the broker
+const maxBackoff = 30 * time.Second
+
+// stats counts ingested messages by outcome, e.g. completed
+var stats = expvar.NewMap("ingest")
+
+// Message is a message read from or published to the broker
+type Message struct {
+	Topic string
+	Key   string // Kafka record key; results carry the request's
+	Value []byte
+	Reply string // NATS reply subject; results go there instead of the results topic
+
+	partition int32 // Kafka position, committed once the message is handled
+	offset    int64
+}
+
+// Broker reads review requests from one topic and publishes results
+type Broker interface {
+	// Fetch blocks until messages are available or ctx is done
+	Fetch(ctx context.Context) ([]Message, error)
+	// Commit marks fetched messages as handled, so they aren't delivered
+	// again after a restart
+	Commit(ctx context.Context, messages []Message) error
+	Publish(ctx context.Context, message Message) error
+	Close() error
+}
+
+// Config describes the broker to connect to
+type Config struct {
+	Broker       string // nats or kafka
+	URL          string // nats://host:4222 (tls:// for TLS), or the REST Proxy's URL
+	Username     string // Empty with a password sends it as a NATS token
+	Password     string
+	Topic        string // Subject or topic review requests are read from
+	ResultsTopic string // Subject or topic results are published to
+	Group        string // Queue group or consumer group shared by every gateway
+}
+
+// New connects to the configured broker
+func New(cfg Config) (Broker, error) {
+	switch cfg.Broker {
+	case BrokerNATS:
+		return newNATS(cfg)
+	case BrokerKafka:
+		return newKafka(cfg)
+	}
+	return nil, fmt.Errorf("unknown broker %q: must be nats or kafka", cfg.Broker)
+}
+
+// Reviewer reviews one request as /review would
+type Reviewer func(ctx context.Context, request models.ReviewRequest) (models.ReviewResponse, *models.BatchError)
+
+// Consumer reviews the requests of a broker, concurrency at a time
+type Consumer struct {
+	broker       Broker
+	resultsTopic string
+	concurrency  int
+	review       Reviewer
+	done         chan struct{}
+}
+
+// NewConsumer creates a consumer publishing results to resultsTopic
+func NewConsumer(broker Broker, resultsTopic string, concurrency int, review Reviewer) *Consumer {
+	return &Consumer{
+		broker:       broker,
+		resultsTopic: resultsTopic,
+		concurrency:  concurrency,
+		review:       review,
+		done:         make(chan struct{}),
+	}
+}
+
+// Start consumes in the background until ctx is done. Reviews already
+// fetched are finished, published and committed before it stops.
+func (c *Consumer) Start(ctx context.Context) {
+	go c.loop(ctx)
+}
+
+// loop fetches, reviews and commits messages until ctx is done
+func (c *Consumer) loop(ctx context.Context) {
+	defer close(c.done)
+	backoff := time.Second
+	for ctx.Err() == nil {
+		messages, err := c.broker.Fetch(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Warning: failed to fetch review requests, retrying in %v: %v", backoff, err)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+		backoff = time.Second
+
+		// Reviews outlive ctx so a shutdown doesn't drop fetched messages
+		background := context.WithoutCancel(ctx)
+		slots := make(chan struct{}, c.concurrency)
+		var wg sync.WaitGroup
+		for _, m := range messages {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func(m Message) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				c.handle(background, m)
+			}(m)
+		}
+		wg.Wait()
+		if err := c.broker.Commit(background, messages); err != nil {
+			log.Printf("Warning: failed to commit %d review requests; they may be reviewed again: %v", len(messages), err)
+		}
+	}
+}
+
+// handle reviews one message and publishes its result
+func (c *Consumer) handle(ctx context.Context, m Message) {
+	stats.Add("received", 1)
+	var request models.IngestRequest
+	result := models.IngestResult{Status: "completed"}
+	if err := json.Unmarshal(m.Value, &request); err != nil {
+		result.Status = "failed"
+		result.Error = &models.BatchError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Invalid JSON: %v", err)}
+	} else {
+		result.RequestID = request.RequestID
+		response, reviewErr := c.review(ctx, request.ReviewRequest)
+		if reviewErr != nil {
+			result.Status = "failed"
+			result.Error = reviewErr
+		} else {
+			result.ID = response.ID
+			result.Review = &response
+		}
+	}
+	if result.RequestID == "" {
+		result.RequestID = m.Key
+	}
+	stats.Add(result.Status, 1)
+
+	value, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("Error encoding review result: %v", err)
+		return
+	}
+	out := Message{Topic: c.resultsTopic, Key: result.RequestID, Value: value}
+	if m.Reply != "" {
+		out.Topic = m.Reply
+	}
+	if err := c.broker.Publish(ctx, out); err != nil {
+		stats.Add("publish_errors", 1)
+		log.Printf("Warning: failed to publish the result of review request %q to %s: %v", result.RequestID, out.Topic, err)
+	}
+}
+
+// Wait blocks until the consumer has stopped or ctx is done
+func (c *Consumer) Wait(ctx context.Context) error {
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
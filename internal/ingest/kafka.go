@@ -0,0 +1,271 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	kafkaContentType = "application/vnd.kafka.v2+json"
+	kafkaBinaryType  = "application/vnd.kafka.binary.v2+json"
+	// kafkaPollInterval is the wait between fetches that return nothing
+	kafkaPollInterval = time.Second
+)
+
+// kafkaBroker consumes and produces through the Confluent REST Proxy (API
+// v2). Offsets are committed once a fetch's reviews are published, so a
+// gateway that stops mid-batch leaves them to be reviewed again.
+type kafkaBroker struct {
+	cfg    Config
+	client *http.Client
+
+	mu       sync.Mutex
+	instance string // base_uri of the consumer instance; empty until created
+}
+
+// kafkaRecord is a record as the REST Proxy returns it, in binary format
+type kafkaRecord struct {
+	Topic     string `json:"topic"`
+	Key       []byte `json:"key"` // base64 in JSON
+	Value     []byte `json:"value"`
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// kafkaError is the body of a REST Proxy error
+type kafkaError struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+func newKafka(cfg Config) (*kafkaBroker, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid Kafka REST Proxy URL %q", cfg.URL)
+	}
+	b := &kafkaBroker{cfg: cfg, client: &http.Client{Timeout: 30 * time.Second}}
+	if _, err := b.consumer(context.Background()); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// consumer returns the consumer instance, creating and subscribing one in
+// the consumer group if needed
+func (b *kafkaBroker) consumer(ctx context.Context) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.instance != "" {
+		return b.instance, nil
+	}
+
+	var created struct {
+		BaseURI string `json:"base_uri"`
+	}
+	body := map[string]string{
+		"format":             "binary",
+		"auto.offset.reset":  "earliest",
+		"auto.commit.enable": "false",
+	}
+	endpoint := strings.TrimRight(b.cfg.URL, "/") + "/consumers/" + url.PathEscape(b.cfg.Group)
+	if err := b.call(ctx, http.MethodPost, endpoint, kafkaContentType, body, &created); err != nil {
+		return "", fmt.Errorf("failed to create Kafka consumer: %w", err)
+	}
+	subscription := map[string][]string{"topics": {b.cfg.Topic}}
+	if err := b.call(ctx, http.MethodPost, created.BaseURI+"/subscription", kafkaContentType, subscription, nil); err != nil {
+		b.call(ctx, http.MethodDelete, created.BaseURI, kafkaContentType, nil, nil)
+		return "", fmt.Errorf("failed to subscribe to %s: %w", b.cfg.Topic, err)
+	}
+	b.instance = created.BaseURI
+	return b.instance, nil
+}
+
+// reset forgets a consumer instance the proxy no longer knows, e.g. after
+// it restarted or expired the instance
+func (b *kafkaBroker) reset(instance string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.instance == instance {
+		b.instance = ""
+	}
+}
+
+// Fetch polls the consumer until records arrive
+func (b *kafkaBroker) Fetch(ctx context.Context) ([]Message, error) {
+	instance, err := b.consumer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var records []kafkaRecord
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, instance+"/records", nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", kafkaBinaryType)
+		if err := b.do(req, &records); err != nil {
+			if isNotFound(err) {
+				b.reset(instance)
+			}
+			return nil, fmt.Errorf("failed to fetch records: %w", err)
+		}
+		if len(records) > 0 {
+			messages := make([]Message, len(records))
+			for i, record := range records {
+				messages[i] = Message{Topic: record.Topic, Key: string(record.Key), Value: record.Value, partition: record.Partition, offset: record.Offset}
+			}
+			return messages, nil
+		}
+
+		select {
+		case <-time.After(kafkaPollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Commit commits the highest offset of each partition fetched
+func (b *kafkaBroker) Commit(ctx context.Context, messages []Message) error {
+	type offset struct {
+		Topic     string `json:"topic"`
+		Partition int32  `json:"partition"`
+		Offset    int64  `json:"offset"`
+	}
+	latest := make(map[string]offset)
+	for _, m := range messages {
+		key := fmt.Sprintf("%s/%d", m.Topic, m.partition)
+		if o, ok := latest[key]; !ok || m.offset > o.Offset {
+			latest[key] = offset{Topic: m.Topic, Partition: m.partition, Offset: m.offset}
+		}
+	}
+	offsets := make([]offset, 0, len(latest))
+	for _, o := range latest {
+		offsets = append(offsets, o)
+	}
+
+	instance, err := b.consumer(ctx)
+	if err != nil {
+		return err
+	}
+	err = b.call(ctx, http.MethodPost, instance+"/offsets", kafkaContentType, map[string]interface{}{"offsets": offsets}, nil)
+	if isNotFound(err) {
+		b.reset(instance)
+	}
+	return err
+}
+
+// Publish produces a record to its topic
+func (b *kafkaBroker) Publish(ctx context.Context, m Message) error {
+	record := map[string]interface{}{"value": base64.StdEncoding.EncodeToString(m.Value)}
+	if m.Key != "" {
+		record["key"] = base64.StdEncoding.EncodeToString([]byte(m.Key))
+	}
+	var produced struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+	}
+	endpoint := strings.TrimRight(b.cfg.URL, "/") + "/topics/" + url.PathEscape(m.Topic)
+	body := map[string]interface{}{"records": []interface{}{record}}
+	if err := b.call(ctx, http.MethodPost, endpoint, kafkaBinaryType, body, &produced); err != nil {
+		return err
+	}
+	for _, o := range produced.Offsets {
+		if o.ErrorCode != nil {
+			return fmt.Errorf("Kafka rejected the record: %s (code %d)", o.Error, *o.ErrorCode)
+		}
+	}
+	return nil
+}
+
+// Close deletes the consumer instance, leaving the group at once instead
+// of when the proxy expires it
+func (b *kafkaBroker) Close() error {
+	b.mu.Lock()
+	instance := b.instance
+	b.instance = ""
+	b.mu.Unlock()
+	if instance == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := b.call(ctx, http.MethodDelete, instance, kafkaContentType, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete Kafka consumer: %w", err)
+	}
+	return nil
+}
+
+// call sends a JSON request and decodes the response into out, if not nil
+func (b *kafkaBroker) call(ctx context.Context, method, endpoint, contentType string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", kafkaContentType)
+	return b.do(req, out)
+}
+
+// do sends a request with the configured credentials
+func (b *kafkaBroker) do(req *http.Request, out interface{}) error {
+	if b.cfg.Username != "" {
+		req.SetBasicAuth(b.cfg.Username, b.cfg.Password)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var e kafkaError
+		if json.Unmarshal(data, &e) == nil && e.Message != "" {
+			return &proxyError{status: resp.StatusCode, message: e.Message}
+		}
+		return &proxyError{status: resp.StatusCode, message: strings.TrimSpace(string(data))}
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// proxyError is an error answer of the REST Proxy
+type proxyError struct {
+	status  int
+	message string
+}
+
+func (e *proxyError) Error() string {
+	return fmt.Sprintf("REST Proxy returned %d: %s", e.status, e.message)
+}
+
+// isNotFound reports whether the proxy didn't know the consumer instance
+func isNotFound(err error) bool {
+	e, ok := err.(*proxyError)
+	return ok && e.status == http.StatusNotFound
+}
@@ -0,0 +1,311 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	natsDialTimeout = 10 * time.Second
+	// natsReadTimeout outlasts the server's pings, every two minutes by
+	// default, so a silent connection is noticed and replaced
+	natsReadTimeout = 5 * time.Minute
+	// natsPending is the number of messages read ahead of the reviews
+	natsPending = 64
+)
+
+// natsBroker speaks the NATS client protocol. Core NATS doesn't redeliver:
+// messages fetched when the gateway stops are lost, and requests published
+// while no gateway is subscribed are dropped.
+type natsBroker struct {
+	cfg     Config
+	closing chan struct{}
+
+	mu   sync.Mutex
+	conn *natsConn
+}
+
+// natsConn is one connection to the server
+type natsConn struct {
+	conn       net.Conn
+	maxPayload int
+
+	wmu sync.Mutex
+	w   *bufio.Writer
+
+	messages chan Message
+	done     chan struct{} // Closed when the connection fails
+	err      error
+}
+
+// natsInfo is the part of the server's INFO the client uses
+type natsInfo struct {
+	MaxPayload  int  `json:"max_payload"`
+	TLSRequired bool `json:"tls_required"`
+}
+
+func newNATS(cfg Config) (*natsBroker, error) {
+	b := &natsBroker{cfg: cfg, closing: make(chan struct{})}
+	if _, err := b.current(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// current returns the live connection, reconnecting if it failed
+func (b *natsBroker) current() (*natsConn, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn != nil {
+		select {
+		case <-b.conn.done:
+		default:
+			return b.conn, nil
+		}
+	}
+	select {
+	case <-b.closing:
+		return nil, errors.New("NATS connection closed")
+	default:
+	}
+
+	conn, err := b.dial()
+	if err != nil {
+		return nil, err
+	}
+	b.conn = conn
+	return conn, nil
+}
+
+// dial connects, authenticates and subscribes to the request subject in
+// the queue group, so each request goes to one gateway
+func (b *natsBroker) dial() (*natsConn, error) {
+	u, err := url.Parse(b.cfg.URL)
+	if err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid NATS URL %q: expected nats://host:port or tls://host:port", b.cfg.URL)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	raw, err := net.DialTimeout("tcp", addr, natsDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS at %s: %w", addr, err)
+	}
+	conn := raw
+	fail := func(err error) (*natsConn, error) {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(natsDialTimeout))
+
+	r := bufio.NewReader(conn)
+	line, err := readLine(r)
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		return fail(fmt.Errorf("unexpected NATS greeting %q: %v", line, err))
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err != nil {
+		return fail(fmt.Errorf("failed to parse NATS server info: %w", err))
+	}
+	secure := u.Scheme == "tls" || info.TLSRequired
+	if secure {
+		tlsConn := tls.Client(raw, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			return fail(fmt.Errorf("NATS TLS handshake failed: %w", err))
+		}
+		conn = tlsConn
+		r = bufio.NewReader(conn)
+	}
+
+	options := map[string]interface{}{
+		"verbose":      false,
+		"pedantic":     false,
+		"tls_required": secure,
+		"name":         "ai-review-gateway",
+		"lang":         "go",
+		"version":      "1.0",
+		"protocol":     1,
+	}
+	switch {
+	case b.cfg.Username != "":
+		options["user"], options["pass"] = b.cfg.Username, b.cfg.Password
+	case b.cfg.Password != "":
+		options["auth_token"] = b.cfg.Password
+	}
+	connect, _ := json.Marshal(options)
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "CONNECT %s\r\nPING\r\n", connect)
+	if err := w.Flush(); err != nil {
+		return fail(fmt.Errorf("failed to send NATS CONNECT: %w", err))
+	}
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return fail(fmt.Errorf("NATS connection failed: %w", err))
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			return fail(fmt.Errorf("NATS rejected the connection: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR"))))
+		}
+		if line == "PONG" {
+			break
+		}
+	}
+
+	fmt.Fprintf(w, "SUB %s %s 1\r\n", b.cfg.Topic, b.cfg.Group)
+	if err := w.Flush(); err != nil {
+		return fail(fmt.Errorf("failed to subscribe to %s: %w", b.cfg.Topic, err))
+	}
+	conn.SetDeadline(time.Time{})
+
+	c := &natsConn{
+		conn:       conn,
+		maxPayload: info.MaxPayload,
+		w:          w,
+		messages:   make(chan Message, natsPending),
+		done:       make(chan struct{}),
+	}
+	go c.read(r, b.closing)
+	return c, nil
+}
+
+// read receives messages and answers pings until the connection fails
+func (c *natsConn) read(r *bufio.Reader, closing chan struct{}) {
+	defer close(c.done)
+	defer c.conn.Close()
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(natsReadTimeout))
+		line, err := readLine(r)
+		if err != nil {
+			c.err = fmt.Errorf("NATS connection lost: %w", err)
+			return
+		}
+		switch {
+		case strings.HasPrefix(line, "MSG "):
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			fields := strings.Fields(line)
+			if len(fields) != 4 && len(fields) != 5 {
+				c.err = fmt.Errorf("malformed NATS message header %q", line)
+				return
+			}
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || size < 0 {
+				c.err = fmt.Errorf("malformed NATS message header %q", line)
+				return
+			}
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				c.err = fmt.Errorf("NATS connection lost: %w", err)
+				return
+			}
+			m := Message{Topic: fields[1], Value: payload[:size]}
+			if len(fields) == 5 {
+				m.Reply = fields[3]
+			}
+			select {
+			case c.messages <- m:
+			case <-closing:
+				return
+			}
+		case line == "PING":
+			if err := c.write("PONG\r\n"); err != nil {
+				c.err = err
+				return
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			c.err = fmt.Errorf("NATS error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+			return
+		}
+	}
+}
+
+// write sends protocol lines and flushes them
+func (c *natsConn) write(format string, args ...interface{}) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(natsDialTimeout))
+	fmt.Fprintf(c.w, format, args...)
+	return c.w.Flush()
+}
+
+// Fetch waits for a message, then takes whatever else has arrived
+func (b *natsBroker) Fetch(ctx context.Context) ([]Message, error) {
+	c, err := b.current()
+	if err != nil {
+		return nil, err
+	}
+	var messages []Message
+	select {
+	case m := <-c.messages:
+		messages = append(messages, m)
+	case <-c.done:
+		return nil, c.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	for {
+		select {
+		case m := <-c.messages:
+			messages = append(messages, m)
+		default:
+			return messages, nil
+		}
+	}
+}
+
+// Commit does nothing: core NATS has no acknowledgements
+func (b *natsBroker) Commit(ctx context.Context, messages []Message) error {
+	return nil
+}
+
+// Publish sends a message to its subject
+func (b *natsBroker) Publish(ctx context.Context, m Message) error {
+	c, err := b.current()
+	if err != nil {
+		return err
+	}
+	if c.maxPayload > 0 && len(m.Value) > c.maxPayload {
+		return fmt.Errorf("message of %d bytes exceeds the server's limit of %d", len(m.Value), c.maxPayload)
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(natsDialTimeout))
+	fmt.Fprintf(c.w, "PUB %s %d\r\n", m.Topic, len(m.Value))
+	c.w.Write(m.Value)
+	c.w.WriteString("\r\n")
+	return c.w.Flush()
+}
+
+// Close disconnects from the server
+func (b *natsBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	close(b.closing)
+	if b.conn == nil {
+		return nil
+	}
+	select {
+	case <-b.conn.done:
+		return nil
+	default:
+		return b.conn.conn.Close()
+	}
+}
+
+// readLine reads a protocol line without its CRLF
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
@@ -0,0 +1,298 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/redis"
+)
+
+const (
+	// pollTimeout is how long a worker blocks on Redis for a job before
+	// checking whether the queue is stopping
+	pollTimeout = 2 * time.Second
+	// heartbeatInterval and heartbeatTTL keep a replica's jobs its own
+	// while it runs; once its heartbeat expires, other replicas queue its
+	// unfinished jobs again
+	heartbeatInterval = 10 * time.Second
+	heartbeatTTL      = 30 * time.Second
+)
+
+// Handler runs a job queued with SubmitPayload. ctx lets it Yield its
+// worker while it waits.
+type Handler func(ctx context.Context, payload []byte)
+
+// store keeps the queued jobs of every replica in Redis lists. A worker
+// moves a job from its class's queue to its replica's processing list, and
+// removes it from there once done.
+type store struct {
+	client   *redis.Client
+	prefix   string
+	instance string
+	handler  Handler
+
+	stopping chan struct{}
+	stopOnce sync.Once
+	pullers  sync.WaitGroup
+}
+
+// NewDurable creates a queue keeping jobs in Redis under prefix. Workers
+// take jobs queued by any replica once Handle is called; jobs a replica
+// took but didn't finish, because it crashed or its shutdown timed out,
+// are queued again once its heartbeat expires. Jobs run at least once: one
+// interrupted mid-review is reviewed again from the start.
+func NewDurable(interactiveWorkers, batchWorkers, queueSize int, client *redis.Client, prefix string) (*Queue, error) {
+	q := New(interactiveWorkers, batchWorkers, queueSize)
+	s := &store{
+		client:   client,
+		prefix:   prefix,
+		instance: instanceName(),
+		stopping: make(chan struct{}),
+	}
+	if err := s.heartbeat(); err != nil {
+		return nil, err
+	}
+	q.store = s
+	return q, nil
+}
+
+// Handle starts taking jobs from Redis, running them with handler
+func (q *Queue) Handle(handler Handler) {
+	s := q.store
+	s.handler = handler
+	go s.keepAlive()
+	for class, p := range q.pools {
+		for i := 0; i < p.workers; i++ {
+			s.pullers.Add(1)
+			go q.pull(class, p)
+		}
+	}
+}
+
+// Durable reports whether jobs are kept in Redis
+func (q *Queue) Durable() bool {
+	return q.store != nil
+}
+
+// SubmitPayload queues a job described by payload in Redis, to be run by
+// the handler of whichever replica takes it
+func (q *Queue) SubmitPayload(class string, payload []byte) error {
+	if q.store == nil {
+		return fmt.Errorf("payload jobs need a durable queue")
+	}
+	if _, ok := q.pools[class]; !ok {
+		return fmt.Errorf("unknown job class %q", class)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	queued, err := redis.Int(q.store.client.Do(ctx, "LLEN", q.store.queueKey(class)))
+	if err != nil {
+		return fmt.Errorf("failed to queue job: %w", err)
+	}
+	if int(queued) >= q.queueSize {
+		stats.Add(class+".rejected", 1)
+		return ErrQueueFull
+	}
+	if _, err := q.store.client.Do(ctx, "LPUSH", q.store.queueKey(class), string(payload)); err != nil {
+		return fmt.Errorf("failed to queue job: %w", err)
+	}
+	stats.Add(class+".accepted", 1)
+	return nil
+}
+
+// pull takes jobs of a class from Redis for the workers until the queue
+// stops. Each puller holds at most one job, which waits for a free worker.
+func (q *Queue) pull(class string, p *pool) {
+	s := q.store
+	defer s.pullers.Done()
+	queue, processing := s.queueKey(class), s.processingKey(class)
+	for {
+		select {
+		case <-s.stopping:
+			return
+		default:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), pollTimeout+5*time.Second)
+		payload, err := redis.String(s.client.Do(ctx, "BRPOPLPUSH", queue, processing, fmt.Sprint(int(pollTimeout.Seconds()))))
+		cancel()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Warning: failed to take a %s job from Redis: %v", class, err)
+			select {
+			case <-time.After(pollTimeout):
+			case <-s.stopping:
+				return
+			}
+			continue
+		}
+
+		q.pending.Add(1)
+		job := func(ctx context.Context) {
+			s.handler(ctx, []byte(payload))
+			s.remove(processing, payload)
+		}
+		select {
+		case p.durable <- job:
+		case <-s.stopping:
+			// Give the job back for another replica or the next start
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if _, err := s.client.Do(ctx, "RPUSH", queue, payload); err == nil {
+				s.remove(processing, payload)
+			}
+			cancel()
+			q.pending.Done()
+			return
+		}
+	}
+}
+
+// remove deletes a finished job from a processing list
+func (s *store) remove(processing, payload string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := s.client.Do(ctx, "LREM", processing, "1", payload); err != nil {
+		log.Printf("Warning: failed to mark a job done; it may run again: %v", err)
+	}
+}
+
+// keepAlive refreshes the replica's heartbeat and requeues the unfinished
+// jobs of replicas whose heartbeat expired, until the queue stops
+func (s *store) keepAlive() {
+	s.recover()
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.heartbeat(); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			s.recover()
+		case <-s.stopping:
+			return
+		}
+	}
+}
+
+// heartbeat marks the replica alive
+func (s *store) heartbeat() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := s.client.Do(ctx, "SET", s.key("alive", s.instance), "1", "EX", fmt.Sprint(int(heartbeatTTL.Seconds()))); err != nil {
+		return fmt.Errorf("failed to record job queue heartbeat: %w", err)
+	}
+	if _, err := s.client.Do(ctx, "SADD", s.key("instances"), s.instance); err != nil {
+		return fmt.Errorf("failed to record job queue heartbeat: %w", err)
+	}
+	return nil
+}
+
+// recover queues again the jobs of replicas that stopped without finishing
+// them. They go to the back of their class's queue.
+func (s *store) recover() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	instances, err := redis.Strings(s.client.Do(ctx, "SMEMBERS", s.key("instances")))
+	if err != nil {
+		log.Printf("Warning: failed to list job queue replicas: %v", err)
+		return
+	}
+	for _, instance := range instances {
+		if instance == s.instance {
+			continue
+		}
+		alive, err := redis.Int(s.client.Do(ctx, "EXISTS", s.key("alive", instance)))
+		if err != nil || alive == 1 {
+			continue
+		}
+
+		recovered := 0
+		for _, class := range []string{ClassInteractive, ClassBatch} {
+			for err == nil {
+				if _, err = s.client.Do(ctx, "RPOPLPUSH", s.key("processing", class, instance), s.queueKey(class)); err == nil {
+					recovered++
+				}
+			}
+			if !errors.Is(err, redis.Nil) {
+				break
+			}
+			err = nil
+		}
+		if recovered > 0 {
+			log.Printf("Requeued %d unfinished jobs of stopped replica %s", recovered, instance)
+		}
+		if err != nil {
+			log.Printf("Warning: failed to requeue the jobs of stopped replica %s: %v", instance, err)
+			continue
+		}
+		s.client.Do(ctx, "SREM", s.key("instances"), instance)
+	}
+}
+
+// stop makes the workers stop taking jobs from Redis
+func (s *store) stop() {
+	s.stopOnce.Do(func() { close(s.stopping) })
+	s.pullers.Wait()
+}
+
+// leave removes a replica that finished all its jobs, so none are
+// requeued for it
+func (s *store) leave() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.client.Do(ctx, "DEL", s.key("alive", s.instance))
+	s.client.Do(ctx, "SREM", s.key("instances"), s.instance)
+}
+
+// addQueued replaces the queued counts with those of Redis, shared by
+// every replica
+func (s *store) addQueued(result map[string]ClassStats) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for class, load := range result {
+		if queued, err := redis.Int(s.client.Do(ctx, "LLEN", s.queueKey(class))); err == nil {
+			load.Queued = int(queued)
+			result[class] = load
+		}
+	}
+}
+
+func (s *store) queueKey(class string) string {
+	return s.key("queue", class)
+}
+
+func (s *store) processingKey(class string) string {
+	return s.key("processing", class, s.instance)
+}
+
+// key joins the prefix and parts with colons
+func (s *store) key(parts ...string) string {
+	key := s.prefix
+	for _, part := range parts {
+		key += ":" + part
+	}
+	return key
+}
+
+// instanceName identifies this replica: its host name and a random suffix,
+// new on each start
+func instanceName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "gateway"
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return host + "-" + hex.EncodeToString(suffix)
+}
@@ -0,0 +1,224 @@
+// Package jobs runs asynchronous reviews on a worker pool per priority
+// class, so bulk work queued in one class never holds up another. Jobs are
+// queued in memory, or in Redis so they survive restarts and are shared by
+// every replica.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+// Priority classes
+const (
+	ClassInteractive = "interactive" // Checks a developer is waiting on, e.g. blocking PR checks
+	ClassBatch       = "batch"       // Scheduled and bulk reviews, e.g. nightly runs
+)
+
+// ErrQueueFull is returned when a class already has as many jobs waiting
+// as its queue holds
+var ErrQueueFull = errors.New("job queue is full")
+
+// stats counts jobs by class and outcome, e.g. batch.accepted
+var stats = expvar.NewMap("review_jobs")
+
+// Job is the work of one queued job. ctx lets it Yield its worker while
+// it waits.
+type Job func(ctx context.Context)
+
+// pool is the queue and workers of one class
+type pool struct {
+	jobs    chan Job
+	durable chan Job // Jobs taken from Redis, handed to the next free worker
+
+	mu      sync.Mutex
+	queued  int
+	running int
+	waiting int
+	workers int
+}
+
+// ClassStats describes the load of one class
+type ClassStats struct {
+	Workers int `json:"workers"`
+	Queued  int `json:"queued"`  // Waiting for a worker
+	Running int `json:"running"` // Being reviewed
+	Waiting int `json:"waiting"` // Yielded their worker, e.g. while a provider batch runs
+}
+
+// Queue runs jobs on the workers of their class
+type Queue struct {
+	pools     map[string]*pool
+	queueSize int
+	pending   sync.WaitGroup
+	store     *store // nil keeps jobs in memory only
+}
+
+// New starts interactiveWorkers and batchWorkers workers, each class
+// queueing up to queueSize jobs while its workers are busy
+func New(interactiveWorkers, batchWorkers, queueSize int) *Queue {
+	q := &Queue{pools: make(map[string]*pool), queueSize: queueSize}
+	for class, workers := range map[string]int{ClassInteractive: interactiveWorkers, ClassBatch: batchWorkers} {
+		p := &pool{jobs: make(chan Job, queueSize), durable: make(chan Job), workers: workers}
+		q.pools[class] = p
+		for i := 0; i < workers; i++ {
+			go q.work(class, p)
+		}
+	}
+	return q
+}
+
+// ParseClass checks a class name; empty is interactive
+func ParseClass(class string) (string, error) {
+	switch class {
+	case "":
+		return ClassInteractive, nil
+	case ClassInteractive, ClassBatch:
+		return class, nil
+	}
+	return "", fmt.Errorf("invalid priority %q: must be interactive or batch", class)
+}
+
+// Submit queues fn on the workers of class. It never blocks: a full queue
+// returns ErrQueueFull.
+func (q *Queue) Submit(class string, fn Job) error {
+	p, ok := q.pools[class]
+	if !ok {
+		return fmt.Errorf("unknown job class %q", class)
+	}
+
+	q.pending.Add(1)
+	p.mu.Lock()
+	select {
+	case p.jobs <- fn:
+		p.queued++
+		p.mu.Unlock()
+		stats.Add(class+".accepted", 1)
+		return nil
+	default:
+		p.mu.Unlock()
+		q.pending.Done()
+		stats.Add(class+".rejected", 1)
+		return ErrQueueFull
+	}
+}
+
+// work runs the jobs of a class one at a time. A worker whose job yields
+// hands its place to a new worker and stops once the job finishes.
+func (q *Queue) work(class string, p *pool) {
+	for {
+		var fn Job
+		select {
+		case fn = <-p.jobs:
+			p.mu.Lock()
+			p.queued--
+		case fn = <-p.durable:
+			p.mu.Lock()
+		}
+		p.running++
+		p.mu.Unlock()
+
+		w := &worker{queue: q, class: class, pool: p}
+		fn(context.WithValue(context.Background(), workerKey{}, w))
+
+		p.mu.Lock()
+		p.running--
+		p.mu.Unlock()
+		stats.Add(class+".completed", 1)
+		q.pending.Done()
+		if w.yielded {
+			return
+		}
+	}
+}
+
+type workerKey struct{}
+
+// worker is the worker running one job
+type worker struct {
+	queue   *Queue
+	class   string
+	pool    *pool
+	yielded bool
+}
+
+// Yield frees the worker running the job of ctx for other jobs of its
+// class, for a job about to wait a long time without using it, and returns
+// the function to call once the wait is over. The job then finishes
+// alongside the class's workers. Outside a job, or when the job already
+// yielded, it does nothing.
+func Yield(ctx context.Context) (resume func()) {
+	w, _ := ctx.Value(workerKey{}).(*worker)
+	if w == nil || w.yielded {
+		return func() {}
+	}
+	w.yielded = true
+
+	p := w.pool
+	p.mu.Lock()
+	p.running--
+	p.waiting++
+	p.mu.Unlock()
+	go w.queue.work(w.class, p)
+
+	return func() {
+		p.mu.Lock()
+		p.waiting--
+		p.running++
+		p.mu.Unlock()
+	}
+}
+
+// Queued reports whether ctx belongs to a queued job
+func Queued(ctx context.Context) bool {
+	_, ok := ctx.Value(workerKey{}).(*worker)
+	return ok
+}
+
+// WithJob returns parent carrying the worker of job's context, so a job
+// running with values of its own can still Yield
+func WithJob(parent, job context.Context) context.Context {
+	if w, ok := job.Value(workerKey{}).(*worker); ok {
+		return context.WithValue(parent, workerKey{}, w)
+	}
+	return parent
+}
+
+// Stats returns the load of each class
+func (q *Queue) Stats() map[string]ClassStats {
+	result := make(map[string]ClassStats, len(q.pools))
+	for class, p := range q.pools {
+		p.mu.Lock()
+		result[class] = ClassStats{Workers: p.workers, Queued: p.queued, Running: p.running, Waiting: p.waiting}
+		p.mu.Unlock()
+	}
+	if q.store != nil {
+		q.store.addQueued(result)
+	}
+	return result
+}
+
+// Wait blocks until every queued and running job finishes or ctx is done.
+// Jobs still in Redis are left for other replicas, or the next start.
+func (q *Queue) Wait(ctx context.Context) error {
+	if q.store != nil {
+		q.store.stop()
+	}
+	done := make(chan struct{})
+	go func() {
+		q.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		if q.store != nil {
+			q.store.leave()
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,347 @@
+package knowledge
+
+import (
+	"context"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scm"
+)
+
+// Document kinds
+const (
+	KindCode      = "code"
+	KindGuideline = "guideline"
+)
+
+const (
+	chunkLines     = 60    // Lines per chunk
+	chunkOverlap   = 10    // Lines shared with the previous chunk
+	maxChunkBytes  = 4000  // Longer chunks are truncated before embedding
+	embedBatchSize = 64    // Texts per embedding request
+	maxRepoChunks  = 20000 // Upper bound on chunks stored per repository
+	maxQueryBytes  = 8000  // Query text sent for embedding at review time
+)
+
+// Chunk is an embedded slice of an indexed document
+type Chunk struct {
+	Path      string
+	Kind      string
+	StartLine int
+	EndLine   int
+	Text      string
+	Vector    []float32
+}
+
+// repository holds the chunks indexed for one tenant and repository
+type repository struct {
+	Chunks    []Chunk
+	UpdatedAt time.Time
+}
+
+// Store is an in-memory vector store of repository code and guidelines.
+// Each tenant has its own index so one API key cannot influence another's
+// reviews. When a path is set the store is persisted there after every
+// change and reloaded on start.
+type Store struct {
+	mu       sync.RWMutex
+	embedder providers.Embedder
+	model    string
+	path     string
+	repos    map[string]*repository
+}
+
+// NewStore creates a store that embeds text with the given embedder and
+// model, loading previously persisted data from path if it exists
+func NewStore(embedder providers.Embedder, model, path string) (*Store, error) {
+	s := &Store{
+		embedder: embedder,
+		model:    model,
+		path:     path,
+		repos:    make(map[string]*repository),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open knowledge store: %w", err)
+	}
+	defer f.Close()
+
+	if err := gob.NewDecoder(f).Decode(&s.repos); err != nil {
+		return nil, fmt.Errorf("failed to load knowledge store: %w", err)
+	}
+	return s, nil
+}
+
+func key(tenant, repo string) string {
+	return tenant + "|" + scm.NormalizeRepository(repo)
+}
+
+// Index chunks and embeds documents into a repository's index. Documents
+// replace earlier versions of the same path; with replace set the whole
+// repository index is rebuilt. It returns the number of chunks stored.
+func (s *Store) Index(ctx context.Context, tenant, repo string, docs []models.IndexDocument, replace bool) (int, error) {
+	if s == nil {
+		return 0, fmt.Errorf("knowledge store is not configured")
+	}
+
+	var chunks []Chunk
+	for _, doc := range docs {
+		kind := doc.Kind
+		if kind == "" {
+			kind = KindCode
+		}
+		chunks = append(chunks, split(diff.NormalizePath(doc.Path), kind, doc.Content)...)
+	}
+
+	for start := 0; start < len(chunks); start += embedBatchSize {
+		end := min(start+embedBatchSize, len(chunks))
+		texts := make([]string, 0, end-start)
+		for _, c := range chunks[start:end] {
+			texts = append(texts, embeddingText(c))
+		}
+		vectors, err := s.embedder.Embed(ctx, s.model, texts)
+		if err != nil {
+			return 0, err
+		}
+		for i, v := range vectors {
+			chunks[start+i].Vector = v
+		}
+	}
+
+	paths := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		paths[diff.NormalizePath(doc.Path)] = true
+	}
+
+	s.mu.Lock()
+	k := key(tenant, repo)
+	// The current index is left untouched until the update is known to fit,
+	// since searches may still be reading it
+	var kept []Chunk
+	if r := s.repos[k]; r != nil && !replace {
+		for _, c := range r.Chunks {
+			if !paths[c.Path] {
+				kept = append(kept, c)
+			}
+		}
+	}
+	if len(kept)+len(chunks) > maxRepoChunks {
+		s.mu.Unlock()
+		return 0, fmt.Errorf("repository index would exceed %d chunks", maxRepoChunks)
+	}
+	r := &repository{Chunks: append(kept, chunks...), UpdatedAt: time.Now()}
+	s.repos[k] = r
+	total := len(r.Chunks)
+	s.mu.Unlock()
+
+	return total, s.save()
+}
+
+// Search returns up to k chunks of a repository most similar to the query,
+// skipping chunks from excluded paths and stopping at maxBytes of content.
+// A nil store or an unindexed repository returns no results.
+func (s *Store) Search(ctx context.Context, tenant, repo, query string, k, maxBytes int, exclude map[string]bool) ([]models.ContextSnippet, error) {
+	if s == nil || k <= 0 {
+		return nil, nil
+	}
+
+	s.mu.RLock()
+	r := s.repos[key(tenant, repo)]
+	empty := r == nil || len(r.Chunks) == 0
+	s.mu.RUnlock()
+	if empty {
+		return nil, nil
+	}
+
+	if len(query) > maxQueryBytes {
+		query = query[:maxQueryBytes]
+	}
+	vectors, err := s.embedder.Embed(ctx, s.model, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	queryVector := vectors[0]
+
+	type scored struct {
+		chunk Chunk
+		score float64
+	}
+	var candidates []scored
+
+	s.mu.RLock()
+	for _, c := range r.Chunks {
+		if exclude[c.Path] {
+			continue
+		}
+		candidates = append(candidates, scored{chunk: c, score: cosine(queryVector, c.Vector)})
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	var snippets []models.ContextSnippet
+	remaining := maxBytes
+	for _, c := range candidates {
+		if len(snippets) == k {
+			break
+		}
+		if c.score <= 0 || len(c.chunk.Text) > remaining {
+			continue
+		}
+		remaining -= len(c.chunk.Text)
+		snippets = append(snippets, models.ContextSnippet{
+			Path:      c.chunk.Path,
+			Kind:      c.chunk.Kind,
+			StartLine: c.chunk.StartLine,
+			EndLine:   c.chunk.EndLine,
+			Content:   c.chunk.Text,
+			Score:     c.score,
+		})
+	}
+	return snippets, nil
+}
+
+// Delete removes a repository's index
+func (s *Store) Delete(tenant, repo string) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+
+	s.mu.Lock()
+	k := key(tenant, repo)
+	_, ok := s.repos[k]
+	delete(s.repos, k)
+	s.mu.Unlock()
+
+	if !ok {
+		return false, nil
+	}
+	return true, s.save()
+}
+
+// Stats lists the repositories indexed for a tenant
+func (s *Store) Stats(tenant string) []models.IndexStats {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefix := tenant + "|"
+	stats := []models.IndexStats{}
+	for k, r := range s.repos {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		files := make(map[string]bool)
+		for _, c := range r.Chunks {
+			files[c.Path] = true
+		}
+		stats = append(stats, models.IndexStats{
+			Repository: strings.TrimPrefix(k, prefix),
+			Documents:  len(files),
+			Chunks:     len(r.Chunks),
+			UpdatedAt:  r.UpdatedAt,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Repository < stats[j].Repository })
+	return stats
+}
+
+// save writes the store to disk atomically
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".knowledge-*")
+	if err != nil {
+		return fmt.Errorf("failed to save knowledge store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(s.repos); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save knowledge store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save knowledge store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save knowledge store: %w", err)
+	}
+	return nil
+}
+
+// split cuts a document into overlapping line-based chunks
+func split(path, kind, content string) []Chunk {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	var chunks []Chunk
+	for start := 0; start < len(lines); start += chunkLines - chunkOverlap {
+		end := min(start+chunkLines, len(lines))
+		text := strings.Join(lines[start:end], "\n")
+		if len(text) > maxChunkBytes {
+			text = text[:maxChunkBytes]
+		}
+		if strings.TrimSpace(text) != "" {
+			chunks = append(chunks, Chunk{
+				Path:      path,
+				Kind:      kind,
+				StartLine: start + 1,
+				EndLine:   end,
+				Text:      text,
+			})
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
+
+// embeddingText prefixes a chunk with its path, which carries a lot of
+// meaning for code search
+func embeddingText(c Chunk) string {
+	return c.Path + "\n" + c.Text
+}
+
+// cosine returns the cosine similarity of two vectors, or 0 if they are
+// incomparable (for example after the embedding model changed)
+func cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,238 @@
+// Package language detects the languages of changed files, for requests that
+// don't name one
+package language
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+)
+
+// Unknown is the language of requests that name none and whose files
+// aren't recognized
+const Unknown = "unknown"
+
+// kind tells programming languages from data and markup formats, which
+// only set the language of a review when nothing else changed
+type kind int
+
+const (
+	code kind = iota
+	data
+)
+
+type entry struct {
+	name string
+	kind kind
+}
+
+// byExtension maps lower-case file extensions to languages
+var byExtension = map[string]entry{
+	".go": {"Go", code}, ".ts": {"TypeScript", code}, ".tsx": {"TypeScript", code}, ".mts": {"TypeScript", code},
+	".js": {"JavaScript", code}, ".jsx": {"JavaScript", code}, ".mjs": {"JavaScript", code}, ".cjs": {"JavaScript", code},
+	".py": {"Python", code}, ".pyi": {"Python", code}, ".java": {"Java", code}, ".kt": {"Kotlin", code}, ".kts": {"Kotlin", code},
+	".scala": {"Scala", code}, ".groovy": {"Groovy", code}, ".gradle": {"Groovy", code}, ".rb": {"Ruby", code}, ".php": {"PHP", code},
+	".rs": {"Rust", code}, ".cs": {"C#", code}, ".fs": {"F#", code}, ".c": {"C", code}, ".h": {"C", code},
+	".cc": {"C++", code}, ".cpp": {"C++", code}, ".cxx": {"C++", code}, ".hpp": {"C++", code}, ".hh": {"C++", code},
+	".m": {"Objective-C", code}, ".mm": {"Objective-C", code}, ".swift": {"Swift", code}, ".dart": {"Dart", code},
+	".ex": {"Elixir", code}, ".exs": {"Elixir", code}, ".erl": {"Erlang", code}, ".hs": {"Haskell", code}, ".clj": {"Clojure", code},
+	".lua": {"Lua", code}, ".r": {"R", code}, ".pl": {"Perl", code}, ".pm": {"Perl", code}, ".jl": {"Julia", code},
+	".sql": {"SQL", code}, ".sh": {"Shell", code}, ".bash": {"Shell", code}, ".zsh": {"Shell", code}, ".ps1": {"PowerShell", code},
+	".vue": {"Vue", code}, ".svelte": {"Svelte", code}, ".tf": {"Terraform", code}, ".hcl": {"HCL", code}, ".sol": {"Solidity", code},
+	".html": {"HTML", data}, ".htm": {"HTML", data}, ".css": {"CSS", data}, ".scss": {"SCSS", data}, ".less": {"Less", data},
+	".json": {"JSON", data}, ".yaml": {"YAML", data}, ".yml": {"YAML", data}, ".toml": {"TOML", data}, ".xml": {"XML", data},
+	".proto": {"Protocol Buffers", data}, ".graphql": {"GraphQL", data}, ".md": {"Markdown", data}, ".rst": {"reStructuredText", data},
+}
+
+// byName maps well-known file names without a telling extension
+var byName = map[string]entry{
+	"dockerfile": {"Dockerfile", code}, "containerfile": {"Dockerfile", code}, "makefile": {"Makefile", code},
+	"gnumakefile": {"Makefile", code}, "rakefile": {"Ruby", code}, "gemfile": {"Ruby", code}, "jenkinsfile": {"Groovy", code},
+	"go.mod": {"Go", data}, "package.json": {"JSON", data},
+}
+
+// byInterpreter maps shebang interpreters to languages
+var byInterpreter = map[string]entry{
+	"sh": {"Shell", code}, "bash": {"Shell", code}, "zsh": {"Shell", code}, "dash": {"Shell", code},
+	"python": {"Python", code}, "python3": {"Python", code}, "node": {"JavaScript", code}, "deno": {"TypeScript", code},
+	"ruby": {"Ruby", code}, "perl": {"Perl", code}, "php": {"PHP", code}, "pwsh": {"PowerShell", code},
+}
+
+// IsUnknown reports whether a requested language leaves detection to the
+// gateway
+func IsUnknown(language string) bool {
+	language = strings.TrimSpace(language)
+	return language == "" || strings.EqualFold(language, Unknown)
+}
+
+// Of returns the language of a file, from its name or, for scripts, the
+// shebang on its first line; empty when unrecognized
+func Of(filePath, firstLine string) string {
+	e, ok := lookup(filePath, firstLine)
+	if !ok {
+		return ""
+	}
+	return e.name
+}
+
+func lookup(filePath, firstLine string) (entry, bool) {
+	base := strings.ToLower(path.Base(filePath))
+	if e, ok := byName[base]; ok {
+		return e, true
+	}
+	if strings.HasPrefix(base, "dockerfile.") {
+		return byName["dockerfile"], true
+	}
+	if e, ok := byExtension[path.Ext(base)]; ok {
+		return e, true
+	}
+	if strings.HasPrefix(firstLine, "#!") {
+		fields := strings.Fields(strings.TrimPrefix(firstLine, "#!"))
+		if len(fields) > 0 {
+			interpreter := path.Base(fields[0])
+			if interpreter == "env" {
+				// #!/usr/bin/env [-S] python3
+				for _, f := range fields[1:] {
+					if !strings.HasPrefix(f, "-") {
+						interpreter = f
+						break
+					}
+				}
+			}
+			if e, ok := byInterpreter[interpreter]; ok {
+				return e, true
+			}
+			if e, ok := byInterpreter[strings.TrimRight(interpreter, "0123456789.")]; ok {
+				return e, true
+			}
+		}
+	}
+	return entry{}, false
+}
+
+// OfFile returns the language of a changed file; empty when unrecognized
+func OfFile(f *diff.File) string {
+	return Of(f.Path(), firstLine(f))
+}
+
+// firstLine returns the first line of the new file when the diff shows it
+func firstLine(f *diff.File) string {
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			if l.NewLine == 1 {
+				return l.Content
+			}
+		}
+	}
+	return ""
+}
+
+// Detect returns the languages of the changed files, the most changed
+// first. Data and markup formats are left out unless nothing else changed.
+func Detect(files []*diff.File) []string {
+	changed := map[kind]map[string]int{code: {}, data: {}}
+	for _, f := range files {
+		if f.IsBinary {
+			continue
+		}
+		e, ok := lookup(f.Path(), firstLine(f))
+		if !ok {
+			continue
+		}
+		changed[e.kind][e.name] += changedLines(f)
+	}
+
+	counts := changed[code]
+	if len(counts) == 0 {
+		counts = changed[data]
+	}
+	return byChanges(counts)
+}
+
+// Group is a set of changed files reviewed together, in one or more
+// related languages
+type Group struct {
+	Languages []string
+	Paths     []string
+}
+
+// Groups splits a diff's files by programming language, so each language
+// can be reviewed with its own prompt. Data formats, markup and unknown
+// files join the most changed language. When there are more than limit
+// languages, the least changed ones share the last group. Groups returns
+// nil when fewer than two programming languages changed.
+func Groups(files []*diff.File, limit int) []Group {
+	counts := make(map[string]int)
+	paths := make(map[string][]string)
+	var other []string
+	for _, f := range files {
+		e, ok := lookup(f.Path(), firstLine(f))
+		if !ok || e.kind != code || f.IsBinary {
+			other = append(other, f.Path())
+			continue
+		}
+		counts[e.name] += changedLines(f)
+		paths[e.name] = append(paths[e.name], f.Path())
+	}
+	if len(counts) < 2 || limit < 2 {
+		return nil
+	}
+
+	languages := byChanges(counts)
+	groups := make([]Group, 0, limit)
+	for i, name := range languages {
+		if i < limit {
+			groups = append(groups, Group{Languages: []string{name}, Paths: paths[name]})
+			continue
+		}
+		last := &groups[limit-1]
+		last.Languages = append(last.Languages, name)
+		last.Paths = append(last.Paths, paths[name]...)
+	}
+	groups[0].Paths = append(groups[0].Paths, other...)
+	return groups
+}
+
+// changedLines weighs a file by its added and removed lines, counting the
+// file itself so renames and mode changes weigh something
+func changedLines(f *diff.File) int {
+	lines := 1
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			if l.Kind != diff.LineContext {
+				lines++
+			}
+		}
+	}
+	return lines
+}
+
+// byChanges orders languages by their changed lines, most first
+func byChanges(counts map[string]int) []string {
+	languages := make([]string, 0, len(counts))
+	for name := range counts {
+		languages = append(languages, name)
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if counts[languages[i]] != counts[languages[j]] {
+			return counts[languages[i]] > counts[languages[j]]
+		}
+		return languages[i] < languages[j]
+	})
+	return languages
+}
+
+// Describe names languages in a prompt: "Go", "Go and SQL" or "Go,
+// TypeScript and SQL"
+func Describe(languages []string) string {
+	switch len(languages) {
+	case 0:
+		return Unknown
+	case 1:
+		return languages[0]
+	default:
+		return strings.Join(languages[:len(languages)-1], ", ") + " and " + languages[len(languages)-1]
+	}
+}
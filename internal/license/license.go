@@ -0,0 +1,135 @@
+// Package license checks that new files start with the license or copyright
+// header a repository requires. The check is deterministic and runs before
+// the model is called; its findings are merged with the model's.
+package license
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/glob"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// Category is the diagnostic code of missing headers
+const Category = "license-header"
+
+// DefaultLines is how many leading lines are searched for the header
+const DefaultLines = 20
+
+// DefaultPaths are the files checked when a configuration names none:
+// source files, where license headers are customary
+var DefaultPaths = []string{
+	"*.go", "*.js", "*.jsx", "*.mjs", "*.ts", "*.tsx", "*.py", "*.java", "*.kt", "*.scala",
+	"*.c", "*.h", "*.cc", "*.cpp", "*.hpp", "*.cs", "*.rb", "*.php", "*.rs", "*.swift", "*.sh",
+}
+
+// Validate checks a license header configuration
+func Validate(cfg *models.LicenseHeaderConfig) error {
+	if strings.TrimSpace(cfg.Header) == "" && cfg.Pattern == "" {
+		return fmt.Errorf("license_header needs a header or a pattern")
+	}
+	if cfg.Pattern != "" {
+		if _, err := regexp.Compile(cfg.Pattern); err != nil {
+			return fmt.Errorf("invalid license_header pattern: %w", err)
+		}
+	}
+	switch strings.ToUpper(cfg.Severity) {
+	case "", "INFO", "WARNING", "ERROR":
+	default:
+		return fmt.Errorf("invalid license_header severity %q: must be INFO, WARNING or ERROR", cfg.Severity)
+	}
+	if cfg.Lines < 0 {
+		return fmt.Errorf("license_header lines must not be negative")
+	}
+	return nil
+}
+
+// Check reports the files a diff adds without the required header. Only
+// new files are checked: existing files are the repository's business, and
+// headers are rarely touched by later changes. When the configuration has a
+// literal header, each finding carries a fix inserting it.
+func Check(files []*diff.File, cfg *models.LicenseHeaderConfig) []models.Diagnostic {
+	if cfg == nil || Validate(cfg) != nil {
+		return nil
+	}
+	var pattern *regexp.Regexp
+	if cfg.Pattern != "" {
+		pattern = regexp.MustCompile(cfg.Pattern)
+	}
+	paths := cfg.Paths
+	if len(paths) == 0 {
+		paths = DefaultPaths
+	}
+	limit := cfg.Lines
+	if limit == 0 {
+		limit = DefaultLines
+	}
+	severity := strings.ToUpper(cfg.Severity)
+	if severity == "" {
+		severity = "WARNING"
+	}
+	header := strings.TrimRight(strings.ReplaceAll(cfg.Header, "\r\n", "\n"), "\n")
+
+	var diagnostics []models.Diagnostic
+	for _, f := range files {
+		if !f.IsNew || f.IsBinary || len(f.Hunks) == 0 || !glob.MatchAny(paths, f.Path()) {
+			continue
+		}
+		lines := leadingLines(f, limit)
+		if len(lines) == 0 {
+			continue
+		}
+		text := strings.Join(lines, "\n")
+		if pattern != nil && pattern.MatchString(text) {
+			continue
+		}
+		if pattern == nil && strings.Contains(collapse(text), collapse(header)) {
+			continue
+		}
+
+		d := models.Diagnostic{
+			Message: "New file is missing the required license header",
+			Location: models.Location{
+				Path: f.Path(),
+				Range: models.Range{
+					Start: models.Position{Line: 1, Column: 1},
+					End:   models.Position{Line: 1, Column: 2},
+				},
+			},
+			Severity: severity,
+			Code:     models.Code{Value: Category},
+		}
+		if header != "" {
+			d.Suggestion = "Start the file with the license header:\n" + header
+			d.Fix = &models.Fix{StartLine: 1, EndLine: 1, Replacement: header + "\n\n" + lines[0]}
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics
+}
+
+// leadingLines returns up to limit lines from the start of a new file
+func leadingLines(f *diff.File, limit int) []string {
+	var lines []string
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			if l.Kind != diff.LineAdded {
+				continue
+			}
+			if l.NewLine > limit {
+				return lines
+			}
+			lines = append(lines, l.Content)
+		}
+	}
+	return lines
+}
+
+// collapse joins runs of whitespace so a header matches regardless of
+// wrapping and indentation
+func collapse(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,373 @@
+// Package linter reads static-analysis reports uploaded with a review, so
+// their findings can be merged with the model's. Reports are produced by
+// the caller's CI; the gateway never runs linters itself.
+package linter
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// Report formats
+const (
+	FormatSARIF        = "sarif"         // SARIF 2.1.0, from CodeQL, Semgrep, golangci-lint --out-format sarif, ...
+	FormatRDJSON       = "rdjson"        // reviewdog diagnostic format
+	FormatRDJSONL      = "rdjsonl"       // reviewdog diagnostics, one per line
+	FormatCheckstyle   = "checkstyle"    // Checkstyle XML, written by most linters
+	FormatGolangciLint = "golangci-lint" // golangci-lint --out-format json
+	FormatESLint       = "eslint"        // eslint --format json
+)
+
+// Formats lists the supported report formats
+var Formats = []string{FormatSARIF, FormatRDJSON, FormatRDJSONL, FormatCheckstyle, FormatGolangciLint, FormatESLint}
+
+// Parse reads the findings of a report. An empty format is detected from
+// the content, and an empty tool name is taken from the report or format.
+// Every finding has Sources set to the tool name.
+func Parse(report models.LinterReport) ([]models.Diagnostic, error) {
+	format := strings.ToLower(strings.TrimSpace(report.Format))
+	content := []byte(strings.TrimSpace(report.Content))
+	if len(content) == 0 {
+		return nil, nil
+	}
+	if format == "" {
+		format = detect(content)
+		if format == "" {
+			return nil, fmt.Errorf("unrecognized report format; set format to one of %s", strings.Join(Formats, ", "))
+		}
+	}
+
+	var diagnostics []models.Diagnostic
+	var tool string
+	var err error
+	switch format {
+	case FormatSARIF:
+		diagnostics, tool, err = parseSARIF(content)
+	case FormatRDJSON:
+		diagnostics, tool, err = parseRDJSON(content)
+	case FormatRDJSONL:
+		diagnostics, err = parseRDJSONL(content)
+	case FormatCheckstyle:
+		diagnostics, err = parseCheckstyle(content)
+	case FormatGolangciLint:
+		diagnostics, err = parseGolangciLint(content)
+	case FormatESLint:
+		diagnostics, err = parseESLint(content)
+	default:
+		return nil, fmt.Errorf("unsupported report format %q; use one of %s", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s report: %w", format, err)
+	}
+
+	if report.Tool != "" {
+		tool = report.Tool
+	}
+	if tool == "" {
+		tool = format
+	}
+	for i := range diagnostics {
+		diagnostics[i].Sources = []string{tool}
+		diagnostics[i].Severity = normalizeSeverity(diagnostics[i].Severity)
+		if diagnostics[i].Location.Range.Start.Column == 0 {
+			diagnostics[i].Location.Range.Start.Column = 1
+		}
+		if diagnostics[i].Location.Range.End.Line == 0 {
+			diagnostics[i].Location.Range.End = diagnostics[i].Location.Range.Start
+			diagnostics[i].Location.Range.End.Column++
+		}
+	}
+	return diagnostics, nil
+}
+
+// detect guesses a report's format from its content
+func detect(content []byte) string {
+	switch content[0] {
+	case '<':
+		if bytes.Contains(content, []byte("<checkstyle")) {
+			return FormatCheckstyle
+		}
+	case '[':
+		if bytes.Contains(content, []byte(`"filePath"`)) {
+			return FormatESLint
+		}
+	case '{':
+		var probe map[string]json.RawMessage
+		if json.Unmarshal(content, &probe) != nil {
+			if bytes.Contains(content, []byte(`"location"`)) {
+				return FormatRDJSONL
+			}
+			return ""
+		}
+		switch {
+		case probe["runs"] != nil:
+			return FormatSARIF
+		case probe["Issues"] != nil:
+			return FormatGolangciLint
+		case probe["diagnostics"] != nil:
+			return FormatRDJSON
+		case probe["location"] != nil:
+			return FormatRDJSONL
+		}
+	}
+	return ""
+}
+
+func parseSARIF(content []byte) ([]models.Diagnostic, string, error) {
+	var log struct {
+		Runs []struct {
+			Tool struct {
+				Driver struct {
+					Name  string `json:"name"`
+					Rules []struct {
+						ID      string `json:"id"`
+						HelpURI string `json:"helpUri"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID  string `json:"ruleId"`
+				Level   string `json:"level"`
+				Message struct {
+					Text string `json:"text"`
+				} `json:"message"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine   int `json:"startLine"`
+							StartColumn int `json:"startColumn"`
+							EndLine     int `json:"endLine"`
+							EndColumn   int `json:"endColumn"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(content, &log); err != nil {
+		return nil, "", err
+	}
+
+	var diagnostics []models.Diagnostic
+	tool := ""
+	for _, run := range log.Runs {
+		if tool == "" {
+			tool = run.Tool.Driver.Name
+		}
+		help := make(map[string]string)
+		for _, rule := range run.Tool.Driver.Rules {
+			help[rule.ID] = rule.HelpURI
+		}
+		for _, result := range run.Results {
+			if len(result.Locations) == 0 {
+				continue
+			}
+			loc := result.Locations[0].PhysicalLocation
+			region := loc.Region
+			diagnostics = append(diagnostics, models.Diagnostic{
+				Message: result.Message.Text,
+				Location: models.Location{
+					Path: loc.ArtifactLocation.URI,
+					Range: models.Range{
+						Start: models.Position{Line: region.StartLine, Column: region.StartColumn},
+						End:   models.Position{Line: region.EndLine, Column: region.EndColumn},
+					},
+				},
+				Severity: result.Level, // The default, warning, is also normalizeSeverity's
+				Code:     models.Code{Value: result.RuleID, URL: help[result.RuleID]},
+			})
+		}
+	}
+	return diagnostics, tool, nil
+}
+
+func parseRDJSON(content []byte) ([]models.Diagnostic, string, error) {
+	var result struct {
+		Source      models.Source       `json:"source"`
+		Diagnostics []models.Diagnostic `json:"diagnostics"`
+	}
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, "", err
+	}
+	return cleanRDJSON(result.Diagnostics), result.Source.Name, nil
+}
+
+func parseRDJSONL(content []byte) ([]models.Diagnostic, error) {
+	var diagnostics []models.Diagnostic
+	for n, line := range bytes.Split(content, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var d models.Diagnostic
+		if err := json.Unmarshal(line, &d); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return cleanRDJSON(diagnostics), nil
+}
+
+// cleanRDJSON drops fields of uploaded reviewdog diagnostics that only the
+// gateway sets
+func cleanRDJSON(diagnostics []models.Diagnostic) []models.Diagnostic {
+	for i := range diagnostics {
+		diagnostics[i].Providers = nil
+		diagnostics[i].Fingerprint = ""
+	}
+	return diagnostics
+}
+
+func parseCheckstyle(content []byte) ([]models.Diagnostic, error) {
+	var report struct {
+		Files []struct {
+			Name   string `xml:"name,attr"`
+			Errors []struct {
+				Line     int    `xml:"line,attr"`
+				Column   int    `xml:"column,attr"`
+				Severity string `xml:"severity,attr"`
+				Message  string `xml:"message,attr"`
+				Source   string `xml:"source,attr"`
+			} `xml:"error"`
+		} `xml:"file"`
+	}
+	if err := xml.Unmarshal(content, &report); err != nil {
+		return nil, err
+	}
+
+	var diagnostics []models.Diagnostic
+	for _, f := range report.Files {
+		for _, e := range f.Errors {
+			diagnostics = append(diagnostics, models.Diagnostic{
+				Message: e.Message,
+				Location: models.Location{
+					Path:  f.Name,
+					Range: models.Range{Start: models.Position{Line: e.Line, Column: e.Column}},
+				},
+				Severity: e.Severity,
+				Code:     models.Code{Value: e.Source},
+			})
+		}
+	}
+	return diagnostics, nil
+}
+
+func parseGolangciLint(content []byte) ([]models.Diagnostic, error) {
+	var report struct {
+		Issues []struct {
+			FromLinter string `json:"FromLinter"`
+			Text       string `json:"Text"`
+			Severity   string `json:"Severity"`
+			Pos        struct {
+				Filename string `json:"Filename"`
+				Line     int    `json:"Line"`
+				Column   int    `json:"Column"`
+			} `json:"Pos"`
+		} `json:"Issues"`
+	}
+	if err := json.Unmarshal(content, &report); err != nil {
+		return nil, err
+	}
+
+	diagnostics := make([]models.Diagnostic, 0, len(report.Issues))
+	for _, issue := range report.Issues {
+		diagnostics = append(diagnostics, models.Diagnostic{
+			Message: issue.Text,
+			Location: models.Location{
+				Path:  issue.Pos.Filename,
+				Range: models.Range{Start: models.Position{Line: issue.Pos.Line, Column: issue.Pos.Column}},
+			},
+			Severity: issue.Severity,
+			Code:     models.Code{Value: issue.FromLinter},
+		})
+	}
+	return diagnostics, nil
+}
+
+func parseESLint(content []byte) ([]models.Diagnostic, error) {
+	var report []struct {
+		FilePath string `json:"filePath"`
+		Messages []struct {
+			RuleID    string `json:"ruleId"`
+			Severity  int    `json:"severity"`
+			Message   string `json:"message"`
+			Line      int    `json:"line"`
+			Column    int    `json:"column"`
+			EndLine   int    `json:"endLine"`
+			EndColumn int    `json:"endColumn"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(content, &report); err != nil {
+		return nil, err
+	}
+
+	var diagnostics []models.Diagnostic
+	for _, f := range report {
+		for _, m := range f.Messages {
+			severity := "WARNING"
+			if m.Severity == 2 {
+				severity = "ERROR"
+			}
+			diagnostics = append(diagnostics, models.Diagnostic{
+				Message: m.Message,
+				Location: models.Location{
+					Path: f.FilePath,
+					Range: models.Range{
+						Start: models.Position{Line: m.Line, Column: m.Column},
+						End:   models.Position{Line: m.EndLine, Column: m.EndColumn},
+					},
+				},
+				Severity: severity,
+				Code:     models.Code{Value: m.RuleID},
+			})
+		}
+	}
+	return diagnostics, nil
+}
+
+// normalizeSeverity maps linter severities to ERROR, WARNING or INFO
+func normalizeSeverity(severity string) string {
+	switch strings.ToLower(strings.TrimSpace(severity)) {
+	case "error", "critical", "blocker", "high", "fatal":
+		return "ERROR"
+	case "info", "note", "hint", "suggestion", "low", "none":
+		return "INFO"
+	default:
+		return "WARNING"
+	}
+}
+
+// ResolvePaths rewrites the paths in findings to the diff's paths. Linters
+// often report absolute paths or paths relative to another directory, so a
+// finding is matched to the changed file its path ends with.
+func ResolvePaths(diagnostics []models.Diagnostic, files []*diff.File) {
+	changed := make(map[string]bool, len(files))
+	for _, f := range files {
+		changed[f.Path()] = true
+	}
+	for i := range diagnostics {
+		p := strings.TrimPrefix(diagnostics[i].Location.Path, "file://")
+		p = diff.NormalizePath(path.Clean(strings.ReplaceAll(p, "\\", "/")))
+		if !changed[p] {
+			best := ""
+			for c := range changed {
+				if strings.HasSuffix(p, "/"+c) && len(c) > len(best) {
+					best = c
+				}
+			}
+			if best != "" {
+				p = best
+			}
+		}
+		diagnostics[i].Location.Path = p
+	}
+}
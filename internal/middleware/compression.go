@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// minCompressSize is the response size below which gzip costs more than it
+// saves
+const minCompressSize = 1024
+
+var gzipWriters = sync.Pool{New: func() interface{} { return gzip.NewWriter(io.Discard) }}
+
+// Compression middleware decompresses request bodies sent with
+// "Content-Encoding: gzip" and, when compressResponses is set, gzips
+// responses for clients sending "Accept-Encoding: gzip". Bodies in other
+// encodings are rejected with 415. Body limits applied further in count
+// decompressed bytes. Responses under minCompressSize, already encoded
+// or of media types that don't compress, such as images, are sent as they
+// are.
+func Compression(next http.Handler, compressResponses bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			body, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, `{"error":"Request body is not valid gzip"}`, http.StatusBadRequest)
+				return
+			}
+			r.Body = &gzipBody{Reader: body, body: r.Body}
+			r.Header.Del("Content-Encoding")
+			r.ContentLength = -1
+		default:
+			w.Header().Set("Accept-Encoding", "gzip")
+			http.Error(w, `{"error":"Unsupported Content-Encoding: use gzip"}`, http.StatusUnsupportedMediaType)
+			return
+		}
+
+		if !compressResponses {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipWriter{ResponseWriter: w}
+		next.ServeHTTP(gw, r)
+		gw.close()
+	})
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, entry := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "x-gzip" && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipBody closes both the gzip reader and the request body it reads
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
+
+// gzipWriter holds back the start of a response until it knows whether
+// the response is worth compressing
+type gzipWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool         // The handler set the status
+	decided     bool         // The status was sent, compressed or not
+	gz          *gzip.Writer // Set when compressing
+	buffer      []byte
+}
+
+func (gw *gzipWriter) WriteHeader(code int) {
+	if code < 200 {
+		gw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if gw.wroteHeader {
+		return
+	}
+	gw.statusCode = code
+	gw.wroteHeader = true
+
+	header := gw.Header()
+	switch {
+	case code == http.StatusNoContent || code == http.StatusNotModified,
+		header.Get("Content-Encoding") != "",
+		!compressible(header.Get("Content-Type")):
+		gw.start(false)
+	default:
+		if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil {
+			gw.start(length >= minCompressSize)
+		}
+	}
+}
+
+func (gw *gzipWriter) Write(b []byte) (int, error) {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+	if !gw.decided {
+		gw.buffer = append(gw.buffer, b...)
+		if len(gw.buffer) >= minCompressSize {
+			if err := gw.start(true); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	}
+	if gw.gz != nil {
+		return gw.gz.Write(b)
+	}
+	return gw.ResponseWriter.Write(b)
+}
+
+// Flush sends what has been written so far. A response flushed before its
+// size is known is streamed, so it is compressed.
+func (gw *gzipWriter) Flush() {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+	if !gw.decided {
+		gw.start(true)
+	}
+	if gw.gz != nil {
+		gw.gz.Flush()
+	}
+	http.NewResponseController(gw.ResponseWriter).Flush()
+}
+
+func (gw *gzipWriter) Unwrap() http.ResponseWriter {
+	return gw.ResponseWriter
+}
+
+// start sends the status, compressed or not, followed by the buffered body
+func (gw *gzipWriter) start(compress bool) error {
+	gw.decided = true
+	header := gw.Header()
+	if compress {
+		if header.Get("Content-Type") == "" {
+			// The server can't sniff the type of a compressed body
+			header.Set("Content-Type", http.DetectContentType(gw.buffer))
+		}
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", "gzip")
+		gw.gz = gzipWriters.Get().(*gzip.Writer)
+		gw.gz.Reset(gw.ResponseWriter)
+	}
+	gw.ResponseWriter.WriteHeader(gw.statusCode)
+
+	buffered := gw.buffer
+	gw.buffer = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+	var err error
+	if gw.gz != nil {
+		_, err = gw.gz.Write(buffered)
+	} else {
+		_, err = gw.ResponseWriter.Write(buffered)
+	}
+	return err
+}
+
+// close finishes the response once the handler returns
+func (gw *gzipWriter) close() {
+	if !gw.wroteHeader {
+		return
+	}
+	if !gw.decided {
+		gw.start(false)
+	}
+	if gw.gz != nil {
+		gw.gz.Close()
+		gzipWriters.Put(gw.gz)
+		gw.gz = nil
+	}
+}
+
+// compressible reports whether responses of a media type are worth
+// compressing; an unset type is sniffed when the response starts
+func compressible(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	for _, suffix := range []string{"json", "jsonl", "javascript", "xml", "yaml"} {
+		if strings.HasSuffix(mediaType, suffix) {
+			return true
+		}
+	}
+	return false
+}
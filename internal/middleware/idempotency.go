@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// idempotentResponse is a cached response for an idempotency key
+type idempotentResponse struct {
+	done        bool
+	statusCode  int
+	contentType string
+	body        []byte
+	expires     time.Time
+}
+
+// IdempotencyCache remembers responses to POST requests carrying an
+// Idempotency-Key header so client retries don't trigger duplicate reviews
+type IdempotencyCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*idempotentResponse
+}
+
+// NewIdempotencyCache creates a cache that keeps responses for ttl
+func NewIdempotencyCache(ttl time.Duration) *IdempotencyCache {
+	return &IdempotencyCache{
+		ttl:     ttl,
+		entries: make(map[string]*idempotentResponse),
+	}
+}
+
+// Idempotency middleware replays the cached response for a repeated
+// Idempotency-Key. Keys are scoped to the authenticated client.
+func Idempotency(next http.Handler, cache *IdempotencyCache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key = ClientID(r.Context()) + "|" + r.URL.Path + "|" + key
+
+		cache.mu.Lock()
+		cache.evictExpired()
+		if entry, ok := cache.entries[key]; ok {
+			cache.mu.Unlock()
+			if !entry.done {
+				http.Error(w, `{"error":"A request with this Idempotency-Key is already in progress"}`, http.StatusConflict)
+				return
+			}
+			w.Header().Set("Content-Type", entry.contentType)
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(entry.statusCode)
+			w.Write(entry.body)
+			return
+		}
+		entry := &idempotentResponse{}
+		cache.entries[key] = entry
+		cache.mu.Unlock()
+
+		recorder := &recordingWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		// Only successful responses are replayed; failures, including
+		// handlers that panic, leave the key free to be retried
+		defer func() {
+			cache.mu.Lock()
+			defer cache.mu.Unlock()
+			if !entry.done {
+				delete(cache.entries, key)
+			}
+		}()
+		next.ServeHTTP(recorder, r)
+
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		if recorder.statusCode >= 400 {
+			return
+		}
+		entry.done = true
+		entry.statusCode = recorder.statusCode
+		entry.contentType = recorder.Header().Get("Content-Type")
+		entry.body = recorder.body.Bytes()
+		entry.expires = time.Now().Add(cache.ttl)
+	})
+}
+
+// evictExpired removes completed entries past their TTL; callers hold mu
+func (c *IdempotencyCache) evictExpired() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		if entry.done && now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// recordingWriter captures the status code and body written to a response
+type recordingWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
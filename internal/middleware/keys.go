@@ -0,0 +1,199 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/quota"
+)
+
+const apiKeyContextKey contextKey = "api_key"
+
+// Key is a client credential. Only a salted hash of the secret is kept, so
+// neither the key store file nor a memory dump reveals it.
+type Key struct {
+	ID      string       `json:"id"`
+	Name    string       `json:"name"`
+	Owner   string       `json:"owner,omitempty"`
+	Created time.Time    `json:"created"`
+	Scopes  []string     `json:"scopes,omitempty"` // Endpoints the key may call; empty allows all
+	Models  []string     `json:"models,omitempty"` // provider/model patterns the key may use; empty allows all
+	Tenant  string       `json:"tenant,omitempty"` // Tenant whose providers and limits the key uses
+	Quota   *quota.Limit `json:"quota,omitempty"`  // Monthly usage allowed for the key
+	Tier    string       `json:"tier,omitempty"`   // Rate-limit tier; empty uses DEFAULT_RATE_LIMIT_TIER
+	Salt    string       `json:"salt"`
+	Hash    string       `json:"hash"` // Hex SHA-256 of salt and secret
+
+	LastUsed *time.Time `json:"last_used,omitempty"` // As read from the store file
+	used     atomic.Int64
+}
+
+// NewKey hashes secret into a key with the given metadata
+func NewKey(secret, name, owner string, scopes, models []string) *Key {
+	salt := randomHex(16)
+	return &Key{
+		ID:      randomHex(8),
+		Name:    name,
+		Owner:   owner,
+		Created: time.Now().UTC(),
+		Scopes:  scopes,
+		Models:  models,
+		Salt:    salt,
+		Hash:    hashSecret(salt, secret),
+	}
+}
+
+// Matches reports in constant time whether secret is the key's secret
+func (k *Key) Matches(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashSecret(k.Salt, secret)), []byte(k.Hash)) == 1
+}
+
+// LastUsedAt returns when the key last authenticated a request
+func (k *Key) LastUsedAt() (time.Time, bool) {
+	if nanos := k.used.Load(); nanos != 0 {
+		return time.Unix(0, nanos).UTC(), true
+	}
+	if k.LastUsed != nil {
+		return *k.LastUsed, true
+	}
+	return time.Time{}, false
+}
+
+// touch records a use of the key
+func (k *Key) touch() {
+	k.used.Store(time.Now().UnixNano())
+}
+
+// APIKey returns the key the request authenticated with, if any
+func APIKey(ctx context.Context) *Key {
+	key, _ := ctx.Value(apiKeyContextKey).(*Key)
+	return key
+}
+
+// KeySet holds the credentials accepted by an auth middleware. It can be
+// replaced while the gateway runs, e.g. after secrets are rotated.
+type KeySet struct {
+	keys atomic.Pointer[[]*Key]
+}
+
+// NewKeySet creates a key set holding keys; empty keys are ignored
+func NewKeySet(keys ...string) *KeySet {
+	s := &KeySet{}
+	s.Set(keys)
+	return s
+}
+
+// Set replaces the accepted keys with plain secrets, hashing them
+func (s *KeySet) Set(secrets []string) {
+	s.SetKeys(HashKeys(secrets, nil))
+}
+
+// SetKeys replaces the accepted keys
+func (s *KeySet) SetKeys(keys []*Key) {
+	s.keys.Store(&keys)
+}
+
+// Keys returns the accepted keys
+func (s *KeySet) Keys() []*Key {
+	return *s.keys.Load()
+}
+
+// Empty reports whether no key is accepted
+func (s *KeySet) Empty() bool {
+	return len(*s.keys.Load()) == 0
+}
+
+// Contains reports whether secret is accepted
+func (s *KeySet) Contains(secret string) bool {
+	_, ok := s.Lookup(secret)
+	return ok
+}
+
+// Lookup returns the key matching secret. Every key is checked, so the
+// time taken doesn't reveal which one matched.
+func (s *KeySet) Lookup(secret string) (*Key, bool) {
+	var found *Key
+	for _, key := range *s.keys.Load() {
+		if key.Matches(secret) {
+			found = key
+		}
+	}
+	if found == nil {
+		return nil, false
+	}
+	found.touch()
+	return found, true
+}
+
+// HashKeys turns plain secrets, such as those from API_KEYS, into keys
+// with the rate-limit tiers tiers assigns to the secrets; empty secrets
+// are ignored
+func HashKeys(secrets []string, tiers map[string]string) []*Key {
+	keys := make([]*Key, 0, len(secrets))
+	for i, secret := range secrets {
+		if secret != "" {
+			key := NewKey(secret, fmt.Sprintf("API_KEYS[%d]", i), "", nil, nil)
+			key.ID = fmt.Sprintf("env-%d", i)
+			key.Tier = tiers[secret]
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// LoadKeyFile reads keys from a JSON key store file
+func LoadKeyFile(path string) ([]*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key store: %w", err)
+	}
+
+	var store struct {
+		Keys []*Key `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, fmt.Errorf("failed to parse key store %s: %w", path, err)
+	}
+	for i, key := range store.Keys {
+		if key.Salt == "" || len(key.Hash) != sha256.Size*2 {
+			return nil, fmt.Errorf("key store %s: key %d (%s) needs a salt and a hex SHA-256 hash", path, i, key.Name)
+		}
+		if err := CheckModelPatterns(key.Models); err != nil {
+			return nil, fmt.Errorf("key store %s: key %d (%s): %w", path, i, key.Name, err)
+		}
+	}
+	return store.Keys, nil
+}
+
+// GenerateSecret returns a random 256-bit client key
+func GenerateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// hashSecret returns the hex SHA-256 of salt and secret. Gateway keys are
+// long random strings, so a fast hash is enough to make stored hashes
+// useless to an attacker.
+func hashSecret(salt, secret string) string {
+	sum := sha256.Sum256([]byte(salt + secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,197 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/quota"
+)
+
+// ErrStaticKey is returned when revoking a key that comes from API_KEYS
+var ErrStaticKey = errors.New("key is configured in API_KEYS and can't be revoked here")
+
+// KeyStore manages the client keys of a KeySet: the static keys from
+// API_KEYS and the keys persisted in the API_KEY_STORE file, which can be
+// created and revoked while the gateway runs
+type KeyStore struct {
+	mu     sync.Mutex
+	path   string
+	set    *KeySet
+	static []*Key
+	stored []*Key
+}
+
+// NewKeyStore creates a store publishing its keys to set and persisting
+// them to path; an empty path keeps only static keys
+func NewKeyStore(set *KeySet, path string) *KeyStore {
+	return &KeyStore{path: path, set: set}
+}
+
+// Writable reports whether keys can be created and revoked
+func (s *KeyStore) Writable() bool {
+	return s.path != ""
+}
+
+// Load replaces the static keys and rereads the store file. A missing file
+// holds no keys. Last-used times carry over for keys that are kept.
+func (s *KeyStore) Load(static []*Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var stored []*Key
+	if s.path != "" {
+		var err error
+		stored, err = LoadKeyFile(s.path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	previous := make(map[string]*Key)
+	for _, key := range s.set.Keys() {
+		previous[key.ID] = key
+	}
+	for _, key := range append(append([]*Key{}, static...), stored...) {
+		if old, ok := previous[key.ID]; ok {
+			key.used.Store(old.used.Load())
+		}
+	}
+
+	s.static, s.stored = static, stored
+	s.publishLocked()
+	return nil
+}
+
+// List returns the static keys followed by the stored keys
+func (s *KeyStore) List() []*Key {
+	if s == nil {
+		return nil
+	}
+	return s.set.Keys()
+}
+
+// IsStatic reports whether key comes from API_KEYS
+func (s *KeyStore) IsStatic(key *Key) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, static := range s.static {
+		if static == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Create generates a random secret, stores a key for it and returns the
+// secret, which is not kept
+func (s *KeyStore) Create(name, owner string, scopes, models []string, tenant, tier string, limit *quota.Limit) (string, *Key, error) {
+	if !s.Writable() {
+		return "", nil, fmt.Errorf("API_KEY_STORE is not configured")
+	}
+	if err := CheckModelPatterns(models); err != nil {
+		return "", nil, err
+	}
+	secret, err := GenerateSecret()
+	if err != nil {
+		return "", nil, err
+	}
+	key := NewKey(secret, name, owner, scopes, models)
+	key.Tenant = tenant
+	key.Tier = tier
+	key.Quota = limit
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := append(append([]*Key{}, s.stored...), key)
+	if err := s.saveLocked(stored); err != nil {
+		return "", nil, err
+	}
+	s.stored = stored
+	s.publishLocked()
+	return secret, key, nil
+}
+
+// Revoke removes the stored key with the given ID. It reports false if no
+// key has that ID.
+func (s *KeyStore) Revoke(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.static {
+		if key.ID == id {
+			return false, ErrStaticKey
+		}
+	}
+	stored := make([]*Key, 0, len(s.stored))
+	for _, key := range s.stored {
+		if key.ID != id {
+			stored = append(stored, key)
+		}
+	}
+	if len(stored) == len(s.stored) {
+		return false, nil
+	}
+	if err := s.saveLocked(stored); err != nil {
+		return false, err
+	}
+	s.stored = stored
+	s.publishLocked()
+	return true, nil
+}
+
+// publishLocked makes the current keys the accepted ones
+func (s *KeyStore) publishLocked() {
+	keys := make([]*Key, 0, len(s.static)+len(s.stored))
+	s.set.SetKeys(append(append(keys, s.static...), s.stored...))
+}
+
+// storedKey is a key as written to the store file. Its LastUsed shadows
+// the key's, so saving doesn't modify keys that requests are reading.
+type storedKey struct {
+	*Key
+	LastUsed *time.Time `json:"last_used,omitempty"`
+}
+
+// saveLocked atomically rewrites the store file with keys, recording their
+// last-used times
+func (s *KeyStore) saveLocked(keys []*Key) error {
+	records := make([]storedKey, len(keys))
+	for i, key := range keys {
+		records[i].Key = key
+		if used, ok := key.LastUsedAt(); ok {
+			records[i].LastUsed = &used
+		}
+	}
+	data, err := json.MarshalIndent(struct {
+		Keys []storedKey `json:"keys"`
+	}{records}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode key store: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to save key store: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".keys-*")
+	if err != nil {
+		return fmt.Errorf("failed to save key store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save key store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save key store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save key store: %w", err)
+	}
+	return nil
+}
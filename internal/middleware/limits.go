@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// MultipartMemory is how much of a multipart body is held in memory; larger
+// parts, such as big diff uploads, are spooled to temporary files
+const MultipartMemory = 1 << 20
+
+// LimitBody middleware caps request bodies at maxBytes. Requests declaring a
+// larger Content-Length are rejected up front; reads past the limit on
+// other requests fail with *http.MaxBytesError, reported as 413.
+func LimitBody(next http.Handler, maxBytes int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxBytes {
+			writeBodyTooLarge(w, maxBytes)
+			return
+		}
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IsBodyTooLarge reports whether err came from reading past a body limit,
+// returning the limit
+func IsBodyTooLarge(err error) (int64, bool) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return tooLarge.Limit, true
+	}
+	return 0, false
+}
+
+// writeBodyTooLarge sends a 413 for a body over limit bytes
+func writeBodyTooLarge(w http.ResponseWriter, limit int64) {
+	http.Error(w, fmt.Sprintf(`{"error":"Request body exceeds %d bytes"}`, limit), http.StatusRequestEntityTooLarge)
+}
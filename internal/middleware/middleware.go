@@ -1,11 +1,41 @@
 package middleware
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
+type contextKey string
+
+const clientIDKey contextKey = "client_id"
+
+// ClientID returns the identifier of the authenticated caller, if any
+func ClientID(ctx context.Context) string {
+	id, _ := ctx.Value(clientIDKey).(string)
+	return id
+}
+
+// WithClientID returns a context acting for the caller id, e.g. to finish
+// a review the caller queued before a restart
+func WithClientID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, clientIDKey, id)
+}
+
+// clientIDForKey derives a non-reversible identifier from an API key
+func clientIDForKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return "key-" + hex.EncodeToString(sum[:])[:12]
+}
+
 // Logging middleware logs all HTTP requests
 func Logging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -16,12 +46,13 @@ func Logging(next http.Handler) http.Handler {
 		
 		next.ServeHTTP(wrapped, r)
 		
-		log.Printf("%s %s %d %v %s",
+		log.Printf("%s %s %d %v %s %s",
 			r.Method,
 			r.URL.Path,
 			wrapped.statusCode,
 			time.Since(start),
 			r.RemoteAddr,
+			RequestID(r.Context()),
 		)
 	})
 }
@@ -31,7 +62,8 @@ func CORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-API-Key")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Encoding, Authorization, X-API-Key, X-Admin-Key, Idempotency-Key, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 		
 		// Handle preflight requests
 		if r.Method == http.MethodOptions {
@@ -43,36 +75,54 @@ func CORS(next http.Handler) http.Handler {
 	})
 }
 
-// APIKeyAuth middleware validates API keys
-func APIKeyAuth(next http.Handler, validKeys []string) http.Handler {
+// APIKeyAuth middleware validates API keys and, when tokens is set, OIDC
+// bearer tokens. An empty key set accepts bearer tokens only.
+func APIKeyAuth(next http.Handler, validKeys *KeySet, tokens *TokenVerifier) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip auth for health check
-		if r.URL.Path == "/health" {
+		// Skip auth for health check, API description, the dashboard page and admin endpoints;
+		// main mounts the whole /admin/ tree behind AdminAuth
+		if r.URL.Path == "/health" || r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || r.URL.Path == "/openapi.json" || r.URL.Path == "/ui" || strings.HasPrefix(r.URL.Path, "/ui/") || strings.HasPrefix(r.URL.Path, "/admin/") {
 			next.ServeHTTP(w, r)
 			return
 		}
 		
+		if token, ok := bearerToken(r.Header.Get("Authorization")); ok && tokens != nil {
+			clientID, err := tokens.Verify(r.Context(), token)
+			if errors.Is(err, errMissingScope) {
+				http.Error(w, `{"error":"Token lacks a required scope"}`, http.StatusForbidden)
+				return
+			}
+			if err != nil {
+				log.Printf("Rejected bearer token: %v", err)
+				http.Error(w, `{"error":"Invalid bearer token"}`, http.StatusUnauthorized)
+				return
+			}
+			ctx := context.WithValue(r.Context(), clientIDKey, clientID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+		
 		apiKey := r.Header.Get("X-API-Key")
 		if apiKey == "" {
+			if tokens != nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, `{"error":"Missing X-API-Key header or bearer token"}`, http.StatusUnauthorized)
+				return
+			}
 			http.Error(w, `{"error":"Missing X-API-Key header"}`, http.StatusUnauthorized)
 			return
 		}
 		
 		// Validate API key
-		valid := false
-		for _, key := range validKeys {
-			if apiKey == key {
-				valid = true
-				break
-			}
-		}
-		
-		if !valid {
+		key, ok := validKeys.Lookup(apiKey)
+		if !ok {
 			http.Error(w, `{"error":"Invalid API key"}`, http.StatusUnauthorized)
 			return
 		}
 		
-		next.ServeHTTP(w, r)
+		ctx := context.WithValue(r.Context(), clientIDKey, clientIDForKey(apiKey))
+		ctx = context.WithValue(ctx, apiKeyContextKey, key)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
@@ -87,16 +137,136 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// Recover middleware recovers from panics
+// Recover middleware converts a handler panic into a 500 JSON response
+// carrying the request ID, and logs the stack trace. If the handler had
+// already started its response, the connection is aborted instead so the
+// client can't mistake a truncated body for a complete one.
 func Recover(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracked := &trackingWriter{ResponseWriter: w}
 		defer func() {
-			if err := recover(); err != nil {
-				log.Printf("Panic recovered: %v", err)
-				http.Error(w, `{"error":"Internal server error"}`, http.StatusInternalServerError)
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			id := RequestID(r.Context())
+			log.Printf("Panic serving %s %s (request %s): %v\n%s", r.Method, r.URL.Path, id, err, debug.Stack())
+			if tracked.wrote {
+				panic(http.ErrAbortHandler)
 			}
+
+			w.Header().Del("Content-Length")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":      "Internal server error",
+				"request_id": id,
+			})
 		}()
+		next.ServeHTTP(tracked, r)
+	})
+}
+
+// trackingWriter records whether a response has been started
+type trackingWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (tw *trackingWriter) WriteHeader(code int) {
+	tw.wrote = true
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *trackingWriter) Write(b []byte) (int, error) {
+	tw.wrote = true
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *trackingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+
+// MaintenanceMode holds the gateway's read-only switch
+type MaintenanceMode struct {
+	enabled atomic.Bool
+}
+
+// NewMaintenanceMode creates a maintenance switch with the given initial state
+func NewMaintenanceMode(enabled bool) *MaintenanceMode {
+	m := &MaintenanceMode{}
+	m.enabled.Store(enabled)
+	return m
+}
+
+// Enabled reports whether the gateway is in read-only mode
+func (m *MaintenanceMode) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// Set turns read-only mode on or off
+func (m *MaintenanceMode) Set(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// ReadOnly middleware rejects new reviews while maintenance mode is enabled.
+// History, analytics, health and admin endpoints keep working.
+func ReadOnly(next http.Handler, mode *MaintenanceMode) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mode.Enabled() && startsProviderWork(r) {
+			w.Header().Set("Retry-After", "300")
+			http.Error(w, `{"error":"Gateway is in read-only maintenance mode; new reviews are temporarily disabled"}`, http.StatusServiceUnavailable)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// providerRoutes lists the endpoints whose POSTs start new provider work.
+// RateLimit, ReadOnly and Quotas all gate these routes, so a new endpoint
+// calling providers only has to be added here. Entries ending in a slash
+// match every path below them.
+var providerRoutes = []string{
+	"/review",
+	"/review/", // batch, compare, estimate, dry-run and follow-ups
+	"/ask",
+	"/generate/",
+	"/index",
+	"/eval/run",
+}
+
+// startsProviderWork reports whether a request starts new provider work
+func startsProviderWork(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	for _, route := range providerRoutes {
+		if r.URL.Path == route || (strings.HasSuffix(route, "/") && strings.HasPrefix(r.URL.Path, route)) {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminAuth middleware validates the admin credential
+func AdminAuth(next http.Handler, adminKeys *KeySet) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if adminKeys.Empty() {
+			http.Error(w, `{"error":"Admin API is disabled"}`, http.StatusNotFound)
+			return
+		}
+
+		key := r.Header.Get("X-Admin-Key")
+		if !adminKeys.Contains(key) {
+			http.Error(w, `{"error":"Invalid admin key"}`, http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
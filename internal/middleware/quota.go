@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/quota"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/tenants"
+)
+
+const quotaKey contextKey = "quota"
+
+// QuotaAccount is one quota a request is charged against
+type QuotaAccount struct {
+	Name  string      `json:"name"`
+	Limit quota.Limit `json:"limit"`
+}
+
+// quotaState is what a request needs to be charged for provider usage
+type quotaState struct {
+	tracker  *quota.Tracker
+	accounts []QuotaAccount
+}
+
+// Quotas middleware rejects new provider work once any of the caller's
+// monthly quotas is exhausted, and lets handlers charge usage with
+// RecordUsage. A request is charged to its key's quota and its tenant's
+// quota when they are set, otherwise to the caller under defaultLimit.
+func Quotas(next http.Handler, tracker *quota.Tracker, defaultLimit quota.Limit) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID := ClientID(r.Context())
+		if clientID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		state := &quotaState{tracker: tracker, accounts: quotaAccounts(r.Context(), clientID, defaultLimit)}
+		if startsProviderWork(r) {
+			var exhausted []quota.Status
+			for _, account := range state.accounts {
+				if status := tracker.Status(account.Name, account.Limit); status.Exhausted {
+					exhausted = append(exhausted, status)
+				}
+			}
+			if len(exhausted) > 0 {
+				writeQuotaExhausted(w, tracker, exhausted)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), quotaKey, state)))
+	})
+}
+
+// quotaAccounts lists the quotas a request is charged against
+func quotaAccounts(ctx context.Context, clientID string, defaultLimit quota.Limit) []QuotaAccount {
+	var accounts []QuotaAccount
+	if key := APIKey(ctx); key != nil && key.Quota != nil {
+		accounts = append(accounts, QuotaAccount{"key-" + key.ID, *key.Quota})
+	}
+	if t := tenants.FromContext(ctx); t != nil && t.Quota != nil {
+		accounts = append(accounts, QuotaAccount{"tenant-" + t.Name, *t.Quota})
+	}
+	if len(accounts) == 0 {
+		accounts = append(accounts, QuotaAccount{clientID, defaultLimit})
+	}
+	return accounts
+}
+
+// QuotaAccounts returns the quotas the request is charged against, so work
+// finished elsewhere can be charged to them with WithQuotas
+func QuotaAccounts(ctx context.Context) []QuotaAccount {
+	state, ok := ctx.Value(quotaKey).(*quotaState)
+	if !ok {
+		return nil
+	}
+	return append([]QuotaAccount(nil), state.accounts...)
+}
+
+// WithQuotas returns a context whose RecordUsage charges accounts in
+// tracker
+func WithQuotas(ctx context.Context, tracker *quota.Tracker, accounts []QuotaAccount) context.Context {
+	if tracker == nil || len(accounts) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, quotaKey, &quotaState{tracker: tracker, accounts: accounts})
+}
+
+// RecordUsage charges provider usage to the request's quotas
+func RecordUsage(ctx context.Context, tokens int, costUSD float64) {
+	state, ok := ctx.Value(quotaKey).(*quotaState)
+	if !ok {
+		return
+	}
+	names := make([]string, len(state.accounts))
+	for i, account := range state.accounts {
+		names[i] = account.Name
+	}
+	state.tracker.Record(names, tokens, costUSD)
+}
+
+// QuotaStatus reports the request's quotas
+func QuotaStatus(ctx context.Context) []quota.Status {
+	state, ok := ctx.Value(quotaKey).(*quotaState)
+	if !ok {
+		return nil
+	}
+	statuses := make([]quota.Status, len(state.accounts))
+	for i, account := range state.accounts {
+		statuses[i] = state.tracker.Status(account.Name, account.Limit)
+	}
+	return statuses
+}
+
+// writeQuotaExhausted sends a 429 telling the caller when its quota resets
+func writeQuotaExhausted(w http.ResponseWriter, tracker *quota.Tracker, exhausted []quota.Status) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"error": fmt.Sprintf("Monthly quota exhausted for %s; it resets at %s", exhausted[0].Account, exhausted[0].ResetsAt),
+		"quota": exhausted,
+	})
+	w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(tracker.UntilReset().Seconds()))))
+	http.Error(w, string(body), http.StatusTooManyRequests)
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/ratelimit"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/tenants"
+)
+
+const priorityKey contextKey = "priority"
+
+// Priority returns the scheduling priority of the caller
+func Priority(ctx context.Context) int {
+	if p, ok := ctx.Value(priorityKey).(int); ok {
+		return p
+	}
+	return ratelimit.PriorityNormal
+}
+
+// WithPriority returns a context scheduling at priority instead of the
+// caller's tier
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey, priority)
+}
+
+// RateLimit middleware enforces the request rate of the caller's key tier on
+// review endpoints and records the tier's scheduling priority in the context
+func RateLimit(next http.Handler, limiter *ratelimit.Limiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID := ClientID(r.Context())
+		if clientID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var keyTier string
+		if key := APIKey(r.Context()); key != nil {
+			keyTier = key.Tier
+		}
+		tier := limiter.TierFor(keyTier)
+		if t := tenants.FromContext(r.Context()); t != nil && t.RateLimitTier != "" {
+			if tenantTier, ok := limiter.Tier(t.RateLimitTier); ok {
+				tier = tenantTier
+			}
+		}
+		w.Header().Set("X-RateLimit-Tier", tier.Name)
+
+		if startsProviderWork(r) {
+			if ok, wait := limiter.Allow(clientID, tier); !ok {
+				w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, fmt.Sprintf(`{"error":"Rate limit exceeded for tier %s"}`, tier.Name), http.StatusTooManyRequests)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), priorityKey, tier.Priority)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
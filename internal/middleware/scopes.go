@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ErrModelNotAllowed is returned for a provider and model outside the
+// caller's key restrictions
+var ErrModelNotAllowed = errors.New("model not allowed for this API key")
+
+// AllowsPath reports whether the key's scopes cover an endpoint. A scope
+// names the first path segment ("review" covers /review, /review/compare
+// and /review/{id}/followup); a key without scopes may call any endpoint.
+func (k *Key) AllowsPath(urlPath string) bool {
+	if len(k.Scopes) == 0 {
+		return true
+	}
+	endpoint, _, _ := strings.Cut(strings.TrimPrefix(urlPath, "/"), "/")
+	for _, scope := range k.Scopes {
+		if strings.TrimPrefix(scope, "/") == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsModel reports whether the key may use a provider and model. Models
+// are provider/model patterns such as "google/gemini-2.0-flash" or
+// "google/*"; a key without models may use any.
+func (k *Key) AllowsModel(provider, model string) bool {
+	if len(k.Models) == 0 {
+		return true
+	}
+	target := provider + "/" + model
+	for _, pattern := range k.Models {
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckModelPatterns rejects malformed provider/model patterns
+func CheckModelPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil || !strings.Contains(pattern, "/") {
+			return fmt.Errorf("invalid model pattern %q; use provider/model, e.g. google/gemini-2.0-flash or google/*", pattern)
+		}
+	}
+	return nil
+}
+
+// AuthorizeModel checks a resolved provider and model against the
+// restrictions of the key the request authenticated with. Handlers call it
+// after applying defaults and aliases, so the check sees the model that
+// will actually run.
+func AuthorizeModel(ctx context.Context, provider, model string) error {
+	key := APIKey(ctx)
+	if key == nil || key.AllowsModel(provider, model) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s/%s (allowed: %s)", ErrModelNotAllowed, provider, model, strings.Join(key.Models, ", "))
+}
+
+// KeyScopes middleware rejects requests to endpoints outside the scopes of
+// the caller's API key
+func KeyScopes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := APIKey(r.Context()); key != nil && !key.AllowsPath(r.URL.Path) {
+			http.Error(w, `{"error":"Endpoint not allowed for this API key"}`, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/tenants"
+)
+
+// Tenants middleware attaches the tenant of the caller's API key to the
+// request. All keys of a tenant share one client ID, so they see the same
+// review history and draw from the same rate limit bucket.
+func Tenants(next http.Handler, directory *tenants.Directory) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := APIKey(r.Context())
+		if key == nil || key.Tenant == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		t, ok := directory.Get(key.Tenant)
+		if !ok {
+			log.Printf("API key %s belongs to unknown tenant %s", key.ID, key.Tenant)
+			http.Error(w, `{"error":"Unknown tenant for this API key"}`, http.StatusForbidden)
+			return
+		}
+
+		ctx := tenants.NewContext(r.Context(), t)
+		ctx = context.WithValue(ctx, clientIDKey, "tenant-"+t.Name)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
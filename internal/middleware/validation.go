@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/openapi"
+)
+
+// validationError is the body of a 400 response for an invalid request
+type validationError struct {
+	Error  string               `json:"error"`
+	Fields []openapi.FieldError `json:"fields"`
+}
+
+// ValidateRequests checks JSON request bodies, and the JSON parts of
+// multipart bodies, against the OpenAPI document and rejects invalid
+// requests with a 400 listing the offending fields. maxMemory is passed to
+// ParseMultipartForm; larger parts are spooled to temporary files.
+func ValidateRequests(next http.Handler, validator *openapi.Validator, maxMemory int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Method == http.MethodGet || r.Method == http.MethodDelete {
+			next.ServeHTTP(w, r)
+			return
+		}
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		var fields []openapi.FieldError
+		switch mediaType {
+		case "application/json":
+			schema, _, ok := validator.BodySchema(r.Method, r.URL.Path, mediaType)
+			if !ok {
+				break
+			}
+			body, err := io.ReadAll(r.Body)
+			if limit, tooLarge := IsBodyTooLarge(err); tooLarge {
+				writeBodyTooLarge(w, limit)
+				return
+			}
+			if err != nil {
+				writeValidationError(w, []openapi.FieldError{{Message: "failed to read request body"}})
+				return
+			}
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			fields = validator.ValidateJSON(schema, body)
+
+		case "multipart/form-data":
+			schema, jsonParts, ok := validator.BodySchema(r.Method, r.URL.Path, mediaType)
+			if !ok || len(jsonParts) == 0 {
+				break
+			}
+			// The parsed form is kept on the request for the handler
+			if err := r.ParseMultipartForm(maxMemory); err != nil {
+				if limit, tooLarge := IsBodyTooLarge(err); tooLarge {
+					writeBodyTooLarge(w, limit)
+					return
+				}
+				writeValidationError(w, []openapi.FieldError{{Message: "invalid multipart body: " + err.Error()}})
+				return
+			}
+			for _, name := range jsonParts {
+				value := r.FormValue(name)
+				if value == "" {
+					fields = append(fields, openapi.FieldError{Field: name, Message: "is required"})
+					continue
+				}
+				partSchema, ok := validator.PropertySchema(schema, name)
+				if !ok {
+					continue
+				}
+				for _, f := range validator.ValidateJSON(partSchema, []byte(value)) {
+					f.Field = prefixField(name, f.Field)
+					fields = append(fields, f)
+				}
+			}
+		}
+
+		if len(fields) > 0 {
+			writeValidationError(w, fields)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeValidationError sends a 400 listing invalid fields
+func writeValidationError(w http.ResponseWriter, fields []openapi.FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	if err := json.NewEncoder(w).Encode(validationError{Error: "Invalid request body", Fields: fields}); err != nil {
+		log.Printf("Error encoding validation error: %v", err)
+	}
+}
+
+func prefixField(prefix, field string) string {
+	if field == "" {
+		return prefix
+	}
+	if field[0] == '[' {
+		return prefix + field
+	}
+	return prefix + "." + field
+}
@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// API versions
+const (
+	VersionLegacy = ""   // Unversioned paths: bare response bodies
+	Version1      = "v1" // /v1/...: responses wrapped in an Envelope
+)
+
+// versionMediaType selects v1 on unversioned paths via the Accept header
+const versionMediaType = "application/vnd.aireview.v1+json"
+
+var versionPrefix = regexp.MustCompile(`^/(v[0-9]+)(/|$)`)
+
+const versionKey contextKey = "api_version"
+
+// versionState is the per-request API version, and whether the handler
+// asked for its response to be sent without an envelope
+type versionState struct {
+	version string
+	raw     bool
+}
+
+// Envelope wraps every response of a versioned API
+type Envelope struct {
+	APIVersion string          `json:"api_version"`
+	Data       json.RawMessage `json:"data,omitempty"`
+	Error      *EnvelopeError  `json:"error,omitempty"`
+}
+
+// EnvelopeError is the error member of an Envelope
+type EnvelopeError struct {
+	Status    int             `json:"status"`
+	Message   string          `json:"message"`
+	Fields    json.RawMessage `json:"fields,omitempty"`     // Present for request validation errors
+	RequestID string          `json:"request_id,omitempty"` // Present for internal errors
+}
+
+// APIVersion returns the API version of a request
+func APIVersion(ctx context.Context) string {
+	state, _ := ctx.Value(versionKey).(*versionState)
+	if state == nil {
+		return VersionLegacy
+	}
+	return state.version
+}
+
+// RawResponse asks for the response to be sent without the versioned
+// envelope, for formats consumed by other tools (e.g. GitLab Code Quality)
+func RawResponse(ctx context.Context) {
+	if state, _ := ctx.Value(versionKey).(*versionState); state != nil {
+		state.raw = true
+	}
+}
+
+// Versioning middleware serves the API under /v1 as well as at the legacy
+// unversioned paths. The version prefix is stripped before routing, so
+// handlers and later middleware see the same paths for both. Versioned
+// responses are wrapped in an Envelope; legacy responses are unchanged so
+// existing integrations keep working. Clients on unversioned paths can opt
+// in with "Accept: application/vnd.aireview.v1+json".
+func Versioning(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := &versionState{version: VersionLegacy}
+		if m := versionPrefix.FindStringSubmatch(r.URL.Path); m != nil {
+			if m[1] != Version1 {
+				http.Error(w, `{"error":"Unsupported API version"}`, http.StatusNotFound)
+				return
+			}
+			state.version = Version1
+			r = r.Clone(r.Context())
+			r.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/"+m[1]), "/")
+			r.URL.RawPath = ""
+		} else if strings.Contains(r.Header.Get("Accept"), versionMediaType) {
+			state.version = Version1
+		}
+
+		if state.version == VersionLegacy {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("API-Version", state.version)
+		buffer := &bufferedWriter{header: w.Header(), statusCode: http.StatusOK}
+		next.ServeHTTP(buffer, r.WithContext(context.WithValue(r.Context(), versionKey, state)))
+		buffer.flushTo(w, state)
+	})
+}
+
+// bufferedWriter holds a response so it can be wrapped before sending
+type bufferedWriter struct {
+	header      http.Header
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (bw *bufferedWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *bufferedWriter) WriteHeader(code int) {
+	if !bw.wroteHeader {
+		bw.statusCode = code
+		bw.wroteHeader = true
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	bw.wroteHeader = true
+	return bw.body.Write(b)
+}
+
+// flushTo writes the buffered response, wrapped in an envelope unless it
+// is empty, raw or not JSON
+func (bw *bufferedWriter) flushTo(w http.ResponseWriter, state *versionState) {
+	mediaType, _, _ := mime.ParseMediaType(bw.header.Get("Content-Type"))
+	body := bytes.TrimSpace(bw.body.Bytes())
+	isJSON := mediaType == "application/json" || json.Valid(body)
+
+	if state.raw || bw.statusCode == http.StatusNoContent || len(body) == 0 || (bw.statusCode < 400 && !isJSON) {
+		w.WriteHeader(bw.statusCode)
+		w.Write(bw.body.Bytes())
+		return
+	}
+
+	envelope := Envelope{APIVersion: state.version}
+	if bw.statusCode >= 400 {
+		envelope.Error = &EnvelopeError{Status: bw.statusCode, Message: string(body)}
+		var legacy struct {
+			Error     string          `json:"error"`
+			Fields    json.RawMessage `json:"fields"`
+			RequestID string          `json:"request_id"`
+		}
+		if json.Unmarshal(body, &legacy) == nil && legacy.Error != "" {
+			envelope.Error.Message = legacy.Error
+			envelope.Error.Fields = legacy.Fields
+			envelope.Error.RequestID = legacy.RequestID
+		}
+	} else {
+		envelope.Data = json.RawMessage(body)
+	}
+
+	encoded, err := json.Marshal(envelope)
+	if err != nil {
+		w.WriteHeader(bw.statusCode)
+		w.Write(bw.body.Bytes())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(encoded)+1))
+	w.WriteHeader(bw.statusCode)
+	w.Write(append(encoded, '\n'))
+}
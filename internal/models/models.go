@@ -1,5 +1,16 @@
 package models
 
+import "time"
+
+// ModelParams are optional generation settings forwarded to the provider
+// in place of the gateway's defaults
+type ModelParams struct {
+	Temperature     *float32 `json:"temperature,omitempty"`      // 0-2; Anthropic accepts 0-1
+	MaxTokens       int      `json:"max_tokens,omitempty"`       // Output token limit
+	TopP            *float32 `json:"top_p,omitempty"`            // Nucleus sampling, above 0 and at most 1
+	ReasoningEffort string   `json:"reasoning_effort,omitempty"` // low, medium or high, for reasoning models
+}
+
 // ReviewRequest represents the incoming review request
 type ReviewRequest struct {
 	AIModel      string   `json:"ai_model"`
@@ -8,6 +19,126 @@ type ReviewRequest struct {
 	ReviewMode   string   `json:"review_mode"`
 	GitDiff      string   `json:"git_diff"`
 	GitInfo      *GitInfo `json:"git_info,omitempty"`
+	OutputFormat string   `json:"output_format,omitempty"` // diagnostic (default), codequality or sarif
+	Anonymize    bool     `json:"anonymize,omitempty"`     // Anonymize the diff before sending it to the provider
+	RepoConfig   *RepoConfig `json:"repo_config,omitempty"`  // Inline .aireview.yml settings
+	MinSeverity  string   `json:"min_severity,omitempty"`  // Drop diagnostics below INFO, WARNING or ERROR
+	MaxIssues    int      `json:"max_issues,omitempty"`    // Keep at most this many diagnostics, most severe first
+	FileContents []FileContent `json:"file_contents,omitempty"` // Full content of changed files, for context
+	Guidelines   []string `json:"guidelines,omitempty"`     // Named team guidelines to enforce, e.g. backend-go
+	Baseline     []string `json:"baseline,omitempty"`       // Fingerprints of acknowledged findings to suppress
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // Overall time limit, up to the server's MAX_REVIEW_TIMEOUT
+	CallbackURL  string   `json:"callback_url,omitempty"`   // Review asynchronously and POST the result here
+	Priority     string   `json:"priority,omitempty"`       // interactive (default) or batch; batch reviews wait behind interactive ones
+	BaseSHA      string   `json:"base_sha,omitempty"`       // With HeadSHA, the gateway fetches the diff from git_info.repo_url
+	HeadSHA      string   `json:"head_sha,omitempty"`
+	CommitMessage string  `json:"commit_message,omitempty"` // Checked against Conventional Commits; see ReviewResponse.CommitMessage
+	GeneratePatches  bool `json:"generate_patches,omitempty"`  // Ask for fixes as patches; see ReviewResponse.Patches
+	SuggestionBlocks bool `json:"suggestion_blocks,omitempty"` // Also render patches as GitHub suggestion blocks
+	ResponseLanguage string `json:"response_language,omitempty"` // Language of overviews and messages, e.g. vi, ja or ko
+	Categories   []string `json:"categories,omitempty"`     // Review only these categories, e.g. possible-bug; empty reviews all
+	VerdictPolicy *VerdictPolicy `json:"verdict_policy,omitempty"` // Thresholds for ReviewResponse.Verdict, replacing .aireview.yml's and the gateway's
+	LinterReports []LinterReport `json:"linter_reports,omitempty"` // Static-analysis findings merged with the model's
+	SCMToken     string   `json:"scm_token,omitempty"`      // Reads a private repository for BaseSHA and HeadSHA; never stored
+	PromptVersion string  `json:"prompt_version,omitempty"` // Registered prompt version to use instead of the experiment's pick
+	ModelParams                                                // Optional generation settings, sent flat
+
+	// TruncatedFiles lists the files whose changes were cut down to fit the
+	// prompt, so the model isn't misled by what's missing; set by the gateway
+	TruncatedFiles []TruncatedFile `json:"-"`
+
+	// LinterFindings are the findings read from LinterReports; set by the
+	// gateway
+	LinterFindings []Diagnostic `json:"-"`
+
+	// InjectionFindings lists suspected prompt-injection attempts in the
+	// diff so the prompt can warn the model; set by the gateway
+	InjectionFindings []InjectionFinding `json:"-"`
+
+	// RelatedContext is code and guidance retrieved from the repository
+	// index; set by the gateway
+	RelatedContext []ContextSnippet `json:"-"`
+
+	// StyleGuides holds the documents named in Guidelines and the repository
+	// configuration; set by the gateway
+	StyleGuides []StyleGuide `json:"-"`
+
+	// RejectedFindings are findings developers marked as false positives in
+	// earlier reviews of the repository; set by the gateway
+	RejectedFindings []FeedbackExample `json:"-"`
+
+	// Route names the routing rule that picked the provider and model when
+	// the request named neither; set by the gateway
+	Route string `json:"-"`
+}
+
+// TruncatedFile records a file whose changes were too large to send to the
+// model in full
+type TruncatedFile struct {
+	Path          string `json:"path"`
+	Strategy      string `json:"strategy"`       // head-tail or sample
+	OriginalBytes int    `json:"original_bytes"` // Size of the file's diff
+	Bytes         int    `json:"bytes"`          // Size of the part sent
+	OmittedLines  int    `json:"omitted_lines"`  // Added and removed lines left out
+}
+
+// FileContent is the full content of a file after the change
+type FileContent struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// StyleGuide is a named team guideline document such as a language style
+// guide or API conventions
+type StyleGuide struct {
+	Name      string    `json:"name"`
+	Content   string    `json:"content,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RepoConfig holds per-repository review settings (.aireview.yml)
+type RepoConfig struct {
+	IgnorePaths []string `json:"ignore_paths,omitempty" yaml:"ignore_paths"`
+	MinSeverity string   `json:"min_severity,omitempty" yaml:"min_severity"`
+	Categories  []string `json:"categories,omitempty" yaml:"categories"`
+	MaxIssues   int      `json:"max_issues,omitempty" yaml:"max_issues"`
+	Guidelines  string   `json:"guidelines,omitempty" yaml:"guidelines"`
+	StyleGuides []string `json:"style_guides,omitempty" yaml:"style_guides"` // Named team guidelines to enforce
+	LicenseHeader *LicenseHeaderConfig `json:"license_header,omitempty" yaml:"license_header"` // Header new files must start with
+	Verdict     *VerdictPolicy `json:"verdict,omitempty" yaml:"verdict"` // Thresholds for the review verdict
+}
+
+// VerdictPolicy maps severities to the number of findings at or above them
+// a review may have before it fails or warns, e.g. Fail {ERROR: 0} fails a
+// review with any ERROR
+type VerdictPolicy struct {
+	Fail map[string]int `json:"fail,omitempty" yaml:"fail"`
+	Warn map[string]int `json:"warn,omitempty" yaml:"warn"`
+}
+
+// Verdict is the outcome of a review under a verdict policy, for CI gating
+type Verdict struct {
+	Status  string         `json:"status"`            // pass, warn or fail
+	Reasons []string       `json:"reasons,omitempty"` // Thresholds crossed
+	Counts  map[string]int `json:"counts"`            // Findings per severity
+}
+
+// LicenseHeaderConfig is the license or copyright header a repository
+// requires in new files. Header is matched literally, ignoring whitespace,
+// and Pattern as a regular expression.
+type LicenseHeaderConfig struct {
+	Header   string   `json:"header,omitempty" yaml:"header"`     // Exact header text, including comment markers
+	Pattern  string   `json:"pattern,omitempty" yaml:"pattern"`   // Instead of Header, e.g. Copyright \d{4} Acme
+	Paths    []string `json:"paths,omitempty" yaml:"paths"`       // Files checked; defaults to common source files
+	Lines    int      `json:"lines,omitempty" yaml:"lines"`       // Leading lines searched; defaults to 20
+	Severity string   `json:"severity,omitempty" yaml:"severity"` // Defaults to WARNING
+}
+
+// LinterReport is the output of a static-analysis tool run by the caller
+type LinterReport struct {
+	Tool    string `json:"tool,omitempty"`   // Name shown in Diagnostic.Sources; defaults to the report's own
+	Format  string `json:"format,omitempty"` // sarif, rdjson, rdjsonl, checkstyle, golangci-lint or eslint; detected when empty
+	Content string `json:"content"`
 }
 
 // GitInfo contains git repository information
@@ -28,9 +159,76 @@ type GitUser struct {
 
 // ReviewResponse represents the diagnostic format response
 type ReviewResponse struct {
+	ID          string       `json:"id,omitempty"` // Used to ask follow-up questions
 	Source      Source       `json:"source"`
 	Diagnostics []Diagnostic `json:"diagnostics"`
 	Overview    string       `json:"overview,omitempty"`
+	SkippedFiles []string    `json:"skipped_files,omitempty"` // Files not sent to the model
+	TruncatedFiles []TruncatedFile `json:"truncated_files,omitempty"` // Files only partly sent to the model
+	Suppressed  *SuppressionSummary `json:"suppressed,omitempty"`
+	Redactions  []Redaction  `json:"redactions,omitempty"` // Secrets removed before the diff was sent
+	SuspiciousContent bool   `json:"suspicious_content,omitempty"` // Prompt-injection attempts were neutralized
+	InjectionFindings []InjectionFinding `json:"injection_findings,omitempty"`
+	RelatedContext    []ContextSnippet   `json:"related_context,omitempty"` // Indexed snippets added to the prompt
+	CommitMessage     *CommitMessageReview `json:"commit_message,omitempty"` // Set when the request has a commit_message
+	Patches           []Patch            `json:"patches,omitempty"` // Fixes for diagnostics, with generate_patches
+	DetectedLanguages []string           `json:"detected_languages,omitempty"` // Languages of the diff, most changed first, when the request named none
+	Partial           bool               `json:"partial,omitempty"` // The model's output was cut off; diagnostics are the issues it finished
+	Verdict           *Verdict           `json:"verdict,omitempty"` // pass, warn or fail under the verdict policy
+	SchemaValidation  *SchemaReport      `json:"schema_validation,omitempty"` // Set when model output broke the response schema
+	PromptVersion     string             `json:"prompt_version,omitempty"`    // Prompt version the review used, when versions are registered
+	AIProvider        string             `json:"ai_provider,omitempty"`       // Set when a routing rule picked the provider and model
+	AIModel           string             `json:"ai_model,omitempty"`
+	Route             string             `json:"route,omitempty"` // Name of that rule
+	Deduplicated      bool               `json:"deduplicated,omitempty"` // The same changes were just reviewed for the caller; this is that review, under its ID
+}
+
+// Patch is a fix for one diagnostic, applying to the changed file
+type Patch struct {
+	Diagnostic  int    `json:"diagnostic"`  // Index into the response's diagnostics
+	Fingerprint string `json:"fingerprint"` // Of the diagnostic
+	Path        string `json:"path"`
+	StartLine   int    `json:"start_line"`           // First replaced line of the new file
+	EndLine     int    `json:"end_line"`             // Last replaced line of the new file
+	Diff        string `json:"diff"`                 // Unified diff for git apply
+	Suggestion  string `json:"suggestion,omitempty"` // GitHub suggestion block for a comment on StartLine-EndLine, with suggestion_blocks
+}
+
+// CommitMessageReview is the verdict on a request's commit message
+type CommitMessageReview struct {
+	Conventional bool     `json:"conventional"`        // Follows Conventional Commits
+	Problems     []string `json:"problems,omitempty"`  // Rules the message breaks
+	Suggested    string   `json:"suggested,omitempty"` // An improved message from the model, when it proposed one
+}
+
+// InjectionFinding records text in the diff that appears to be aimed at the
+// reviewing model, such as "ignore previous instructions"
+type InjectionFinding struct {
+	Kind    string `json:"kind"` // instruction-override, role-marker or output-manipulation
+	Path    string `json:"path,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Excerpt string `json:"excerpt"`
+}
+
+// Redaction records a secret replaced with a placeholder before the diff
+// was sent to the provider. The secret itself is never included.
+type Redaction struct {
+	Type        string `json:"type"`
+	Placeholder string `json:"placeholder"`
+	Path        string `json:"path,omitempty"`
+	Line        int    `json:"line,omitempty"` // Line in the new file; 0 for removed lines
+}
+
+// SuppressionSummary counts diagnostics removed after parsing, by reason
+type SuppressionSummary struct {
+	Total       int `json:"total"`
+	OutsideDiff int `json:"outside_diff,omitempty"` // Not on a changed line
+	Duplicates  int `json:"duplicates,omitempty"`   // Merged into a near-identical finding
+	RepoConfig  int `json:"repo_config,omitempty"`  // Filtered by .aireview.yml
+	Severity    int `json:"severity,omitempty"`     // Below min_severity
+	MaxIssues   int `json:"max_issues,omitempty"`   // Beyond max_issues or the review mode's limit
+	Baseline    int `json:"baseline,omitempty"`     // Acknowledged in the request's baseline
+	Categories  int `json:"categories,omitempty"`   // Outside the request's categories
 }
 
 // Source represents the source of diagnostics
@@ -47,6 +245,19 @@ type Diagnostic struct {
 	Code     Code     `json:"code"`
 	Original string   `json:"original,omitempty"` // Original code snippet
 	Suggestion string `json:"suggestion,omitempty"` // Suggested fix
+	Providers []string `json:"providers,omitempty"` // Ensemble providers that reported this finding
+	Fingerprint string `json:"fingerprint,omitempty"` // Stable ID for baselines; see ReviewRequest.Baseline
+	Sources  []string `json:"sources,omitempty"` // ai-review and the linters that reported it, when linter_reports are sent
+	CWE      string   `json:"cwe,omitempty"`   // Weakness ID of security findings, e.g. CWE-89; Code.URL links to it
+	OWASP    string   `json:"owasp,omitempty"` // OWASP Top 10 category of security findings, e.g. A03:2021-Injection
+	Fix      *Fix     `json:"-"` // Replacement code proposed by the model; returned as a Patch
+}
+
+// Fix replaces lines of the changed file
+type Fix struct {
+	StartLine   int
+	EndLine     int
+	Replacement string
 }
 
 // Location represents the location of an issue in the code
@@ -55,7 +266,9 @@ type Location struct {
 	Range Range  `json:"range"`
 }
 
-// Range represents a range in the code
+// Range represents a range in the code. End is exclusive; an End.Column
+// of 0 means the range runs to the end of End.Line, as for findings about
+// a whole function.
 type Range struct {
 	Start Position `json:"start"`
 	End   Position `json:"end"`
@@ -77,5 +290,440 @@ type Code struct {
 type AIProviderResponse struct {
 	Overview    string
 	Diagnostics []Diagnostic
+	CommitMessage string // Improved commit message, when the request had one
+	ParserPath  string // structured, repaired, reprompted, partial or unstructured
+	Usage       Usage
+	Schema      SchemaReport // Issues that broke the response schema
+}
+
+// SchemaReport counts the issues of a model's response that didn't match
+// the response schema
+type SchemaReport struct {
+	Rejected int `json:"rejected"` // Dropped for a missing file, line or message
+	Repaired int `json:"repaired"` // Kept after fixing an unknown severity or category, a bad column or fix
+}
+
+// Add sums two reports
+func (r SchemaReport) Add(other SchemaReport) SchemaReport {
+	return SchemaReport{Rejected: r.Rejected + other.Rejected, Repaired: r.Repaired + other.Repaired}
+}
+
+// Usage records the size of a provider exchange
+type Usage struct {
+	PromptBytes      int
+	ResponseBytes    int
+	PromptTokens     int // As reported by the provider, or estimated for an interrupted response; zero if unknown
+	CompletionTokens int
+	Truncated        bool // Output stopped at the max token limit
+	Batched          bool // Answered through a provider's batch API, at its discount
+	CachedTokens     int  // Prompt tokens read from the provider's context cache, billed at its discount
+}
+
+
+// AskRequest represents a targeted question about a single hunk
+type AskRequest struct {
+	AIModel    string `json:"ai_model"`
+	AIProvider string `json:"ai_provider"`
+	Language   string `json:"language"`
+	File       string `json:"file"`
+	Hunk       string `json:"hunk"`
+	Question   string `json:"question"`
+	Context    string `json:"context,omitempty"` // Optional surrounding code
+
+	// InjectionFindings lists suspected prompt-injection attempts in the
+	// hunk or context; set by the gateway
+	InjectionFindings []InjectionFinding `json:"-"`
+}
+
+// AskResponse represents a focused answer to an AskRequest
+type AskResponse struct {
+	Answer      string       `json:"answer"`
+	Verdict     string       `json:"verdict"` // yes, no or unclear
+	Confidence  string       `json:"confidence"` // high, medium or low
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Redactions  []Redaction  `json:"redactions,omitempty"`
+	SuspiciousContent bool   `json:"suspicious_content,omitempty"`
+	InjectionFindings []InjectionFinding `json:"injection_findings,omitempty"`
+}
+
+// PRDescriptionRequest asks for a pull request title and description
+type PRDescriptionRequest struct {
+	AIModel    string   `json:"ai_model"`
+	AIProvider string   `json:"ai_provider"`
+	Language   string   `json:"language"`
+	GitDiff    string   `json:"git_diff"`
+	GitInfo    *GitInfo `json:"git_info,omitempty"`
+	Notes      string   `json:"notes,omitempty"` // The author's notes on intent, e.g. the issue being fixed
+
+	// InjectionFindings lists suspected prompt-injection attempts in the
+	// diff or notes; set by the gateway
+	InjectionFindings []InjectionFinding `json:"-"`
+}
+
+// PRDescription is a generated pull request title and description
+type PRDescription struct {
+	Title        string   `json:"title"`
+	Summary      string   `json:"summary"`   // Markdown paragraphs on what changed and why
+	Changelog    []string `json:"changelog"` // One entry per user-visible change
+	SkippedFiles []string `json:"skipped_files,omitempty"`
+	Redactions   []Redaction `json:"redactions,omitempty"`
+	SuspiciousContent bool   `json:"suspicious_content,omitempty"`
+	InjectionFindings []InjectionFinding `json:"injection_findings,omitempty"`
+}
+
+// DocsRequest asks for documentation of the declarations a diff adds, or
+// of the undocumented declarations in a file
+type DocsRequest struct {
+	AIModel          string       `json:"ai_model"`
+	AIProvider       string       `json:"ai_provider"`
+	Language         string       `json:"language"`
+	GitDiff          string       `json:"git_diff,omitempty"`
+	File             *FileContent `json:"file,omitempty"` // Instead of git_diff
+	GitInfo          *GitInfo     `json:"git_info,omitempty"`
+	SuggestionBlocks bool         `json:"suggestion_blocks,omitempty"` // Also render each comment as a GitHub suggestion block
+
+	// InjectionFindings lists suspected prompt-injection attempts in the
+	// diff or file; set by the gateway
+	InjectionFindings []InjectionFinding `json:"-"`
+}
+
+// DocsResponse is the documentation proposed for a diff or file
+type DocsResponse struct {
+	Docs              []DocComment       `json:"docs"`
+	SkippedFiles      []string           `json:"skipped_files,omitempty"`
+	Redactions        []Redaction        `json:"redactions,omitempty"`
+	SuspiciousContent bool               `json:"suspicious_content,omitempty"`
+	InjectionFindings []InjectionFinding `json:"injection_findings,omitempty"`
+}
+
+// DocComment is a doc comment proposed for one declaration
+type DocComment struct {
+	Path       string `json:"path"`
+	Line       int    `json:"line"`   // Line of the declaration in the new file
+	Symbol     string `json:"symbol"` // Name of the function, type or package documented
+	Comment    string `json:"comment"`
+	Diff       string `json:"diff"`                 // Unified diff inserting the comment, for git apply
+	Suggestion string `json:"suggestion,omitempty"` // GitHub suggestion block for a comment on Line, with suggestion_blocks
 }
 
+// FollowupRequest is a question about a completed review
+type FollowupRequest struct {
+	Question   string `json:"question"`
+	Diagnostic *int   `json:"diagnostic,omitempty"` // Index into the review's diagnostics
+}
+
+// Conversation turn roles
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// ConversationTurn is one message in a follow-up conversation
+type ConversationTurn struct {
+	Role    string `json:"role"` // user or assistant
+	Content string `json:"content"`
+}
+
+// FollowupResponse is the answer to a FollowupRequest
+type FollowupResponse struct {
+	ReviewID string `json:"review_id"`
+	Answer   string `json:"answer"`
+	Turns    int    `json:"turns"` // Conversation turns kept for this review
+}
+
+// ReviewRecord is a completed review kept in the review history
+type ReviewRecord struct {
+	ID               string       `json:"id"`
+	Tenant           string       `json:"tenant,omitempty"` // Client the review belongs to; admin views only
+	CreatedAt        time.Time    `json:"created_at"`
+	Repository       string       `json:"repository,omitempty"` // Normalized to host/owner/name
+	PRNumber         string       `json:"pr_number,omitempty"`
+	CommitHash       string       `json:"commit_hash,omitempty"`
+	BranchName       string       `json:"branch_name,omitempty"`
+	AIProvider       string       `json:"ai_provider"`
+	AIModel          string       `json:"ai_model"`
+	ReviewMode       string       `json:"review_mode"`
+	Language         string       `json:"language"`
+	DiffBytes        int          `json:"diff_bytes"`
+	LatencyMs        int64        `json:"latency_ms"`
+	PromptTokens     int          `json:"prompt_tokens,omitempty"`
+	CompletionTokens int          `json:"completion_tokens,omitempty"`
+	CostUSD          *float64     `json:"cost_usd,omitempty"`
+	Batched          bool         `json:"batched,omitempty"` // Sent through the provider's batch API, at its discount
+	CachedTokens     int          `json:"cached_tokens,omitempty"` // Prompt tokens read from the provider's context cache, at its discount
+	Overview         string       `json:"overview,omitempty"`
+	DiagnosticCount  int          `json:"diagnostic_count"`
+	SeverityCounts   map[string]int `json:"severity_counts,omitempty"`
+	Diagnostics      []Diagnostic `json:"diagnostics,omitempty"` // Omitted from listings
+	Suppressed       *SuppressionSummary `json:"suppressed,omitempty"`
+	PromptVersion    string       `json:"prompt_version,omitempty"`
+	Feedback         []Feedback   `json:"feedback,omitempty"` // Verdicts on the diagnostics
+	Prompts          []PromptPreview `json:"prompts,omitempty"` // Prompts of each provider call, when HISTORY_PROMPTS is set; omitted from listings
+}
+
+// ReviewList is a page of the review history, newest first
+type ReviewList struct {
+	Reviews []ReviewRecord `json:"reviews"`
+	Total   int            `json:"total"` // Matching reviews before paging
+}
+
+// UsageBucket sums the reviews completed in one period
+type UsageBucket struct {
+	Start            time.Time `json:"start"`
+	Reviews          int       `json:"reviews"`
+	Diagnostics      int       `json:"diagnostics"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	CostUSD          float64   `json:"cost_usd"`
+	AvgLatencyMs     int64     `json:"avg_latency_ms"`
+}
+
+// FeedbackRequest marks one diagnostic of a review as helpful or a false
+// positive
+type FeedbackRequest struct {
+	Diagnostic *int   `json:"diagnostic"` // Index into the review's diagnostics
+	Verdict    string `json:"verdict"`    // helpful or false-positive
+	Comment    string `json:"comment,omitempty"`
+}
+
+// Feedback is a developer's verdict on one diagnostic
+type Feedback struct {
+	ReviewID      string    `json:"review_id"`
+	Diagnostic    int       `json:"diagnostic"`
+	Verdict       string    `json:"verdict"`
+	Comment       string    `json:"comment,omitempty"`
+	Repository    string    `json:"repository,omitempty"`
+	Path          string    `json:"path"`
+	Category      string    `json:"category"`
+	Message       string    `json:"message"`
+	PromptVersion string    `json:"prompt_version,omitempty"` // Of the review, for scoring prompt experiments
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// FeedbackExample is a finding developers repeatedly rejected, shown to
+// the model so it stops reporting similar issues
+type FeedbackExample struct {
+	Path     string
+	Category string
+	Message  string
+	Comment  string // Most recent reason given, if any
+	Votes    int    // False-positive votes minus helpful votes
+}
+
+// PromptVersionStats is the feedback a client gave on the reviews of one
+// prompt version
+type PromptVersionStats struct {
+	Version        string   `json:"version"`
+	Weight         int      `json:"weight,omitempty"` // Share of new reviews in the running experiment
+	Reviews        int      `json:"reviews"`          // Reviews in the history
+	Helpful        int      `json:"helpful"`
+	FalsePositives int      `json:"false_positives"`
+	HelpfulRate    *float64 `json:"helpful_rate,omitempty"` // Helpful share of the verdicts, once there are any
+}
+
+// PromptVersionList lists the registered prompt versions with their scores
+type PromptVersionList struct {
+	Experiment bool                 `json:"experiment"` // Whether new reviews are split between versions
+	Versions   []PromptVersionStats `json:"versions"`
+}
+
+// CompareTarget is a provider/model pair to run in a comparison
+type CompareTarget struct {
+	AIProvider string `json:"ai_provider"`
+	AIModel    string `json:"ai_model,omitempty"` // Empty selects the provider's default model
+}
+
+// CompareResult is the outcome of one CompareTarget
+type CompareResult struct {
+	AIProvider       string          `json:"ai_provider"`
+	AIModel          string          `json:"ai_model,omitempty"`
+	LatencyMs        int64           `json:"latency_ms"`
+	PromptTokens     int             `json:"prompt_tokens,omitempty"`
+	CompletionTokens int             `json:"completion_tokens,omitempty"`
+	CostUSD          *float64        `json:"cost_usd,omitempty"` // Omitted when the model has no known price
+	Review           *ReviewResponse `json:"review,omitempty"`
+	Error            string          `json:"error,omitempty"`
+}
+
+// CompareResponse holds side-by-side results for POST /review/compare
+type CompareResponse struct {
+	Results []CompareResult `json:"results"`
+}
+
+// BatchError is why one review of a batch failed
+type BatchError struct {
+	Status  int    `json:"status"` // The status /review would have answered with
+	Message string `json:"message"`
+}
+
+// BatchItemResult is the outcome of one review of POST /review/batch
+type BatchItemResult struct {
+	Index  int             `json:"index"`            // Position of the review in the batch
+	Status string          `json:"status"`           // completed, pending (sent to its callback_url) or failed
+	ID     string          `json:"id,omitempty"`     // Review ID, or job ID of a pending review
+	Review *ReviewResponse `json:"review,omitempty"` // Completed reviews only
+	Error  *BatchError     `json:"error,omitempty"`
+}
+
+// BatchResponse is the answer to a JSON POST /review/batch
+type BatchResponse struct {
+	Items     []BatchItemResult `json:"items"` // In the order of the request
+	Completed int               `json:"completed"`
+	Pending   int               `json:"pending"`
+	Failed    int               `json:"failed"`
+}
+
+// IngestRequest is a review request read from the message queue
+type IngestRequest struct {
+	RequestID string `json:"request_id,omitempty"` // Echoed in the result; defaults to the Kafka record key
+	ReviewRequest
+}
+
+// IngestResult is published for each review request read from the queue
+type IngestResult struct {
+	RequestID string          `json:"request_id,omitempty"`
+	Status    string          `json:"status"`           // completed or failed
+	ID        string          `json:"id,omitempty"`     // Review ID
+	Review    *ReviewResponse `json:"review,omitempty"` // Completed reviews only
+	Error     *BatchError     `json:"error,omitempty"`
+}
+
+// ExpectedFinding is a finding a labeled eval diff should be reviewed with
+type ExpectedFinding struct {
+	Path     string `json:"path" yaml:"path"`
+	Line     int    `json:"line" yaml:"line"`
+	Category string `json:"category,omitempty" yaml:"category"` // Required to match when set
+	Note     string `json:"note,omitempty" yaml:"note"`         // What the finding is, for people reading results
+}
+
+// EvalRequest is the body of POST /eval/run
+type EvalRequest struct {
+	AIProvider    string   `json:"ai_provider"`
+	AIModel       string   `json:"ai_model,omitempty"`
+	PromptVersion string   `json:"prompt_version,omitempty"`
+	ReviewMode    string   `json:"review_mode,omitempty"` // Overrides the cases' modes
+	Cases         []string `json:"cases,omitempty"`       // Names of the cases to run; empty runs all
+	ModelParams
+}
+
+// EvalCaseResult is the score of one eval case
+type EvalCaseResult struct {
+	Name           string            `json:"name"`
+	Expected       int               `json:"expected"`
+	Reported       int               `json:"reported"`
+	TruePositives  int               `json:"true_positives"`
+	FalsePositives int               `json:"false_positives"`
+	FalseNegatives int               `json:"false_negatives"`
+	Precision      float64           `json:"precision"`
+	Recall         float64           `json:"recall"`
+	F1             float64           `json:"f1"`
+	Missed         []ExpectedFinding `json:"missed,omitempty"`
+	Diagnostics    []Diagnostic      `json:"diagnostics,omitempty"` // What the review reported
+	LatencyMs      int64             `json:"latency_ms"`
+	Error          string            `json:"error,omitempty"` // Set when the review failed; the case isn't scored
+}
+
+// EvalResponse holds the scores of an eval run, over all cases that
+// completed
+type EvalResponse struct {
+	AIProvider       string           `json:"ai_provider"`
+	AIModel          string           `json:"ai_model"`
+	PromptVersion    string           `json:"prompt_version,omitempty"`
+	Cases            []EvalCaseResult `json:"cases"`
+	Failed           int              `json:"failed"` // Cases whose review failed
+	TruePositives    int              `json:"true_positives"`
+	FalsePositives   int              `json:"false_positives"`
+	FalseNegatives   int              `json:"false_negatives"`
+	Precision        float64          `json:"precision"`
+	Recall           float64          `json:"recall"`
+	F1               float64          `json:"f1"`
+	PromptTokens     int              `json:"prompt_tokens"`
+	CompletionTokens int              `json:"completion_tokens"`
+	CostUSD          *float64         `json:"cost_usd,omitempty"`
+}
+
+// FileStats summarizes the changes to one file of a diff
+type FileStats struct {
+	Path     string `json:"path"`
+	Language string `json:"language,omitempty"`
+	Added    int    `json:"lines_added"`
+	Removed  int    `json:"lines_removed"`
+	Binary    bool   `json:"binary,omitempty"`
+	Skipped   bool   `json:"skipped,omitempty"`   // Ignored, generated, binary or too large; not sent to the model
+	Truncated bool   `json:"truncated,omitempty"` // Only partly sent to the model
+}
+
+// EstimateResponse is the pre-flight estimate returned by
+// POST /review/estimate
+type EstimateResponse struct {
+	AIProvider        string      `json:"ai_provider"`
+	AIModel           string      `json:"ai_model,omitempty"`
+	FilesChanged      int         `json:"files_changed"`
+	LinesAdded        int         `json:"lines_added"`
+	LinesRemoved      int         `json:"lines_removed"`
+	Files             []FileStats `json:"files"`
+	DetectedLanguages []string    `json:"detected_languages,omitempty"`
+	ProviderCalls     int         `json:"provider_calls"`
+	PromptTokens      int         `json:"estimated_prompt_tokens"`
+	CompletionTokens  int         `json:"max_completion_tokens"`
+	CostUSD           *float64    `json:"estimated_cost_usd,omitempty"` // Omitted when the model has no known price
+	ContextWindow     int         `json:"context_window,omitempty"`     // Omitted when the model's window is unknown
+	ChunkingRequired  bool        `json:"chunking_required"`
+}
+
+// DryRunResponse is the prompts a review would send, returned by
+// POST /review/dry-run
+type DryRunResponse struct {
+	AIProvider     string          `json:"ai_provider"`
+	AIModel        string          `json:"ai_model,omitempty"`
+	ReviewMode     string          `json:"review_mode"`
+	Calls          []PromptPreview `json:"calls"`
+	PromptTokens   int             `json:"estimated_prompt_tokens"`
+	CritiquePass   bool            `json:"critique_pass,omitempty"` // Refined reviews send each call's findings back for a second pass
+	SkippedFiles   []string        `json:"skipped_files,omitempty"`
+	TruncatedFiles []TruncatedFile `json:"truncated_files,omitempty"`
+	Redactions     []Redaction     `json:"redactions,omitempty"`
+}
+
+// PromptPreview is one provider call of a dry run
+type PromptPreview struct {
+	Language     string   `json:"language"`
+	Files        []string `json:"files"`
+	Anonymized   bool     `json:"anonymized,omitempty"` // Paths and identifiers are replaced before the provider sees them
+	SystemPrompt string   `json:"system_prompt"`
+	UserPrompt   string   `json:"user_prompt"`
+	PromptTokens int      `json:"estimated_prompt_tokens"`
+}
+
+// IndexDocument is a file or guideline to add to the repository index
+type IndexDocument struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+	Kind    string `json:"kind,omitempty"` // code (default) or guideline
+}
+
+// IndexRequest represents a POST /index request
+type IndexRequest struct {
+	Repository string          `json:"repository"` // Repository URL, matched against git_info.repo_url
+	Documents  []IndexDocument `json:"documents"`
+	Replace    bool            `json:"replace,omitempty"` // Drop everything previously indexed for the repository
+}
+
+// IndexStats describes an indexed repository
+type IndexStats struct {
+	Repository string    `json:"repository"`
+	Documents  int       `json:"documents"`
+	Chunks     int       `json:"chunks"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ContextSnippet is related code or guidance retrieved from the repository
+// index for a review
+type ContextSnippet struct {
+	Path      string  `json:"path"`
+	Kind      string  `json:"kind"`
+	StartLine int     `json:"start_line"`
+	EndLine   int     `json:"end_line"`
+	Content   string  `json:"-"`
+	Score     float64 `json:"score"`
+}
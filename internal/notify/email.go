@@ -0,0 +1,225 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/output"
+)
+
+// SMTPConfig is the mail server email reports are sent through
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // Empty sends without authenticating
+	Password string
+	From     string
+	TLS      string // starttls, tls (implicit, usually port 465) or none
+}
+
+// Mailer sends email through an SMTP server
+type Mailer struct {
+	config SMTPConfig
+}
+
+// NewMailer creates a mailer. It returns nil when no host is configured.
+func NewMailer(cfg SMTPConfig) *Mailer {
+	if cfg.Host == "" {
+		return nil
+	}
+	return &Mailer{config: cfg}
+}
+
+// Send delivers a message with plain text and HTML alternatives
+func (m *Mailer) Send(ctx context.Context, to []string, subject, text, html string) error {
+	message, err := m.compose(to, subject, text, html)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	tlsConfig := &tls.Config{ServerName: m.config.Host}
+	dialer := &net.Dialer{Timeout: notifyTimeout}
+	var conn net.Conn
+	if m.config.TLS == "tls" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if m.config.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS; set SMTP_TLS=tls or none", addr)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	if m.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(envelopeAddress(m.config.From)); err != nil {
+		return fmt.Errorf("sender rejected: %w", err)
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(envelopeAddress(rcpt)); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return client.Quit()
+}
+
+// compose builds a multipart/alternative message
+func (m *Mailer) compose(to []string, subject, text, html string) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, alternative := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", html},
+	} {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alternative.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to compose email: %w", err)
+		}
+		qp := quotedprintable.NewWriter(part)
+		qp.Write([]byte(alternative.content))
+		qp.Close()
+	}
+	parts.Close()
+
+	var message bytes.Buffer
+	header := []struct{ name, value string }{
+		{"From", m.config.From},
+		{"To", strings.Join(to, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", messageID(m.config.From)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + parts.Boundary()},
+	}
+	for _, h := range header {
+		fmt.Fprintf(&message, "%s: %s\r\n", h.name, h.value)
+	}
+	message.WriteString("\r\n")
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
+
+// envelopeAddress strips the display name from an address, e.g.
+// "AI Review <reviews@example.com>" becomes reviews@example.com
+func envelopeAddress(address string) string {
+	if addr, err := mail.ParseAddress(address); err == nil {
+		return addr.Address
+	}
+	return address
+}
+
+// messageID returns a unique Message-ID in the sender's domain
+func messageID(from string) string {
+	domain := "ai-gateway.local"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndex(addr.Address, "@"); at >= 0 {
+			domain = addr.Address[at+1:]
+		}
+	}
+	b := make([]byte, 12)
+	rand.Read(b)
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain)
+}
+
+// EmailConfig selects email recipients in a notification route
+type EmailConfig struct {
+	To []string `yaml:"to"`
+}
+
+// notifier creates the configured email notifier
+func (c *EmailConfig) notifier(mailer *Mailer) (*Email, error) {
+	if mailer == nil {
+		return nil, fmt.Errorf("SMTP_HOST is not set")
+	}
+	if len(c.To) == 0 {
+		return nil, fmt.Errorf("no recipients")
+	}
+	return NewEmail(mailer, c.To)
+}
+
+// Email sends the full review report to a list of recipients
+type Email struct {
+	mailer *Mailer
+	to     []string
+}
+
+// NewEmail creates an email notifier
+func NewEmail(mailer *Mailer, to []string) (*Email, error) {
+	for _, rcpt := range to {
+		if _, err := mail.ParseAddress(rcpt); err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", rcpt, err)
+		}
+	}
+	return &Email{mailer: mailer, to: to}, nil
+}
+
+// Name identifies the notifier in logs
+func (e *Email) Name() string {
+	return "Email"
+}
+
+// Notify mails the review report
+func (e *Email) Notify(ctx context.Context, summary Summary) error {
+	report := output.Report{
+		Title:       summary.Title(),
+		Link:        summary.Link,
+		Overview:    summary.Overview,
+		Diagnostics: summary.Diagnostics,
+		Footer:      fmt.Sprintf("Reviewed by %s/%s, review %s", summary.Provider, summary.Model, summary.ID),
+	}
+	html, err := report.HTML()
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("AI review of %s: %s", summary.Title(), summary.Counts())
+	return e.mailer.Send(ctx, e.to, subject, report.Markdown(), html)
+}
@@ -0,0 +1,373 @@
+// Package notify tells teams about finished reviews through chat and
+// other channels
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/postprocess"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scm"
+	"gopkg.in/yaml.v3"
+)
+
+// notifyTimeout bounds the delivery of one notification
+const notifyTimeout = 30 * time.Second
+
+// Summary is what notifications report about a finished review
+type Summary struct {
+	ID       string `json:"id"`
+	Repo     string `json:"repo_url,omitempty"` // As sent by the client
+	PRNumber string `json:"pr_number,omitempty"`
+	Branch   string `json:"branch,omitempty"`
+	Commit   string `json:"commit,omitempty"`
+	Link     string `json:"link,omitempty"` // Pull request page, or the repository when there is none
+	Tenant   string `json:"tenant,omitempty"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Overview string `json:"overview,omitempty"`
+	Errors   int    `json:"errors"`
+	Warnings int    `json:"warnings"`
+	Infos    int    `json:"infos"`
+
+	Diagnostics []models.Diagnostic `json:"-"` // For full reports
+}
+
+// NewSummary summarizes a review for notifications
+func NewSummary(id, tenant string, request models.ReviewRequest, response models.ReviewResponse) Summary {
+	summary := Summary{
+		ID:       id,
+		Tenant:   tenant,
+		Provider: request.AIProvider,
+		Model:    request.AIModel,
+		Overview: response.Overview,
+
+		Diagnostics: response.Diagnostics,
+	}
+	if info := request.GitInfo; info != nil {
+		summary.Repo = info.RepoURL
+		summary.PRNumber = info.PRNumber
+		summary.Branch = info.BranchName
+		summary.Commit = info.CommitHash
+		summary.Link = pullRequestURL(info.RepoURL, info.PRNumber)
+	}
+	for _, d := range response.Diagnostics {
+		switch strings.ToUpper(d.Severity) {
+		case "ERROR":
+			summary.Errors++
+		case "WARNING":
+			summary.Warnings++
+		default:
+			summary.Infos++
+		}
+	}
+	return summary
+}
+
+// Title names the reviewed change, e.g. "acme/api #42"
+func (s Summary) Title() string {
+	name := "Review " + s.ID
+	if s.Repo != "" {
+		name = scm.NormalizeRepository(s.Repo)
+		if parts := strings.SplitN(name, "/", 2); len(parts) == 2 && strings.Contains(parts[0], ".") {
+			name = parts[1]
+		}
+	}
+	switch {
+	case s.PRNumber != "":
+		return name + " #" + s.PRNumber
+	case s.Branch != "":
+		return name + " (" + s.Branch + ")"
+	}
+	return name
+}
+
+// Counts describes the findings, e.g. "2 errors, 1 warning, 0 info"
+func (s Summary) Counts() string {
+	return fmt.Sprintf("%s, %s, %d info", plural(s.Errors, "error"), plural(s.Warnings, "warning"), s.Infos)
+}
+
+// worst returns the most severe finding's severity, or "" without findings
+func (s Summary) worst() string {
+	switch {
+	case s.Errors > 0:
+		return "ERROR"
+	case s.Warnings > 0:
+		return "WARNING"
+	case s.Infos > 0:
+		return "INFO"
+	}
+	return ""
+}
+
+// Notifier delivers review summaries to one destination
+type Notifier interface {
+	Notify(ctx context.Context, summary Summary) error
+	Name() string // Used in logs
+}
+
+// Route sends the reviews it matches to its notifiers. A route matches a
+// review when all of the conditions it sets do.
+type Route struct {
+	Name        string         `yaml:"name"`
+	Repos       []string       `yaml:"repos"`        // Globs over host/owner/name or owner/name
+	Tenants     []string       `yaml:"tenants"`      // Tenants from TENANTS_FILE
+	Branches    []string       `yaml:"branches"`     // Globs over git_info.branch_name, e.g. main or release/*
+	MinSeverity string         `yaml:"min_severity"` // Only notify when a finding is at least this severe
+	Slack       *SlackConfig   `yaml:"slack"`
+	Teams       *TeamsConfig   `yaml:"teams"`
+	Webhook     *WebhookConfig `yaml:"webhook"`
+	Email       *EmailConfig   `yaml:"email"`
+
+	notifiers []Notifier
+}
+
+// NewRoute creates a route matching every review
+func NewRoute(name string, notifiers ...Notifier) *Route {
+	return &Route{Name: name, notifiers: notifiers}
+}
+
+// matches reports whether the route covers a review
+func (r *Route) matches(summary Summary) bool {
+	if len(r.Repos) > 0 && !scm.MatchRepository(r.Repos, summary.Repo) {
+		return false
+	}
+	if len(r.Tenants) > 0 && !contains(r.Tenants, summary.Tenant) {
+		return false
+	}
+	if len(r.Branches) > 0 && !matchesAny(r.Branches, summary.Branch) {
+		return false
+	}
+	if r.MinSeverity != "" && postprocess.SeverityRank(summary.worst()) < postprocess.SeverityRank(r.MinSeverity) {
+		return false
+	}
+	return true
+}
+
+// build creates the route's notifiers from its settings
+func (r *Route) build(secret func(name string) string, mailer *Mailer) error {
+	if r.Slack != nil {
+		slack, err := r.Slack.notifier(secret)
+		if err != nil {
+			return fmt.Errorf("slack: %w", err)
+		}
+		r.notifiers = append(r.notifiers, slack)
+	}
+	if r.Teams != nil {
+		teams, err := r.Teams.notifier(secret)
+		if err != nil {
+			return fmt.Errorf("teams: %w", err)
+		}
+		r.notifiers = append(r.notifiers, teams)
+	}
+	if r.Webhook != nil {
+		hook, err := r.Webhook.notifier(secret)
+		if err != nil {
+			return fmt.Errorf("webhook: %w", err)
+		}
+		r.notifiers = append(r.notifiers, hook)
+	}
+	if r.Email != nil {
+		email, err := r.Email.notifier(mailer)
+		if err != nil {
+			return fmt.Errorf("email: %w", err)
+		}
+		r.notifiers = append(r.notifiers, email)
+	}
+	if len(r.notifiers) == 0 {
+		return fmt.Errorf("no destination configured")
+	}
+	return nil
+}
+
+// Load reads notification routes from a YAML or JSON file. secret looks
+// up the variables holding webhook URLs and tokens; email is sent through
+// mailer.
+func Load(file string, secret func(name string) string, mailer *Mailer) ([]*Route, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notifications file: %w", err)
+	}
+
+	var doc struct {
+		Routes []*Route `yaml:"routes"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse notifications file %s: %w", file, err)
+	}
+
+	for i, route := range doc.Routes {
+		if route.Name == "" {
+			route.Name = fmt.Sprintf("route %d", i)
+		}
+		switch strings.ToUpper(route.MinSeverity) {
+		case "", "INFO", "WARNING", "ERROR":
+			route.MinSeverity = strings.ToUpper(route.MinSeverity)
+		default:
+			return nil, fmt.Errorf("notifications file %s: %s: min_severity must be INFO, WARNING or ERROR", file, route.Name)
+		}
+		for _, pattern := range route.Branches {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("notifications file %s: %s: invalid branch pattern %q", file, route.Name, pattern)
+			}
+		}
+		if err := route.build(secret, mailer); err != nil {
+			return nil, fmt.Errorf("notifications file %s: %s: %w", file, route.Name, err)
+		}
+	}
+	return doc.Routes, nil
+}
+
+// Dispatcher sends notifications in the background so they never delay a
+// review response
+type Dispatcher struct {
+	routes  []*Route
+	pending sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher for routes. It returns nil when there
+// are none; a nil Dispatcher is safe to use and sends nothing.
+func NewDispatcher(routes []*Route) *Dispatcher {
+	if len(routes) == 0 {
+		return nil
+	}
+	return &Dispatcher{routes: routes}
+}
+
+// Notify sends a review summary to every matching route's notifiers
+func (d *Dispatcher) Notify(summary Summary) {
+	if d == nil {
+		return
+	}
+	for _, route := range d.routes {
+		if !route.matches(summary) {
+			continue
+		}
+		for _, notifier := range route.notifiers {
+			d.pending.Add(1)
+			go func(route string, notifier Notifier) {
+				defer d.pending.Done()
+				ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+				defer cancel()
+				if err := notifier.Notify(ctx, summary); err != nil {
+					log.Printf("Warning: %s notification for review %s (%s) failed: %v", notifier.Name(), summary.ID, route, err)
+				}
+			}(route.Name, notifier)
+		}
+	}
+}
+
+// Wait blocks until notifications in flight finish or ctx is done
+func (d *Dispatcher) Wait(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// postJSON posts body to url and returns the response body, failing on
+// statuses other than 2xx
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("receiver returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
+
+// lookupURL reads a destination URL from the variable name, if set
+func lookupURL(secret func(name string) string, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("the variable holding the URL is required")
+	}
+	value := secret(name)
+	if value == "" {
+		return "", fmt.Errorf("%s is not set", name)
+	}
+	return value, nil
+}
+
+// pullRequestURL links to a pull or merge request on the common hosts,
+// or to the repository
+func pullRequestURL(repo, pr string) string {
+	if repo == "" {
+		return ""
+	}
+	base := "https://" + scm.NormalizeRepository(repo)
+	if pr == "" {
+		return base
+	}
+	switch host := strings.SplitN(scm.NormalizeRepository(repo), "/", 2)[0]; {
+	case host == "github.com":
+		return base + "/pull/" + pr
+	case strings.Contains(host, "gitlab"):
+		return base + "/-/merge_requests/" + pr
+	case host == "bitbucket.org":
+		return base + "/pull-requests/" + pr
+	}
+	return base
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// matchesAny reports whether value matches any of the globs
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
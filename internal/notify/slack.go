@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// slackPostMessageURL is the Web API method used with a bot token
+const slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+
+// slackMaxText is kept under Slack's 3000 character limit for a section
+const slackMaxText = 2900
+
+// SlackConfig selects a Slack destination in a notification route: an
+// incoming webhook, or a bot token and channel
+type SlackConfig struct {
+	WebhookURLEnv string `yaml:"webhook_url_env"` // Variable holding an incoming webhook URL
+	BotTokenEnv   string `yaml:"bot_token_env"`   // Variable holding a bot token, used with channel
+	Channel       string `yaml:"channel"`
+}
+
+// notifier creates the configured Slack notifier
+func (c *SlackConfig) notifier(secret func(name string) string) (*Slack, error) {
+	var webhookURL, botToken string
+	if c.WebhookURLEnv != "" {
+		if webhookURL = secret(c.WebhookURLEnv); webhookURL == "" {
+			return nil, fmt.Errorf("%s is not set", c.WebhookURLEnv)
+		}
+	}
+	if c.BotTokenEnv != "" {
+		if botToken = secret(c.BotTokenEnv); botToken == "" {
+			return nil, fmt.Errorf("%s is not set", c.BotTokenEnv)
+		}
+	}
+	return NewSlack(webhookURL, botToken, c.Channel)
+}
+
+// Slack posts review summaries to a Slack channel
+type Slack struct {
+	client     *http.Client
+	webhookURL string
+	botToken   string
+	channel    string
+	apiURL     string
+}
+
+// NewSlack creates a Slack notifier. With a bot token, messages are posted
+// to channel through the Web API; otherwise to the incoming webhook, whose
+// own channel is used.
+func NewSlack(webhookURL, botToken, channel string) (*Slack, error) {
+	if botToken != "" && channel == "" {
+		return nil, fmt.Errorf("a bot token needs a channel")
+	}
+	if botToken == "" && webhookURL == "" {
+		return nil, fmt.Errorf("a webhook URL or a bot token is required")
+	}
+	return &Slack{
+		client:     &http.Client{Timeout: notifyTimeout},
+		webhookURL: webhookURL,
+		botToken:   botToken,
+		channel:    channel,
+		apiURL:     slackPostMessageURL,
+	}, nil
+}
+
+// Name identifies the notifier in logs
+func (s *Slack) Name() string {
+	return "Slack"
+}
+
+// Notify posts a summary message
+func (s *Slack) Notify(ctx context.Context, summary Summary) error {
+	message := slackMessage(summary)
+	target := s.webhookURL
+	if s.botToken != "" {
+		message["channel"] = s.channel
+		target = s.apiURL
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode Slack message: %w", err)
+	}
+	header := http.Header{}
+	if s.botToken != "" {
+		header.Set("Authorization", "Bearer "+s.botToken)
+	}
+	respBody, err := postJSON(ctx, s.client, target, body, header)
+	if err != nil {
+		return fmt.Errorf("failed to post to Slack: %w", err)
+	}
+
+	// The Web API reports failures in the body of a 200 response
+	if s.botToken != "" {
+		var result struct {
+			OK    bool   `json:"ok"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(respBody, &result); err != nil || !result.OK {
+			return fmt.Errorf("Slack rejected the message: %s", result.Error)
+		}
+	}
+	return nil
+}
+
+// slackMessage lays out a summary with Block Kit, with plain text for
+// notifications and clients without blocks
+func slackMessage(summary Summary) map[string]interface{} {
+	title := slackEscape(summary.Title())
+	if summary.Link != "" {
+		title = fmt.Sprintf("<%s|%s>", summary.Link, title)
+	}
+
+	icon := ":white_check_mark:"
+	switch {
+	case summary.Errors > 0:
+		icon = ":red_circle:"
+	case summary.Warnings > 0:
+		icon = ":warning:"
+	}
+
+	blocks := []map[string]interface{}{
+		slackSection(fmt.Sprintf("%s *AI review of %s*\n%s", icon, title, summary.Counts())),
+	}
+	if summary.Overview != "" {
+		blocks = append(blocks, slackSection(truncate(slackEscape(summary.Overview), slackMaxText)))
+	}
+	footer := fmt.Sprintf("%s/%s · review %s", summary.Provider, summary.Model, summary.ID)
+	if summary.Tenant != "" {
+		footer += " · " + summary.Tenant
+	}
+	blocks = append(blocks, map[string]interface{}{
+		"type":     "context",
+		"elements": []map[string]string{{"type": "mrkdwn", "text": slackEscape(footer)}},
+	})
+
+	return map[string]interface{}{
+		"text":   fmt.Sprintf("AI review of %s: %s", summary.Title(), summary.Counts()),
+		"blocks": blocks,
+	}
+}
+
+func slackSection(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "section",
+		"text": map[string]string{"type": "mrkdwn", "text": text},
+	}
+}
+
+// slackEscape escapes the characters Slack treats as markup
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// truncate shortens text to at most max bytes, on a rune boundary
+func truncate(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	cut := max - len("…")
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "…"
+}
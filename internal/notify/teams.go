@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// TeamsConfig selects a Microsoft Teams destination in a notification route
+type TeamsConfig struct {
+	WebhookURLEnv string `yaml:"webhook_url_env"` // Variable holding an incoming webhook or Workflows URL
+}
+
+// notifier creates the configured Teams notifier
+func (c *TeamsConfig) notifier(secret func(name string) string) (*Teams, error) {
+	webhookURL, err := lookupURL(secret, c.WebhookURLEnv)
+	if err != nil {
+		return nil, err
+	}
+	return NewTeams(webhookURL), nil
+}
+
+// Teams posts review summaries to a Microsoft Teams channel as adaptive
+// cards, through an incoming webhook or a Workflows "post to a channel
+// when a webhook request is received" trigger
+type Teams struct {
+	client     *http.Client
+	webhookURL string
+}
+
+// NewTeams creates a Teams notifier
+func NewTeams(webhookURL string) *Teams {
+	return &Teams{
+		client:     &http.Client{Timeout: notifyTimeout},
+		webhookURL: webhookURL,
+	}
+}
+
+// Name identifies the notifier in logs
+func (t *Teams) Name() string {
+	return "Teams"
+}
+
+// Notify posts a summary card
+func (t *Teams) Notify(ctx context.Context, summary Summary) error {
+	body, err := json.Marshal(teamsMessage(summary))
+	if err != nil {
+		return fmt.Errorf("failed to encode Teams message: %w", err)
+	}
+	if _, err := postJSON(ctx, t.client, t.webhookURL, body, nil); err != nil {
+		return fmt.Errorf("failed to post to Teams: %w", err)
+	}
+	return nil
+}
+
+// teamsMessage wraps a summary in an adaptive card
+func teamsMessage(summary Summary) map[string]interface{} {
+	color := "Good"
+	switch {
+	case summary.Errors > 0:
+		color = "Attention"
+	case summary.Warnings > 0:
+		color = "Warning"
+	}
+
+	body := []map[string]interface{}{
+		{"type": "TextBlock", "text": "AI review of " + summary.Title(), "weight": "Bolder", "size": "Medium", "wrap": true},
+		{"type": "TextBlock", "text": summary.Counts(), "color": color, "spacing": "None", "wrap": true},
+	}
+	if summary.Overview != "" {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": truncate(summary.Overview, 4000), "wrap": true})
+	}
+	facts := []map[string]string{
+		{"title": "Model", "value": summary.Provider + "/" + summary.Model},
+		{"title": "Review", "value": summary.ID},
+	}
+	if summary.Tenant != "" {
+		facts = append(facts, map[string]string{"title": "Tenant", "value": summary.Tenant})
+	}
+	body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if summary.Link != "" {
+		title := "Open repository"
+		if summary.PRNumber != "" {
+			title = "Open pull request"
+		}
+		card["actions"] = []map[string]string{{"type": "Action.OpenUrl", "title": title, "url": summary.Link}}
+	}
+
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	}
+}
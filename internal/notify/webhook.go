@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/webhook"
+)
+
+// summaryEvent names the generic webhook event
+const summaryEvent = "review.summary"
+
+// WebhookConfig selects a generic JSON webhook in a notification route
+type WebhookConfig struct {
+	URLEnv    string `yaml:"url_env"`    // Variable holding the URL
+	SecretEnv string `yaml:"secret_env"` // Variable holding a key to sign deliveries with; optional
+}
+
+// notifier creates the configured webhook notifier
+func (c *WebhookConfig) notifier(secret func(name string) string) (*Webhook, error) {
+	url, err := lookupURL(secret, c.URLEnv)
+	if err != nil {
+		return nil, err
+	}
+	var key string
+	if c.SecretEnv != "" {
+		if key = secret(c.SecretEnv); key == "" {
+			return nil, fmt.Errorf("%s is not set", c.SecretEnv)
+		}
+	}
+	return NewWebhook(url, key), nil
+}
+
+// Webhook posts review summaries as JSON to any HTTP endpoint, signed the
+// same way as review callbacks when it has a secret
+type Webhook struct {
+	client *http.Client
+	url    string
+	secret []byte
+}
+
+// NewWebhook creates a generic webhook notifier; an empty secret sends
+// unsigned deliveries
+func NewWebhook(url, secret string) *Webhook {
+	return &Webhook{
+		client: &http.Client{Timeout: notifyTimeout},
+		url:    url,
+		secret: []byte(secret),
+	}
+}
+
+// Name identifies the notifier in logs
+func (w *Webhook) Name() string {
+	return "Webhook"
+}
+
+// Notify posts the summary as a review.summary event
+func (w *Webhook) Notify(ctx context.Context, summary Summary) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"event":   summaryEvent,
+		"summary": summary,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+
+	header := http.Header{}
+	header.Set(webhook.HeaderEvent, summaryEvent)
+	if len(w.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		header.Set(webhook.HeaderTimestamp, timestamp)
+		header.Set(webhook.HeaderSignature, webhook.Sign(w.secret, timestamp, body))
+	}
+	if _, err := postJSON(ctx, w.client, w.url, body, header); err != nil {
+		return fmt.Errorf("failed to post webhook: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// Spec returns the OpenAPI document describing the gateway's API
+func Spec() []byte {
+	return spec
+}
+
+// FieldError describes one invalid field of a request body
+type FieldError struct {
+	Field   string `json:"field"` // Dotted path, e.g. git_info.pr_number or targets[1].ai_provider
+	Message string `json:"message"`
+}
+
+// schema is a JSON schema object from the document
+type schema = map[string]interface{}
+
+// route is a path template from the document split into segments
+type route struct {
+	segments   []string
+	operations map[string]schema // Keyed by upper-case HTTP method
+}
+
+// Validator checks request bodies against the request body schemas in the
+// OpenAPI document. It supports the subset of JSON Schema the document
+// uses: type, properties, required, items, enum, pattern, length, item
+// count and numeric bounds, allOf and $ref.
+type Validator struct {
+	components map[string]schema
+	routes     []route
+	patterns   sync.Map // pattern string -> *regexp.Regexp
+}
+
+// NewValidator creates a validator for the embedded OpenAPI document
+func NewValidator() (*Validator, error) {
+	var doc struct {
+		Paths      map[string]map[string]schema `json:"paths"`
+		Components struct {
+			Schemas map[string]schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+
+	v := &Validator{components: doc.Components.Schemas}
+	for path, item := range doc.Paths {
+		r := route{
+			segments:   strings.Split(strings.Trim(path, "/"), "/"),
+			operations: make(map[string]schema),
+		}
+		for method, operation := range item {
+			r.operations[strings.ToUpper(method)] = operation
+		}
+		v.routes = append(v.routes, r)
+	}
+	// Literal segments win over parameters, e.g. /review/compare over /review/{id}
+	sort.Slice(v.routes, func(i, j int) bool {
+		return literalCount(v.routes[i].segments) > literalCount(v.routes[j].segments)
+	})
+	return v, nil
+}
+
+// BodySchema returns the schema of the request body of an operation for a
+// media type ("application/json" or "multipart/form-data"), and for
+// multipart bodies the names of the parts that hold JSON
+func (v *Validator) BodySchema(method, path, mediaType string) (schema, []string, bool) {
+	operation, ok := v.operation(method, path)
+	if !ok {
+		return nil, nil, false
+	}
+	body, _ := operation["requestBody"].(schema)
+	content, _ := body["content"].(schema)
+	media, ok := content[mediaType].(schema)
+	if !ok {
+		return nil, nil, false
+	}
+	s, ok := media["schema"].(schema)
+	if !ok {
+		return nil, nil, false
+	}
+
+	var jsonParts []string
+	encoding, _ := media["encoding"].(schema)
+	for name, e := range encoding {
+		if enc, ok := e.(schema); ok && enc["contentType"] == "application/json" {
+			jsonParts = append(jsonParts, name)
+		}
+	}
+	sort.Strings(jsonParts)
+	return s, jsonParts, true
+}
+
+// ValidateJSON checks a JSON document against a schema
+func (v *Validator) ValidateJSON(s schema, data []byte) []FieldError {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return []FieldError{{Message: describeSyntaxError(err)}}
+	}
+	var errs []FieldError
+	v.validate(s, value, "", &errs)
+	return errs
+}
+
+// PropertySchema returns the schema of a named property of an object
+// schema
+func (v *Validator) PropertySchema(s schema, name string) (schema, bool) {
+	s = v.resolve(s)
+	properties, _ := s["properties"].(schema)
+	property, ok := properties[name].(schema)
+	return property, ok
+}
+
+// operation finds the operation for a request
+func (v *Validator) operation(method, path string) (schema, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, r := range v.routes {
+		if matches(r.segments, segments) {
+			operation, ok := r.operations[strings.ToUpper(method)]
+			return operation, ok
+		}
+	}
+	return nil, false
+}
+
+// validate checks value against s, appending problems to errs
+func (v *Validator) validate(s schema, value interface{}, field string, errs *[]FieldError) {
+	s = v.resolve(s)
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, sub := range list(s["allOf"]) {
+		if subSchema, ok := sub.(schema); ok {
+			v.validate(subSchema, value, field, errs)
+		}
+	}
+
+	if value == nil {
+		if nullable, _ := s["nullable"].(bool); !nullable && s["type"] != nil {
+			fail("must not be null")
+		}
+		return
+	}
+
+	switch s["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range list(s["required"]) {
+			if _, ok := object[name.(string)]; !ok {
+				*errs = append(*errs, FieldError{Field: join(field, name.(string)), Message: "is required"})
+			}
+		}
+		properties, _ := s["properties"].(schema)
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := properties[name].(schema); ok {
+				v.validate(property, object[name], join(field, name), errs)
+			}
+		}
+
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		if n, ok := number(s["minItems"]); ok && float64(len(array)) < n {
+			fail("must have at least %v items", n)
+		}
+		if n, ok := number(s["maxItems"]); ok && float64(len(array)) > n {
+			fail("must have at most %v items", n)
+		}
+		if items, ok := s["items"].(schema); ok {
+			for i, item := range array {
+				v.validate(items, item, fmt.Sprintf("%s[%d]", field, i), errs)
+			}
+		}
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		length := len([]rune(str))
+		if n, ok := number(s["minLength"]); ok && float64(length) < n {
+			if n == 1 {
+				fail("must not be empty")
+			} else {
+				fail("must be at least %v characters", n)
+			}
+		}
+		if n, ok := number(s["maxLength"]); ok && float64(length) > n {
+			fail("must be at most %v characters", n)
+		}
+		if pattern, ok := s["pattern"].(string); ok && !v.regexp(pattern).MatchString(str) {
+			if description, ok := s["description"].(string); ok {
+				fail("has an invalid value %q: expected %s", str, description)
+			} else {
+				fail("has an invalid value %q", str)
+			}
+		}
+
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		f, err := n.Float64()
+		if !ok || err != nil || s["type"] == "integer" && f != math.Trunc(f) {
+			if s["type"] == "integer" {
+				fail("must be an integer")
+			} else {
+				fail("must be a number")
+			}
+			return
+		}
+		if min, ok := number(s["minimum"]); ok && f < min {
+			fail("must be at least %v", min)
+		}
+		if max, ok := number(s["maximum"]); ok && f > max {
+			fail("must be at most %v", max)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+			return
+		}
+	}
+
+	if enum := list(s["enum"]); len(enum) > 0 {
+		for _, allowed := range enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				return
+			}
+		}
+		var choices []string
+		for _, allowed := range enum {
+			if allowed != "" {
+				choices = append(choices, fmt.Sprint(allowed))
+			}
+		}
+		fail("must be one of %s", strings.Join(choices, ", "))
+	}
+}
+
+// resolve follows a $ref to a component schema
+func (v *Validator) resolve(s schema) schema {
+	for i := 0; i < 10; i++ {
+		ref, ok := s["$ref"].(string)
+		if !ok {
+			return s
+		}
+		target, ok := v.components[strings.TrimPrefix(ref, "#/components/schemas/")]
+		if !ok {
+			return schema{}
+		}
+		s = target
+	}
+	return s
+}
+
+// regexp compiles and caches a schema pattern
+func (v *Validator) regexp(pattern string) *regexp.Regexp {
+	if re, ok := v.patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(pattern)
+	v.patterns.Store(pattern, re)
+	return re
+}
+
+// describeSyntaxError turns a JSON decoding error into a message with the
+// byte offset of the problem
+func describeSyntaxError(err error) string {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Sprintf("invalid JSON at byte %d: %v", syntaxErr.Offset, syntaxErr)
+	}
+	return fmt.Sprintf("invalid JSON: %v", err)
+}
+
+// matches reports whether path segments match a route template
+func matches(template, segments []string) bool {
+	if len(template) != len(segments) {
+		return false
+	}
+	for i, t := range template {
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if t != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func literalCount(segments []string) int {
+	n := 0
+	for _, s := range segments {
+		if !strings.HasPrefix(s, "{") {
+			n++
+		}
+	}
+	return n
+}
+
+func join(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+func list(value interface{}) []interface{} {
+	l, _ := value.([]interface{})
+	return l
+}
+
+func number(value interface{}) (float64, bool) {
+	f, ok := value.(float64)
+	return f, ok
+}
@@ -0,0 +1,133 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// CodeQualityIssue represents a single issue in the GitLab Code Quality
+// report format (a subset of the Code Climate engine specification)
+type CodeQualityIssue struct {
+	Type        string              `json:"type"`
+	CheckName   string              `json:"check_name"`
+	Description string              `json:"description"`
+	Content     *CodeQualityContent `json:"content,omitempty"`
+	Categories  []string            `json:"categories"`
+	Severity    string              `json:"severity"`
+	Fingerprint string              `json:"fingerprint"`
+	Location    CodeQualityLocation `json:"location"`
+}
+
+// CodeQualityContent holds the extended markdown body of an issue
+type CodeQualityContent struct {
+	Body string `json:"body"`
+}
+
+// CodeQualityLocation represents the location of an issue
+type CodeQualityLocation struct {
+	Path  string           `json:"path"`
+	Lines CodeQualityLines `json:"lines"`
+}
+
+// CodeQualityLines represents the line range of an issue
+type CodeQualityLines struct {
+	Begin int `json:"begin"`
+	End   int `json:"end,omitempty"`
+}
+
+// ToCodeQuality converts diagnostics into a GitLab Code Quality report
+func ToCodeQuality(diagnostics []models.Diagnostic) []CodeQualityIssue {
+	issues := make([]CodeQualityIssue, 0, len(diagnostics))
+	seen := make(map[string]int)
+
+	for _, d := range diagnostics {
+		checkName := d.Code.Value
+		if checkName == "" {
+			checkName = "ai-review"
+		}
+
+		// GitLab requires fingerprints to be unique within a report
+		fingerprint := codeQualityFingerprint(d.Location.Path, checkName, d.Message)
+		if n := seen[fingerprint]; n > 0 {
+			seen[fingerprint] = n + 1
+			fingerprint = codeQualityFingerprint(fingerprint, fmt.Sprint(n))
+		} else {
+			seen[fingerprint] = 1
+		}
+
+		line := d.Location.Range.Start.Line
+		if line < 1 {
+			line = 1
+		}
+		endLine := d.Location.Range.End.Line
+		if endLine < line {
+			endLine = line
+		}
+
+		issue := CodeQualityIssue{
+			Type:        "issue",
+			CheckName:   checkName,
+			Description: d.Message,
+			Categories:  []string{codeClimateCategory(checkName)},
+			Severity:    codeQualitySeverity(d.Severity),
+			Fingerprint: fingerprint,
+			Location: CodeQualityLocation{
+				Path: d.Location.Path,
+				Lines: CodeQualityLines{
+					Begin: line,
+					End:   endLine,
+				},
+			},
+		}
+		if d.Suggestion != "" {
+			issue.Content = &CodeQualityContent{Body: d.Suggestion}
+		}
+
+		issues = append(issues, issue)
+	}
+
+	return issues
+}
+
+// codeQualityFingerprint builds a stable fingerprint from the given parts.
+// Line numbers are deliberately excluded so that findings survive unrelated
+// edits that shift code up or down.
+func codeQualityFingerprint(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// codeQualitySeverity maps diagnostic severities to Code Climate severities
+func codeQualitySeverity(severity string) string {
+	switch severity {
+	case "ERROR":
+		return "critical"
+	case "WARNING":
+		return "major"
+	default:
+		return "info"
+	}
+}
+
+// codeClimateCategory maps review categories to Code Climate categories
+func codeClimateCategory(category string) string {
+	switch category {
+	case "possible-bug", "possible-issue":
+		return "Bug Risk"
+	case "performance":
+		return "Performance"
+	case "maintainability":
+		return "Complexity"
+	case "best-practice":
+		return "Style"
+	default:
+		return "Clarity"
+	}
+}
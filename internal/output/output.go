@@ -0,0 +1,23 @@
+package output
+
+import "strings"
+
+// Supported response formats
+const (
+	FormatDiagnostic  = "diagnostic"
+	FormatCodeQuality = "codequality"
+	FormatSARIF       = "sarif"
+)
+
+// NormalizeFormat maps user supplied format names to a supported format.
+// Unknown or empty values fall back to the reviewdog diagnostic format.
+func NormalizeFormat(format string) string {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "codequality", "code-quality", "code_quality", "codeclimate", "gitlab":
+		return FormatCodeQuality
+	case "sarif":
+		return FormatSARIF
+	default:
+		return FormatDiagnostic
+	}
+}
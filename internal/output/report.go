@@ -0,0 +1,138 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// Report is a review laid out for people to read, e.g. in an email
+type Report struct {
+	Title       string
+	Link        string // Pull request or repository page; optional
+	Overview    string
+	Diagnostics []models.Diagnostic
+	Footer      string // e.g. the model that produced the review
+}
+
+// sorted returns the diagnostics most severe first, then by file and line
+func (r Report) sorted() []models.Diagnostic {
+	sorted := append([]models.Diagnostic(nil), r.Diagnostics...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if ra, rb := severityOrder(a.Severity), severityOrder(b.Severity); ra != rb {
+			return ra < rb
+		}
+		if a.Location.Path != b.Location.Path {
+			return a.Location.Path < b.Location.Path
+		}
+		return a.Location.Range.Start.Line < b.Location.Range.Start.Line
+	})
+	return sorted
+}
+
+// Markdown renders the report as Markdown
+func (r Report) Markdown() string {
+	var b strings.Builder
+	title := r.Title
+	if r.Link != "" {
+		title = fmt.Sprintf("[%s](%s)", r.Title, r.Link)
+	}
+	fmt.Fprintf(&b, "# AI review of %s\n\n", title)
+	if r.Overview != "" {
+		fmt.Fprintf(&b, "%s\n\n", r.Overview)
+	}
+
+	diagnostics := r.sorted()
+	if len(diagnostics) == 0 {
+		b.WriteString("No issues found.\n")
+	}
+	for _, d := range diagnostics {
+		fmt.Fprintf(&b, "- **%s** `%s`: %s", strings.ToUpper(d.Severity), location(d), d.Message)
+		if d.Code.Value != "" {
+			fmt.Fprintf(&b, " (%s)", d.Code.Value)
+		}
+		b.WriteString("\n")
+		if d.Suggestion != "" {
+			fmt.Fprintf(&b, "\n  ```\n%s\n  ```\n", indent(d.Suggestion, "  "))
+		}
+	}
+	if r.Footer != "" {
+		fmt.Fprintf(&b, "\n---\n%s\n", r.Footer)
+	}
+	return b.String()
+}
+
+// HTML renders the report as a self-contained HTML document with inline
+// styles, which mail clients require
+func (r Report) HTML() (string, error) {
+	var b bytes.Buffer
+	err := reportTemplate.Execute(&b, map[string]interface{}{
+		"Report":      r,
+		"Diagnostics": r.sorted(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render report: %w", err)
+	}
+	return b.String(), nil
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"location": location,
+	"upper":    strings.ToUpper,
+	"color": func(severity string) string {
+		switch strings.ToUpper(severity) {
+		case "ERROR":
+			return "#c62828"
+		case "WARNING":
+			return "#ef6c00"
+		}
+		return "#1565c0"
+	},
+}).Parse(`<!DOCTYPE html>
+<html><body style="font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;font-size:14px;color:#24292f">
+<h2>AI review of {{if .Report.Link}}<a href="{{.Report.Link}}">{{.Report.Title}}</a>{{else}}{{.Report.Title}}{{end}}</h2>
+{{with .Report.Overview}}<p style="white-space:pre-wrap">{{.}}</p>{{end}}
+{{if .Diagnostics}}<table cellpadding="6" style="border-collapse:collapse;width:100%">
+{{range .Diagnostics}}<tr style="border-top:1px solid #d0d7de;vertical-align:top">
+<td style="color:{{color .Severity}};font-weight:bold">{{upper .Severity}}</td>
+<td><code>{{location .}}</code></td>
+<td>{{.Message}}{{with .Code.Value}} <span style="color:#57606a">({{.}})</span>{{end}}{{with .Suggestion}}<pre style="background:#f6f8fa;padding:8px">{{.}}</pre>{{end}}</td>
+</tr>
+{{end}}</table>{{else}}<p>No issues found.</p>{{end}}
+{{with .Report.Footer}}<p style="color:#57606a;font-size:12px">{{.}}</p>{{end}}
+</body></html>
+`))
+
+// location formats a diagnostic's file and line, or lines when it spans
+// several
+func location(d models.Diagnostic) string {
+	start, end := d.Location.Range.Start.Line, d.Location.Range.End.Line
+	if start > 0 && end > start {
+		return fmt.Sprintf("%s:%d-%d", d.Location.Path, start, end)
+	}
+	if start > 0 {
+		return fmt.Sprintf("%s:%d", d.Location.Path, start)
+	}
+	return d.Location.Path
+}
+
+// severityOrder sorts ERROR before WARNING before everything else
+func severityOrder(severity string) int {
+	switch strings.ToUpper(severity) {
+	case "ERROR":
+		return 0
+	case "WARNING":
+		return 1
+	}
+	return 2
+}
+
+// indent prefixes every line of text
+func indent(text, prefix string) string {
+	return prefix + strings.ReplaceAll(strings.TrimRight(text, "\n"), "\n", "\n"+prefix)
+}
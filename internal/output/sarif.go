@@ -0,0 +1,205 @@
+package output
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// SARIFVersion is the version of the SARIF reports produced
+const SARIFVersion = "2.1.0"
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// SARIFLog is a SARIF 2.1.0 report, as read by GitHub code scanning and
+// most security dashboards
+type SARIFLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SARIFRun `json:"runs"`
+}
+
+// SARIFRun holds the results of one review
+type SARIFRun struct {
+	Tool    SARIFTool     `json:"tool"`
+	Results []SARIFResult `json:"results"`
+}
+
+// SARIFTool describes the reviewer and the rules it reported
+type SARIFTool struct {
+	Driver SARIFDriver `json:"driver"`
+}
+
+// SARIFDriver is the tool component that produced the results
+type SARIFDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []SARIFRule `json:"rules,omitempty"`
+}
+
+// SARIFRule is one diagnostic code, or one CWE for security findings
+type SARIFRule struct {
+	ID         string               `json:"id"`
+	HelpURI    string               `json:"helpUri,omitempty"`
+	Properties *SARIFRuleProperties `json:"properties,omitempty"`
+}
+
+// SARIFRuleProperties classify security rules the way GitHub code scanning
+// reads them
+type SARIFRuleProperties struct {
+	Tags             []string `json:"tags,omitempty"`              // security and external/cwe/cwe-<number>
+	SecuritySeverity string   `json:"security-severity,omitempty"` // CVSS-like score of the most severe result
+	OWASP            []string `json:"owasp,omitempty"`             // OWASP Top 10 categories of the results
+}
+
+// SARIFResult is one finding
+type SARIFResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             SARIFMessage      `json:"message"`
+	Locations           []SARIFLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+}
+
+// SARIFMessage is plain text shown for a result
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+// SARIFLocation points at the code a result is about
+type SARIFLocation struct {
+	PhysicalLocation SARIFPhysicalLocation `json:"physicalLocation"`
+}
+
+// SARIFPhysicalLocation is a file and region
+type SARIFPhysicalLocation struct {
+	ArtifactLocation SARIFArtifactLocation `json:"artifactLocation"`
+	Region           SARIFRegion           `json:"region"`
+}
+
+// SARIFArtifactLocation is a path relative to the repository root
+type SARIFArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// SARIFRegion is a range of lines, and columns when known
+type SARIFRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// ToSARIF converts a review into a SARIF report
+func ToSARIF(source models.Source, diagnostics []models.Diagnostic) SARIFLog {
+	name := source.Name
+	if name == "" {
+		name = "ai-review"
+	}
+
+	rules := make(map[string]SARIFRule)
+	results := make([]SARIFResult, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		// Security findings are grouped by weakness, so dashboards can
+		// link and count them by CWE
+		ruleID := d.Code.Value
+		if d.CWE != "" {
+			ruleID = d.CWE
+		}
+		if ruleID == "" {
+			ruleID = "ai-review"
+		}
+		rule, ok := rules[ruleID]
+		if !ok {
+			rule = SARIFRule{ID: ruleID, HelpURI: d.Code.URL}
+		}
+		if d.CWE != "" {
+			addSecurityProperties(&rule, d)
+		}
+		rules[ruleID] = rule
+
+		start := d.Location.Range.Start
+		end := d.Location.Range.End
+		region := SARIFRegion{StartLine: max(start.Line, 1), StartColumn: start.Column}
+		if end.Line >= region.StartLine {
+			region.EndLine = end.Line
+			region.EndColumn = end.Column
+		}
+
+		result := SARIFResult{
+			RuleID:  ruleID,
+			Level:   sarifLevel(d.Severity),
+			Message: SARIFMessage{Text: d.Message},
+			Locations: []SARIFLocation{{
+				PhysicalLocation: SARIFPhysicalLocation{
+					ArtifactLocation: SARIFArtifactLocation{URI: d.Location.Path},
+					Region:           region,
+				},
+			}},
+		}
+		if d.Fingerprint != "" {
+			result.PartialFingerprints = map[string]string{"aiReview/v1": d.Fingerprint}
+		}
+		results = append(results, result)
+	}
+
+	driver := SARIFDriver{Name: name, InformationURI: source.URL}
+	for _, rule := range rules {
+		driver.Rules = append(driver.Rules, rule)
+	}
+	sort.Slice(driver.Rules, func(i, j int) bool {
+		return driver.Rules[i].ID < driver.Rules[j].ID
+	})
+
+	return SARIFLog{
+		Schema:  sarifSchema,
+		Version: SARIFVersion,
+		Runs:    []SARIFRun{{Tool: SARIFTool{Driver: driver}, Results: results}},
+	}
+}
+
+// securitySeverities are the GitHub security-severity scores for each
+// diagnostic severity: high, medium and low
+var securitySeverities = map[string]string{
+	"ERROR":   "8.0",
+	"WARNING": "5.0",
+	"INFO":    "2.0",
+}
+
+// addSecurityProperties tags a rule with the CWE and OWASP category of a
+// security finding, keeping the highest severity seen
+func addSecurityProperties(rule *SARIFRule, d models.Diagnostic) {
+	if rule.Properties == nil {
+		rule.Properties = &SARIFRuleProperties{
+			Tags: []string{"security", "external/cwe/" + strings.ToLower(d.CWE)},
+		}
+	}
+	if score := securitySeverities[d.Severity]; score > rule.Properties.SecuritySeverity {
+		rule.Properties.SecuritySeverity = score
+	}
+	if d.OWASP != "" && !contains(rule.Properties.OWASP, d.OWASP) {
+		rule.Properties.OWASP = append(rule.Properties.OWASP, d.OWASP)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sarifLevel maps diagnostic severities to SARIF levels
+func sarifLevel(severity string) string {
+	switch severity {
+	case "ERROR":
+		return "error"
+	case "WARNING":
+		return "warning"
+	default:
+		return "note"
+	}
+}
@@ -0,0 +1,390 @@
+// Package periodic reviews branches on a schedule: the changes between a
+// pair of branches, or the last commits of one, for repositories that
+// don't review through pull requests
+package periodic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// scheduleName matches schedule names, which name report directories
+var scheduleName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// minInterval is the shortest interval a schedule may run at
+const minInterval = 5 * time.Minute
+
+// Schedule is one periodic review
+type Schedule struct {
+	Name        string `yaml:"name"`
+	Repo        string `yaml:"repo"`         // e.g. github.com/acme/api
+	Base        string `yaml:"base"`         // With Head, review what Head adds to Base, e.g. main
+	Head        string `yaml:"head"`         // e.g. develop
+	Branch      string `yaml:"branch"`       // With LastCommits, review the newest commits of Branch
+	LastCommits int    `yaml:"last_commits"` // e.g. 10
+	Every       string `yaml:"every"`        // Interval, e.g. 6h
+	At          string `yaml:"at"`           // Or daily at HH:MM
+	TokenEnv    string `yaml:"token_env"`    // Variable holding a token that can read Repo; GITHUB_TOKEN for GitHub when unset
+
+	AIProvider string `yaml:"ai_provider"`
+	AIModel    string `yaml:"ai_model"`
+	ReviewMode string `yaml:"review_mode"`
+	Language   string `yaml:"language"`
+
+	interval time.Duration
+	minute   int // Minute of the day of At; -1 when Every is used
+}
+
+// File is a schedules file
+type File struct {
+	Timezone   string     `yaml:"timezone"`    // IANA name for At; the server's when unset
+	ReportsDir string     `yaml:"reports_dir"` // Where each run's report is written; empty keeps none
+	Reviews    []Schedule `yaml:"reviews"`
+
+	location *time.Location
+}
+
+// Run is the outcome of one run of a schedule
+type Run struct {
+	Started     time.Time `json:"started"`
+	BaseSHA     string    `json:"base_sha,omitempty"`
+	HeadSHA     string    `json:"head_sha,omitempty"`
+	ReviewID    string    `json:"review_id,omitempty"`
+	Diagnostics int       `json:"diagnostics"`
+	Skipped     string    `json:"skipped,omitempty"` // Why nothing was reviewed, e.g. no new commits
+	Error       string    `json:"error,omitempty"`
+}
+
+// Status describes a schedule and its latest run
+type Status struct {
+	Name    string    `json:"name"`
+	Repo    string    `json:"repo"`
+	NextRun time.Time `json:"next_run"`
+	LastRun *Run      `json:"last_run,omitempty"`
+}
+
+// Report is the file written for each reviewed run
+type Report struct {
+	Schedule string                `json:"schedule"`
+	Repo     string                `json:"repo"`
+	Run      Run                   `json:"run"`
+	Review   models.ReviewResponse `json:"review"`
+}
+
+// Resolver returns the base and head commits a schedule reviews now
+type Resolver func(ctx context.Context, s Schedule) (base, head string, err error)
+
+// Submitter hands the review of a schedule to the batch workers, calling
+// done with its outcome; it fails when the review can't be queued
+type Submitter func(s Schedule, request models.ReviewRequest, done func(models.ReviewResponse, error)) error
+
+// Load reads and checks a schedules file
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schedules file: %w", err)
+	}
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse schedules file: %w", err)
+	}
+
+	file.location = time.Local
+	if file.Timezone != "" {
+		if file.location, err = time.LoadLocation(file.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid schedules timezone: %w", err)
+		}
+	}
+	seen := make(map[string]bool)
+	for i := range file.Reviews {
+		s := &file.Reviews[i]
+		if err := s.check(); err != nil {
+			return nil, fmt.Errorf("scheduled review %d (%s): %w", i+1, s.Name, err)
+		}
+		if seen[s.Name] {
+			return nil, fmt.Errorf("scheduled review %s is defined twice", s.Name)
+		}
+		seen[s.Name] = true
+	}
+	return &file, nil
+}
+
+// check validates a schedule and parses its timing
+func (s *Schedule) check() error {
+	if !scheduleName.MatchString(s.Name) {
+		return fmt.Errorf("name must be lowercase letters, digits, dots, dashes or underscores")
+	}
+	if s.Repo == "" {
+		return fmt.Errorf("repo is required")
+	}
+	pair := s.Base != "" || s.Head != ""
+	recent := s.Branch != "" || s.LastCommits != 0
+	switch {
+	case pair && recent:
+		return fmt.Errorf("set either base and head, or branch and last_commits")
+	case pair && (s.Base == "" || s.Head == ""):
+		return fmt.Errorf("base and head are both required")
+	case recent && (s.Branch == "" || s.LastCommits < 1):
+		return fmt.Errorf("branch and a positive last_commits are both required")
+	case !pair && !recent:
+		return fmt.Errorf("set base and head, or branch and last_commits")
+	}
+
+	s.minute = -1
+	switch {
+	case (s.Every == "") == (s.At == ""):
+		return fmt.Errorf("set exactly one of every and at")
+	case s.Every != "":
+		interval, err := time.ParseDuration(s.Every)
+		if err != nil || interval < minInterval {
+			return fmt.Errorf("invalid every %q: must be a duration of at least %v, e.g. 6h", s.Every, minInterval)
+		}
+		s.interval = interval
+	default:
+		hour, minute, ok := strings.Cut(s.At, ":")
+		h, err1 := strconv.Atoi(hour)
+		m, err2 := strconv.Atoi(minute)
+		if !ok || err1 != nil || err2 != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+			return fmt.Errorf("invalid at %q: expected HH:MM, e.g. 02:30", s.At)
+		}
+		s.minute = h*60 + m
+	}
+	return nil
+}
+
+// next returns when the schedule runs after now
+func (s *Schedule) next(now time.Time, location *time.Location) time.Time {
+	if s.minute < 0 {
+		return now.Add(s.interval)
+	}
+	local := now.In(location)
+	next := time.Date(local.Year(), local.Month(), local.Day(), s.minute/60, s.minute%60, 0, 0, location)
+	if !next.After(local) {
+		next = time.Date(local.Year(), local.Month(), local.Day()+1, s.minute/60, s.minute%60, 0, 0, location)
+	}
+	return next
+}
+
+// Runner runs the schedules of a file
+type Runner struct {
+	file    *File
+	resolve Resolver
+	submit  Submitter
+
+	mu       sync.Mutex
+	nextRuns map[string]time.Time
+	lastRuns map[string]*Run
+	reviewed map[string]string // Base and head last reviewed, per schedule
+	running  map[string]bool
+	triggers map[string]chan struct{}
+}
+
+// NewRunner creates a runner for the schedules of file
+func NewRunner(file *File, resolve Resolver, submit Submitter) *Runner {
+	r := &Runner{
+		file:     file,
+		resolve:  resolve,
+		submit:   submit,
+		nextRuns: make(map[string]time.Time),
+		lastRuns: make(map[string]*Run),
+		reviewed: make(map[string]string),
+		running:  make(map[string]bool),
+		triggers: make(map[string]chan struct{}),
+	}
+	for _, s := range file.Reviews {
+		r.triggers[s.Name] = make(chan struct{}, 1)
+	}
+	return r
+}
+
+// Start runs every schedule in the background until ctx is done
+func (r *Runner) Start(ctx context.Context) {
+	for _, s := range r.file.Reviews {
+		go r.loop(ctx, s)
+	}
+}
+
+// loop runs one schedule at its times and whenever it is triggered
+func (r *Runner) loop(ctx context.Context, s Schedule) {
+	for {
+		next := s.next(time.Now(), r.file.location)
+		r.mu.Lock()
+		r.nextRuns[s.Name] = next
+		r.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-r.triggers[s.Name]:
+			timer.Stop()
+		}
+		r.run(ctx, s)
+	}
+}
+
+// Trigger runs a schedule now, reporting whether it exists
+func (r *Runner) Trigger(name string) bool {
+	trigger, ok := r.triggers[name]
+	if !ok {
+		return false
+	}
+	select {
+	case trigger <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// run resolves the commits of a schedule and submits their review, unless
+// the previous run already reviewed them or is still in progress
+func (r *Runner) run(ctx context.Context, s Schedule) {
+	run := &Run{Started: time.Now().UTC()}
+	r.mu.Lock()
+	busy := r.running[s.Name]
+	r.mu.Unlock()
+	if busy {
+		run.Skipped = "the previous run is still in progress"
+		r.finish(s, run, nil)
+		return
+	}
+
+	base, head, err := r.resolve(ctx, s)
+	if err != nil {
+		run.Error = err.Error()
+		r.finish(s, run, nil)
+		return
+	}
+	run.BaseSHA, run.HeadSHA = base, head
+
+	r.mu.Lock()
+	unchanged := r.reviewed[s.Name] == base+"..."+head
+	r.mu.Unlock()
+	switch {
+	case base == head:
+		run.Skipped = "no changes"
+		r.finish(s, run, nil)
+		return
+	case unchanged:
+		run.Skipped = "no new commits since the last run"
+		r.finish(s, run, nil)
+		return
+	}
+
+	request := models.ReviewRequest{
+		AIProvider: s.AIProvider,
+		AIModel:    s.AIModel,
+		ReviewMode: s.ReviewMode,
+		Language:   s.Language,
+		GitInfo:    &models.GitInfo{RepoURL: s.Repo, BranchName: s.Head, CommitHash: head},
+		BaseSHA:    base,
+		HeadSHA:    head,
+	}
+	if s.Branch != "" {
+		request.GitInfo.BranchName = s.Branch
+	}
+
+	r.setRunning(s.Name, true)
+	err = r.submit(s, request, func(response models.ReviewResponse, err error) {
+		defer r.setRunning(s.Name, false)
+		if err != nil {
+			run.Error = err.Error()
+			r.finish(s, run, nil)
+			return
+		}
+		run.ReviewID = response.ID
+		run.Diagnostics = len(response.Diagnostics)
+		r.finish(s, run, &response)
+	})
+	if err != nil {
+		r.setRunning(s.Name, false)
+		run.Error = err.Error()
+		r.finish(s, run, nil)
+	}
+}
+
+func (r *Runner) setRunning(name string, running bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running[name] = running
+}
+
+// finish records the outcome of a run and writes its report
+func (r *Runner) finish(s Schedule, run *Run, response *models.ReviewResponse) {
+	r.mu.Lock()
+	r.lastRuns[s.Name] = run
+	if response != nil {
+		r.reviewed[s.Name] = run.BaseSHA + "..." + run.HeadSHA
+	}
+	r.mu.Unlock()
+
+	switch {
+	case run.Error != "":
+		log.Printf("Warning: scheduled review %s failed: %s", s.Name, run.Error)
+	case run.Skipped != "":
+		log.Printf("Scheduled review %s skipped: %s", s.Name, run.Skipped)
+	default:
+		log.Printf("✓ Scheduled review %s of %s %s...%s: %d diagnostics", s.Name, s.Repo, shortSHA(run.BaseSHA), shortSHA(run.HeadSHA), run.Diagnostics)
+	}
+
+	if response == nil || r.file.ReportsDir == "" {
+		return
+	}
+	if err := r.writeReport(s, run, *response); err != nil {
+		log.Printf("Warning: failed to write report of scheduled review %s: %v", s.Name, err)
+	}
+}
+
+// writeReport saves a run's review as
+// <reports_dir>/<name>/<time>-<head>.json
+func (r *Runner) writeReport(s Schedule, run *Run, response models.ReviewResponse) error {
+	dir := filepath.Join(r.file.ReportsDir, s.Name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(Report{Schedule: s.Name, Repo: s.Repo, Run: *run, Review: response}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, run.Started.Format("20060102-150405")+"-"+shortSHA(run.HeadSHA)+".json"), data, 0o644)
+}
+
+// Statuses returns every schedule with its next and latest run, by name
+func (r *Runner) Statuses() []Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	statuses := make([]Status, 0, len(r.file.Reviews))
+	for _, s := range r.file.Reviews {
+		status := Status{Name: s.Name, Repo: s.Repo, NextRun: r.nextRuns[s.Name]}
+		if run := r.lastRuns[s.Name]; run != nil {
+			copied := *run
+			status.LastRun = &copied
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
@@ -0,0 +1,174 @@
+// Package policy decides which providers a request may be sent to, so
+// code from sensitive repositories or tenants only reaches approved
+// providers, e.g. one hosted in a required region
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scm"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule restricts the providers of the requests it matches. A rule matches
+// a request when every condition it sets does; a rule without conditions
+// matches every request.
+type Rule struct {
+	Name      string   `yaml:"name"`
+	Repos     []string `yaml:"repos"`     // Globs over host/owner/name or owner/name, e.g. github.com/acme/internal-*
+	Languages []string `yaml:"languages"` // Languages named by the request
+	Tenants   []string `yaml:"tenants"`   // Tenants from TENANTS_FILE
+	Allow     []string `yaml:"allow"`     // provider or provider/model patterns; empty allows any not denied
+	Deny      []string `yaml:"deny"`      // provider or provider/model patterns
+}
+
+// Subject is what rules are matched against
+type Subject struct {
+	Repo     string // Repository URL, in any form
+	Language string
+	Tenant   string
+}
+
+// Violation is returned when a rule forbids a provider
+type Violation struct {
+	Rule     string
+	Provider string
+	Model    string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("policy %s does not allow %s/%s for this request", v.Rule, v.Provider, v.Model)
+}
+
+// Policy is an ordered list of rules. A nil policy allows everything.
+type Policy struct {
+	rules []Rule
+}
+
+// Load reads a YAML or JSON policy file
+func Load(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %w", err)
+	}
+
+	var doc struct {
+		Rules []Rule `yaml:"rules"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse policy file %s: %w", file, err)
+	}
+
+	names := make(map[string]bool)
+	for i, rule := range doc.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("policy file %s: rule %d needs a name", file, i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("policy file %s: rule %s is listed twice", file, rule.Name)
+		}
+		names[rule.Name] = true
+		if len(rule.Allow) == 0 && len(rule.Deny) == 0 {
+			return nil, fmt.Errorf("policy file %s: rule %s needs allow or deny", file, rule.Name)
+		}
+		for _, pattern := range append(append([]string{}, rule.Allow...), rule.Deny...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("policy file %s: rule %s: invalid pattern %q", file, rule.Name, pattern)
+			}
+		}
+	}
+	return &Policy{rules: doc.Rules}, nil
+}
+
+// Len returns the number of rules
+func (p *Policy) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.rules)
+}
+
+// Applies reports whether any rule matches the subject
+func (p *Policy) Applies(subject Subject) bool {
+	if p == nil {
+		return false
+	}
+	for _, rule := range p.rules {
+		if rule.matches(subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns a *Violation if a rule matching the subject forbids
+// sending it to provider and model
+func (p *Policy) Check(subject Subject, provider, model string) error {
+	if p == nil {
+		return nil
+	}
+	for _, rule := range p.rules {
+		if !rule.matches(subject) {
+			continue
+		}
+		denied := matchesProvider(rule.Deny, provider, model)
+		if denied || (len(rule.Allow) > 0 && !matchesProvider(rule.Allow, provider, model)) {
+			return &Violation{Rule: rule.Name, Provider: provider, Model: model}
+		}
+	}
+	return nil
+}
+
+// matches reports whether every condition of the rule holds for subject
+func (r *Rule) matches(subject Subject) bool {
+	if len(r.Repos) > 0 && !scm.MatchRepository(r.Repos, subject.Repo) {
+		return false
+	}
+	if len(r.Languages) > 0 && !containsFold(r.Languages, subject.Language) {
+		return false
+	}
+	if len(r.Tenants) > 0 && !contains(r.Tenants, subject.Tenant) {
+		return false
+	}
+	return true
+}
+
+// matchesProvider reports whether provider or provider/model matches any
+// of the patterns
+func matchesProvider(patterns []string, provider, model string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, provider); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, provider+"/"+model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package postprocess
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// Fingerprint identifies a finding by file, category and message. Line
+// numbers are deliberately excluded so that a finding keeps its
+// fingerprint when unrelated edits shift code up or down.
+func Fingerprint(d models.Diagnostic) string {
+	h := sha256.New()
+	for _, part := range []string{
+		diff.NormalizePath(d.Location.Path),
+		strings.ToLower(strings.TrimSpace(d.Code.Value)),
+		strings.Join(strings.Fields(strings.ToLower(d.Message)), " "),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// AddFingerprints sets the fingerprint of every diagnostic
+func AddFingerprints(diagnostics []models.Diagnostic) {
+	for i := range diagnostics {
+		diagnostics[i].Fingerprint = Fingerprint(diagnostics[i])
+	}
+}
+
+// FilterBaseline drops diagnostics whose fingerprint is in the baseline of
+// previously acknowledged findings
+func FilterBaseline(diagnostics []models.Diagnostic, baseline []string) ([]models.Diagnostic, int) {
+	if len(baseline) == 0 {
+		return diagnostics, 0
+	}
+	known := make(map[string]bool, len(baseline))
+	for _, fp := range baseline {
+		known[strings.ToLower(strings.TrimSpace(fp))] = true
+	}
+	return filter(diagnostics, func(d models.Diagnostic) bool {
+		return !known[Fingerprint(d)]
+	})
+}
@@ -0,0 +1,104 @@
+package postprocess
+
+import (
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// duplicateSimilarity is the minimum word overlap for two findings on the
+// same line to count as duplicates
+const duplicateSimilarity = 0.5
+
+// nearbyDuplicateSimilarity is the overlap needed for findings a line or two
+// apart, as when overlapping chunks of a large diff report the same issue
+const nearbyDuplicateSimilarity = 0.8
+
+// Deduplicate merges near-duplicate findings: those in the same file on the
+// same line with similar messages, or on nearby lines with nearly identical
+// ones. Each cluster keeps the message, location and rule of its most severe
+// finding, every distinct suggestion, and all providers and sources. It
+// returns the remaining diagnostics and the number merged away.
+func Deduplicate(diagnostics []models.Diagnostic) ([]models.Diagnostic, int) {
+	result := make([]models.Diagnostic, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		match := -1
+		for i := range result {
+			if duplicates(result[i], d) {
+				match = i
+				break
+			}
+		}
+		if match < 0 {
+			result = append(result, d)
+			continue
+		}
+		result[match] = mergeDuplicate(result[match], d)
+	}
+	return result, len(diagnostics) - len(result)
+}
+
+// duplicates reports whether two diagnostics describe the same finding
+func duplicates(a, b models.Diagnostic) bool {
+	if diff.NormalizePath(a.Location.Path) != diff.NormalizePath(b.Location.Path) {
+		return false
+	}
+	distance := a.Location.Range.Start.Line - b.Location.Range.Start.Line
+	switch {
+	case distance == 0:
+		return wordSimilarity(a.Message, b.Message) >= duplicateSimilarity
+	case distance >= -mergeLineWindow && distance <= mergeLineWindow:
+		return wordSimilarity(a.Message, b.Message) >= nearbyDuplicateSimilarity
+	default:
+		return false
+	}
+}
+
+// mergeDuplicate folds one finding of a cluster into another
+func mergeDuplicate(kept, dropped models.Diagnostic) models.Diagnostic {
+	if SeverityRank(dropped.Severity) > SeverityRank(kept.Severity) {
+		kept, dropped = dropped, kept
+	}
+	kept.Suggestion = joinSuggestions(kept.Suggestion, dropped.Suggestion)
+	if kept.Fix == nil {
+		kept.Fix = dropped.Fix
+	}
+	if kept.CWE == "" {
+		kept.CWE, kept.OWASP = dropped.CWE, dropped.OWASP
+		if kept.Code.URL == "" {
+			kept.Code.URL = dropped.Code.URL
+		}
+	}
+	kept.Providers = union(kept.Providers, dropped.Providers)
+	kept.Sources = union(kept.Sources, dropped.Sources)
+	return kept
+}
+
+// joinSuggestions combines two suggestions, leaving out one the other
+// already contains
+func joinSuggestions(a, b string) string {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	switch {
+	case b == "" || strings.Contains(a, b):
+		return a
+	case a == "" || strings.Contains(b, a):
+		return b
+	default:
+		return a + "\n\n" + b
+	}
+}
+
+// union appends the values of b missing from a
+func union(a, b []string) []string {
+	result := append([]string{}, a...)
+	for _, v := range b {
+		if !contains(result, v) {
+			result = append(result, v)
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package postprocess
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/glob"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// SeverityRank orders severities from least (INFO) to most (ERROR) severe
+func SeverityRank(severity string) int {
+	switch strings.ToUpper(severity) {
+	case "ERROR":
+		return 3
+	case "WARNING":
+		return 2
+	case "INFO":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// FilterPaths drops diagnostics on files matching any of the patterns
+func FilterPaths(diagnostics []models.Diagnostic, patterns []string) ([]models.Diagnostic, int) {
+	return filter(diagnostics, func(d models.Diagnostic) bool {
+		return !glob.MatchAny(patterns, diff.NormalizePath(d.Location.Path))
+	})
+}
+
+// FilterSeverity drops diagnostics below the minimum severity
+func FilterSeverity(diagnostics []models.Diagnostic, minSeverity string) ([]models.Diagnostic, int) {
+	min := SeverityRank(minSeverity)
+	return filter(diagnostics, func(d models.Diagnostic) bool {
+		return SeverityRank(d.Severity) >= min
+	})
+}
+
+// FilterCategories keeps only diagnostics in the given categories
+func FilterCategories(diagnostics []models.Diagnostic, categories []string) ([]models.Diagnostic, int) {
+	allowed := make(map[string]bool, len(categories))
+	for _, c := range categories {
+		allowed[strings.ToLower(strings.TrimSpace(c))] = true
+	}
+	return filter(diagnostics, func(d models.Diagnostic) bool {
+		return allowed[strings.ToLower(d.Code.Value)]
+	})
+}
+
+// FilterRequestedCategories keeps diagnostics in the requested categories.
+// Only categories of the taxonomy are filtered: findings filed elsewhere,
+// such as linter rules, license headers and security findings, are kept.
+func FilterRequestedCategories(diagnostics []models.Diagnostic, requested, taxonomy []string) ([]models.Diagnostic, int) {
+	if len(requested) == 0 {
+		return diagnostics, 0
+	}
+	allowed := make(map[string]bool, len(requested))
+	for _, c := range requested {
+		allowed[strings.ToLower(c)] = true
+	}
+	filtered := make(map[string]bool, len(taxonomy))
+	for _, c := range taxonomy {
+		filtered[strings.ToLower(c)] = true
+	}
+	return filter(diagnostics, func(d models.Diagnostic) bool {
+		category := strings.ToLower(d.Code.Value)
+		return allowed[category] || !filtered[category]
+	})
+}
+
+// Limit keeps the most severe maxIssues diagnostics, preserving the model's
+// ordering within a severity
+func Limit(diagnostics []models.Diagnostic, maxIssues int) ([]models.Diagnostic, int) {
+	if maxIssues <= 0 || len(diagnostics) <= maxIssues {
+		return diagnostics, 0
+	}
+
+	sorted := make([]models.Diagnostic, len(diagnostics))
+	copy(sorted, diagnostics)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return SeverityRank(sorted[i].Severity) > SeverityRank(sorted[j].Severity)
+	})
+
+	return sorted[:maxIssues], len(sorted) - maxIssues
+}
+
+// ApplyRepoConfig applies the filters of a repository configuration and
+// returns the remaining diagnostics and the number suppressed
+func ApplyRepoConfig(diagnostics []models.Diagnostic, cfg *models.RepoConfig) ([]models.Diagnostic, int) {
+	total := 0
+	var n int
+
+	if len(cfg.IgnorePaths) > 0 {
+		diagnostics, n = FilterPaths(diagnostics, cfg.IgnorePaths)
+		total += n
+	}
+	if len(cfg.Categories) > 0 {
+		diagnostics, n = FilterCategories(diagnostics, cfg.Categories)
+		total += n
+	}
+	if cfg.MinSeverity != "" {
+		diagnostics, n = FilterSeverity(diagnostics, cfg.MinSeverity)
+		total += n
+	}
+	diagnostics, n = Limit(diagnostics, cfg.MaxIssues)
+	total += n
+
+	return diagnostics, total
+}
+
+// filter keeps diagnostics for which keep returns true
+func filter(diagnostics []models.Diagnostic, keep func(models.Diagnostic) bool) ([]models.Diagnostic, int) {
+	kept := make([]models.Diagnostic, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		if keep(d) {
+			kept = append(kept, d)
+		}
+	}
+	return kept, len(diagnostics) - len(kept)
+}
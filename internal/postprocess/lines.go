@@ -0,0 +1,119 @@
+package postprocess
+
+import (
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// Line validation policies
+const (
+	LinePolicyOff    = "off"    // Keep diagnostics as reported by the model
+	LinePolicyClamp  = "clamp"  // Snap lines inside a hunk to the nearest added line
+	LinePolicyFilter = "filter" // Drop diagnostics that aren't on an added line
+)
+
+// LineStats reports what line validation did to a set of diagnostics
+type LineStats struct {
+	Adjusted int
+	Dropped  int
+}
+
+// ValidateLines checks diagnostic locations against the changed lines of the
+// diff. Models frequently hallucinate line numbers, and reviewdog silently
+// discards comments outside the diff, so diagnostics are clamped or dropped
+// according to the policy. A diagnostic spanning several lines is kept when
+// any of them was added.
+//
+// Files without hunk information (e.g. binary files or diffs without @@
+// headers) are left untouched because there is nothing to validate against.
+func ValidateLines(diagnostics []models.Diagnostic, files []*diff.File, policy string) ([]models.Diagnostic, LineStats) {
+	var stats LineStats
+	if policy == LinePolicyOff || len(files) == 0 {
+		return diagnostics, stats
+	}
+
+	byPath := make(map[string]*diff.File, len(files))
+	for _, f := range files {
+		byPath[f.Path()] = f
+	}
+
+	kept := make([]models.Diagnostic, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		f, ok := byPath[diff.NormalizePath(d.Location.Path)]
+		if !ok {
+			stats.Dropped++
+			continue
+		}
+		if len(f.Hunks) == 0 {
+			kept = append(kept, d)
+			continue
+		}
+
+		line := d.Location.Range.Start.Line
+		if coversAddedLine(f.AddedLines(), d.Location.Range) {
+			kept = append(kept, d)
+			continue
+		}
+
+		if policy != LinePolicyClamp {
+			stats.Dropped++
+			continue
+		}
+
+		target, ok := nearestAddedLine(f, line)
+		if !ok {
+			stats.Dropped++
+			continue
+		}
+
+		shift := target - line
+		d.Location.Range.Start.Line = target
+		d.Location.Range.End.Line += shift
+		if d.Location.Range.End.Line < target {
+			d.Location.Range.End.Line = target
+		}
+		stats.Adjusted++
+		kept = append(kept, d)
+	}
+
+	return kept, stats
+}
+
+// coversAddedLine reports whether any line of a range was added
+func coversAddedLine(added map[int]bool, r models.Range) bool {
+	if added[r.Start.Line] {
+		return true
+	}
+	for line := range added {
+		if line > r.Start.Line && line <= r.End.Line {
+			return true
+		}
+	}
+	return false
+}
+
+// nearestAddedLine finds the added line closest to the given line within the
+// hunk that contains it
+func nearestAddedLine(f *diff.File, line int) (int, bool) {
+	for _, h := range f.Hunks {
+		if !h.Contains(line) {
+			continue
+		}
+
+		best, bestDistance := 0, -1
+		for _, l := range h.Lines {
+			if l.Kind != diff.LineAdded {
+				continue
+			}
+			distance := l.NewLine - line
+			if distance < 0 {
+				distance = -distance
+			}
+			if bestDistance < 0 || distance < bestDistance {
+				best, bestDistance = l.NewLine, distance
+			}
+		}
+		return best, bestDistance >= 0
+	}
+	return 0, false
+}
@@ -0,0 +1,177 @@
+package postprocess
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// mergeLineWindow is how far apart two findings may be and still be treated
+// as the same issue; models often disagree by a line or two
+const mergeLineWindow = 2
+
+// mergeSimilarity is the minimum word overlap between two messages in
+// different categories for them to count as the same issue
+const mergeSimilarity = 0.5
+
+// SourcedDiagnostics are the diagnostics returned by one provider
+type SourcedDiagnostics struct {
+	Source      string
+	Diagnostics []models.Diagnostic
+}
+
+// Merge combines the findings of several providers. Near-identical
+// diagnostics (same file, nearby lines, same category or similar wording)
+// are collapsed into one, keeping the most severe, and annotated with every
+// provider that reported them. Findings with more agreement come first.
+func Merge(results []SourcedDiagnostics) []models.Diagnostic {
+	var merged []models.Diagnostic
+
+	for _, result := range results {
+		for _, d := range result.Diagnostics {
+			match := -1
+			for i := range merged {
+				if sameIssue(merged[i], d) && !contains(merged[i].Providers, result.Source) {
+					match = i
+					break
+				}
+			}
+
+			if match < 0 {
+				d.Providers = []string{result.Source}
+				merged = append(merged, d)
+				continue
+			}
+
+			existing := &merged[match]
+			existing.Providers = append(existing.Providers, result.Source)
+			if SeverityRank(d.Severity) > SeverityRank(existing.Severity) {
+				existing.Severity = d.Severity
+			}
+			if existing.Suggestion == "" {
+				existing.Suggestion = d.Suggestion
+			}
+			if existing.Fix == nil {
+				existing.Fix = d.Fix
+			}
+			if existing.CWE == "" {
+				existing.CWE, existing.OWASP, existing.Code.URL = d.CWE, d.OWASP, d.Code.URL
+			}
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return len(merged[i].Providers) > len(merged[j].Providers)
+	})
+	return merged
+}
+
+// AISource names the model in Diagnostic.Sources
+const AISource = "ai-review"
+
+// lineSimilarity is the minimum word overlap for a model finding and a
+// linter finding on the same line to count as the same issue; linters word
+// their messages tersely, so less is needed than between models
+const lineSimilarity = 0.25
+
+// MergeLinters combines the findings of linters with the model's. A model
+// finding that repeats a linter finding is folded into it, keeping the
+// linter's location and rule, the higher severity and the model's
+// suggestion when the linter has none. Linter findings come first, and
+// every diagnostic lists the tools that reported it in Sources.
+func MergeLinters(ai, linters []models.Diagnostic) []models.Diagnostic {
+	merged := make([]models.Diagnostic, 0, len(linters)+len(ai))
+	for _, d := range linters {
+		d.Sources = append([]string{}, d.Sources...)
+		merged = append(merged, d)
+	}
+	reported := len(merged)
+
+	for _, d := range ai {
+		match := -1
+		for i := 0; i < reported; i++ {
+			if (sameIssue(merged[i], d) || sameLineIssue(merged[i], d)) && !contains(merged[i].Sources, AISource) {
+				match = i
+				break
+			}
+		}
+		if match < 0 {
+			d.Sources = []string{AISource}
+			merged = append(merged, d)
+			continue
+		}
+
+		existing := &merged[match]
+		existing.Sources = append(existing.Sources, AISource)
+		if SeverityRank(d.Severity) > SeverityRank(existing.Severity) {
+			existing.Severity = d.Severity
+		}
+		if existing.Suggestion == "" {
+			existing.Suggestion, existing.Fix = d.Suggestion, d.Fix
+		}
+	}
+	return merged
+}
+
+// sameLineIssue reports whether two diagnostics on the same line describe
+// related problems
+func sameLineIssue(a, b models.Diagnostic) bool {
+	return diff.NormalizePath(a.Location.Path) == diff.NormalizePath(b.Location.Path) &&
+		a.Location.Range.Start.Line == b.Location.Range.Start.Line &&
+		wordSimilarity(a.Message, b.Message) >= lineSimilarity
+}
+
+// sameIssue reports whether two diagnostics describe the same finding
+func sameIssue(a, b models.Diagnostic) bool {
+	if diff.NormalizePath(a.Location.Path) != diff.NormalizePath(b.Location.Path) {
+		return false
+	}
+	distance := a.Location.Range.Start.Line - b.Location.Range.Start.Line
+	if distance < -mergeLineWindow || distance > mergeLineWindow {
+		return false
+	}
+	if a.Code.Value != "" && strings.EqualFold(a.Code.Value, b.Code.Value) {
+		return true
+	}
+	return wordSimilarity(a.Message, b.Message) >= mergeSimilarity
+}
+
+// wordSimilarity is the Jaccard index of the words in two messages
+func wordSimilarity(a, b string) float64 {
+	wordsA, wordsB := words(a), words(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range wordsA {
+		if wordsB[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wordsA)+len(wordsB)-shared)
+}
+
+// words returns the set of lower-cased words of three or more letters
+func words(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(w) >= 3 {
+			set[w] = true
+		}
+	}
+	return set
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
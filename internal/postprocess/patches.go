@@ -0,0 +1,200 @@
+package postprocess
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// patchContext is the number of unchanged lines kept around each patch,
+// as in git diff
+const patchContext = 3
+
+// BuildPatches turns the fixes the model proposed into unified diffs against
+// the new version of each file. Diagnostics must already be fingerprinted.
+func BuildPatches(diagnostics []models.Diagnostic, files []*diff.File, contents []models.FileContent, suggestionBlocks bool) []models.Patch {
+	known := KnownLines(files, contents)
+
+	var patches []models.Patch
+	for i, d := range diagnostics {
+		if d.Fix == nil {
+			continue
+		}
+		patch, ok := known.Patch(d.Location.Path, *d.Fix, suggestionBlocks)
+		if !ok {
+			continue
+		}
+		patch.Diagnostic = i
+		patch.Fingerprint = d.Fingerprint
+		patches = append(patches, patch)
+	}
+	return patches
+}
+
+// FileLines holds the lines of the new version of files that the model was
+// shown, keyed by file path and line number
+type FileLines map[string]map[int]string
+
+// KnownLines collects the added and context lines of a diff, and every line
+// of the full file contents
+func KnownLines(files []*diff.File, contents []models.FileContent) FileLines {
+	known := make(FileLines)
+	for _, f := range files {
+		if f.IsDelete || f.IsBinary {
+			continue
+		}
+		lines := make(map[int]string)
+		for _, h := range f.Hunks {
+			for _, l := range h.Lines {
+				if l.Kind != diff.LineRemoved {
+					lines[l.NewLine] = l.Content
+				}
+			}
+		}
+		known[f.Path()] = lines
+	}
+	for _, c := range contents {
+		path := diff.NormalizePath(c.Path)
+		lines := known[path]
+		if lines == nil {
+			lines = make(map[int]string)
+			known[path] = lines
+		}
+		for i, line := range strings.Split(strings.TrimSuffix(c.Content, "\n"), "\n") {
+			lines[i+1] = strings.TrimSuffix(line, "\r")
+		}
+	}
+	return known
+}
+
+// Line returns a known line of a file
+func (f FileLines) Line(path string, line int) (string, bool) {
+	content, ok := f[diff.NormalizePath(path)][line]
+	return content, ok
+}
+
+// Patch renders a fix as a unified diff, and optionally as a GitHub
+// suggestion block. It fails when any replaced line is unknown, so fixes
+// for lines the model never saw are dropped, and when the fix changes
+// nothing.
+func (f FileLines) Patch(path string, fix models.Fix, suggestionBlock bool) (models.Patch, bool) {
+	path = diff.NormalizePath(path)
+	lines := f[path]
+	if lines == nil || fix.StartLine < 1 || fix.EndLine < fix.StartLine {
+		return models.Patch{}, false
+	}
+	original, ok := lineRange(lines, fix.StartLine, fix.EndLine)
+	if !ok {
+		return models.Patch{}, false
+	}
+	replacement := splitReplacement(fix.Replacement)
+	if equalLines(original, replacement) {
+		return models.Patch{}, false
+	}
+
+	patch := models.Patch{
+		Path:      path,
+		StartLine: fix.StartLine,
+		EndLine:   fix.EndLine,
+		Diff:      unifiedDiff(path, lines, fix.StartLine, fix.EndLine, original, replacement),
+	}
+	if suggestionBlock {
+		patch.Suggestion = suggestionBlockFor(replacement)
+	}
+	return patch, true
+}
+
+// lineRange returns lines start to end, or false when any is unknown
+func lineRange(lines map[int]string, start, end int) ([]string, bool) {
+	result := make([]string, 0, end-start+1)
+	for n := start; n <= end; n++ {
+		line, ok := lines[n]
+		if !ok {
+			return nil, false
+		}
+		result = append(result, line)
+	}
+	return result, true
+}
+
+// splitReplacement splits replacement code into lines; an empty
+// replacement deletes the lines
+func splitReplacement(replacement string) []string {
+	replacement = strings.TrimSuffix(strings.ReplaceAll(replacement, "\r\n", "\n"), "\n")
+	if replacement == "" {
+		return nil
+	}
+	return strings.Split(replacement, "\n")
+}
+
+// unifiedDiff renders a single-hunk diff replacing lines start to end
+func unifiedDiff(path string, lines map[int]string, start, end int, original, replacement []string) string {
+	var before, after []string
+	for n := start - 1; n >= 1 && n >= start-patchContext; n-- {
+		line, ok := lines[n]
+		if !ok {
+			break
+		}
+		before = append([]string{line}, before...)
+	}
+	for n := end + 1; n <= end+patchContext; n++ {
+		line, ok := lines[n]
+		if !ok {
+			break
+		}
+		after = append(after, line)
+	}
+
+	oldStart := start - len(before)
+	oldCount := len(before) + len(original) + len(after)
+	newCount := len(before) + len(replacement) + len(after)
+	newStart := oldStart
+	if newCount == 0 {
+		newStart--
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n@@ -%d,%d +%d,%d @@\n", path, path, oldStart, oldCount, newStart, newCount)
+	for _, line := range before {
+		b.WriteString(" " + line + "\n")
+	}
+	for _, line := range original {
+		b.WriteString("-" + line + "\n")
+	}
+	for _, line := range replacement {
+		b.WriteString("+" + line + "\n")
+	}
+	for _, line := range after {
+		b.WriteString(" " + line + "\n")
+	}
+	return b.String()
+}
+
+// suggestionBlockFor renders a GitHub suggestion, which replaces the lines a
+// review comment spans. The fence is made longer than any backtick run in
+// the code.
+func suggestionBlockFor(replacement []string) string {
+	code := strings.Join(replacement, "\n")
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	if code == "" {
+		return fence + "suggestion\n" + fence
+	}
+	return fence + "suggestion\n" + code + "\n" + fence
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package preprocess
+
+import (
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/glob"
+)
+
+// generatedPatterns match vendored dependencies, lockfiles and common
+// generated sources that aren't worth reviewing
+var generatedPatterns = []string{
+	"vendor/**",
+	"**/vendor/**",
+	"node_modules/**",
+	"**/node_modules/**",
+	"third_party/**",
+	"*_pb.go",
+	"*.pb.go",
+	"*.pb.gw.go",
+	"*_pb2.py",
+	"*_pb2_grpc.py",
+	"*_generated.go",
+	"*.generated.*",
+	"*.gen.go",
+	"zz_generated*.go",
+	"*.min.js",
+	"*.min.css",
+	"*.js.map",
+	"*.css.map",
+	"*.snap",
+	"package-lock.json",
+	"npm-shrinkwrap.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+	"go.sum",
+	"Cargo.lock",
+	"Gemfile.lock",
+	"composer.lock",
+	"poetry.lock",
+	"Pipfile.lock",
+	"mix.lock",
+	"pubspec.lock",
+	"packages.lock.json",
+}
+
+// generatedMarkers are header comments emitted by code generators
+var generatedMarkers = []string{
+	"Code generated",
+	"DO NOT EDIT",
+	"@generated",
+	"<auto-generated",
+	"autogenerated file",
+}
+
+// markerScanLines is how many lines from the top of a new file are checked
+// for generator markers
+const markerScanLines = 10
+
+// FileFilter selects which files of a diff are sent to the model
+type FileFilter struct {
+	IgnorePatterns []string // Glob patterns of files to drop
+	SkipGenerated  bool     // Drop vendored, lockfile and generated files
+}
+
+// Apply returns the files to review and the paths of skipped files. Binary
+// files are always skipped.
+func (f FileFilter) Apply(files []*diff.File) ([]*diff.File, []string) {
+	kept := make([]*diff.File, 0, len(files))
+	var skipped []string
+
+	for _, file := range files {
+		path := file.Path()
+		if file.IsBinary || glob.MatchAny(f.IgnorePatterns, path) || (f.SkipGenerated && IsGenerated(file)) {
+			skipped = append(skipped, path)
+			continue
+		}
+		kept = append(kept, file)
+	}
+
+	return kept, skipped
+}
+
+// IsGenerated reports whether a file looks vendored or machine generated,
+// based on its path or a generator marker near the top of the file
+func IsGenerated(file *diff.File) bool {
+	if glob.MatchAny(generatedPatterns, file.Path()) {
+		return true
+	}
+
+	for _, h := range file.Hunks {
+		if h.NewStart > markerScanLines {
+			continue
+		}
+		for _, l := range h.Lines {
+			if l.NewLine == 0 || l.NewLine > markerScanLines {
+				continue
+			}
+			for _, marker := range generatedMarkers {
+				if strings.Contains(l.Content, marker) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
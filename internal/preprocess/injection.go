@@ -0,0 +1,106 @@
+package preprocess
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// Injection finding kinds
+const (
+	InjectionOverride   = "instruction-override" // Tries to replace the reviewer's instructions
+	InjectionRoleMarker = "role-marker"          // Chat template or role delimiters
+	InjectionOutput     = "output-manipulation"  // Tries to dictate the review result
+)
+
+type injectionPattern struct {
+	kind  string
+	regex *regexp.Regexp
+}
+
+// injectionPatterns are heuristics for text aimed at the reviewing model
+// rather than at human readers of the code
+var injectionPatterns = []injectionPattern{
+	{InjectionOverride, regexp.MustCompile(`(?i)\b(?:ignore|disregard|forget|override)\b.{0,30}\b(?:previous|prior|above|earlier|all|any|your|system)\b.{0,20}\b(?:instructions?|prompts?|rules|guidelines|directions)\b`)},
+	{InjectionOverride, regexp.MustCompile(`(?i)\b(?:you are now|from now on,? you|new instructions\s*:|act as (?:an?|the) )`)},
+	{InjectionOverride, regexp.MustCompile(`(?i)\b(?:ai|llm|language model|assistant|code reviewer|reviewer bot)\b.{0,40}\b(?:must|should|shall|are instructed to)\b`)},
+	{InjectionOutput, regexp.MustCompile(`(?i)\b(?:do not|don't|never)\s+(?:report|flag|mention|comment on)\b.{0,40}\b(?:issues?|bugs?|problems?|vulnerabilit(?:y|ies)|this)\b`)},
+	{InjectionOutput, regexp.MustCompile(`(?i)\b(?:return|respond with|output)\b.{0,30}\b(?:empty|no)\s+(?:issues|diagnostics|findings)\b`)},
+	{InjectionOutput, regexp.MustCompile(`(?i)\b(?:approve|lgtm)\b.{0,30}\b(?:this (?:pr|pull request|change|diff)|without review)\b`)},
+	{InjectionRoleMarker, roleMarkerRegex},
+}
+
+// roleMarkerRegex matches chat template delimiters that could make the
+// diff look like a new conversation turn
+var roleMarkerRegex = regexp.MustCompile(`(?i)<\|(?:im_start|im_end|system|user|assistant|endoftext)\|>|\[/?INST\]|<</?SYS>>|</?(?:system|assistant)>|^\W{0,3}#{2,}\s*(?:system|assistant)\s*:?\s*$`)
+
+// NeutralizeInjection scans the added lines of a diff for prompt-injection
+// attempts. Chat role markers are defanged in place; other findings are
+// left intact so the code can still be reviewed, and are reported so the
+// prompt can warn the model about them. Text that isn't a unified diff is
+// scanned line by line without locations.
+func NeutralizeInjection(text string) (string, []models.InjectionFinding) {
+	var findings []models.InjectionFinding
+
+	scan := func(path string, lineNumber int, content string) {
+		for _, p := range injectionPatterns {
+			if match := p.regex.FindString(content); match != "" {
+				findings = append(findings, models.InjectionFinding{
+					Kind:    p.kind,
+					Path:    path,
+					Line:    lineNumber,
+					Excerpt: excerpt(match),
+				})
+				return
+			}
+		}
+	}
+
+	if files := diff.Parse(text); len(files) > 0 {
+		for _, f := range files {
+			for _, h := range f.Hunks {
+				for _, l := range h.Lines {
+					if l.Kind == diff.LineAdded {
+						scan(f.Path(), l.NewLine, l.Content)
+					}
+				}
+			}
+		}
+	} else {
+		for _, line := range strings.Split(text, "\n") {
+			scan("", 0, line)
+		}
+	}
+
+	if len(findings) == 0 {
+		return text, nil
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = defangRoleMarkers(line)
+	}
+	return strings.Join(lines, "\n"), findings
+}
+
+// defangRoleMarkers breaks up chat role markers so they read as plain text
+func defangRoleMarkers(line string) string {
+	prefix := ""
+	if line != "" && (line[0] == '+' || line[0] == '-' || line[0] == ' ') {
+		prefix, line = line[:1], line[1:]
+	}
+	return prefix + roleMarkerRegex.ReplaceAllStringFunc(line, func(match string) string {
+		return "[" + strings.Trim(strings.TrimSpace(match), "<>[]|#:/ ") + "]"
+	})
+}
+
+// excerpt shortens matched text for the response
+func excerpt(text string) string {
+	text = strings.TrimSpace(text)
+	if len(text) > 80 {
+		return text[:77] + "..."
+	}
+	return text
+}
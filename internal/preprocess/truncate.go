@@ -0,0 +1,175 @@
+package preprocess
+
+import (
+	"sort"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// Strategies for files whose changes exceed the size limit
+const (
+	StrategySkip     = "skip"      // Leave the file out
+	StrategyHeadTail = "head-tail" // Keep the first and last changes
+	StrategySample   = "sample"    // Keep the start of every hunk
+)
+
+// SizeLimit cuts down files whose diff is too large to review in full
+type SizeLimit struct {
+	MaxBytes int    // Largest diff of a single file sent in full; zero disables the limit
+	Strategy string // skip, head-tail or sample
+}
+
+// Apply returns the files to review, the paths of files skipped for their
+// size, and the files that were truncated
+func (l SizeLimit) Apply(files []*diff.File) ([]*diff.File, []string, []models.TruncatedFile) {
+	if l.MaxBytes <= 0 {
+		return files, nil, nil
+	}
+
+	kept := make([]*diff.File, 0, len(files))
+	var skipped []string
+	var truncated []models.TruncatedFile
+	for _, file := range files {
+		if len(file.Raw) <= l.MaxBytes {
+			kept = append(kept, file)
+			continue
+		}
+
+		var keep [][]bool
+		switch l.Strategy {
+		case StrategyHeadTail:
+			keep = headTail(file, l.MaxBytes)
+		case StrategySample:
+			keep = sample(file, l.MaxBytes)
+		}
+		cut, omitted := rebuild(file, keep)
+		if cut == nil {
+			skipped = append(skipped, file.Path())
+			continue
+		}
+		kept = append(kept, cut)
+		truncated = append(truncated, models.TruncatedFile{
+			Path:          file.Path(),
+			Strategy:      l.Strategy,
+			OriginalBytes: len(file.Raw),
+			Bytes:         len(cut.Raw),
+			OmittedLines:  omitted,
+		})
+	}
+	return kept, skipped, truncated
+}
+
+// lineSize is the size of a line in the diff text
+func lineSize(l diff.Line) int {
+	return len(l.Content) + 2
+}
+
+// headTail keeps the changes from the start of the file until half the
+// budget is spent, and from the end for the other half
+func headTail(file *diff.File, budget int) [][]bool {
+	keep := make([][]bool, len(file.Hunks))
+	for i, h := range file.Hunks {
+		keep[i] = make([]bool, len(h.Lines))
+	}
+
+	remaining := budget / 2
+	for i := 0; i < len(file.Hunks) && remaining > 0; i++ {
+		for j, l := range file.Hunks[i].Lines {
+			if remaining -= lineSize(l); remaining < 0 {
+				break
+			}
+			keep[i][j] = true
+		}
+	}
+	remaining = budget / 2
+	for i := len(file.Hunks) - 1; i >= 0 && remaining > 0; i-- {
+		lines := file.Hunks[i].Lines
+		for j := len(lines) - 1; j >= 0; j-- {
+			if remaining -= lineSize(lines[j]); remaining < 0 {
+				break
+			}
+			keep[i][j] = true
+		}
+	}
+	return keep
+}
+
+// sample shares the budget between the hunks, so every part of the file is
+// represented. Hunks smaller than their share are kept whole and the rest
+// is divided among the larger ones, each keeping its first lines.
+func sample(file *diff.File, budget int) [][]bool {
+	sizes := make([]int, len(file.Hunks))
+	order := make([]int, len(file.Hunks))
+	for i, h := range file.Hunks {
+		for _, l := range h.Lines {
+			sizes[i] += lineSize(l)
+		}
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return sizes[order[a]] < sizes[order[b]] })
+
+	allowance := make([]int, len(file.Hunks))
+	remaining := budget
+	for n, i := range order {
+		allowance[i] = min(sizes[i], remaining/(len(order)-n))
+		remaining -= allowance[i]
+	}
+
+	keep := make([][]bool, len(file.Hunks))
+	for i, h := range file.Hunks {
+		keep[i] = make([]bool, len(h.Lines))
+		left := allowance[i]
+		for j, l := range h.Lines {
+			if left -= lineSize(l); left < 0 {
+				break
+			}
+			keep[i][j] = true
+		}
+	}
+	return keep
+}
+
+// rebuild returns the file with only the kept lines, each run of them as a
+// hunk, and the number of changed lines left out. It returns nil when no
+// changed line is kept.
+func rebuild(file *diff.File, keep [][]bool) (*diff.File, int) {
+	var hunks []*diff.Hunk
+	omitted, changed := 0, 0
+	for i, h := range file.Hunks {
+		start := -1
+		for j := 0; j <= len(h.Lines); j++ {
+			kept := j < len(h.Lines) && i < len(keep) && keep[i][j]
+			if j < len(h.Lines) && h.Lines[j].Kind != diff.LineContext {
+				if kept {
+					changed++
+				} else {
+					omitted++
+				}
+			}
+			switch {
+			case kept && start < 0:
+				start = j
+			case !kept && start >= 0:
+				if run := h.Slice(start, j); hasChanges(run) {
+					hunks = append(hunks, run)
+				}
+				start = -1
+			}
+		}
+	}
+	if changed == 0 {
+		return nil, omitted
+	}
+	return file.WithHunks(hunks), omitted
+}
+
+// hasChanges reports whether a hunk adds or removes any line
+func hasChanges(h *diff.Hunk) bool {
+	for _, l := range h.Lines {
+		if l.Kind != diff.LineContext {
+			return true
+		}
+	}
+	return false
+}
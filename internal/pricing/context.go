@@ -0,0 +1,40 @@
+package pricing
+
+// bytesPerToken is the rough size of a token in source code and English
+// prose, used where the provider's tokenizer isn't available
+const bytesPerToken = 4
+
+// contextWindows are the published context windows of known models, in
+// tokens
+var contextWindows = map[string]int{
+	"gemini-2.0-flash":  1048576,
+	"gemini-1.5-pro":    2097152,
+	"gemini-1.5-flash":  1048576,
+	"gemini-pro":        32760,
+	"gpt-4o":            128000,
+	"gpt-4o-mini":       128000,
+	"gpt-4-turbo":       128000,
+	"gpt-4":             8192,
+	"gpt-3.5-turbo":     16385,
+	"o1":                200000,
+	"o1-mini":           128000,
+	"o3":                200000,
+	"o3-mini":           200000,
+	"o4-mini":           200000,
+	"claude-3-5-sonnet": 200000,
+	"claude-3-5-haiku":  200000,
+	"claude-3-opus":     200000,
+	"claude-3-sonnet":   200000,
+	"claude-3-haiku":    200000,
+}
+
+// ContextWindow returns the context window of a model in tokens, and false
+// if the model is unknown
+func ContextWindow(model string) (int, bool) {
+	return lookup(contextWindows, model)
+}
+
+// EstimateTokens approximates the number of tokens in a text
+func EstimateTokens(text string) int {
+	return (len(text) + bytesPerToken - 1) / bytesPerToken
+}
@@ -0,0 +1,134 @@
+package pricing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Price is the list price of a model in USD per million tokens
+type Price struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+}
+
+// BatchDiscount is the share of the list price charged for calls sent
+// through a batch API
+const BatchDiscount = 0.5
+
+// CachedInputDiscount is the share of the input price charged for prompt
+// tokens read from a provider's context cache. Storing the cache is billed
+// separately and isn't counted.
+const CachedInputDiscount = 0.25
+
+// defaultPrices are published list prices; override them with SetPrices
+// when negotiated rates differ
+var defaultPrices = map[string]Price{
+	"gemini-2.0-flash":           {0.10, 0.40},
+	"gemini-1.5-pro":             {1.25, 5.00},
+	"gemini-1.5-flash":           {0.075, 0.30},
+	"gemini-pro":                 {0.50, 1.50},
+	"gpt-4o":                     {2.50, 10.00},
+	"gpt-4o-mini":                {0.15, 0.60},
+	"gpt-4-turbo":                {10.00, 30.00},
+	"gpt-4":                      {30.00, 60.00},
+	"gpt-3.5-turbo":              {0.50, 1.50},
+	"o1":                         {15.00, 60.00},
+	"o1-mini":                    {1.10, 4.40},
+	"o3":                         {2.00, 8.00},
+	"o3-mini":                    {1.10, 4.40},
+	"o4-mini":                    {1.10, 4.40},
+	"claude-3-5-sonnet-20241022": {3.00, 15.00},
+	"claude-3-5-haiku-20241022":  {0.80, 4.00},
+	"claude-3-opus-20240229":     {15.00, 75.00},
+	"claude-3-sonnet-20240229":   {3.00, 15.00},
+	"claude-3-haiku-20240307":    {0.25, 1.25},
+}
+
+var (
+	mu     sync.RWMutex
+	prices = copyPrices(defaultPrices)
+)
+
+// SetPrices overrides or adds prices for the given models
+func SetPrices(overrides map[string]Price) {
+	mu.Lock()
+	defer mu.Unlock()
+	for model, price := range overrides {
+		prices[model] = price
+	}
+}
+
+// Lookup returns the price of a model. Dated or suffixed variants such as
+// gpt-4o-2024-08-06 fall back to the longest matching known prefix.
+func Lookup(model string) (Price, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return lookup(prices, model)
+}
+
+// lookup finds a model in a table keyed by model name, falling back to the
+// longest known prefix of a dated or suffixed name
+func lookup[T any](table map[string]T, model string) (T, bool) {
+	if value, ok := table[model]; ok {
+		return value, true
+	}
+	var best string
+	for known := range table {
+		if strings.HasPrefix(model, known+"-") && len(known) > len(best) {
+			best = known
+		}
+	}
+	if best == "" {
+		var zero T
+		return zero, false
+	}
+	return table[best], true
+}
+
+// Cost returns the cost in USD of an exchange, and false if the model has
+// no known price
+func Cost(model string, promptTokens, completionTokens int) (float64, bool) {
+	price, ok := Lookup(model)
+	if !ok {
+		return 0, false
+	}
+	return (float64(promptTokens)*price.InputPerMillion + float64(completionTokens)*price.OutputPerMillion) / 1e6, true
+}
+
+// Parse parses prices in the form "model=input:output,..." where input and
+// output are USD per million tokens
+func Parse(value string) (map[string]Price, error) {
+	result := make(map[string]Price)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		model, rates, ok := strings.Cut(entry, "=")
+		input, output, ok2 := strings.Cut(rates, ":")
+		if !ok || !ok2 || strings.TrimSpace(model) == "" {
+			return nil, fmt.Errorf("invalid price %q: expected model=input:output", entry)
+		}
+		in, err := strconv.ParseFloat(strings.TrimSpace(input), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid input price in %q: %w", entry, err)
+		}
+		out, err := strconv.ParseFloat(strings.TrimSpace(output), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output price in %q: %w", entry, err)
+		}
+		result[strings.TrimSpace(model)] = Price{InputPerMillion: in, OutputPerMillion: out}
+	}
+	return result, nil
+}
+
+func copyPrices(src map[string]Price) map[string]Price {
+	dst := make(map[string]Price, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,116 @@
+package prompt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// GenerateAskSystemPrompt creates the system prompt for targeted questions
+func GenerateAskSystemPrompt(language string) string {
+	return fmt.Sprintf(`You are an expert %s engineer answering a developer's specific question about a code change.
+
+## Output Format
+You must respond ONLY with valid JSON in this exact format:
+
+{
+  "answer": "Direct answer to the question with a short justification (1-5 sentences)",
+  "verdict": "yes|no|unclear",
+  "confidence": "high|medium|low",
+  "issues": [
+    {
+      "file": "path/to/file.ext",
+      "line": 42,
+      "column": 10,
+      "end_line": 42,
+      "end_column": 25,
+      "severity": "ERROR|WARNING|INFO",
+      "category": "%s",
+      "message": "Problem related to the question",
+      "suggestion": "Specific actionable fix"
+    }
+  ]
+}
+
+## Important Rules:
+- Answer ONLY the question asked; do not perform a general review
+- "verdict" answers yes/no questions; use "unclear" when the code shown is not enough to decide
+- Only list issues that are directly relevant to the question
+- Use line numbers from the hunk header`, language, strings.Join(CurrentTaxonomy().Slugs(), "|"))
+}
+
+// GenerateAskUserPrompt creates the user prompt for a targeted question
+func GenerateAskUserPrompt(request *models.AskRequest) string {
+	var builder strings.Builder
+
+	builder.WriteString(fmt.Sprintf("**Question:** %s\n\n", strings.TrimSpace(request.Question)))
+	if request.File != "" {
+		builder.WriteString(fmt.Sprintf("**File:** %s\n\n", request.File))
+	}
+	builder.WriteString(untrustedNotice)
+	writeInjectionWarning(&builder, request.InjectionFindings)
+	if request.Context != "" {
+		fence := fenceFor(request.Context)
+		builder.WriteString("**Surrounding Code:**\n" + fence + "\n")
+		builder.WriteString(request.Context)
+		builder.WriteString("\n" + fence + "\n\n")
+	}
+	fence := fenceFor(request.Hunk)
+	builder.WriteString("**Hunk:**\n" + fence + "diff\n")
+	builder.WriteString(request.Hunk)
+	builder.WriteString("\n" + fence + "\n\n")
+	builder.WriteString("Respond ONLY with valid JSON in the format specified\n")
+
+	return builder.String()
+}
+
+// ParseAskResponse parses the answer to a targeted question. Issues are
+// parsed with the regular review parser; a response that isn't JSON is
+// returned verbatim as the answer.
+func ParseAskResponse(responseText string, file string) (*models.AskResponse, error) {
+	var raw struct {
+		Answer     string `json:"answer"`
+		Verdict    string `json:"verdict"`
+		Confidence string `json:"confidence"`
+	}
+
+	jsonStr := extractJSON(responseText)
+	if err := json.Unmarshal([]byte(jsonStr), &raw); err != nil || raw.Answer == "" {
+		return &models.AskResponse{
+			Answer:      strings.TrimSpace(responseText),
+			Verdict:     "unclear",
+			Confidence:  "low",
+			Diagnostics: []models.Diagnostic{},
+		}, nil
+	}
+
+	diagnostics := []models.Diagnostic{}
+	if parsed, err := parseStructuredResponse(jsonStr); err == nil {
+		diagnostics = parsed.Diagnostics
+	}
+	for i := range diagnostics {
+		if diagnostics[i].Location.Path == "" {
+			diagnostics[i].Location.Path = file
+		}
+	}
+
+	return &models.AskResponse{
+		Answer:      raw.Answer,
+		Verdict:     normalizeChoice(raw.Verdict, []string{"yes", "no", "unclear"}, "unclear"),
+		Confidence:  normalizeChoice(raw.Confidence, []string{"high", "medium", "low"}, "low"),
+		Diagnostics: diagnostics,
+	}, nil
+}
+
+// normalizeChoice lower-cases value and returns it if it is one of choices
+func normalizeChoice(value string, choices []string, defaultValue string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, c := range choices {
+		if value == c {
+			return value
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,52 @@
+package prompt
+
+import (
+	"strings"
+)
+
+// minRepeatedTail is the shortest overlap between the end of a cut-off
+// response and the start of its continuation treated as repeated text
+// rather than a coincidence, and the longest looked for
+const (
+	minRepeatedTail = 16
+	maxRepeatedTail = 512
+)
+
+// GenerateContinuationPrompt creates the user prompt for carrying on a
+// response that stopped at the output token limit. It repeats the original
+// request, so the model has the diff in front of it, followed by the output
+// so far.
+func GenerateContinuationPrompt(userPrompt, partial string) string {
+	var builder strings.Builder
+
+	builder.WriteString(userPrompt)
+	builder.WriteString("\n\n## Continuation\n")
+	builder.WriteString("Your previous response to this request was cut off at the output token limit. It is reproduced below exactly as received:\n\n")
+	fence := fenceFor(partial)
+	builder.WriteString(fence + "\n")
+	builder.WriteString(partial)
+	builder.WriteString("\n" + fence + "\n\n")
+	builder.WriteString("Continue the response from the exact character where it stops. Output ONLY the remaining text: do not repeat anything already written, do not start a new JSON object, and do not wrap the output in a code block. The combined text must be the single valid JSON object requested above.\n")
+
+	return builder.String()
+}
+
+// JoinContinuation appends a continuation to the text it carries on. A code
+// fence the model opened the continuation with is dropped, as is any tail of
+// the previous text it repeated.
+func JoinContinuation(previous, next string) string {
+	if rest, ok := strings.CutPrefix(strings.TrimLeft(next, " \t\r\n"), "```"); ok {
+		// Drop the fence and its language tag
+		if _, after, found := strings.Cut(rest, "\n"); found {
+			next = after
+		}
+		next = strings.TrimSuffix(strings.TrimRight(next, " \t\r\n"), "```")
+	}
+
+	for n := min(len(previous), len(next), maxRepeatedTail); n >= minRepeatedTail; n-- {
+		if strings.HasSuffix(previous, next[:n]) {
+			return previous + next[n:]
+		}
+	}
+	return previous + next
+}
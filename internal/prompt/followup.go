@@ -0,0 +1,87 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// FollowupContext is a completed review that a follow-up question refers to
+type FollowupContext struct {
+	Diff        string
+	Files       []models.FileContent
+	Overview    string
+	Diagnostics []models.Diagnostic
+	Focus       *models.Diagnostic // The diagnostic asked about, if any
+	History     []models.ConversationTurn
+}
+
+// GenerateFollowupSystemPrompt creates the system prompt for follow-up
+// questions about a review
+func GenerateFollowupSystemPrompt(language string) string {
+	return fmt.Sprintf(`You are the expert %s reviewer who reviewed the code change below. A developer is asking a follow-up question about your review.
+
+## Important Rules:
+- Answer the question directly and concretely, referring to the code by file and line
+- Explain the reasoning behind a finding when asked "why"; show a minimal example or fix when it helps
+- If, on reflection, a finding is wrong or overstated, say so plainly
+- Stay on the topic of this change and this review
+- Respond in Markdown prose; do not respond with JSON`, language)
+}
+
+// GenerateFollowupUserPrompt creates the user prompt for a follow-up
+// question, including the original diff, the review and the conversation
+// so far
+func GenerateFollowupUserPrompt(review *FollowupContext, question string) string {
+	var builder strings.Builder
+
+	builder.WriteString(untrustedNotice)
+	fence := fenceFor(review.Diff)
+	builder.WriteString("**Code Changes:**\n" + fence + "diff\n")
+	builder.WriteString(review.Diff)
+	builder.WriteString("\n" + fence + "\n\n")
+	writeFileContents(&builder, review.Files)
+
+	if review.Overview != "" {
+		builder.WriteString(fmt.Sprintf("**Your Review Overview:** %s\n\n", review.Overview))
+	}
+	if len(review.Diagnostics) > 0 {
+		builder.WriteString("**Your Findings:**\n")
+		for i, d := range review.Diagnostics {
+			builder.WriteString(fmt.Sprintf("%d. %s\n", i, formatFinding(d)))
+		}
+		builder.WriteString("\n")
+	}
+	if review.Focus != nil {
+		builder.WriteString(fmt.Sprintf("**The question is about this finding:** %s\n", formatFinding(*review.Focus)))
+		if review.Focus.Suggestion != "" {
+			builder.WriteString(fmt.Sprintf("Suggested fix: %s\n", review.Focus.Suggestion))
+		}
+		builder.WriteString("\n")
+	}
+
+	if len(review.History) > 0 {
+		builder.WriteString("**Conversation So Far:**\n")
+		for _, turn := range review.History {
+			speaker := "Developer"
+			if turn.Role == models.RoleAssistant {
+				speaker = "You"
+			}
+			builder.WriteString(fmt.Sprintf("%s: %s\n\n", speaker, strings.TrimSpace(turn.Content)))
+		}
+	}
+
+	builder.WriteString(fmt.Sprintf("**Developer's Question:** %s\n", strings.TrimSpace(question)))
+
+	return builder.String()
+}
+
+// formatFinding renders a diagnostic on one line
+func formatFinding(d models.Diagnostic) string {
+	lines := fmt.Sprint(d.Location.Range.Start.Line)
+	if end := d.Location.Range.End.Line; end > d.Location.Range.Start.Line {
+		lines += fmt.Sprintf("-%d", end)
+	}
+	return fmt.Sprintf("%s:%s [%s] %s", d.Location.Path, lines, d.Severity, d.Message)
+}
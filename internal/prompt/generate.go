@@ -0,0 +1,200 @@
+package prompt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// maxTitleLength is the longest pull request title kept; GitHub truncates
+// longer titles in most views
+const maxTitleLength = 72
+
+// GeneratePRDescriptionSystemPrompt creates the system prompt for writing a
+// pull request title and description
+func GeneratePRDescriptionSystemPrompt(language string) string {
+	return fmt.Sprintf(`You are an expert %s engineer writing the title and description of a pull request for your reviewers.
+
+## Output Format
+You must respond ONLY with valid JSON in this exact format:
+
+{
+  "title": "Imperative summary of the change, under 72 characters",
+  "summary": "1-3 short Markdown paragraphs: what changed, why, and anything reviewers should look at closely",
+  "changelog": [
+    "One entry per user-visible change, e.g. Add retry of failed webhook deliveries"
+  ]
+}
+
+## Important Rules:
+- Describe only what the diff shows; never invent motivation, tickets or test results
+- Use the author's notes, when given, for the reason behind the change
+- Write the title in the imperative mood ("Add", "Fix", "Remove"), without a trailing period
+- Keep changelog entries short and aimed at users of the code; leave out refactors and test-only changes unless they are all the change does
+- Return an empty changelog when nothing is user-visible`, language)
+}
+
+// GeneratePRDescriptionUserPrompt creates the user prompt for writing a pull
+// request title and description
+func GeneratePRDescriptionUserPrompt(request *models.PRDescriptionRequest) string {
+	var builder strings.Builder
+
+	if info := request.GitInfo; info != nil && info.BranchName != "" {
+		builder.WriteString(fmt.Sprintf("**Branch:** %s\n\n", info.BranchName))
+	}
+	if notes := strings.TrimSpace(request.Notes); notes != "" {
+		fence := fenceFor(notes)
+		builder.WriteString("**Author's Notes:**\n" + fence + "\n" + notes + "\n" + fence + "\n\n")
+	}
+	builder.WriteString(untrustedNotice)
+	writeInjectionWarning(&builder, request.InjectionFindings)
+
+	fence := fenceFor(request.GitDiff)
+	builder.WriteString("**Changes:**\n" + fence + "diff\n")
+	builder.WriteString(request.GitDiff)
+	builder.WriteString("\n" + fence + "\n\n")
+	builder.WriteString("Respond ONLY with valid JSON in the format specified\n")
+
+	return builder.String()
+}
+
+// ParsePRDescription parses a generated pull request title and description
+func ParsePRDescription(responseText string) (*models.PRDescription, error) {
+	var raw struct {
+		Title     string   `json:"title"`
+		Summary   string   `json:"summary"`
+		Changelog []string `json:"changelog"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(responseText)), &raw); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON: %w", err)
+	}
+
+	title := strings.TrimSuffix(strings.TrimSpace(strings.SplitN(raw.Title, "\n", 2)[0]), ".")
+	if title == "" {
+		return nil, fmt.Errorf("response has no title")
+	}
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = strings.TrimSpace(string(runes[:maxTitleLength-1])) + "…"
+	}
+
+	changelog := make([]string, 0, len(raw.Changelog))
+	for _, entry := range raw.Changelog {
+		entry = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(entry), "-*• "))
+		if entry != "" {
+			changelog = append(changelog, entry)
+		}
+	}
+
+	return &models.PRDescription{
+		Title:     title,
+		Summary:   strings.TrimSpace(raw.Summary),
+		Changelog: changelog,
+	}, nil
+}
+
+// Doc comment placements
+const (
+	PlacementAbove = "above" // Comment lines before the declaration, as in Go, Java or Rust
+	PlacementBelow = "below" // Docstring after the declaration line, as in Python
+)
+
+// GenerateDocsSystemPrompt creates the system prompt for writing missing
+// documentation
+func GenerateDocsSystemPrompt(language string) string {
+	return fmt.Sprintf(`You are an expert %s engineer writing the documentation a change is missing, for the maintainers of the code.
+
+## Output Format
+You must respond ONLY with valid JSON in this exact format:
+
+{
+  "docs": [
+    {
+      "file": "path/to/file",
+      "line": 42,
+      "symbol": "ParseConfig",
+      "placement": "above",
+      "comment": "// ParseConfig reads the gateway configuration from path,\n// applying defaults for missing settings."
+    }
+  ]
+}
+
+"line" is the line of the declaration in the new version of the file. "comment" is the complete doc comment exactly as it should appear in the file, with the language's comment markers and indentation. Use "placement": "above" for comments placed before the declaration line, and "below" for docstrings placed right after it, as in Python.
+
+## Important Rules:
+- Document only declarations without documentation: public or exported functions, methods, types, constants and variables, and the package or module itself
+- For a diff, only document declarations on added lines
+- Follow the language's documentation conventions, e.g. Go comments start with the name of what they document, Java and JavaScript use /** */ blocks, Python uses docstrings
+- Describe what the code does, its important behavior and any non-obvious constraints, from what the code shows; never restate the signature or invent behavior
+- Keep comments short: one to three sentences, more only for complex APIs
+- Return an empty docs array when nothing is missing`, language)
+}
+
+// GenerateDocsUserPrompt creates the user prompt for writing missing
+// documentation
+func GenerateDocsUserPrompt(request *models.DocsRequest) string {
+	var builder strings.Builder
+
+	builder.WriteString(untrustedNotice)
+	writeInjectionWarning(&builder, request.InjectionFindings)
+
+	if request.File != nil {
+		builder.WriteString("**File:**\n")
+		writeNumberedFile(&builder, *request.File)
+	} else {
+		fence := fenceFor(request.GitDiff)
+		builder.WriteString("**Changes:**\n" + fence + "diff\n")
+		builder.WriteString(request.GitDiff)
+		builder.WriteString("\n" + fence + "\n\n")
+	}
+	builder.WriteString("Respond ONLY with valid JSON in the format specified\n")
+
+	return builder.String()
+}
+
+// DocProposal is documentation proposed by the model, before it is checked
+// against the file
+type DocProposal struct {
+	Path      string
+	Line      int
+	Symbol    string
+	Placement string // PlacementAbove or PlacementBelow
+	Comment   string
+}
+
+// ParseDocs parses generated documentation
+func ParseDocs(responseText string) ([]DocProposal, error) {
+	var raw struct {
+		Docs []struct {
+			File      string `json:"file"`
+			Line      int    `json:"line"`
+			Symbol    string `json:"symbol"`
+			Placement string `json:"placement"`
+			Comment   string `json:"comment"`
+		} `json:"docs"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(responseText)), &raw); err != nil {
+		return nil, fmt.Errorf("response is not valid JSON: %w", err)
+	}
+
+	docs := make([]DocProposal, 0, len(raw.Docs))
+	for _, d := range raw.Docs {
+		comment := strings.TrimRight(strings.ReplaceAll(d.Comment, "\r\n", "\n"), "\n")
+		if strings.TrimSpace(comment) == "" || d.File == "" || d.Line < 1 {
+			continue
+		}
+		placement := strings.ToLower(strings.TrimSpace(d.Placement))
+		if placement != PlacementBelow {
+			placement = PlacementAbove
+		}
+		docs = append(docs, DocProposal{
+			Path:      d.File,
+			Line:      d.Line,
+			Symbol:    strings.TrimSpace(d.Symbol),
+			Placement: placement,
+			Comment:   comment,
+		})
+	}
+	return docs, nil
+}
@@ -0,0 +1,68 @@
+package prompt
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// responseLanguages maps lower-case language tags to the names used in
+// prompts. Models follow a language name more reliably than a tag.
+var responseLanguages = map[string]string{
+	"ar": "Arabic", "bn": "Bengali", "cs": "Czech", "da": "Danish", "de": "German", "el": "Greek",
+	"en": "English", "es": "Spanish", "fa": "Persian", "fi": "Finnish", "fr": "French", "he": "Hebrew",
+	"hi": "Hindi", "hu": "Hungarian", "id": "Indonesian", "it": "Italian", "ja": "Japanese", "ko": "Korean",
+	"ms": "Malay", "nl": "Dutch", "no": "Norwegian", "pl": "Polish", "pt": "Portuguese", "pt-br": "Brazilian Portuguese",
+	"ro": "Romanian", "ru": "Russian", "sv": "Swedish", "th": "Thai", "tl": "Filipino", "tr": "Turkish",
+	"uk": "Ukrainian", "vi": "Vietnamese", "zh": "Simplified Chinese", "zh-cn": "Simplified Chinese",
+	"zh-hans": "Simplified Chinese", "zh-tw": "Traditional Chinese", "zh-hant": "Traditional Chinese",
+}
+
+// ResponseLanguage resolves a response_language value, a language tag such
+// as "vi" or "pt-BR" or an English language name, to the name used in
+// prompts. Regional tags without an entry fall back to their language.
+func ResponseLanguage(value string) (string, bool) {
+	tag := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(value), "_", "-"))
+	if tag == "" {
+		return "", false
+	}
+	if name, ok := responseLanguages[tag]; ok {
+		return name, true
+	}
+	if base, _, ok := strings.Cut(tag, "-"); ok {
+		if name, ok := responseLanguages[base]; ok {
+			return name, true
+		}
+	}
+	for _, name := range responseLanguages {
+		if strings.EqualFold(name, tag) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// ResponseLanguageTags lists the supported language tags, for error
+// messages
+func ResponseLanguageTags() []string {
+	tags := make([]string, 0, len(responseLanguages))
+	for tag := range responseLanguages {
+		if !strings.Contains(tag, "-") {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// writeResponseLanguage asks for prose in the team's language while keeping
+// everything the gateway parses unchanged
+func writeResponseLanguage(builder *strings.Builder, name string) {
+	if name == "" {
+		return
+	}
+	builder.WriteString(fmt.Sprintf(`
+**Response Language:**
+Write the overview, every issue's message and suggestion, and the body of any commit message in %s. Keep the JSON keys and the severity and category values in English, exactly as specified, and leave file paths, code, identifiers, fix replacements and commit message types untranslated.
+`, name))
+}
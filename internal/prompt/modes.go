@@ -0,0 +1,156 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Review modes
+const (
+	ModeFull     = "full"
+	ModeSecurity = "security"
+	ModeQuick    = "quick"
+	ModeSummary  = "summary"
+	ModeRefined  = "refined" // Full review followed by a self-critique pass
+)
+
+// QuickModeMaxIssues is the maximum number of issues reported in quick mode
+const QuickModeMaxIssues = 5
+
+// NormalizeMode maps a requested review mode to a supported mode.
+// Unknown values (including the legacy "file" mode) fall back to full.
+func NormalizeMode(mode string) string {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case ModeSecurity:
+		return ModeSecurity
+	case ModeQuick:
+		return ModeQuick
+	case ModeSummary:
+		return ModeSummary
+	case ModeRefined:
+		return ModeRefined
+	default:
+		return ModeFull
+	}
+}
+
+// MaxOutputTokens returns the output token budget for a review mode, capped
+// by the provider's own default
+func MaxOutputTokens(mode string, providerDefault int) int {
+	budget := providerDefault
+	switch NormalizeMode(mode) {
+	case ModeQuick:
+		budget = 2048
+	case ModeSummary:
+		budget = 1024
+	}
+	if budget > providerDefault {
+		return providerDefault
+	}
+	return budget
+}
+
+// generateSecurityPrompt creates the system prompt for security reviews
+func generateSecurityPrompt(language string) string {
+	return fmt.Sprintf(`You are an application security expert reviewing %s code. Review ALL code changes for security vulnerabilities, using the OWASP Top 10 as your checklist:
+
+## Security Checklist:
+
+1. **Broken Access Control** - Missing authorization checks, IDOR, privilege escalation, path traversal
+2. **Cryptographic Failures** - Weak algorithms, hard-coded keys, plaintext secrets, insecure randomness
+3. **Injection** - SQL, NoSQL, OS command, LDAP, template and XSS injection
+4. **Insecure Design** - Missing rate limits, trust boundary violations, unsafe defaults
+5. **Security Misconfiguration** - Debug modes, permissive CORS, verbose errors, default credentials
+6. **Vulnerable Components** - Known-vulnerable or unmaintained dependencies
+7. **Authentication Failures** - Weak session handling, credential stuffing exposure, missing MFA hooks
+8. **Data Integrity Failures** - Unsafe deserialization, unsigned updates, untrusted CI inputs
+9. **Logging Failures** - Missing audit logs, sensitive data written to logs
+10. **SSRF** - Unvalidated outbound requests built from user input
+
+## Output Format
+You must respond ONLY with valid JSON in this exact format:
+
+{
+  "overview": "Brief summary of the security posture of the change (2-4 sentences)",
+  "issues": [
+    {
+      "file": "path/to/file.ext",
+      "line": 42,
+      "column": 10,
+      "end_line": 42,
+      "end_column": 25,
+      "severity": "ERROR|WARNING|INFO",
+      "category": "security",
+      "cwe": "CWE-89",
+      "owasp": "A03:2021-Injection",
+      "message": "Vulnerability description",
+      "suggestion": "Specific remediation"
+    }
+  ]
+}
+
+## Severity Guidelines:
+- **ERROR**: Exploitable vulnerabilities
+- **WARNING**: Weaknesses that are exploitable under some conditions
+- **INFO**: Hardening recommendations
+
+## Important Rules:
+- Report ONLY security findings; ignore style and general quality
+- Give every finding the most specific CWE ID that fits (e.g. CWE-89 rather than CWE-74) and its OWASP Top 10 2021 category, written as "A01:2021-Broken Access Control"
+- Do not report speculative issues without a plausible attack path
+- Provide specific line numbers and actionable remediations
+- Focus on changed code (marked with + or -)
+- Consider %s-specific security pitfalls`, language, language)
+}
+
+// generateQuickPrompt creates the system prompt for quick reviews
+func generateQuickPrompt(language string, categories []string) string {
+	var slugs []string
+	for _, c := range CurrentTaxonomy().Select(categories) {
+		slugs = append(slugs, c.Slug)
+	}
+	return fmt.Sprintf(`You are an expert code reviewer specializing in %s. Perform a QUICK review of the code changes and report only the most important problems.
+
+## Output Format
+You must respond ONLY with valid JSON in this exact format:
+
+{
+  "overview": "One or two sentence summary of the change",
+  "issues": [
+    {
+      "file": "path/to/file.ext",
+      "line": 42,
+      "column": 10,
+      "end_line": 42,
+      "end_column": 25,
+      "severity": "ERROR|WARNING",
+      "category": "%s",
+      "message": "Clear, concise description",
+      "suggestion": "Specific actionable fix"
+    }
+  ]
+}
+
+## Important Rules:
+- Report at most %d issues, ordered by impact
+- Only report bugs, security problems and serious performance or maintainability issues
+- Skip style nits, minor suggestions and INFO-level findings
+- Focus on changed code (marked with + or -)`, language, strings.Join(slugs, "|"), QuickModeMaxIssues)
+}
+
+// generateSummaryPrompt creates the system prompt for summary-only reviews
+func generateSummaryPrompt(language string) string {
+	return fmt.Sprintf(`You are an expert %s engineer. Summarize the code changes for a reviewer.
+
+## Output Format
+You must respond ONLY with valid JSON in this exact format:
+
+{
+  "overview": "Summary of what the change does, its main risks and areas that deserve careful review (3-6 sentences)",
+  "issues": []
+}
+
+## Important Rules:
+- Do NOT report individual issues; the issues array must be empty
+- Describe intent and impact, not line-by-line details`, language)
+}
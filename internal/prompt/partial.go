@@ -0,0 +1,171 @@
+package prompt
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// ParserPartial marks a response cut off before its JSON was complete; the
+// diagnostics are the issues finished before it stopped
+const ParserPartial = "partial"
+
+// PartialParser follows a JSON review response as it streams in and keeps
+// every issue completed so far, so a response cut off by the token limit,
+// a timeout or a dropped connection still yields its finished issues. It
+// is safe for concurrent use.
+type PartialParser struct {
+	mu sync.Mutex
+	partialState
+}
+
+// partialState is the scan state of a PartialParser
+type partialState struct {
+	text          string
+	pos           int  // Next byte of text to scan
+	depth         int  // Nesting of objects and arrays; 0 before the first {
+	inString      bool // Inside a string literal
+	escaped       bool // The previous byte was a backslash within a string
+	stringStart   int
+	afterColon    bool   // The next top-level value belongs to key
+	key           string // Last top-level key
+	issuesDepth   int    // Depth inside the issues array; zero outside it
+	issueStart    int
+	issues        []json.RawMessage
+	overview      string
+	commitMessage string
+}
+
+// ParsePartial recovers the finished issues of a cut-off response. It
+// returns false when nothing was finished.
+func ParsePartial(text string) (*models.AIProviderResponse, bool) {
+	var p PartialParser
+	p.Update(text)
+	return p.Response()
+}
+
+// Update scans the response text received so far. Each call passes the
+// whole text; text that doesn't extend the previous one, as when a call is
+// retried, starts the parse over.
+func (p *PartialParser) Update(text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(text) < p.pos || text[:p.pos] != p.text[:p.pos] {
+		p.partialState = partialState{}
+	}
+	p.text = text
+	for ; p.pos < len(text); p.pos++ {
+		p.scan(text[p.pos])
+	}
+}
+
+// scan advances the parser over one byte of the response
+func (p *partialState) scan(c byte) {
+	i := p.pos
+	if p.inString {
+		switch {
+		case p.escaped:
+			p.escaped = false
+		case c == '\\':
+			p.escaped = true
+		case c == '"':
+			p.inString = false
+			if p.depth == 1 {
+				p.topLevelString(p.text[p.stringStart : i+1])
+			}
+		}
+		return
+	}
+	if p.depth == 0 {
+		// Skip prose and code fences before the JSON object
+		if c == '{' {
+			p.depth = 1
+		}
+		return
+	}
+
+	switch c {
+	case '"':
+		p.inString = true
+		p.stringStart = i
+	case ':':
+		if p.depth == 1 {
+			p.afterColon = true
+		}
+	case ',':
+		if p.depth == 1 {
+			p.afterColon = false
+		}
+	case '{', '[':
+		if p.depth == 1 && c == '[' && p.afterColon && p.key == "issues" {
+			p.issuesDepth = 2
+		}
+		if p.issuesDepth > 0 && p.depth == p.issuesDepth && c == '{' {
+			p.issueStart = i
+		}
+		p.depth++
+	case '}', ']':
+		p.depth--
+		if p.issuesDepth > 0 && p.depth == p.issuesDepth && c == '}' {
+			if issue := p.text[p.issueStart : i+1]; json.Valid([]byte(issue)) {
+				p.issues = append(p.issues, json.RawMessage(issue))
+			}
+		}
+		if p.depth < p.issuesDepth {
+			p.issuesDepth = 0
+		}
+	}
+}
+
+// topLevelString records a key of the response object, or the value of the
+// overview and commit message
+func (p *partialState) topLevelString(literal string) {
+	var value string
+	if err := json.Unmarshal([]byte(literal), &value); err != nil {
+		return
+	}
+	if !p.afterColon {
+		p.key = value
+		return
+	}
+	switch p.key {
+	case "overview":
+		p.overview = value
+	case "commit_message":
+		p.commitMessage = value
+	}
+}
+
+// Text returns the response text received so far
+func (p *PartialParser) Text() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.text
+}
+
+// Response builds a review from the issues completed so far. It returns
+// false when neither an issue nor the overview was finished.
+func (p *PartialParser) Response() (*models.AIProviderResponse, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.issues) == 0 && p.overview == "" {
+		return nil, false
+	}
+	data, err := json.Marshal(struct {
+		Overview      string            `json:"overview"`
+		CommitMessage string            `json:"commit_message,omitempty"`
+		Issues        []json.RawMessage `json:"issues"`
+	}{p.overview, p.commitMessage, p.issues})
+	if err != nil {
+		return nil, false
+	}
+	response, err := parseStructuredResponse(string(data))
+	if err != nil {
+		return nil, false
+	}
+	response.ParserPath = ParserPartial
+	return response, true
+}
@@ -0,0 +1,108 @@
+package prompt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// GenerateCritiqueSystemPrompt creates the system prompt for the second
+// pass of a refined review, in which the model audits its own findings
+func GenerateCritiqueSystemPrompt(language string) string {
+	return fmt.Sprintf(`You are a senior %s engineer auditing the findings of a first-pass automated code review. Your job is to remove noise, not to review the code again.
+
+## For every first-pass finding:
+1. Check it against the diff. Drop it if it is a false positive: speculative, contradicted by the code shown, about unchanged code, a duplicate of another finding, or a pure style preference
+2. For findings you keep, correct "file" and "line" so they point at the exact changed line, and "end_line" so it covers the code the finding is about, using new-file line numbers from the hunk headers
+3. Lower the severity if it is overstated; tighten the message and suggestion if they are vague
+4. Do NOT add new findings
+
+## Output Format
+You must respond ONLY with valid JSON in this exact format, listing only the findings you keep:
+
+{
+  "overview": "Brief summary of the change and the confirmed findings (2-4 sentences)",
+  "issues": [
+    {
+      "file": "path/to/file.ext",
+      "line": 42,
+      "column": 10,
+      "end_line": 42,
+      "end_column": 25,
+      "severity": "ERROR|WARNING|INFO",
+      "category": "same category as the first-pass finding",
+      "message": "Clear description",
+      "suggestion": "Specific actionable fix"
+    }
+  ]
+}`, language)
+}
+
+// GenerateCritiqueUserPrompt creates the user prompt for the second pass of
+// a refined review from the diff and the first-pass findings
+func GenerateCritiqueUserPrompt(request *models.ReviewRequest, firstPass *models.AIProviderResponse) string {
+	var builder strings.Builder
+
+	builder.WriteString(untrustedNotice)
+	writeInjectionWarning(&builder, request.InjectionFindings)
+
+	fence := fenceFor(request.GitDiff)
+	builder.WriteString("**Git Diff:**\n" + fence + "diff\n")
+	builder.WriteString(request.GitDiff)
+	builder.WriteString("\n" + fence + "\n\n")
+
+	writeFileContents(&builder, request.FileContents)
+
+	type issue struct {
+		File       string `json:"file"`
+		Line       int    `json:"line"`
+		Column     int    `json:"column,omitempty"`
+		EndLine    int    `json:"end_line,omitempty"`
+		EndColumn  int    `json:"end_column,omitempty"`
+		Severity   string `json:"severity"`
+		Category   string `json:"category"`
+		Message    string `json:"message"`
+		Suggestion string `json:"suggestion,omitempty"`
+	}
+	issues := make([]issue, 0, len(firstPass.Diagnostics))
+	for _, d := range firstPass.Diagnostics {
+		issues = append(issues, issue{
+			File:       d.Location.Path,
+			Line:       d.Location.Range.Start.Line,
+			Column:     d.Location.Range.Start.Column,
+			EndLine:    d.Location.Range.End.Line,
+			EndColumn:  d.Location.Range.End.Column,
+			Severity:   d.Severity,
+			Category:   d.Code.Value,
+			Message:    d.Message,
+			Suggestion: d.Suggestion,
+		})
+	}
+	findings, _ := json.MarshalIndent(map[string]interface{}{
+		"overview": firstPass.Overview,
+		"issues":   issues,
+	}, "", "  ")
+
+	fence = fenceFor(string(findings))
+	builder.WriteString("**First-pass findings:**\n" + fence + "json\n")
+	builder.Write(findings)
+	builder.WriteString("\n" + fence + "\n\n")
+	builder.WriteString("Audit each finding as instructed and respond ONLY with valid JSON in the format specified\n")
+	writeResponseLanguage(&builder, request.ResponseLanguage)
+
+	return builder.String()
+}
+
+// ParseCritiqueResponse parses the second-pass response. Unlike
+// ParseAIResponse there is no free-text fallback: a critique that isn't
+// valid JSON is rejected so the caller can keep the first-pass findings.
+func ParseCritiqueResponse(responseText string) (*models.AIProviderResponse, error) {
+	response, err := parseStructuredResponse(extractJSON(responseText))
+	if err != nil {
+		return nil, fmt.Errorf("critique response is not valid JSON: %w", err)
+	}
+	response.ParserPath = ParserStructured
+	return response, nil
+}
@@ -0,0 +1,141 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parser paths of responses whose JSON needed fixing
+const (
+	ParserRepaired   = "repaired"   // Malformed JSON fixed by RepairJSON
+	ParserReprompted = "reprompted" // Malformed JSON rewritten by the model when asked
+)
+
+// RepairJSON fixes the mistakes models commonly make when writing JSON:
+// trailing commas, comments, single-quoted strings, raw newlines and tabs
+// in strings, unescaped quotes inside strings and invalid escapes. Text
+// before the first { and after the last } is dropped. The result is not
+// guaranteed to be valid.
+func RepairJSON(text string) string {
+	start := strings.IndexByte(text, '{')
+	if start < 0 {
+		return text
+	}
+	text = text[start:]
+	if end := strings.LastIndexByte(text, '}'); end >= 0 {
+		text = text[:end+1]
+	}
+
+	var out strings.Builder
+	out.Grow(len(text))
+	var quote byte // Delimiter of the string being copied; zero outside strings
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if quote == 0 {
+			switch {
+			case c == '"' || c == '\'':
+				quote = c
+				out.WriteByte('"')
+			case c == ',' && closesNext(text, i+1):
+				// Drop trailing commas
+			case c == '/' && i+1 < len(text) && text[i+1] == '/':
+				for i < len(text) && text[i] != '\n' {
+					i++
+				}
+				i--
+			case c == '/' && i+1 < len(text) && text[i+1] == '*':
+				if end := strings.Index(text[i+2:], "*/"); end >= 0 {
+					i += end + 3
+				} else {
+					i = len(text)
+				}
+			default:
+				out.WriteByte(c)
+			}
+			continue
+		}
+
+		switch {
+		case c == '\\' && i+1 < len(text):
+			next := text[i+1]
+			switch {
+			case next == '\'':
+				out.WriteByte('\'')
+				i++
+			case strings.IndexByte(`"\/bfnrtu`, next) >= 0:
+				out.WriteByte(c)
+				out.WriteByte(next)
+				i++
+			default:
+				out.WriteString(`\\`)
+			}
+		case c == quote && endsString(text, i+1):
+			quote = 0
+			out.WriteByte('"')
+		case c == '"':
+			out.WriteString(`\"`)
+		case c == '\n':
+			out.WriteString(`\n`)
+		case c == '\r':
+			out.WriteString(`\r`)
+		case c == '\t':
+			out.WriteString(`\t`)
+		case c < 0x20:
+			fmt.Fprintf(&out, `\u%04x`, c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
+
+// closesNext reports whether the next non-space byte from i closes an
+// object or array
+func closesNext(text string, i int) bool {
+	for ; i < len(text); i++ {
+		switch text[i] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '}', ']':
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+// endsString reports whether a double quote followed by the text from i
+// closes its string, rather than being a quote the model forgot to escape:
+// the next non-space byte must be one that can follow a JSON string
+func endsString(text string, i int) bool {
+	for ; i < len(text); i++ {
+		switch text[i] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case ',', ':', '}', ']':
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// GenerateJSONRepairPrompt creates the user prompt asking the model to
+// rewrite a response that could not be parsed as valid JSON. It is sent
+// with the original system prompt, which describes the format.
+func GenerateJSONRepairPrompt(response string, parseErr error) string {
+	var builder strings.Builder
+
+	builder.WriteString("Your previous response could not be parsed as JSON")
+	if parseErr != nil {
+		builder.WriteString(" (" + parseErr.Error() + ")")
+	}
+	builder.WriteString(". It is reproduced below:\n\n")
+	fence := fenceFor(response)
+	builder.WriteString(fence + "\n")
+	builder.WriteString(response)
+	builder.WriteString("\n" + fence + "\n\n")
+	builder.WriteString("Rewrite it as a single valid JSON object in the format specified, keeping the overview and every issue with its wording unchanged. Respond ONLY with the JSON, without a code block or any other text.\n")
+
+	return builder.String()
+}
@@ -0,0 +1,227 @@
+package prompt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// SecurityCategory is the category of security review findings. It is
+// outside any taxonomy and never normalized away.
+const SecurityCategory = "security"
+
+// Taxonomy is the set of categories the model reviews against and its
+// findings are normalized to
+type Taxonomy struct {
+	Categories []Category `yaml:"categories"`
+	// Default is the category of findings whose category the taxonomy
+	// doesn't know; empty files them under possible-issue, or the last
+	// category when there is none
+	Default string `yaml:"default"`
+}
+
+var (
+	taxonomyMu     sync.RWMutex
+	activeTaxonomy = &Taxonomy{Categories: DefaultCategories}
+)
+
+// LoadTaxonomy reads a taxonomy from a YAML file:
+//
+//	default: other
+//	categories:
+//	  - slug: correctness
+//	    name: Correctness
+//	    description: Logic errors and unhandled edge cases
+//	    severity: ERROR
+//	    aliases: [possible-bug, bug]
+//	    url: https://wiki.example.com/review/correctness
+func LoadTaxonomy(file string) (*Taxonomy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read categories file: %w", err)
+	}
+
+	var t Taxonomy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&t); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse categories file %s: %w", file, err)
+	}
+	if len(t.Categories) == 0 {
+		return nil, fmt.Errorf("categories file %s lists no categories", file)
+	}
+
+	seen := make(map[string]string)
+	for i := range t.Categories {
+		c := &t.Categories[i]
+		c.Slug = slugify(c.Slug)
+		if c.Slug == "" {
+			return nil, fmt.Errorf("categories file %s: category %d needs a slug", file, i)
+		}
+		if c.Slug == SecurityCategory {
+			return nil, fmt.Errorf("categories file %s: %s is reserved for security reviews", file, SecurityCategory)
+		}
+		if c.Name == "" {
+			c.Name = c.Slug
+		}
+		if c.URL != "" {
+			if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("categories file %s: invalid url %q for %s: must be an http or https URL", file, c.URL, c.Slug)
+			}
+		}
+		c.Severity = strings.ToUpper(strings.TrimSpace(c.Severity))
+		switch c.Severity {
+		case "", "INFO", "WARNING", "ERROR":
+		default:
+			return nil, fmt.Errorf("categories file %s: invalid severity %q for %s: must be INFO, WARNING or ERROR", file, c.Severity, c.Slug)
+		}
+		for _, key := range append([]string{c.Slug, c.Name}, c.Aliases...) {
+			key = slugify(key)
+			if other, ok := seen[key]; ok && other != c.Slug {
+				return nil, fmt.Errorf("categories file %s: %q names both %s and %s", file, key, other, c.Slug)
+			}
+			seen[key] = c.Slug
+		}
+	}
+	if t.Default != "" {
+		t.Default = slugify(t.Default)
+		if _, ok := t.lookup(t.Default); !ok {
+			return nil, fmt.Errorf("categories file %s: default %q is not a listed category", file, t.Default)
+		}
+	}
+	return &t, nil
+}
+
+// SetTaxonomy installs a taxonomy; nil restores the built-in categories
+func SetTaxonomy(t *Taxonomy) {
+	if t == nil {
+		t = &Taxonomy{Categories: DefaultCategories}
+	}
+	taxonomyMu.Lock()
+	defer taxonomyMu.Unlock()
+	activeTaxonomy = t
+}
+
+// CurrentTaxonomy returns the installed taxonomy
+func CurrentTaxonomy() *Taxonomy {
+	taxonomyMu.RLock()
+	defer taxonomyMu.RUnlock()
+	return activeTaxonomy
+}
+
+// Slugs lists the category slugs, in order
+func (t *Taxonomy) Slugs() []string {
+	slugs := make([]string, len(t.Categories))
+	for i, c := range t.Categories {
+		slugs[i] = c.Slug
+	}
+	return slugs
+}
+
+// Select returns the categories with the given slugs, in taxonomy order;
+// all of them when slugs is empty
+func (t *Taxonomy) Select(slugs []string) []Category {
+	if len(slugs) == 0 {
+		return t.Categories
+	}
+	wanted := make(map[string]bool, len(slugs))
+	for _, slug := range slugs {
+		wanted[slugify(slug)] = true
+	}
+	var categories []Category
+	for _, c := range t.Categories {
+		if wanted[c.Slug] {
+			categories = append(categories, c)
+		}
+	}
+	return categories
+}
+
+// Resolve returns the slug of the category a slug, name or alias refers to
+func (t *Taxonomy) Resolve(value string) (string, bool) {
+	c, ok := t.lookup(value)
+	return c.Slug, ok
+}
+
+// lookup finds the category a slug, name or alias refers to
+func (t *Taxonomy) lookup(value string) (Category, bool) {
+	key := slugify(value)
+	for _, c := range t.Categories {
+		if key == c.Slug || key == slugify(c.Name) {
+			return c, true
+		}
+		for _, alias := range c.Aliases {
+			if key == slugify(alias) {
+				return c, true
+			}
+		}
+	}
+	return Category{}, false
+}
+
+// catchAll is the category findings that fit nowhere else are reported in,
+// such as suspected prompt-injection attempts
+func (t *Taxonomy) catchAll() string {
+	if t.Default != "" {
+		return t.Default
+	}
+	if c, ok := t.lookup("possible-issue"); ok {
+		return c.Slug
+	}
+	return t.Categories[len(t.Categories)-1].Slug
+}
+
+// Normalize rewrites the category of each diagnostic to its taxonomy slug,
+// and applies the category's severity and documentation link
+func (t *Taxonomy) Normalize(diagnostics []models.Diagnostic) {
+	for i := range diagnostics {
+		d := &diagnostics[i]
+		if slugify(d.Code.Value) == SecurityCategory {
+			d.Code.Value = SecurityCategory
+			continue
+		}
+		c, ok := t.lookup(d.Code.Value)
+		if !ok {
+			c, ok = t.lookup(t.catchAll())
+		}
+		if !ok {
+			continue
+		}
+		d.Code.Value = c.Slug
+		if c.Severity != "" {
+			d.Severity = c.Severity
+		}
+		if d.Code.URL == "" {
+			d.Code.URL = c.URL
+		}
+	}
+}
+
+// slugify lower-cases a category and joins its words with hyphens, so
+// "Possible Bug", "possible_bug" and "possible-bug" compare equal
+func slugify(value string) string {
+	fields := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return r == ' ' || r == '_' || r == '-' || r == '\t'
+	})
+	return strings.Join(fields, "-")
+}
+
+// writeCategoryList lists the categories as numbered Markdown items
+func writeCategoryList(builder *strings.Builder, categories []Category) {
+	for i, c := range categories {
+		builder.WriteString(fmt.Sprintf("%d. **%s** - %s", i+1, c.Name, c.Description))
+		if c.Severity != "" {
+			builder.WriteString(fmt.Sprintf(" (always %s)", c.Severity))
+		}
+		builder.WriteString("\n")
+	}
+}
@@ -0,0 +1,156 @@
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// Category describes a review category offered to the model
+type Category struct {
+	Slug        string   `yaml:"slug"`
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Severity    string   `yaml:"severity"` // Severity findings are reported with; empty keeps the model's
+	Aliases     []string `yaml:"aliases"`  // Other names the model may use for the category
+	URL         string   `yaml:"url"`      // Team documentation of the category, linked from its findings
+}
+
+// DefaultCategories are the six built-in review categories
+var DefaultCategories = []Category{
+	{Slug: "possible-bug", Name: "Possible Bug", Description: "Logic errors, null pointer risks, off-by-one errors, race conditions, edge cases not handled"},
+	{Slug: "best-practice", Name: "Best Practice", Description: "Coding standards violations, naming conventions, code organization, design patterns misuse"},
+	{Slug: "performance", Name: "Performance", Description: "Inefficient algorithms, unnecessary loops, memory leaks, N+1 queries, blocking operations"},
+	{Slug: "maintainability", Name: "Maintainability", Description: "Code complexity, lack of documentation, unclear variable names, hard-coded values, tight coupling"},
+	{Slug: "possible-issue", Name: "Possible Issue", Description: "Code smells, anti-patterns, deprecated API usage, potential future problems"},
+	{Slug: "enhancement", Name: "Enhancement", Description: "Optimization opportunities, better approaches, missing features, code improvements"},
+}
+
+// TemplateData is the data available to custom prompt templates
+type TemplateData struct {
+	Language   string
+	Mode       string
+	Categories []Category
+	Guidelines string
+	Diff       string
+	GitInfo    *models.GitInfo
+	Files      []models.FileContent     // Full content of changed files, when available
+	Related    []models.ContextSnippet  // Snippets retrieved from the repository index
+	Rejected   []models.FeedbackExample // Findings developers marked as false positives, reasons sanitized
+}
+
+// Templates holds custom system and user prompt templates loaded from disk.
+// Templates are looked up by mode first ("system.security.tmpl") and then
+// by kind ("system.tmpl").
+type Templates struct {
+	set        *template.Template
+	guidelines string
+}
+
+var (
+	templatesMu     sync.RWMutex
+	activeTemplates *Templates
+)
+
+// LoadTemplates parses all *.tmpl files in dir. A guidelines.md file in the
+// same directory is exposed to templates as {{.Guidelines}}.
+func LoadTemplates(dir string) (*Templates, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no *.tmpl files found in %s", dir)
+	}
+
+	set, err := template.New("prompts").Funcs(template.FuncMap{
+		"join":  strings.Join,
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}).ParseFiles(paths...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse prompt templates: %w", err)
+	}
+
+	t := &Templates{set: set}
+
+	guidelines, err := os.ReadFile(filepath.Join(dir, "guidelines.md"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read guidelines: %w", err)
+	}
+	t.guidelines = string(guidelines)
+
+	return t, nil
+}
+
+// SetTemplates installs custom templates; nil restores the built-in prompts
+func SetTemplates(t *Templates) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	activeTemplates = t
+}
+
+// currentTemplates returns the installed custom templates, if any
+func currentTemplates() *Templates {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+	return activeTemplates
+}
+
+// render executes the most specific template for the kind and mode.
+// It returns false when no custom template applies or execution fails, in
+// which case callers fall back to the built-in prompt.
+func (t *Templates) render(kind string, data TemplateData) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+
+	for _, name := range []string{kind + "." + data.Mode + ".tmpl", kind + ".tmpl"} {
+		tmpl := t.set.Lookup(name)
+		if tmpl == nil {
+			continue
+		}
+
+		var builder strings.Builder
+		if err := tmpl.Execute(&builder, data); err != nil {
+			log.Printf("Error executing prompt template %s: %v", name, err)
+			return "", false
+		}
+		return builder.String(), true
+	}
+
+	return "", false
+}
+
+// templateData builds the template data for a language, mode, requested
+// categories and request
+func (t *Templates) templateData(language, mode string, categories []string, request *models.ReviewRequest) TemplateData {
+	data := TemplateData{
+		Language:   language,
+		Mode:       NormalizeMode(mode),
+		Categories: CurrentTaxonomy().Select(categories),
+		Guidelines: t.guidelines,
+	}
+	if request != nil {
+		data.Diff = request.GitDiff
+		data.GitInfo = request.GitInfo
+		data.Files = request.FileContents
+		data.Related = request.RelatedContext
+		data.Rejected = sanitizeRejected(request.RejectedFindings)
+		if request.RepoConfig != nil && request.RepoConfig.Guidelines != "" {
+			data.Guidelines = strings.TrimSpace(data.Guidelines + "\n\n" + request.RepoConfig.Guidelines)
+		}
+		for _, guide := range request.StyleGuides {
+			data.Guidelines = strings.TrimSpace(data.Guidelines + "\n\n" + guide.Content)
+		}
+	}
+	return data
+}
@@ -0,0 +1,43 @@
+package prompt
+
+import (
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// checkIssue validates a parsed issue against the response schema before
+// its category is normalized. Issues without a file, a positive line or a
+// message are rejected. An unknown severity becomes INFO, an unknown
+// category is left for the taxonomy to refile, a column before the start
+// of the line or an end before the start is reset, and a fix with an
+// impossible line range is dropped; each counts as a repair. It returns
+// whether the issue is kept and whether it was repaired.
+func checkIssue(d *models.Diagnostic, taxonomy *Taxonomy) (kept, repaired bool) {
+	d.Message = strings.TrimSpace(d.Message)
+	d.Location.Path = strings.TrimSpace(d.Location.Path)
+	if d.Location.Path == "" || d.Location.Range.Start.Line <= 0 || d.Message == "" {
+		return false, false
+	}
+
+	severity, known := normalizeSeverity(d.Severity)
+	d.Severity = severity
+	repaired = !known
+	if _, ok := taxonomy.Resolve(d.Code.Value); !ok && slugify(d.Code.Value) != SecurityCategory {
+		repaired = true
+	}
+	if start := d.Location.Range.Start; start.Column < 1 {
+		d.Location.Range.Start.Column = 1
+		repaired = true
+	}
+	if start, end := d.Location.Range.Start, d.Location.Range.End; end.Line < start.Line || end.Column < 0 ||
+		(end.Line == start.Line && end.Column <= start.Column) {
+		d.Location.Range.End = models.Position{Line: start.Line, Column: start.Column + 1}
+		repaired = true
+	}
+	if fix := d.Fix; fix != nil && (fix.StartLine <= 0 || fix.EndLine < fix.StartLine) {
+		d.Fix = nil
+		repaired = true
+	}
+	return true, repaired
+}
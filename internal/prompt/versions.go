@@ -0,0 +1,204 @@
+package prompt
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultVersion names the prompts used when a request is not assigned a
+// registered version: the custom templates of PROMPT_TEMPLATE_DIR, or the
+// built-in prompts
+const DefaultVersion = "default"
+
+// Arm is one prompt version of an experiment and its share of traffic
+type Arm struct {
+	Version string
+	Weight  int
+}
+
+// Experiment splits reviews between prompt versions by weight
+type Experiment struct {
+	Arms []Arm
+}
+
+// Registry holds the versioned prompt templates and the running experiment
+type Registry struct {
+	versions   map[string]*Templates
+	experiment *Experiment
+}
+
+var (
+	registryMu     sync.RWMutex
+	activeRegistry *Registry
+)
+
+// LoadVersions loads every subdirectory of dir holding *.tmpl files as a
+// prompt version named after the subdirectory
+func LoadVersions(dir string) (map[string]*Templates, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompt versions: %w", err)
+	}
+
+	versions := make(map[string]*Templates)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if name == DefaultVersion {
+			return nil, fmt.Errorf("prompt version %q is reserved for the default prompts", name)
+		}
+		t, err := LoadTemplates(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("prompt version %s: %w", name, err)
+		}
+		versions[name] = t
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no prompt versions found in %s", dir)
+	}
+	return versions, nil
+}
+
+// ParseExperiment parses a comma-separated list of version=weight pairs,
+// e.g. "default=50,v2=50". Every version must be registered or default.
+// An empty spec returns nil, which assigns every review the default.
+func ParseExperiment(spec string, versions map[string]*Templates) (*Experiment, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	experiment := &Experiment{}
+	seen := make(map[string]bool)
+	total := 0
+	for _, part := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid experiment arm %q: expected version=weight", part)
+		}
+		weight, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("invalid weight %q for prompt version %s", value, name)
+		}
+		if _, ok := versions[name]; !ok && name != DefaultVersion {
+			return nil, fmt.Errorf("unknown prompt version %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("prompt version %s listed twice", name)
+		}
+		seen[name] = true
+		total += weight
+		experiment.Arms = append(experiment.Arms, Arm{Version: name, Weight: weight})
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("experiment weights must not all be zero")
+	}
+	return experiment, nil
+}
+
+// SetVersions installs the prompt versions and experiment; nil versions
+// remove the registry, leaving only the default prompts
+func SetVersions(versions map[string]*Templates, experiment *Experiment) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if len(versions) == 0 {
+		activeRegistry = nil
+		return
+	}
+	activeRegistry = &Registry{versions: versions, experiment: experiment}
+}
+
+// currentRegistry returns the installed prompt versions, if any
+func currentRegistry() *Registry {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return activeRegistry
+}
+
+// Versions returns the names of the registered prompt versions, default
+// first, or nil when none are registered
+func Versions() []string {
+	registry := currentRegistry()
+	if registry == nil {
+		return nil
+	}
+	names := make([]string, 0, len(registry.versions)+1)
+	for name := range registry.versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{DefaultVersion}, names...)
+}
+
+// HasVersion reports whether a request may ask for a prompt version
+func HasVersion(version string) bool {
+	registry := currentRegistry()
+	if registry == nil {
+		return false
+	}
+	_, ok := registry.versions[version]
+	return ok || version == DefaultVersion
+}
+
+// ExperimentWeights returns the traffic share of each version in the
+// running experiment, or nil when none is running
+func ExperimentWeights() map[string]int {
+	registry := currentRegistry()
+	if registry == nil || registry.experiment == nil {
+		return nil
+	}
+	weights := make(map[string]int, len(registry.experiment.Arms))
+	for _, arm := range registry.experiment.Arms {
+		weights[arm.Version] = arm.Weight
+	}
+	return weights
+}
+
+// AssignVersion picks the prompt version of a review. The same key always
+// gets the same version, so keying on the pull request keeps its reviews
+// comparable. It returns an empty string when no versions are registered.
+func AssignVersion(key string) string {
+	registry := currentRegistry()
+	if registry == nil {
+		return ""
+	}
+	if registry.experiment == nil {
+		return DefaultVersion
+	}
+
+	total := 0
+	for _, arm := range registry.experiment.Arms {
+		total += arm.Weight
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	point := int(hash.Sum32() % uint32(total))
+	for _, arm := range registry.experiment.Arms {
+		if point < arm.Weight {
+			return arm.Version
+		}
+		point -= arm.Weight
+	}
+	return DefaultVersion
+}
+
+// templatesFor returns the templates of a prompt version; the default
+// version uses the custom templates, if any
+func templatesFor(version string) *Templates {
+	if version != "" && version != DefaultVersion {
+		if registry := currentRegistry(); registry != nil {
+			if t, ok := registry.versions[version]; ok {
+				return t
+			}
+		}
+	}
+	return currentTemplates()
+}
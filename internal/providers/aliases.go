@@ -0,0 +1,218 @@
+package providers
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Alias maps a short model name such as "fast" to a provider and model
+type Alias struct {
+	Provider string `json:"provider,omitempty"` // Empty selects the provider that lists the model
+	Model    string `json:"model"`
+}
+
+var aliasName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// ParseAliases parses "name=provider:model" or "name=model" entries
+// separated by commas
+func ParseAliases(value string) (map[string]Alias, error) {
+	aliases := make(map[string]Alias)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, target, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(target) == "" {
+			return nil, fmt.Errorf("invalid model alias %q: expected name=provider:model", entry)
+		}
+		alias := Alias{Model: strings.TrimSpace(target)}
+		if provider, model, ok := strings.Cut(alias.Model, ":"); ok {
+			alias = Alias{Provider: strings.TrimSpace(provider), Model: strings.TrimSpace(model)}
+		}
+		aliases[strings.TrimSpace(name)] = alias
+	}
+	return aliases, nil
+}
+
+// SetDefaults sets the provider used when a request names none, and that
+// provider's model when the request names no model
+func (r *Registry) SetDefaults(provider, model string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaultProvider = provider
+	r.defaultModel = model
+}
+
+// Defaults returns the default provider and its default model override
+func (r *Registry) Defaults() (string, string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.defaultProvider, r.defaultModel
+}
+
+// CheckModel returns an error listing the valid options if model isn't
+// supported by the provider
+func (r *Registry) CheckModel(provider, model string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.checkModelLocked(provider, model)
+}
+
+// AllowUnlistedModels lets requests name models a provider doesn't list
+// in SupportedModels, e.g. models released after the gateway
+func (r *Registry) AllowUnlistedModels(allow bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.allowUnlisted = allow
+}
+
+// SetAlias adds or replaces a model alias. The target must be a model of a
+// registered provider.
+func (r *Registry) SetAlias(name string, alias Alias) error {
+	if !aliasName.MatchString(name) {
+		return fmt.Errorf("invalid alias name %q: use lower-case letters, digits, '.', '_' and '-'", name)
+	}
+	if alias.Model == "" {
+		return fmt.Errorf("alias %s has no model", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for providerName, provider := range r.providers {
+		if slices.Contains(provider.SupportedModels(), name) {
+			return fmt.Errorf("alias %s shadows a model of provider %s", name, providerName)
+		}
+	}
+	if alias.Provider == "" {
+		alias.Provider = r.providerForModelLocked(alias.Model)
+		if alias.Provider == "" {
+			return fmt.Errorf("alias %s: no registered provider supports model %s", name, alias.Model)
+		}
+	}
+	if err := r.checkModelLocked(alias.Provider, alias.Model); err != nil {
+		return fmt.Errorf("alias %s: %w", name, err)
+	}
+
+	if r.aliases == nil {
+		r.aliases = make(map[string]Alias)
+	}
+	r.aliases[name] = alias
+	return nil
+}
+
+// DeleteAlias removes a model alias and reports whether it existed
+func (r *Registry) DeleteAlias(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.aliases[name]
+	delete(r.aliases, name)
+	return ok
+}
+
+// Aliases returns a copy of the configured model aliases
+func (r *Registry) Aliases() map[string]Alias {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	aliases := make(map[string]Alias, len(r.aliases))
+	for name, alias := range r.aliases {
+		aliases[name] = alias
+	}
+	return aliases
+}
+
+// Resolve maps a requested provider and model, either of which may be
+// empty and the model of which may be an alias, to a registered provider
+// and one of its supported models. Errors describe the valid options and
+// are meant to be shown to the caller.
+func (r *Registry) Resolve(provider, model string) (string, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if alias, ok := r.aliases[model]; ok {
+		if provider != "" && provider != alias.Provider {
+			return "", "", fmt.Errorf("model alias %s belongs to provider %s, not %s", model, alias.Provider, provider)
+		}
+		provider, model = alias.Provider, alias.Model
+	}
+
+	if provider == "" {
+		provider = r.defaultProvider
+	}
+	p, ok := r.providers[provider]
+	if !ok {
+		return "", "", fmt.Errorf("provider '%s' not found; available providers: %s", provider, strings.Join(r.namesLocked(), ", "))
+	}
+
+	if model == "" {
+		model = p.DefaultModel()
+		if provider == r.defaultProvider && r.defaultModel != "" {
+			model = r.defaultModel
+		}
+	}
+	if err := r.checkModelLocked(provider, model); err != nil {
+		return "", "", err
+	}
+	return provider, model, nil
+}
+
+// checkModelLocked returns an error listing the valid options if model
+// isn't supported by the provider
+func (r *Registry) checkModelLocked(provider, model string) error {
+	p, ok := r.providers[provider]
+	if !ok {
+		return fmt.Errorf("provider '%s' not found", provider)
+	}
+	// Ensemble members each use their own model
+	if _, ok := p.(*EnsembleProvider); ok || model == "" || r.allowUnlisted {
+		return nil
+	}
+
+	supported := p.SupportedModels()
+	if slices.Contains(supported, model) {
+		return nil
+	}
+
+	message := fmt.Sprintf("model %q is not supported by provider %s; valid models: %s", model, provider, strings.Join(supported, ", "))
+	var aliases []string
+	for name, alias := range r.aliases {
+		if alias.Provider == provider {
+			aliases = append(aliases, name)
+		}
+	}
+	if len(aliases) > 0 {
+		sort.Strings(aliases)
+		message += fmt.Sprintf(" (aliases: %s)", strings.Join(aliases, ", "))
+	}
+	return fmt.Errorf("%s", message)
+}
+
+// providerForModelLocked returns the registered provider that lists
+// model, preferring the default provider
+func (r *Registry) providerForModelLocked(model string) string {
+	if p, ok := r.providers[r.defaultProvider]; ok && slices.Contains(p.SupportedModels(), model) {
+		return r.defaultProvider
+	}
+	for _, name := range r.namesLocked() {
+		if slices.Contains(r.providers[name].SupportedModels(), model) {
+			return name
+		}
+	}
+	return ""
+}
+
+// namesLocked returns the registered provider names, sorted
+func (r *Registry) namesLocked() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,31 @@
+package providers
+
+import "context"
+
+type batchKey struct{}
+
+// WithBatch lets calls made with ctx go through a provider's batch API,
+// where one is enabled: they are answered within a day at a discount, and
+// aren't streamed
+func WithBatch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, batchKey{}, true)
+}
+
+// BatchRequested reports whether calls made with ctx may be batched
+func BatchRequested(ctx context.Context) bool {
+	batch, _ := ctx.Value(batchKey{}).(bool)
+	return batch
+}
+
+// batchSupporter is implemented by providers that can send calls through a
+// batch API
+type batchSupporter interface {
+	SupportsBatch() bool
+}
+
+// SupportsBatch reports whether provider sends calls made WithBatch through
+// a batch API
+func SupportsBatch(provider AIProvider) bool {
+	s, ok := provider.(batchSupporter)
+	return ok && s.SupportsBatch()
+}
@@ -0,0 +1,14 @@
+package providers
+
+// contextCacher is implemented by providers that can cache the shared
+// context of completion requests
+type contextCacher interface {
+	CachesContext() bool
+}
+
+// CachesContext reports whether provider caches the SharedContext of
+// completion requests, which are otherwise sent whole in UserPrompt
+func CachesContext(provider AIProvider) bool {
+	c, ok := provider.(contextCacher)
+	return ok && c.CachesContext()
+}
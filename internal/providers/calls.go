@@ -0,0 +1,16 @@
+package providers
+
+// concurrentReviewer is implemented by providers whose reviews make
+// several provider calls at once
+type concurrentReviewer interface {
+	ReviewCalls() int
+}
+
+// ReviewCalls returns the number of provider calls a review by provider
+// makes at once, which is the number of scheduler slots it should hold
+func ReviewCalls(provider AIProvider) int {
+	if c, ok := provider.(concurrentReviewer); ok {
+		return max(c.ReviewCalls(), 1)
+	}
+	return 1
+}
@@ -0,0 +1,92 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// healthCheckTimeout bounds one provider's health check
+const healthCheckTimeout = 15 * time.Second
+
+// healthChecker is implemented by providers that can verify their keys
+// with a lightweight call, such as listing models
+type healthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// CheckHealth runs the health check of every registered provider that has
+// one, marking those that fail unhealthy until a check or request succeeds
+func (r *Registry) CheckHealth(ctx context.Context) {
+	r.mu.RLock()
+	checkers := make(map[string]healthChecker)
+	for name, provider := range r.providers {
+		if c, ok := provider.(healthChecker); ok {
+			checkers[name] = c
+		}
+	}
+	r.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for name, checker := range checkers {
+		wg.Add(1)
+		go func(name string, checker healthChecker) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+			err := checker.CheckHealth(checkCtx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Printf("Warning: provider %s failed its health check: %v", name, err)
+			}
+			r.health.check(name, err)
+		}(name, checker)
+	}
+	wg.Wait()
+}
+
+// WatchHealth checks the providers every interval until ctx is done
+func (r *Registry) WatchHealth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.CheckHealth(ctx)
+		}
+	}
+}
+
+// checkInconclusive reports whether a failed health check says nothing
+// about the key: it was rate limited, or the server has no models
+// endpoint, as some OpenAI-compatible servers don't
+func checkInconclusive(err error) bool {
+	return isRateLimited(err) || hasStatus(err, http.StatusNotFound)
+}
+
+// hasStatus reports whether err is an upstream response with an HTTP status
+func hasStatus(err error, status int) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode == status
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.HTTPStatusCode == status
+	}
+	return strings.Contains(err.Error(), fmt.Sprintf("status %d", status))
+}
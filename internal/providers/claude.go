@@ -1,28 +1,36 @@
 package providers
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
-	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
 )
 
 // ClaudeProvider implements the AIProvider interface for Anthropic Claude
 type ClaudeProvider struct {
 	apiKey     string
+	baseURL    string
 	httpClient *http.Client
+	noStream   bool // Never stream, for compatible servers that can't
 }
 
-// NewClaudeProvider creates a new Claude provider
-func NewClaudeProvider(apiKey string) *ClaudeProvider {
+// NewClaudeProvider creates a new Claude provider. An empty baseURL uses
+// https://api.anthropic.com.
+func NewClaudeProvider(apiKey, baseURL string) *ClaudeProvider {
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com"
+	}
 	return &ClaudeProvider{
 		apiKey:     apiKey,
-		httpClient: &http.Client{},
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: newHTTPClient(),
 	}
 }
 
@@ -41,13 +49,20 @@ func (p *ClaudeProvider) SupportedModels() []string {
 	}
 }
 
+// DefaultModel returns the model used when a request doesn't name one
+func (p *ClaudeProvider) DefaultModel() string {
+	return "claude-3-5-sonnet-20241022"
+}
+
 // ClaudeRequest represents the request structure for Claude API
 type ClaudeRequest struct {
 	Model       string          `json:"model"`
 	MaxTokens   int             `json:"max_tokens"`
 	Messages    []ClaudeMessage `json:"messages"`
 	System      string          `json:"system,omitempty"`
-	Temperature float64         `json:"temperature,omitempty"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	TopP        *float64        `json:"top_p,omitempty"`
+	Stream      bool            `json:"stream,omitempty"`
 }
 
 // ClaudeMessage represents a message in Claude API
@@ -61,45 +76,117 @@ type ClaudeResponse struct {
 	Content []struct {
 		Text string `json:"text"`
 	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// claudeStreamEvent is one server-sent event of a streamed Claude response
+type claudeStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
 	Error *struct {
 		Type    string `json:"type"`
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
 }
 
+// CheckParams enforces Anthropic's narrower temperature range
+func (p *ClaudeProvider) CheckParams(model string, params models.ModelParams) error {
+	if params.Temperature != nil && *params.Temperature > 1 {
+		return fmt.Errorf("temperature must be between 0 and 1 for Anthropic models")
+	}
+	if params.ReasoningEffort != "" {
+		return fmt.Errorf("reasoning_effort is not supported by Anthropic models")
+	}
+	return nil
+}
+
+// CheckHealth verifies the API key by listing models
+func (p *ClaudeProvider) CheckHealth(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/v1/models?limit=1", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to list models: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("failed to list models: status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
 // Review performs a code review using Claude
 func (p *ClaudeProvider) Review(ctx context.Context, request *models.ReviewRequest) (*models.AIProviderResponse, error) {
+	return review(ctx, p, request, 4096)
+}
+
+// Complete sends a raw prompt to Claude and returns the response text
+func (p *ClaudeProvider) Complete(ctx context.Context, request *CompletionRequest) (*CompletionResponse, error) {
 	// Get model, default to claude-3-5-sonnet if not specified
-	modelName := request.AIModel
+	modelName := request.Model
 	if modelName == "" {
-		modelName = "claude-3-5-sonnet-20241022"
+		modelName = p.DefaultModel()
 	}
 
-	// Generate prompts
-	systemPrompt := prompt.GenerateSystemPrompt(request.Language)
-	userPrompt := prompt.GenerateUserPrompt(request)
+	maxTokens := request.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = 4096
+	}
 
 	// Create request
+	temperature := float64(request.Temperature)
 	reqBody := ClaudeRequest{
 		Model:       modelName,
-		MaxTokens:   4096,
-		Temperature: 0.3,
-		System:      systemPrompt,
+		MaxTokens:   maxTokens,
+		Temperature: &temperature,
+		System:      request.SystemPrompt,
 		Messages: []ClaudeMessage{
 			{
 				Role:    "user",
-				Content: userPrompt,
+				Content: request.UserPrompt,
 			},
 		},
 	}
 
+	if request.TopP != nil {
+		topP := float64(*request.TopP)
+		reqBody.TopP = &topP
+	}
+	reqBody.Stream = request.Stream != nil && !p.noStream
+
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Create HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/v1/messages", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -115,6 +202,10 @@ func (p *ClaudeProvider) Review(ctx context.Context, request *models.ReviewReque
 	}
 	defer resp.Body.Close()
 
+	if reqBody.Stream && resp.StatusCode == http.StatusOK {
+		return readClaudeStream(resp.Body, request.Stream)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
@@ -138,8 +229,61 @@ func (p *ClaudeProvider) Review(ctx context.Context, request *models.ReviewReque
 		return nil, fmt.Errorf("no content in response")
 	}
 
-	responseText := claudeResp.Content[0].Text
+	return &CompletionResponse{
+		Text:             claudeResp.Content[0].Text,
+		PromptTokens:     claudeResp.Usage.InputTokens,
+		CompletionTokens: claudeResp.Usage.OutputTokens,
+		Truncated:        claudeResp.StopReason == "max_tokens",
+	}, nil
+}
+
+// readClaudeStream reads a streamed Claude response, passing the text
+// received so far to onText as it arrives
+func readClaudeStream(body io.Reader, onText func(string)) (*CompletionResponse, error) {
+	response := &CompletionResponse{}
+	var text strings.Builder
+	stopped := false
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var event claudeStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stream event: %w", err)
+		}
+		switch event.Type {
+		case "message_start":
+			response.PromptTokens = event.Message.Usage.InputTokens
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				text.WriteString(event.Delta.Text)
+				onText(text.String())
+			}
+		case "message_delta":
+			response.CompletionTokens = event.Usage.OutputTokens
+			response.Truncated = event.Delta.StopReason == "max_tokens"
+		case "message_stop":
+			stopped = true
+		case "error":
+			if event.Error != nil {
+				return nil, fmt.Errorf("Claude API error: %s", event.Error.Message)
+			}
+			return nil, fmt.Errorf("Claude API error")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read response stream: %w", err)
+	}
+	if !stopped {
+		return nil, fmt.Errorf("response stream ended before the message was complete")
+	}
 
-	// Parse the response
-	return prompt.ParseAIResponse(responseText)
+	if text.Len() == 0 {
+		return nil, fmt.Errorf("no content in response")
+	}
+	response.Text = text.String()
+	return response, nil
 }
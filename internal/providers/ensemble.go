@@ -0,0 +1,171 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/postprocess"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
+)
+
+// EnsembleMember is one provider/model pair in an ensemble
+type EnsembleMember struct {
+	Name     string // Registry name, e.g. google
+	Model    string // Empty selects the provider's default model
+	Provider AIProvider
+}
+
+// EnsembleProvider fans a review out to several providers concurrently and
+// merges their findings, annotating each with the providers that agreed
+type EnsembleProvider struct {
+	members []EnsembleMember
+}
+
+// NewEnsembleProvider creates a new ensemble provider
+func NewEnsembleProvider(members []EnsembleMember) *EnsembleProvider {
+	return &EnsembleProvider{
+		members: members,
+	}
+}
+
+// ParseEnsembleMembers parses a list of "provider" or "provider:model"
+// entries, resolving providers from the registry
+func ParseEnsembleMembers(entries []string, registry *Registry) ([]EnsembleMember, error) {
+	members := make([]EnsembleMember, 0, len(entries))
+	for _, entry := range entries {
+		name, model, _ := strings.Cut(strings.TrimSpace(entry), ":")
+		provider, err := registry.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, EnsembleMember{Name: name, Model: model, Provider: provider})
+	}
+	return members, nil
+}
+
+// Name returns the provider name
+func (p *EnsembleProvider) Name() string {
+	return "ensemble"
+}
+
+// SupportedModels returns the members of the ensemble
+func (p *EnsembleProvider) SupportedModels() []string {
+	models := make([]string, 0, len(p.members))
+	for _, m := range p.members {
+		models = append(models, m.label())
+	}
+	return models
+}
+
+// DefaultModel returns an empty string; each member uses its own model
+func (p *EnsembleProvider) DefaultModel() string {
+	return ""
+}
+
+// label identifies a member in responses
+func (m EnsembleMember) label() string {
+	if m.Model == "" {
+		return m.Name
+	}
+	return m.Name + ":" + m.Model
+}
+
+// ReviewCalls returns the number of concurrent calls a review makes: one
+// per member
+func (p *EnsembleProvider) ReviewCalls() int {
+	calls := 0
+	for _, m := range p.members {
+		calls += ReviewCalls(m.Provider)
+	}
+	return calls
+}
+
+// Review runs the review on every member and merges the results. Members
+// that fail are logged and left out; the review only fails if all do.
+func (p *EnsembleProvider) Review(ctx context.Context, request *models.ReviewRequest) (*models.AIProviderResponse, error) {
+	type result struct {
+		response *models.AIProviderResponse
+		err      error
+	}
+
+	results := make([]result, len(p.members))
+	var wg sync.WaitGroup
+	for i, member := range p.members {
+		wg.Add(1)
+		go func(i int, member EnsembleMember) {
+			defer wg.Done()
+			memberRequest := *request
+			memberRequest.AIProvider = member.Name
+			memberRequest.AIModel = member.Model
+			response, err := member.Provider.Review(ctx, &memberRequest)
+			results[i] = result{response: response, err: err}
+		}(i, member)
+	}
+	wg.Wait()
+
+	var sourced []postprocess.SourcedDiagnostics
+	var overviews []string
+	var errs []string
+	merged := &models.AIProviderResponse{ParserPath: prompt.ParserStructured}
+	for i, r := range results {
+		label := p.members[i].label()
+		if r.err != nil {
+			log.Printf("Ensemble member %s failed: %v", label, r.err)
+			errs = append(errs, fmt.Sprintf("%s: %v", label, r.err))
+			continue
+		}
+
+		sourced = append(sourced, postprocess.SourcedDiagnostics{Source: label, Diagnostics: r.response.Diagnostics})
+		if r.response.Overview != "" {
+			overviews = append(overviews, fmt.Sprintf("[%s] %s", label, r.response.Overview))
+		}
+		if merged.CommitMessage == "" {
+			merged.CommitMessage = r.response.CommitMessage
+		}
+		switch r.response.ParserPath {
+		case prompt.ParserPartial:
+			merged.ParserPath = prompt.ParserPartial
+		case prompt.ParserUnstructured:
+			if merged.ParserPath != prompt.ParserPartial {
+				merged.ParserPath = prompt.ParserUnstructured
+			}
+		}
+		merged.Usage.PromptBytes += r.response.Usage.PromptBytes
+		merged.Usage.ResponseBytes += r.response.Usage.ResponseBytes
+		merged.Usage.PromptTokens += r.response.Usage.PromptTokens
+		merged.Usage.CompletionTokens += r.response.Usage.CompletionTokens
+		merged.Usage.Truncated = merged.Usage.Truncated || r.response.Usage.Truncated
+		merged.Schema = merged.Schema.Add(r.response.Schema)
+	}
+
+	if len(sourced) == 0 {
+		return nil, fmt.Errorf("all ensemble members failed: %s", strings.Join(errs, "; "))
+	}
+
+	merged.Overview = strings.Join(overviews, "\n\n")
+	merged.Diagnostics = postprocess.Merge(sourced)
+	return merged, nil
+}
+
+// Complete sends the prompt to each member in turn and returns the first
+// successful response
+func (p *EnsembleProvider) Complete(ctx context.Context, request *CompletionRequest) (*CompletionResponse, error) {
+	var lastErr error
+	for _, member := range p.members {
+		memberRequest := *request
+		memberRequest.Model = member.Model
+		response, err := member.Provider.Complete(ctx, &memberRequest)
+		if err == nil {
+			return response, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, fmt.Errorf("all ensemble members failed: %w", lastErr)
+}
@@ -0,0 +1,59 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+type extraFieldsKey struct{}
+
+// withExtraFields asks extraFieldsDoer to add fields to the JSON body of
+// requests made with ctx
+func withExtraFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	return context.WithValue(ctx, extraFieldsKey{}, fields)
+}
+
+// extraFieldsDoer adds request body fields that go-openai's request types
+// don't have yet, such as reasoning_effort
+type extraFieldsDoer struct {
+	doer openai.HTTPDoer
+}
+
+// Do merges the context's extra fields into the JSON body and sends it
+func (d *extraFieldsDoer) Do(req *http.Request) (*http.Response, error) {
+	fields, _ := req.Context().Value(extraFieldsKey{}).(map[string]interface{})
+	if len(fields) == 0 || req.Body == nil {
+		return d.doer.Do(req)
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("failed to add request fields: %w", err)
+	}
+	for name, value := range fields {
+		if body[name], err = json.Marshal(value); err != nil {
+			return nil, fmt.Errorf("failed to add request field %s: %w", name, err)
+		}
+	}
+	if data, err = json.Marshal(body); err != nil {
+		return nil, fmt.Errorf("failed to add request fields: %w", err)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return d.doer.Do(req)
+}
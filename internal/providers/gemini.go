@@ -2,30 +2,144 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/google/generative-ai-go/genai"
 	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
-	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// Gemini transports
+const (
+	GeminiTransportSDK  = "sdk"  // generative-ai-go SDK only
+	GeminiTransportREST = "rest" // Raw REST API only
+	GeminiTransportAuto = "auto" // SDK, falling back to REST on transport and server errors
+)
+
+// GeminiConfig configures the Gemini provider
+type GeminiConfig struct {
+	APIKey     string
+	Transport  string        // sdk, rest or auto
+	Endpoint   string        // REST endpoint, e.g. https://generativelanguage.googleapis.com
+	APIVersion string        // REST API version, e.g. v1beta
+	CacheTTL   time.Duration // How long shared review context stays cached; zero disables caching
+	HTTPClient *http.Client  // REST client, e.g. one using a proxy; nil uses the shared one
+}
+
 // GeminiProvider implements the AIProvider interface for Google Gemini
 type GeminiProvider struct {
-	client *genai.Client
+	client    *genai.Client
+	rest      *geminiRESTClient
+	cache     *geminiContextCache // nil when context caching is disabled
+	transport string
+	noStream  bool // Never stream
 }
 
 // NewGeminiProvider creates a new Gemini provider
-func NewGeminiProvider(apiKey string) (*GeminiProvider, error) {
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+func NewGeminiProvider(cfg GeminiConfig) (*GeminiProvider, error) {
+	transport := cfg.Transport
+	if transport == "" {
+		transport = GeminiTransportAuto
+	}
+	if (httpTransport != nil || cfg.HTTPClient != nil) && transport != GeminiTransportREST {
+		// Only REST traffic goes through the configured transport or client
+		if transport == GeminiTransportSDK {
+			log.Printf("Warning: Gemini SDK traffic can't be routed through the HTTP transport or proxy; using the REST API")
+		}
+		transport = GeminiTransportREST
+	}
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = newHTTPClient()
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://generativelanguage.googleapis.com"
+	}
+	version := cfg.APIVersion
+	if version == "" {
+		version = "v1beta"
 	}
 
-	return &GeminiProvider{
-		client: client,
-	}, nil
+	provider := &GeminiProvider{
+		transport: transport,
+		rest: &geminiRESTClient{
+			apiKey:     cfg.APIKey,
+			endpoint:   endpoint,
+			version:    version,
+			httpClient: httpClient,
+		},
+	}
+
+	if cfg.CacheTTL > 0 {
+		provider.cache = newGeminiContextCache(provider.rest, cfg.CacheTTL)
+	}
+
+	if transport != GeminiTransportREST {
+		ctx := context.Background()
+		client, err := genai.NewClient(ctx, option.WithAPIKey(cfg.APIKey))
+		if err != nil {
+			if transport == GeminiTransportSDK {
+				return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+			}
+			log.Printf("Warning: Gemini SDK unavailable, using REST API: %v", err)
+		}
+		provider.client = client
+	}
+
+	return provider, nil
+}
+
+// Close deletes the cached context and releases the SDK client, if one
+// was created
+func (p *GeminiProvider) Close() error {
+	if p.cache != nil {
+		p.cache.Close()
+	}
+	if p.client == nil {
+		return nil
+	}
+	return p.client.Close()
+}
+
+// CachesContext reports whether shared context is cached
+func (p *GeminiProvider) CachesContext() bool {
+	return p.cache != nil
+}
+
+// CheckHealth verifies the API key by listing models through the REST API
+func (p *GeminiProvider) CheckHealth(ctx context.Context) error {
+	if err := p.rest.send(ctx, http.MethodGet, "models?pageSize=1", nil, nil); err != nil {
+		return fmt.Errorf("failed to list models: %w", err)
+	}
+	return nil
+}
+
+// CheckParams rejects parameters Gemini models don't take
+func (p *GeminiProvider) CheckParams(model string, params models.ModelParams) error {
+	if params.ReasoningEffort != "" {
+		return fmt.Errorf("reasoning_effort is not supported by Gemini models")
+	}
+	return nil
+}
+
+// geminiTopP returns the request's top_p, or the gateway's default of 0.95
+func geminiTopP(request *CompletionRequest) float32 {
+	if request.TopP != nil {
+		return *request.TopP
+	}
+	return 0.95
 }
 
 // Name returns the provider name
@@ -43,28 +157,100 @@ func (p *GeminiProvider) SupportedModels() []string {
 	}
 }
 
+// DefaultModel returns the model used when a request doesn't name one
+func (p *GeminiProvider) DefaultModel() string {
+	return "gemini-2.0-flash"
+}
+
 // Review performs a code review using Gemini
 func (p *GeminiProvider) Review(ctx context.Context, request *models.ReviewRequest) (*models.AIProviderResponse, error) {
+	return review(ctx, p, request, 8192)
+}
+
+// Complete sends a raw prompt to Gemini and returns the response text
+func (p *GeminiProvider) Complete(ctx context.Context, request *CompletionRequest) (*CompletionResponse, error) {
 	// Get model, default to gemini-2.0-flash if not specified
-	modelName := request.AIModel
+	modelName := request.Model
 	if modelName == "" {
-		modelName = "gemini-2.0-flash"
+		modelName = p.DefaultModel()
 	}
 
+	maxTokens := request.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = 8192
+	}
+
+	if p.noStream && request.Stream != nil {
+		withoutStream := *request
+		withoutStream.Stream = nil
+		request = &withoutStream
+	}
+
+	// Calls with cached context go through the REST API. Without a cache,
+	// the shared context is sent ahead of the prompt.
+	if request.SharedContext != "" {
+		if name, ok := p.cache.lookup(ctx, modelName, request); ok {
+			response, err := p.rest.complete(ctx, modelName, request, maxTokens, name)
+			if err == nil || ctx.Err() != nil {
+				return response, err
+			}
+			log.Printf("Warning: Gemini call with cached context failed, sending the context again: %v", err)
+			p.cache.forget(modelName, request)
+		}
+		withContext := *request
+		withContext.UserPrompt = request.SharedContext + request.UserPrompt
+		withContext.SharedContext = ""
+		request = &withContext
+	}
+
+	if p.client == nil {
+		return p.rest.complete(ctx, modelName, request, maxTokens, "")
+	}
+
+	response, err := p.completeSDK(ctx, modelName, request, maxTokens)
+	if err != nil && p.transport == GeminiTransportAuto && ctx.Err() == nil && sdkUnavailable(err) {
+		log.Printf("Gemini SDK request failed, retrying via REST API: %v", err)
+		return p.rest.complete(ctx, modelName, request, maxTokens, "")
+	}
+	return response, err
+}
+
+// sdkUnavailable reports whether an SDK call failed in a way the REST API
+// might not: the connection failed or the service answered with a server
+// error. Invalid requests, rejected keys and rate limits would fail the
+// same way over REST, so retrying them there only doubles the traffic.
+func sdkUnavailable(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code >= 500
+	}
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Unavailable, codes.Internal, codes.DataLoss:
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// completeSDK sends a prompt through the generative-ai-go SDK
+func (p *GeminiProvider) completeSDK(ctx context.Context, modelName string, request *CompletionRequest, maxTokens int) (*CompletionResponse, error) {
 	model := p.client.GenerativeModel(modelName)
 
 	// Configure model for structured output
-	model.SetTemperature(0.3)
-	model.SetTopP(0.95)
+	model.SetTemperature(request.Temperature)
+	model.SetTopP(geminiTopP(request))
 	model.SetTopK(40)
-	model.SetMaxOutputTokens(8192)
-
-	// Generate prompt
-	systemPrompt := prompt.GenerateSystemPrompt(request.Language)
-	userPrompt := prompt.GenerateUserPrompt(request)
+	model.SetMaxOutputTokens(int32(maxTokens))
 
 	// Create the prompt parts
-	fullPrompt := fmt.Sprintf("%s\n\n%s", systemPrompt, userPrompt)
+	fullPrompt := fmt.Sprintf("%s\n\n%s", request.SystemPrompt, request.UserPrompt)
+
+	if request.Stream != nil {
+		return streamSDK(ctx, model, fullPrompt, request.Stream)
+	}
 
 	// Generate content
 	resp, err := model.GenerateContent(ctx, genai.Text(fullPrompt))
@@ -73,7 +259,7 @@ func (p *GeminiProvider) Review(ctx context.Context, request *models.ReviewReque
 	}
 
 	// Extract text from response
-	if len(resp.Candidates) == 0 {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
 		return nil, fmt.Errorf("no response candidates from Gemini")
 	}
 
@@ -84,6 +270,87 @@ func (p *GeminiProvider) Review(ctx context.Context, request *models.ReviewReque
 		}
 	}
 
-	// Parse the response
-	return prompt.ParseAIResponse(responseText)
+	response := &CompletionResponse{
+		Text:      responseText,
+		Truncated: resp.Candidates[0].FinishReason == genai.FinishReasonMaxTokens,
+	}
+	if resp.UsageMetadata != nil {
+		response.PromptTokens = int(resp.UsageMetadata.PromptTokenCount)
+		response.CompletionTokens = int(resp.UsageMetadata.CandidatesTokenCount)
+	}
+	return response, nil
+}
+
+// streamSDK streams a prompt through the SDK, passing the text received so
+// far to onText as it arrives
+func streamSDK(ctx context.Context, model *genai.GenerativeModel, prompt string, onText func(string)) (*CompletionResponse, error) {
+	iter := model.GenerateContentStream(ctx, genai.Text(prompt))
+	response := &CompletionResponse{}
+	var text strings.Builder
+	finished := false
+	for {
+		resp, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to stream content: %w", err)
+		}
+		if resp.UsageMetadata != nil {
+			response.PromptTokens = int(resp.UsageMetadata.PromptTokenCount)
+			response.CompletionTokens = int(resp.UsageMetadata.CandidatesTokenCount)
+		}
+		if len(resp.Candidates) == 0 {
+			continue
+		}
+		candidate := resp.Candidates[0]
+		if candidate.Content != nil {
+			for _, part := range candidate.Content.Parts {
+				if txt, ok := part.(genai.Text); ok && txt != "" {
+					text.WriteString(string(txt))
+					onText(text.String())
+				}
+			}
+		}
+		if candidate.FinishReason != genai.FinishReasonUnspecified {
+			finished = true
+			response.Truncated = candidate.FinishReason == genai.FinishReasonMaxTokens
+		}
+	}
+
+	if !finished {
+		return nil, fmt.Errorf("response stream ended before the content was finished")
+	}
+	response.Text = text.String()
+	return response, nil
+}
+
+// Embed creates embeddings using the Gemini embedding API
+func (p *GeminiProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	if p.client == nil {
+		return nil, fmt.Errorf("Gemini embeddings require the SDK transport")
+	}
+	if model == "" {
+		model = "text-embedding-004"
+	}
+
+	em := p.client.EmbeddingModel(model)
+	batch := em.NewBatch()
+	for _, text := range texts {
+		batch.AddContent(genai.Text(text))
+	}
+
+	resp, err := em.BatchEmbedContents(ctx, batch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings: %w", err)
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Embeddings))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for i, e := range resp.Embeddings {
+		vectors[i] = e.Values
+	}
+	return vectors, nil
 }
@@ -0,0 +1,177 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// geminiCacheMinTokens is the estimated size below which context isn't
+	// cached: Gemini rejects smaller cached content, and caching it saves
+	// little
+	geminiCacheMinTokens = 4096
+	// geminiCacheMargin is how long before it expires cached content
+	// stops being used, so calls don't reference content that is gone
+	geminiCacheMargin = time.Minute
+)
+
+// cacheStats counts cached content created, reused and failed
+var cacheStats = expvar.NewMap("gemini_context_cache")
+
+// geminiContextCache registers the system prompt and shared context of
+// calls as Gemini cached content, reused by calls with the same model,
+// system prompt and shared context until it expires
+type geminiContextCache struct {
+	rest *geminiRESTClient
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cachedContext
+}
+
+// cachedContext is cached content being created or created
+type cachedContext struct {
+	ready   chan struct{}
+	name    string // e.g. cachedContents/abc; empty when creation failed
+	expires time.Time
+}
+
+func newGeminiContextCache(rest *geminiRESTClient, ttl time.Duration) *geminiContextCache {
+	return &geminiContextCache{rest: rest, ttl: ttl, entries: make(map[string]*cachedContext)}
+}
+
+// lookup returns the name of the cached content holding a request's system
+// prompt and shared context, creating it on first use. It returns false
+// when the context is too small to cache or creating it failed, in which
+// case it isn't tried again until the TTL passes.
+func (c *geminiContextCache) lookup(ctx context.Context, model string, request *CompletionRequest) (string, bool) {
+	if c == nil || (len(request.SystemPrompt)+len(request.SharedContext))/4 < geminiCacheMinTokens {
+		return "", false
+	}
+	key := cacheKey(model, request)
+
+	c.mu.Lock()
+	now := time.Now()
+	for k, e := range c.entries {
+		if !e.expires.IsZero() && now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	e, ok := c.entries[key]
+	if !ok {
+		e = &cachedContext{ready: make(chan struct{})}
+		c.entries[key] = e
+	}
+	c.mu.Unlock()
+
+	if ok {
+		select {
+		case <-e.ready:
+		case <-ctx.Done():
+			return "", false
+		}
+		if e.name != "" {
+			cacheStats.Add("hits", 1)
+		}
+		return e.name, e.name != ""
+	}
+
+	name, expires, err := c.rest.createCachedContent(ctx, model, request.SystemPrompt, request.SharedContext, c.ttl)
+	if err != nil {
+		log.Printf("Warning: failed to cache Gemini context, sending it with each call for %v: %v", c.ttl, err)
+		cacheStats.Add("failed", 1)
+		expires = time.Now().Add(c.ttl)
+	} else {
+		cacheStats.Add("created", 1)
+	}
+	c.mu.Lock()
+	e.name, e.expires = name, expires.Add(-geminiCacheMargin)
+	c.mu.Unlock()
+	close(e.ready)
+	return name, name != ""
+}
+
+// forget drops the cached content of a request, e.g. after a call using
+// it failed
+func (c *geminiContextCache) forget(model string, request *CompletionRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, cacheKey(model, request))
+}
+
+// Close deletes the cached content still alive, so it stops being billed
+func (c *geminiContextCache) Close() {
+	c.mu.Lock()
+	var names []string
+	for _, e := range c.entries {
+		select {
+		case <-e.ready:
+			if e.name != "" {
+				names = append(names, e.name)
+			}
+		default:
+		}
+	}
+	c.entries = make(map[string]*cachedContext)
+	c.mu.Unlock()
+
+	for _, name := range names {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := c.rest.deleteCachedContent(ctx, name); err != nil {
+			log.Printf("Warning: failed to delete Gemini cached content %s: %v", name, err)
+		}
+		cancel()
+	}
+}
+
+// cacheKey identifies the cached content of a model, system prompt and
+// shared context
+func cacheKey(model string, request *CompletionRequest) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + request.SystemPrompt + "\x00" + request.SharedContext))
+	return hex.EncodeToString(sum[:])
+}
+
+// createCachedContent registers a system prompt and shared context as
+// cached content, returning its name and expiry
+func (c *geminiRESTClient) createCachedContent(ctx context.Context, model, systemPrompt, shared string, ttl time.Duration) (string, time.Time, error) {
+	body := map[string]interface{}{
+		"model":    "models/" + model,
+		"contents": []geminiContent{{Role: "user", Parts: []geminiPart{{Text: shared}}}},
+		"ttl":      fmt.Sprintf("%ds", int(ttl.Seconds())),
+	}
+	if systemPrompt != "" {
+		body["systemInstruction"] = geminiContent{Parts: []geminiPart{{Text: systemPrompt}}}
+	}
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var created struct {
+		Name       string    `json:"name"`
+		ExpireTime time.Time `json:"expireTime"`
+	}
+	if err := c.send(ctx, http.MethodPost, "cachedContents", jsonData, &created); err != nil {
+		return "", time.Time{}, err
+	}
+	if created.Name == "" {
+		return "", time.Time{}, fmt.Errorf("no cached content name in the response")
+	}
+	if created.ExpireTime.IsZero() {
+		created.ExpireTime = time.Now().Add(ttl)
+	}
+	return created.Name, created.ExpireTime, nil
+}
+
+// deleteCachedContent deletes cached content by name
+func (c *geminiRESTClient) deleteCachedContent(ctx context.Context, name string) error {
+	return c.send(ctx, http.MethodDelete, name, nil, nil)
+}
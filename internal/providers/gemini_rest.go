@@ -0,0 +1,235 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// geminiRESTClient calls the Gemini generateContent REST API directly,
+// bypassing the generative-ai-go SDK
+type geminiRESTClient struct {
+	apiKey     string
+	endpoint   string
+	version    string
+	httpClient *http.Client
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature     float32 `json:"temperature"`
+	TopP            float32 `json:"topP,omitempty"`
+	TopK            int     `json:"topK,omitempty"`
+	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+}
+
+type geminiRESTRequest struct {
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent        `json:"contents"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+	CachedContent     string                 `json:"cachedContent,omitempty"`
+}
+
+type geminiRESTResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount        int `json:"promptTokenCount"`
+		CandidatesTokenCount    int `json:"candidatesTokenCount"`
+		CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	} `json:"usageMetadata"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error,omitempty"`
+}
+
+// complete sends a generateContent request and returns the response text.
+// With cachedContent, the name of cached content holding the system prompt
+// and shared context, only the user prompt is sent.
+func (c *geminiRESTClient) complete(ctx context.Context, model string, request *CompletionRequest, maxTokens int, cachedContent string) (*CompletionResponse, error) {
+	reqBody := geminiRESTRequest{
+		Contents: []geminiContent{
+			{
+				Role:  "user",
+				Parts: []geminiPart{{Text: request.UserPrompt}},
+			},
+		},
+		GenerationConfig: geminiGenerationConfig{
+			Temperature:     request.Temperature,
+			TopP:            geminiTopP(request),
+			TopK:            40,
+			MaxOutputTokens: maxTokens,
+		},
+	}
+	if cachedContent != "" {
+		reqBody.CachedContent = cachedContent
+	} else if request.SystemPrompt != "" {
+		reqBody.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: request.SystemPrompt}}}
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/models/%s:generateContent",
+		strings.TrimRight(c.endpoint, "/"), c.version, url.PathEscape(model))
+	if request.Stream != nil {
+		endpoint = fmt.Sprintf("%s/%s/models/%s:streamGenerateContent?alt=sse",
+			strings.TrimRight(c.endpoint, "/"), c.version, url.PathEscape(model))
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", c.apiKey)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if request.Stream != nil && resp.StatusCode == http.StatusOK {
+		return readGeminiStream(resp.Body, request.Stream)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var geminiResp geminiRESTResponse
+	if err := json.Unmarshal(body, &geminiResp); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		}
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if geminiResp.Error != nil {
+		return nil, fmt.Errorf("Gemini API error (%s): %s", geminiResp.Error.Status, geminiResp.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	if len(geminiResp.Candidates) == 0 {
+		return nil, fmt.Errorf("no response candidates from Gemini")
+	}
+
+	var builder strings.Builder
+	for _, part := range geminiResp.Candidates[0].Content.Parts {
+		builder.WriteString(part.Text)
+	}
+	return &CompletionResponse{
+		Text:             builder.String(),
+		PromptTokens:     geminiResp.UsageMetadata.PromptTokenCount,
+		CompletionTokens: geminiResp.UsageMetadata.CandidatesTokenCount,
+		CachedTokens:     geminiResp.UsageMetadata.CachedContentTokenCount,
+		Truncated:        geminiResp.Candidates[0].FinishReason == "MAX_TOKENS",
+	}, nil
+}
+
+// readGeminiStream reads a streamGenerateContent response sent as
+// server-sent events, passing the text received so far to onText as it
+// arrives
+func readGeminiStream(body io.Reader, onText func(string)) (*CompletionResponse, error) {
+	response := &CompletionResponse{}
+	var text strings.Builder
+	finished := false
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var chunk geminiRESTResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("Gemini API error (%s): %s", chunk.Error.Status, chunk.Error.Message)
+		}
+		if chunk.UsageMetadata.PromptTokenCount > 0 {
+			response.PromptTokens = chunk.UsageMetadata.PromptTokenCount
+			response.CompletionTokens = chunk.UsageMetadata.CandidatesTokenCount
+			response.CachedTokens = chunk.UsageMetadata.CachedContentTokenCount
+		}
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			if part.Text != "" {
+				text.WriteString(part.Text)
+				onText(text.String())
+			}
+		}
+		if reason := chunk.Candidates[0].FinishReason; reason != "" {
+			finished = true
+			response.Truncated = reason == "MAX_TOKENS"
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read response stream: %w", err)
+	}
+	if !finished {
+		return nil, fmt.Errorf("response stream ended before the content was finished")
+	}
+
+	response.Text = text.String()
+	return response, nil
+}
+
+// send calls a Gemini REST resource, decoding the response into result
+// when it is set
+func (c *geminiRESTClient) send(ctx context.Context, method, resource string, body []byte, result interface{}) error {
+	endpoint := fmt.Sprintf("%s/%s/%s", strings.TrimRight(c.endpoint, "/"), c.version, resource)
+	httpReq, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", c.apiKey)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(data))
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return nil
+}
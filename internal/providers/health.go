@@ -0,0 +1,100 @@
+package providers
+
+import (
+	"sync"
+	"time"
+)
+
+// Provider health statuses
+const (
+	HealthUnknown   = "unknown"   // No requests yet
+	HealthHealthy   = "healthy"   // The last request succeeded
+	HealthDegraded  = "degraded"  // Recent requests failed
+	HealthUnhealthy = "unhealthy" // UnhealthyAfter or more consecutive failures, or a failed health check
+)
+
+// UnhealthyAfter is the number of consecutive failures after which a
+// provider is reported unhealthy
+const UnhealthyAfter = 3
+
+// Health is a provider's status derived from its recent requests
+type Health struct {
+	Status              string     `json:"status"`
+	ConsecutiveFailures int        `json:"consecutive_failures,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastFailure         *time.Time `json:"last_failure,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastCheck           *time.Time `json:"last_check,omitempty"`  // Last health check, e.g. listing models
+	CheckError          string     `json:"check_error,omitempty"` // Why the last health check failed
+}
+
+// healthTracker records request outcomes per provider
+type healthTracker struct {
+	mu     sync.Mutex
+	health map[string]*Health
+}
+
+func (t *healthTracker) record(name string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.entry(name)
+	now := time.Now().UTC()
+	if err == nil {
+		// The keys work after all
+		h.ConsecutiveFailures = 0
+		h.LastSuccess = &now
+		h.CheckError = ""
+		return
+	}
+	h.ConsecutiveFailures++
+	h.LastFailure = &now
+	h.LastError = err.Error()
+}
+
+// check records the outcome of a health check; err is nil on success
+func (t *healthTracker) check(name string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.entry(name)
+	now := time.Now().UTC()
+	h.LastCheck = &now
+	h.CheckError = ""
+	if err != nil {
+		h.CheckError = err.Error()
+	}
+}
+
+// entry returns a provider's health, adding it if needed; t.mu must be held
+func (t *healthTracker) entry(name string) *Health {
+	if t.health == nil {
+		t.health = make(map[string]*Health)
+	}
+	h, ok := t.health[name]
+	if !ok {
+		h = &Health{}
+		t.health[name] = h
+	}
+	return h
+}
+
+func (t *healthTracker) get(name string) Health {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h, ok := t.health[name]
+	if !ok {
+		return Health{Status: HealthUnknown}
+	}
+	result := *h
+	switch {
+	case h.CheckError != "", h.ConsecutiveFailures >= UnhealthyAfter:
+		result.Status = HealthUnhealthy
+	case h.ConsecutiveFailures > 0:
+		result.Status = HealthDegraded
+	default:
+		result.Status = HealthHealthy
+	}
+	return result
+}
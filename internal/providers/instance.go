@@ -0,0 +1,192 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// instance adapts a provider to the name, models and limits of a manifest
+// entry
+type instance struct {
+	provider     AIProvider
+	name         string
+	models       []string
+	defaultModel string
+	slots        chan struct{} // nil when concurrency is unlimited
+	timeout      time.Duration
+}
+
+// embeddingInstance is an instance whose provider can also embed text
+type embeddingInstance struct {
+	*instance
+	embedder Embedder
+}
+
+// newInstance wraps provider for the spec, keeping its Embedder support
+func newInstance(provider AIProvider, spec ProviderSpec) AIProvider {
+	inst := &instance{
+		provider:     provider,
+		name:         spec.Name,
+		models:       spec.Models,
+		defaultModel: spec.DefaultModel,
+		timeout:      time.Duration(spec.Limits.TimeoutSeconds) * time.Second,
+	}
+	if len(inst.models) == 0 {
+		inst.models = provider.SupportedModels()
+	}
+	if inst.defaultModel == "" {
+		inst.defaultModel = provider.DefaultModel()
+		if !slices.Contains(inst.models, inst.defaultModel) {
+			inst.defaultModel = inst.models[0]
+		}
+	}
+	if spec.Limits.MaxConcurrent > 0 {
+		inst.slots = make(chan struct{}, spec.Limits.MaxConcurrent)
+	}
+
+	if embedder, ok := provider.(Embedder); ok {
+		return &embeddingInstance{instance: inst, embedder: embedder}
+	}
+	return inst
+}
+
+// Name returns the manifest name
+func (p *instance) Name() string {
+	return p.name
+}
+
+// SupportedModels returns the manifest's models
+func (p *instance) SupportedModels() []string {
+	return p.models
+}
+
+// DefaultModel returns the manifest's default model
+func (p *instance) DefaultModel() string {
+	return p.defaultModel
+}
+
+// Review performs a code review within the instance's limits
+func (p *instance) Review(ctx context.Context, request *models.ReviewRequest) (*models.AIProviderResponse, error) {
+	ctx, release, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if request.AIModel == "" {
+		withModel := *request
+		withModel.AIModel = p.defaultModel
+		request = &withModel
+	}
+	return p.provider.Review(ctx, request)
+}
+
+// Complete sends a raw prompt within the instance's limits
+func (p *instance) Complete(ctx context.Context, request *CompletionRequest) (*CompletionResponse, error) {
+	ctx, release, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if request.Model == "" {
+		withModel := *request
+		withModel.Model = p.defaultModel
+		request = &withModel
+	}
+	return p.provider.Complete(ctx, request)
+}
+
+// Embed returns embeddings within the instance's limits
+func (p *embeddingInstance) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	ctx, release, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return p.embedder.Embed(ctx, model, texts)
+}
+
+// KeyStats returns the per-key usage of the wrapped provider
+func (p *instance) KeyStats() []KeyStats {
+	if s, ok := p.provider.(keyStatser); ok {
+		return s.KeyStats()
+	}
+	return nil
+}
+
+// SetKeys replaces the upstream keys of the wrapped provider
+func (p *instance) SetKeys(apiKeys []string) error {
+	if r, ok := p.provider.(keyRotator); ok {
+		return r.SetKeys(apiKeys)
+	}
+	return fmt.Errorf("provider %s doesn't support key rotation", p.name)
+}
+
+// CheckParams applies the wrapped provider's parameter rules
+func (p *instance) CheckParams(model string, params models.ModelParams) error {
+	if c, ok := p.provider.(paramChecker); ok {
+		return c.CheckParams(model, params)
+	}
+	return nil
+}
+
+// SupportsBatch reports whether the wrapped provider has a batch API
+// enabled
+func (p *instance) SupportsBatch() bool {
+	return SupportsBatch(p.provider)
+}
+
+// CachesContext reports whether the wrapped provider caches shared context
+func (p *instance) CachesContext() bool {
+	return CachesContext(p.provider)
+}
+
+// CheckHealth runs the wrapped provider's health check
+func (p *instance) CheckHealth(ctx context.Context) error {
+	if c, ok := p.provider.(healthChecker); ok {
+		return c.CheckHealth(ctx)
+	}
+	return nil
+}
+
+// Close closes the wrapped provider's clients
+func (p *instance) Close() error {
+	if c, ok := p.provider.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// acquire waits for a concurrency slot and applies the call timeout.
+// Batched calls wait on the provider's batch API instead, so they take
+// neither.
+func (p *instance) acquire(ctx context.Context) (context.Context, func(), error) {
+	cancel := context.CancelFunc(func() {})
+	if BatchRequested(ctx) && SupportsBatch(p.provider) {
+		return ctx, cancel, nil
+	}
+	if p.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+	}
+
+	if p.slots == nil {
+		return ctx, cancel, nil
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return ctx, func() {
+			<-p.slots
+			cancel()
+		}, nil
+	case <-ctx.Done():
+		cancel()
+		return nil, nil, ctx.Err()
+	}
+}
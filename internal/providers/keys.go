@@ -0,0 +1,430 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Upstream key selection strategies
+const (
+	KeySelectionRoundRobin       = "round-robin"        // Each call uses the next key
+	KeySelectionLeastRateLimited = "least-rate-limited" // Prefer the key rate limited longest ago
+)
+
+// KeyStats is the usage of one upstream API key
+type KeyStats struct {
+	ID               string     `json:"id"` // Non-reversible fingerprint of the key
+	Requests         int64      `json:"requests"`
+	Failures         int64      `json:"failures"`
+	RateLimited      int64      `json:"rate_limited"`
+	PromptTokens     int64      `json:"prompt_tokens"`
+	CompletionTokens int64      `json:"completion_tokens"`
+	LastUsed         *time.Time `json:"last_used,omitempty"`
+	LastRateLimited  *time.Time `json:"last_rate_limited,omitempty"`
+	CheckError       string     `json:"check_error,omitempty"` // Why the key failed the last health check
+}
+
+// ParseKeys splits a comma-separated list of API keys
+func ParseKeys(value string) []string {
+	var keys []string
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// keyID derives a non-reversible identifier from an API key
+func keyID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return "key-" + hex.EncodeToString(sum[:])[:12]
+}
+
+// pooledKey is a provider client bound to one upstream key
+type pooledKey struct {
+	provider AIProvider
+	stats    KeyStats
+	calls    sync.WaitGroup // Calls that may still use the key
+}
+
+// keyPool spreads calls across clients built with different upstream
+// keys. A call that is rate limited is retried once on every other key.
+type keyPool struct {
+	mu        sync.Mutex
+	rotating  sync.Mutex // Serializes SetKeys
+	keys      []*pooledKey
+	next      int
+	selection string
+	build     func(apiKey string) (AIProvider, error)
+}
+
+// embeddingKeyPool is a key pool whose clients can also embed text
+type embeddingKeyPool struct {
+	*keyPool
+}
+
+// newKeyPool creates a pool over clients built for each key by build
+func newKeyPool(apiKeys []string, selection string, build func(apiKey string) (AIProvider, error)) (AIProvider, error) {
+	pool := &keyPool{selection: selection, build: build}
+	if err := pool.SetKeys(apiKeys); err != nil {
+		return nil, err
+	}
+
+	if _, ok := pool.first().(Embedder); ok {
+		return &embeddingKeyPool{keyPool: pool}, nil
+	}
+	return pool, nil
+}
+
+// first returns the client of the first key. Every client is built the
+// same way, so it answers for the pool's metadata and capabilities.
+func (p *keyPool) first() AIProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.keys[0].provider
+}
+
+// Name returns the provider name
+func (p *keyPool) Name() string {
+	return p.first().Name()
+}
+
+// SupportedModels returns the list of supported models
+func (p *keyPool) SupportedModels() []string {
+	return p.first().SupportedModels()
+}
+
+// DefaultModel returns the model used when a request doesn't name one
+func (p *keyPool) DefaultModel() string {
+	return p.first().DefaultModel()
+}
+
+// Review performs a code review with the selected key
+func (p *keyPool) Review(ctx context.Context, request *models.ReviewRequest) (*models.AIProviderResponse, error) {
+	var response *models.AIProviderResponse
+	err := p.call(func(key *pooledKey) (int, int, error) {
+		var err error
+		response, err = key.provider.Review(ctx, request)
+		if err != nil {
+			return 0, 0, err
+		}
+		return response.Usage.PromptTokens, response.Usage.CompletionTokens, nil
+	})
+	return response, err
+}
+
+// Complete sends a raw prompt with the selected key
+func (p *keyPool) Complete(ctx context.Context, request *CompletionRequest) (*CompletionResponse, error) {
+	var response *CompletionResponse
+	err := p.call(func(key *pooledKey) (int, int, error) {
+		var err error
+		response, err = key.provider.Complete(ctx, request)
+		if err != nil {
+			return 0, 0, err
+		}
+		return response.PromptTokens, response.CompletionTokens, nil
+	})
+	return response, err
+}
+
+// Embed returns embeddings with the selected key
+func (p *embeddingKeyPool) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	var vectors [][]float32
+	err := p.call(func(key *pooledKey) (int, int, error) {
+		var err error
+		vectors, err = key.provider.(Embedder).Embed(ctx, model, texts)
+		return 0, 0, err
+	})
+	return vectors, err
+}
+
+// SetKeys replaces the pool's keys, keeping the stats of keys that remain.
+// Calls in flight finish with the key they started with; the clients of
+// removed keys are closed once those calls return.
+func (p *keyPool) SetKeys(apiKeys []string) error {
+	if len(apiKeys) == 0 {
+		return fmt.Errorf("no API key")
+	}
+	p.rotating.Lock()
+	defer p.rotating.Unlock()
+
+	p.mu.Lock()
+	previous := make(map[string]*pooledKey, len(p.keys))
+	for _, key := range p.keys {
+		previous[key.stats.ID] = key
+	}
+	p.mu.Unlock()
+
+	keys := make([]*pooledKey, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		id := keyID(apiKey)
+		if key, ok := previous[id]; ok {
+			keys = append(keys, key)
+			continue
+		}
+		provider, err := p.build(apiKey)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, &pooledKey{provider: provider, stats: KeyStats{ID: id}})
+	}
+
+	kept := make(map[*pooledKey]bool, len(keys))
+	for _, key := range keys {
+		kept[key] = true
+	}
+	p.mu.Lock()
+	var removed []*pooledKey
+	for _, key := range p.keys {
+		if !kept[key] {
+			removed = append(removed, key)
+		}
+	}
+	p.keys = keys
+	p.next = 0
+	p.mu.Unlock()
+
+	if len(removed) > 0 {
+		go closeKeys(removed)
+	}
+	return nil
+}
+
+// closeKeys closes the clients of keys no longer in the pool once the
+// calls still using them return
+func closeKeys(keys []*pooledKey) {
+	for _, key := range keys {
+		key.calls.Wait()
+		if c, ok := key.provider.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				log.Printf("Warning: failed to close the client of removed key %s: %v", key.stats.ID, err)
+			}
+		}
+	}
+}
+
+// CheckParams applies the parameter rules of the pool's clients
+func (p *keyPool) CheckParams(model string, params models.ModelParams) error {
+	if c, ok := p.first().(paramChecker); ok {
+		return c.CheckParams(model, params)
+	}
+	return nil
+}
+
+// SupportsBatch reports whether the pool's clients have a batch API
+// enabled
+func (p *keyPool) SupportsBatch() bool {
+	return SupportsBatch(p.first())
+}
+
+// CachesContext reports whether the pool's clients cache shared context
+func (p *keyPool) CachesContext() bool {
+	return CachesContext(p.first())
+}
+
+// CheckHealth checks every key, recording the failures in their stats. It
+// fails only when no key passes; the keys that fail are logged.
+func (p *keyPool) CheckHealth(ctx context.Context) error {
+	p.mu.Lock()
+	keys := slices.Clone(p.keys)
+	for _, key := range keys {
+		key.calls.Add(1)
+	}
+	p.mu.Unlock()
+	defer func() {
+		for _, key := range keys {
+			key.calls.Done()
+		}
+	}()
+
+	var errs []error
+	for _, key := range keys {
+		checker, ok := key.provider.(healthChecker)
+		if !ok {
+			return nil
+		}
+		err := checker.CheckHealth(ctx)
+		if checkInconclusive(err) {
+			err = nil
+		}
+
+		p.mu.Lock()
+		key.stats.CheckError = ""
+		if err != nil {
+			key.stats.CheckError = err.Error()
+		}
+		p.mu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key.stats.ID, err))
+		}
+	}
+	if len(errs) == len(keys) {
+		return errors.Join(errs...)
+	}
+	for _, err := range errs {
+		log.Printf("Warning: upstream key failed its health check: %v", err)
+	}
+	return nil
+}
+
+// Close closes the client of every key
+func (p *keyPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+	for _, key := range p.keys {
+		if c, ok := key.provider.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// KeyStats returns the usage of each key, in configuration order
+func (p *keyPool) KeyStats() []KeyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]KeyStats, len(p.keys))
+	for i, key := range p.keys {
+		stats[i] = key.stats
+	}
+	return stats
+}
+
+// call runs fn with keys in selection order until one isn't rate limited
+func (p *keyPool) call(fn func(key *pooledKey) (promptTokens, completionTokens int, err error)) error {
+	keys := p.order()
+	defer func() {
+		for _, key := range keys {
+			key.calls.Done()
+		}
+	}()
+
+	var err error
+	for _, key := range keys {
+		var promptTokens, completionTokens int
+		promptTokens, completionTokens, err = fn(key)
+		limited := isRateLimited(err)
+		p.record(key, promptTokens, completionTokens, err, limited)
+		if !limited {
+			return err
+		}
+	}
+	return err
+}
+
+// order returns every key, the one to try first at the front. The keys
+// count as in use until the caller marks each one Done.
+func (p *keyPool) order() []*pooledKey {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	start := p.next
+	p.next = (start + 1) % len(p.keys)
+	if p.selection == KeySelectionLeastRateLimited {
+		// Ties keep round-robin order
+		best := start
+		for i := 1; i < len(p.keys); i++ {
+			candidate := (start + i) % len(p.keys)
+			if rateLimitedBefore(p.keys[candidate], p.keys[best]) {
+				best = candidate
+			}
+		}
+		start = best
+	}
+
+	ordered := make([]*pooledKey, 0, len(p.keys))
+	for i := range p.keys {
+		key := p.keys[(start+i)%len(p.keys)]
+		key.calls.Add(1)
+		ordered = append(ordered, key)
+	}
+	return ordered
+}
+
+// rateLimitedBefore reports whether a was last rate limited before b
+func rateLimitedBefore(a, b *pooledKey) bool {
+	if a.stats.LastRateLimited == nil {
+		return b.stats.LastRateLimited != nil
+	}
+	return b.stats.LastRateLimited != nil && a.stats.LastRateLimited.Before(*b.stats.LastRateLimited)
+}
+
+// record updates a key's stats after a call
+func (p *keyPool) record(key *pooledKey, promptTokens, completionTokens int, err error, limited bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now().UTC()
+	key.stats.Requests++
+	key.stats.LastUsed = &now
+	key.stats.PromptTokens += int64(promptTokens)
+	key.stats.CompletionTokens += int64(completionTokens)
+	if err != nil {
+		key.stats.Failures++
+	}
+	if limited {
+		key.stats.RateLimited++
+		key.stats.LastRateLimited = &now
+	}
+}
+
+// keyStatser is implemented by providers that track per-key usage
+type keyStatser interface {
+	KeyStats() []KeyStats
+}
+
+// keyRotator is implemented by providers whose keys can be replaced
+type keyRotator interface {
+	SetKeys(apiKeys []string) error
+}
+
+// KeyStats returns the per-key usage of a provider, or nil if it doesn't
+// track any
+func (r *Registry) KeyStats(name string) []KeyStats {
+	provider, err := r.Get(name)
+	if err != nil {
+		return nil
+	}
+	if s, ok := provider.(keyStatser); ok {
+		return s.KeyStats()
+	}
+	return nil
+}
+
+// isRateLimited reports whether err is an upstream rate-limit response
+func isRateLimited(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "status 429") ||
+		strings.Contains(message, "resource_exhausted") ||
+		strings.Contains(message, "rate limit") ||
+		strings.Contains(message, "rate_limit")
+}
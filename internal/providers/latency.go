@@ -0,0 +1,60 @@
+package providers
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencyWindow is the number of recent calls per model latency
+// percentiles are computed over
+const latencyWindow = 100
+
+// latencyTracker keeps the latencies of the most recent successful calls
+// per provider and model
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration // Ring buffers keyed by provider/model
+	next    map[string]int
+}
+
+func (t *latencyTracker) record(key string, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.samples == nil {
+		t.samples = make(map[string][]time.Duration)
+		t.next = make(map[string]int)
+	}
+	samples := t.samples[key]
+	if len(samples) < latencyWindow {
+		t.samples[key] = append(samples, latency)
+		return
+	}
+	samples[t.next[key]] = latency
+	t.next[key] = (t.next[key] + 1) % latencyWindow
+}
+
+func (t *latencyTracker) percentile(key string, p float64) (time.Duration, int) {
+	t.mu.Lock()
+	samples := slices.Clone(t.samples[key])
+	t.mu.Unlock()
+
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	slices.Sort(samples)
+	index := int(float64(len(samples))*p+0.5) - 1
+	return samples[min(max(index, 0), len(samples)-1)], len(samples)
+}
+
+// ReportLatency records how long a successful call to a model took
+func (r *Registry) ReportLatency(provider, model string, latency time.Duration) {
+	r.latency.record(provider+"/"+model, latency)
+}
+
+// LatencyP95 returns the 95th percentile latency of a model's recent
+// successful calls and the number of calls it is computed over
+func (r *Registry) LatencyP95(provider, model string) (time.Duration, int) {
+	return r.latency.percentile(provider+"/"+model, 0.95)
+}
@@ -0,0 +1,305 @@
+package providers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Provider types accepted in a manifest
+const (
+	TypeGoogle    = "google"
+	TypeOpenAI    = "openai"
+	TypeAnthropic = "anthropic"
+)
+
+var providerName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// Manifest lists the provider instances the gateway registers
+type Manifest struct {
+	Providers []ProviderSpec `yaml:"providers"`
+}
+
+// ProviderSpec describes one provider instance
+type ProviderSpec struct {
+	Name         string   `yaml:"name"`          // Registry name used as ai_provider
+	Type         string   `yaml:"type"`          // google, openai or anthropic
+	BaseURL      string   `yaml:"base_url"`      // Empty uses the vendor's API
+	APIKeyEnv    string   `yaml:"api_key_env"`   // Environment variable holding one or more comma-separated API keys
+	KeySelection string   `yaml:"key_selection"` // round-robin (default) or least-rate-limited
+	Models       []string `yaml:"models"`        // Empty uses the type's built-in list
+	DefaultModel string   `yaml:"default_model"` // Empty uses the type's default, or the first model
+	Transport    string   `yaml:"transport"`     // google only: sdk, rest or auto
+	APIVersion   string   `yaml:"api_version"`   // google only
+	NoStream     bool     `yaml:"no_stream"`     // Never stream responses, for compatible servers that can't
+	Batch        bool     `yaml:"batch"`         // openai only: send queued batch-class reviews through the Batch API
+	CacheTTL     int      `yaml:"cache_ttl"`     // google only: seconds shared review context stays cached; zero disables caching
+	Proxy        string   `yaml:"proxy"`         // http, https or socks5 proxy URL, or direct; empty uses HTTPS_PROXY
+	ProxyEnv     string   `yaml:"proxy_env"`     // Environment variable holding the proxy URL, e.g. with credentials; overrides proxy when set
+	Limits       Limits   `yaml:"limits"`
+}
+
+// Limits bound the load the gateway sends to one provider instance
+type Limits struct {
+	MaxConcurrent  int `yaml:"max_concurrent"`  // Concurrent calls; zero means unlimited
+	TimeoutSeconds int `yaml:"timeout_seconds"` // Per-call timeout; zero uses the caller's
+}
+
+// LoadManifest reads a YAML or JSON provider manifest
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provider manifest: %w", err)
+	}
+
+	// JSON is valid YAML, so one decoder handles both formats
+	var manifest Manifest
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse provider manifest %s: %w", path, err)
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, fmt.Errorf("provider manifest %s: %w", path, err)
+	}
+	return &manifest, nil
+}
+
+// Validate checks that every entry can be built
+func (m *Manifest) Validate() error {
+	if len(m.Providers) == 0 {
+		return fmt.Errorf("no providers listed")
+	}
+
+	seen := make(map[string]bool)
+	for i, spec := range m.Providers {
+		if !providerName.MatchString(spec.Name) {
+			return fmt.Errorf("provider %d: invalid name %q: use lower-case letters, digits, '.', '_' and '-'", i, spec.Name)
+		}
+		if spec.Name == "ensemble" {
+			return fmt.Errorf("provider %d: the name ensemble is reserved", i)
+		}
+		if seen[spec.Name] {
+			return fmt.Errorf("provider %s is listed twice", spec.Name)
+		}
+		seen[spec.Name] = true
+
+		switch spec.Type {
+		case TypeGoogle, TypeOpenAI, TypeAnthropic:
+		default:
+			return fmt.Errorf("provider %s: type must be one of google, openai or anthropic", spec.Name)
+		}
+		if spec.APIKeyEnv == "" {
+			return fmt.Errorf("provider %s: api_key_env is required", spec.Name)
+		}
+		if spec.DefaultModel != "" && len(spec.Models) > 0 && !slices.Contains(spec.Models, spec.DefaultModel) {
+			return fmt.Errorf("provider %s: default_model %s is not in models", spec.Name, spec.DefaultModel)
+		}
+		switch spec.KeySelection {
+		case "", KeySelectionRoundRobin, KeySelectionLeastRateLimited:
+		default:
+			return fmt.Errorf("provider %s: key_selection must be round-robin or least-rate-limited", spec.Name)
+		}
+		switch spec.Transport {
+		case "", GeminiTransportSDK, GeminiTransportREST, GeminiTransportAuto:
+		default:
+			return fmt.Errorf("provider %s: transport must be one of sdk, rest or auto", spec.Name)
+		}
+		if spec.Batch && spec.Type != TypeOpenAI {
+			return fmt.Errorf("provider %s: batch is only supported by openai providers", spec.Name)
+		}
+		if spec.Proxy != "" && spec.Proxy != ProxyDirect {
+			if _, err := parseProxy(spec.Proxy); err != nil {
+				return fmt.Errorf("provider %s: %w", spec.Name, err)
+			}
+		}
+		if spec.CacheTTL < 0 {
+			return fmt.Errorf("provider %s: cache_ttl must not be negative", spec.Name)
+		}
+		if spec.CacheTTL > 0 && spec.Type != TypeGoogle {
+			return fmt.Errorf("provider %s: cache_ttl is only supported by google providers", spec.Name)
+		}
+		if spec.Limits.MaxConcurrent < 0 || spec.Limits.TimeoutSeconds < 0 {
+			return fmt.Errorf("provider %s: limits must not be negative", spec.Name)
+		}
+	}
+	return nil
+}
+
+// ParseTimeouts parses "name=seconds,..." per-provider call timeouts
+func ParseTimeouts(value string) (map[string]int, error) {
+	timeouts := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, seconds, ok := strings.Cut(entry, "=")
+		parsed, err := strconv.Atoi(strings.TrimSpace(seconds))
+		if !ok || err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid provider timeout %q: expected name=seconds", entry)
+		}
+		timeouts[strings.TrimSpace(name)] = parsed
+	}
+	return timeouts, nil
+}
+
+// SetTimeouts overrides the call timeout of the named providers
+func (m *Manifest) SetTimeouts(timeouts map[string]int) error {
+	for name, seconds := range timeouts {
+		i := slices.IndexFunc(m.Providers, func(spec ProviderSpec) bool { return spec.Name == name })
+		if i < 0 {
+			return fmt.Errorf("provider %s is not in the manifest", name)
+		}
+		m.Providers[i].Limits.TimeoutSeconds = seconds
+	}
+	return nil
+}
+
+// Register builds every provider whose API keys are set and adds it to the
+// registry. secret looks up the api_key_env variables. Providers that fail
+// to build are logged and skipped. It returns the names registered, in
+// manifest order.
+func (m *Manifest) Register(registry *Registry, secret func(name string) string) []string {
+	var names []string
+	for _, spec := range m.Providers {
+		apiKeys := ParseKeys(secret(spec.APIKeyEnv))
+		if len(apiKeys) == 0 {
+			continue
+		}
+		if spec.ProxyEnv != "" {
+			if proxy := secret(spec.ProxyEnv); proxy != "" {
+				spec.Proxy = proxy
+			}
+		}
+		provider, err := spec.Build(apiKeys)
+		if err != nil {
+			log.Printf("Warning: Failed to initialize provider %s: %v", spec.Name, err)
+			continue
+		}
+		registry.Register(spec.Name, provider)
+		names = append(names, spec.Name)
+	}
+	return names
+}
+
+// Missing returns the providers whose API keys are set but that aren't
+// registered, because they failed to initialize or were configured after
+// startup
+func (m *Manifest) Missing(registry *Registry, secret func(name string) string) []string {
+	var names []string
+	for _, spec := range m.Providers {
+		if len(ParseKeys(secret(spec.APIKeyEnv))) == 0 {
+			continue
+		}
+		if _, err := registry.Get(spec.Name); err != nil {
+			names = append(names, spec.Name)
+		}
+	}
+	return names
+}
+
+// RotateKeys rereads the API keys of the registered providers. Providers
+// whose keys are now unset keep their old keys; providers that weren't
+// registered at startup need a restart. It returns the names rotated.
+func (m *Manifest) RotateKeys(registry *Registry, secret func(name string) string) []string {
+	var names []string
+	for _, spec := range m.Providers {
+		apiKeys := ParseKeys(secret(spec.APIKeyEnv))
+		provider, err := registry.Get(spec.Name)
+		if err != nil {
+			if len(apiKeys) > 0 {
+				log.Printf("Warning: %s is now set; restart to register provider %s", spec.APIKeyEnv, spec.Name)
+			}
+			continue
+		}
+		if len(apiKeys) == 0 {
+			log.Printf("Warning: %s is empty; provider %s keeps its current keys", spec.APIKeyEnv, spec.Name)
+			continue
+		}
+		rotator, ok := provider.(keyRotator)
+		if !ok {
+			continue
+		}
+		if err := rotator.SetKeys(apiKeys); err != nil {
+			log.Printf("Warning: Failed to rotate keys of provider %s: %v", spec.Name, err)
+			continue
+		}
+		names = append(names, spec.Name)
+	}
+	return names
+}
+
+// Build creates the provider described by the spec. With several keys,
+// calls are spread across them according to the spec's key selection.
+func (s ProviderSpec) Build(apiKeys []string) (AIProvider, error) {
+	if len(apiKeys) == 0 {
+		return nil, fmt.Errorf("no API key")
+	}
+	selection := s.KeySelection
+	if selection == "" {
+		selection = KeySelectionRoundRobin
+	}
+
+	provider, err := newKeyPool(apiKeys, selection, s.build)
+	if err != nil {
+		return nil, err
+	}
+	return newInstance(provider, s), nil
+}
+
+// build creates a client for the spec's type using one API key
+func (s ProviderSpec) build(apiKey string) (AIProvider, error) {
+	httpClient, err := newProxiedHTTPClient(s.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	switch s.Type {
+	case TypeGoogle:
+		transport := s.Transport
+		if transport == "" && s.BaseURL != "" {
+			// The SDK always talks to Google's endpoint
+			transport = GeminiTransportREST
+		}
+		config := GeminiConfig{
+			APIKey:     apiKey,
+			Transport:  transport,
+			Endpoint:   s.BaseURL,
+			APIVersion: s.APIVersion,
+			CacheTTL:   time.Duration(s.CacheTTL) * time.Second,
+		}
+		if s.Proxy != "" {
+			// The SDK can't use the proxy, so the REST API is used
+			config.HTTPClient = httpClient
+		}
+		provider, err := NewGeminiProvider(config)
+		if err != nil {
+			return nil, err
+		}
+		provider.noStream = s.NoStream
+		return provider, nil
+	case TypeOpenAI:
+		provider := newOpenAIProvider(apiKey, s.BaseURL, httpClient)
+		provider.noStream = s.NoStream
+		if s.Batch {
+			provider.EnableBatch()
+		}
+		return provider, nil
+	case TypeAnthropic:
+		provider := NewClaudeProvider(apiKey, s.BaseURL)
+		provider.httpClient = httpClient
+		provider.noStream = s.NoStream
+		return provider, nil
+	default:
+		return nil, fmt.Errorf("unknown provider type %q", s.Type)
+	}
+}
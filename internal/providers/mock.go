@@ -0,0 +1,174 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
+)
+
+// mockModel is the only model of the mock provider
+const mockModel = "mock"
+
+// mockLineLimit is the length above which the mock reports a long line
+const mockLineLimit = 120
+
+// mockRule reports added lines matching a pattern
+type mockRule struct {
+	pattern  *regexp.Regexp
+	severity string
+	category string
+	message  string
+}
+
+// mockRules are the checks of the rule-based mock review
+var mockRules = []mockRule{
+	{regexp.MustCompile(`(?i)(password|passwd|secret|api_?key|token)\s*[:=]+\s*["'][^"']{4,}["']`), "ERROR", "possible-issue", "Possible hardcoded credential"},
+	{regexp.MustCompile(`\b(TODO|FIXME|XXX)\b`), "INFO", "maintainability", "Unresolved TODO comment"},
+	{regexp.MustCompile(`\b(console\.log|fmt\.Print(ln|f)?|System\.out\.print(ln)?|var_dump|dbg!)\(|^\s*print\(`), "WARNING", "best-practice", "Debug output left in the code"},
+	{regexp.MustCompile(`\b(panic|eval)\(`), "WARNING", "possible-bug", "Call that can crash or execute arbitrary code"},
+}
+
+// MockConfig configures the mock provider
+type MockConfig struct {
+	ResponseFile string        // JSON review returned for every request; empty uses the built-in rules
+	Latency      time.Duration // Delay added to every call, to simulate a real provider
+}
+
+// MockProvider answers without calling a model, so CI integrations, load
+// tests and local development don't spend tokens. Reviews return the
+// canned response file, or findings of a few simple rules applied to the
+// added lines of the diff.
+type MockProvider struct {
+	canned  string
+	latency time.Duration
+}
+
+// NewMockProvider creates a mock provider, reading its canned response
+func NewMockProvider(config MockConfig) (*MockProvider, error) {
+	provider := &MockProvider{latency: config.Latency}
+	if config.ResponseFile != "" {
+		data, err := os.ReadFile(config.ResponseFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mock response file: %w", err)
+		}
+		if response, err := prompt.ParseAIResponse(string(data)); err != nil || response.ParserPath == prompt.ParserUnstructured {
+			return nil, fmt.Errorf("mock response file %s is not a JSON review with overview and issues", config.ResponseFile)
+		}
+		provider.canned = string(data)
+	}
+	return provider, nil
+}
+
+// Name returns the provider name
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+// SupportedModels returns the list of supported models
+func (p *MockProvider) SupportedModels() []string {
+	return []string{mockModel}
+}
+
+// DefaultModel returns the model used when a request names none
+func (p *MockProvider) DefaultModel() string {
+	return mockModel
+}
+
+// Review returns the canned response or the findings of the built-in rules
+func (p *MockProvider) Review(ctx context.Context, request *models.ReviewRequest) (*models.AIProviderResponse, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	text := p.canned
+	if text == "" {
+		text = mockReview(request.GitDiff)
+	}
+	response, err := prompt.ParseAIResponse(text)
+	if err != nil {
+		return nil, err
+	}
+	response.Usage = models.Usage{
+		PromptBytes:   len(request.GitDiff),
+		ResponseBytes: len(text),
+	}
+	return response, nil
+}
+
+// Complete returns the canned response, or a fixed placeholder
+func (p *MockProvider) Complete(ctx context.Context, request *CompletionRequest) (*CompletionResponse, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	text := p.canned
+	if text == "" {
+		text = "This is a response from the mock provider; no model was called."
+	}
+	if request.Stream != nil {
+		request.Stream(text)
+	}
+	return &CompletionResponse{Text: text}, nil
+}
+
+// wait sleeps for the configured latency unless the context ends first
+func (p *MockProvider) wait(ctx context.Context) error {
+	if p.latency <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(p.latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// mockReview applies the mock rules to the added lines of a diff and
+// returns the findings as a JSON review
+func mockReview(gitDiff string) string {
+	type issue struct {
+		File     string `json:"file"`
+		Line     int    `json:"line"`
+		Severity string `json:"severity"`
+		Category string `json:"category"`
+		Message  string `json:"message"`
+	}
+	issues := make([]issue, 0)
+	files := diff.Parse(gitDiff)
+	for _, f := range files {
+		for _, h := range f.Hunks {
+			for _, l := range h.Lines {
+				if l.Kind != diff.LineAdded {
+					continue
+				}
+				for _, rule := range mockRules {
+					if rule.pattern.MatchString(l.Content) {
+						issues = append(issues, issue{f.Path(), l.NewLine, rule.severity, rule.category, rule.message})
+					}
+				}
+				if len(l.Content) > mockLineLimit {
+					issues = append(issues, issue{f.Path(), l.NewLine, "INFO", "maintainability", fmt.Sprintf("Line longer than %d characters", mockLineLimit)})
+				}
+			}
+		}
+	}
+
+	data, _ := json.Marshal(struct {
+		Overview string  `json:"overview"`
+		Issues   []issue `json:"issues"`
+	}{
+		Overview: fmt.Sprintf("Mock review of %d files: %d findings from built-in rules; no model was called.", len(files), len(issues)),
+		Issues:   issues,
+	})
+	return string(data)
+}
@@ -2,21 +2,39 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
 
 	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
-	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
 	openai "github.com/sashabaranov/go-openai"
 )
 
 // OpenAIProvider implements the AIProvider interface for OpenAI
 type OpenAIProvider struct {
-	client *openai.Client
+	client   *openai.Client
+	noStream bool           // Never stream, for compatible servers that can't
+	batcher  *openAIBatcher // nil unless the Batch API is enabled
 }
 
-// NewOpenAIProvider creates a new OpenAI provider
-func NewOpenAIProvider(apiKey string) *OpenAIProvider {
-	client := openai.NewClient(apiKey)
+// NewOpenAIProvider creates a new OpenAI provider. An empty baseURL uses
+// the OpenAI API; other values target OpenAI-compatible servers.
+func NewOpenAIProvider(apiKey, baseURL string) *OpenAIProvider {
+	return newOpenAIProvider(apiKey, baseURL, newHTTPClient())
+}
+
+// newOpenAIProvider creates an OpenAI provider sending its requests with
+// httpClient
+func newOpenAIProvider(apiKey, baseURL string, httpClient *http.Client) *OpenAIProvider {
+	config := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		config.BaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+	config.HTTPClient = &extraFieldsDoer{doer: httpClient}
+	client := openai.NewClientWithConfig(config)
 	return &OpenAIProvider{
 		client: client,
 	}
@@ -34,51 +52,254 @@ func (p *OpenAIProvider) SupportedModels() []string {
 		"gpt-4-turbo",
 		"gpt-4o",
 		"gpt-3.5-turbo",
+		"o1",
+		"o1-mini",
+		"o3",
+		"o3-mini",
+		"o4-mini",
 	}
 }
 
+// DefaultModel returns the model used when a request doesn't name one
+func (p *OpenAIProvider) DefaultModel() string {
+	return "gpt-4o"
+}
+
 // Review performs a code review using OpenAI
 func (p *OpenAIProvider) Review(ctx context.Context, request *models.ReviewRequest) (*models.AIProviderResponse, error) {
+	return review(ctx, p, request, 4096)
+}
+
+// Complete sends a raw prompt to OpenAI and returns the response text
+func (p *OpenAIProvider) Complete(ctx context.Context, request *CompletionRequest) (*CompletionResponse, error) {
 	// Get model, default to gpt-4o if not specified
-	modelName := request.AIModel
+	modelName := request.Model
 	if modelName == "" {
-		modelName = "gpt-4o"
-	}
-
-	// Generate prompts
-	systemPrompt := prompt.GenerateSystemPrompt(request.Language)
-	userPrompt := prompt.GenerateUserPrompt(request)
-
-	// Create chat completion request
-	resp, err := p.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model: modelName,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: systemPrompt,
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: userPrompt,
-				},
-			},
-			Temperature: 0.3,
-			MaxTokens:   4096,
-		},
-	)
+		modelName = p.DefaultModel()
+	}
 
+	maxTokens := request.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = 4096
+	}
+
+	chatRequest := openai.ChatCompletionRequest{
+		Model:    modelName,
+		Messages: openAIMessages(modelName, request),
+	}
+	if isReasoningModel(modelName) {
+		// Reasoning tokens count against max_completion_tokens, so reserve
+		// room for them on top of the visible output; sampling settings are
+		// fixed by the API
+		chatRequest.MaxCompletionTokens = maxTokens + reasoningTokenReserve
+		if request.ReasoningEffort != "" && !isLegacyReasoningModel(modelName) {
+			ctx = withExtraFields(ctx, map[string]interface{}{"reasoning_effort": request.ReasoningEffort})
+		}
+	} else {
+		// go-openai drops a zero temperature, which the API reads as 1
+		chatRequest.Temperature = request.Temperature
+		if chatRequest.Temperature == 0 {
+			chatRequest.Temperature = math.SmallestNonzeroFloat32
+		}
+		if request.TopP != nil {
+			chatRequest.TopP = *request.TopP
+		}
+		chatRequest.MaxTokens = maxTokens
+	}
+
+	var response *CompletionResponse
+	var err error
+	if p.batcher != nil && BatchRequested(ctx) {
+		extra, _ := ctx.Value(extraFieldsKey{}).(map[string]interface{})
+		response, err = p.batcher.complete(ctx, chatRequest, extra)
+	} else if request.Stream != nil && !p.noStream {
+		response, err = p.completeStream(ctx, chatRequest, request.Stream)
+	} else {
+		response, err = p.completeOnce(ctx, chatRequest)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if isReasoningModel(modelName) && response.Text == "" && response.Truncated {
+		return nil, fmt.Errorf("%s used its whole token budget before answering; raise max_tokens or lower reasoning_effort", modelName)
+	}
+	return response, nil
+}
+
+// completeOnce sends a chat completion request and waits for the whole
+// response
+func (p *OpenAIProvider) completeOnce(ctx context.Context, chatRequest openai.ChatCompletionRequest) (*CompletionResponse, error) {
+	resp, err := p.client.CreateChatCompletion(ctx, chatRequest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create chat completion: %w", err)
 	}
+	return chatCompletion(resp)
+}
 
+// chatCompletion extracts the text and usage of a chat completion
+func chatCompletion(resp openai.ChatCompletionResponse) (*CompletionResponse, error) {
 	if len(resp.Choices) == 0 {
 		return nil, fmt.Errorf("no response from OpenAI")
 	}
 
-	responseText := resp.Choices[0].Message.Content
+	return &CompletionResponse{
+		Text:             resp.Choices[0].Message.Content,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		Truncated:        resp.Choices[0].FinishReason == openai.FinishReasonLength,
+	}, nil
+}
+
+// completeStream sends a streaming chat completion request, passing the
+// text received so far to onText as it arrives
+func (p *OpenAIProvider) completeStream(ctx context.Context, chatRequest openai.ChatCompletionRequest, onText func(string)) (*CompletionResponse, error) {
+	chatRequest.Stream = true
+	chatRequest.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	stream, err := p.client.CreateChatCompletionStream(ctx, chatRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chat completion stream: %w", err)
+	}
+	defer stream.Close()
 
-	// Parse the response
-	return prompt.ParseAIResponse(responseText)
+	response := &CompletionResponse{}
+	var text strings.Builder
+	finished := false
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chat completion stream: %w", err)
+		}
+		if chunk.Usage != nil {
+			response.PromptTokens = chunk.Usage.PromptTokens
+			response.CompletionTokens = chunk.Usage.CompletionTokens
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		if delta := chunk.Choices[0].Delta.Content; delta != "" {
+			text.WriteString(delta)
+			onText(text.String())
+		}
+		if reason := chunk.Choices[0].FinishReason; reason != "" {
+			finished = true
+			response.Truncated = reason == openai.FinishReasonLength
+		}
+	}
+
+	if !finished {
+		return nil, fmt.Errorf("response stream ended before the completion was finished")
+	}
+	response.Text = text.String()
+	return response, nil
+}
+
+// EnableBatch sends calls made WithBatch through the OpenAI Batch API
+func (p *OpenAIProvider) EnableBatch() {
+	if p.batcher == nil {
+		p.batcher = newOpenAIBatcher(p.client)
+	}
+}
+
+// SupportsBatch reports whether the Batch API is enabled
+func (p *OpenAIProvider) SupportsBatch() bool {
+	return p.batcher != nil
+}
+
+// Close stops the batches in flight, if the Batch API is enabled
+func (p *OpenAIProvider) Close() error {
+	if p.batcher != nil {
+		return p.batcher.Close()
+	}
+	return nil
+}
+
+// CheckHealth verifies the API key by listing models
+func (p *OpenAIProvider) CheckHealth(ctx context.Context) error {
+	if _, err := p.client.ListModels(ctx); err != nil {
+		return fmt.Errorf("failed to list models: %w", err)
+	}
+	return nil
+}
+
+// reasoningTokenReserve is added to the output budget of reasoning models
+// for the tokens they spend thinking
+const reasoningTokenReserve = 25000
+
+// CheckParams rejects reasoning_effort for models that don't reason, and
+// sampling settings for models that do
+func (p *OpenAIProvider) CheckParams(model string, params models.ModelParams) error {
+	if !isReasoningModel(model) {
+		if params.ReasoningEffort != "" {
+			return fmt.Errorf("reasoning_effort is only supported by OpenAI reasoning models (o1, o3, o4-mini, ...), not %s", model)
+		}
+		return nil
+	}
+	if params.Temperature != nil || params.TopP != nil {
+		return fmt.Errorf("temperature and top_p are not supported by reasoning model %s", model)
+	}
+	if params.ReasoningEffort != "" && isLegacyReasoningModel(model) {
+		return fmt.Errorf("reasoning_effort is not supported by %s", model)
+	}
+	return nil
+}
+
+// isReasoningModel reports whether model is an OpenAI o-series model
+func isReasoningModel(model string) bool {
+	return len(model) > 1 && model[0] == 'o' && model[1] >= '0' && model[1] <= '9'
+}
+
+// isLegacyReasoningModel reports whether model is an early o1 release, which
+// accepts neither system nor developer messages nor reasoning_effort
+func isLegacyReasoningModel(model string) bool {
+	_, legacy := openai.O1SeriesModels[model]
+	return legacy
+}
+
+// openAIMessages builds the chat messages for a prompt. Reasoning models
+// take instructions as a developer message; early o1 releases only accept
+// user messages, so the instructions are prepended to the prompt.
+func openAIMessages(model string, request *CompletionRequest) []openai.ChatCompletionMessage {
+	role := openai.ChatMessageRoleSystem
+	switch {
+	case isLegacyReasoningModel(model):
+		return []openai.ChatCompletionMessage{
+			{Role: openai.ChatMessageRoleUser, Content: request.SystemPrompt + "\n\n" + request.UserPrompt},
+		}
+	case isReasoningModel(model):
+		role = "developer"
+	}
+	return []openai.ChatCompletionMessage{
+		{Role: role, Content: request.SystemPrompt},
+		{Role: openai.ChatMessageRoleUser, Content: request.UserPrompt},
+	}
+}
+
+// Embed creates embeddings using the OpenAI embeddings API
+func (p *OpenAIProvider) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	if model == "" {
+		model = string(openai.SmallEmbedding3)
+	}
+
+	resp, err := p.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+		Input: texts,
+		Model: openai.EmbeddingModel(model),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings: %w", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, e := range resp.Data {
+		if e.Index < 0 || e.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding index %d out of range", e.Index)
+		}
+		vectors[e.Index] = e.Embedding
+	}
+	return vectors, nil
 }
@@ -0,0 +1,369 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/jobs"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	// batchWindow is how long calls are collected before they are sent as
+	// one batch
+	batchWindow = 10 * time.Second
+	// batchMaxCalls sends a batch before the window ends once it holds
+	// this many calls
+	batchMaxCalls = 1000
+	// batchPollInterval is the wait between checks of a submitted batch
+	batchPollInterval = 30 * time.Second
+	// batchCompletionWindow is the time OpenAI has to finish a batch
+	batchCompletionWindow = "24h"
+)
+
+// batchStats counts batches and the calls they carried, e.g.
+// calls_completed, and the calls waiting for a batch to finish
+var batchStats = expvar.NewMap("openai_batches")
+
+// openAIBatcher sends chat completions through the OpenAI Batch API. Calls
+// arriving within batchWindow of each other share one batch, which is
+// polled until OpenAI finishes it.
+type openAIBatcher struct {
+	client *openai.Client
+	ctx    context.Context // Cancelled by Close
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	pending []*batchCall
+	timer   *time.Timer
+	running sync.WaitGroup // Batches submitted and not yet finished
+}
+
+// batchCall is one chat completion waiting in a batch
+type batchCall struct {
+	id   string // custom_id of its line
+	body json.RawMessage
+	done chan batchResult // Buffered, so a caller that gave up doesn't block delivery
+}
+
+type batchResult struct {
+	response *CompletionResponse
+	err      error
+}
+
+// batchLine is one request of a batch input file
+type batchLine struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// batchOutput is one line of a batch output or error file
+type batchOutput struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int             `json:"status_code"`
+		Body       json.RawMessage `json:"body"`
+	} `json:"response"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func newOpenAIBatcher(client *openai.Client) *openAIBatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &openAIBatcher{client: client, ctx: ctx, cancel: cancel}
+}
+
+// complete adds a chat completion to the next batch and waits for its
+// result. extra holds body fields go-openai doesn't know, such as
+// reasoning_effort. While it waits, a queued job's worker is free for
+// other jobs.
+func (b *openAIBatcher) complete(ctx context.Context, chatRequest openai.ChatCompletionRequest, extra map[string]interface{}) (*CompletionResponse, error) {
+	body, err := batchBody(chatRequest, extra)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	call := &batchCall{id: hex.EncodeToString(id), body: body, done: make(chan batchResult, 1)}
+	if err := b.add(call); err != nil {
+		return nil, err
+	}
+
+	batchStats.Add("waiting", 1)
+	defer batchStats.Add("waiting", -1)
+	resume := jobs.Yield(ctx)
+	defer resume()
+
+	select {
+	case result := <-call.done:
+		return result.response, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// batchBody encodes a chat completion request with extra fields merged in
+func batchBody(chatRequest openai.ChatCompletionRequest, extra map[string]interface{}) (json.RawMessage, error) {
+	data, err := json.Marshal(chatRequest)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("failed to add request fields: %w", err)
+	}
+	for name, value := range extra {
+		if body[name], err = json.Marshal(value); err != nil {
+			return nil, fmt.Errorf("failed to add request field %s: %w", name, err)
+		}
+	}
+	return json.Marshal(body)
+}
+
+// add queues a call for the next batch, sending the batch once it is full
+func (b *openAIBatcher) add(call *batchCall) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.ctx.Err() != nil {
+		return fmt.Errorf("OpenAI batch client is closed")
+	}
+
+	b.pending = append(b.pending, call)
+	if len(b.pending) >= batchMaxCalls {
+		if b.timer != nil {
+			b.timer.Stop()
+			b.timer = nil
+		}
+		calls := b.pending
+		b.pending = nil
+		b.running.Add(1)
+		go b.run(calls)
+		return nil
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(batchWindow, b.flush)
+	}
+	return nil
+}
+
+// flush sends the calls collected during the window
+func (b *openAIBatcher) flush() {
+	b.mu.Lock()
+	calls := b.pending
+	b.pending = nil
+	b.timer = nil
+	if len(calls) == 0 || b.ctx.Err() != nil {
+		b.mu.Unlock()
+		return
+	}
+	b.running.Add(1)
+	b.mu.Unlock()
+
+	b.run(calls)
+}
+
+// run submits calls as one batch, waits for it and hands each call its
+// result. Calls without one fail.
+func (b *openAIBatcher) run(calls []*batchCall) {
+	defer b.running.Done()
+	results, err := b.submit(calls)
+	for _, call := range calls {
+		result, ok := results[call.id]
+		switch {
+		case ok:
+		case err != nil:
+			result.err = err
+		default:
+			result.err = fmt.Errorf("OpenAI batch returned no result for the call")
+		}
+		if result.err != nil {
+			batchStats.Add("calls_failed", 1)
+		} else {
+			batchStats.Add("calls_completed", 1)
+		}
+		call.done <- result
+	}
+}
+
+// submit uploads the calls, creates their batch and polls it until OpenAI
+// finishes it, returning the results by call ID. Once the batch exists,
+// its files are deleted when done with.
+func (b *openAIBatcher) submit(calls []*batchCall) (map[string]batchResult, error) {
+	var input bytes.Buffer
+	for _, call := range calls {
+		line, err := json.Marshal(batchLine{CustomID: call.id, Method: "POST", URL: string(openai.BatchEndpointChatCompletions), Body: call.body})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode batch request: %w", err)
+		}
+		input.Write(line)
+		input.WriteByte('\n')
+	}
+
+	file, err := b.client.CreateFileBytes(b.ctx, openai.FileBytesRequest{Name: "reviews.jsonl", Bytes: input.Bytes(), Purpose: openai.PurposeBatch})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload batch input: %w", err)
+	}
+	defer b.deleteFile(file.ID)
+	batch, err := b.client.CreateBatch(b.ctx, openai.CreateBatchRequest{
+		InputFileID:      file.ID,
+		Endpoint:         openai.BatchEndpointChatCompletions,
+		CompletionWindow: batchCompletionWindow,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create batch: %w", err)
+	}
+	batchStats.Add("batches_submitted", 1)
+	batchStats.Add("calls_submitted", int64(len(calls)))
+	log.Printf("OpenAI batch %s submitted with %d calls", batch.ID, len(calls))
+
+	ticker := time.NewTicker(batchPollInterval)
+	defer ticker.Stop()
+	for !batchFinished(batch.Status) {
+		select {
+		case <-ticker.C:
+		case <-b.ctx.Done():
+			// The waiting reviews fail or are queued again, so don't pay
+			// for a batch no one will read
+			b.cancelBatch(batch.ID)
+			return nil, fmt.Errorf("OpenAI batch %s abandoned: gateway stopping", batch.ID)
+		}
+		retrieved, err := b.client.RetrieveBatch(b.ctx, batch.ID)
+		if err != nil {
+			log.Printf("Warning: failed to check OpenAI batch %s: %v", batch.ID, err)
+			continue
+		}
+		batch.Batch = retrieved.Batch
+	}
+	log.Printf("OpenAI batch %s %s: %d of %d calls completed", batch.ID, batch.Status, batch.RequestCounts.Completed, batch.RequestCounts.Total)
+	batchStats.Add("batches_"+batch.Status, 1)
+
+	results := make(map[string]batchResult, len(calls))
+	for _, fileID := range []*string{batch.OutputFileID, batch.ErrorFileID} {
+		if fileID == nil || *fileID == "" {
+			continue
+		}
+		if err := b.readResults(*fileID, results); err != nil {
+			return results, err
+		}
+		b.deleteFile(*fileID)
+	}
+	if batch.Status != "completed" && len(results) == 0 {
+		return nil, fmt.Errorf("OpenAI batch %s %s", batch.ID, batch.Status)
+	}
+	return results, nil
+}
+
+// batchFinished reports whether a batch status is final
+func batchFinished(status string) bool {
+	switch status {
+	case "completed", "failed", "expired", "cancelled":
+		return true
+	}
+	return false
+}
+
+// readResults adds the results of a batch output or error file
+func (b *openAIBatcher) readResults(fileID string, results map[string]batchResult) error {
+	content, err := b.client.GetFileContent(b.ctx, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to read batch results: %w", err)
+	}
+	defer content.Close()
+
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var line batchOutput
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return fmt.Errorf("failed to parse batch results: %w", err)
+		}
+		results[line.CustomID] = batchLineResult(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read batch results: %w", err)
+	}
+	return nil
+}
+
+// batchLineResult converts one line of batch results
+func batchLineResult(line batchOutput) batchResult {
+	if line.Error != nil {
+		return batchResult{err: fmt.Errorf("batch request failed: %s: %s", line.Error.Code, line.Error.Message)}
+	}
+	if line.Response == nil {
+		return batchResult{err: fmt.Errorf("batch request returned no response")}
+	}
+	if line.Response.StatusCode != 200 {
+		var body struct {
+			Error openai.APIError `json:"error"`
+		}
+		json.Unmarshal(line.Response.Body, &body)
+		body.Error.HTTPStatusCode = line.Response.StatusCode
+		return batchResult{err: fmt.Errorf("failed to create chat completion: %w", &body.Error)}
+	}
+
+	var resp openai.ChatCompletionResponse
+	if err := json.Unmarshal(line.Response.Body, &resp); err != nil {
+		return batchResult{err: fmt.Errorf("failed to parse batch response: %w", err)}
+	}
+	response, err := chatCompletion(resp)
+	if response != nil {
+		response.Batched = true
+	}
+	return batchResult{response: response, err: err}
+}
+
+// deleteFile removes a batch file from the account, logging failures
+func (b *openAIBatcher) deleteFile(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := b.client.DeleteFile(ctx, id); err != nil {
+		log.Printf("Warning: failed to delete OpenAI batch file %s: %v", id, err)
+	}
+}
+
+// cancelBatch asks OpenAI to stop a batch, logging failures
+func (b *openAIBatcher) cancelBatch(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := b.client.CancelBatch(ctx, id); err != nil {
+		log.Printf("Warning: failed to cancel OpenAI batch %s: %v", id, err)
+	}
+}
+
+// Close stops polling and cancels the batches in flight, waiting until
+// OpenAI is told; calls waiting for a batch fail
+func (b *openAIBatcher) Close() error {
+	b.mu.Lock()
+	b.cancel()
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	calls := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	for _, call := range calls {
+		batchStats.Add("calls_failed", 1)
+		call.done <- batchResult{err: fmt.Errorf("OpenAI batch client is closed")}
+	}
+	b.running.Wait()
+	return nil
+}
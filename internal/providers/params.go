@@ -0,0 +1,64 @@
+package providers
+
+import (
+	"fmt"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// maxOutputTokens bounds the max_tokens a request may ask for
+const maxOutputTokens = 128000
+
+// Reasoning effort levels accepted by reasoning models
+const (
+	ReasoningLow    = "low"
+	ReasoningMedium = "medium"
+	ReasoningHigh   = "high"
+)
+
+// paramChecker is implemented by providers that accept only some model
+// parameters, or narrower ranges
+type paramChecker interface {
+	CheckParams(model string, params models.ModelParams) error
+}
+
+// CheckParams validates a request's model parameters, first against the
+// ranges every provider accepts and then against the provider's own rules
+func (r *Registry) CheckParams(provider, model string, params models.ModelParams) error {
+	if params.Temperature != nil && (*params.Temperature < 0 || *params.Temperature > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if params.TopP != nil && (*params.TopP <= 0 || *params.TopP > 1) {
+		return fmt.Errorf("top_p must be above 0 and at most 1")
+	}
+	if params.MaxTokens < 0 || params.MaxTokens > maxOutputTokens {
+		return fmt.Errorf("max_tokens must be between 1 and %d", maxOutputTokens)
+	}
+	switch params.ReasoningEffort {
+	case "", ReasoningLow, ReasoningMedium, ReasoningHigh:
+	default:
+		return fmt.Errorf("reasoning_effort must be low, medium or high")
+	}
+
+	p, err := r.Get(provider)
+	if err != nil {
+		return err
+	}
+	if checker, ok := p.(paramChecker); ok {
+		return checker.CheckParams(model, params)
+	}
+	return nil
+}
+
+// applyParams overrides the gateway's generation defaults with the
+// request's model parameters
+func applyParams(request *CompletionRequest, params models.ModelParams) {
+	if params.Temperature != nil {
+		request.Temperature = *params.Temperature
+	}
+	if params.MaxTokens > 0 {
+		request.MaxTokens = params.MaxTokens
+	}
+	request.TopP = params.TopP
+	request.ReasoningEffort = params.ReasoningEffort
+}
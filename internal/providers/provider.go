@@ -2,21 +2,306 @@ package providers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
 
 	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/pricing"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
 )
 
 // AIProvider defines the interface for AI providers
 type AIProvider interface {
 	Review(ctx context.Context, request *models.ReviewRequest) (*models.AIProviderResponse, error)
+	Complete(ctx context.Context, request *CompletionRequest) (*CompletionResponse, error)
 	Name() string
 	SupportedModels() []string
+	DefaultModel() string // Used when a request doesn't name a model
+}
+
+// Embedder is implemented by providers that can create text embeddings
+type Embedder interface {
+	// Embed returns one vector per text. An empty model selects the
+	// provider's default embedding model.
+	Embed(ctx context.Context, model string, texts []string) ([][]float32, error)
+}
+
+// CompletionRequest is a raw prompt sent to a provider. It lets features
+// other than code review reuse the provider abstraction.
+type CompletionRequest struct {
+	Model           string // Empty selects the provider's default model
+	SystemPrompt    string
+	UserPrompt      string
+	MaxTokens       int
+	Temperature     float32
+	TopP            *float32 // Nil uses the provider's default
+	ReasoningEffort string   // low, medium or high; empty uses the model's default
+
+	// SharedContext, sent ahead of UserPrompt, is context repeated across
+	// calls, such as guidelines and full files, that the provider may
+	// cache. Only set for providers that cache context.
+	SharedContext string
+
+	// Stream, when set, asks the provider to stream the response and is
+	// called with the text received so far as it grows
+	Stream func(text string)
+}
+
+// CompletionResponse is the text returned for a CompletionRequest along
+// with usage reported by the provider
+type CompletionResponse struct {
+	Text             string
+	PromptTokens     int
+	CompletionTokens int
+	Truncated        bool // Output stopped at the max token limit
+	Batched          bool // Answered through a batch API, at its discount
+	CachedTokens     int  // Prompt tokens read from the provider's context cache
+}
+
+// review runs a code review through a provider's Complete method
+func review(ctx context.Context, provider AIProvider, request *models.ReviewRequest, defaultMaxTokens int) (*models.AIProviderResponse, error) {
+	completionRequest := &CompletionRequest{
+		Model:        request.AIModel,
+		SystemPrompt: prompt.GenerateSystemPrompt(request.Language, request.ReviewMode, request.Categories, request.PromptVersion),
+		MaxTokens:    prompt.MaxOutputTokens(request.ReviewMode, defaultMaxTokens),
+		Temperature:  0.3,
+	}
+	if CachesContext(provider) {
+		completionRequest.SharedContext, completionRequest.UserPrompt = prompt.GenerateSharedUserPrompt(request)
+	} else {
+		completionRequest.UserPrompt = prompt.GenerateUserPrompt(request)
+	}
+	applyParams(completionRequest, request.ModelParams)
+
+	// Parse issues as they stream in, so a response that is cut off still
+	// yields the ones it finished
+	partial := &prompt.PartialParser{}
+	completionRequest.Stream = partial.Update
+
+	completion, promptBytes, err := completeContinued(ctx, provider, completionRequest, partial)
+	if err != nil {
+		response, ok := partial.Response()
+		if !ok {
+			return nil, err
+		}
+		log.Printf("Warning: response interrupted, returning the %d issues received: %v", len(response.Diagnostics), err)
+		// The provider's token counts come with the end of the response, so
+		// charge an estimate of what the interrupted call used
+		text := partial.Text()
+		response.Usage = models.Usage{
+			PromptBytes:      promptBytes,
+			ResponseBytes:    len(text),
+			PromptTokens:     estimatePromptTokens(completionRequest),
+			CompletionTokens: pricing.EstimateTokens(text),
+			Truncated:        true,
+		}
+		return response, nil
+	}
+
+	// Parse the response, keeping the finished issues of one cut off by the
+	// token limit rather than falling back to the free-text parser
+	response, err := prompt.ParseAIResponse(completion.Text)
+	if completion.Truncated && (err != nil || response.ParserPath == prompt.ParserUnstructured) {
+		if recovered, ok := prompt.ParsePartial(completion.Text); ok {
+			response, err = recovered, nil
+		}
+	}
+	usage := models.Usage{
+		PromptBytes:      promptBytes,
+		ResponseBytes:    len(completion.Text),
+		PromptTokens:     completion.PromptTokens,
+		CompletionTokens: completion.CompletionTokens,
+		Truncated:        completion.Truncated,
+		Batched:          completion.Batched,
+		CachedTokens:     completion.CachedTokens,
+	}
+
+	// Ask the model to fix output that isn't valid JSON before settling for
+	// the free-text parser
+	if !completion.Truncated && (err != nil || response.ParserPath == prompt.ParserUnstructured) && jsonRepairRetry.Load() {
+		if fixed, ok := repromptJSON(ctx, provider, completionRequest, completion.Text, err, &usage); ok {
+			response, err = fixed, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	response.Usage = usage
+
+	// Critique only complete reviews; a partial one keeps its marker
+	if prompt.NormalizeMode(request.ReviewMode) == prompt.ModeRefined && len(response.Diagnostics) > 0 && response.ParserPath != prompt.ParserPartial {
+		return refine(ctx, provider, request, response, defaultMaxTokens), nil
+	}
+	return response, nil
+}
+
+// maxContinuations is the number of follow-up requests made to finish a
+// response cut off by the token limit
+var maxContinuations atomic.Int32
+
+// SetMaxContinuations sets how many follow-up requests may be made to finish
+// a response cut off by the token limit; zero disables continuation
+func SetMaxContinuations(n int) {
+	maxContinuations.Store(int32(n))
+}
+
+// completeContinued sends a completion request and, while the output stops
+// at the token limit, asks the model to carry on from where it stopped. The
+// fragments are stitched into one response with the usage of every call,
+// which stays marked truncated if the last one was too. It also returns
+// the bytes of prompt sent. A failed continuation ends the attempt and
+// returns the text received so far.
+func completeContinued(ctx context.Context, provider AIProvider, request *CompletionRequest, partial *prompt.PartialParser) (*CompletionResponse, int, error) {
+	promptBytes := len(request.SystemPrompt) + len(request.SharedContext) + len(request.UserPrompt)
+	completion, err := provider.Complete(ctx, request)
+	if err != nil {
+		return nil, promptBytes, err
+	}
+
+	limit := int(maxContinuations.Load())
+	for n := 1; completion.Truncated && n <= limit && ctx.Err() == nil; n++ {
+		text := completion.Text
+		next := *request
+		next.UserPrompt = prompt.GenerateContinuationPrompt(request.UserPrompt, text)
+		next.Stream = func(more string) {
+			partial.Update(prompt.JoinContinuation(text, more))
+		}
+		log.Printf("Response truncated at %d bytes, requesting continuation %d of %d", len(text), n, limit)
+
+		promptBytes += len(next.SystemPrompt) + len(next.SharedContext) + len(next.UserPrompt)
+		more, err := provider.Complete(ctx, &next)
+		if err != nil {
+			log.Printf("Warning: continuation failed, keeping the truncated response: %v", err)
+			// Charge an estimate of a continuation interrupted mid-stream
+			if received := pricing.EstimateTokens(partial.Text()) - pricing.EstimateTokens(text); received > 0 {
+				completion.PromptTokens += estimatePromptTokens(&next)
+				completion.CompletionTokens += received
+			}
+			break
+		}
+		completion = &CompletionResponse{
+			Text:             prompt.JoinContinuation(text, more.Text),
+			PromptTokens:     completion.PromptTokens + more.PromptTokens,
+			CompletionTokens: completion.CompletionTokens + more.CompletionTokens,
+			Truncated:        more.Truncated,
+			Batched:          completion.Batched,
+			CachedTokens:     completion.CachedTokens + more.CachedTokens,
+		}
+	}
+	return completion, promptBytes, nil
+}
+
+// estimatePromptTokens approximates the prompt tokens of a request, for
+// calls whose reported usage was lost
+func estimatePromptTokens(request *CompletionRequest) int {
+	return pricing.EstimateTokens(request.SystemPrompt) + pricing.EstimateTokens(request.SharedContext) + pricing.EstimateTokens(request.UserPrompt)
+}
+
+// jsonRepairRetry enables asking the model to fix a response that isn't
+// valid JSON
+var jsonRepairRetry atomic.Bool
+
+// SetJSONRepairRetry sets whether a response that can't be parsed as JSON,
+// even after repair, is sent back to the model to be fixed
+func SetJSONRepairRetry(enabled bool) {
+	jsonRepairRetry.Store(enabled)
+}
+
+// repromptJSON sends a malformed response back to the model with the
+// original system prompt, asking for it as valid JSON, and adds the call to
+// usage. It returns false if the call fails or its output doesn't parse
+// either.
+func repromptJSON(ctx context.Context, provider AIProvider, request *CompletionRequest, text string, parseErr error, usage *models.Usage) (*models.AIProviderResponse, bool) {
+	retry := *request
+	retry.UserPrompt = prompt.GenerateJSONRepairPrompt(text, parseErr)
+	retry.SharedContext = ""
+	retry.Temperature = 0
+	retry.Stream = nil
+
+	completion, err := provider.Complete(ctx, &retry)
+	if err != nil {
+		log.Printf("Warning: JSON repair request failed: %v", err)
+		return nil, false
+	}
+	usage.PromptBytes += len(retry.SystemPrompt) + len(retry.UserPrompt)
+	usage.ResponseBytes += len(completion.Text)
+	usage.PromptTokens += completion.PromptTokens
+	usage.CachedTokens += completion.CachedTokens
+	usage.CompletionTokens += completion.CompletionTokens
+
+	response, err := prompt.ParseAIResponse(completion.Text)
+	if err != nil || response.ParserPath == prompt.ParserUnstructured || completion.Truncated {
+		log.Printf("Warning: JSON repair request returned unusable output")
+		return nil, false
+	}
+	log.Printf("Malformed JSON response fixed by the model")
+	response.ParserPath = prompt.ParserReprompted
+	return response, true
+}
+
+// refine runs the self-critique pass of a refined review. If the critique
+// fails the first-pass findings are returned unchanged.
+func refine(ctx context.Context, provider AIProvider, request *models.ReviewRequest, firstPass *models.AIProviderResponse, defaultMaxTokens int) *models.AIProviderResponse {
+	critiqueRequest := &CompletionRequest{
+		Model:        request.AIModel,
+		SystemPrompt: prompt.GenerateCritiqueSystemPrompt(request.Language),
+		UserPrompt:   prompt.GenerateCritiqueUserPrompt(request, firstPass),
+		MaxTokens:    defaultMaxTokens,
+		Temperature:  0.1,
+	}
+	// The critique keeps its low temperature; other settings still apply
+	params := request.ModelParams
+	params.Temperature = nil
+	applyParams(critiqueRequest, params)
+
+	completion, err := provider.Complete(ctx, critiqueRequest)
+	if err != nil {
+		log.Printf("Warning: critique pass failed, returning first-pass findings: %v", err)
+		return firstPass
+	}
+
+	usage := firstPass.Usage
+	usage.PromptBytes += len(critiqueRequest.SystemPrompt) + len(critiqueRequest.UserPrompt)
+	usage.ResponseBytes += len(completion.Text)
+	usage.PromptTokens += completion.PromptTokens
+	usage.CompletionTokens += completion.CompletionTokens
+	usage.Truncated = usage.Truncated || completion.Truncated
+
+	firstPass.Usage = usage
+	if completion.Truncated {
+		log.Printf("Warning: critique response truncated, returning first-pass findings")
+		return firstPass
+	}
+	refined, err := prompt.ParseCritiqueResponse(completion.Text)
+	if err != nil {
+		log.Printf("Warning: unusable critique response, returning first-pass findings: %v", err)
+		return firstPass
+	}
+
+	log.Printf("Critique pass kept %d of %d findings", len(refined.Diagnostics), len(firstPass.Diagnostics))
+	if refined.Overview == "" {
+		refined.Overview = firstPass.Overview
+	}
+	refined.ParserPath = firstPass.ParserPath
+	refined.Usage = usage
+	refined.Schema = firstPass.Schema.Add(refined.Schema)
+	return refined
 }
 
 // Registry manages AI providers
 type Registry struct {
-	providers map[string]AIProvider
+	mu              sync.RWMutex
+	providers       map[string]AIProvider
+	aliases         map[string]Alias
+	defaultProvider string
+	defaultModel    string
+	allowUnlisted   bool
+	health          healthTracker
+	latency         latencyTracker
 }
 
 // NewRegistry creates a new provider registry
@@ -28,11 +313,15 @@ func NewRegistry() *Registry {
 
 // Register adds a provider to the registry
 func (r *Registry) Register(name string, provider AIProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.providers[name] = provider
 }
 
 // Get retrieves a provider by name
 func (r *Registry) Get(name string) (AIProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	provider, ok := r.providers[name]
 	if !ok {
 		return nil, fmt.Errorf("provider '%s' not found", name)
@@ -40,8 +329,37 @@ func (r *Registry) Get(name string) (AIProvider, error) {
 	return provider, nil
 }
 
+// ReportResult records the outcome of a request to a provider; err is nil
+// on success
+func (r *Registry) ReportResult(name string, err error) {
+	r.health.record(name, err)
+}
+
+// Health returns a provider's status derived from its recent requests
+func (r *Registry) Health(name string) Health {
+	return r.health.get(name)
+}
+
+// Close releases the clients of every registered provider
+func (r *Registry) Close() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var errs []error
+	for name, provider := range r.providers {
+		if c, ok := provider.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close provider %s: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // List returns all registered provider names
 func (r *Registry) List() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	names := make([]string, 0, len(r.providers))
 	for name := range r.providers {
 		names = append(names, name)
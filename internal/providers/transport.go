@@ -0,0 +1,129 @@
+package providers
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// httpTransport carries the HTTP requests of providers built after it is
+// set; nil uses baseTransport
+var httpTransport http.RoundTripper
+
+// baseTransport and httpTimeout are the tuning set by ConfigureHTTP; nil
+// uses http.DefaultTransport
+var (
+	baseTransport http.RoundTripper
+	httpTimeout   time.Duration
+)
+
+// HTTPConfig tunes the HTTP client providers share
+type HTTPConfig struct {
+	Timeout             time.Duration // Whole exchange, streamed body included; zero means none
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+	IdleConnTimeout     time.Duration // How long an unused connection is kept open
+	MaxIdleConnsPerHost int
+	DisableHTTP2        bool
+}
+
+// ConfigureHTTP tunes the HTTP client of providers built afterwards and
+// returns its transport, for a transport set with SetHTTPTransport to
+// send traffic on. Every provider shares the transport's connections.
+// The Gemini SDK keeps its own client.
+func ConfigureHTTP(cfg HTTPConfig) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	transport.IdleConnTimeout = cfg.IdleConnTimeout
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	if cfg.DisableHTTP2 {
+		// A non-nil empty map turns HTTP/2 off
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	baseTransport = transport
+	httpTimeout = cfg.Timeout
+	return transport
+}
+
+// SetHTTPTransport routes the HTTP traffic of providers built afterwards
+// through rt, e.g. to record or replay it. It must be called before the
+// providers are registered. Gemini providers then use the REST API, as the
+// SDK's traffic can't be routed.
+func SetHTTPTransport(rt http.RoundTripper) {
+	httpTransport = rt
+}
+
+// newHTTPClient creates the HTTP client of a provider
+func newHTTPClient() *http.Client {
+	transport := httpTransport
+	if transport == nil {
+		transport = baseTransport
+	}
+	return &http.Client{Transport: transport, Timeout: httpTimeout}
+}
+
+// ProxyDirect as a provider's proxy connects to it directly, ignoring
+// HTTPS_PROXY
+const ProxyDirect = "direct"
+
+// proxyTransports holds one transport per proxy, so the clients of a
+// provider's keys share connections
+var (
+	proxyMu         sync.Mutex
+	proxyTransports = make(map[string]http.RoundTripper)
+)
+
+// parseProxy checks a proxy URL: http, https or socks5, with a host
+func parseProxy(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %s: the scheme must be http, https or socks5", u.Redacted())
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %s: no host", u.Redacted())
+	}
+	return u, nil
+}
+
+// newProxiedHTTPClient creates the HTTP client of a provider whose calls
+// go through proxy, a URL or ProxyDirect; an empty proxy uses HTTPS_PROXY.
+// Traffic routed with SetHTTPTransport ignores the proxy.
+func newProxiedHTTPClient(proxy string) (*http.Client, error) {
+	if proxy == "" || httpTransport != nil {
+		return newHTTPClient(), nil
+	}
+	var proxyFunc func(*http.Request) (*url.URL, error) // nil connects directly
+	if proxy != ProxyDirect {
+		u, err := parseProxy(proxy)
+		if err != nil {
+			return nil, err
+		}
+		proxyFunc = http.ProxyURL(u)
+	}
+
+	proxyMu.Lock()
+	defer proxyMu.Unlock()
+	transport, ok := proxyTransports[proxy]
+	if !ok {
+		base, _ := baseTransport.(*http.Transport)
+		if base == nil {
+			base = http.DefaultTransport.(*http.Transport)
+		}
+		clone := base.Clone()
+		clone.Proxy = proxyFunc
+		transport = clone
+		proxyTransports[proxy] = transport
+	}
+	return &http.Client{Transport: transport, Timeout: httpTimeout}, nil
+}
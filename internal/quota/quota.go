@@ -0,0 +1,175 @@
+// Package quota tracks token and cost usage per billing period and
+// enforces monthly limits
+package quota
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Limit is the usage allowed per billing period. Zero fields are unlimited.
+type Limit struct {
+	Tokens  int64   `json:"tokens,omitempty" yaml:"tokens"`     // Prompt plus completion tokens
+	CostUSD float64 `json:"cost_usd,omitempty" yaml:"cost_usd"` // Estimated cost of priced models
+}
+
+// IsZero reports whether the limit allows unlimited usage
+func (l Limit) IsZero() bool {
+	return l.Tokens <= 0 && l.CostUSD <= 0
+}
+
+// Usage is what an account consumed in one billing period
+type Usage struct {
+	Period  string  `json:"period"` // YYYY-MM, UTC
+	Tokens  int64   `json:"tokens"`
+	CostUSD float64 `json:"cost_usd"`
+}
+
+// Status reports an account's usage against its limit
+type Status struct {
+	Account          string   `json:"account"`
+	Period           string   `json:"period"`
+	ResetsAt         string   `json:"resets_at"`
+	Limit            Limit    `json:"limit"`
+	Used             Usage    `json:"used"`
+	RemainingTokens  *int64   `json:"remaining_tokens,omitempty"`
+	RemainingCostUSD *float64 `json:"remaining_cost_usd,omitempty"`
+	Exhausted        bool     `json:"exhausted"`
+}
+
+// Tracker records usage per account. With a path, usage is persisted so
+// restarts don't reset quotas.
+type Tracker struct {
+	mu    sync.Mutex
+	path  string
+	usage map[string]*Usage
+	now   func() time.Time
+}
+
+// NewTracker creates a tracker persisting to path; an empty path keeps
+// usage in memory
+func NewTracker(path string) (*Tracker, error) {
+	t := &Tracker{path: path, usage: make(map[string]*Usage), now: time.Now}
+	if path == "" {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quota usage: %w", err)
+	}
+	if err := json.Unmarshal(data, &t.usage); err != nil {
+		return nil, fmt.Errorf("failed to parse quota usage %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Record adds usage to each account's current period
+func (t *Tracker) Record(accounts []string, tokens int, costUSD float64) {
+	if len(accounts) == 0 || (tokens == 0 && costUSD == 0) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	period := periodOf(t.now())
+	for _, account := range accounts {
+		usage := t.currentLocked(account, period)
+		usage.Tokens += int64(tokens)
+		usage.CostUSD += costUSD
+		t.usage[account] = usage
+	}
+	if err := t.saveLocked(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// Status reports an account's usage against limit
+func (t *Tracker) Status(account string, limit Limit) Status {
+	t.mu.Lock()
+	now := t.now()
+	usage := *t.currentLocked(account, periodOf(now))
+	t.mu.Unlock()
+
+	status := Status{
+		Account:  account,
+		Period:   usage.Period,
+		ResetsAt: periodEnd(now).Format(time.RFC3339),
+		Limit:    limit,
+		Used:     usage,
+	}
+	if limit.Tokens > 0 {
+		remaining := max(limit.Tokens-usage.Tokens, 0)
+		status.RemainingTokens = &remaining
+		status.Exhausted = remaining == 0
+	}
+	if limit.CostUSD > 0 {
+		remaining := max(limit.CostUSD-usage.CostUSD, 0)
+		status.RemainingCostUSD = &remaining
+		status.Exhausted = status.Exhausted || remaining == 0
+	}
+	return status
+}
+
+// UntilReset returns the time left in the current billing period
+func (t *Tracker) UntilReset() time.Duration {
+	now := t.now()
+	return periodEnd(now).Sub(now)
+}
+
+// currentLocked returns the account's usage in period, starting a new
+// period when the stored one has ended
+func (t *Tracker) currentLocked(account, period string) *Usage {
+	if usage, ok := t.usage[account]; ok && usage.Period == period {
+		return usage
+	}
+	return &Usage{Period: period}
+}
+
+// saveLocked atomically rewrites the usage file
+func (t *Tracker) saveLocked() error {
+	if t.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(t.usage)
+	if err != nil {
+		return fmt.Errorf("failed to encode quota usage: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(t.path), ".quota-*")
+	if err != nil {
+		return fmt.Errorf("failed to save quota usage: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save quota usage: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save quota usage: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), t.path); err != nil {
+		return fmt.Errorf("failed to save quota usage: %w", err)
+	}
+	return nil
+}
+
+// periodOf names the calendar month containing t, in UTC
+func periodOf(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// periodEnd returns the start of the month after t, in UTC
+func periodEnd(t time.Time) time.Time {
+	year, month, _ := t.UTC().Date()
+	return time.Date(year, month+1, 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,214 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scheduling priorities
+const (
+	PriorityLow    = 0
+	PriorityNormal = 1
+	PriorityHigh   = 2
+)
+
+// Tier describes the rate limit and scheduling priority of a class of keys
+type Tier struct {
+	Name              string
+	RequestsPerMinute int // Zero means unlimited
+	Burst             int
+	Priority          int
+}
+
+// DefaultTier is used for keys without an explicit tier when no default
+// tier is configured
+var DefaultTier = Tier{Name: "default", Priority: PriorityNormal}
+
+// bucket is a token bucket for a single client
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter enforces per-client request rates according to each key's tier
+type Limiter struct {
+	mu          sync.Mutex
+	tiers       map[string]Tier
+	defaultTier string
+	buckets     map[string]*bucket
+}
+
+// NewLimiter creates a limiter. Keys without a tier use defaultTier.
+func NewLimiter(tiers []Tier, defaultTier string) (*Limiter, error) {
+	l := &Limiter{
+		buckets: make(map[string]*bucket),
+	}
+	if err := l.Update(tiers, defaultTier); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Update replaces the tiers. Clients keep their buckets, so a reload
+// doesn't refill them.
+func (l *Limiter) Update(tiers []Tier, defaultTier string) error {
+	byName := make(map[string]Tier)
+	for _, t := range tiers {
+		byName[t.Name] = t
+	}
+	if _, ok := byName[defaultTier]; defaultTier != "" && !ok {
+		return fmt.Errorf("unknown default tier %q", defaultTier)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tiers = byName
+	l.defaultTier = defaultTier
+	return nil
+}
+
+// TierFor returns the named tier of a key, or the default tier when the
+// key has none or its tier no longer exists
+func (l *Limiter) TierFor(name string) Tier {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if t, ok := l.tiers[name]; ok && name != "" {
+		return t
+	}
+	if t, ok := l.tiers[l.defaultTier]; ok {
+		return t
+	}
+	return DefaultTier
+}
+
+// Tier returns the named tier
+func (l *Limiter) Tier(name string) (Tier, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t, ok := l.tiers[name]
+	return t, ok
+}
+
+// Allow consumes a token for the client. When the bucket is empty it
+// returns false and how long until the next token is available.
+func (l *Limiter) Allow(clientID string, tier Tier) (bool, time.Duration) {
+	if tier.RequestsPerMinute <= 0 {
+		return true, 0
+	}
+
+	burst := float64(tier.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	rate := float64(tier.RequestsPerMinute) / 60 // tokens per second
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, ok := l.buckets[clientID]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[clientID] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+// ParseTiers parses a tier specification of the form
+// "name=rpm:burst:priority,..." e.g. "interactive=120:20:high,batch=10:2:low".
+// Burst and priority are optional.
+func ParseTiers(spec string) ([]Tier, error) {
+	var tiers []Tier
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, values, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid tier %q: expected name=rpm[:burst[:priority]]", entry)
+		}
+
+		parts := strings.Split(values, ":")
+		tier := Tier{Name: strings.TrimSpace(name), Priority: PriorityNormal}
+
+		rpm, err := strconv.Atoi(parts[0])
+		if err != nil || rpm < 0 {
+			return nil, fmt.Errorf("invalid requests per minute in tier %q", entry)
+		}
+		tier.RequestsPerMinute = rpm
+		tier.Burst = rpm
+
+		if len(parts) > 1 && parts[1] != "" {
+			burst, err := strconv.Atoi(parts[1])
+			if err != nil || burst < 0 {
+				return nil, fmt.Errorf("invalid burst in tier %q", entry)
+			}
+			tier.Burst = burst
+		}
+
+		if len(parts) > 2 {
+			priority, err := ParsePriority(parts[2])
+			if err != nil {
+				return nil, fmt.Errorf("tier %q: %w", entry, err)
+			}
+			tier.Priority = priority
+		}
+
+		tiers = append(tiers, tier)
+	}
+	return tiers, nil
+}
+
+// ParseKeyTiers parses "key=tier,..." into a map, checking that each tier
+// is one of tiers
+func ParseKeyTiers(spec string, tiers []Tier) (map[string]string, error) {
+	keyTiers := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, tier, ok := strings.Cut(entry, "=")
+		if !ok || key == "" || tier == "" {
+			return nil, fmt.Errorf("invalid key tier mapping: expected key=tier")
+		}
+		key, tier = strings.TrimSpace(key), strings.TrimSpace(tier)
+		if !slices.ContainsFunc(tiers, func(t Tier) bool { return t.Name == tier }) {
+			return nil, fmt.Errorf("key %s... references unknown tier %q", key[:min(len(key), 4)], tier)
+		}
+		keyTiers[key] = tier
+	}
+	return keyTiers, nil
+}
+
+// ParsePriority parses low, normal or high
+func ParsePriority(value string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "low":
+		return PriorityLow, nil
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	default:
+		return 0, fmt.Errorf("invalid priority %q: must be low, normal or high", value)
+	}
+}
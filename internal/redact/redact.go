@@ -0,0 +1,197 @@
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/diff"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// rule detects one kind of secret. When group is non-zero only that
+// submatch is replaced, so surrounding syntax (key names, quotes) survives.
+type rule struct {
+	kind  string
+	regex *regexp.Regexp
+	group int
+}
+
+// rules are checked in order; more specific token formats come first so a
+// secret is reported under its most precise type
+var rules = []rule{
+	{kind: "aws-access-key", regex: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{kind: "github-token", regex: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{kind: "slack-token", regex: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{kind: "google-api-key", regex: regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}`)},
+	{kind: "anthropic-api-key", regex: regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_\-]{20,}`)},
+	{kind: "openai-api-key", regex: regexp.MustCompile(`\bsk-(?:proj-)?[A-Za-z0-9_\-]{20,}`)},
+	{kind: "stripe-key", regex: regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{24,}`)},
+	{kind: "jwt", regex: regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{10,}\.eyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}`)},
+	{kind: "url-credentials", regex: regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.\-]*://[^/\s:@]+:([^@\s/]+)@`), group: 1},
+	{kind: "generic-secret", regex: regexp.MustCompile(`(?i)[A-Za-z0-9_.\-]*(?:secret|password|passwd|pwd|token|api[_\-]?key|access[_\-]?key|private[_\-]?key)[A-Za-z0-9_.\-]*["']?\s*(?:=|:=|:)\s*["']([^"'\s]{8,})["']`), group: 1},
+}
+
+var (
+	privateKeyBegin = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)
+	privateKeyEnd   = regexp.MustCompile(`-----END [A-Z ]*PRIVATE KEY-----`)
+	redactedRegex   = regexp.MustCompile(`<redacted:[a-z\-]+:\d+>`)
+)
+
+// redactor hands out stable placeholders: the same secret seen twice gets
+// the same placeholder, so the model can still tell values apart
+type redactor struct {
+	placeholders map[string]string
+	counts       map[string]int
+	redactions   []models.Redaction
+}
+
+// Diff scans a unified diff for API keys, tokens and other credentials and
+// replaces them with placeholders such as <redacted:aws-access-key:1>.
+// Line structure is preserved so diagnostic line numbers remain valid.
+// Text without diff headers is scanned as well; redactions are then
+// reported without a location.
+func Diff(text string) (string, []models.Redaction) {
+	r := &redactor{
+		placeholders: make(map[string]string),
+		counts:       make(map[string]int),
+	}
+
+	lines := strings.Split(text, "\n")
+	var path string
+	oldLine, newLine := 0, 0
+	oldRemaining, newRemaining := 0, 0
+	inPrivateKey := false
+
+	for i, line := range lines {
+		inHunk := oldRemaining > 0 || newRemaining > 0
+
+		// Track the position in the new file for the redaction report
+		var lineNumber int
+		switch {
+		case inHunk && line != "" && line[0] == '+':
+			lineNumber = newLine
+			newLine++
+			newRemaining--
+		case inHunk && line != "" && line[0] == '-':
+			oldLine++
+			oldRemaining--
+		case inHunk && (line == "" || line[0] == ' '):
+			lineNumber = newLine
+			oldLine++
+			newLine++
+			oldRemaining--
+			newRemaining--
+		case strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ "):
+			// Deleted files only have a name on the --- line
+			if name := diff.NormalizePath(line[4:]); name != "/dev/null" {
+				path = name
+			}
+			continue
+		case strings.HasPrefix(line, "@@"):
+			if hunk, ok := diff.ParseHunkHeader(line); ok {
+				oldLine, newLine = hunk.OldStart, hunk.NewStart
+				oldRemaining, newRemaining = hunk.OldLines, hunk.NewLines
+			}
+			inPrivateKey = false
+			continue
+		case strings.HasPrefix(line, "diff --git "):
+			inPrivateKey = false
+			continue
+		}
+
+		// Private key bodies span several lines; blank out everything
+		// between the armour lines
+		if inPrivateKey || privateKeyBegin.MatchString(line) {
+			prefix := ""
+			if inHunk && line != "" {
+				prefix = line[:1]
+			}
+			if !inPrivateKey {
+				lines[i] = privateKeyBegin.ReplaceAllStringFunc(line, func(match string) string {
+					return r.placeholder("private-key", match, path, lineNumber)
+				})
+				inPrivateKey = !privateKeyEnd.MatchString(line)
+				continue
+			}
+			if privateKeyEnd.MatchString(line) {
+				inPrivateKey = false
+			}
+			lines[i] = prefix
+			continue
+		}
+
+		lines[i] = r.line(line, path, lineNumber)
+	}
+
+	return strings.Join(lines, "\n"), r.redactions
+}
+
+// line applies every rule to a single line
+func (r *redactor) line(line, path string, lineNumber int) string {
+	for _, rl := range rules {
+		if !rl.regex.MatchString(line) {
+			continue
+		}
+		if rl.group == 0 {
+			line = rl.regex.ReplaceAllStringFunc(line, func(match string) string {
+				if redactedRegex.MatchString(match) {
+					return match
+				}
+				return r.placeholder(rl.kind, match, path, lineNumber)
+			})
+			continue
+		}
+
+		var builder strings.Builder
+		last := 0
+		for _, loc := range rl.regex.FindAllStringSubmatchIndex(line, -1) {
+			start, end := loc[2*rl.group], loc[2*rl.group+1]
+			if start < 0 || !looksSecret(line[start:end]) {
+				continue
+			}
+			builder.WriteString(line[last:start])
+			builder.WriteString(r.placeholder(rl.kind, line[start:end], path, lineNumber))
+			last = end
+		}
+		builder.WriteString(line[last:])
+		line = builder.String()
+	}
+	return line
+}
+
+// placeholder returns the placeholder for a secret and records the redaction
+func (r *redactor) placeholder(kind, secret, path string, lineNumber int) string {
+	placeholder, ok := r.placeholders[secret]
+	if !ok {
+		r.counts[kind]++
+		placeholder = fmt.Sprintf("<redacted:%s:%d>", kind, r.counts[kind])
+		r.placeholders[secret] = placeholder
+	}
+	r.redactions = append(r.redactions, models.Redaction{
+		Type:        kind,
+		Placeholder: placeholder,
+		Path:        path,
+		Line:        lineNumber,
+	})
+	return placeholder
+}
+
+// looksSecret filters out values that are obviously not literal secrets,
+// such as environment lookups, template variables and earlier placeholders
+func looksSecret(value string) bool {
+	if redactedRegex.MatchString(value) {
+		return false
+	}
+	switch value[0] {
+	case '$', '<', '{', '%':
+		return false
+	}
+	lower := strings.ToLower(value)
+	for _, placeholder := range []string{"changeme", "example", "placeholder", "your_", "your-", "xxxx", "****"} {
+		if strings.Contains(lower, placeholder) {
+			return false
+		}
+	}
+	return true
+}
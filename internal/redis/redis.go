@@ -0,0 +1,270 @@
+// Package redis is a minimal Redis client speaking RESP, for the few
+// commands the gateway's shared state needs
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTimeout bounds commands whose context has no deadline
+const defaultTimeout = 10 * time.Second
+
+// maxIdle is the number of connections kept open between commands
+const maxIdle = 8
+
+// Nil is returned by Do when Redis answers with a null reply
+var Nil = errors.New("redis: nil")
+
+// Error is an error reply from the server
+type Error string
+
+func (e Error) Error() string { return string(e) }
+
+// Client sends commands over a small pool of connections
+type Client struct {
+	addr     string
+	username string
+	password string
+	db       int
+	tls      *tls.Config
+	idle     chan *conn
+}
+
+// conn is one connection
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// New creates a client for redis://[user:password@]host:port/db, or
+// rediss:// for TLS, and checks the server answers
+func New(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid Redis URL: expected redis://host:port/db or rediss://")
+	}
+	c := &Client{addr: u.Host, idle: make(chan *conn, maxIdle)}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.Scheme == "rediss" {
+		c.tls = &tls.Config{ServerName: u.Hostname()}
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid Redis database %q", db)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+	if _, err := c.Do(ctx, "PING"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Do sends a command and returns its reply: a string, an int64, a slice of
+// replies, or Nil
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultTimeout)
+	}
+	cn.SetDeadline(deadline)
+
+	reply, err := cn.do(args)
+	var replyErr Error
+	if err != nil && !errors.Is(err, Nil) && !errors.As(err, &replyErr) {
+		// The connection's state is unknown after a network error
+		cn.Close()
+		return nil, fmt.Errorf("redis %s: %w", args[0], err)
+	}
+	c.put(cn)
+	return reply, err
+}
+
+// Close closes the idle connections
+func (c *Client) Close() error {
+	for {
+		select {
+		case cn := <-c.idle:
+			cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// get takes an idle connection or opens one
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: defaultTimeout}
+	var nc net.Conn
+	var err error
+	if c.tls != nil {
+		nc, err = (&tls.Dialer{NetDialer: dialer, Config: c.tls}).DialContext(ctx, "tcp", c.addr)
+	} else {
+		nc, err = dialer.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis at %s: %w", c.addr, err)
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	cn.SetDeadline(time.Now().Add(defaultTimeout))
+
+	if c.password != "" {
+		auth := []string{"AUTH", c.password}
+		if c.username != "" {
+			auth = []string{"AUTH", c.username, c.password}
+		}
+		if _, err := cn.do(auth); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("Redis authentication failed: %w", err)
+		}
+	}
+	if c.db != 0 {
+		if _, err := cn.do([]string{"SELECT", strconv.Itoa(c.db)}); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("failed to select Redis database %d: %w", c.db, err)
+		}
+	}
+	return cn, nil
+}
+
+// put returns a connection to the pool, closing it when the pool is full
+func (c *Client) put(cn *conn) {
+	select {
+	case c.idle <- cn:
+	default:
+		cn.Close()
+	}
+}
+
+// do writes a command and reads its reply
+func (cn *conn) do(args []string) (interface{}, error) {
+	fmt.Fprintf(cn.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(cn.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, err
+	}
+	return cn.read()
+}
+
+// read parses one reply
+func (cn *conn) read() (interface{}, error) {
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed reply %q", line)
+		}
+		if size < 0 {
+			return nil, Nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(cn.r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("malformed reply %q", line)
+		}
+		if count < 0 {
+			return nil, Nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = cn.read(); err != nil && !errors.Is(err, Nil) {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply %q", line)
+}
+
+// String converts a reply to a string
+func String(reply interface{}, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	s, ok := reply.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected reply type %T", reply)
+	}
+	return s, nil
+}
+
+// Int converts a reply to an integer
+func Int(reply interface{}, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected reply type %T", reply)
+	}
+	return n, nil
+}
+
+// Strings converts an array reply to strings
+func Strings(reply interface{}, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected reply type %T", reply)
+	}
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
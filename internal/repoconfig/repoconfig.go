@@ -0,0 +1,70 @@
+package repoconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/license"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scm"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/verdict"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the repository configuration file
+const FileName = ".aireview.yml"
+
+// maxConfigSize bounds the size of a fetched configuration file
+const maxConfigSize = 64 * 1024
+
+// Parse parses a .aireview.yml document. JSON is accepted as well since it
+// is a subset of YAML.
+func Parse(data []byte) (*models.RepoConfig, error) {
+	var cfg models.RepoConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", FileName, err)
+	}
+	if err := Validate(&cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate checks the values of a repository configuration
+func Validate(cfg *models.RepoConfig) error {
+	switch strings.ToUpper(cfg.MinSeverity) {
+	case "", "INFO", "WARNING", "ERROR":
+	default:
+		return fmt.Errorf("invalid min_severity %q: must be INFO, WARNING or ERROR", cfg.MinSeverity)
+	}
+	if cfg.MaxIssues < 0 {
+		return fmt.Errorf("max_issues must not be negative")
+	}
+	if cfg.LicenseHeader != nil {
+		if err := license.Validate(cfg.LicenseHeader); err != nil {
+			return err
+		}
+	}
+	if cfg.Verdict != nil {
+		if err := verdict.Validate(cfg.Verdict); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fetch downloads .aireview.yml from a GitHub repository at the given ref.
+// It returns nil without error when the repository has no config file.
+func Fetch(ctx context.Context, repoURL, ref, token string) (*models.RepoConfig, error) {
+	data, err := scm.FetchFile(ctx, repoURL, ref, FileName, token, maxConfigSize)
+	if errors.Is(err, scm.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
@@ -0,0 +1,276 @@
+// Package routing picks the provider and model of reviews that don't name
+// one, by rules on the review's size, mode, language and time of day and a
+// strategy choosing between each rule's candidates
+package routing
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/pricing"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
+)
+
+// Strategies
+const (
+	StrategyFirst    = "first"    // The first available candidate, in the order listed
+	StrategyCheapest = "cheapest" // The lowest estimated cost for the review
+	StrategyFastest  = "fastest"  // The lowest p95 latency of recent calls
+)
+
+// expectedCompletionTokens is the output assumed when comparing the cost of
+// candidates
+const expectedCompletionTokens = 1024
+
+// minLatencySamples is the number of calls a candidate needs before its p95
+// latency is trusted; the fastest strategy tries candidates with fewer
+// first so every one gets measured
+const minLatencySamples = 5
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Conditions select the reviews a route applies to; empty conditions match
+// every review
+type Conditions struct {
+	MinPromptTokens int      `yaml:"min_prompt_tokens"` // Estimated from the diff
+	MaxPromptTokens int      `yaml:"max_prompt_tokens"`
+	Modes           []string `yaml:"modes"`     // Review modes, e.g. quick
+	Languages       []string `yaml:"languages"` // Request languages, any case
+	Hours           string   `yaml:"hours"`     // Local hours as start-end, e.g. 20-8 for 20:00 to 07:59
+	Days            []string `yaml:"days"`      // mon to sun
+}
+
+// Route is one routing rule
+type Route struct {
+	Name       string     `yaml:"name"`
+	When       Conditions `yaml:"when"`
+	Strategy   string     `yaml:"strategy"`   // first (default), cheapest or fastest
+	Candidates []string   `yaml:"candidates"` // provider/model, or provider for its default model
+
+	startHour, endHour int
+	days               []time.Weekday
+}
+
+// Router holds the routing rules, tried in order
+type Router struct {
+	Timezone string  `yaml:"timezone"` // IANA name for hours and days; empty uses the server's
+	Routes   []Route `yaml:"routes"`
+
+	location *time.Location
+}
+
+// Request describes the review being routed
+type Request struct {
+	PromptTokens int
+	Mode         string
+	Language     string
+	Time         time.Time
+}
+
+// Choice is the provider and model a route picked
+type Choice struct {
+	Route    string
+	Provider string
+	Model    string
+}
+
+var (
+	mu     sync.RWMutex
+	active *Router
+)
+
+// Load reads and checks a routing file
+func Load(path string) (*Router, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routing file: %w", err)
+	}
+	var router Router
+	if err := yaml.Unmarshal(data, &router); err != nil {
+		return nil, fmt.Errorf("failed to parse routing file: %w", err)
+	}
+
+	router.location = time.Local
+	if router.Timezone != "" {
+		if router.location, err = time.LoadLocation(router.Timezone); err != nil {
+			return nil, fmt.Errorf("invalid routing timezone: %w", err)
+		}
+	}
+	if len(router.Routes) == 0 {
+		return nil, fmt.Errorf("routing file %s defines no routes", path)
+	}
+	for i := range router.Routes {
+		if err := router.Routes[i].check(); err != nil {
+			return nil, fmt.Errorf("route %d (%s): %w", i+1, router.Routes[i].Name, err)
+		}
+	}
+	return &router, nil
+}
+
+// check validates a route and parses its hours and days
+func (r *Route) check() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch r.Strategy {
+	case "":
+		r.Strategy = StrategyFirst
+	case StrategyFirst, StrategyCheapest, StrategyFastest:
+	default:
+		return fmt.Errorf("invalid strategy %q: must be first, cheapest or fastest", r.Strategy)
+	}
+	if len(r.Candidates) == 0 {
+		return fmt.Errorf("at least one candidate is required")
+	}
+
+	r.startHour, r.endHour = -1, -1
+	if r.When.Hours != "" {
+		start, end, ok := strings.Cut(r.When.Hours, "-")
+		var err1, err2 error
+		r.startHour, err1 = strconv.Atoi(strings.TrimSpace(start))
+		r.endHour, err2 = strconv.Atoi(strings.TrimSpace(end))
+		if !ok || err1 != nil || err2 != nil || r.startHour < 0 || r.startHour > 23 || r.endHour < 0 || r.endHour > 24 || r.startHour == r.endHour {
+			return fmt.Errorf("invalid hours %q: expected start-end, e.g. 20-8", r.When.Hours)
+		}
+	}
+	for _, day := range r.When.Days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return fmt.Errorf("invalid day %q: use mon to sun", day)
+		}
+		r.days = append(r.days, weekday)
+	}
+	return nil
+}
+
+// Check reports candidates that aren't registered providers or models
+func (r *Router) Check(registry *providers.Registry) error {
+	for _, route := range r.Routes {
+		for _, candidate := range route.Candidates {
+			provider, model, _ := strings.Cut(candidate, "/")
+			if _, _, err := registry.Resolve(provider, model); err != nil {
+				return fmt.Errorf("route %s: candidate %s: %w", route.Name, candidate, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Set installs the routing rules; nil turns routing off
+func Set(r *Router) {
+	mu.Lock()
+	defer mu.Unlock()
+	active = r
+}
+
+// Current returns the installed routing rules, if any
+func Current() *Router {
+	mu.RLock()
+	defer mu.RUnlock()
+	return active
+}
+
+// Pick applies the first route matching the request. Candidates that
+// aren't registered, or that allowed rejects, are skipped, and unhealthy
+// ones are used only when nothing else is left. It returns false when no
+// route matches or none of its candidates can be used.
+func (r *Router) Pick(registry *providers.Registry, request Request, allowed func(provider, model string) bool) (Choice, bool) {
+	for _, route := range r.Routes {
+		if !route.matches(request, r.location) {
+			continue
+		}
+
+		var usable, unhealthy []Choice
+		for _, candidate := range route.Candidates {
+			provider, model, _ := strings.Cut(candidate, "/")
+			provider, model, err := registry.Resolve(provider, model)
+			if err != nil || !allowed(provider, model) {
+				continue
+			}
+			choice := Choice{Route: route.Name, Provider: provider, Model: model}
+			if registry.Health(provider).Status == providers.HealthUnhealthy {
+				unhealthy = append(unhealthy, choice)
+				continue
+			}
+			usable = append(usable, choice)
+		}
+		if len(usable) == 0 {
+			usable = unhealthy
+		}
+		if len(usable) == 0 {
+			continue
+		}
+		return route.pick(registry, request, usable), true
+	}
+	return Choice{}, false
+}
+
+// matches reports whether the route's conditions hold for the request
+func (r *Route) matches(request Request, location *time.Location) bool {
+	when := r.When
+	if when.MinPromptTokens > 0 && request.PromptTokens < when.MinPromptTokens {
+		return false
+	}
+	if when.MaxPromptTokens > 0 && request.PromptTokens > when.MaxPromptTokens {
+		return false
+	}
+	if len(when.Modes) > 0 && !slices.Contains(when.Modes, request.Mode) {
+		return false
+	}
+	if len(when.Languages) > 0 && !slices.ContainsFunc(when.Languages, func(l string) bool { return strings.EqualFold(l, request.Language) }) {
+		return false
+	}
+
+	now := request.Time.In(location)
+	if len(r.days) > 0 && !slices.Contains(r.days, now.Weekday()) {
+		return false
+	}
+	if r.startHour >= 0 {
+		hour := now.Hour()
+		if r.startHour < r.endHour {
+			return hour >= r.startHour && hour < r.endHour
+		}
+		return hour >= r.startHour || hour < r.endHour
+	}
+	return true
+}
+
+// pick applies the route's strategy to its usable candidates
+func (r *Route) pick(registry *providers.Registry, request Request, candidates []Choice) Choice {
+	switch r.Strategy {
+	case StrategyCheapest:
+		best, bestCost := -1, 0.0
+		for i, c := range candidates {
+			cost, ok := pricing.Cost(c.Model, request.PromptTokens, expectedCompletionTokens)
+			if ok && (best < 0 || cost < bestCost) {
+				best, bestCost = i, cost
+			}
+		}
+		if best >= 0 {
+			return candidates[best]
+		}
+	case StrategyFastest:
+		best, bestLatency := -1, time.Duration(0)
+		for i, c := range candidates {
+			latency, samples := registry.LatencyP95(c.Provider, c.Model)
+			if samples < minLatencySamples {
+				return c
+			}
+			if best < 0 || latency < bestLatency {
+				best, bestLatency = i, latency
+			}
+		}
+		return candidates[best]
+	}
+	return candidates[0]
+}
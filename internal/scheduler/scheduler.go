@@ -0,0 +1,175 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+)
+
+// numPriorities is the number of distinct priority levels (low, normal, high)
+const numPriorities = 3
+
+// Scheduler bounds the number of concurrent provider calls. When all slots
+// are busy, waiting callers are admitted strictly by priority, then in
+// arrival order.
+type Scheduler struct {
+	mu       sync.Mutex
+	capacity int
+	inUse    int
+	waiters  [numPriorities][]*waiter
+}
+
+// waiter is a caller queued for n slots
+type waiter struct {
+	ready chan struct{}
+	n     int
+}
+
+// New creates a scheduler with the given number of slots. It returns nil
+// for a non-positive capacity; a nil Scheduler admits everything.
+func New(capacity int) *Scheduler {
+	if capacity <= 0 {
+		return nil
+	}
+	return &Scheduler{capacity: capacity}
+}
+
+// Acquire waits for a slot and returns a function that releases it
+func (s *Scheduler) Acquire(ctx context.Context, priority int) (func(), error) {
+	return s.AcquireN(ctx, priority, 1)
+}
+
+// AcquireN waits for n slots, for a caller making n provider calls at
+// once, and returns a function that releases them. Requests for more
+// slots than the scheduler has take all of them.
+func (s *Scheduler) AcquireN(ctx context.Context, priority, n int) (func(), error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	if priority < 0 {
+		priority = 0
+	}
+	if priority >= numPriorities {
+		priority = numPriorities - 1
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	w := &waiter{ready: make(chan struct{}), n: n}
+	s.mu.Lock()
+	if !s.hasWaiters() && s.fitsLocked(w) {
+		s.takeLocked(w)
+		s.mu.Unlock()
+		return s.releaser(w), nil
+	}
+	s.waiters[priority] = append(s.waiters[priority], w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return s.releaser(w), nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.remove(priority, w) {
+			// A large waiter leaving may unblock smaller ones behind it
+			s.admitLocked()
+			return nil, ctx.Err()
+		}
+		// The slots were handed over concurrently; give them back
+		s.releaseLocked(w.n)
+		return nil, ctx.Err()
+	}
+}
+
+// Waiting returns the number of callers waiting for a slot
+func (s *Scheduler) Waiting() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, w := range s.waiters {
+		n += len(w)
+	}
+	return n
+}
+
+// releaser returns the function releasing the slots held by w
+func (s *Scheduler) releaser(w *waiter) func() {
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.releaseLocked(w.n)
+	}
+}
+
+// SetCapacity changes the number of slots. Growing admits waiters at
+// once; shrinking takes effect as calls in flight finish.
+func (s *Scheduler) SetCapacity(capacity int) {
+	if s == nil || capacity <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.capacity = capacity
+	s.admitLocked()
+}
+
+// releaseLocked frees n slots and hands them to waiters that now fit
+func (s *Scheduler) releaseLocked(n int) {
+	s.inUse -= n
+	s.admitLocked()
+}
+
+// fitsLocked reports whether w can take its slots now, counting a request
+// for more slots than the scheduler has as one for all of them
+func (s *Scheduler) fitsLocked(w *waiter) bool {
+	return s.inUse+min(w.n, s.capacity) <= s.capacity
+}
+
+// takeLocked gives w its slots
+func (s *Scheduler) takeLocked(w *waiter) {
+	w.n = min(w.n, s.capacity)
+	s.inUse += w.n
+}
+
+// admitLocked wakes waiters in priority order while their slots are free.
+// A waiter that doesn't fit yet holds back those behind it, so callers
+// needing several slots aren't starved by single-slot ones.
+func (s *Scheduler) admitLocked() {
+	for p := numPriorities - 1; p >= 0; p-- {
+		for len(s.waiters[p]) > 0 {
+			next := s.waiters[p][0]
+			if !s.fitsLocked(next) {
+				return
+			}
+			s.waiters[p] = s.waiters[p][1:]
+			s.takeLocked(next)
+			close(next.ready)
+		}
+	}
+}
+
+func (s *Scheduler) hasWaiters() bool {
+	for _, w := range s.waiters {
+		if len(w) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// remove deletes a waiter, reporting whether it was still queued
+func (s *Scheduler) remove(priority int, w *waiter) bool {
+	queue := s.waiters[priority]
+	for i, queued := range queue {
+		if queued == w {
+			s.waiters[priority] = append(queue[:i], queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package scm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// branchName matches the branch names ResolveBranch accepts; it keeps names
+// from being taken for git options or revision expressions
+var branchName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._/-]*$`)
+
+// BranchRequest names a commit by its distance from the tip of a branch
+type BranchRequest struct {
+	RepoURL      string
+	Branch       string
+	Back         int      // Commits before the tip, following first parents; 0 is the tip
+	Token        string   // Read access to the repository; optional for public ones
+	AllowedHosts []string // Hosts the repository may be on
+}
+
+// ResolveBranch returns the full SHA of the commit Back commits before the
+// tip of Branch. The tip is read with ls-remote; older commits come from a
+// shallow, blobless fetch of the branch.
+func ResolveBranch(ctx context.Context, req BranchRequest) (string, error) {
+	if !branchName.MatchString(req.Branch) || strings.Contains(req.Branch, "..") {
+		return "", fmt.Errorf("invalid branch name %q", req.Branch)
+	}
+	if req.Back < 0 {
+		return "", fmt.Errorf("commits back must not be negative")
+	}
+	repo := NormalizeRepository(req.RepoURL)
+	host, _, _ := strings.Cut(repo, "/")
+	if !containsHost(req.AllowedHosts, host) {
+		return "", fmt.Errorf("repository host %q is not allowed", host)
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", fmt.Errorf("git is not installed")
+	}
+	dir, err := os.MkdirTemp("", "ai-gateway-branch-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create clone directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	ref := "refs/heads/" + req.Branch
+	steps := [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", "https://" + repo + ".git"},
+	}
+	if req.Back > 0 {
+		steps = append(steps, []string{"fetch", "--quiet", "--no-tags", "--filter=blob:none", fmt.Sprintf("--depth=%d", req.Back+1), "origin", ref})
+	}
+	for _, args := range steps {
+		if err := runGit(ctx, dir, host, req.Token, io.Discard, args...); err != nil {
+			return "", err
+		}
+	}
+
+	var out strings.Builder
+	if req.Back == 0 {
+		if err := runGit(ctx, dir, host, req.Token, &out, "ls-remote", "origin", ref); err != nil {
+			return "", err
+		}
+		sha, _, _ := strings.Cut(strings.TrimSpace(out.String()), "\t")
+		if !fullSHA.MatchString(sha) {
+			return "", fmt.Errorf("branch %s not found", req.Branch)
+		}
+		return sha, nil
+	}
+
+	if err := runGit(ctx, dir, host, req.Token, &out, "rev-parse", "--verify", "--quiet", fmt.Sprintf("FETCH_HEAD~%d^{commit}", req.Back)); err != nil {
+		return "", fmt.Errorf("branch %s has fewer than %d commits", req.Branch, req.Back+1)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
@@ -0,0 +1,209 @@
+package scm
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrDiffTooLarge is returned when a diff exceeds the requested size limit
+var ErrDiffTooLarge = errors.New("diff too large")
+
+// fullSHA matches a complete SHA-1 or SHA-256 commit ID. Abbreviated IDs
+// and ref names are refused: servers only fetch full IDs, and names could
+// be taken for git options.
+var fullSHA = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// DiffRequest names the two commits to diff
+type DiffRequest struct {
+	RepoURL      string
+	Base         string
+	Head         string
+	Token        string   // Read access to the repository; optional for public ones
+	AllowedHosts []string // Hosts the repository may be on
+	MaxSize      int64
+}
+
+// FetchDiff returns the unified diff of the changes Head makes since it
+// diverged from Base, as a pull request from Head into Base shows them.
+// GitHub repositories use the compare API; diffs it refuses as too large,
+// and repositories on other hosts, come from a blobless clone.
+func FetchDiff(ctx context.Context, req DiffRequest) (string, error) {
+	req.Base = strings.ToLower(req.Base)
+	req.Head = strings.ToLower(req.Head)
+	if !fullSHA.MatchString(req.Base) || !fullSHA.MatchString(req.Head) {
+		return "", fmt.Errorf("base_sha and head_sha must be full commit SHAs")
+	}
+	repo := NormalizeRepository(req.RepoURL)
+	host, _, _ := strings.Cut(repo, "/")
+	if !containsHost(req.AllowedHosts, host) {
+		return "", fmt.Errorf("repository host %q is not allowed", host)
+	}
+
+	if host == "github.com" {
+		diff, err := compareGitHub(ctx, req)
+		if !errors.Is(err, errCompareRefused) {
+			return diff, err
+		}
+	}
+	return cloneDiff(ctx, "https://"+repo+".git", host, req)
+}
+
+// errCompareRefused is returned when GitHub won't render a diff, usually
+// because it has too many files
+var errCompareRefused = errors.New("compare refused")
+
+// compareGitHub fetches a diff from the GitHub compare API
+func compareGitHub(ctx context.Context, req DiffRequest) (string, error) {
+	owner, name, ok := ParseGitHubURL(req.RepoURL)
+	if !ok {
+		return "", fmt.Errorf("unsupported repository URL: %s", req.RepoURL)
+	}
+	endpoint := fmt.Sprintf("https://api.github.com/repos/%s/%s/compare/%s...%s", owner, name, req.Base, req.Head)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Accept", "application/vnd.github.diff")
+	if req.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+req.Token)
+	}
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch diff: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", fmt.Errorf("repository or commits not found, or the token cannot read them")
+	case http.StatusNotAcceptable, http.StatusUnprocessableEntity, http.StatusInternalServerError, http.StatusServiceUnavailable:
+		return "", errCompareRefused
+	default:
+		return "", fmt.Errorf("failed to fetch diff: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, req.MaxSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read diff: %w", err)
+	}
+	if int64(len(data)) > req.MaxSize {
+		return "", ErrDiffTooLarge
+	}
+	return string(data), nil
+}
+
+// cloneDiff diffs the commits in a temporary blobless clone, which fetches
+// the history but only the file contents the diff needs
+func cloneDiff(ctx context.Context, cloneURL, host string, req DiffRequest) (string, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", fmt.Errorf("git is not installed")
+	}
+	dir, err := os.MkdirTemp("", "ai-gateway-diff-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create clone directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	git := func(stdout io.Writer, args ...string) error {
+		return runGit(ctx, dir, host, req.Token, stdout, args...)
+	}
+
+	steps := [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", cloneURL},
+		{"fetch", "--quiet", "--no-tags", "--filter=blob:none", "origin", req.Base, req.Head},
+	}
+	for _, args := range steps {
+		if err := git(io.Discard, args...); err != nil {
+			return "", err
+		}
+	}
+
+	diff := &limitedBuffer{limit: req.MaxSize}
+	if err := git(diff, "diff", "--no-color", "--no-ext-diff", req.Base+"..."+req.Head); err != nil {
+		if diff.exceeded {
+			return "", ErrDiffTooLarge
+		}
+		return "", err
+	}
+	return diff.String(), nil
+}
+
+// runGit runs a git command in dir, authenticating to host with token when
+// one is given. The credential goes through the environment rather than
+// the arguments, which other processes can read.
+func runGit(ctx context.Context, dir, host, token string, stdout io.Writer, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_CONFIG_NOSYSTEM=1")
+	if token != "" {
+		cmd.Env = append(cmd.Env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+basicCredentials(host, token))
+	}
+	var stderr strings.Builder
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("git %s timed out", args[0])
+		}
+		return fmt.Errorf("git %s failed: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// basicCredentials encodes a token as HTTP basic credentials, with the user
+// name each host expects for tokens
+func basicCredentials(host, token string) string {
+	user := "oauth2"
+	switch host {
+	case "github.com":
+		user = "x-access-token"
+	case "bitbucket.org":
+		user = "x-token-auth"
+	}
+	return base64.StdEncoding.EncodeToString([]byte(user + ":" + token))
+}
+
+func containsHost(hosts []string, host string) bool {
+	for _, h := range hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedBuffer collects output up to limit bytes, then fails writes
+type limitedBuffer struct {
+	strings.Builder
+	limit    int64
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if int64(b.Len()+len(p)) > b.limit {
+		b.exceeded = true
+		return 0, ErrDiffTooLarge
+	}
+	return b.Builder.Write(p)
+}
@@ -0,0 +1,124 @@
+package scm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/glob"
+)
+
+// ErrNotFound is returned when the file does not exist at the given ref
+var ErrNotFound = errors.New("file not found")
+
+// ErrTooLarge is returned when a file exceeds the requested size limit
+var ErrTooLarge = errors.New("file too large")
+
+// NormalizeRepository reduces a repository URL to host/owner/name so that
+// https, ssh and .git variants of the same repository compare equal
+func NormalizeRepository(repo string) string {
+	repo = strings.ToLower(strings.TrimSpace(repo))
+	repo = strings.TrimSuffix(repo, ".git")
+	if i := strings.Index(repo, "://"); i >= 0 {
+		repo = repo[i+3:]
+	}
+	if at := strings.Index(repo, "@"); at >= 0 {
+		repo = repo[at+1:]
+	}
+	repo = strings.Replace(repo, ":", "/", 1)
+	return strings.Trim(repo, "/")
+}
+
+// MatchRepository reports whether a repository URL matches any of the
+// globs, which are tried against both its host/owner/name and owner/name
+// forms
+func MatchRepository(patterns []string, repo string) bool {
+	if repo == "" {
+		return false
+	}
+	full := NormalizeRepository(repo)
+	short := full
+	if strings.Count(full, "/") > 1 {
+		short = full[strings.Index(full, "/")+1:]
+	}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if glob.Match(pattern, full) || glob.Match(pattern, short) {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchFile downloads a file from a GitHub repository at the given ref.
+// Files larger than maxSize bytes return ErrTooLarge.
+func FetchFile(ctx context.Context, repoURL, ref, path, token string, maxSize int64) ([]byte, error) {
+	owner, repo, ok := ParseGitHubURL(repoURL)
+	if !ok {
+		return nil, fmt.Errorf("unsupported repository URL: %s", repoURL)
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	endpoint := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s?ref=%s",
+		owner, repo, strings.Join(segments, "/"), url.QueryEscape(ref))
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github.raw")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: status %d", path, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// ParseGitHubURL extracts owner and repository from a github.com URL
+func ParseGitHubURL(repoURL string) (string, string, bool) {
+	repoURL = strings.TrimSuffix(strings.TrimSpace(repoURL), ".git")
+	repoURL = strings.TrimPrefix(repoURL, "git@github.com:")
+	repoURL = strings.TrimPrefix(repoURL, "https://")
+	repoURL = strings.TrimPrefix(repoURL, "http://")
+	repoURL = strings.TrimPrefix(repoURL, "github.com/")
+
+	parts := strings.Split(strings.Trim(repoURL, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -0,0 +1,140 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// MaxTurns bounds the conversation history kept per review
+const MaxTurns = 10
+
+// Session is the context of a completed review that follow-up questions
+// can refer to
+type Session struct {
+	ID          string
+	Tenant      string
+	Provider    string
+	Model       string
+	Language    string
+	Anonymized  bool // The provider only saw an anonymized diff
+	Diff        string
+	Files       []models.FileContent
+	Overview    string
+	Diagnostics []models.Diagnostic
+	History     []models.ConversationTurn
+	CreatedAt   time.Time
+}
+
+// Store keeps recent review sessions in memory. Sessions expire after the
+// TTL, and the oldest are evicted once capacity is reached.
+type Store struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	capacity int
+	sessions map[string]*Session
+	order    []string // IDs in insertion order
+}
+
+// NewStore creates a session store. It returns nil if ttl or capacity is
+// not positive; a nil store keeps nothing.
+func NewStore(ttl time.Duration, capacity int) *Store {
+	if ttl <= 0 || capacity <= 0 {
+		return nil
+	}
+	return &Store{
+		ttl:      ttl,
+		capacity: capacity,
+		sessions: make(map[string]*Session),
+	}
+}
+
+// NewID returns a random review ID
+func NewID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Save stores a session, assigning it an ID if it has none, and returns
+// the ID. A nil store returns an empty ID.
+func (s *Store) Save(session *Session) string {
+	if s == nil {
+		return ""
+	}
+	if session.ID == "" {
+		session.ID = NewID()
+	}
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictLocked()
+	for len(s.order) >= s.capacity {
+		delete(s.sessions, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.sessions[session.ID] = session
+	s.order = append(s.order, session.ID)
+	return session.ID
+}
+
+// Get returns a copy of a tenant's session
+func (s *Store) Get(tenant, id string) (*Session, bool) {
+	if s == nil {
+		return nil, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictLocked()
+	session, ok := s.sessions[id]
+	if !ok || session.Tenant != tenant {
+		return nil, false
+	}
+	copied := *session
+	copied.History = append([]models.ConversationTurn(nil), session.History...)
+	return &copied, true
+}
+
+// Append adds turns to a session's history, keeping the most recent
+// MaxTurns, and returns the length of the history
+func (s *Store) Append(id string, turns ...models.ConversationTurn) int {
+	if s == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return 0
+	}
+	session.History = append(session.History, turns...)
+	if len(session.History) > MaxTurns {
+		session.History = session.History[len(session.History)-MaxTurns:]
+	}
+	return len(session.History)
+}
+
+// evictLocked drops expired sessions. Sessions are stored in creation
+// order, so expired ones are always at the front.
+func (s *Store) evictLocked() {
+	cutoff := time.Now().Add(-s.ttl)
+	for len(s.order) > 0 {
+		session, ok := s.sessions[s.order[0]]
+		if ok && session.CreatedAt.After(cutoff) {
+			return
+		}
+		delete(s.sessions, s.order[0])
+		s.order = s.order[1:]
+	}
+}
@@ -0,0 +1,292 @@
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"log"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/anonymize"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/eval"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/preprocess"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/pricing"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
+)
+
+// DefaultMaxConcurrent bounds the shadow calls in flight when the
+// configuration doesn't
+const DefaultMaxConcurrent = 4
+
+// agreementTolerance is how many lines apart a primary and a shadow finding
+// in the same file and category may be and still count as the same finding
+const agreementTolerance = 3
+
+// outcomes counts mirrored reviews on /debug/vars, keyed by completed,
+// failed and dropped (skipped because MaxConcurrent calls were in flight)
+var outcomes = expvar.NewMap("shadow_reviews")
+
+// Config controls which requests are mirrored and where they go
+type Config struct {
+	Provider      string  // Provider to send shadow traffic to
+	Model         string  // Model override for shadow requests (optional)
+	ReviewMode    string  // Review mode (prompt) override for shadow requests (optional)
+	PromptVersion string  // Prompt version override for shadow requests (optional)
+	Percent       float64 // Percentage of requests to mirror (0-100)
+	OutputPath    string  // JSONL file receiving shadow records; empty logs only
+	Anonymize     bool    // Anonymize diffs sent to the shadow provider
+	Timeout       time.Duration
+	MaxConcurrent int // Shadow calls in flight at most; further samples are dropped
+}
+
+// Record captures a primary/shadow result pair for offline comparison
+type Record struct {
+	Timestamp               time.Time           `json:"timestamp"`
+	Language                string              `json:"language"`
+	DiffSize                int                 `json:"diff_size"`
+	PrimaryProvider         string              `json:"primary_provider"`
+	PrimaryModel            string              `json:"primary_model"`
+	PrimaryMode             string              `json:"primary_mode"`
+	PrimaryPromptVersion    string              `json:"primary_prompt_version,omitempty"`
+	PrimaryLatencyMs        int64               `json:"primary_latency_ms"`
+	PrimaryOverview         string              `json:"primary_overview"`
+	PrimaryDiagnostics      []models.Diagnostic `json:"primary_diagnostics"`
+	PrimaryPromptTokens     int                 `json:"primary_prompt_tokens"`
+	PrimaryCompletionTokens int                 `json:"primary_completion_tokens"`
+	PrimaryCostUSD          *float64            `json:"primary_cost_usd,omitempty"`
+	ShadowProvider          string              `json:"shadow_provider"`
+	ShadowModel             string              `json:"shadow_model"`
+	ShadowMode              string              `json:"shadow_mode"`
+	ShadowPromptVersion     string              `json:"shadow_prompt_version,omitempty"`
+	ShadowLatencyMs         int64               `json:"shadow_latency_ms"`
+	ShadowOverview          string              `json:"shadow_overview,omitempty"`
+	ShadowDiagnostics       []models.Diagnostic `json:"shadow_diagnostics,omitempty"`
+	ShadowPromptTokens      int                 `json:"shadow_prompt_tokens,omitempty"`
+	ShadowCompletionTokens  int                 `json:"shadow_completion_tokens,omitempty"`
+	ShadowCostUSD           *float64            `json:"shadow_cost_usd,omitempty"`
+	ShadowError             string              `json:"shadow_error,omitempty"`
+	Agreement               *Agreement          `json:"agreement,omitempty"` // Set when the shadow call succeeded
+}
+
+// Agreement compares the findings of the primary and shadow reviews.
+// Findings agree when they are in the same file and category, at most a
+// few lines apart.
+type Agreement struct {
+	Matched     int     `json:"matched"`
+	PrimaryOnly int     `json:"primary_only"`
+	ShadowOnly  int     `json:"shadow_only"`
+	Rate        float64 `json:"rate"` // Matched share of all distinct findings; 1 when neither reported any
+}
+
+// Shadower mirrors a sample of review requests to a staging provider
+// without affecting the response returned to the caller
+type Shadower struct {
+	registry *providers.Registry
+	config   Config
+	mu       sync.Mutex
+	pending  sync.WaitGroup // Shadow calls in flight
+	slots    chan struct{}  // Bounds the shadow calls in flight
+}
+
+// NewShadower creates a new shadower. It returns nil when shadowing is not
+// configured; a nil Shadower is safe to use and mirrors nothing.
+func NewShadower(registry *providers.Registry, cfg Config) *Shadower {
+	if cfg.Provider == "" || cfg.Percent <= 0 {
+		return nil
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 120 * time.Second
+	}
+	if cfg.MaxConcurrent <= 0 {
+		cfg.MaxConcurrent = DefaultMaxConcurrent
+	}
+	return &Shadower{
+		registry: registry,
+		config:   cfg,
+		slots:    make(chan struct{}, cfg.MaxConcurrent),
+	}
+}
+
+// Mirror sends a copy of the request to the shadow provider in the
+// background if it is selected by sampling. Samples taken while
+// MaxConcurrent shadow calls are in flight are dropped, so a slow
+// candidate never holds up or piles onto production traffic.
+func (s *Shadower) Mirror(request models.ReviewRequest, primary *models.AIProviderResponse, primaryLatency time.Duration) {
+	if s == nil || rand.Float64()*100 >= s.config.Percent {
+		return
+	}
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		outcomes.Add("dropped", 1)
+		return
+	}
+
+	provider, err := s.registry.Get(s.config.Provider)
+	if err != nil {
+		<-s.slots
+		log.Printf("Shadow provider error: %v", err)
+		return
+	}
+
+	record := Record{
+		Timestamp:               time.Now().UTC(),
+		Language:                request.Language,
+		DiffSize:                len(request.GitDiff),
+		PrimaryProvider:         request.AIProvider,
+		PrimaryModel:            request.AIModel,
+		PrimaryMode:             request.ReviewMode,
+		PrimaryPromptVersion:    request.PromptVersion,
+		PrimaryLatencyMs:        primaryLatency.Milliseconds(),
+		PrimaryOverview:         primary.Overview,
+		PrimaryDiagnostics:      primary.Diagnostics,
+		PrimaryPromptTokens:     primary.Usage.PromptTokens,
+		PrimaryCompletionTokens: primary.Usage.CompletionTokens,
+		ShadowProvider:          s.config.Provider,
+	}
+	if cost, ok := pricing.Cost(request.AIModel, primary.Usage.PromptTokens, primary.Usage.CompletionTokens); ok {
+		record.PrimaryCostUSD = &cost
+	}
+
+	shadowRequest := request
+	shadowRequest.AIProvider = s.config.Provider
+	// A model from the primary provider is meaningless for another provider
+	if s.config.Model != "" || s.config.Provider != request.AIProvider {
+		shadowRequest.AIModel = s.config.Model
+	}
+	if s.config.ReviewMode != "" {
+		shadowRequest.ReviewMode = s.config.ReviewMode
+	}
+	if s.config.PromptVersion != "" {
+		shadowRequest.PromptVersion = s.config.PromptVersion
+	}
+	if _, model, err := s.registry.Resolve(shadowRequest.AIProvider, shadowRequest.AIModel); err == nil {
+		shadowRequest.AIModel = model
+	}
+	record.ShadowModel = shadowRequest.AIModel
+	record.ShadowMode = shadowRequest.ReviewMode
+	record.ShadowPromptVersion = shadowRequest.PromptVersion
+
+	var mapping *anonymize.Mapping
+	if s.config.Anonymize || request.Anonymize {
+		shadowRequest.GitDiff, mapping = anonymize.Diff(request.GitDiff)
+		shadowRequest.GitInfo = nil
+		shadowRequest.FileContents = nil
+		shadowRequest.RelatedContext = nil
+		shadowRequest.RejectedFindings = nil
+		_, shadowRequest.InjectionFindings = preprocess.NeutralizeInjection(shadowRequest.GitDiff)
+	}
+
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		defer func() { <-s.slots }()
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.Timeout)
+		defer cancel()
+
+		start := time.Now()
+		response, err := provider.Review(ctx, &shadowRequest)
+		record.ShadowLatencyMs = time.Since(start).Milliseconds()
+		if err != nil {
+			record.ShadowError = err.Error()
+			outcomes.Add("failed", 1)
+			s.write(record)
+			return
+		}
+		if mapping != nil {
+			record.ShadowOverview = mapping.RestoreText(response.Overview)
+			record.ShadowDiagnostics = mapping.Restore(response.Diagnostics)
+		} else {
+			record.ShadowOverview = response.Overview
+			record.ShadowDiagnostics = response.Diagnostics
+		}
+		record.ShadowPromptTokens = response.Usage.PromptTokens
+		record.ShadowCompletionTokens = response.Usage.CompletionTokens
+		if cost, ok := pricing.Cost(record.ShadowModel, response.Usage.PromptTokens, response.Usage.CompletionTokens); ok {
+			record.ShadowCostUSD = &cost
+		}
+		record.Agreement = compare(record.PrimaryDiagnostics, record.ShadowDiagnostics)
+		outcomes.Add("completed", 1)
+
+		s.write(record)
+	}()
+}
+
+// compare measures how far the shadow's findings agree with the primary's
+func compare(primary, shadow []models.Diagnostic) *Agreement {
+	expected := make([]models.ExpectedFinding, len(primary))
+	for i, d := range primary {
+		expected[i] = models.ExpectedFinding{Path: d.Location.Path, Line: d.Location.Range.Start.Line, Category: d.Code.Value}
+	}
+	score := eval.Score(expected, shadow, agreementTolerance)
+	agreement := &Agreement{
+		Matched:     score.TruePositives,
+		PrimaryOnly: score.FalseNegatives,
+		ShadowOnly:  score.FalsePositives,
+		Rate:        1,
+	}
+	if total := agreement.Matched + agreement.PrimaryOnly + agreement.ShadowOnly; total > 0 {
+		agreement.Rate = float64(agreement.Matched) / float64(total)
+	}
+	return agreement
+}
+
+// Wait blocks until shadow calls in flight have finished or ctx is done
+func (s *Shadower) Wait(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// write appends a record to the output file, or logs a summary if no file
+// is configured
+func (s *Shadower) write(record Record) {
+	if record.Agreement != nil {
+		log.Printf("Shadow review: provider=%s model=%s latency=%dms (primary %dms) diagnostics=%d (primary %d) agreement=%.2f",
+			record.ShadowProvider, record.ShadowModel, record.ShadowLatencyMs, record.PrimaryLatencyMs,
+			len(record.ShadowDiagnostics), len(record.PrimaryDiagnostics), record.Agreement.Rate)
+	} else {
+		log.Printf("Shadow review: provider=%s model=%s latency=%dms error=%q",
+			record.ShadowProvider, record.ShadowModel, record.ShadowLatencyMs, record.ShadowError)
+	}
+
+	if s.config.OutputPath == "" {
+		return
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Error encoding shadow record: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.config.OutputPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		log.Printf("Error opening shadow output: %v", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		log.Printf("Error writing shadow record: %v", err)
+	}
+}
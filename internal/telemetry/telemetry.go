@@ -0,0 +1,73 @@
+package telemetry
+
+import (
+	"expvar"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// Event is a structured record of a single provider exchange
+type Event struct {
+	Kind          string // review, ask, pr-description or docs
+	ClientID      string
+	Provider      string
+	Model         string
+	Mode          string
+	PromptVersion string // Empty unless prompt versions are registered
+	DiffBytes     int
+	Usage         models.Usage
+	ParserPath    string // Empty when the endpoint has no fallback parser
+	Diagnostics   int
+	Latency       time.Duration
+}
+
+// logger writes one JSON object per line so events can be shipped to a log
+// pipeline without parsing free-form messages
+var logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+// Counters published on /debug/vars, keyed by provider
+var (
+	providerRequests = expvar.NewMap("provider_requests")
+	promptTokens     = expvar.NewMap("provider_prompt_tokens")
+	completionTokens = expvar.NewMap("provider_completion_tokens")
+	truncations      = expvar.NewMap("provider_truncations")
+	parserPaths      = expvar.NewMap("parser_paths")           // keyed by parser path
+	promptVersions   = expvar.NewMap("prompt_version_reviews") // keyed by prompt version
+)
+
+// Emit logs the event and updates the published counters
+func Emit(e Event) {
+	logger.Info("provider_exchange",
+		slog.String("kind", e.Kind),
+		slog.String("client_id", e.ClientID),
+		slog.String("provider", e.Provider),
+		slog.String("model", e.Model),
+		slog.String("mode", e.Mode),
+		slog.String("prompt_version", e.PromptVersion),
+		slog.Int("diff_bytes", e.DiffBytes),
+		slog.Int("prompt_bytes", e.Usage.PromptBytes),
+		slog.Int("response_bytes", e.Usage.ResponseBytes),
+		slog.Int("prompt_tokens", e.Usage.PromptTokens),
+		slog.Int("completion_tokens", e.Usage.CompletionTokens),
+		slog.Bool("truncated", e.Usage.Truncated),
+		slog.String("parser_path", e.ParserPath),
+		slog.Int("diagnostics", e.Diagnostics),
+		slog.Int64("latency_ms", e.Latency.Milliseconds()),
+	)
+
+	providerRequests.Add(e.Provider, 1)
+	promptTokens.Add(e.Provider, int64(e.Usage.PromptTokens))
+	completionTokens.Add(e.Provider, int64(e.Usage.CompletionTokens))
+	if e.Usage.Truncated {
+		truncations.Add(e.Provider, 1)
+	}
+	if e.ParserPath != "" {
+		parserPaths.Add(e.ParserPath, 1)
+	}
+	if e.PromptVersion != "" {
+		promptVersions.Add(e.PromptVersion, 1)
+	}
+}
@@ -0,0 +1,189 @@
+// Package tenants maps client keys to tenants, each with its own upstream
+// provider credentials, defaults and rate limit tier
+package tenants
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/quota"
+	"gopkg.in/yaml.v3"
+)
+
+type contextKey string
+
+const tenantKey contextKey = "tenant"
+
+var tenantName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// Tenant is a team served by the gateway
+type Tenant struct {
+	Name            string                   `yaml:"name"`
+	Providers       []providers.ProviderSpec `yaml:"providers"`        // The tenant's own upstream credentials; empty uses the gateway's providers
+	DefaultProvider string                   `yaml:"default_provider"` // Empty uses the first of Providers; set only with Providers
+	DefaultModel    string                   `yaml:"default_model"`
+	RateLimitTier   string                   `yaml:"rate_limit_tier"` // Empty uses the key's tier
+	Quota           *quota.Limit             `yaml:"quota"`           // Monthly usage allowed for the tenant
+
+	registry *providers.Registry
+}
+
+// Registry returns the tenant's own providers, or nil when the tenant uses
+// the gateway's
+func (t *Tenant) Registry() *providers.Registry {
+	return t.registry
+}
+
+// Directory holds the configured tenants
+type Directory struct {
+	tenants map[string]*Tenant
+}
+
+// Load reads a YAML or JSON tenants file and builds each tenant's
+// providers. secret looks up the api_key_env variables.
+func Load(path string, secret func(name string) string, allowUnlistedModels bool) (*Directory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants file: %w", err)
+	}
+
+	var doc struct {
+		Tenants []*Tenant `yaml:"tenants"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse tenants file %s: %w", path, err)
+	}
+
+	dir := &Directory{tenants: make(map[string]*Tenant)}
+	for i, t := range doc.Tenants {
+		if !tenantName.MatchString(t.Name) {
+			return nil, fmt.Errorf("tenants file %s: tenant %d: invalid name %q", path, i, t.Name)
+		}
+		if dir.tenants[t.Name] != nil {
+			return nil, fmt.Errorf("tenants file %s: tenant %s is listed twice", path, t.Name)
+		}
+		if t.Quota != nil && (t.Quota.Tokens < 0 || t.Quota.CostUSD < 0) {
+			return nil, fmt.Errorf("tenants file %s: tenant %s: quota must not be negative", path, t.Name)
+		}
+		if err := t.build(secret, allowUnlistedModels); err != nil {
+			return nil, fmt.Errorf("tenants file %s: tenant %s: %w", path, t.Name, err)
+		}
+		dir.tenants[t.Name] = t
+	}
+	return dir, nil
+}
+
+// build registers the tenant's own providers
+func (t *Tenant) build(secret func(name string) string, allowUnlistedModels bool) error {
+	if len(t.Providers) == 0 {
+		// The gateway's providers keep the gateway's defaults
+		if t.DefaultProvider != "" || t.DefaultModel != "" {
+			return fmt.Errorf("default_provider and default_model need the tenant's own providers")
+		}
+		return nil
+	}
+	manifest := &providers.Manifest{Providers: t.Providers}
+	if err := manifest.Validate(); err != nil {
+		return err
+	}
+
+	registry := providers.NewRegistry()
+	names := manifest.Register(registry, secret)
+	if missing := manifest.Missing(registry, secret); len(missing) > 0 {
+		return fmt.Errorf("providers %v failed to initialize", missing)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("none of the providers' api_key_env variables are set")
+	}
+
+	defaultProvider := t.DefaultProvider
+	if defaultProvider == "" {
+		defaultProvider = names[0]
+	}
+	if _, err := registry.Get(defaultProvider); err != nil {
+		return fmt.Errorf("default_provider %s is not one of the tenant's providers", defaultProvider)
+	}
+	if err := registry.CheckModel(defaultProvider, t.DefaultModel); err != nil && !allowUnlistedModels {
+		return fmt.Errorf("default_model: %w", err)
+	}
+	registry.SetDefaults(defaultProvider, t.DefaultModel)
+	registry.AllowUnlistedModels(allowUnlistedModels)
+
+	t.registry = registry
+	log.Printf("✓ Tenant %s: providers %v", t.Name, names)
+	return nil
+}
+
+// Get returns the named tenant
+func (d *Directory) Get(name string) (*Tenant, bool) {
+	if d == nil {
+		return nil, false
+	}
+	t, ok := d.tenants[name]
+	return t, ok
+}
+
+// Names returns the tenant names, sorted
+func (d *Directory) Names() []string {
+	if d == nil {
+		return nil
+	}
+	names := make([]string, 0, len(d.tenants))
+	for name := range d.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Len returns the number of tenants
+func (d *Directory) Len() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.tenants)
+}
+
+// Close releases the clients of every tenant's providers
+func (d *Directory) Close() error {
+	if d == nil {
+		return nil
+	}
+	var errs []error
+	for _, t := range d.tenants {
+		if t.registry != nil {
+			errs = append(errs, t.registry.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewContext returns a context carrying the request's tenant
+func NewContext(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey, t)
+}
+
+// FromContext returns the request's tenant, if any
+func FromContext(ctx context.Context) *Tenant {
+	t, _ := ctx.Value(tenantKey).(*Tenant)
+	return t
+}
+
+// RegistryFor returns the providers serving a request: its tenant's own,
+// or shared
+func RegistryFor(ctx context.Context, shared *providers.Registry) *providers.Registry {
+	if t := FromContext(ctx); t != nil && t.registry != nil {
+		return t.registry
+	}
+	return shared
+}
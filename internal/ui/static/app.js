@@ -0,0 +1,334 @@
+// Operator dashboard. Reads the admin endpoints with the admin key, kept
+// for the browser session only.
+(function () {
+  'use strict';
+
+  const pageSize = 25;
+  const refreshInterval = 30000;
+
+  const state = {
+    key: sessionStorage.getItem('adminKey') || '',
+    offset: 0,
+    total: 0,
+    filters: {},
+    timer: null,
+  };
+
+  const $ = (selector) => document.querySelector(selector);
+
+  // el creates an element with text content; markup is never interpreted
+  function el(tag, text, className) {
+    const node = document.createElement(tag);
+    if (text !== undefined && text !== null) {
+      node.textContent = String(text);
+    }
+    if (className) {
+      node.className = className;
+    }
+    return node;
+  }
+
+  function row(cells) {
+    const tr = document.createElement('tr');
+    for (const cell of cells) {
+      const td = document.createElement('td');
+      if (cell instanceof Node) {
+        td.appendChild(cell);
+      } else {
+        td.textContent = cell === undefined || cell === null ? '' : String(cell);
+      }
+      tr.appendChild(td);
+    }
+    return tr;
+  }
+
+  function formatTime(value) {
+    return value ? new Date(value).toLocaleString() : '';
+  }
+
+  function formatCost(value) {
+    return value === undefined || value === null ? '' : '$' + Number(value).toFixed(4);
+  }
+
+  function setStatus(message) {
+    $('#status').textContent = message || '';
+  }
+
+  async function get(path) {
+    const response = await fetch(path, { headers: { 'X-Admin-Key': state.key } });
+    if (response.status === 401 || response.status === 403) {
+      disconnect();
+      throw new Error('The admin key was rejected');
+    }
+    const body = await response.json().catch(() => ({}));
+    if (!response.ok) {
+      throw new Error(body.error || response.statusText);
+    }
+    return body;
+  }
+
+  function renderProviders(providers) {
+    const body = $('#providers tbody');
+    body.replaceChildren();
+    for (const p of providers) {
+      const health = p.health || {};
+      body.appendChild(row([
+        p.name + (p.default ? ' (default)' : ''),
+        el('span', health.status, 'badge ' + health.status),
+        p.default_model,
+        health.consecutive_failures || 0,
+        formatTime(health.last_success),
+        health.check_error || health.last_error,
+      ]));
+    }
+  }
+
+  function renderJobs(jobs) {
+    const body = $('#jobs tbody');
+    body.replaceChildren();
+    for (const name of Object.keys(jobs || {}).sort()) {
+      const j = jobs[name];
+      body.appendChild(row([name, j.workers, j.running, j.waiting || 0, j.queued]));
+    }
+  }
+
+  // chart draws one bar per usage bucket
+  function chart(title, buckets, value, format) {
+    const ns = 'http://www.w3.org/2000/svg';
+    const container = el('div', null, 'chart');
+    const values = buckets.map(value);
+    const max = Math.max(...values, 0);
+    const total = values.reduce((sum, v) => sum + v, 0);
+    container.appendChild(el('h4', title + ': ' + format(total)));
+
+    const svg = document.createElementNS(ns, 'svg');
+    svg.setAttribute('viewBox', '0 0 ' + buckets.length + ' 100');
+    svg.setAttribute('preserveAspectRatio', 'none');
+    buckets.forEach((bucket, i) => {
+      const height = max > 0 ? (values[i] / max) * 100 : 0;
+      const rect = document.createElementNS(ns, 'rect');
+      rect.setAttribute('x', i + 0.1);
+      rect.setAttribute('y', 100 - height);
+      rect.setAttribute('width', 0.8);
+      rect.setAttribute('height', height);
+      const tip = document.createElementNS(ns, 'title');
+      tip.textContent = formatTime(bucket.start) + ': ' + format(values[i]);
+      rect.appendChild(tip);
+      svg.appendChild(rect);
+    });
+    container.appendChild(svg);
+    return container;
+  }
+
+  function renderUsage(summary) {
+    $('#usage-disabled').hidden = summary.history;
+    const charts = $('#charts');
+    charts.replaceChildren();
+    if (!summary.history) {
+      return;
+    }
+    const usage = summary.usage || [];
+    const count = (v) => Math.round(v).toLocaleString();
+    charts.appendChild(chart('Reviews', usage, (b) => b.reviews, count));
+    charts.appendChild(chart('Tokens', usage, (b) => b.prompt_tokens + b.completion_tokens, count));
+    charts.appendChild(chart('Cost', usage, (b) => b.cost_usd, formatCost));
+    charts.appendChild(chart('Findings', usage, (b) => b.diagnostics, count));
+  }
+
+  async function loadSummary() {
+    const summary = await get('/admin/dashboard?hours=' + encodeURIComponent($('#hours').value));
+    renderProviders(summary.providers || []);
+    renderJobs(summary.jobs);
+    renderUsage(summary);
+  }
+
+  async function loadReviews() {
+    const params = new URLSearchParams({ limit: pageSize, offset: state.offset });
+    for (const [name, value] of Object.entries(state.filters)) {
+      if (value) {
+        params.set(name, value);
+      }
+    }
+    const list = await get('/admin/reviews?' + params);
+    state.total = list.total;
+
+    const body = $('#reviews tbody');
+    body.replaceChildren();
+    for (const r of list.reviews) {
+      const tr = row([
+        formatTime(r.created_at),
+        r.tenant,
+        r.repository,
+        r.pr_number,
+        r.ai_provider + '/' + r.ai_model,
+        r.diagnostic_count,
+        r.latency_ms + ' ms',
+        (r.prompt_tokens || 0) + (r.completion_tokens || 0),
+        formatCost(r.cost_usd),
+      ]);
+      tr.addEventListener('click', () => {
+        const hash = '#/reviews/' + encodeURIComponent(r.id);
+        if (location.hash === hash) {
+          route();
+        } else {
+          location.hash = hash;
+        }
+      });
+      body.appendChild(tr);
+    }
+
+    const first = list.total === 0 ? 0 : state.offset + 1;
+    $('#page').textContent = first + '–' + (state.offset + list.reviews.length) + ' of ' + list.total;
+    $('#newer').disabled = state.offset === 0;
+    $('#older').disabled = state.offset + pageSize >= list.total;
+  }
+
+  // showReview opens a review's details, highlighting one diagnostic when
+  // selected is set
+  async function showReview(id, selected) {
+    const review = await get('/admin/reviews/' + encodeURIComponent(id));
+    $('#detail').hidden = false;
+    $('#detail-id').textContent = review.id;
+
+    const meta = $('#detail-meta');
+    meta.replaceChildren();
+    const fields = [
+      ['Completed', formatTime(review.created_at)],
+      ['Client', review.tenant],
+      ['Repository', review.repository],
+      ['Pull request', review.pr_number],
+      ['Branch', review.branch_name],
+      ['Commit', review.commit_hash],
+      ['Provider', review.ai_provider + '/' + review.ai_model],
+      ['Mode', review.review_mode],
+      ['Language', review.language],
+      ['Prompt version', review.prompt_version],
+      ['Diff size', review.diff_bytes + ' bytes'],
+      ['Latency', review.latency_ms + ' ms'],
+      ['Tokens', (review.prompt_tokens || 0) + ' in' + (review.cached_tokens ? ' (' + review.cached_tokens + ' cached)' : '') + ', ' + (review.completion_tokens || 0) + ' out'],
+      ['Cost', formatCost(review.cost_usd) + (review.batched ? ' (batch price)' : '')],
+    ];
+    for (const [name, value] of fields) {
+      if (value) {
+        meta.appendChild(el('dt', name));
+        meta.appendChild(el('dd', value));
+      }
+    }
+    $('#detail-overview').textContent = review.overview || '';
+
+    const verdicts = {};
+    for (const f of review.feedback || []) {
+      verdicts[f.diagnostic] = f.verdict + (f.comment ? ': ' + f.comment : '');
+    }
+    const diagnostics = $('#detail-diagnostics tbody');
+    diagnostics.replaceChildren();
+    let target = $('#detail');
+    (review.diagnostics || []).forEach((d, i) => {
+      const start = d.location.range.start.line;
+      const end = d.location.range.end.line;
+      const tr = row([
+        i,
+        el('span', d.severity, 'badge ' + d.severity),
+        d.location.path + ':' + start + (end && end !== start ? '-' + end : ''),
+        d.code && d.code.value,
+        d.message,
+        verdicts[i],
+      ]);
+      if (i === selected) {
+        tr.className = 'selected';
+        target = tr;
+      }
+      diagnostics.appendChild(tr);
+    });
+
+    const prompts = $('#detail-prompts');
+    prompts.replaceChildren();
+    if (!review.prompts || review.prompts.length === 0) {
+      prompts.appendChild(el('p', 'Prompts are not kept for this review; set HISTORY_PROMPTS=true to keep them.', 'muted'));
+    }
+    (review.prompts || []).forEach((p, i) => {
+      const details = document.createElement('details');
+      details.appendChild(el('summary', 'Call ' + (i + 1) + ': ' + p.language + ', ' + p.files.length + ' files, ~' + p.estimated_prompt_tokens + ' tokens' + (p.anonymized ? ', anonymized' : '')));
+      details.appendChild(el('h4', 'System prompt'));
+      details.appendChild(el('pre', p.system_prompt));
+      details.appendChild(el('h4', 'User prompt'));
+      details.appendChild(el('pre', p.user_prompt));
+      prompts.appendChild(details);
+    });
+    target.scrollIntoView({ behavior: 'smooth' });
+  }
+
+  // route opens the review a link such as #/reviews/{id}/diagnostics/{n}
+  // points at; diagnostics link back to their review this way
+  function route() {
+    const match = location.hash.match(/^#\/reviews\/([^/]+)(?:\/diagnostics\/(\d+))?$/);
+    if (!match || !state.key) {
+      return;
+    }
+    const selected = match[2] === undefined ? undefined : Number(match[2]);
+    showReview(decodeURIComponent(match[1]), selected).catch((err) => setStatus(err.message));
+  }
+
+  async function refresh() {
+    try {
+      await Promise.all([loadSummary(), loadReviews()]);
+      setStatus('');
+    } catch (err) {
+      setStatus(err.message);
+    }
+  }
+
+  function connect() {
+    $('#dashboard').hidden = false;
+    $('#logout').hidden = false;
+    $('#admin-key').hidden = true;
+    refresh();
+    route();
+    clearInterval(state.timer);
+    state.timer = setInterval(refresh, refreshInterval);
+  }
+
+  function disconnect() {
+    state.key = '';
+    sessionStorage.removeItem('adminKey');
+    clearInterval(state.timer);
+    $('#dashboard').hidden = true;
+    $('#logout').hidden = true;
+    $('#admin-key').hidden = false;
+  }
+
+  $('#login').addEventListener('submit', (event) => {
+    event.preventDefault();
+    state.key = $('#admin-key').value.trim();
+    $('#admin-key').value = '';
+    if (state.key) {
+      sessionStorage.setItem('adminKey', state.key);
+      connect();
+    }
+  });
+  $('#logout').addEventListener('click', disconnect);
+  $('#hours').addEventListener('change', () => loadSummary().catch((err) => setStatus(err.message)));
+  $('#filters').addEventListener('submit', (event) => {
+    event.preventDefault();
+    state.filters = Object.fromEntries(new FormData(event.target));
+    state.offset = 0;
+    loadReviews().catch((err) => setStatus(err.message));
+  });
+  $('#newer').addEventListener('click', () => {
+    state.offset = Math.max(0, state.offset - pageSize);
+    loadReviews().catch((err) => setStatus(err.message));
+  });
+  $('#older').addEventListener('click', () => {
+    state.offset += pageSize;
+    loadReviews().catch((err) => setStatus(err.message));
+  });
+  $('#close-detail').addEventListener('click', () => {
+    $('#detail').hidden = true;
+    history.replaceState(null, '', location.pathname + location.search);
+  });
+  window.addEventListener('hashchange', route);
+
+  if (state.key) {
+    connect();
+  }
+})();
@@ -0,0 +1,192 @@
+// Package vcr records provider HTTP traffic to fixture files and replays
+// it, so the whole pipeline from handler to provider to parser can be run
+// deterministically without calling a model
+package vcr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Modes
+const (
+	ModeOff    = "off"
+	ModeRecord = "record" // Call the provider and save each exchange
+	ModeReplay = "replay" // Answer from saved exchanges; never call the provider
+)
+
+// secretParams are query parameters left out of recorded URLs and request
+// matching, as they carry API keys
+var secretParams = []string{"key", "api_key", "access_token"}
+
+// Fixture is one recorded exchange
+type Fixture struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the part of a request that identifies it; headers,
+// which hold credentials, aren't kept
+type RecordedRequest struct {
+	Method string          `json:"method"`
+	URL    string          `json:"url"`
+	Body   json.RawMessage `json:"body,omitempty"` // JSON bodies, with sorted keys
+	Text   string          `json:"text,omitempty"` // Other bodies
+}
+
+// RecordedResponse is a provider's answer
+type RecordedResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body"`
+}
+
+// Transport is an http.RoundTripper that records or replays exchanges in a
+// directory, one JSON file per distinct request
+type Transport struct {
+	mode string
+	dir  string
+	next http.RoundTripper
+}
+
+// New creates a transport for the mode. Recording sends requests through
+// next, or http.DefaultTransport when nil.
+func New(mode, dir string, next http.RoundTripper) (*Transport, error) {
+	switch mode {
+	case ModeRecord, ModeReplay:
+	default:
+		return nil, fmt.Errorf("invalid VCR mode %q: must be record or replay", mode)
+	}
+	if mode == ModeRecord {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create VCR directory: %w", err)
+		}
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{mode: mode, dir: dir, next: next}, nil
+}
+
+// RoundTrip answers a request from its fixture, or sends it and records
+// the response
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(t.dir, recorded.key()+".json")
+
+	if t.mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("vcr: no recorded response for %s %s (%s): %w", recorded.Method, recorded.URL, filepath.Base(path), err)
+		}
+		var fixture Fixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			return nil, fmt.Errorf("vcr: failed to parse fixture %s: %w", path, err)
+		}
+		return fixture.Response.build(req), nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("vcr: failed to read response: %w", err)
+	}
+	fixture := Fixture{
+		Request: recorded,
+		Response: RecordedResponse{
+			Status:      resp.StatusCode,
+			ContentType: resp.Header.Get("Content-Type"),
+			Body:        string(body),
+		},
+	}
+	if data, err := json.MarshalIndent(fixture, "", "  "); err != nil {
+		log.Printf("Warning: vcr: failed to encode fixture: %v", err)
+	} else if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Printf("Warning: vcr: failed to write fixture: %v", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// recordRequest captures the method, URL without credentials and body of a
+// request, restoring the body for sending
+func recordRequest(req *http.Request) (RecordedRequest, error) {
+	u := *req.URL
+	query := u.Query()
+	for _, name := range secretParams {
+		query.Del(name)
+	}
+	u.RawQuery = query.Encode()
+	recorded := RecordedRequest{Method: req.Method, URL: u.String()}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return recorded, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return recorded, fmt.Errorf("vcr: failed to read request: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Re-encoding sorts object keys, so equal JSON matches whatever the
+	// field order
+	var value interface{}
+	if json.Unmarshal(body, &value) == nil {
+		if canonical, err := json.Marshal(value); err == nil {
+			recorded.Body = canonical
+			return recorded, nil
+		}
+	}
+	recorded.Text = string(body)
+	return recorded, nil
+}
+
+// key names the fixture of a request
+func (r RecordedRequest) key() string {
+	sum := sha256.New()
+	fmt.Fprintf(sum, "%s %s\n", r.Method, r.URL)
+	sum.Write(r.Body)
+	sum.Write([]byte(r.Text))
+	host := "request"
+	if u, err := url.Parse(r.URL); err == nil && u.Host != "" {
+		host = strings.ReplaceAll(u.Host, ":", "_")
+	}
+	return host + "-" + hex.EncodeToString(sum.Sum(nil))[:16]
+}
+
+// build turns a recorded response into the answer to req
+func (r RecordedResponse) build(req *http.Request) *http.Response {
+	header := make(http.Header)
+	if r.ContentType != "" {
+		header.Set("Content-Type", r.ContentType)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
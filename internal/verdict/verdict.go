@@ -0,0 +1,120 @@
+// Package verdict turns a review's findings into a pass, warn or fail
+// result, so CI pipelines can gate merges on a policy kept in one place
+package verdict
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/postprocess"
+)
+
+// Verdicts
+const (
+	Pass = "pass"
+	Warn = "warn"
+	Fail = "fail"
+)
+
+// Parse reads a policy written as comma-separated verdict:SEVERITY>N rules,
+// e.g. "fail:ERROR>0,warn:WARNING>5"
+func Parse(spec string) (*models.VerdictPolicy, error) {
+	policy := &models.VerdictPolicy{}
+	for _, rule := range strings.Split(spec, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		status, threshold, ok := strings.Cut(rule, ":")
+		severity, count, ok2 := strings.Cut(threshold, ">")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid verdict rule %q: use verdict:SEVERITY>N, e.g. fail:ERROR>0", rule)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if err != nil {
+			return nil, fmt.Errorf("invalid verdict rule %q: %q is not a number", rule, count)
+		}
+		severity = strings.ToUpper(strings.TrimSpace(severity))
+		switch strings.ToLower(strings.TrimSpace(status)) {
+		case Fail:
+			if policy.Fail == nil {
+				policy.Fail = make(map[string]int)
+			}
+			policy.Fail[severity] = n
+		case Warn:
+			if policy.Warn == nil {
+				policy.Warn = make(map[string]int)
+			}
+			policy.Warn[severity] = n
+		default:
+			return nil, fmt.Errorf("invalid verdict rule %q: the verdict must be fail or warn", rule)
+		}
+	}
+	if err := Validate(policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Validate checks the severities and limits of a policy
+func Validate(policy *models.VerdictPolicy) error {
+	for _, thresholds := range []map[string]int{policy.Fail, policy.Warn} {
+		for severity, limit := range thresholds {
+			switch strings.ToUpper(severity) {
+			case "INFO", "WARNING", "ERROR":
+			default:
+				return fmt.Errorf("invalid verdict severity %q: must be INFO, WARNING or ERROR", severity)
+			}
+			if limit < 0 {
+				return fmt.Errorf("verdict limit for %s must not be negative", severity)
+			}
+		}
+	}
+	return nil
+}
+
+// Evaluate applies a policy to the findings of a review. A threshold counts
+// the findings at or above its severity and is crossed when there are more
+// than its limit; the verdict is fail when any fail threshold is crossed,
+// warn when any warn threshold is, and pass otherwise.
+func Evaluate(diagnostics []models.Diagnostic, policy *models.VerdictPolicy) *models.Verdict {
+	counts := make(map[string]int)
+	for _, d := range diagnostics {
+		counts[d.Severity]++
+	}
+	atOrAbove := func(severity string) int {
+		n := 0
+		rank := postprocess.SeverityRank(severity)
+		for s, c := range counts {
+			if postprocess.SeverityRank(s) >= rank {
+				n += c
+			}
+		}
+		return n
+	}
+	crossed := func(thresholds map[string]int) []string {
+		var reasons []string
+		for severity, limit := range thresholds {
+			severity = strings.ToUpper(severity)
+			if n := atOrAbove(severity); n > limit {
+				reasons = append(reasons, fmt.Sprintf("%d findings at %s or above (limit %d)", n, severity, limit))
+			}
+		}
+		sort.Strings(reasons)
+		return reasons
+	}
+
+	verdict := &models.Verdict{Status: Pass, Counts: counts}
+	if policy == nil {
+		return verdict
+	}
+	if reasons := crossed(policy.Fail); len(reasons) > 0 {
+		verdict.Status, verdict.Reasons = Fail, reasons
+	} else if reasons := crossed(policy.Warn); len(reasons) > 0 {
+		verdict.Status, verdict.Reasons = Warn, reasons
+	}
+	return verdict
+}
@@ -0,0 +1,286 @@
+// Package webhook delivers signed JSON events to caller-supplied URLs
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Gateway-Event"
+	HeaderDelivery  = "X-Gateway-Delivery"
+	HeaderTimestamp = "X-Gateway-Timestamp"
+	HeaderSignature = "X-Gateway-Signature"
+)
+
+// errNotPublic marks a connection refused because the receiver resolved
+// to a non-public address; such deliveries aren't retried
+var errNotPublic = errors.New("refusing callback to non-public address")
+
+// attemptTimeout bounds a single delivery attempt
+const attemptTimeout = 10 * time.Second
+
+// Dispatcher signs and delivers events in the background, retrying
+// failed deliveries with exponential backoff
+type Dispatcher struct {
+	client       *http.Client
+	secret       []byte
+	maxAttempts  int
+	allowedHosts []string
+	allowHTTP    bool
+	backoff      time.Duration // Delay before the first retry; doubles after each
+	pending      sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher signing with secret. It returns nil
+// when secret is empty; a nil Dispatcher accepts no URLs. Callbacks must
+// use https unless allowHTTP is set. Loopback, private and link-local
+// addresses are refused when connecting, after DNS resolution, unless
+// their host is listed in allowedHosts. Redirects are not followed and
+// callbacks don't go through HTTP(S)_PROXY.
+func NewDispatcher(secret string, maxAttempts int, allowedHosts []string, allowHTTP bool) *Dispatcher {
+	if secret == "" {
+		return nil
+	}
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	d := &Dispatcher{
+		secret:       []byte(secret),
+		maxAttempts:  maxAttempts,
+		allowedHosts: allowedHosts,
+		allowHTTP:    allowHTTP,
+		backoff:      time.Second,
+	}
+	d.client = &http.Client{
+		Timeout: attemptTimeout,
+		Transport: &http.Transport{
+			DialContext:           d.dial,
+			TLSHandshakeTimeout:   attemptTimeout,
+			ResponseHeaderTimeout: attemptTimeout,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConnsPerHost:   2,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return d
+}
+
+// CheckURL reports why events can't be sent to rawURL, if they can't
+func (d *Dispatcher) CheckURL(rawURL string) error {
+	if d == nil {
+		return fmt.Errorf("callbacks are disabled; WEBHOOK_SECRET is not configured")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("callback URL must be an absolute https URL")
+	}
+	if u.Scheme == "http" && !d.allowHTTP {
+		return fmt.Errorf("callback URL must use https; set WEBHOOK_ALLOW_HTTP to allow http")
+	}
+	host := strings.ToLower(u.Hostname())
+	if d.allowed(host) {
+		return nil
+	}
+	if len(d.allowedHosts) > 0 {
+		return fmt.Errorf("callback host %s is not in WEBHOOK_ALLOWED_HOSTS", host)
+	}
+	if ip, err := netip.ParseAddr(host); err == nil && !publicAddr(ip) {
+		return fmt.Errorf("callback host %s is not a public address; add it to WEBHOOK_ALLOWED_HOSTS to allow it", host)
+	}
+	return nil
+}
+
+// allowed reports whether host is listed in WEBHOOK_ALLOWED_HOSTS
+func (d *Dispatcher) allowed(host string) bool {
+	for _, allowed := range d.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// dial connects to a callback receiver. Hosts listed in
+// WEBHOOK_ALLOWED_HOSTS may resolve to any address; other hosts are
+// checked against the address actually dialled, so a name that
+// re-resolves to an internal address is still refused.
+func (d *Dispatcher) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: attemptTimeout, KeepAlive: 30 * time.Second}
+	if host, _, err := net.SplitHostPort(addr); err != nil || !d.allowed(strings.ToLower(host)) {
+		dialer.Control = refusePrivate
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// refusePrivate is a net.Dialer Control function rejecting connections to
+// addresses that aren't publicly routable
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("refusing callback to %s: %w", address, err)
+	}
+	if !publicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w %s", errNotPublic, addrPort.Addr())
+	}
+	return nil
+}
+
+// Ranges that IsGlobalUnicast and IsPrivate let through but aren't
+// reachable on the internet
+var (
+	thisNetwork        = netip.MustParsePrefix("0.0.0.0/8")
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+)
+
+// publicAddr reports whether ip is a publicly routable unicast address
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !thisNetwork.Contains(ip) && !sharedAddressSpace.Contains(ip)
+}
+
+// Go runs fn in the background; Wait waits for it
+func (d *Dispatcher) Go(fn func()) {
+	d.pending.Add(1)
+	go func() {
+		defer d.pending.Done()
+		fn()
+	}()
+}
+
+// Send delivers an event to rawURL in the background
+func (d *Dispatcher) Send(rawURL, event string, payload interface{}) {
+	d.Go(func() {
+		if err := d.Deliver(context.Background(), rawURL, event, payload); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	})
+}
+
+// Deliver posts an event to rawURL, retrying network errors, 429 and 5xx
+// responses up to the configured number of attempts
+func (d *Dispatcher) Deliver(ctx context.Context, rawURL, event string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event, err)
+	}
+	delivery := randomID()
+
+	delay := d.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(ctx, rawURL, event, delivery, body)
+		if err == nil {
+			log.Printf("✓ Delivered %s event %s to %s", event, delivery, redactURL(rawURL))
+			return nil
+		}
+		if !retry || attempt >= d.maxAttempts {
+			return fmt.Errorf("failed to deliver %s event %s to %s after %d attempts: %w", event, delivery, redactURL(rawURL), attempt, err)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("failed to deliver %s event %s: %w", event, delivery, ctx.Err())
+		}
+		delay *= 2
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying
+func (d *Dispatcher) post(ctx context.Context, rawURL, event, delivery string, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, attemptTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ai-gateway-webhook")
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderDelivery, delivery)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(d.secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return !errors.Is(err, errNotPublic), err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("receiver returned %s", resp.Status)
+}
+
+// Wait blocks until background deliveries finish or ctx is done
+func (d *Dispatcher) Wait(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Sign returns the signature header value for a delivery: the hex
+// HMAC-SHA256 of the timestamp, a dot and the body
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// redactURL drops the query string and credentials, which often carry
+// tokens, from a URL for logging
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "callback URL"
+	}
+	return u.Scheme + "://" + u.Host + u.Path
+}
+
+// randomID returns a random delivery ID
+func randomID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
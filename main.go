@@ -1,22 +1,80 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/analytics"
 	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/config"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/dedup"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/feedback"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/guidelines"
 	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/handlers"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/history"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/ingest"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/jobs"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/knowledge"
 	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/notify"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/openapi"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/periodic"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/policy"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/pricing"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
 	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
-	"github.com/joho/godotenv"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/quota"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/ratelimit"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/redis"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/routing"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scheduler"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/session"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/shadow"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/tenants"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/ui"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/vcr"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/verdict"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/webhook"
 )
 
 func main() {
 	// Load .env file if it exists (for local development)
-	_ = godotenv.Load()
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file; environment variables override its settings")
+	newKey := flag.String("new-key", "", "Generate a client key with this name, print its secret and API_KEY_STORE entry, and exit")
+	keyOwner := flag.String("key-owner", "", "Owner recorded for -new-key")
+	keyScopes := flag.String("key-scopes", "", "Comma-separated endpoints the -new-key key may call, e.g. review,ask")
+	keyModels := flag.String("key-models", "", "Comma-separated provider/model patterns the -new-key key may use, e.g. google/*")
+	keyTenant := flag.String("key-tenant", "", "Tenant the -new-key key belongs to")
+	keyTier := flag.String("key-tier", "", "Rate-limit tier of the -new-key key, from RATE_LIMIT_TIERS")
+	flag.Parse()
+
+	if *newKey != "" {
+		printNewKey(*newKey, *keyOwner, *keyScopes, *keyModels, *keyTenant, *keyTier)
+		return
+	}
+
+	dotEnv := loadDotEnv()
 
 	// Load configuration
+	if *configFile != "" {
+		if err := config.LoadFile(*configFile); err != nil {
+			log.Fatalf("Configuration error: %v", err)
+		}
+		log.Printf("✓ Configuration file %s loaded", *configFile)
+	}
 	cfg := config.Load()
 
 	// Validate configuration
@@ -24,70 +82,802 @@ func main() {
 		log.Fatalf("Configuration error: %v", err)
 	}
 
+	// Load custom prompt templates if configured
+	if cfg.PromptTemplateDir != "" {
+		templates, err := prompt.LoadTemplates(cfg.PromptTemplateDir)
+		if err != nil {
+			log.Fatalf("Prompt template error: %v", err)
+		}
+		prompt.SetTemplates(templates)
+		log.Printf("✓ Custom prompt templates loaded from %s", cfg.PromptTemplateDir)
+	}
+
+	// Register versioned prompts and start the experiment between them
+	if cfg.PromptVersionsDir != "" {
+		versions, err := prompt.LoadVersions(cfg.PromptVersionsDir)
+		if err != nil {
+			log.Fatalf("Prompt template error: %v", err)
+		}
+		experiment, err := prompt.ParseExperiment(cfg.PromptExperiment, versions)
+		if err != nil {
+			log.Fatalf("Configuration error: PROMPT_EXPERIMENT: %v", err)
+		}
+		prompt.SetVersions(versions, experiment)
+		log.Printf("✓ Prompt versions loaded from %s: %s", cfg.PromptVersionsDir, strings.Join(prompt.Versions(), ", "))
+	}
+
+	// Replace the built-in review categories if configured
+	if cfg.CategoriesFile != "" {
+		taxonomy, err := prompt.LoadTaxonomy(cfg.CategoriesFile)
+		if err != nil {
+			log.Fatalf("Configuration error: %v", err)
+		}
+		prompt.SetTaxonomy(taxonomy)
+		log.Printf("✓ %d review categories loaded from %s", len(taxonomy.Categories), cfg.CategoriesFile)
+	}
+
+	if _, err := verdict.Parse(cfg.VerdictPolicy); err != nil {
+		log.Fatalf("Configuration error: VERDICT_POLICY: %v", err)
+	}
+
+	// Override model prices used for cost estimates
+	if cfg.ModelPricing != "" {
+		prices, err := pricing.Parse(cfg.ModelPricing)
+		if err != nil {
+			log.Fatalf("Configuration error: %v", err)
+		}
+		pricing.SetPrices(prices)
+	}
+
 	// Initialize AI providers
 	providerRegistry := providers.NewRegistry()
+	providers.SetMaxContinuations(cfg.MaxContinuations)
+	providers.SetJSONRepairRetry(cfg.JSONRepairRetry)
+
+	// Register the providers listed in the manifest, or the built-in
+	// google, openai and anthropic providers whose API keys are set
+	manifest := defaultManifest(cfg)
+	if cfg.ProvidersFile != "" {
+		loaded, err := providers.LoadManifest(cfg.ProvidersFile)
+		if err != nil {
+			log.Fatalf("Configuration error: %v", err)
+		}
+		manifest = loaded
+	}
+	timeouts, err := providers.ParseTimeouts(cfg.ProviderTimeouts)
+	if err == nil {
+		err = manifest.SetTimeouts(timeouts)
+	}
+	if err != nil {
+		log.Fatalf("Configuration error: PROVIDER_TIMEOUTS: %v", err)
+	}
+	baseTransport := providers.ConfigureHTTP(providers.HTTPConfig{
+		Timeout:             time.Duration(cfg.HTTPTimeout) * time.Second,
+		DialTimeout:         time.Duration(cfg.HTTPDialTimeout) * time.Second,
+		TLSHandshakeTimeout: time.Duration(cfg.HTTPTLSTimeout) * time.Second,
+		IdleConnTimeout:     time.Duration(cfg.HTTPIdleTimeout) * time.Second,
+		MaxIdleConnsPerHost: cfg.HTTPIdleConns,
+		DisableHTTP2:        !cfg.HTTP2,
+	})
+	if cfg.VCRMode != vcr.ModeOff {
+		transport, err := vcr.New(cfg.VCRMode, cfg.VCRDir, baseTransport)
+		if err != nil {
+			log.Fatalf("Configuration error: %v", err)
+		}
+		providers.SetHTTPTransport(transport)
+		log.Printf("✓ VCR %s mode: provider traffic uses fixtures in %s", cfg.VCRMode, cfg.VCRDir)
+	}
+	for _, name := range manifest.Register(providerRegistry, config.Secret) {
+		log.Printf("✓ Provider %s registered", name)
+	}
+	if cfg.MockProvider {
+		mock, err := providers.NewMockProvider(providers.MockConfig{
+			ResponseFile: cfg.MockResponseFile,
+			Latency:      time.Duration(cfg.MockLatency) * time.Millisecond,
+		})
+		if err != nil {
+			log.Fatalf("Configuration error: %v", err)
+		}
+		providerRegistry.Register("mock", mock)
+		log.Printf("✓ Mock provider registered; its reviews call no model")
+	}
+
+	if len(providerRegistry.List()) == 0 {
+		log.Fatal("No AI providers configured. Please set at least one API key.")
+	}
+
+	// Register the ensemble provider when two or more providers are available
+	ensembleEntries := cfg.EnsembleProviders
+	if len(ensembleEntries) == 0 {
+		// The mock only joins an ensemble when listed
+		ensembleEntries = slices.DeleteFunc(providerRegistry.List(), func(name string) bool { return name == "mock" })
+		sort.Strings(ensembleEntries)
+		if len(ensembleEntries) > 3 {
+			ensembleEntries = ensembleEntries[:3]
+		}
+	}
+	if len(ensembleEntries) >= 2 {
+		members, err := providers.ParseEnsembleMembers(ensembleEntries, providerRegistry)
+		if err != nil {
+			log.Fatalf("Ensemble configuration error: %v", err)
+		}
+		for _, m := range members {
+			if cfg.ShouldAnonymize(m.Name) {
+				cfg.AnonymizeProviders = append(cfg.AnonymizeProviders, "ensemble")
+				break
+			}
+		}
+		providerRegistry.Register("ensemble", providers.NewEnsembleProvider(members))
+		log.Printf("✓ Ensemble provider registered (%s)", strings.Join(ensembleEntries, ", "))
+	}
+
+	// Verify provider keys, so a bad key shows as unhealthy before the
+	// first review fails on it. Warming up runs the first check before
+	// serving, leaving a connection open to each provider. Replayed
+	// traffic has no checks to answer.
+	healthChecks, stopHealthChecks := context.WithCancel(context.Background())
+	defer stopHealthChecks()
+	if cfg.VCRMode != vcr.ModeReplay {
+		interval := time.Duration(cfg.HealthCheckInterval) * time.Second
+		switch {
+		case cfg.ProviderWarmup:
+			started := time.Now()
+			providerRegistry.CheckHealth(healthChecks)
+			log.Printf("✓ Providers warmed up in %v", time.Since(started).Round(time.Millisecond))
+			if interval > 0 {
+				go providerRegistry.WatchHealth(healthChecks, interval)
+			}
+		case interval > 0:
+			go func() {
+				providerRegistry.CheckHealth(healthChecks)
+				providerRegistry.WatchHealth(healthChecks, interval)
+			}()
+		}
+	}
 
-	// Register Google Gemini provider if API key is available
-	if cfg.GoogleAPIKey != "" {
-		geminiProvider, err := providers.NewGeminiProvider(cfg.GoogleAPIKey)
+	// Resolve default models and aliases, failing fast on models the
+	// providers don't offer
+	providerRegistry.SetDefaults(cfg.DefaultProvider, cfg.DefaultModel)
+	providerRegistry.AllowUnlistedModels(cfg.AllowUnlistedModels)
+	if _, err := providerRegistry.Get(cfg.DefaultProvider); err == nil {
+		if _, _, err := providerRegistry.Resolve("", ""); err != nil {
+			log.Fatalf("Configuration error: DEFAULT_AI_MODEL: %v", err)
+		}
+	}
+	aliases, err := providers.ParseAliases(cfg.ModelAliases)
+	if err != nil {
+		log.Fatalf("Configuration error: MODEL_ALIASES: %v", err)
+	}
+	for name, alias := range aliases {
+		if err := providerRegistry.SetAlias(name, alias); err != nil {
+			log.Fatalf("Configuration error: MODEL_ALIASES: %v", err)
+		}
+	}
+	if len(aliases) > 0 {
+		log.Printf("✓ %d model aliases configured", len(aliases))
+	}
+	if cfg.RoutingFile != "" {
+		router, err := routing.Load(cfg.RoutingFile)
+		if err == nil {
+			err = router.Check(providerRegistry)
+		}
 		if err != nil {
-			log.Printf("Warning: Failed to initialize Gemini provider: %v", err)
+			log.Fatalf("Configuration error: ROUTING_FILE: %v", err)
+		}
+		routing.Set(router)
+		log.Printf("✓ %d routing rules loaded from %s", len(router.Routes), cfg.RoutingFile)
+	}
+
+	// Initialize rate limiting and scheduling
+	tiers, err := ratelimit.ParseTiers(cfg.RateLimitTiers)
+	if err != nil {
+		log.Fatalf("Configuration error: RATE_LIMIT_TIERS: %v", err)
+	}
+	keyTiers, err := ratelimit.ParseKeyTiers(cfg.APIKeyTiers, tiers)
+	if err != nil {
+		log.Fatalf("Configuration error: API_KEY_TIERS: %v", err)
+	}
+	limiter, err := ratelimit.NewLimiter(tiers, cfg.DefaultRateLimitTier)
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+	reviewScheduler := scheduler.New(cfg.MaxConcurrentReviews)
+
+	// Load tenants and build their own providers
+	var directory *tenants.Directory
+	if cfg.TenantsFile != "" {
+		directory, err = tenants.Load(cfg.TenantsFile, config.Secret, cfg.AllowUnlistedModels)
+		if err != nil {
+			log.Fatalf("Configuration error: %v", err)
+		}
+		for _, name := range directory.Names() {
+			t, _ := directory.Get(name)
+			if _, ok := limiter.Tier(t.RateLimitTier); t.RateLimitTier != "" && !ok {
+				log.Fatalf("Configuration error: tenant %s: unknown rate_limit_tier %q", name, t.RateLimitTier)
+			}
+		}
+		log.Printf("✓ %d tenants loaded from %s", directory.Len(), cfg.TenantsFile)
+	}
+
+	// Load the provider policy
+	var rules *policy.Policy
+	if cfg.PolicyFile != "" {
+		if rules, err = policy.Load(cfg.PolicyFile); err != nil {
+			log.Fatalf("Configuration error: %v", err)
+		}
+		log.Printf("✓ %d provider policy rules loaded from %s", rules.Len(), cfg.PolicyFile)
+	}
+
+	quotas, err := quota.NewTracker(cfg.QuotaStorePath)
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+
+	// Create handlers
+	recorder := analytics.NewRecorder()
+	if cfg.ShadowPromptVersion != "" && !prompt.HasVersion(cfg.ShadowPromptVersion) {
+		log.Fatalf("Configuration error: SHADOW_PROMPT_VERSION: unknown prompt version %q", cfg.ShadowPromptVersion)
+	}
+	shadower := shadow.NewShadower(providerRegistry, shadow.Config{
+		Provider:      cfg.ShadowProvider,
+		Model:         cfg.ShadowModel,
+		ReviewMode:    cfg.ShadowReviewMode,
+		PromptVersion: cfg.ShadowPromptVersion,
+		Percent:       cfg.ShadowPercent,
+		OutputPath:    cfg.ShadowOutputPath,
+		Anonymize:     cfg.ShouldAnonymize(cfg.ShadowProvider),
+		Timeout:       time.Duration(cfg.ReviewTimeout) * time.Second,
+		MaxConcurrent: cfg.ShadowMaxConcurrent,
+	})
+	if shadower != nil {
+		if _, err := providerRegistry.Get(cfg.ShadowProvider); err != nil {
+			log.Printf("Warning: SHADOW_PROVIDER: %v; no reviews will be mirrored until it is available", err)
+		}
+		log.Printf("✓ Shadowing %.1f%% of reviews to %s", cfg.ShadowPercent, cfg.ShadowProvider)
+	}
+	knowledgeStore, err := newKnowledgeStore(cfg, providerRegistry)
+	if err != nil {
+		log.Fatalf("Knowledge base error: %v", err)
+	}
+	library, err := guidelines.NewLibrary(cfg.GuidelinesDir)
+	if err != nil {
+		log.Fatalf("Guidelines error: %v", err)
+	}
+	if n := len(library.List()); n > 0 {
+		log.Printf("✓ %d team guidelines loaded from %s", n, cfg.GuidelinesDir)
+	}
+	notifier := notify.NewDispatcher(notificationRoutes(cfg))
+	webhooks := webhook.NewDispatcher(cfg.WebhookSecret, cfg.WebhookMaxAttempts, cfg.WebhookAllowedHosts, cfg.WebhookAllowHTTP)
+	jobQueue := jobs.New(cfg.JobInteractiveWorkers, cfg.JobBatchWorkers, cfg.JobQueueSize)
+	if cfg.JobRedisURL != "" {
+		client, err := redis.New(cfg.JobRedisURL)
+		if err == nil {
+			jobQueue, err = jobs.NewDurable(cfg.JobInteractiveWorkers, cfg.JobBatchWorkers, cfg.JobQueueSize, client, cfg.JobRedisPrefix)
+		}
+		if err != nil {
+			log.Fatalf("Failed to set up the Redis job queue: %v", err)
+		}
+		log.Printf("✓ Asynchronous reviews queued in Redis under %s", cfg.JobRedisPrefix)
+	}
+	expvar.Publish("review_job_queues", expvar.Func(func() interface{} { return jobQueue.Stats() }))
+	sessions := session.NewStore(time.Duration(cfg.FollowupTTLHours)*time.Hour, cfg.FollowupMaxReviews)
+	reviewHistory, err := history.NewStore(cfg.HistoryDatabase, cfg.HistoryMaxReviews)
+	if err != nil {
+		log.Fatalf("Review history error: %v", err)
+	}
+	verdicts, err := feedback.NewStore(cfg.FeedbackPath)
+	if err != nil {
+		log.Fatalf("Feedback store error: %v", err)
+	}
+	var coordinator dedup.Coordinator
+	if cfg.DedupReviews {
+		ttl := time.Duration(cfg.DedupTTL) * time.Second
+		redisURL := cfg.DedupRedisURL
+		if redisURL == "" {
+			redisURL = cfg.JobRedisURL
+		}
+		if redisURL == "" {
+			coordinator = dedup.NewLocal(ttl)
+			log.Printf("✓ Duplicate reviews shared within this replica for %v", ttl)
 		} else {
-			providerRegistry.Register("google", geminiProvider)
-			log.Println("✓ Gemini provider registered")
+			client, err := redis.New(redisURL)
+			if err != nil {
+				log.Fatalf("Failed to connect to Redis for duplicate reviews: %v", err)
+			}
+			coordinator = dedup.NewRedis(client, "ai-review:dedup", ttl)
+			log.Printf("✓ Duplicate reviews shared across replicas through Redis for %v", ttl)
 		}
 	}
+	handler := handlers.NewReviewHandler(providerRegistry, cfg, recorder, shadower, reviewScheduler, knowledgeStore, library, sessions, reviewHistory, verdicts, rules, webhooks, jobQueue, coordinator, notifier)
+	historyHandler := handlers.NewHistoryHandler(reviewHistory, verdicts)
+	guidelinesHandler := handlers.NewGuidelinesHandler(library)
+	indexHandler := handlers.NewIndexHandler(knowledgeStore, cfg)
+	analyticsHandler := handlers.NewAnalyticsHandler(recorder, cfg)
+	askHandler := handlers.NewAskHandler(providerRegistry, cfg, reviewScheduler, rules)
+	generateHandler := handlers.NewGenerateHandler(providerRegistry, cfg, reviewScheduler, rules)
+	providersHandler := handlers.NewProvidersHandler(providerRegistry, cfg)
+	maintenance := middleware.NewMaintenanceMode(cfg.ReadOnly)
+	adminHandler := handlers.NewAdminHandler(maintenance)
+	dashboardHandler := handlers.NewDashboardHandler(providerRegistry, reviewHistory, jobQueue)
 
-	// Register OpenAI provider if API key is available
-	if cfg.OpenAIAPIKey != "" {
-		openaiProvider := providers.NewOpenAIProvider(cfg.OpenAIAPIKey)
-		providerRegistry.Register("openai", openaiProvider)
-		log.Println("✓ OpenAI provider registered")
+	// Scheduled reviews run on the batch workers until shutdown starts
+	schedules, stopSchedules := context.WithCancel(context.Background())
+	defer stopSchedules()
+	var scheduledRunner *periodic.Runner
+	if cfg.ScheduledReviewsFile != "" {
+		file, err := periodic.Load(cfg.ScheduledReviewsFile)
+		if err == nil {
+			for _, s := range file.Reviews {
+				if _, _, err = providerRegistry.Resolve(s.AIProvider, s.AIModel); err != nil {
+					err = fmt.Errorf("scheduled review %s: %w", s.Name, err)
+					break
+				}
+			}
+		}
+		if err != nil {
+			log.Fatalf("Configuration error: SCHEDULED_REVIEWS_FILE: %v", err)
+		}
+		scheduledRunner = periodic.NewRunner(file, handler.ResolveScheduled, handler.SubmitScheduled)
+		scheduledRunner.Start(schedules)
+		log.Printf("✓ %d reviews scheduled from %s", len(file.Reviews), cfg.ScheduledReviewsFile)
+	}
+	scheduledHandler := handlers.NewScheduledHandler(scheduledRunner)
+	if jobQueue.Durable() {
+		jobQueue.Handle(handler.RunQueued(directory, quotas))
 	}
 
-	// Register Anthropic Claude provider if API key is available
-	if cfg.AnthropicAPIKey != "" {
-		claudeProvider := providers.NewClaudeProvider(cfg.AnthropicAPIKey)
-		providerRegistry.Register("anthropic", claudeProvider)
-		log.Println("✓ Claude provider registered")
+	// Review requests from the message queue are consumed until shutdown
+	// starts, like scheduled reviews
+	var consumer *ingest.Consumer
+	var broker ingest.Broker
+	if cfg.IngestBroker != "" {
+		broker, err = ingest.New(ingest.Config{
+			Broker:       cfg.IngestBroker,
+			URL:          cfg.IngestURL,
+			Username:     cfg.IngestUsername,
+			Password:     cfg.IngestPassword,
+			Topic:        cfg.IngestTopic,
+			ResultsTopic: cfg.IngestResultsTopic,
+			Group:        cfg.IngestGroup,
+		})
+		if err != nil {
+			log.Fatalf("Failed to connect to the %s broker: %v", cfg.IngestBroker, err)
+		}
+		consumer = ingest.NewConsumer(broker, cfg.IngestResultsTopic, cfg.IngestConcurrency, handler.ReviewIngested)
+		consumer.Start(schedules)
+		log.Printf("✓ Consuming review requests from %s %s (group %s), publishing results to %s", cfg.IngestBroker, cfg.IngestTopic, cfg.IngestGroup, cfg.IngestResultsTopic)
 	}
 
-	if len(providerRegistry.List()) == 0 {
-		log.Fatal("No AI providers configured. Please set at least one API key.")
+	validator, err := openapi.NewValidator()
+	if err != nil {
+		log.Fatalf("OpenAPI error: %v", err)
 	}
 
-	// Create handler
-	handler := handlers.NewReviewHandler(providerRegistry, cfg)
+	// Gateway credentials, replaced when the configuration is reloaded
+	apiKeys := middleware.NewKeySet()
+	var keyStore *middleware.KeyStore
+	if cfg.AcceptsAPIKeys() {
+		keyStore = middleware.NewKeyStore(apiKeys, cfg.APIKeyStore)
+		if err := keyStore.Load(middleware.HashKeys(cfg.APIKeys, keyTiers)); err != nil {
+			log.Fatalf("Configuration error: %v", err)
+		}
+		log.Printf("✓ %d client API keys loaded", len(keyStore.List()))
+	}
+	adminKeys := middleware.NewKeySet(cfg.AdminAPIKey)
+	configReloader := &reloader{
+		cfg:       cfg,
+		manifest:  manifest,
+		registry:  providerRegistry,
+		keyStore:  keyStore,
+		adminKeys: adminKeys,
+		limiter:   limiter,
+		scheduler: reviewScheduler,
+		dotEnv:    dotEnv,
+	}
+	go configReloader.watch(time.Duration(cfg.ConfigWatchInterval) * time.Second)
+
+	var tokens *middleware.TokenVerifier
+	if cfg.AcceptsTokens() {
+		tokens, err = middleware.NewTokenVerifier(context.Background(), middleware.TokenConfig{
+			Issuer:      cfg.JWTIssuer,
+			JWKSURL:     cfg.JWTJWKSURL,
+			Audience:    cfg.JWTAudience,
+			Scopes:      cfg.JWTRequiredScopes,
+			ClientClaim: cfg.JWTClientClaim,
+		})
+		if err != nil {
+			log.Fatalf("Configuration error: %v", err)
+		}
+		log.Printf("✓ Accepting bearer tokens from %s", cfg.JWTIssuer)
+	}
+
+	keysHandler := handlers.NewKeysHandler(keyStore, directory, limiter)
+	healthHandler := handlers.NewHealthHandler(readinessChecks(cfg, manifest, providerRegistry, reviewHistory)...)
 
 	// Setup routes
 	mux := http.NewServeMux()
-	mux.HandleFunc("/health", healthCheckHandler)
+	mux.HandleFunc("/health", healthCheckHandler(maintenance))
+	mux.HandleFunc("/healthz", healthHandler.HandleLiveness)
+	mux.HandleFunc("/readyz", healthHandler.HandleReadiness)
+	mux.HandleFunc("/openapi.json", openAPIHandler)
+	mux.Handle("/ui", ui.Handler())
+	mux.Handle("/ui/", ui.Handler())
 	mux.HandleFunc("/review", handler.HandleReview)
+	mux.HandleFunc("/review/batch", handler.HandleBatch)
+	mux.HandleFunc("/review/compare", handler.HandleCompare)
+	mux.HandleFunc("/review/estimate", handler.HandleEstimate)
+	mux.HandleFunc("/review/dry-run", handler.HandleDryRun)
+	mux.HandleFunc("/eval/run", handler.HandleEval)
+	mux.HandleFunc("/review/", handler.HandleFollowup)
+	mux.HandleFunc("/reviews", historyHandler.HandleReviews)
+	mux.HandleFunc("/reviews/", historyHandler.HandleReviews)
+	mux.HandleFunc("/prompts", historyHandler.HandlePrompts)
+	mux.HandleFunc("/ask", askHandler.HandleAsk)
+	mux.HandleFunc("/generate/pr-description", generateHandler.HandlePRDescription)
+	mux.HandleFunc("/generate/docs", generateHandler.HandleDocs)
+	mux.HandleFunc("/providers", providersHandler.HandleProviders)
+	mux.HandleFunc("/models", providersHandler.HandleModels)
+	mux.HandleFunc("/analytics", analyticsHandler.HandleAnalytics)
+	mux.HandleFunc("/index", indexHandler.HandleIndex)
+	mux.HandleFunc("/quota", handlers.HandleQuota)
+	// Every /admin/ path, including unknown ones, requires the admin key;
+	// APIKeyAuth leaves these to AdminAuth
+	adminMux := http.NewServeMux()
+	adminMux.HandleFunc("/admin/maintenance", adminHandler.HandleMaintenance)
+	adminMux.Handle("/admin/metrics", expvar.Handler())
+	adminMux.HandleFunc("/admin/dashboard", dashboardHandler.HandleSummary)
+	adminMux.HandleFunc("/admin/reviews", historyHandler.HandleAdminReviews)
+	adminMux.HandleFunc("/admin/reviews/", historyHandler.HandleAdminReviews)
+	adminMux.HandleFunc("/admin/guidelines", guidelinesHandler.HandleGuidelines)
+	adminMux.HandleFunc("/admin/guidelines/", guidelinesHandler.HandleGuidelines)
+	adminMux.HandleFunc("/admin/providers", providersHandler.HandleProviderKeys)
+	adminMux.HandleFunc("/admin/aliases", providersHandler.HandleAliases)
+	adminMux.HandleFunc("/admin/aliases/", providersHandler.HandleAliases)
+	adminMux.HandleFunc("/admin/keys", keysHandler.HandleKeys)
+	adminMux.HandleFunc("/admin/keys/", keysHandler.HandleKeys)
+	adminMux.HandleFunc("/admin/scheduled-reviews", scheduledHandler.HandleScheduled)
+	adminMux.HandleFunc("/admin/scheduled-reviews/", scheduledHandler.HandleScheduled)
+	mux.Handle("/admin/", middleware.AdminAuth(adminMux, adminKeys))
 
 	// Apply middleware
-	httpHandler := middleware.Logging(
-		middleware.CORS(
-			middleware.APIKeyAuth(mux, cfg.APIKeys),
+	httpHandler := middleware.AssignRequestID(
+		middleware.Logging(
+			middleware.Compression(
+				middleware.Versioning(
+					middleware.Recover(
+						middleware.CORS(
+							middleware.APIKeyAuth(
+								middleware.KeyScopes(
+									middleware.Tenants(
+										middleware.RateLimit(
+											middleware.ReadOnly(
+												middleware.Quotas(
+													middleware.Idempotency(
+														middleware.LimitBody(
+															middleware.ValidateRequests(mux, validator, middleware.MultipartMemory),
+															cfg.RequestSizeLimit(),
+														),
+														middleware.NewIdempotencyCache(10*time.Minute),
+													),
+													quotas,
+													quota.Limit{Tokens: cfg.DefaultTokenQuota, CostUSD: cfg.DefaultCostQuotaUSD},
+												),
+												maintenance,
+											),
+											limiter,
+										),
+										directory,
+									),
+								),
+								apiKeys,
+								tokens,
+							),
+						),
+					),
+				),
+				cfg.CompressResponses,
+			),
 		),
 	)
 
+	tlsConfig, challenge, err := serverTLS(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure TLS: %v", err)
+	}
+	if challenge != nil && cfg.TLSAutocertHTTPAddr != "" {
+		go serveChallenges(cfg.TLSAutocertHTTPAddr, challenge)
+	}
+
 	// Start server
 	addr := fmt.Sprintf(":%s", cfg.Port)
-	log.Printf("🚀 AI Gateway server starting on %s", addr)
+	scheme := "HTTP"
+	if tlsConfig != nil {
+		scheme = "HTTPS"
+		if tlsConfig.ClientCAs != nil {
+			scheme = "HTTPS with client certificates"
+		}
+	}
+	log.Printf("🚀 AI Gateway server starting on %s (%s)", addr, scheme)
 	log.Printf("📋 Available providers: %v", providerRegistry.List())
+	if maintenance.Enabled() {
+		log.Println("⚠️  Read-only maintenance mode is enabled; new reviews will be rejected")
+	}
+
+	// No write timeout: reviews may run for MAX_REVIEW_TIMEOUT and stream
+	// their results, and each handler bounds its own work
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           httpHandler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: time.Duration(cfg.ReadHeaderTimeout) * time.Second,
+		ReadTimeout:       time.Duration(cfg.RequestReadTimeout()) * time.Second,
+		IdleTimeout:       time.Duration(cfg.IdleTimeout) * time.Second,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			serverErr <- server.ListenAndServeTLS("", "")
+			return
+		}
+		serverErr <- server.ListenAndServe()
+	}()
 
-	if err := http.ListenAndServe(addr, httpHandler); err != nil {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err := <-serverErr:
 		log.Fatalf("Server failed to start: %v", err)
+	case sig := <-stop:
+		log.Printf("Received %v, draining connections (up to %ds)", sig, cfg.ShutdownTimeout)
+	}
+
+	healthHandler.SetDraining()
+	stopSchedules()
+	stopHealthChecks()
+	if cfg.ShutdownDelay > 0 {
+		// Give load balancers time to see /readyz fail before the
+		// listener closes
+		time.Sleep(time.Duration(cfg.ShutdownDelay) * time.Second)
+	}
+	var background []backgroundWork
+	clients := []io.Closer{providerRegistry, directory}
+	if consumer != nil {
+		// Its reviews run inline and trigger the other kinds of work
+		background = append(background, backgroundWork{"queued review requests", consumer})
+		clients = append(clients, broker)
+	}
+	background = append(background,
+		backgroundWork{"shadow reviews", shadower},
+		backgroundWork{"asynchronous reviews", jobQueue},
+		backgroundWork{"review callbacks", webhooks},
+		backgroundWork{"notifications", notifier},
+	)
+	clients = append(clients, reviewHistory)
+	shutdown(server, time.Duration(cfg.ShutdownTimeout)*time.Second, background, clients...)
+}
+
+// backgroundWork is work that outlives the request that started it
+type backgroundWork struct {
+	name string
+	work interface {
+		Wait(ctx context.Context) error
+	}
+}
+
+// shutdown stops accepting connections, waits up to timeout for in-flight
+// requests and then each kind of background work in order, then closes the
+// provider clients
+func shutdown(server *http.Server, timeout time.Duration, background []backgroundWork, clients ...io.Closer) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Warning: requests still in flight after %v, closing connections: %v", timeout, err)
+		server.Close()
+	}
+	for _, b := range background {
+		if err := b.work.Wait(ctx); err != nil {
+			log.Printf("Warning: abandoning %s still in flight: %v", b.name, err)
+		}
+	}
+	for _, c := range clients {
+		if err := c.Close(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	log.Println("✓ Server stopped")
+}
+
+func healthCheckHandler(maintenance *middleware.MaintenanceMode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"status":"healthy","service":"ai-gateway","read_only":%t}`, maintenance.Enabled())
+	}
+}
+
+// readinessChecks lists what /readyz verifies: every provider with an API
+// key initialized, the directories of the configured stores exist and the
+// review history database answers
+func readinessChecks(cfg *config.Config, manifest *providers.Manifest, registry *providers.Registry, reviews *history.Store) []handlers.ReadinessCheck {
+	return []handlers.ReadinessCheck{
+		{Name: "providers", Check: func(ctx context.Context) error {
+			if missing := manifest.Missing(registry, config.Secret); len(missing) > 0 {
+				return fmt.Errorf("providers not initialized: %s", strings.Join(missing, ", "))
+			}
+			if len(registry.List()) == 0 {
+				return fmt.Errorf("no providers registered")
+			}
+			return nil
+		}},
+		{Name: "storage", Check: func(ctx context.Context) error {
+			for _, path := range []string{cfg.FeedbackPath, cfg.RAGStorePath, cfg.ShadowOutputPath} {
+				if path == "" {
+					continue
+				}
+				dir := filepath.Dir(path)
+				if info, err := os.Stat(dir); err != nil {
+					return err
+				} else if !info.IsDir() {
+					return fmt.Errorf("%s is not a directory", dir)
+				}
+			}
+			return nil
+		}},
+		{Name: "history", Check: reviews.Ping},
 	}
 }
 
-func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
+// openAPIHandler serves the OpenAPI document
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
 		return
 	}
 
+	middleware.RawResponse(r.Context())
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `{"status":"healthy","service":"ai-gateway"}`)
+	w.Write(openapi.Spec())
+}
+
+// defaultManifest describes the built-in providers configured through
+// GOOGLE_API_KEY, OPENAI_API_KEY and ANTHROPIC_API_KEY, reached through
+// PROVIDER_PROXY when it is set
+func defaultManifest(cfg *config.Config) *providers.Manifest {
+	return &providers.Manifest{Providers: []providers.ProviderSpec{
+		{
+			Name:         "google",
+			Type:         providers.TypeGoogle,
+			APIKeyEnv:    "GOOGLE_API_KEY",
+			KeySelection: cfg.KeySelection,
+			BaseURL:      cfg.GeminiEndpoint,
+			Transport:    cfg.GeminiTransport,
+			APIVersion:   cfg.GeminiAPIVersion,
+			CacheTTL:     cfg.GeminiCacheTTL,
+			ProxyEnv:     "PROVIDER_PROXY",
+		},
+		{Name: "openai", Type: providers.TypeOpenAI, APIKeyEnv: "OPENAI_API_KEY", KeySelection: cfg.KeySelection, Batch: cfg.OpenAIBatch, ProxyEnv: "PROVIDER_PROXY"},
+		{Name: "anthropic", Type: providers.TypeAnthropic, APIKeyEnv: "ANTHROPIC_API_KEY", KeySelection: cfg.KeySelection, ProxyEnv: "PROVIDER_PROXY"},
+	}}
+}
+
+// newKnowledgeStore creates the repository knowledge base using the
+// configured embedding provider, or the first registered provider that
+// supports embeddings. It returns nil if none does.
+func newKnowledgeStore(cfg *config.Config, registry *providers.Registry) (*knowledge.Store, error) {
+	candidates := []string{"openai", "google"}
+	if cfg.RAGEmbeddingProvider != "" {
+		candidates = []string{cfg.RAGEmbeddingProvider}
+	}
+
+	for _, name := range candidates {
+		provider, err := registry.Get(name)
+		if err != nil {
+			continue
+		}
+		embedder, ok := provider.(providers.Embedder)
+		if !ok {
+			return nil, fmt.Errorf("provider %s does not support embeddings", name)
+		}
+		store, err := knowledge.NewStore(embedder, cfg.RAGEmbeddingModel, cfg.RAGStorePath)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("✓ Repository knowledge base enabled (embeddings: %s)", name)
+		return store, nil
+	}
+
+	if cfg.RAGEmbeddingProvider != "" {
+		return nil, fmt.Errorf("embedding provider %s is not configured", cfg.RAGEmbeddingProvider)
+	}
+	return nil, nil
+}
+
+// printNewKey generates a random client key and prints the secret, to hand
+// to the client, and the entry to add to the API_KEY_STORE file
+func printNewKey(name, owner, scopes, models, tenant, tier string) {
+	secret, err := middleware.GenerateSecret()
+	if err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+	modelList := splitFlagList(models)
+	if err := middleware.CheckModelPatterns(modelList); err != nil {
+		log.Fatalf("Invalid -key-models: %v", err)
+	}
+
+	key := middleware.NewKey(secret, name, owner, splitFlagList(scopes), modelList)
+	key.Tenant = tenant
+	key.Tier = tier
+	entry, err := json.MarshalIndent(key, "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode key: %v", err)
+	}
+	fmt.Printf("Secret (shown once): %s\n\nAPI_KEY_STORE entry:\n%s\n", secret, entry)
+}
+
+// splitFlagList splits a comma-separated flag value, dropping empty entries
+func splitFlagList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// notificationRoutes builds the review notification routes from
+// NOTIFICATIONS_FILE and the SLACK_*, TEAMS_*, NOTIFY_* and EMAIL_REPORT_TO
+// variables, which notify of every review
+func notificationRoutes(cfg *config.Config) []*notify.Route {
+	mailer := notify.NewMailer(notify.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+		TLS:      cfg.SMTPTLS,
+	})
+
+	var routes []*notify.Route
+	if cfg.NotificationsFile != "" {
+		loaded, err := notify.Load(cfg.NotificationsFile, config.Secret, mailer)
+		if err != nil {
+			log.Fatalf("Configuration error: %v", err)
+		}
+		routes = append(routes, loaded...)
+		log.Printf("✓ %d notification routes loaded from %s", len(loaded), cfg.NotificationsFile)
+	}
+
+	var everyReview []notify.Notifier
+	if cfg.SlackWebhookURL != "" || cfg.SlackBotToken != "" {
+		slack, err := notify.NewSlack(cfg.SlackWebhookURL, cfg.SlackBotToken, cfg.SlackChannel)
+		if err != nil {
+			log.Fatalf("Configuration error: %v", err)
+		}
+		everyReview = append(everyReview, slack)
+	}
+	if cfg.TeamsWebhookURL != "" {
+		everyReview = append(everyReview, notify.NewTeams(cfg.TeamsWebhookURL))
+	}
+	if cfg.NotifyWebhookURL != "" {
+		everyReview = append(everyReview, notify.NewWebhook(cfg.NotifyWebhookURL, cfg.NotifyWebhookKey))
+	}
+	if len(cfg.EmailReportTo) > 0 {
+		email, err := notify.NewEmail(mailer, cfg.EmailReportTo)
+		if err != nil {
+			log.Fatalf("Configuration error: EMAIL_REPORT_TO: %v", err)
+		}
+		everyReview = append(everyReview, email)
+	}
+	if len(everyReview) > 0 {
+		routes = append(routes, notify.NewRoute("environment", everyReview...))
+	}
+	return routes
 }
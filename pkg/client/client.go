@@ -0,0 +1,338 @@
+// Package client is a Go client for the AI Gateway HTTP API.
+//
+// Basic usage:
+//
+//	c := client.New("https://ai-gateway.example.com", os.Getenv("AI_GATEWAY_KEY"))
+//	resp, err := c.Review(ctx, &client.ReviewRequest{
+//		AIProvider: "google",
+//		Language:   "go",
+//		GitDiff:    diffText,
+//	})
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/models"
+)
+
+// Request and response types shared with the gateway
+type (
+	ReviewRequest  = models.ReviewRequest
+	ReviewResponse = models.ReviewResponse
+	GitInfo        = models.GitInfo
+	GitUser        = models.GitUser
+	Diagnostic     = models.Diagnostic
+	Location       = models.Location
+	Range          = models.Range
+	Position       = models.Position
+	Code           = models.Code
+	Source         = models.Source
+)
+
+// APIError is returned when the gateway responds with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ai-gateway: status %d: %s", e.StatusCode, e.Message)
+}
+
+// Temporary reports whether the request may succeed if retried
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Client is an AI Gateway API client. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+	compress   bool
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the underlying HTTP client
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets the number of retries for temporary failures and the
+// initial backoff, which doubles after every attempt
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// WithCompression gzips request bodies, which shrinks large diffs several
+// times over on slow links. The gateway must accept "Content-Encoding: gzip".
+// Responses are decompressed whether or not it is set.
+func WithCompression() Option {
+	return func(c *Client) {
+		c.compress = true
+	}
+}
+
+// New creates a new client for the gateway at baseURL
+func New(baseURL, apiKey string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+		maxRetries: 3,
+		backoff:    time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Health checks that the gateway is up
+func (c *Client) Health(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/health", "", nil, "", nil)
+}
+
+// Review submits a diff for review as a JSON request. Temporary failures are
+// retried with the same idempotency key, so the gateway never runs the same
+// review twice.
+func (c *Client) Review(ctx context.Context, request *ReviewRequest) (*ReviewResponse, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	var response ReviewResponse
+	err = c.do(ctx, http.MethodPost, "/review", "application/json", func() (io.Reader, error) {
+		return bytes.NewReader(body), nil
+	}, newIdempotencyKey(), &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ReviewStream submits a review as multipart/form-data, streaming the diff
+// from r instead of buffering it in memory. request.GitDiff is ignored.
+//
+// If r implements io.Seeker the upload is retried on temporary failures;
+// otherwise it is attempted once.
+func (c *Client) ReviewStream(ctx context.Context, request *ReviewRequest, r io.Reader) (*ReviewResponse, error) {
+	metadata := *request
+	metadata.GitDiff = ""
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	seeker, canRetry := r.(io.Seeker)
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	attempt := 0
+
+	body := func() (io.Reader, error) {
+		if attempt > 0 {
+			if !canRetry {
+				return nil, errors.New("diff reader is not seekable; cannot retry")
+			}
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("failed to rewind diff: %w", err)
+			}
+		}
+		attempt++
+
+		pr, pw := io.Pipe()
+		mw := multipart.NewWriter(pw)
+		if err := mw.SetBoundary(boundary); err != nil {
+			return nil, err
+		}
+		go func() {
+			err := writeMultipart(mw, metadataJSON, r)
+			pw.CloseWithError(err)
+		}()
+		return pr, nil
+	}
+
+	var response ReviewResponse
+	contentType := "multipart/form-data; boundary=" + boundary
+	if err := c.do(ctx, http.MethodPost, "/review", contentType, body, newIdempotencyKey(), &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// writeMultipart writes the metadata field and git_diff file to mw
+func writeMultipart(mw *multipart.Writer, metadata []byte, diff io.Reader) error {
+	if err := mw.WriteField("metadata", string(metadata)); err != nil {
+		return err
+	}
+	part, err := mw.CreateFormFile("git_diff", "changes.diff")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, diff); err != nil {
+		return err
+	}
+	return mw.Close()
+}
+
+// do sends a request, retrying temporary failures, and decodes the JSON
+// response into out. body is called once per attempt.
+func (c *Client) do(ctx context.Context, method, path, contentType string, body func() (io.Reader, error), idempotencyKey string, out interface{}) error {
+	backoff := c.backoff
+	var lastErr error
+
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		var reader io.Reader
+		if body != nil {
+			r, err := body()
+			if err != nil {
+				if lastErr != nil {
+					return lastErr
+				}
+				return err
+			}
+			reader = r
+			if c.compress {
+				reader = gzipReader(reader)
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if reader != nil && c.compress {
+			req.Header.Set("Content-Encoding", "gzip")
+		}
+		if c.apiKey != "" {
+			req.Header.Set("X-API-Key", c.apiKey)
+		}
+		if idempotencyKey != "" {
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			lastErr = fmt.Errorf("failed to send request: %w", err)
+			continue
+		}
+
+		err = decodeResponse(resp, out)
+		resp.Body.Close()
+		if err == nil {
+			return nil
+		}
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || !apiErr.Temporary() {
+			return err
+		}
+		lastErr = err
+		if wait := retryAfter(resp); wait > backoff {
+			backoff = wait
+		}
+	}
+
+	return lastErr
+}
+
+// gzipReader compresses r as it is read. A closable r, such as the pipe of
+// a streamed upload, is closed once it is consumed or the request fails.
+func gzipReader(r io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(pw)
+		_, err := io.Copy(gz, r)
+		if err == nil {
+			err = gz.Close()
+		}
+		pw.CloseWithError(err)
+		if closer, ok := r.(io.Closer); ok {
+			closer.Close()
+		}
+	}()
+	return pr
+}
+
+// decodeResponse turns a response into out or an *APIError
+func decodeResponse(resp *http.Response, out interface{}) error {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errorBody struct {
+			Error string `json:"error"`
+		}
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &errorBody) == nil && errorBody.Error != "" {
+			message = errorBody.Error
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: message}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// retryAfter parses a Retry-After header given in seconds
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// newIdempotencyKey generates a random key for a logical request
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/config"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/middleware"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/prompt"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/providers"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/ratelimit"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/routing"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/scheduler"
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/vcr"
+	"github.com/joho/godotenv"
+)
+
+// dotEnvFile is the optional file of local environment variables
+const dotEnvFile = ".env"
+
+// secretVariables are the credential variables that may be read from files
+var secretVariables = []string{"API_KEYS", "ADMIN_API_KEY", "GITHUB_TOKEN", "GOOGLE_API_KEY", "OPENAI_API_KEY", "ANTHROPIC_API_KEY"}
+
+// reloader applies configuration changes to the running gateway. Every
+// setting it touches is swapped atomically, so reviews in flight finish
+// with the values they started with.
+type reloader struct {
+	cfg       *config.Config // Startup configuration; only its credentials are replaced
+	manifest  *providers.Manifest
+	registry  *providers.Registry
+	keyStore  *middleware.KeyStore // Nil when API keys are disabled
+	adminKeys *middleware.KeySet
+	limiter   *ratelimit.Limiter
+	scheduler *scheduler.Scheduler
+	dotEnv    map[string]bool // Variables set from .env rather than the real environment
+}
+
+// loadDotEnv sets variables from .env that aren't already set. It returns
+// the names it set, which later reloads may change.
+func loadDotEnv() map[string]bool {
+	set := make(map[string]bool)
+	values, err := godotenv.Read(dotEnvFile)
+	if err != nil {
+		return set
+	}
+	for name, value := range values {
+		if _, ok := os.LookupEnv(name); !ok {
+			os.Setenv(name, value)
+			set[name] = true
+		}
+	}
+	return set
+}
+
+// watch reloads on SIGHUP and, if interval is positive, whenever a watched
+// file changes
+func (r *reloader) watch(interval time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	state := r.fileState()
+	for {
+		select {
+		case <-signals:
+			r.reload("SIGHUP")
+		case <-tick:
+			current := r.fileState()
+			if current == state {
+				continue
+			}
+			r.reload("file change")
+		}
+		state = r.fileState()
+	}
+}
+
+// fileState summarizes the size and modification time of every file the
+// configuration is read from
+func (r *reloader) fileState() string {
+	paths := []string{dotEnvFile, config.FilePath(), config.Lookup("API_KEY_STORE"), config.Lookup("CATEGORIES_FILE"), config.Lookup("ROUTING_FILE")}
+	for _, name := range secretVariables {
+		paths = append(paths, config.Lookup(name+"_FILE"))
+	}
+	for _, spec := range r.manifest.Providers {
+		paths = append(paths, config.Lookup(spec.APIKeyEnv+"_FILE"))
+	}
+	if dir := config.Lookup("PROMPT_TEMPLATE_DIR"); dir != "" {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*"))
+		paths = append(paths, matches...)
+	}
+	if dir := config.Lookup("PROMPT_VERSIONS_DIR"); dir != "" {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*", "*"))
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	var state strings.Builder
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			fmt.Fprintf(&state, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return state.String()
+}
+
+// reload rereads the environment, .env, the configuration file and *_FILE
+// files and applies API
+// keys, defaults, rate limits, concurrency, prompt templates and versions,
+// review categories, routing rules and provider keys. Nothing is applied if the new configuration is invalid.
+func (r *reloader) reload(reason string) {
+	r.refreshDotEnv()
+	if path := config.FilePath(); path != "" {
+		if err := config.LoadFile(path); err != nil {
+			log.Printf("Reload (%s) rejected: %v", reason, err)
+			return
+		}
+	}
+	fresh := config.Load()
+	if err := fresh.Validate(); err != nil {
+		log.Printf("Reload (%s) rejected: %v", reason, err)
+		return
+	}
+
+	// Parse everything before applying anything
+	tiers, err := ratelimit.ParseTiers(fresh.RateLimitTiers)
+	if err != nil {
+		log.Printf("Reload (%s) rejected: RATE_LIMIT_TIERS: %v", reason, err)
+		return
+	}
+	keyTiers, err := ratelimit.ParseKeyTiers(fresh.APIKeyTiers, tiers)
+	if err != nil {
+		log.Printf("Reload (%s) rejected: API_KEY_TIERS: %v", reason, err)
+		return
+	}
+	if _, err := ratelimit.NewLimiter(tiers, fresh.DefaultRateLimitTier); err != nil {
+		log.Printf("Reload (%s) rejected: %v", reason, err)
+		return
+	}
+	var templates *prompt.Templates
+	if fresh.PromptTemplateDir != "" {
+		if templates, err = prompt.LoadTemplates(fresh.PromptTemplateDir); err != nil {
+			log.Printf("Reload (%s) rejected: %v", reason, err)
+			return
+		}
+	}
+	var versions map[string]*prompt.Templates
+	var experiment *prompt.Experiment
+	if fresh.PromptVersionsDir != "" {
+		if versions, err = prompt.LoadVersions(fresh.PromptVersionsDir); err != nil {
+			log.Printf("Reload (%s) rejected: %v", reason, err)
+			return
+		}
+		if experiment, err = prompt.ParseExperiment(fresh.PromptExperiment, versions); err != nil {
+			log.Printf("Reload (%s) rejected: PROMPT_EXPERIMENT: %v", reason, err)
+			return
+		}
+	}
+	var taxonomy *prompt.Taxonomy
+	if fresh.CategoriesFile != "" {
+		if taxonomy, err = prompt.LoadTaxonomy(fresh.CategoriesFile); err != nil {
+			log.Printf("Reload (%s) rejected: %v", reason, err)
+			return
+		}
+	}
+	if _, err := r.registry.Get(fresh.DefaultProvider); err == nil {
+		if err := r.registry.CheckModel(fresh.DefaultProvider, fresh.DefaultModel); err != nil && !fresh.AllowUnlistedModels {
+			log.Printf("Reload (%s) rejected: DEFAULT_AI_MODEL: %v", reason, err)
+			return
+		}
+	}
+
+	var router *routing.Router
+	if fresh.RoutingFile != "" {
+		if router, err = routing.Load(fresh.RoutingFile); err == nil {
+			err = router.Check(r.registry)
+		}
+		if err != nil {
+			log.Printf("Reload (%s) rejected: ROUTING_FILE: %v", reason, err)
+			return
+		}
+	}
+
+	if r.keyStore != nil {
+		if err := r.keyStore.Load(middleware.HashKeys(fresh.APIKeys, keyTiers)); err != nil {
+			log.Printf("Reload (%s): keeping the current client keys: %v", reason, err)
+		}
+	}
+	r.adminKeys.Set([]string{fresh.AdminAPIKey})
+	r.cfg.GitHubToken.Set(fresh.GitHubToken.Get())
+	r.registry.AllowUnlistedModels(fresh.AllowUnlistedModels)
+	r.registry.SetDefaults(fresh.DefaultProvider, fresh.DefaultModel)
+	r.limiter.Update(tiers, fresh.DefaultRateLimitTier)
+	if (r.scheduler == nil) != (fresh.MaxConcurrentReviews <= 0) {
+		log.Println("Warning: enabling or disabling MAX_CONCURRENT_REVIEWS requires a restart")
+	}
+	r.scheduler.SetCapacity(fresh.MaxConcurrentReviews)
+	prompt.SetTemplates(templates)
+	prompt.SetVersions(versions, experiment)
+	prompt.SetTaxonomy(taxonomy)
+	routing.Set(router)
+	rotated := r.manifest.RotateKeys(r.registry, config.Secret)
+	if len(rotated) > 0 && r.cfg.HealthCheckInterval > 0 && r.cfg.VCRMode != vcr.ModeReplay {
+		// Clear or confirm the health of the rotated keys now
+		go r.registry.CheckHealth(context.Background())
+	}
+
+	log.Printf("✓ Configuration reloaded (%s): %d API keys, default %s, provider keys rotated: %s",
+		reason, len(r.keyStore.List()), fresh.DefaultProvider, strings.Join(rotated, ", "))
+}
+
+// refreshDotEnv rereads .env, updating the variables it set at startup
+// and any that are still unset. Variables from the real environment win.
+func (r *reloader) refreshDotEnv() {
+	values, err := godotenv.Read(dotEnvFile)
+	if err != nil {
+		values = map[string]string{}
+	}
+	for name := range r.dotEnv {
+		if _, ok := values[name]; !ok {
+			os.Unsetenv(name)
+			delete(r.dotEnv, name)
+		}
+	}
+	for name, value := range values {
+		if _, ok := os.LookupEnv(name); ok && !r.dotEnv[name] {
+			continue
+		}
+		os.Setenv(name, value)
+		r.dotEnv[name] = true
+	}
+}
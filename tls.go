@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sotatek-DungNguyen16/ai-review-gateway/internal/config"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// serverTLS builds the TLS configuration for the server, or returns nil
+// when TLS is disabled. For ACME it also returns the handler answering
+// HTTP-01 challenges on TLS_AUTOCERT_HTTP_ADDR.
+func serverTLS(cfg *config.Config) (*tls.Config, http.Handler, error) {
+	if !cfg.TLSEnabled() {
+		return nil, nil, nil
+	}
+
+	var tlsConfig *tls.Config
+	var challenge http.Handler
+	if len(cfg.TLSAutocertDomains) > 0 {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.TLSAutocertDomains...),
+			Cache:      autocert.DirCache(cfg.TLSAutocertCacheDir),
+			Email:      cfg.TLSAutocertEmail,
+		}
+		tlsConfig = manager.TLSConfig()
+		challenge = manager.HTTPHandler(nil)
+	} else {
+		certificate, err := newCertificateFile(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsConfig = &tls.Config{GetCertificate: certificate.get}
+	}
+	tlsConfig.MinVersion = tls.VersionTLS12
+
+	if cfg.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read TLS_CLIENT_CA_FILE: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("TLS_CLIENT_CA_FILE %s contains no certificates", cfg.TLSClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		if cfg.TLSClientAuth == "optional" {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	return tlsConfig, challenge, nil
+}
+
+// serveChallenges answers ACME HTTP-01 challenges on addr and redirects
+// other requests to HTTPS
+func serveChallenges(addr string, challenge http.Handler) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           challenge,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if err := server.ListenAndServe(); err != nil {
+		log.Printf("Warning: ACME challenge listener on %s stopped: %v", addr, err)
+	}
+}
+
+// certificateFile serves a certificate and key from disk, reloading them
+// when either file changes so renewed certificates apply without a restart
+type certificateFile struct {
+	certPath string
+	keyPath  string
+
+	mu          sync.Mutex
+	certificate *tls.Certificate
+	modified    time.Time
+}
+
+// newCertificateFile loads the certificate pair, failing if it is invalid
+func newCertificateFile(certPath, keyPath string) (*certificateFile, error) {
+	c := &certificateFile{certPath: certPath, keyPath: keyPath}
+	if _, err := c.get(nil); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// get returns the current certificate, reloading it if the files changed.
+// A pair that fails to load keeps the previous certificate in use.
+func (c *certificateFile) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	modified := c.modTime()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.certificate != nil && !modified.After(c.modified) {
+		return c.certificate, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(c.certPath, c.keyPath)
+	if err != nil {
+		if c.certificate != nil {
+			log.Printf("Warning: failed to reload TLS certificate, keeping the previous one: %v", err)
+			c.modified = modified
+			return c.certificate, nil
+		}
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	if c.certificate != nil {
+		log.Printf("✓ Reloaded TLS certificate %s", c.certPath)
+	}
+	c.certificate = &certificate
+	c.modified = modified
+	return c.certificate, nil
+}
+
+// modTime returns the latest modification time of the certificate files
+func (c *certificateFile) modTime() time.Time {
+	var latest time.Time
+	for _, path := range []string{c.certPath, c.keyPath} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}